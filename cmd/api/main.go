@@ -11,6 +11,7 @@ import (
 
 	"seaply/internal/config"
 	"seaply/internal/database"
+	"seaply/internal/domain"
 	"seaply/internal/middleware"
 	"seaply/internal/payment"
 	"seaply/internal/provider"
@@ -48,6 +49,14 @@ func main() {
 		log.Fatal().Err(err).Msg("Failed to load config")
 	}
 
+	// `api migrate ...` manages the schema instead of starting the server
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(cfg, os.Args[2:]); err != nil {
+			log.Fatal().Err(err).Msg("Migration failed")
+		}
+		return
+	}
+
 	log.Info().
 		Str("environment", cfg.Server.Environment).
 		Str("port", cfg.Server.Port).
@@ -61,6 +70,15 @@ func main() {
 	defer db.Close()
 	log.Info().Msg("Connected to PostgreSQL")
 
+	// Apply pending migrations before anything reads the schema
+	if cfg.Database.MigrateOnBoot {
+		applied, err := migrateOnBoot(db)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to migrate database")
+		}
+		log.Info().Int("applied", applied).Msg("Database schema is up to date")
+	}
+
 	// Initialize Redis
 	redis, err := database.NewRedisClient(cfg.Redis)
 	if err != nil {
@@ -83,10 +101,24 @@ func main() {
 	providerManager := initializeProviders(cfg)
 	log.Info().Msg("Initialized product providers")
 
+	// Providers with credentials stored in the database replace their environment configuration
+	providerRegistry := services.NewProviderRegistry(db.Pool, redis, providerManager, cfg.App.CredentialKey)
+	if err := providerRegistry.LoadAll(context.Background()); err != nil {
+		log.Warn().Err(err).Msg("Failed to load providers from database")
+	}
+	providerRegistry.Subscribe(context.Background())
+
 	// Initialize payment gateways
 	paymentManager := initializePaymentGateways(cfg)
 	log.Info().Msg("Initialized payment gateways")
 
+	// Gateways with credentials stored in the database replace their environment configuration
+	gatewayRegistry := services.NewGatewayRegistry(db.Pool, redis, paymentManager, cfg.App.CredentialKey)
+	if err := gatewayRegistry.LoadAll(context.Background()); err != nil {
+		log.Warn().Err(err).Msg("Failed to load payment gateways from database")
+	}
+	gatewayRegistry.Subscribe(context.Background())
+
 	// Start provider and payment health checks
 	ctx := context.Background()
 	providerManager.StartHealthCheck(ctx, 5*time.Minute)
@@ -97,15 +129,114 @@ func main() {
 	emailService := services.NewEmailService()
 	log.Info().Msg("Initialized email service")
 
+	// Emails go through the outbox so they survive SMTP failures and restarts
+	var emailSender services.Notifier = services.NewSMTPNotifier(emailService)
+	if cfg.Notification.Driver == "memory" {
+		emailSender = services.NewMemoryNotifier(services.ChannelEmail)
+	}
+	emailOutbox := services.NewEmailOutbox(db.Pool, emailSender, services.EmailOutboxConfig{
+		MaxAttempts: cfg.Notification.EmailMaxAttempts,
+	})
+	emailOutbox.Start(ctx)
+	log.Info().Msg("Started email outbox worker")
+
+	notificationService, err := initializeNotifications(cfg, emailOutbox)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize notification service")
+	}
+	notificationService.Start(ctx, db.Pool, 5*time.Minute)
+	log.Info().Str("driver", cfg.Notification.Driver).Msg("Initialized notification service")
+
 	// Initialize middleware
-	authMiddleware := middleware.NewAuthMiddleware(jwtService)
-	rateLimiter := middleware.NewRateLimiter(redis)
+	auditService := services.NewAuditService(db.Pool)
+	authMiddleware := middleware.NewAuthMiddleware(jwtService, auditService)
+	rateLimiter := middleware.NewRateLimiter(redis, middleware.RateLimiterOptions{
+		FailOpen:         cfg.RateLimit.FailOpen,
+		WebhookAllowlist: cfg.RateLimit.WebhookAllowlist,
+		TierResolver: func(ctx context.Context, userID string) (string, error) {
+			var level string
+			err := db.Pool.QueryRow(ctx, `SELECT membership_level::text FROM users WHERE id = $1`, userID).Scan(&level)
+			if err != nil {
+				return "", err
+			}
+			if level == string(domain.MembershipRoyal) {
+				return middleware.RateLimitTierRoyal, nil
+			}
+			return middleware.RateLimitTierDefault, nil
+		},
+	})
+	rateLimiter.Start(ctx, db.Pool, 5*time.Minute)
+
+	// Responses of POST requests sent with an Idempotency-Key are replayed for retries
+	idempotency := middleware.NewIdempotencyStore(db.Pool, middleware.IdempotencyConfig{})
+	idempotency.Start(ctx, time.Hour)
+	catalogCache := middleware.NewCatalogCache(redis, middleware.CatalogCacheConfig{
+		Enabled:     cfg.Cache.Enabled,
+		TTL:         cfg.Cache.TTL,
+		LocalTTL:    cfg.Cache.LocalTTL,
+		LockTimeout: cfg.Cache.LockTimeout,
+	})
+	if catalogCache.Enabled() {
+		catalogCache.Subscribe(ctx)
+		log.Info().Dur("ttl", cfg.Cache.TTL).Msg("Catalogue cache enabled")
+	}
+
+	// Provider costs are compared against buy prices on the interval from settings
+	priceWatcher := services.NewPriceWatcher(db.Pool, providerManager, notificationService, func(ctx context.Context) {
+		catalogCache.Invalidate(ctx, middleware.CacheSKUs)
+	})
+	priceWatcher.Start(ctx)
+	log.Info().Msg("Started provider price watcher")
+
+	// Provider balances are snapshotted for burn rate, alerts and routing
+	balanceMonitor := services.NewBalanceMonitor(db.Pool, providerManager, notificationService)
+	balanceMonitor.Start(ctx)
+	log.Info().Msg("Started provider balance monitor")
+
+	// Invoice text outside the built-in font, such as Thai product names, uses these fonts
+	for _, path := range cfg.App.InvoiceFonts {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			err = utils.RegisterPDFFont(data)
+		}
+		if err != nil {
+			log.Warn().Err(err).Str("path", path).Msg("Failed to load invoice font")
+		}
+	}
+
+	// Invoice PDFs are cached in S3 and downloaded through signed links
+	invoiceService := services.NewInvoiceService(db.Pool, s3Storage, services.InvoiceConfig{
+		APIBaseURL:      cfg.App.BaseURL,
+		FrontendBaseURL: cfg.App.FrontendBaseURL,
+		SigningKey:      cfg.JWT.SecretKey,
+	})
+
+	// Invoice status changes are streamed to invoice pages through Redis pub/sub
+	invoiceStream := services.NewInvoiceStream(db.Pool, redis, cfg.JWT.SecretKey)
+	invoiceStream.Start(ctx)
+
+	// Outbound webhooks, delivered with retries from the webhook_deliveries table
+	webhooks := services.NewWebhookDispatcher(db.Pool, cfg.App.CredentialKey, services.WebhookConfig{})
+	webhooks.Start(ctx)
+
+	// Guests reach their orders with a one-time code sent to their email or phone
+	guestAccess := services.NewGuestAccess(redis, notificationService, cfg.JWT.SecretKey)
+
+	// Sensitive admin actions above the configured thresholds wait for a second admin
+	approvals := services.NewApprovalService(db.Pool, notificationService)
+	approvals.Start(ctx, 5*time.Minute)
+
+	// Order and deposit checkouts are scored for fraud, risky ones are held for review
+	riskEngine := services.NewRiskEngine(db.Pool, notificationService)
+
+	// H2H partner API, signed with per-key secrets stored encrypted
+	partnerService := services.NewPartnerService(db.Pool, redis, webhooks, cfg.App.CredentialKey)
 
 	// Setup router
 	r := chi.NewRouter()
 
 	// Global middleware
-	r.Use(chimiddleware.RealIP)
+	r.Use(middleware.RealIP(cfg.Server.TrustedProxies))
 	r.Use(middleware.RequestID)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
@@ -116,8 +247,8 @@ func main() {
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{cfg.Server.AllowOrigins},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-Request-ID"},
-		ExposedHeaders:   []string{"X-Request-ID", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-Request-ID", "Idempotency-Key", "X-Guest-Token", "X-Device-ID"},
+		ExposedHeaders:   []string{"X-Request-ID", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Retry-After", "Idempotent-Replayed"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
@@ -131,18 +262,36 @@ func main() {
 	})
 
 	// API routes
-	router.SetupRoutes(r, &router.Dependencies{
-		Config:          cfg,
-		DB:              db,
-		Redis:           redis,
-		S3:              s3Storage,
-		JWTService:      jwtService,
-		EmailService:    emailService,
-		AuthMiddleware:  authMiddleware,
-		RateLimiter:     rateLimiter,
-		ProviderManager: providerManager,
-		PaymentManager:  paymentManager,
-	})
+	routerDeps := &router.Dependencies{
+		Config:              cfg,
+		DB:                  db,
+		Redis:               redis,
+		S3:                  s3Storage,
+		JWTService:          jwtService,
+		EmailService:        emailService,
+		NotificationService: notificationService,
+		EmailOutbox:         emailOutbox,
+		AuthMiddleware:      authMiddleware,
+		RateLimiter:         rateLimiter,
+		CatalogCache:        catalogCache,
+		ProviderManager:     providerManager,
+		PaymentManager:      paymentManager,
+		PriceWatcher:        priceWatcher,
+		BalanceMonitor:      balanceMonitor,
+		ProviderRegistry:    providerRegistry,
+		GatewayRegistry:     gatewayRegistry,
+		InvoiceService:      invoiceService,
+		InvoiceStream:       invoiceStream,
+		PartnerService:      partnerService,
+		Webhooks:            webhooks,
+		Idempotency:         idempotency,
+		GuestAccess:         guestAccess,
+		Audit:               auditService,
+		Approvals:           approvals,
+		Risk:                riskEngine,
+	}
+	router.SetupRoutes(r, routerDeps)
+	router.StartJobs(ctx, routerDeps)
 
 	// Create server
 	server := &http.Server{
@@ -194,6 +343,49 @@ func printBanner() {
 	fmt.Println(banner)
 }
 
+// initializeNotifications initializes the notification service and its channels
+func initializeNotifications(cfg *config.Config, emailOutbox *services.EmailOutbox) (*services.NotificationService, error) {
+	templates, err := services.NewTemplateRenderer(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	service := services.NewNotificationService(templates, services.NotificationConfig{
+		Workers:         cfg.Notification.Workers,
+		MaxRetries:      cfg.Notification.MaxRetries,
+		FrontendBaseURL: cfg.App.FrontendBaseURL,
+		SecurityChatID:  cfg.Notification.TelegramSecurityChatID,
+	})
+
+	service.Register(services.NewOutboxNotifier(emailOutbox))
+	log.Info().Msg("Registered email notifier")
+
+	if cfg.Notification.Driver == "memory" {
+		service.Register(services.NewMemoryNotifier(services.ChannelWhatsApp))
+		service.Register(services.NewMemoryNotifier(services.ChannelTelegram))
+		return service, nil
+	}
+
+	if cfg.Notification.WhatsAppAPIURL != "" && cfg.Notification.WhatsAppAPIKey != "" {
+		service.Register(services.NewWhatsAppNotifier(
+			cfg.Notification.WhatsAppAPIURL,
+			cfg.Notification.WhatsAppAPIKey,
+			cfg.Notification.WhatsAppSender,
+		))
+		log.Info().Msg("Registered WhatsApp notifier")
+	}
+
+	if cfg.Notification.TelegramBotToken != "" {
+		service.Register(services.NewTelegramNotifier(
+			cfg.Notification.TelegramBotToken,
+			cfg.Notification.TelegramChatID,
+		))
+		log.Info().Msg("Registered Telegram notifier")
+	}
+
+	return service, nil
+}
+
 // initializeProviders initializes all product providers
 func initializeProviders(cfg *config.Config) *provider.Manager {
 	manager := provider.NewManager()
@@ -267,15 +459,6 @@ func initializePaymentGateways(cfg *config.Config) *payment.Manager {
 	}
 
 	// Initialize BRI gateway (VA BRI via SNAP API)
-	log.Info().
-		Str("client_id", cfg.Payment.BRI.ClientID).
-		Str("partner_id", cfg.Payment.BRI.PartnerID).
-		Str("private_key_path", cfg.Payment.BRI.PrivateKeyPath).
-		Str("base_url", cfg.Payment.BRI.BaseURL).
-		Bool("has_client_id", cfg.Payment.BRI.ClientID != "").
-		Bool("has_private_key_path", cfg.Payment.BRI.PrivateKeyPath != "").
-		Msg("BRI gateway config check")
-
 	if cfg.Payment.BRI.ClientID != "" && cfg.Payment.BRI.PrivateKeyPath != "" {
 		bri, err := payment.NewBRIGateway(
 			cfg.Payment.BRI.ClientID,
@@ -288,7 +471,6 @@ func initializePaymentGateways(cfg *config.Config) *payment.Manager {
 		if err != nil {
 			log.Error().
 				Err(err).
-				Str("private_key_path", cfg.Payment.BRI.PrivateKeyPath).
 				Msg("Failed to initialize BRI gateway")
 		} else {
 			manager.Register(bri)
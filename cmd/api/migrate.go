@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"seaply/database/migrations"
+	"seaply/internal/config"
+	"seaply/internal/database"
+)
+
+const migrateUsage = "usage: api migrate up | down [steps] | to <version> | status | baseline <version>"
+
+// runMigrate handles `api migrate ...`, managing the schema with the migrations
+// embedded in the binary instead of starting the server
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	db, err := database.NewPostgresDB(cfg.Database)
+	if err != nil {
+		return fmt.Errorf("connect to database: %w", err)
+	}
+	defer db.Close()
+
+	migrator, err := database.NewMigrator(db.Pool, migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	switch args[0] {
+	case "up":
+		count, err := migrator.Up(ctx)
+		fmt.Printf("Applied %d migration(s)\n", count)
+		return err
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+		count, err := migrator.Down(ctx, steps)
+		fmt.Printf("Reverted %d migration(s)\n", count)
+		return err
+
+	case "to":
+		version, err := migrationVersionArg(args)
+		if err != nil {
+			return err
+		}
+		count, err := migrator.To(ctx, version)
+		fmt.Printf("Ran %d migration(s) to reach version %d\n", count, version)
+		return err
+
+	case "baseline":
+		version, err := migrationVersionArg(args)
+		if err != nil {
+			return err
+		}
+		count, err := migrator.Baseline(ctx, version)
+		fmt.Printf("Recorded %d migration(s) as applied\n", count)
+		return err
+
+	case "status":
+		return printMigrationStatus(ctx, migrator)
+	}
+
+	return errors.New(migrateUsage)
+}
+
+func migrationVersionArg(args []string) (int64, error) {
+	if len(args) < 2 {
+		return 0, errors.New(migrateUsage)
+	}
+	version, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("invalid migration version %q", args[1])
+	}
+	return version, nil
+}
+
+func printMigrationStatus(ctx context.Context, migrator *database.Migrator) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	pending := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, status := range statuses {
+		state := "pending"
+		switch {
+		case status.Missing:
+			state = "applied, not in this build"
+		case status.Modified:
+			state = "applied, file modified"
+		case status.Applied:
+			state = "applied"
+		default:
+			pending++
+		}
+
+		appliedAt := "-"
+		if status.AppliedAt != nil {
+			appliedAt = status.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%06d\t%s\t%s\t%s\n", status.Version, status.Name, state, appliedAt)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n%d pending, latest version %d\n", pending, migrator.Latest())
+	return nil
+}
+
+// migrateOnBoot applies the pending migrations before the server starts and returns
+// how many ran; replicas starting together wait on the migration lock
+func migrateOnBoot(db *database.PostgresDB) (int, error) {
+	migrator, err := database.NewMigrator(db.Pool, migrations.FS)
+	if err != nil {
+		return 0, err
+	}
+	return migrator.Up(context.Background())
+}
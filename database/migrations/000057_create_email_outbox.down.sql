@@ -0,0 +1,3 @@
+-- Drop email_outbox table
+DROP TRIGGER IF EXISTS update_email_outbox_updated_at ON email_outbox;
+DROP TABLE IF EXISTS public.email_outbox;
@@ -0,0 +1,49 @@
+-- Create email_outbox table for transactional emails delivered by the background worker
+CREATE TABLE IF NOT EXISTS public.email_outbox (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+
+    -- Message
+    recipient VARCHAR(255) NOT NULL,
+    subject VARCHAR(500) NOT NULL,
+    html_body TEXT NOT NULL,
+    template VARCHAR(100), -- verification, reset, order_success, invoice, ...
+
+    -- What the email is about
+    reference_type VARCHAR(50), -- USER, TRANSACTION, DEPOSIT, INVOICE
+    reference_id VARCHAR(100), -- user id or invoice number
+
+    -- Delivery
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING', -- PENDING, SENDING, SENT, FAILED
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 5,
+    last_error TEXT,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    locked_at TIMESTAMPTZ,
+    sent_at TIMESTAMPTZ,
+
+    -- Admin who queued the email manually (resend)
+    created_by UUID REFERENCES admins(id) ON DELETE SET NULL,
+
+    -- Timestamps
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+
+    CONSTRAINT email_outbox_status_check CHECK (status IN ('PENDING', 'SENDING', 'SENT', 'FAILED'))
+);
+
+-- Indexes
+CREATE INDEX idx_email_outbox_pending ON email_outbox(next_attempt_at) WHERE status = 'PENDING';
+CREATE INDEX idx_email_outbox_status ON email_outbox(status);
+CREATE INDEX idx_email_outbox_reference ON email_outbox(reference_type, reference_id);
+CREATE INDEX idx_email_outbox_recipient ON email_outbox(recipient);
+CREATE INDEX idx_email_outbox_created_at ON email_outbox(created_at DESC);
+
+-- Trigger for updated_at
+CREATE TRIGGER update_email_outbox_updated_at BEFORE UPDATE ON email_outbox
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Comments
+COMMENT ON TABLE public.email_outbox IS 'Outbound transactional emails, written in the same transaction as the business change';
+COMMENT ON COLUMN public.email_outbox.status IS 'PENDING, SENDING (claimed by a worker), SENT or FAILED (max attempts reached)';
+COMMENT ON COLUMN public.email_outbox.next_attempt_at IS 'Earliest time of the next delivery attempt (exponential backoff)';
+COMMENT ON COLUMN public.email_outbox.locked_at IS 'When a worker claimed the message; stale locks are released';
@@ -0,0 +1 @@
+DELETE FROM public.settings WHERE category = 'rate_limit';
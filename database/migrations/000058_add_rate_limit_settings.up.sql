@@ -0,0 +1,15 @@
+-- Rate limit policies per route group, loaded by the API every few minutes.
+-- requests: per IP for anonymous clients, userRequests: per authenticated user, window: seconds
+INSERT INTO public.settings (category, key, value, description) VALUES
+('rate_limit', 'public', '{"requests": 100, "userRequests": 200, "window": 60}', 'Catalogue and other public endpoints'),
+('rate_limit', 'auth', '{"requests": 10, "userRequests": 10, "window": 60, "failOpen": false}', 'Login, register and password endpoints'),
+('rate_limit', 'user', '{"requests": 100, "userRequests": 100, "window": 60}', 'Authenticated user endpoints'),
+('rate_limit', 'order', '{"requests": 30, "userRequests": 60, "window": 60}', 'Inquiries and order creation'),
+('rate_limit', 'admin', '{"requests": 200, "userRequests": 200, "window": 60}', 'Admin dashboard'),
+('rate_limit', 'webhook', '{"requests": 600, "userRequests": 600, "window": 60}', 'Webhooks from IPs outside the allowlist'),
+('rate_limit', 'royalMultiplier', '3', 'Limit multiplier for ROYAL members'),
+('rate_limit', 'partnerMultiplier', '10', 'Limit multiplier for API partners'),
+('rate_limit', 'failOpen', 'true', 'Allow requests when Redis is unavailable'),
+('rate_limit', 'webhookAllowlist', '[]', 'Gateway and provider IPs/CIDRs exempt from webhook limits'),
+('rate_limit', 'webhookEnforceAllowlist', 'false', 'Reject webhooks from IPs outside the allowlist')
+ON CONFLICT (category, key) DO NOTHING;
@@ -0,0 +1,8 @@
+-- Drop transaction_units table
+DROP TRIGGER IF EXISTS update_transaction_units_updated_at ON transaction_units;
+DROP TABLE IF EXISTS public.transaction_units;
+
+ALTER TABLE public.transactions DROP COLUMN IF EXISTS refunded_amount;
+
+ALTER TABLE public.skus DROP CONSTRAINT IF EXISTS skus_max_quantity_check;
+ALTER TABLE public.skus DROP COLUMN IF EXISTS max_quantity;
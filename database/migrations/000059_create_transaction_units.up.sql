@@ -0,0 +1,48 @@
+-- Per-SKU purchase limit, checked on inquiry and order creation
+ALTER TABLE public.skus ADD COLUMN IF NOT EXISTS max_quantity INTEGER NOT NULL DEFAULT 1;
+
+ALTER TABLE public.skus ADD CONSTRAINT skus_max_quantity_check CHECK (max_quantity >= 1);
+
+COMMENT ON COLUMN public.skus.max_quantity IS 'Maximum quantity per order, each unit is fulfilled by its own provider order';
+
+-- Amount returned to the customer for units that could not be delivered
+ALTER TABLE public.transactions ADD COLUMN IF NOT EXISTS refunded_amount BIGINT NOT NULL DEFAULT 0;
+
+COMMENT ON COLUMN public.transactions.refunded_amount IS 'Total refunded for failed units of a multi-quantity order';
+
+-- Create transaction_units table, one provider order per purchased unit
+CREATE TABLE IF NOT EXISTS public.transaction_units (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    transaction_id UUID NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+    unit_number INTEGER NOT NULL,
+
+    -- Provider order
+    ref_id VARCHAR(100) NOT NULL UNIQUE, -- {invoice_number}-{unit_number}
+    provider_ref_id VARCHAR(100),
+    serial_number TEXT,
+    status public.transaction_status NOT NULL DEFAULT 'PENDING',
+    message TEXT,
+    provider_response JSONB,
+
+    -- Refund of a failed unit
+    refunded_amount BIGINT NOT NULL DEFAULT 0,
+
+    -- Timestamps
+    completed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+
+    CONSTRAINT transaction_units_unit_key UNIQUE (transaction_id, unit_number)
+);
+
+-- Indexes
+CREATE INDEX idx_transaction_units_transaction ON transaction_units(transaction_id);
+CREATE INDEX idx_transaction_units_status ON transaction_units(status);
+
+-- Trigger for updated_at
+CREATE TRIGGER update_transaction_units_updated_at BEFORE UPDATE ON transaction_units
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Comments
+COMMENT ON TABLE public.transaction_units IS 'Units of a multi-quantity order, each sent to the provider with a derived ref_id';
+COMMENT ON COLUMN public.transaction_units.ref_id IS 'Reference sent to the provider, the invoice number suffixed with the unit number';
@@ -0,0 +1,3 @@
+ALTER TABLE public.products
+    DROP COLUMN IF EXISTS customer_data_template,
+    DROP COLUMN IF EXISTS customer_no_template;
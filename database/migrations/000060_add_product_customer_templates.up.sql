@@ -0,0 +1,11 @@
+-- Per-product mapping of product_fields inputs to the provider order payload.
+-- Placeholders use the field key, e.g. '{userId}{zoneId}' or '{"email": "{email}", "server": "{server}"}'.
+ALTER TABLE public.products
+    ADD COLUMN IF NOT EXISTS customer_no_template VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS customer_data_template JSONB;
+
+COMMENT ON COLUMN public.products.customer_no_template IS 'Template for provider customer_no built from account input keys; NULL uses userId + zoneId/serverId or phoneNumber';
+COMMENT ON COLUMN public.products.customer_data_template IS 'Provider field name to template map sent as additional customer data';
+
+-- Select options are validated against this list; store them as a JSON array
+UPDATE public.product_fields SET options = NULL WHERE options = 'null'::jsonb;
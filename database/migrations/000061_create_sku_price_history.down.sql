@@ -0,0 +1,4 @@
+DELETE FROM public.settings WHERE category = 'price_watcher';
+DROP TABLE IF EXISTS public.sku_price_history;
+ALTER TABLE public.sku_pricing DROP COLUMN IF EXISTS auto_disabled_at;
+ALTER TABLE public.skus DROP COLUMN IF EXISTS margin_rule;
@@ -0,0 +1,53 @@
+-- Provider cost tracking for the price watcher
+ALTER TABLE public.skus ADD COLUMN IF NOT EXISTS margin_rule JSONB;
+ALTER TABLE public.sku_pricing ADD COLUMN IF NOT EXISTS auto_disabled_at TIMESTAMPTZ;
+
+COMMENT ON COLUMN public.skus.margin_rule IS 'Overrides the price_watcher.marginRule setting, e.g. {"type": "fixed", "value": 1000, "floor": 500}';
+COMMENT ON COLUMN public.sku_pricing.auto_disabled_at IS 'Set when the price watcher disabled the price for a negative margin; cleared when it re-enables it';
+
+CREATE TABLE IF NOT EXISTS public.sku_price_history (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    sku_id UUID NOT NULL REFERENCES skus(id) ON DELETE CASCADE,
+    sku_pricing_id UUID REFERENCES sku_pricing(id) ON DELETE SET NULL,
+    provider_id UUID REFERENCES providers(id) ON DELETE SET NULL,
+    region_code VARCHAR(5) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+
+    -- Prices
+    old_buy_price BIGINT NOT NULL,
+    new_buy_price BIGINT NOT NULL,
+    old_sell_price BIGINT NOT NULL,
+    new_sell_price BIGINT NOT NULL,
+    change_percent NUMERIC(8,2) NOT NULL DEFAULT 0,
+
+    -- What happened
+    action VARCHAR(20) NOT NULL, -- RECORDED, ADJUSTED, DISABLED, ENABLED
+    message TEXT,
+
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+
+    CONSTRAINT sku_price_history_action_check CHECK (action IN ('RECORDED', 'ADJUSTED', 'DISABLED', 'ENABLED'))
+);
+
+-- Indexes
+CREATE INDEX idx_sku_price_history_sku ON sku_price_history(sku_id, created_at DESC);
+CREATE INDEX idx_sku_price_history_provider ON sku_price_history(provider_id);
+CREATE INDEX idx_sku_price_history_action ON sku_price_history(action);
+CREATE INDEX idx_sku_price_history_created_at ON sku_price_history(created_at DESC);
+
+-- Comments
+COMMENT ON TABLE public.sku_price_history IS 'Provider cost changes detected by the price watcher and the resulting price changes';
+COMMENT ON COLUMN public.sku_price_history.change_percent IS 'Provider cost change relative to the previous buy price';
+COMMENT ON COLUMN public.sku_price_history.action IS 'RECORDED (cost only), ADJUSTED (sell price raised), DISABLED (negative margin) or ENABLED (margin restored)';
+
+-- Watcher settings
+-- marginRule.type: percent (value % over cost), fixed (value over cost); floor: minimum margin; roundTo: round sell price up
+INSERT INTO public.settings (category, key, value, description) VALUES
+('price_watcher', 'enabled', 'true', 'Compare provider costs against buy prices periodically'),
+('price_watcher', 'interval', '30', 'Minutes between price checks'),
+('price_watcher', 'marginRule', '{"type": "percent", "value": 5, "floor": 0, "roundTo": 1}', 'Default margin applied when a provider cost changes'),
+('price_watcher', 'autoAdjust', 'true', 'Raise sell prices to keep the margin rule'),
+('price_watcher', 'autoDisable', 'true', 'Disable prices whose margin would become negative'),
+('price_watcher', 'alertThreshold', '5', 'Cost change in percent that triggers an admin alert'),
+('price_watcher', 'alertEmail', '""', 'Email address receiving price alerts in addition to Telegram')
+ON CONFLICT (category, key) DO NOTHING;
@@ -0,0 +1,4 @@
+ALTER TABLE public.skus DROP CONSTRAINT IF EXISTS skus_routing_strategy_check;
+ALTER TABLE public.skus DROP COLUMN IF EXISTS routing_strategy;
+DROP TRIGGER IF EXISTS update_sku_provider_mappings_updated_at ON sku_provider_mappings;
+DROP TABLE IF EXISTS public.sku_provider_mappings;
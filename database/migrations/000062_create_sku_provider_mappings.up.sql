@@ -0,0 +1,54 @@
+-- Providers able to fulfil a SKU, tried in routing order with failover
+CREATE TABLE IF NOT EXISTS public.sku_provider_mappings (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    sku_id UUID NOT NULL REFERENCES skus(id) ON DELETE CASCADE,
+    provider_id UUID NOT NULL REFERENCES providers(id) ON DELETE CASCADE,
+    provider_sku_code VARCHAR(100) NOT NULL,
+
+    -- Routing
+    priority INTEGER NOT NULL DEFAULT 1, -- lower is tried first
+    weight INTEGER NOT NULL DEFAULT 1, -- share of orders for the WEIGHTED strategy
+    cost_price BIGINT, -- last known provider cost
+    is_active BOOLEAN NOT NULL DEFAULT true,
+
+    -- Timestamps
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+
+    CONSTRAINT sku_provider_mappings_unique UNIQUE (sku_id, provider_id, provider_sku_code),
+    CONSTRAINT sku_provider_mappings_weight_check CHECK (weight >= 0)
+);
+
+ALTER TABLE public.skus ADD COLUMN IF NOT EXISTS routing_strategy VARCHAR(20) NOT NULL DEFAULT 'PRIORITY';
+ALTER TABLE public.skus ADD CONSTRAINT skus_routing_strategy_check
+    CHECK (routing_strategy IN ('PRIORITY', 'CHEAPEST', 'SUCCESS_RATE', 'WEIGHTED'));
+
+-- Indexes
+CREATE INDEX idx_sku_provider_mappings_sku ON sku_provider_mappings(sku_id, priority);
+CREATE INDEX idx_sku_provider_mappings_provider ON sku_provider_mappings(provider_id);
+
+-- Trigger for updated_at
+CREATE TRIGGER update_sku_provider_mappings_updated_at BEFORE UPDATE ON sku_provider_mappings
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Existing SKUs keep their provider SKU first, then the backup codes
+INSERT INTO public.sku_provider_mappings (sku_id, provider_id, provider_sku_code, priority)
+SELECT id, provider_id, provider_sku_code, 1 FROM public.skus
+WHERE provider_sku_code <> ''
+ON CONFLICT DO NOTHING;
+
+INSERT INTO public.sku_provider_mappings (sku_id, provider_id, provider_sku_code, priority)
+SELECT id, provider_id, provider_sku_code_backup1, 2 FROM public.skus
+WHERE COALESCE(provider_sku_code_backup1, '') <> ''
+ON CONFLICT DO NOTHING;
+
+INSERT INTO public.sku_provider_mappings (sku_id, provider_id, provider_sku_code, priority)
+SELECT id, provider_id, provider_sku_code_backup2, 3 FROM public.skus
+WHERE COALESCE(provider_sku_code_backup2, '') <> ''
+ON CONFLICT DO NOTHING;
+
+-- Comments
+COMMENT ON TABLE public.sku_provider_mappings IS 'Provider SKUs that can fulfil a SKU, ordered by the SKU routing strategy';
+COMMENT ON COLUMN public.sku_provider_mappings.priority IS 'Order for the PRIORITY strategy and tie-breaker for the others';
+COMMENT ON COLUMN public.sku_provider_mappings.weight IS 'Relative share of orders for the WEIGHTED strategy';
+COMMENT ON COLUMN public.skus.routing_strategy IS 'PRIORITY, CHEAPEST, SUCCESS_RATE or WEIGHTED; only used when the SKU has provider mappings';
@@ -0,0 +1,2 @@
+DELETE FROM public.settings WHERE category = 'provider_balance';
+DROP TABLE IF EXISTS public.provider_balance_history;
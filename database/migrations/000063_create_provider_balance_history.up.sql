@@ -0,0 +1,26 @@
+-- Provider balance snapshots taken by the balance monitor
+CREATE TABLE IF NOT EXISTS public.provider_balance_history (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    provider_id UUID NOT NULL REFERENCES providers(id) ON DELETE CASCADE,
+    balance BIGINT NOT NULL,
+    currency VARCHAR(3) NOT NULL DEFAULT 'IDR',
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Indexes
+CREATE INDEX idx_provider_balance_history_provider ON provider_balance_history(provider_id, created_at DESC);
+CREATE INDEX idx_provider_balance_history_created_at ON provider_balance_history(created_at DESC);
+
+-- Comments
+COMMENT ON TABLE public.provider_balance_history IS 'Provider deposit balances snapshotted by the balance monitor, used for burn rate and alerts';
+
+-- Monitor settings
+INSERT INTO public.settings (category, key, value, description) VALUES
+('provider_balance', 'enabled', 'true', 'Snapshot provider balances periodically'),
+('provider_balance', 'interval', '15', 'Minutes between balance snapshots'),
+('provider_balance', 'lowThreshold', '1000000', 'Balance in IDR below which an alert is sent'),
+('provider_balance', 'minHoursLeft', '24', 'Alert when the balance covers fewer hours of projected spend'),
+('provider_balance', 'alertCooldown', '60', 'Minutes before the same provider is alerted again'),
+('provider_balance', 'retentionDays', '30', 'Days of balance snapshots to keep'),
+('provider_balance', 'alertEmail', '""', 'Email address receiving balance alerts in addition to Telegram')
+ON CONFLICT (category, key) DO NOTHING;
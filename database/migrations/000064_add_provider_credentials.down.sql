@@ -0,0 +1,5 @@
+ALTER TABLE public.providers DROP COLUMN IF EXISTS credentials_updated_at;
+ALTER TABLE public.providers DROP COLUMN IF EXISTS is_production;
+ALTER TABLE public.providers DROP COLUMN IF EXISTS webhook_secret;
+ALTER TABLE public.providers DROP COLUMN IF EXISTS credentials;
+ALTER TABLE public.providers DROP COLUMN IF EXISTS provider_type;
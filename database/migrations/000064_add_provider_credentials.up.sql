@@ -0,0 +1,15 @@
+-- Provider instances built from the database instead of environment variables
+ALTER TABLE public.providers ADD COLUMN IF NOT EXISTS provider_type VARCHAR(50);
+ALTER TABLE public.providers ADD COLUMN IF NOT EXISTS credentials TEXT; -- encrypted JSON object
+ALTER TABLE public.providers ADD COLUMN IF NOT EXISTS webhook_secret TEXT; -- encrypted
+ALTER TABLE public.providers ADD COLUMN IF NOT EXISTS is_production BOOLEAN NOT NULL DEFAULT true;
+ALTER TABLE public.providers ADD COLUMN IF NOT EXISTS credentials_updated_at TIMESTAMPTZ;
+
+-- Existing providers use the implementation matching their code
+UPDATE public.providers SET provider_type = LOWER(code)
+WHERE provider_type IS NULL AND LOWER(code) IN ('digiflazz', 'vipreseller', 'bangjeff');
+
+-- Comments
+COMMENT ON COLUMN public.providers.provider_type IS 'Registered provider implementation used to build the instance';
+COMMENT ON COLUMN public.providers.credentials IS 'Encrypted JSON credentials; when empty the provider is configured from environment variables';
+COMMENT ON COLUMN public.providers.webhook_secret IS 'Encrypted secret used to verify provider callbacks';
@@ -0,0 +1,3 @@
+ALTER TABLE public.payment_gateways DROP COLUMN IF EXISTS credentials_updated_at;
+ALTER TABLE public.payment_gateways DROP COLUMN IF EXISTS is_sandbox;
+ALTER TABLE public.payment_gateways DROP COLUMN IF EXISTS credentials;
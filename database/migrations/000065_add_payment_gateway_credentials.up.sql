@@ -0,0 +1,8 @@
+-- Payment gateway instances built from the database instead of environment variables
+ALTER TABLE public.payment_gateways ADD COLUMN IF NOT EXISTS credentials TEXT; -- encrypted JSON object
+ALTER TABLE public.payment_gateways ADD COLUMN IF NOT EXISTS is_sandbox BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE public.payment_gateways ADD COLUMN IF NOT EXISTS credentials_updated_at TIMESTAMPTZ;
+
+-- Comments
+COMMENT ON COLUMN public.payment_gateways.credentials IS 'Encrypted JSON credentials including private keys; when empty the gateway is configured from environment variables';
+COMMENT ON COLUMN public.payment_gateways.is_sandbox IS 'Use the sandbox endpoints of the gateway';
@@ -0,0 +1,2 @@
+-- Files sent with an email, e.g. invoice PDFs: [{"filename", "contentType", "content" (base64)}]
+ALTER TABLE public.email_outbox ADD COLUMN IF NOT EXISTS attachments JSONB NOT NULL DEFAULT '[]'::jsonb;
@@ -0,0 +1,8 @@
+DELETE FROM public.settings WHERE category = 'rate_limit' AND key = 'partner';
+
+DROP INDEX IF EXISTS idx_transactions_partner_ref;
+ALTER TABLE public.transactions DROP COLUMN IF EXISTS partner_callback_url;
+ALTER TABLE public.transactions DROP COLUMN IF EXISTS partner_ref_id;
+ALTER TABLE public.transactions DROP COLUMN IF EXISTS partner_key_id;
+
+DROP TABLE IF EXISTS public.partner_api_keys;
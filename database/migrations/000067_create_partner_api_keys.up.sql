@@ -0,0 +1,53 @@
+-- Create partner_api_keys table, credentials of reseller accounts using the H2H API
+CREATE TABLE IF NOT EXISTS public.partner_api_keys (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+
+    -- Credentials
+    api_key VARCHAR(64) NOT NULL UNIQUE,
+    secret TEXT NOT NULL, -- encrypted
+    secret_hint VARCHAR(8) NOT NULL,
+
+    -- Partner settings
+    callback_url TEXT,
+    ip_whitelist TEXT[] NOT NULL DEFAULT '{}',
+    is_active BOOLEAN NOT NULL DEFAULT true,
+
+    -- Usage
+    last_used_at TIMESTAMPTZ,
+    last_used_ip VARCHAR(45),
+
+    -- Audit
+    created_by UUID REFERENCES admins(id) ON DELETE SET NULL,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Indexes
+CREATE INDEX idx_partner_api_keys_user ON partner_api_keys(user_id);
+
+-- Trigger for updated_at
+CREATE TRIGGER update_partner_api_keys_updated_at BEFORE UPDATE ON partner_api_keys
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Orders placed through the H2H API
+ALTER TABLE public.transactions ADD COLUMN IF NOT EXISTS partner_key_id UUID REFERENCES partner_api_keys(id) ON DELETE SET NULL;
+ALTER TABLE public.transactions ADD COLUMN IF NOT EXISTS partner_ref_id VARCHAR(100);
+ALTER TABLE public.transactions ADD COLUMN IF NOT EXISTS partner_callback_url TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_partner_ref ON transactions(user_id, partner_ref_id)
+    WHERE partner_ref_id IS NOT NULL;
+
+-- Rate limit of the H2H API, per API key and multiplied by partnerMultiplier
+INSERT INTO public.settings (category, key, value, description) VALUES
+('rate_limit', 'partner', '{"requests": 60, "userRequests": 60, "window": 60}', 'H2H partner API, per API key')
+ON CONFLICT (category, key) DO NOTHING;
+
+-- Comments
+COMMENT ON TABLE public.partner_api_keys IS 'API keys of reseller accounts buying through the H2H partner API';
+COMMENT ON COLUMN public.partner_api_keys.secret IS 'Encrypted HMAC secret, shown to the admin once when created or rotated';
+COMMENT ON COLUMN public.partner_api_keys.ip_whitelist IS 'IPs or CIDRs allowed to use the key; empty allows any IP';
+COMMENT ON COLUMN public.transactions.partner_ref_id IS 'Reference of the partner, unique per account so retried orders are not charged twice';
+COMMENT ON COLUMN public.transactions.partner_callback_url IS 'Callback URL of the order, overrides the callback URL of the API key';
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS public.webhook_delivery_attempts;
+DROP TABLE IF EXISTS public.webhook_deliveries;
+DROP TABLE IF EXISTS public.webhook_events;
+DROP TABLE IF EXISTS public.webhook_subscribers;
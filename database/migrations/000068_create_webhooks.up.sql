@@ -0,0 +1,103 @@
+-- Create webhook_subscribers table, endpoints receiving signed event callbacks
+CREATE TABLE IF NOT EXISTS public.webhook_subscribers (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(100) NOT NULL,
+    url TEXT NOT NULL,
+
+    -- Signing secret
+    secret TEXT NOT NULL, -- encrypted
+    secret_hint VARCHAR(8) NOT NULL,
+
+    -- Subscription
+    events TEXT[] NOT NULL DEFAULT '{}', -- empty receives every event
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE, -- only events of this account, e.g. a partner
+    is_active BOOLEAN NOT NULL DEFAULT true,
+
+    -- Audit
+    created_by UUID REFERENCES admins(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_webhook_subscribers_user ON webhook_subscribers(user_id);
+
+CREATE TRIGGER update_webhook_subscribers_updated_at BEFORE UPDATE ON webhook_subscribers
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Create webhook_events table, one row per emitted event
+CREATE TABLE IF NOT EXISTS public.webhook_events (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    event_type VARCHAR(50) NOT NULL, -- transaction.status, deposit.success, refund, order.status
+    reference_type VARCHAR(50) NOT NULL, -- TRANSACTION, DEPOSIT
+    reference_id VARCHAR(100) NOT NULL,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    payload JSONB NOT NULL, -- body posted to every subscriber
+    dedupe_key VARCHAR(255) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_webhook_events_reference ON webhook_events(reference_type, reference_id);
+CREATE INDEX idx_webhook_events_created_at ON webhook_events(created_at DESC);
+
+-- Create webhook_deliveries table, delivered by the background worker
+CREATE TABLE IF NOT EXISTS public.webhook_deliveries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    event_id UUID NOT NULL REFERENCES webhook_events(id) ON DELETE CASCADE,
+
+    -- Recipient: a subscriber or the callback URL of a partner API key
+    subscriber_id UUID REFERENCES webhook_subscribers(id) ON DELETE CASCADE,
+    partner_key_id UUID REFERENCES partner_api_keys(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+
+    -- Delivery
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING', -- PENDING, SENDING, DELIVERED, DEAD
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 8,
+    last_error TEXT,
+    last_status_code INTEGER,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    locked_at TIMESTAMPTZ,
+    delivered_at TIMESTAMPTZ,
+
+    -- Admin who resent the delivery
+    resent_by UUID REFERENCES admins(id) ON DELETE SET NULL,
+
+    -- Timestamps
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+
+    CONSTRAINT webhook_deliveries_status_check CHECK (status IN ('PENDING', 'SENDING', 'DELIVERED', 'DEAD')),
+    CONSTRAINT webhook_deliveries_recipient_check CHECK (subscriber_id IS NOT NULL OR partner_key_id IS NOT NULL)
+);
+
+CREATE INDEX idx_webhook_deliveries_pending ON webhook_deliveries(next_attempt_at) WHERE status = 'PENDING';
+CREATE INDEX idx_webhook_deliveries_status ON webhook_deliveries(status);
+CREATE INDEX idx_webhook_deliveries_event ON webhook_deliveries(event_id);
+CREATE INDEX idx_webhook_deliveries_subscriber ON webhook_deliveries(subscriber_id);
+CREATE INDEX idx_webhook_deliveries_created_at ON webhook_deliveries(created_at DESC);
+
+CREATE TRIGGER update_webhook_deliveries_updated_at BEFORE UPDATE ON webhook_deliveries
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Create webhook_delivery_attempts table, the log of every HTTP call
+CREATE TABLE IF NOT EXISTS public.webhook_delivery_attempts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    delivery_id UUID NOT NULL REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+    attempt INTEGER NOT NULL,
+    url TEXT NOT NULL,
+    status_code INTEGER,
+    response_body TEXT, -- truncated
+    error TEXT,
+    duration_ms INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_webhook_delivery_attempts_delivery ON webhook_delivery_attempts(delivery_id, attempt);
+
+-- Comments
+COMMENT ON TABLE public.webhook_subscribers IS 'Endpoints receiving signed callbacks for transaction, deposit and refund events';
+COMMENT ON COLUMN public.webhook_subscribers.secret IS 'Encrypted HMAC secret, shown to the admin once when created or rotated';
+COMMENT ON TABLE public.webhook_events IS 'Emitted webhook events; dedupe_key keeps a status change from being emitted twice';
+COMMENT ON COLUMN public.webhook_deliveries.status IS 'PENDING, SENDING (claimed by a worker), DELIVERED or DEAD (max attempts reached)';
+COMMENT ON COLUMN public.webhook_deliveries.next_attempt_at IS 'Earliest time of the next delivery attempt (exponential backoff)';
+COMMENT ON TABLE public.webhook_delivery_attempts IS 'Log of every webhook HTTP call with the response of the subscriber';
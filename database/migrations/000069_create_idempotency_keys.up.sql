@@ -0,0 +1,30 @@
+-- Create idempotency_keys table, responses of POST requests sent with an Idempotency-Key header
+CREATE TABLE IF NOT EXISTS public.idempotency_keys (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    scope VARCHAR(100) NOT NULL, -- user:<id>, admin:<id> or guest
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_hash VARCHAR(64) NOT NULL, -- SHA-256 of method, path and body
+
+    -- Processing
+    status VARCHAR(20) NOT NULL DEFAULT 'PROCESSING', -- PROCESSING, COMPLETED
+    locked_until TIMESTAMPTZ NOT NULL,
+
+    -- Stored response, replayed for retries
+    response_status INTEGER,
+    response_content_type VARCHAR(100),
+    response_body BYTEA,
+
+    -- Timestamps
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    completed_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ NOT NULL,
+
+    CONSTRAINT idempotency_keys_status_check CHECK (status IN ('PROCESSING', 'COMPLETED')),
+    CONSTRAINT idempotency_keys_scope_key_unique UNIQUE (scope, idempotency_key)
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
+
+-- Comments
+COMMENT ON TABLE public.idempotency_keys IS 'First response of an idempotent POST, replayed when the client retries with the same key';
+COMMENT ON COLUMN public.idempotency_keys.locked_until IS 'A PROCESSING key past this time belongs to a request that died and may be taken over';
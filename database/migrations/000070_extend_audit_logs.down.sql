@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_audit_logs_admin_created_at;
+DROP INDEX IF EXISTS idx_audit_logs_resource_id;
+
+ALTER TABLE public.audit_logs DROP CONSTRAINT IF EXISTS audit_logs_admin_id_fkey;
+ALTER TABLE public.audit_logs ADD CONSTRAINT audit_logs_admin_id_fkey
+    FOREIGN KEY (admin_id) REFERENCES public.admins(id);
+
+ALTER TABLE public.audit_logs ALTER COLUMN resource_id TYPE UUID
+    USING CASE WHEN resource_id ~* '^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$' THEN resource_id::uuid END;
+ALTER TABLE public.audit_logs ALTER COLUMN action TYPE public.audit_action
+    USING CASE WHEN action IN ('CREATE', 'UPDATE', 'DELETE', 'LOGIN', 'LOGOUT') THEN action::public.audit_action ELSE 'UPDATE'::public.audit_action END;
@@ -0,0 +1,17 @@
+-- Audit actions go beyond CREATE/UPDATE/DELETE (REFUND, ADJUST_BALANCE, ROTATE_SECRET, ...) and
+-- some resources are not identified by a UUID, e.g. settings categories
+ALTER TABLE public.audit_logs ALTER COLUMN action TYPE VARCHAR(50) USING action::text;
+ALTER TABLE public.audit_logs ALTER COLUMN resource_id TYPE VARCHAR(100) USING resource_id::text;
+
+-- Keep the audit trail of deleted admins, their name and email are stored with each entry
+ALTER TABLE public.audit_logs DROP CONSTRAINT IF EXISTS audit_logs_admin_id_fkey;
+ALTER TABLE public.audit_logs ADD CONSTRAINT audit_logs_admin_id_fkey
+    FOREIGN KEY (admin_id) REFERENCES public.admins(id) ON DELETE SET NULL;
+
+-- Indexes for the audit log filters
+CREATE INDEX IF NOT EXISTS idx_audit_logs_resource_id ON audit_logs(resource, resource_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_admin_created_at ON audit_logs(admin_id, created_at DESC);
+
+-- Comments
+COMMENT ON COLUMN public.audit_logs.changes IS 'Fields changed by the action: {"before": {...}, "after": {...}}, secrets redacted';
+COMMENT ON COLUMN public.audit_logs.admin_name IS 'Name of the admin at the time of the action, kept when the admin is deleted';
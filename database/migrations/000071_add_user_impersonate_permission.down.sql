@@ -0,0 +1,4 @@
+DELETE FROM public.role_permissions
+WHERE permission_id IN (SELECT id FROM public.permissions WHERE code = 'user:impersonate');
+
+DELETE FROM public.permissions WHERE code = 'user:impersonate';
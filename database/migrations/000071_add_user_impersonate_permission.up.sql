@@ -0,0 +1,12 @@
+-- Permission to view the user API as a user with a read-only token
+INSERT INTO public.permissions (code, name, description, category)
+VALUES ('user:impersonate', 'Impersonate User', 'Can view as a user with a read-only token, every request is audited', 'User')
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO public.role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM public.roles r
+CROSS JOIN public.permissions p
+WHERE r.code IN ('SUPERADMIN', 'CS_LEAD')
+  AND p.code = 'user:impersonate'
+ON CONFLICT DO NOTHING;
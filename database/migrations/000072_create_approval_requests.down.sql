@@ -0,0 +1,9 @@
+DELETE FROM public.role_permissions
+WHERE permission_id IN (SELECT id FROM public.permissions WHERE code IN ('approval:read', 'approval:approve'));
+
+DELETE FROM public.permissions WHERE code IN ('approval:read', 'approval:approve');
+
+DELETE FROM public.settings WHERE category = 'approval';
+
+DROP TABLE IF EXISTS public.approval_comments;
+DROP TABLE IF EXISTS public.approval_requests;
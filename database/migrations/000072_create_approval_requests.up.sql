@@ -0,0 +1,69 @@
+-- Create approval_requests table, sensitive admin actions held for a second admin (maker-checker)
+CREATE TABLE IF NOT EXISTS public.approval_requests (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    action VARCHAR(50) NOT NULL, -- ADJUST_BALANCE, REFUND_TRANSACTION, REFUND_DEPOSIT, CONFIRM_DEPOSIT, MANUAL_PROCESS
+    resource VARCHAR(50) NOT NULL, -- USER, TRANSACTION, DEPOSIT
+    resource_id VARCHAR(100) NOT NULL,
+    amount BIGINT NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    summary TEXT NOT NULL,
+    payload JSONB NOT NULL, -- request body replayed once approved
+
+    -- PENDING, EXECUTING, APPROVED, REJECTED, CANCELLED, EXPIRED, FAILED
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING',
+    requested_by UUID REFERENCES admins(id) ON DELETE SET NULL,
+    decided_by UUID REFERENCES admins(id) ON DELETE SET NULL,
+    decided_at TIMESTAMPTZ,
+    result JSONB, -- response of the executed action
+    error TEXT, -- why the approved action failed
+
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+
+    CONSTRAINT approval_requests_decided_by_other CHECK (decided_by IS NULL OR decided_by IS DISTINCT FROM requested_by)
+);
+
+CREATE INDEX idx_approval_requests_status ON approval_requests(status, created_at DESC);
+CREATE INDEX idx_approval_requests_resource ON approval_requests(resource, resource_id);
+CREATE INDEX idx_approval_requests_pending_expiry ON approval_requests(expires_at) WHERE status = 'PENDING';
+
+CREATE TRIGGER update_approval_requests_updated_at BEFORE UPDATE ON approval_requests
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Create approval_comments table, discussion between requester and approvers
+CREATE TABLE IF NOT EXISTS public.approval_comments (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    approval_id UUID NOT NULL REFERENCES approval_requests(id) ON DELETE CASCADE,
+    admin_id UUID REFERENCES admins(id) ON DELETE SET NULL,
+    comment TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_approval_comments_approval ON approval_comments(approval_id, created_at);
+
+-- Thresholds per currency from which an action needs a second admin.
+-- An action without a setting never needs approval, a currency missing from it always does.
+INSERT INTO public.settings (category, key, value, description) VALUES
+('approval', 'enabled', 'true', 'Hold sensitive actions above the thresholds for a second admin'),
+('approval', 'expiryHours', '24', 'Hours before a pending approval request expires'),
+('approval', 'adjustBalance', '{"IDR": 1000000, "MYR": 300, "PHP": 3500, "SGD": 80, "THB": 2000}', 'Manual balance adjustments'),
+('approval', 'refundTransaction', '{"IDR": 1000000, "MYR": 300, "PHP": 3500, "SGD": 80, "THB": 2000}', 'Transaction refunds'),
+('approval', 'refundDeposit', '{"IDR": 1000000, "MYR": 300, "PHP": 3500, "SGD": 80, "THB": 2000}', 'Deposit refunds'),
+('approval', 'confirmDeposit', '{"IDR": 1000000, "MYR": 300, "PHP": 3500, "SGD": 80, "THB": 2000}', 'Manual deposit confirmations'),
+('approval', 'manualProcess', '{"IDR": 1000000, "MYR": 300, "PHP": 3500, "SGD": 80, "THB": 2000}', 'Transactions manually marked as SUCCESS')
+ON CONFLICT (category, key) DO NOTHING;
+
+-- Permissions to view and to decide approval requests
+INSERT INTO public.permissions (code, name, description, category) VALUES
+('approval:read', 'View Approvals', 'Can view approval requests', 'Approval'),
+('approval:approve', 'Decide Approvals', 'Can approve or reject actions requested by other admins', 'Approval')
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO public.role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM public.roles r
+CROSS JOIN public.permissions p
+WHERE (p.code = 'approval:read' AND r.code IN ('SUPERADMIN', 'ADMIN', 'FINANCE', 'CS_LEAD', 'CS'))
+   OR (p.code = 'approval:approve' AND r.code IN ('SUPERADMIN', 'FINANCE'))
+ON CONFLICT DO NOTHING;
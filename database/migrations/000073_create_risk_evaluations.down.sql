@@ -0,0 +1,9 @@
+DELETE FROM public.role_permissions
+WHERE permission_id IN (SELECT id FROM public.permissions WHERE code IN ('risk:read', 'risk:review'));
+
+DELETE FROM public.permissions WHERE code IN ('risk:read', 'risk:review');
+
+DELETE FROM public.settings WHERE category = 'risk';
+
+DROP INDEX IF EXISTS public.idx_transactions_ip_created;
+DROP TABLE IF EXISTS public.risk_evaluations;
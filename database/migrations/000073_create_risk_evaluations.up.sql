@@ -0,0 +1,80 @@
+-- Create risk_evaluations table, fraud scoring of order and deposit checkouts and the review queue of held ones
+CREATE TABLE IF NOT EXISTS public.risk_evaluations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    kind VARCHAR(20) NOT NULL, -- ORDER, DEPOSIT
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    ip_address INET,
+    device_id VARCHAR(255), -- X-Device-ID header
+    game_id VARCHAR(255), -- game account of an order, user ID and zone ID
+    region VARCHAR(5),
+    ip_country VARCHAR(5), -- country of the IP set by the CDN
+    amount BIGINT NOT NULL DEFAULT 0,
+    currency VARCHAR(3),
+
+    score INTEGER NOT NULL DEFAULT 0,
+    signals JSONB NOT NULL DEFAULT '{}',
+    rules JSONB NOT NULL DEFAULT '[]', -- names of the matched rules
+    action VARCHAR(20) NOT NULL, -- ALLOW, REQUIRE_LOGIN, REQUIRE_MFA, HOLD, BLOCK
+
+    -- Review of held checkouts: PENDING, RELEASED, CANCELLED
+    transaction_id UUID REFERENCES transactions(id) ON DELETE SET NULL,
+    review_status VARCHAR(20),
+    reviewed_by UUID REFERENCES admins(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMPTZ,
+    review_note TEXT,
+
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_risk_evaluations_ip ON risk_evaluations(ip_address, created_at);
+CREATE INDEX idx_risk_evaluations_device ON risk_evaluations(device_id, created_at);
+CREATE INDEX idx_risk_evaluations_user ON risk_evaluations(user_id, created_at);
+CREATE INDEX idx_risk_evaluations_game_id ON risk_evaluations(game_id, created_at);
+CREATE INDEX idx_risk_evaluations_transaction ON risk_evaluations(transaction_id);
+CREATE INDEX idx_risk_evaluations_review ON risk_evaluations(review_status, created_at DESC) WHERE review_status IS NOT NULL;
+CREATE INDEX idx_risk_evaluations_created ON risk_evaluations(created_at DESC);
+
+-- Failed payments are counted per IP as well as per account
+CREATE INDEX IF NOT EXISTS idx_transactions_ip_created ON transactions(ip_address, created_at);
+
+CREATE TRIGGER update_risk_evaluations_updated_at BEFORE UPDATE ON risk_evaluations
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Rules add their score and apply their action once a signal reaches the threshold;
+-- the action of a checkout is the most severe of its rules and of the score actions it reaches.
+-- Signals: ipPerHour, devicePerHour, accountPerHour, gameIdPerHour, deviceGameIds,
+-- newAccountAmount (thresholds per currency), regionMismatch, failedPayments.
+INSERT INTO public.settings (category, key, value, description) VALUES
+('risk', 'enabled', 'true', 'Score order and deposit checkouts for fraud'),
+('risk', 'newAccountHours', '24', 'Hours an account counts as new for newAccountAmount'),
+('risk', 'releaseWindowHours', '24', 'Hours a customer released by a reviewer is not held again'),
+('risk', 'countryHeader', '"CF-IPCountry"', 'Header set by the CDN with the country of the client IP'),
+('risk', 'rules', '[
+  {"name": "ip_velocity", "signal": "ipPerHour", "threshold": 10, "score": 20, "action": "REQUIRE_LOGIN"},
+  {"name": "ip_velocity_extreme", "signal": "ipPerHour", "threshold": 40, "score": 60, "action": "BLOCK"},
+  {"name": "device_velocity", "signal": "devicePerHour", "threshold": 10, "score": 20, "action": "REQUIRE_LOGIN"},
+  {"name": "account_velocity", "signal": "accountPerHour", "threshold": 15, "score": 30, "action": "REQUIRE_MFA"},
+  {"name": "game_id_velocity", "signal": "gameIdPerHour", "threshold": 10, "score": 20, "action": "ALLOW"},
+  {"name": "device_many_game_ids", "signal": "deviceGameIds", "threshold": 5, "score": 40, "action": "HOLD", "kinds": ["ORDER"]},
+  {"name": "new_account_big_ticket", "signal": "newAccountAmount", "thresholds": {"IDR": 1000000, "MYR": 300, "PHP": 3500, "SGD": 80, "THB": 2000}, "score": 40, "action": "HOLD"},
+  {"name": "region_mismatch", "signal": "regionMismatch", "threshold": 1, "score": 20, "action": "ALLOW"},
+  {"name": "failed_payments", "signal": "failedPayments", "threshold": 3, "score": 20, "action": "REQUIRE_LOGIN"},
+  {"name": "failed_payments_many", "signal": "failedPayments", "threshold": 10, "score": 40, "action": "HOLD"}
+]', 'Risk rules'),
+('risk', 'scoreActions', '{"HOLD": 80, "BLOCK": 140}', 'Action taken from a total score')
+ON CONFLICT (category, key) DO NOTHING;
+
+-- Permissions to view evaluations and to release or cancel held checkouts
+INSERT INTO public.permissions (code, name, description, category) VALUES
+('risk:read', 'View Risk Reviews', 'Can view risk evaluations and held checkouts', 'Risk'),
+('risk:review', 'Review Risk Holds', 'Can release or cancel checkouts held by the risk engine', 'Risk')
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO public.role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM public.roles r
+CROSS JOIN public.permissions p
+WHERE (p.code = 'risk:read' AND r.code IN ('SUPERADMIN', 'ADMIN', 'FINANCE', 'CS_LEAD', 'CS'))
+   OR (p.code = 'risk:review' AND r.code IN ('SUPERADMIN', 'ADMIN', 'CS_LEAD'))
+ON CONFLICT DO NOTHING;
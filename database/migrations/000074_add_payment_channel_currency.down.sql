@@ -0,0 +1 @@
+ALTER TABLE public.payment_channels DROP COLUMN IF EXISTS currency;
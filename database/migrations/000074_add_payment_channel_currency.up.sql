@@ -0,0 +1,15 @@
+-- Add payment_channels.currency, the currency a channel settles in, checked against the order currency
+ALTER TABLE public.payment_channels
+    ADD COLUMN IF NOT EXISTS currency public.currency_code NOT NULL DEFAULT 'IDR';
+
+-- Existing channels settle in the currency of their regions when those all share one
+UPDATE public.payment_channels pc
+SET currency = r.currency
+FROM (
+    SELECT pcr.channel_id, MIN(r.currency::text)::public.currency_code AS currency
+    FROM public.payment_channel_regions pcr
+    JOIN public.regions r ON r.code = pcr.region_code
+    GROUP BY pcr.channel_id
+    HAVING COUNT(DISTINCT r.currency) = 1
+) r
+WHERE pc.id = r.channel_id;
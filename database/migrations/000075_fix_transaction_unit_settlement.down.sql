@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_refunds_transaction;
+
+DELETE FROM public.refunds WHERE processed_by IS NULL;
+
+ALTER TABLE public.refunds ALTER COLUMN processed_by SET NOT NULL;
+
+DROP INDEX IF EXISTS idx_transaction_units_processing;
+
+ALTER TABLE public.transaction_units DROP COLUMN IF EXISTS provider_id;
+
+ALTER TABLE public.skus ALTER COLUMN max_quantity SET DEFAULT 1;
@@ -0,0 +1,18 @@
+-- 000059 defaulted max_quantity to 1, which capped every existing SKU at a single unit
+ALTER TABLE public.skus ALTER COLUMN max_quantity SET DEFAULT 10;
+
+UPDATE public.skus SET max_quantity = 10 WHERE max_quantity = 1;
+
+-- Provider each unit was ordered from, units of one order may fail over to different providers
+ALTER TABLE public.transaction_units
+    ADD COLUMN IF NOT EXISTS provider_id UUID REFERENCES public.providers(id);
+
+COMMENT ON COLUMN public.transaction_units.provider_id IS 'Provider that accepted the unit order, NULL when it is the provider of the transaction';
+
+CREATE INDEX IF NOT EXISTS idx_transaction_units_processing ON public.transaction_units(updated_at)
+    WHERE status = 'PROCESSING';
+
+-- Refunds owed to payers without a balance wait for an admin, they have no processor yet
+ALTER TABLE public.refunds ALTER COLUMN processed_by DROP NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_refunds_transaction ON public.refunds(transaction_id);
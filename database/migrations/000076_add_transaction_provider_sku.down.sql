@@ -0,0 +1,3 @@
+ALTER TABLE public.transaction_units DROP COLUMN IF EXISTS provider_sku_code;
+
+ALTER TABLE public.transactions DROP COLUMN IF EXISTS provider_sku_code;
@@ -0,0 +1,7 @@
+-- Provider SKU the order was placed with, which differs from skus.provider_sku_code after a failover
+ALTER TABLE public.transactions ADD COLUMN IF NOT EXISTS provider_sku_code VARCHAR(100);
+
+ALTER TABLE public.transaction_units ADD COLUMN IF NOT EXISTS provider_sku_code VARCHAR(100);
+
+COMMENT ON COLUMN public.transactions.provider_sku_code IS 'Provider SKU of the mapping that accepted the order, NULL when the SKU default was used';
+COMMENT ON COLUMN public.transaction_units.provider_sku_code IS 'Provider SKU of the mapping that accepted the unit order';
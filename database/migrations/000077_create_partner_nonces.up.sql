@@ -0,0 +1,13 @@
+-- Create partner_nonces table, nonces of signed partner requests when Redis is unavailable
+CREATE TABLE IF NOT EXISTS public.partner_nonces (
+    key_id UUID NOT NULL REFERENCES partner_api_keys(id) ON DELETE CASCADE,
+    nonce VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+
+    PRIMARY KEY (key_id, nonce)
+);
+
+CREATE INDEX IF NOT EXISTS idx_partner_nonces_expires_at ON public.partner_nonces(expires_at);
+
+COMMENT ON TABLE public.partner_nonces IS 'Nonces of signed partner API requests, each accepted once until it expires';
@@ -0,0 +1,10 @@
+// Package migrations embeds the SQL migrations of the database schema so the API
+// binary can apply them without the source tree.
+package migrations
+
+import "embed"
+
+// FS holds the NNNNNN_name.up.sql and NNNNNN_name.down.sql files
+//
+//go:embed *.sql
+var FS embed.FS
@@ -3,20 +3,24 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Redis    RedisConfig
-	JWT      JWTConfig
-	S3       S3Config
-	Provider ProviderConfig
-	Payment  PaymentConfig
-	App      AppConfig
+	Server       ServerConfig
+	Database     DatabaseConfig
+	Redis        RedisConfig
+	Cache        CacheConfig
+	RateLimit    RateLimitConfig
+	JWT          JWTConfig
+	S3           S3Config
+	Provider     ProviderConfig
+	Payment      PaymentConfig
+	Notification NotificationConfig
+	App          AppConfig
 }
 
 type ServerConfig struct {
@@ -25,18 +29,22 @@ type ServerConfig struct {
 	AllowOrigins string
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
+	// TrustedProxies are the IPs or CIDRs of the reverse proxies whose forwarding
+	// headers name the client; requests from anywhere else are taken at their peer IP
+	TrustedProxies []string
 }
 
 type DatabaseConfig struct {
-	Host         string
-	Port         string
-	User         string
-	Password     string
-	DBName       string
-	SSLMode      string
-	MaxOpenConns int
-	MaxIdleConns int
-	MaxLifetime  time.Duration
+	Host          string
+	Port          string
+	User          string
+	Password      string
+	DBName        string
+	SSLMode       string
+	MaxOpenConns  int
+	MaxIdleConns  int
+	MaxLifetime   time.Duration
+	MigrateOnBoot bool
 }
 
 type RedisConfig struct {
@@ -46,12 +54,25 @@ type RedisConfig struct {
 	DB       int
 }
 
+type CacheConfig struct {
+	Enabled     bool
+	TTL         time.Duration
+	LocalTTL    time.Duration
+	LockTimeout time.Duration
+}
+
+type RateLimitConfig struct {
+	FailOpen         bool
+	WebhookAllowlist []string
+}
+
 type JWTConfig struct {
-	SecretKey             string
-	AccessTokenExpiry     time.Duration
-	RefreshTokenExpiry    time.Duration
-	MFATokenExpiry        time.Duration
-	ValidationTokenExpiry time.Duration
+	SecretKey                string
+	AccessTokenExpiry        time.Duration
+	RefreshTokenExpiry       time.Duration
+	MFATokenExpiry           time.Duration
+	ValidationTokenExpiry    time.Duration
+	ImpersonationTokenExpiry time.Duration
 }
 
 type S3Config struct {
@@ -179,6 +200,19 @@ type PakaiLinkConfig struct {
 	IsProduction   bool
 }
 
+type NotificationConfig struct {
+	Driver                 string // "live" sends through real channels, "memory" only records messages
+	Workers                int
+	MaxRetries             int
+	EmailMaxAttempts       int
+	WhatsAppAPIURL         string
+	WhatsAppAPIKey         string
+	WhatsAppSender         string
+	TelegramBotToken       string
+	TelegramChatID         string
+	TelegramSecurityChatID string
+}
+
 type AppConfig struct {
 	Name               string
 	BaseURL            string // API Gateway URL (e.g., https://gateway.seaply.co)
@@ -195,6 +229,8 @@ type AppConfig struct {
 	MaintenanceMessage string
 	InquiryBaseURL     string
 	InquiryKey         string
+	CredentialKey      string   // Encrypts provider and payment gateway credentials stored in the database
+	InvoiceFonts       []string // TrueType fonts for scripts the built-in invoice font lacks, such as Thai
 }
 
 func Load() (*Config, error) {
@@ -203,22 +239,24 @@ func Load() (*Config, error) {
 
 	cfg := &Config{
 		Server: ServerConfig{
-			Port:         getEnv("SERVER_PORT", "8080"),
-			Environment:  getEnv("ENVIRONMENT", "development"),
-			AllowOrigins: getEnv("CORS_ALLOW_ORIGINS", "*"),
-			ReadTimeout:  getDurationEnv("SERVER_READ_TIMEOUT", 30*time.Second),
-			WriteTimeout: getDurationEnv("SERVER_WRITE_TIMEOUT", 30*time.Second),
+			Port:           getEnv("SERVER_PORT", "8080"),
+			Environment:    getEnv("ENVIRONMENT", "development"),
+			AllowOrigins:   getEnv("CORS_ALLOW_ORIGINS", "*"),
+			ReadTimeout:    getDurationEnv("SERVER_READ_TIMEOUT", 30*time.Second),
+			WriteTimeout:   getDurationEnv("SERVER_WRITE_TIMEOUT", 30*time.Second),
+			TrustedProxies: getListEnv("TRUSTED_PROXIES"),
 		},
 		Database: DatabaseConfig{
-			Host:         getEnv("DB_HOST", "localhost"),
-			Port:         getEnv("DB_PORT", "5432"),
-			User:         getEnv("DB_USER", "seaply"),
-			Password:     getEnv("DB_PASSWORD", "seaply_secret_password"),
-			DBName:       getEnv("DB_NAME", "seaply_db"),
-			SSLMode:      getEnv("DB_SSL_MODE", "disable"),
-			MaxOpenConns: getIntEnv("DB_MAX_OPEN_CONNS", 25),
-			MaxIdleConns: getIntEnv("DB_MAX_IDLE_CONNS", 10),
-			MaxLifetime:  getDurationEnv("DB_MAX_LIFETIME", 5*time.Minute),
+			Host:          getEnv("DB_HOST", "localhost"),
+			Port:          getEnv("DB_PORT", "5432"),
+			User:          getEnv("DB_USER", "seaply"),
+			Password:      getEnv("DB_PASSWORD", "seaply_secret_password"),
+			DBName:        getEnv("DB_NAME", "seaply_db"),
+			SSLMode:       getEnv("DB_SSL_MODE", "disable"),
+			MaxOpenConns:  getIntEnv("DB_MAX_OPEN_CONNS", 25),
+			MaxIdleConns:  getIntEnv("DB_MAX_IDLE_CONNS", 10),
+			MaxLifetime:   getDurationEnv("DB_MAX_LIFETIME", 5*time.Minute),
+			MigrateOnBoot: getBoolEnv("DB_MIGRATE_ON_BOOT", false),
 		},
 		Redis: RedisConfig{
 			Host:     getEnv("REDIS_HOST", "localhost"),
@@ -226,12 +264,23 @@ func Load() (*Config, error) {
 			Password: getEnv("REDIS_PASSWORD", ""),
 			DB:       getIntEnv("REDIS_DB", 0),
 		},
+		Cache: CacheConfig{
+			Enabled:     getBoolEnv("CATALOG_CACHE_ENABLED", true),
+			TTL:         getDurationEnv("CATALOG_CACHE_TTL", 5*time.Minute),
+			LocalTTL:    getDurationEnv("CATALOG_CACHE_LOCAL_TTL", 5*time.Second),
+			LockTimeout: getDurationEnv("CATALOG_CACHE_LOCK_TIMEOUT", 3*time.Second),
+		},
+		RateLimit: RateLimitConfig{
+			FailOpen:         getBoolEnv("RATE_LIMIT_FAIL_OPEN", true),
+			WebhookAllowlist: getListEnv("WEBHOOK_IP_ALLOWLIST"),
+		},
 		JWT: JWTConfig{
-			SecretKey:             getEnv("JWT_SECRET_KEY", "your-super-secret-key-change-in-production"),
-			AccessTokenExpiry:     getDurationEnv("JWT_ACCESS_TOKEN_EXPIRY", 1*time.Hour),
-			RefreshTokenExpiry:    getDurationEnv("JWT_REFRESH_TOKEN_EXPIRY", 7*24*time.Hour),
-			MFATokenExpiry:        getDurationEnv("JWT_MFA_TOKEN_EXPIRY", 5*time.Minute),
-			ValidationTokenExpiry: getDurationEnv("JWT_VALIDATION_TOKEN_EXPIRY", 30*time.Minute),
+			SecretKey:                getEnv("JWT_SECRET_KEY", "your-super-secret-key-change-in-production"),
+			AccessTokenExpiry:        getDurationEnv("JWT_ACCESS_TOKEN_EXPIRY", 1*time.Hour),
+			RefreshTokenExpiry:       getDurationEnv("JWT_REFRESH_TOKEN_EXPIRY", 7*24*time.Hour),
+			MFATokenExpiry:           getDurationEnv("JWT_MFA_TOKEN_EXPIRY", 5*time.Minute),
+			ValidationTokenExpiry:    getDurationEnv("JWT_VALIDATION_TOKEN_EXPIRY", 30*time.Minute),
+			ImpersonationTokenExpiry: getDurationEnv("JWT_IMPERSONATION_TOKEN_EXPIRY", 15*time.Minute),
 		},
 		S3: S3Config{
 			Endpoint:        getEnv("S3_ENDPOINT", ""),
@@ -334,6 +383,18 @@ func Load() (*Config, error) {
 				IsProduction:   getBoolEnv("PAKAILINK_IS_PRODUCTION", false),
 			},
 		},
+		Notification: NotificationConfig{
+			Driver:                 getEnv("NOTIFICATION_DRIVER", "live"),
+			Workers:                getIntEnv("NOTIFICATION_WORKERS", 4),
+			MaxRetries:             getIntEnv("NOTIFICATION_MAX_RETRIES", 3),
+			EmailMaxAttempts:       getIntEnv("EMAIL_OUTBOX_MAX_ATTEMPTS", 5),
+			WhatsAppAPIURL:         getEnv("WHATSAPP_API_URL", ""),
+			WhatsAppAPIKey:         getEnv("WHATSAPP_API_TOKEN", ""),
+			WhatsAppSender:         getEnv("WHATSAPP_SENDER", ""),
+			TelegramBotToken:       getEnv("TELEGRAM_BOT_TOKEN", ""),
+			TelegramChatID:         getEnv("TELEGRAM_CHAT_ID", ""),
+			TelegramSecurityChatID: getEnv("TELEGRAM_SECURITY_CHAT_ID", ""),
+		},
 		App: AppConfig{
 			Name:               getEnv("APP_NAME", "Seaply"),
 			BaseURL:            getEnv("APP_BASE_URL", "https://gateway.seaply.co"),
@@ -350,6 +411,8 @@ func Load() (*Config, error) {
 			MaintenanceMessage: getEnv("APP_MAINTENANCE_MESSAGE", ""),
 			InquiryBaseURL:     getEnv("INQUIRY_BASE_URL", "https://inquiry.seaply.co/game"),
 			InquiryKey:         getEnv("INQUIRY_KEY", ""),
+			CredentialKey:      getEnv("APP_CREDENTIAL_KEY", ""),
+			InvoiceFonts:       getListEnv("APP_INVOICE_FONTS"),
 		},
 	}
 
@@ -381,6 +444,16 @@ func getBoolEnv(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
+func getListEnv(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if duration, err := time.ParseDuration(value); err == nil {
@@ -0,0 +1,447 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// migrationLockKey identifies the advisory lock held while migrations run, so
+// replicas starting together wait for each other instead of racing
+const migrationLockKey int64 = 7_305_120_000_050
+
+// migrationFileName matches NNNNNN_name.up.sql and NNNNNN_name.down.sql
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+var (
+	ErrMigrationNotFound  = errors.New("migration not found")
+	ErrMigrationModified  = errors.New("applied migration was modified")
+	ErrMigrationMissing   = errors.New("applied migration is not in this build")
+	ErrMigrationUntracked = errors.New("database has tables but no migration history, run migrate baseline with the version it is at")
+)
+
+// Migration is a numbered schema change with the SQL applying and reverting it
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // SHA-256 of the up file as shipped, recorded when it is applied
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%06d_%s", m.Version, m.Name)
+}
+
+// MigrationStatus describes a migration of the build or of the database
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+	Modified  bool // applied from a file that differs from the one in the build
+	Missing   bool // applied but not in the build
+}
+
+type appliedMigration struct {
+	Version   int64
+	Checksum  string
+	AppliedAt time.Time
+}
+
+type migrationQuerier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// Migrator applies the migrations of a filesystem and tracks them in
+// schema_migration_history. The schema_migrations table of migration 000037 is
+// left to golang-migrate.
+type Migrator struct {
+	pool       *pgxpool.Pool
+	migrations []Migration
+}
+
+// NewMigrator creates a migrator for the migrations in the root of fsys
+func NewMigrator(pool *pgxpool.Pool, fsys fs.FS) (*Migrator, error) {
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{pool: pool, migrations: migrations}, nil
+}
+
+// LoadMigrations reads the up and down files in the root of fsys, ordered by version
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	hasDown := make(map[int64]bool)
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %s: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			sum := sha256.Sum256(content)
+			migration.Up = stripMetaCommands(string(content))
+			migration.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			migration.Down = stripMetaCommands(string(content))
+			hasDown[version] = true
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for version, migration := range byVersion {
+		if migration.Checksum == "" {
+			return nil, fmt.Errorf("migration %s has no up file", migration)
+		}
+		if !hasDown[version] {
+			return nil, fmt.Errorf("migration %s has no down file", migration)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// stripMetaCommands drops the psql meta-commands of a migration, such as the
+// \restrict and \unrestrict lines pg_dump writes, which the server cannot run.
+// The checksum still covers the file as it shipped.
+func stripMetaCommands(sql string) string {
+	lines := strings.Split(sql, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if !strings.HasPrefix(strings.TrimSpace(line), `\`) {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// Latest returns the version of the last migration, 0 when there is none
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+func (m *Migrator) find(version int64) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+// Up applies every pending migration and returns how many were applied
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	return m.To(ctx, m.Latest())
+}
+
+// To applies the pending migrations up to version and reverts the applied ones
+// above it. Version 0 reverts every migration.
+func (m *Migrator) To(ctx context.Context, version int64) (int, error) {
+	if _, ok := m.find(version); !ok && version != 0 {
+		return 0, fmt.Errorf("%w: version %d", ErrMigrationNotFound, version)
+	}
+
+	count := 0
+	err := m.withLock(ctx, false, func(conn *pgx.Conn, applied map[int64]appliedMigration) error {
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok || migration.Version <= version {
+				continue
+			}
+			if err := m.run(ctx, conn, migration, false); err != nil {
+				return err
+			}
+			count++
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok || migration.Version > version {
+				continue
+			}
+			if err := m.run(ctx, conn, migration, true); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Down reverts the last steps applied migrations and returns how many were reverted
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	count := 0
+	err := m.withLock(ctx, false, func(conn *pgx.Conn, applied map[int64]appliedMigration) error {
+		for i := len(m.migrations) - 1; i >= 0 && count < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if err := m.run(ctx, conn, migration, false); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Baseline records the migrations up to version as applied without running them,
+// for databases created before the history was kept
+func (m *Migrator) Baseline(ctx context.Context, version int64) (int, error) {
+	if _, ok := m.find(version); !ok {
+		return 0, fmt.Errorf("%w: version %d", ErrMigrationNotFound, version)
+	}
+
+	count := 0
+	err := m.withLock(ctx, true, func(conn *pgx.Conn, applied map[int64]appliedMigration) error {
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok || migration.Version > version {
+				continue
+			}
+			if _, err := conn.Exec(ctx, `
+				INSERT INTO public.schema_migration_history (version, name, checksum, applied_at)
+				VALUES ($1, $2, $3, NOW())
+			`, migration.Version, migration.Name, migration.Checksum); err != nil {
+				return fmt.Errorf("record migration %s: %w", migration, err)
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Status lists the migrations of the build and of the database by version
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var exists bool
+	if err := m.pool.QueryRow(ctx, `
+		SELECT to_regclass('public.schema_migration_history') IS NOT NULL
+	`).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("check migration history: %w", err)
+	}
+
+	applied := make(map[int64]appliedMigration)
+	if exists {
+		var err error
+		if applied, err = loadAppliedMigrations(ctx, m.pool); err != nil {
+			return nil, err
+		}
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if record, ok := applied[migration.Version]; ok {
+			appliedAt := record.AppliedAt
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+			status.Modified = record.Checksum != migration.Checksum
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, record := range applied {
+		appliedAt := record.AppliedAt
+		statuses = append(statuses, MigrationStatus{
+			Version:   record.Version,
+			Applied:   true,
+			AppliedAt: &appliedAt,
+			Missing:   true,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+
+	return statuses, nil
+}
+
+// withLock runs fn on a connection holding the migration lock, with the applied
+// migrations checked against the build
+func (m *Migrator) withLock(ctx context.Context, baseline bool, fn func(conn *pgx.Conn, applied map[int64]appliedMigration) error) error {
+	poolConn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire connection: %w", err)
+	}
+	defer poolConn.Release()
+	conn := poolConn.Conn()
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() {
+		if _, err := conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockKey); err != nil {
+			// Closing the session releases the lock too
+			_ = conn.Close(context.Background())
+		}
+	}()
+
+	if _, err := conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS public.schema_migration_history (
+			version bigint PRIMARY KEY,
+			name varchar(255) NOT NULL,
+			checksum char(64) NOT NULL,
+			applied_at timestamptz NOT NULL DEFAULT NOW()
+		)
+	`); err != nil {
+		return fmt.Errorf("create migration history: %w", err)
+	}
+
+	applied, err := loadAppliedMigrations(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	if len(applied) == 0 && !baseline {
+		var untracked bool
+		if err := conn.QueryRow(ctx, `
+			SELECT EXISTS (
+				SELECT 1 FROM pg_catalog.pg_tables
+				WHERE schemaname = 'public' AND tablename <> 'schema_migration_history'
+			)
+		`).Scan(&untracked); err != nil {
+			return fmt.Errorf("check existing tables: %w", err)
+		}
+		if untracked {
+			return ErrMigrationUntracked
+		}
+	}
+
+	for _, record := range applied {
+		migration, ok := m.find(record.Version)
+		if !ok {
+			return fmt.Errorf("%w: version %d", ErrMigrationMissing, record.Version)
+		}
+		if migration.Checksum != record.Checksum {
+			return fmt.Errorf("%w: %s", ErrMigrationModified, migration)
+		}
+	}
+
+	return fn(conn, applied)
+}
+
+// run applies or reverts a migration in a transaction with its history record
+func (m *Migrator) run(ctx context.Context, conn *pgx.Conn, migration Migration, up bool) error {
+	start := time.Now()
+	direction := "up"
+	script := migration.Up
+	if !up {
+		direction = "down"
+		script = migration.Down
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin migration %s: %w", migration, err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	if _, err := tx.Exec(ctx, script); err != nil {
+		return fmt.Errorf("migration %s %s: %w", migration, direction, err)
+	}
+
+	if up {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO public.schema_migration_history (version, name, checksum, applied_at)
+			VALUES ($1, $2, $3, NOW())
+		`, migration.Version, migration.Name, migration.Checksum)
+	} else {
+		_, err = tx.Exec(ctx, `
+			DELETE FROM public.schema_migration_history WHERE version = $1
+		`, migration.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("record migration %s: %w", migration, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit migration %s: %w", migration, err)
+	}
+
+	// The migrations were written for psql and some change session settings such
+	// as the search_path, which must not leak into the next one or the pool
+	if _, err := conn.Exec(ctx, "RESET ALL"); err != nil {
+		return fmt.Errorf("reset session after migration %s: %w", migration, err)
+	}
+
+	log.Info().
+		Int64("version", migration.Version).
+		Str("name", migration.Name).
+		Str("direction", direction).
+		Dur("duration", time.Since(start)).
+		Msg("Migration applied")
+
+	return nil
+}
+
+func loadAppliedMigrations(ctx context.Context, db migrationQuerier) (map[int64]appliedMigration, error) {
+	rows, err := db.Query(ctx, `
+		SELECT version, checksum, applied_at
+		FROM public.schema_migration_history
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("load migration history: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]appliedMigration)
+	for rows.Next() {
+		var record appliedMigration
+		if err := rows.Scan(&record.Version, &record.Checksum, &record.AppliedAt); err != nil {
+			return nil, fmt.Errorf("scan migration history: %w", err)
+		}
+		applied[record.Version] = record
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("load migration history: %w", err)
+	}
+
+	return applied, nil
+}
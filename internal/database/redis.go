@@ -179,6 +179,8 @@ const (
 	CacheKeySKUPrefix        = "sku:"
 	CacheKeyRegionPrefix     = "region:"
 	CacheKeyPromoPrefix      = "promo:"
+	CacheKeyCategoryPrefix   = "category:"
+	CacheKeyBannerPrefix     = "banner:"
 	CacheKeyRateLimitPrefix  = "ratelimit:"
 	CacheKeyValidationPrefix = "validation:"
 	CacheKeyMFAPrefix        = "mfa:"
@@ -212,6 +214,14 @@ func (r *RedisClient) PromoCacheKey(promoCode string) string {
 	return CacheKeyPromoPrefix + promoCode
 }
 
+func (r *RedisClient) CategoryCacheKey(region string) string {
+	return CacheKeyCategoryPrefix + region
+}
+
+func (r *RedisClient) BannerCacheKey(region string) string {
+	return CacheKeyBannerPrefix + region
+}
+
 func (r *RedisClient) RateLimitKey(identifier, endpoint string) string {
 	return fmt.Sprintf("%s%s:%s", CacheKeyRateLimitPrefix, identifier, endpoint)
 }
@@ -240,6 +250,14 @@ func (r *RedisClient) InvalidateSKUCache(ctx context.Context, skuCode string) er
 	return r.deleteByPattern(ctx, pattern)
 }
 
+func (r *RedisClient) InvalidateCategoryCache(ctx context.Context) error {
+	return r.deleteByPattern(ctx, CacheKeyCategoryPrefix+"*")
+}
+
+func (r *RedisClient) InvalidateBannerCache(ctx context.Context) error {
+	return r.deleteByPattern(ctx, CacheKeyBannerPrefix+"*")
+}
+
 func (r *RedisClient) deleteByPattern(ctx context.Context, pattern string) error {
 	iter := r.Client.Scan(ctx, 0, pattern, 100).Iterator()
 	var keys []string
@@ -6,7 +6,10 @@ import (
 	"strings"
 
 	"seaply/internal/domain"
+	"seaply/internal/services"
 	"seaply/internal/utils"
+
+	"github.com/rs/zerolog/log"
 )
 
 type contextKey string
@@ -19,10 +22,11 @@ const (
 
 type AuthMiddleware struct {
 	jwtService utils.JWTService
+	audit      *services.AuditService
 }
 
-func NewAuthMiddleware(jwtService utils.JWTService) *AuthMiddleware {
-	return &AuthMiddleware{jwtService: jwtService}
+func NewAuthMiddleware(jwtService utils.JWTService, audit *services.AuditService) *AuthMiddleware {
+	return &AuthMiddleware{jwtService: jwtService, audit: audit}
 }
 
 // OptionalAuth allows requests with or without authentication
@@ -32,6 +36,9 @@ func (m *AuthMiddleware) OptionalAuth(next http.Handler) http.Handler {
 		if token != "" {
 			claims, err := m.jwtService.ValidateAccessToken(token)
 			if err == nil {
+				if claims.IsImpersonation() && !m.allowImpersonation(w, r, claims) {
+					return
+				}
 				ctx := context.WithValue(r.Context(), ClaimsContextKey, claims)
 				r = r.WithContext(ctx)
 			}
@@ -61,6 +68,10 @@ func (m *AuthMiddleware) RequireAuth(next http.Handler) http.Handler {
 			return
 		}
 
+		if claims.IsImpersonation() && !m.allowImpersonation(w, r, claims) {
+			return
+		}
+
 		ctx := context.WithValue(r.Context(), ClaimsContextKey, claims)
 		r = r.WithContext(ctx)
 		next.ServeHTTP(w, r)
@@ -161,6 +172,43 @@ func (m *AuthMiddleware) RequireAnyPermission(permissions ...string) func(http.H
 	}
 }
 
+// allowImpersonation audits a request made with an impersonation token and blocks it
+// unless it only reads
+func (m *AuthMiddleware) allowImpersonation(w http.ResponseWriter, r *http.Request, claims *utils.TokenClaims) bool {
+	readOnly := r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions
+
+	description := r.Method + " " + r.URL.Path
+	if !readOnly {
+		description = "Blocked " + description
+	}
+	if m.audit != nil {
+		err := m.audit.Write(r.Context(), services.AuditActor{
+			AdminID:   claims.ImpersonatedBy,
+			IPAddress: ClientIP(r),
+			UserAgent: r.UserAgent(),
+		}, services.AuditEntry{
+			Action:      services.AuditActionImpersonate,
+			Resource:    "USER",
+			ResourceID:  claims.Subject(),
+			Description: description,
+		})
+		if err != nil {
+			// Impersonated requests must leave a trace
+			log.Error().Err(err).Str("admin_id", claims.ImpersonatedBy).Str("user_id", claims.Subject()).Msg("Failed to audit impersonated request")
+			utils.WriteInternalServerError(w)
+			return false
+		}
+	}
+
+	if !readOnly {
+		utils.WriteErrorJSON(w, http.StatusForbidden, "IMPERSONATION_READ_ONLY",
+			"Sesi impersonasi hanya dapat membaca data",
+			"Write requests are not allowed while viewing as a user")
+		return false
+	}
+	return true
+}
+
 // Helper functions
 func extractToken(r *http.Request) string {
 	// Check Authorization header
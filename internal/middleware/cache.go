@@ -0,0 +1,480 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"seaply/internal/database"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+// CacheResource is a group of public catalogue responses that are cached and invalidated together
+type CacheResource string
+
+const (
+	CacheProducts   CacheResource = "products"
+	CacheSKUs       CacheResource = "skus"
+	CacheCategories CacheResource = "categories"
+	CacheBanners    CacheResource = "banners"
+)
+
+// CacheInvalidationChannel is the pub/sub channel used to drop local caches on every instance
+const CacheInvalidationChannel = "catalog:invalidate"
+
+// CatalogCacheConfig configures the catalogue cache
+type CatalogCacheConfig struct {
+	Enabled     bool
+	TTL         time.Duration // Redis TTL
+	LocalTTL    time.Duration // in-memory TTL, absorbs bursts without hitting Redis
+	LockTimeout time.Duration // how long other instances wait for the instance filling a key
+}
+
+// CacheStats holds the hit counters of a resource
+type CacheStats struct {
+	LocalHits int64 `json:"localHits"`
+	RedisHits int64 `json:"redisHits"`
+	Misses    int64 `json:"misses"`
+	Errors    int64 `json:"errors"`
+}
+
+type cacheCounters struct {
+	localHits atomic.Int64
+	redisHits atomic.Int64
+	misses    atomic.Int64
+	errors    atomic.Int64
+}
+
+type cachedResponse struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"contentType"`
+	Body        []byte `json:"body"`
+}
+
+type localEntry struct {
+	resource  CacheResource
+	response  *cachedResponse
+	expiresAt time.Time
+}
+
+type inflightCall struct {
+	done     chan struct{}
+	response *cachedResponse
+}
+
+// CatalogCache is a read-through cache for public catalogue endpoints.
+// Responses are cached per region and language in Redis and, for a few seconds, in memory.
+type CatalogCache struct {
+	redis *database.RedisClient
+	cfg   CatalogCacheConfig
+
+	mu       sync.Mutex
+	local    map[string]localEntry
+	inflight map[string]*inflightCall
+
+	// generations are bumped on invalidation so loads that started earlier aren't stored
+	generations map[CacheResource]uint64
+
+	counters map[CacheResource]*cacheCounters
+}
+
+// NewCatalogCache creates a new catalogue cache
+func NewCatalogCache(redis *database.RedisClient, cfg CatalogCacheConfig) *CatalogCache {
+	if cfg.TTL <= 0 {
+		cfg.TTL = 5 * time.Minute
+	}
+	if cfg.LocalTTL <= 0 {
+		cfg.LocalTTL = 5 * time.Second
+	}
+	if cfg.LockTimeout <= 0 {
+		cfg.LockTimeout = 3 * time.Second
+	}
+
+	counters := make(map[CacheResource]*cacheCounters)
+	for _, resource := range []CacheResource{CacheProducts, CacheSKUs, CacheCategories, CacheBanners} {
+		counters[resource] = &cacheCounters{}
+	}
+
+	return &CatalogCache{
+		redis:       redis,
+		cfg:         cfg,
+		local:       make(map[string]localEntry),
+		inflight:    make(map[string]*inflightCall),
+		generations: make(map[CacheResource]uint64),
+		counters:    counters,
+	}
+}
+
+// Cache caches successful GET responses of a catalogue resource
+func (c *CatalogCache) Cache(resource CacheResource) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if c == nil || !c.cfg.Enabled || r.Method != http.MethodGet {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := c.key(resource, r)
+			counters := c.counters[resource]
+
+			if response := c.getLocal(key); response != nil {
+				counters.localHits.Add(1)
+				writeCachedResponse(w, response, "HIT")
+				return
+			}
+
+			if response, err := c.getRedis(r.Context(), key); err == nil {
+				counters.redisHits.Add(1)
+				c.setLocal(resource, key, response)
+				writeCachedResponse(w, response, "HIT")
+				return
+			} else if !errors.Is(err, redis.Nil) {
+				counters.errors.Add(1)
+				log.Warn().Err(err).Str("key", key).Msg("Failed to read catalogue cache")
+			}
+
+			counters.misses.Add(1)
+			response, shared := c.load(resource, key, next, r)
+			status := "MISS"
+			if shared {
+				status = "SHARED"
+			}
+			writeCachedResponse(w, response, status)
+		})
+	}
+}
+
+// load runs the handler once per key per instance; concurrent requests wait for its result.
+// Across instances a short Redis lock lets one instance fill the key while the others poll for it.
+func (c *CatalogCache) load(resource CacheResource, key string, next http.Handler, r *http.Request) (*cachedResponse, bool) {
+	c.mu.Lock()
+	if call, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		<-call.done
+		return call.response, true
+	}
+	call := &inflightCall{done: make(chan struct{})}
+	c.inflight[key] = call
+	generation := c.generations[resource]
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.inflight, key)
+		c.mu.Unlock()
+		close(call.done)
+	}()
+
+	ctx := r.Context()
+	lockKey := "lock:" + key
+	locked, err := c.redis.SetNX(ctx, lockKey, 1, c.cfg.LockTimeout)
+	if err != nil {
+		// Redis is unavailable, serve from the database
+		locked = true
+	}
+	if !locked {
+		if response := c.waitForFill(ctx, key); response != nil {
+			call.response = response
+			c.setLocal(resource, key, response)
+			return response, true
+		}
+	}
+
+	recorder := &responseRecorder{header: make(http.Header), status: http.StatusOK}
+	next.ServeHTTP(recorder, r)
+
+	call.response = &cachedResponse{
+		Status:      recorder.status,
+		ContentType: recorder.header.Get("Content-Type"),
+		Body:        recorder.body.Bytes(),
+	}
+
+	if recorder.status == http.StatusOK && c.currentGeneration(resource) == generation {
+		c.setLocal(resource, key, call.response)
+		if err := c.redis.Set(ctx, key, call.response, c.cfg.TTL); err != nil {
+			c.counters[resource].errors.Add(1)
+			log.Warn().Err(err).Str("key", key).Msg("Failed to write catalogue cache")
+		}
+	}
+	if locked && err == nil {
+		_ = c.redis.Delete(ctx, lockKey)
+	}
+
+	return call.response, false
+}
+
+// waitForFill polls Redis until another instance has filled the key or the lock expires
+func (c *CatalogCache) waitForFill(ctx context.Context, key string) *cachedResponse {
+	deadline := time.Now().Add(c.cfg.LockTimeout)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		if response, err := c.getRedis(ctx, key); err == nil {
+			return response
+		}
+	}
+	return nil
+}
+
+// Invalidate drops the cached responses of the given resources on every instance
+func (c *CatalogCache) Invalidate(ctx context.Context, resources ...CacheResource) {
+	if c == nil || len(resources) == 0 {
+		return
+	}
+
+	c.invalidateLocal(resources)
+
+	for _, resource := range resources {
+		var err error
+		switch resource {
+		case CacheProducts:
+			// An empty product code clears every product entry
+			err = c.redis.InvalidateProductCache(ctx, "")
+		case CacheSKUs:
+			err = c.redis.InvalidateSKUCache(ctx, "")
+		case CacheCategories:
+			err = c.redis.InvalidateCategoryCache(ctx)
+		case CacheBanners:
+			err = c.redis.InvalidateBannerCache(ctx)
+		}
+		if err != nil {
+			log.Error().Err(err).Str("resource", string(resource)).Msg("Failed to invalidate catalogue cache")
+		}
+	}
+
+	if err := c.redis.Publish(ctx, CacheInvalidationChannel, resources); err != nil {
+		log.Warn().Err(err).Msg("Failed to publish catalogue cache invalidation")
+	}
+}
+
+// InvalidateOnSuccess invalidates resources after a mutation handler responds with a 2xx status
+func (c *CatalogCache) InvalidateOnSuccess(resources ...CacheResource) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			wrapped := wrapResponseWriter(w)
+			next.ServeHTTP(wrapped, r)
+
+			if r.Method != http.MethodGet && wrapped.Status() >= 200 && wrapped.Status() < 300 {
+				c.InvalidateAsync(resources...)
+			}
+		})
+	}
+}
+
+// InvalidateAsync invalidates resources in the background, for use after a mutation has been committed
+func (c *CatalogCache) InvalidateAsync(resources ...CacheResource) {
+	if c == nil {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		c.Invalidate(ctx, resources...)
+	}()
+}
+
+// Subscribe listens for invalidations published by other instances
+func (c *CatalogCache) Subscribe(ctx context.Context) {
+	pubsub := c.redis.Subscribe(ctx, CacheInvalidationChannel)
+	go func() {
+		defer pubsub.Close()
+
+		ch := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+
+				var resources []CacheResource
+				if err := json.Unmarshal([]byte(msg.Payload), &resources); err != nil {
+					log.Warn().Err(err).Msg("Invalid catalogue cache invalidation message")
+					continue
+				}
+				c.invalidateLocal(resources)
+			}
+		}
+	}()
+}
+
+// Stats returns the hit counters per resource
+func (c *CatalogCache) Stats() map[CacheResource]CacheStats {
+	stats := make(map[CacheResource]CacheStats, len(c.counters))
+	for resource, counters := range c.counters {
+		stats[resource] = CacheStats{
+			LocalHits: counters.localHits.Load(),
+			RedisHits: counters.redisHits.Load(),
+			Misses:    counters.misses.Load(),
+			Errors:    counters.errors.Load(),
+		}
+	}
+	return stats
+}
+
+// Enabled reports whether caching is enabled
+func (c *CatalogCache) Enabled() bool {
+	return c != nil && c.cfg.Enabled
+}
+
+// key builds the cache key from the resource, region, language and query string,
+// e.g. product:MLBB:ID:id:3f2a... or category:ID:en:da39...
+func (c *CatalogCache) key(resource CacheResource, r *http.Request) string {
+	region := GetRegionFromContext(r.Context())
+	if region == "" {
+		region = "ID"
+	}
+
+	query := r.URL.Query()
+	var base string
+	switch resource {
+	case CacheProducts:
+		code := query.Get("productCode")
+		if code == "" {
+			code = "list"
+		}
+		base = c.redis.ProductCacheKey(code, region)
+	case CacheSKUs:
+		slug := chi.URLParam(r, "slug")
+		if slug == "" {
+			slug = query.Get("slug")
+		}
+		if slug == "" {
+			slug = query.Get("productCode")
+		}
+		base = c.redis.SKUCacheKey(slug, region)
+	case CacheCategories:
+		base = c.redis.CategoryCacheKey(region)
+	case CacheBanners:
+		base = c.redis.BannerCacheKey(region)
+	}
+
+	hash := sha1.Sum([]byte(query.Encode()))
+	return base + ":" + requestLanguage(r) + ":" + hex.EncodeToString(hash[:8])
+}
+
+// requestLanguage returns the two letter language of the request
+func requestLanguage(r *http.Request) string {
+	language := r.URL.Query().Get("lang")
+	if language == "" {
+		language = r.Header.Get("Accept-Language")
+	}
+	language = strings.ToLower(strings.TrimSpace(language))
+	if len(language) < 2 || language[0] < 'a' || language[0] > 'z' || language[1] < 'a' || language[1] > 'z' {
+		return "xx"
+	}
+	return language[:2]
+}
+
+func (c *CatalogCache) getLocal(key string) *cachedResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.local[key]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(c.local, key)
+		return nil
+	}
+	return entry.response
+}
+
+func (c *CatalogCache) setLocal(resource CacheResource, key string, response *cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Drop expired entries now and then so the map doesn't grow with unique queries
+	if len(c.local) > 10000 {
+		now := time.Now()
+		for k, entry := range c.local {
+			if now.After(entry.expiresAt) {
+				delete(c.local, k)
+			}
+		}
+	}
+
+	c.local[key] = localEntry{
+		resource:  resource,
+		response:  response,
+		expiresAt: time.Now().Add(c.cfg.LocalTTL),
+	}
+}
+
+func (c *CatalogCache) getRedis(ctx context.Context, key string) (*cachedResponse, error) {
+	var response cachedResponse
+	if err := c.redis.Get(ctx, key, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+func (c *CatalogCache) invalidateLocal(resources []CacheResource) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, resource := range resources {
+		c.generations[resource]++
+	}
+	for key, entry := range c.local {
+		for _, resource := range resources {
+			if entry.resource == resource {
+				delete(c.local, key)
+				break
+			}
+		}
+	}
+}
+
+func (c *CatalogCache) currentGeneration(resource CacheResource) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generations[resource]
+}
+
+func writeCachedResponse(w http.ResponseWriter, response *cachedResponse, status string) {
+	if response.ContentType != "" {
+		w.Header().Set("Content-Type", response.ContentType)
+	}
+	w.Header().Set("X-Cache", status)
+	w.WriteHeader(response.Status)
+	w.Write(response.Body)
+}
+
+// responseRecorder captures a handler response so it can be cached and shared
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	return r.body.Write(b)
+}
@@ -0,0 +1,251 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"seaply/internal/utils"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// Idempotency headers
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	minGuestIdempotencyKey    = 32 // guests must send a random key such as a UUID
+	maxIdempotentRequestBytes = 1 << 20
+)
+
+type idempotencyOutcomeKey struct{}
+
+// idempotencyOutcome is filled in by the handler through IdempotentRetryable
+type idempotencyOutcome struct {
+	retryable bool
+}
+
+// IdempotentRetryable tells the idempotency middleware that the request failed before
+// anything was persisted, so its error response is dropped and a retry with the same key
+// runs again. Any other response, including a 5xx, is stored and replayed.
+func IdempotentRetryable(ctx context.Context) {
+	if outcome, ok := ctx.Value(idempotencyOutcomeKey{}).(*idempotencyOutcome); ok {
+		outcome.retryable = true
+	}
+}
+
+// IdempotencyConfig configures the idempotency store
+type IdempotencyConfig struct {
+	TTL         time.Duration // how long a response is replayed
+	LockTimeout time.Duration // how long a request may run before a retry can take its key over
+}
+
+// idempotencyDB is the part of the pool the idempotency store uses
+type idempotencyDB interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// IdempotencyStore makes POST endpoints safe to retry. The first response for an
+// Idempotency-Key is stored in Postgres and replayed for retries with the same key;
+// a retry arriving while the first request still runs is rejected, and so is a
+// request reusing a key with a different body.
+type IdempotencyStore struct {
+	pool idempotencyDB
+	cfg  IdempotencyConfig
+}
+
+// NewIdempotencyStore creates an idempotency store
+func NewIdempotencyStore(pool *pgxpool.Pool, cfg IdempotencyConfig) *IdempotencyStore {
+	if cfg.TTL <= 0 {
+		cfg.TTL = 24 * time.Hour
+	}
+	if cfg.LockTimeout <= 0 {
+		cfg.LockTimeout = 2 * time.Minute
+	}
+	return &IdempotencyStore{pool: pool, cfg: cfg}
+}
+
+// Start periodically deletes expired keys
+func (s *IdempotencyStore) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := s.pool.Exec(ctx, `DELETE FROM idempotency_keys WHERE expires_at < NOW()`); err != nil {
+					log.Warn().Err(err).Msg("Failed to delete expired idempotency keys")
+				}
+			}
+		}
+	}()
+}
+
+// Idempotent honours the Idempotency-Key header on a route. Requests without the
+// header are passed through. Keys are scoped to the signed-in user or admin, guest
+// keys to the client IP and user agent.
+func (s *IdempotencyStore) Idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if s == nil || key == "" || r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			utils.WriteErrorJSON(w, http.StatusBadRequest, "INVALID_IDEMPOTENCY_KEY",
+				"Idempotency key must be at most 255 characters", "")
+			return
+		}
+
+		scope := idempotencyScope(r)
+		if scope == "" {
+			if len(key) < minGuestIdempotencyKey {
+				utils.WriteErrorJSON(w, http.StatusBadRequest, "INVALID_IDEMPOTENCY_KEY",
+					"Idempotency key must be a random value of at least 32 characters, such as a UUID", "")
+				return
+			}
+			scope = guestIdempotencyScope(r)
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentRequestBytes))
+		if err != nil {
+			utils.WriteBadRequestError(w, "Invalid request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := idempotencyRequestHash(r.Method, r.URL.Path, body)
+
+		owned, err := s.acquire(r.Context(), scope, key, hash)
+		if err != nil {
+			log.Error().Err(err).Str("scope", scope).Msg("Failed to acquire idempotency key")
+			utils.WriteInternalServerError(w)
+			return
+		}
+		if !owned {
+			s.replay(w, r, scope, key, hash)
+			return
+		}
+
+		outcome := &idempotencyOutcome{}
+		recorder := &responseRecorder{header: w.Header(), status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), idempotencyOutcomeKey{}, outcome)))
+
+		// The request ran under its own deadline, the result is stored regardless of the client
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), 5*time.Second)
+		defer cancel()
+
+		if outcome.retryable && recorder.status >= http.StatusInternalServerError {
+			// The handler reported nothing was persisted, let the client retry with the same key
+			_, err = s.pool.Exec(ctx, `DELETE FROM idempotency_keys WHERE scope = $1 AND idempotency_key = $2`, scope, key)
+		} else {
+			_, err = s.pool.Exec(ctx, `
+				UPDATE idempotency_keys
+				SET status = 'COMPLETED', response_status = $3, response_content_type = $4, response_body = $5, completed_at = NOW()
+				WHERE scope = $1 AND idempotency_key = $2
+			`, scope, key, recorder.status, recorder.header.Get("Content-Type"), recorder.body.Bytes())
+		}
+		if err != nil {
+			log.Error().Err(err).Str("scope", scope).Msg("Failed to store idempotent response")
+		}
+
+		w.WriteHeader(recorder.status)
+		w.Write(recorder.body.Bytes())
+	})
+}
+
+// acquire claims a key for this request. An expired key, or one left PROCESSING by a
+// request that died, is taken over.
+func (s *IdempotencyStore) acquire(ctx context.Context, scope, key, hash string) (bool, error) {
+	var id string
+	err := s.pool.QueryRow(ctx, `
+		INSERT INTO idempotency_keys (scope, idempotency_key, request_hash, locked_until, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (scope, idempotency_key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash, status = 'PROCESSING', locked_until = EXCLUDED.locked_until,
+		    response_status = NULL, response_content_type = NULL, response_body = NULL,
+		    created_at = NOW(), completed_at = NULL, expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at < NOW()
+		   OR (idempotency_keys.status = 'PROCESSING' AND idempotency_keys.locked_until < NOW()
+		       AND idempotency_keys.request_hash = EXCLUDED.request_hash)
+		RETURNING id
+	`, scope, key, hash, time.Now().Add(s.cfg.LockTimeout), time.Now().Add(s.cfg.TTL)).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// replay answers a request whose key is already in use
+func (s *IdempotencyStore) replay(w http.ResponseWriter, r *http.Request, scope, key, hash string) {
+	var storedHash, status string
+	var responseStatus *int
+	var contentType *string
+	var body []byte
+	err := s.pool.QueryRow(r.Context(), `
+		SELECT request_hash, status, response_status, response_content_type, response_body
+		FROM idempotency_keys
+		WHERE scope = $1 AND idempotency_key = $2
+	`, scope, key).Scan(&storedHash, &status, &responseStatus, &contentType, &body)
+	if err != nil {
+		log.Error().Err(err).Str("scope", scope).Msg("Failed to load idempotency key")
+		utils.WriteInternalServerError(w)
+		return
+	}
+
+	if storedHash != hash {
+		utils.WriteErrorJSON(w, http.StatusUnprocessableEntity, "IDEMPOTENCY_KEY_MISMATCH",
+			"Idempotency key was already used for a different request", "")
+		return
+	}
+	if status != "COMPLETED" || responseStatus == nil {
+		w.Header().Set("Retry-After", "1")
+		utils.WriteErrorJSON(w, http.StatusConflict, "IDEMPOTENCY_KEY_IN_USE",
+			"A request with this idempotency key is still being processed", "")
+		return
+	}
+
+	if contentType != nil && *contentType != "" {
+		w.Header().Set("Content-Type", *contentType)
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(*responseStatus)
+	w.Write(body)
+}
+
+// idempotencyScope keeps the keys of different accounts apart. It is empty for guests.
+func idempotencyScope(r *http.Request) string {
+	if adminID := GetAdminIDFromContext(r.Context()); adminID != "" {
+		return "admin:" + adminID
+	}
+	if userID := GetUserIDFromContext(r.Context()); userID != "" {
+		return "user:" + userID
+	}
+	return ""
+}
+
+// guestIdempotencyScope keeps the keys of different guests apart, so a guest reusing
+// another guest's key gets a fresh request instead of their stored response
+func guestIdempotencyScope(r *http.Request) string {
+	h := sha256.Sum256([]byte(ClientIP(r) + "\n" + r.UserAgent()))
+	return "guest:" + hex.EncodeToString(h[:16])
+}
+
+func idempotencyRequestHash(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
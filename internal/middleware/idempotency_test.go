@@ -0,0 +1,323 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// fakeIdempotencyKey is a row of idempotency_keys
+type fakeIdempotencyKey struct {
+	hash        string
+	status      string
+	lockedUntil time.Time
+	expiresAt   time.Time
+	code        *int
+	contentType *string
+	body        []byte
+}
+
+// fakeIdempotencyDB keeps idempotency_keys in memory, answering the statements
+// of IdempotencyStore the way Postgres would
+type fakeIdempotencyDB struct {
+	mu   sync.Mutex
+	keys map[string]*fakeIdempotencyKey
+}
+
+func newFakeIdempotencyDB() *fakeIdempotencyDB {
+	return &fakeIdempotencyDB{keys: map[string]*fakeIdempotencyKey{}}
+}
+
+func (db *fakeIdempotencyDB) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	id := args[0].(string) + "|" + args[1].(string)
+	switch {
+	case strings.Contains(sql, "DELETE FROM idempotency_keys"):
+		delete(db.keys, id)
+	case strings.Contains(sql, "UPDATE idempotency_keys"):
+		code, contentType := args[2].(int), args[3].(string)
+		k := db.keys[id]
+		k.status, k.code, k.contentType, k.body = "COMPLETED", &code, &contentType, args[4].([]byte)
+	}
+	return pgconn.CommandTag{}, nil
+}
+
+func (db *fakeIdempotencyDB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	id := args[0].(string) + "|" + args[1].(string)
+	k := db.keys[id]
+	if strings.Contains(sql, "INSERT INTO idempotency_keys") {
+		hash := args[2].(string)
+		now := time.Now()
+		if k != nil && !k.expiresAt.Before(now) &&
+			!(k.status == "PROCESSING" && k.lockedUntil.Before(now) && k.hash == hash) {
+			return fakeIdempotencyRow{err: pgx.ErrNoRows}
+		}
+		db.keys[id] = &fakeIdempotencyKey{
+			hash: hash, status: "PROCESSING", lockedUntil: args[3].(time.Time), expiresAt: args[4].(time.Time),
+		}
+		return fakeIdempotencyRow{values: []any{id}}
+	}
+	if k == nil {
+		return fakeIdempotencyRow{err: pgx.ErrNoRows}
+	}
+	return fakeIdempotencyRow{values: []any{k.hash, k.status, k.code, k.contentType, k.body}}
+}
+
+type fakeIdempotencyRow struct {
+	values []any
+	err    error
+}
+
+func (r fakeIdempotencyRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	for i, d := range dest {
+		switch d := d.(type) {
+		case *string:
+			*d = r.values[i].(string)
+		case **int:
+			*d = r.values[i].(*int)
+		case **string:
+			*d = r.values[i].(*string)
+		case *[]byte:
+			*d = r.values[i].([]byte)
+		}
+	}
+	return nil
+}
+
+const testIdempotencyKey = "5f0c2b9e-7f1d-4a57-9b43-3c4d2e1f0a9b"
+
+func TestIdempotentReplay(t *testing.T) {
+	type call struct {
+		key        string
+		body       string
+		remoteAddr string
+		wantStatus int
+		wantReplay bool
+	}
+
+	tests := []struct {
+		name string
+		// handler response, by call of the handler
+		status    []int
+		retryable bool
+		existing  *fakeIdempotencyKey
+		calls     []call
+		wantRuns  int
+	}{
+		{
+			name:   "retry replays the stored response",
+			status: []int{http.StatusCreated},
+			calls: []call{
+				{key: testIdempotencyKey, body: `{"sku":"ML86"}`, wantStatus: http.StatusCreated},
+				{key: testIdempotencyKey, body: `{"sku":"ML86"}`, wantStatus: http.StatusCreated, wantReplay: true},
+				{key: testIdempotencyKey, body: `{"sku":"ML86"}`, wantStatus: http.StatusCreated, wantReplay: true},
+			},
+			wantRuns: 1,
+		},
+		{
+			name:   "key reused for another body",
+			status: []int{http.StatusCreated},
+			calls: []call{
+				{key: testIdempotencyKey, body: `{"sku":"ML86"}`, wantStatus: http.StatusCreated},
+				{key: testIdempotencyKey, body: `{"sku":"ML172"}`, wantStatus: http.StatusUnprocessableEntity},
+			},
+			wantRuns: 1,
+		},
+		{
+			name:   "server error is replayed",
+			status: []int{http.StatusInternalServerError},
+			calls: []call{
+				{key: testIdempotencyKey, body: `{}`, wantStatus: http.StatusInternalServerError},
+				{key: testIdempotencyKey, body: `{}`, wantStatus: http.StatusInternalServerError, wantReplay: true},
+			},
+			wantRuns: 1,
+		},
+		{
+			name:      "retryable server error runs again",
+			status:    []int{http.StatusBadGateway, http.StatusCreated},
+			retryable: true,
+			calls: []call{
+				{key: testIdempotencyKey, body: `{}`, wantStatus: http.StatusBadGateway},
+				{key: testIdempotencyKey, body: `{}`, wantStatus: http.StatusCreated},
+			},
+			wantRuns: 2,
+		},
+		{
+			name:   "retryable client error is stored",
+			status: []int{http.StatusBadRequest},
+			calls: []call{
+				{key: testIdempotencyKey, body: `{}`, wantStatus: http.StatusBadRequest},
+				{key: testIdempotencyKey, body: `{}`, wantStatus: http.StatusBadRequest, wantReplay: true},
+			},
+			retryable: true,
+			wantRuns:  1,
+		},
+		{
+			name:   "request still running",
+			status: []int{http.StatusCreated},
+			existing: &fakeIdempotencyKey{
+				hash: idempotencyRequestHash(http.MethodPost, "/orders", []byte(`{}`)), status: "PROCESSING",
+				lockedUntil: time.Now().Add(time.Minute), expiresAt: time.Now().Add(time.Hour),
+			},
+			calls: []call{
+				{key: testIdempotencyKey, body: `{}`, wantStatus: http.StatusConflict},
+			},
+			wantRuns: 0,
+		},
+		{
+			name:   "stale lock is taken over",
+			status: []int{http.StatusCreated},
+			existing: &fakeIdempotencyKey{
+				hash: idempotencyRequestHash(http.MethodPost, "/orders", []byte(`{}`)), status: "PROCESSING",
+				lockedUntil: time.Now().Add(-time.Minute), expiresAt: time.Now().Add(time.Hour),
+			},
+			calls: []call{
+				{key: testIdempotencyKey, body: `{}`, wantStatus: http.StatusCreated},
+			},
+			wantRuns: 1,
+		},
+		{
+			name:   "expired key runs again",
+			status: []int{http.StatusCreated},
+			existing: &fakeIdempotencyKey{
+				hash: "other", status: "COMPLETED", expiresAt: time.Now().Add(-time.Minute),
+			},
+			calls: []call{
+				{key: testIdempotencyKey, body: `{}`, wantStatus: http.StatusCreated},
+			},
+			wantRuns: 1,
+		},
+		{
+			name:   "guests do not share keys",
+			status: []int{http.StatusCreated, http.StatusOK},
+			calls: []call{
+				{key: testIdempotencyKey, body: `{}`, remoteAddr: "203.0.113.7:1000", wantStatus: http.StatusCreated},
+				{key: testIdempotencyKey, body: `{}`, remoteAddr: "198.51.100.2:1000", wantStatus: http.StatusOK},
+			},
+			wantRuns: 2,
+		},
+		{
+			name:   "short guest key",
+			status: []int{http.StatusCreated},
+			calls: []call{
+				{key: "retry-1", body: `{}`, wantStatus: http.StatusBadRequest},
+			},
+			wantRuns: 0,
+		},
+		{
+			name:   "no key",
+			status: []int{http.StatusCreated, http.StatusCreated},
+			calls: []call{
+				{body: `{}`, wantStatus: http.StatusCreated},
+				{body: `{}`, wantStatus: http.StatusCreated},
+			},
+			wantRuns: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newFakeIdempotencyDB()
+			store := &IdempotencyStore{pool: db, cfg: IdempotencyConfig{TTL: time.Hour, LockTimeout: time.Minute}}
+
+			runs := 0
+			handler := store.Idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tt.status[runs]
+				runs++
+				if tt.retryable {
+					IdempotentRetryable(r.Context())
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(status)
+				w.Write([]byte(`{"run":` + strconv.Itoa(runs) + `}`))
+			}))
+
+			var first string
+			for i, c := range tt.calls {
+				if tt.existing != nil && i == 0 {
+					remote := c.remoteAddr
+					if remote == "" {
+						remote = "192.0.2.1:1234"
+					}
+					r := httptest.NewRequest(http.MethodPost, "/orders", nil)
+					r.RemoteAddr = remote
+					db.keys[guestIdempotencyScope(r)+"|"+c.key] = tt.existing
+				}
+
+				r := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(c.body))
+				if c.remoteAddr != "" {
+					r.RemoteAddr = c.remoteAddr
+				}
+				if c.key != "" {
+					r.Header.Set(IdempotencyKeyHeader, c.key)
+				}
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, r)
+
+				if w.Code != c.wantStatus {
+					t.Errorf("call %d: status %d, want %d", i+1, w.Code, c.wantStatus)
+				}
+				if replayed := w.Header().Get(IdempotentReplayedHeader) == "true"; replayed != c.wantReplay {
+					t.Errorf("call %d: replayed = %v, want %v", i+1, replayed, c.wantReplay)
+				}
+				if i == 0 {
+					first = w.Body.String()
+				} else if c.wantReplay && w.Body.String() != first {
+					t.Errorf("call %d: replayed body %q, want %q", i+1, w.Body.String(), first)
+				}
+				if c.wantReplay && w.Header().Get("Content-Type") != "application/json" {
+					t.Errorf("call %d: replayed content type %q", i+1, w.Header().Get("Content-Type"))
+				}
+			}
+			if runs != tt.wantRuns {
+				t.Errorf("handler ran %d times, want %d", runs, tt.wantRuns)
+			}
+		})
+	}
+}
+
+func TestIdempotentIgnoresOtherMethods(t *testing.T) {
+	store := &IdempotencyStore{pool: newFakeIdempotencyDB()}
+	runs := 0
+	handler := store.Idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		runs++
+	}))
+
+	for i := 0; i < 2; i++ {
+		r := httptest.NewRequest(http.MethodGet, "/orders", nil)
+		r.Header.Set(IdempotencyKeyHeader, testIdempotencyKey)
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	if runs != 2 {
+		t.Errorf("handler ran %d times, want 2", runs)
+	}
+}
+
+func TestIdempotencyRequestHash(t *testing.T) {
+	base := idempotencyRequestHash(http.MethodPost, "/orders", []byte(`{}`))
+	for _, other := range []string{
+		idempotencyRequestHash(http.MethodPut, "/orders", []byte(`{}`)),
+		idempotencyRequestHash(http.MethodPost, "/deposits", []byte(`{}`)),
+		idempotencyRequestHash(http.MethodPost, "/orders", []byte(`{"a":1}`)),
+	} {
+		if other == base {
+			t.Error("different requests share a hash")
+		}
+	}
+}
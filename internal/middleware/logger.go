@@ -45,6 +45,21 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	return size, err
 }
 
+// Flush sends buffered data to the client, needed by streaming responses
+func (rw *responseWriter) Flush() {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // Logger middleware logs HTTP requests
 func Logger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -72,7 +87,7 @@ func Logger(next http.Handler) http.Handler {
 				Int("status", wrapped.status).
 				Int("size", wrapped.size).
 				Dur("duration", duration).
-				Str("ip", getClientIP(r)).
+				Str("ip", ClientIP(r)).
 				Str("user_agent", r.UserAgent()).
 				Str("request_id", requestID).
 				Msg("HTTP Request")
@@ -91,21 +106,3 @@ func RequestLogger(r *http.Request) zerolog.Logger {
 		Str("path", r.URL.Path).
 		Logger()
 }
-
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header
-	xff := r.Header.Get("X-Forwarded-For")
-	if xff != "" {
-		return xff
-	}
-
-	// Check X-Real-IP header
-	xri := r.Header.Get("X-Real-IP")
-	if xri != "" {
-		return xri
-	}
-
-	// Fall back to RemoteAddr
-	return r.RemoteAddr
-}
-
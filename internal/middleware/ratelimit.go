@@ -2,17 +2,23 @@ package middleware
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"math/rand"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"seaply/internal/database"
 	"seaply/internal/utils"
-)
 
-type RateLimiter struct {
-	redis *database.RedisClient
-}
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
 
 type RateLimitConfig struct {
 	Requests int           // Number of requests allowed
@@ -27,100 +33,406 @@ var (
 	AdminRateLimit   = RateLimitConfig{Requests: 200, Window: time.Minute}
 )
 
-func NewRateLimiter(redis *database.RedisClient) *RateLimiter {
-	return &RateLimiter{redis: redis}
+// Route groups, each with its own policy in the rate_limit settings category
+const (
+	RateLimitGroupPublic  = "public"
+	RateLimitGroupAuth    = "auth"
+	RateLimitGroupUser    = "user"
+	RateLimitGroupOrder   = "order"
+	RateLimitGroupAdmin   = "admin"
+	RateLimitGroupWebhook = "webhook"
+	RateLimitGroupPartner = "partner"
+)
+
+// Rate limit tiers
+const (
+	RateLimitTierDefault = "DEFAULT"
+	RateLimitTierRoyal   = "ROYAL"
+	RateLimitTierPartner = "PARTNER"
+)
+
+const (
+	RateLimitTierContextKey    contextKey = "rate_limit_tier"
+	RateLimitSubjectContextKey contextKey = "rate_limit_subject"
+)
+
+// RateLimitPolicy is the limit of a route group. Anonymous clients are limited per IP
+// with Requests; authenticated users get their own budget of UserRequests.
+type RateLimitPolicy struct {
+	Requests     int   `json:"requests"`
+	UserRequests int   `json:"userRequests"`
+	Window       int   `json:"window"` // seconds
+	FailOpen     *bool `json:"failOpen,omitempty"`
 }
 
-// RateLimit creates a rate limiting middleware
-func (rl *RateLimiter) RateLimit(cfg RateLimitConfig) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Get identifier (IP or user ID)
-			identifier := getClientIP(r)
-			if userID := GetUserIDFromContext(r.Context()); userID != "" {
-				identifier = userID
+func (p RateLimitPolicy) window() time.Duration {
+	if p.Window <= 0 {
+		return time.Minute
+	}
+	return time.Duration(p.Window) * time.Second
+}
+
+// TierResolver returns the rate limit tier of a user, e.g. ROYAL for royal members
+type TierResolver func(ctx context.Context, userID string) (string, error)
+
+// RateLimiterOptions configures the rate limiter
+type RateLimiterOptions struct {
+	FailOpen         bool     // allow requests when Redis is unavailable
+	WebhookAllowlist []string // IPs or CIDRs of payment gateways and providers
+	TierResolver     TierResolver
+}
+
+type cachedTier struct {
+	tier      string
+	expiresAt time.Time
+}
+
+type RateLimiter struct {
+	redis *database.RedisClient
+
+	mu                sync.RWMutex
+	policies          map[string]RateLimitPolicy
+	royalMultiplier   float64
+	partnerMultiplier float64
+	failOpen          bool
+	allowlist         []*net.IPNet
+	enforceAllowlist  bool
+
+	tierResolver TierResolver
+	tiersMu      sync.Mutex
+	tiers        map[string]cachedTier
+}
+
+// slidingWindowScript keeps one sorted set entry per request within the window and
+// only admits the request if fewer than limit entries remain. Returns {allowed, count, resetAtMs}.
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', key, 0, now - window)
+local count = redis.call('ZCARD', key)
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', key, now, ARGV[4])
+	count = count + 1
+	allowed = 1
+end
+redis.call('PEXPIRE', key, window)
+
+local reset = now + window
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window
+end
+return {allowed, count, reset}
+`)
+
+func NewRateLimiter(redis *database.RedisClient, opts RateLimiterOptions) *RateLimiter {
+	rl := &RateLimiter{
+		redis: redis,
+		policies: map[string]RateLimitPolicy{
+			RateLimitGroupPublic:  {Requests: DefaultRateLimit.Requests, UserRequests: DefaultRateLimit.Requests * 2, Window: 60},
+			RateLimitGroupAuth:    {Requests: AuthRateLimit.Requests, UserRequests: AuthRateLimit.Requests, Window: 60},
+			RateLimitGroupUser:    {Requests: DefaultRateLimit.Requests, UserRequests: DefaultRateLimit.Requests, Window: 60},
+			RateLimitGroupOrder:   {Requests: OrderRateLimit.Requests, UserRequests: OrderRateLimit.Requests * 2, Window: 60},
+			RateLimitGroupAdmin:   {Requests: AdminRateLimit.Requests, UserRequests: AdminRateLimit.Requests, Window: 60},
+			RateLimitGroupWebhook: {Requests: 600, UserRequests: 600, Window: 60},
+			RateLimitGroupPartner: {Requests: 60, UserRequests: 60, Window: 60},
+		},
+		royalMultiplier:   3,
+		partnerMultiplier: 10,
+		failOpen:          opts.FailOpen,
+		tierResolver:      opts.TierResolver,
+		tiers:             make(map[string]cachedTier),
+	}
+	rl.allowlist = parseAllowlist(opts.WebhookAllowlist)
+	return rl
+}
+
+// LoadSettings reads the rate_limit settings category. Each route group key holds a
+// RateLimitPolicy; royalMultiplier, partnerMultiplier, failOpen, webhookAllowlist and
+// webhookEnforceAllowlist tune the limiter as a whole.
+func (rl *RateLimiter) LoadSettings(ctx context.Context, pool *pgxpool.Pool) error {
+	rows, err := pool.Query(ctx, `
+		SELECT key, value FROM settings WHERE category = 'rate_limit'
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to load rate limit settings: %w", err)
+	}
+	defer rows.Close()
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	for rows.Next() {
+		var key string
+		var value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			return fmt.Errorf("failed to scan rate limit setting: %w", err)
+		}
+
+		switch key {
+		case "royalMultiplier":
+			json.Unmarshal(value, &rl.royalMultiplier)
+		case "partnerMultiplier":
+			json.Unmarshal(value, &rl.partnerMultiplier)
+		case "failOpen":
+			json.Unmarshal(value, &rl.failOpen)
+		case "webhookEnforceAllowlist":
+			json.Unmarshal(value, &rl.enforceAllowlist)
+		case "webhookAllowlist":
+			var entries []string
+			if err := json.Unmarshal(value, &entries); err == nil && len(entries) > 0 {
+				rl.allowlist = parseAllowlist(entries)
+			}
+		default:
+			var policy RateLimitPolicy
+			if err := json.Unmarshal(value, &policy); err != nil || policy.Requests <= 0 {
+				log.Warn().Str("key", key).Msg("Invalid rate limit policy")
+				continue
+			}
+			if policy.UserRequests <= 0 {
+				policy.UserRequests = policy.Requests
 			}
+			rl.policies[key] = policy
+		}
+	}
+
+	return rows.Err()
+}
 
-			// Create rate limit key
-			key := rl.redis.RateLimitKey(identifier, r.URL.Path)
+// Start periodically reloads the rate limit settings
+func (rl *RateLimiter) Start(ctx context.Context, pool *pgxpool.Pool, interval time.Duration) {
+	go func() {
+		if err := rl.LoadSettings(ctx, pool); err != nil {
+			log.Warn().Err(err).Msg("Failed to load rate limit settings")
+		}
 
-			// Check and increment
-			allowed, remaining, resetAt, err := rl.checkAndIncrement(r.Context(), key, cfg)
-			if err != nil {
-				// On error, allow the request but log it
-				next.ServeHTTP(w, r)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
 				return
+			case <-ticker.C:
+				if err := rl.LoadSettings(ctx, pool); err != nil {
+					log.Warn().Err(err).Msg("Failed to reload rate limit settings")
+				}
+			}
+		}
+	}()
+}
+
+// Limit rate limits a route group using the policy from settings.
+// Authenticated users and API partners are limited per account, everyone else per IP.
+func (rl *RateLimiter) Limit(group string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rl.mu.RLock()
+			policy, ok := rl.policies[group]
+			if !ok {
+				policy = rl.policies[RateLimitGroupPublic]
 			}
+			failOpen := rl.failOpen
+			if policy.FailOpen != nil {
+				failOpen = *policy.FailOpen
+			}
+			rl.mu.RUnlock()
 
-			// Set rate limit headers
-			w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", cfg.Requests))
-			w.Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%d", remaining))
-			w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", resetAt.Unix()))
+			ip := ClientIP(r)
+			limit := policy.Requests
+			key := rl.redis.RateLimitKey("ip:"+ip, group)
 
-			if !allowed {
-				utils.WriteErrorJSON(w, http.StatusTooManyRequests, "RATE_LIMIT_EXCEEDED",
-					"Terlalu banyak permintaan. Silakan coba lagi nanti.",
-					fmt.Sprintf("Limit: %d requests per %s", cfg.Requests, cfg.Window))
-				return
+			if claims := GetClaimsFromContext(r.Context()); claims != nil {
+				limit = policy.UserRequests
+				key = rl.redis.RateLimitKey(claims.Type+":"+claims.Subject(), group)
+				if claims.Type != "admin" {
+					limit = rl.applyTier(limit, rl.tier(r.Context(), claims.Subject()))
+				}
+			} else {
+				if subject, ok := r.Context().Value(RateLimitSubjectContextKey).(string); ok {
+					limit = policy.UserRequests
+					key = rl.redis.RateLimitKey(subject, group)
+				}
+				if tier, ok := r.Context().Value(RateLimitTierContextKey).(string); ok {
+					limit = rl.applyTier(limit, tier)
+				}
 			}
 
-			next.ServeHTTP(w, r)
+			rl.enforce(w, r, next, key, limit, policy.window(), failOpen)
 		})
 	}
 }
 
-func (rl *RateLimiter) checkAndIncrement(ctx context.Context, key string, cfg RateLimitConfig) (bool, int, time.Time, error) {
-	// Get current count
-	count, err := rl.redis.Client.Incr(ctx, key).Result()
+// WebhookGuard lets allowlisted gateway IPs through without limits. Other IPs are
+// rate limited by the webhook policy, or rejected when the allowlist is enforced.
+func (rl *RateLimiter) WebhookGuard(next http.Handler) http.Handler {
+	limited := rl.Limit(RateLimitGroupWebhook)(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := ClientIP(r)
+
+		rl.mu.RLock()
+		allowed := ipAllowed(rl.allowlist, ip)
+		enforce := rl.enforceAllowlist && len(rl.allowlist) > 0
+		rl.mu.RUnlock()
+
+		if allowed {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if enforce {
+			log.Warn().Str("ip", ip).Str("path", r.URL.Path).Msg("Webhook from IP outside allowlist")
+			utils.WriteErrorJSON(w, http.StatusForbidden, "IP_NOT_ALLOWED",
+				"IP address is not allowed", "")
+			return
+		}
+
+		limited.ServeHTTP(w, r)
+	})
+}
+
+func (rl *RateLimiter) enforce(w http.ResponseWriter, r *http.Request, next http.Handler, key string, limit int, window time.Duration, failOpen bool) {
+	allowed, remaining, resetAt, err := rl.check(r.Context(), key, limit, window)
 	if err != nil {
-		return true, cfg.Requests, time.Now().Add(cfg.Window), err
+		if failOpen {
+			log.Warn().Err(err).Str("key", key).Msg("Rate limiter unavailable, allowing request")
+			next.ServeHTTP(w, r)
+			return
+		}
+		log.Error().Err(err).Str("key", key).Msg("Rate limiter unavailable, rejecting request")
+		utils.WriteErrorJSON(w, http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE",
+			"Layanan sedang sibuk. Silakan coba lagi nanti.", "")
+		return
 	}
 
-	// Set expiry on first request
-	if count == 1 {
-		rl.redis.Client.Expire(ctx, key, cfg.Window)
+	// Set rate limit headers
+	w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", limit))
+	w.Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%d", remaining))
+	w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", resetAt.Unix()))
+
+	if !allowed {
+		retryAfter := int(time.Until(resetAt).Seconds()) + 1
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		utils.WriteErrorJSON(w, http.StatusTooManyRequests, "RATE_LIMIT_EXCEEDED",
+			"Terlalu banyak permintaan. Silakan coba lagi nanti.",
+			fmt.Sprintf("Limit: %d requests per %s", limit, window))
+		return
 	}
 
-	// Get TTL for reset time
-	ttl, err := rl.redis.Client.TTL(ctx, key).Result()
+	next.ServeHTTP(w, r)
+}
+
+// check records a request in the sliding window and reports whether it is allowed
+func (rl *RateLimiter) check(ctx context.Context, key string, limit int, window time.Duration) (bool, int, time.Time, error) {
+	now := time.Now().UnixMilli()
+	member := fmt.Sprintf("%d-%d", now, rand.Int63())
+
+	result, err := slidingWindowScript.Run(ctx, rl.redis.Client, []string{key},
+		now, window.Milliseconds(), limit, member).Int64Slice()
 	if err != nil {
-		ttl = cfg.Window
+		return false, 0, time.Time{}, err
+	}
+	if len(result) != 3 {
+		return false, 0, time.Time{}, fmt.Errorf("unexpected rate limit result: %v", result)
 	}
-	resetAt := time.Now().Add(ttl)
 
-	remaining := cfg.Requests - int(count)
+	remaining := limit - int(result[1])
 	if remaining < 0 {
 		remaining = 0
 	}
+	return result[0] == 1, remaining, time.UnixMilli(result[2]), nil
+}
+
+func (rl *RateLimiter) applyTier(limit int, tier string) int {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
 
-	return count <= int64(cfg.Requests), remaining, resetAt, nil
+	switch tier {
+	case RateLimitTierRoyal:
+		return int(float64(limit) * rl.royalMultiplier)
+	case RateLimitTierPartner:
+		return int(float64(limit) * rl.partnerMultiplier)
+	}
+	return limit
 }
 
-// IPRateLimit rate limits by IP only
-func (rl *RateLimiter) IPRateLimit(cfg RateLimitConfig) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			identifier := getClientIP(r)
-			key := rl.redis.RateLimitKey(identifier, r.URL.Path)
+// tier returns the tier of a user, cached for a few minutes
+func (rl *RateLimiter) tier(ctx context.Context, userID string) string {
+	if tier, ok := ctx.Value(RateLimitTierContextKey).(string); ok {
+		return tier
+	}
+	if rl.tierResolver == nil {
+		return RateLimitTierDefault
+	}
 
-			allowed, remaining, resetAt, err := rl.checkAndIncrement(r.Context(), key, cfg)
-			if err != nil {
-				next.ServeHTTP(w, r)
-				return
-			}
+	rl.tiersMu.Lock()
+	cached, ok := rl.tiers[userID]
+	rl.tiersMu.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.tier
+	}
 
-			w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", cfg.Requests))
-			w.Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%d", remaining))
-			w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", resetAt.Unix()))
+	tier, err := rl.tierResolver(ctx, userID)
+	if err != nil {
+		return RateLimitTierDefault
+	}
 
-			if !allowed {
-				utils.WriteErrorJSON(w, http.StatusTooManyRequests, "RATE_LIMIT_EXCEEDED",
-					"Terlalu banyak permintaan. Silakan coba lagi nanti.",
-					fmt.Sprintf("Limit: %d requests per %s", cfg.Requests, cfg.Window))
-				return
+	rl.tiersMu.Lock()
+	if len(rl.tiers) > 50000 {
+		rl.tiers = make(map[string]cachedTier)
+	}
+	rl.tiers[userID] = cachedTier{tier: tier, expiresAt: time.Now().Add(5 * time.Minute)}
+	rl.tiersMu.Unlock()
+
+	return tier
+}
+
+// WithRateLimitTier marks a request as belonging to a tier, e.g. for API partners
+func WithRateLimitTier(ctx context.Context, tier string) context.Context {
+	return context.WithValue(ctx, RateLimitTierContextKey, tier)
+}
+
+// WithRateLimitSubject limits a request per subject instead of per IP, e.g. per API key
+func WithRateLimitSubject(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, RateLimitSubjectContextKey, subject)
+}
+
+func parseAllowlist(entries []string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if strings.Contains(entry, ":") {
+				entry += "/128"
+			} else {
+				entry += "/32"
 			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Warn().Str("entry", entry).Msg("Invalid IP allowlist entry")
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
 
-			next.ServeHTTP(w, r)
-		})
+func ipAllowed(networks []*net.IPNet, ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(parsed) {
+			return true
+		}
 	}
+	return false
 }
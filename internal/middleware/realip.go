@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const ClientIPContextKey contextKey = "client_ip"
+
+// RealIP resolves the IP of the client once per request for ClientIP. The socket peer
+// is the client unless it is one of the trusted proxies (IPs or CIDRs), only then are
+// X-Forwarded-For and X-Real-IP honoured. Unlike chi's RealIP it leaves RemoteAddr alone.
+func RealIP(trustedProxies []string) func(http.Handler) http.Handler {
+	trusted := parseAllowlist(trustedProxies)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := resolveClientIP(r, trusted)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ClientIPContextKey, ip)))
+		})
+	}
+}
+
+// ClientIP returns the IP of the client resolved by RealIP, or the socket peer when
+// RealIP did not run
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(ClientIPContextKey).(string); ok && ip != "" {
+		return ip
+	}
+	return peerIP(r)
+}
+
+// resolveClientIP walks X-Forwarded-For from the nearest hop back and returns the
+// first address that is not a trusted proxy
+func resolveClientIP(r *http.Request, trusted []*net.IPNet) string {
+	peer := peerIP(r)
+	if !ipAllowed(trusted, peer) {
+		return peer
+	}
+
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		hops := strings.Split(xff, ",")
+		client := peer
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if net.ParseIP(hop) == nil {
+				break
+			}
+			client = hop
+			if !ipAllowed(trusted, hop) {
+				break
+			}
+		}
+		return client
+	}
+
+	if xri := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(xri) != nil {
+		return xri
+	}
+	return peer
+}
+
+// peerIP returns the IP of the socket peer without port
+func peerIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
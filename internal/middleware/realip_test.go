@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRealIP(t *testing.T) {
+	trusted := []string{"10.0.0.0/8", "127.0.0.1"}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{
+			name:       "direct client",
+			remoteAddr: "203.0.113.7:51234",
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "spoofed X-Real-IP from an untrusted peer",
+			remoteAddr: "203.0.113.7:51234",
+			headers:    map[string]string{"X-Real-IP": "198.51.100.1"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "spoofed X-Forwarded-For from an untrusted peer",
+			remoteAddr: "203.0.113.7:51234",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "X-Real-IP from a trusted proxy",
+			remoteAddr: "10.0.0.2:443",
+			headers:    map[string]string{"X-Real-IP": "198.51.100.1"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "client prepends a fake hop",
+			remoteAddr: "10.0.0.2:443",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1, 203.0.113.7"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "chain of trusted proxies",
+			remoteAddr: "127.0.0.1:443",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.7, 10.1.2.3"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "garbage hop stops the walk",
+			remoteAddr: "10.0.0.2:443",
+			headers:    map[string]string{"X-Forwarded-For": "not-an-ip"},
+			want:       "10.0.0.2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := RealIP(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = ClientIP(r)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Fatalf("ClientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookGuardIgnoresSpoofedHeaders(t *testing.T) {
+	rl := NewRateLimiter(nil, RateLimiterOptions{WebhookAllowlist: []string{"198.51.100.1"}})
+	rl.enforceAllowlist = true
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		realIP     string
+		want       int
+	}{
+		{"allowlisted gateway", "198.51.100.1:4000", "", http.StatusOK},
+		{"spoofed gateway IP", "203.0.113.7:4000", "198.51.100.1", http.StatusForbidden},
+		{"gateway behind the trusted proxy", "10.0.0.2:443", "198.51.100.1", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := RealIP([]string{"10.0.0.2"})(rl.WebhookGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})))
+
+			req := httptest.NewRequest(http.MethodPost, "/webhooks/digiflazz", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
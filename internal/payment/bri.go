@@ -49,9 +49,6 @@ func NewBRIGateway(clientID, clientSecret, partnerID, privateKeyPath, baseURL, c
 	}
 
 	log.Info().
-		Str("client_id", clientID).
-		Str("partner_id", partnerID).
-		Str("private_key_path", privateKeyPath).
 		Str("base_url", baseURL).
 		Msg("[BRI] Initializing BRI gateway")
 
@@ -81,6 +78,30 @@ func NewBRIGateway(clientID, clientSecret, partnerID, privateKeyPath, baseURL, c
 	return gateway, nil
 }
 
+// NewBRIGatewayFromKey creates a BRI SNAP gateway from PEM private key content
+func NewBRIGatewayFromKey(clientID, clientSecret, partnerID, privateKeyPEM, baseURL, callbackURL string) (*BRIGateway, error) {
+	if baseURL == "" {
+		baseURL = "https://sandbox.partner.api.bri.co.id"
+	}
+
+	privateKey, err := parsePrivateKey([]byte(privateKeyPEM))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load BRI private key: %w", err)
+	}
+
+	return &BRIGateway{
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		partnerID:    partnerID,
+		baseURL:      strings.TrimSuffix(baseURL, "/"),
+		callbackURL:  callbackURL,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		privateKey: privateKey,
+	}, nil
+}
+
 // loadPrivateKey loads RSA private key from file
 func (b *BRIGateway) loadPrivateKey() error {
 	log.Debug().
@@ -112,7 +133,6 @@ func (b *BRIGateway) loadPrivateKey() error {
 	if block == nil {
 		log.Error().
 			Str("path", b.privateKeyPath).
-			Str("content_preview", string(keyData[:min(100, len(keyData))])).
 			Msg("[BRI] Failed to decode PEM block - file may not be in PEM format")
 		return fmt.Errorf("failed to decode PEM block from %s - ensure file is in PEM format", b.privateKeyPath)
 	}
@@ -155,14 +175,6 @@ func (b *BRIGateway) loadPrivateKey() error {
 	return nil
 }
 
-// min returns the minimum of two integers
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
-}
-
 // GetName returns the gateway name
 func (b *BRIGateway) GetName() string {
 	return "BRI_DIRECT"
@@ -308,10 +320,8 @@ func (b *BRIGateway) getAccessToken(ctx context.Context) (string, error) {
 
 	respBody, _ := io.ReadAll(resp.Body)
 
-	// Log full response
 	log.Info().
 		Int("status_code", resp.StatusCode).
-		Str("response_body", string(respBody)).
 		Msg("[BRI] Token Response")
 
 	var tokenResp struct {
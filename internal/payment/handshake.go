@@ -0,0 +1,87 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Authenticator is implemented by gateways that can verify their credentials
+// with an authentication handshake, such as requesting a fresh access token
+type Authenticator interface {
+	Authenticate(ctx context.Context) error
+}
+
+// Authenticate requests a new OAuth2 access token
+func (b *BCAGateway) Authenticate(ctx context.Context) error {
+	b.accessToken = ""
+	token, err := b.getAccessToken(ctx)
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		return fmt.Errorf("bca returned no access token")
+	}
+	return nil
+}
+
+// Authenticate requests a new SNAP B2B access token
+func (b *BRIGateway) Authenticate(ctx context.Context) error {
+	b.tokenMutex.Lock()
+	b.accessToken = ""
+	b.tokenMutex.Unlock()
+
+	_, err := b.getAccessToken(ctx)
+	return err
+}
+
+// Authenticate requests a new SNAP B2B access token
+func (g *PakaiLinkGateway) Authenticate(ctx context.Context) error {
+	g.tokenMutex.Lock()
+	g.accessToken = ""
+	g.tokenMutex.Unlock()
+
+	_, err := g.getAccessToken(ctx)
+	return err
+}
+
+// Authenticate reads the account balance, which requires a valid secret key
+func (x *XenditGateway) Authenticate(ctx context.Context) error {
+	return x.HealthCheck(ctx)
+}
+
+// Authenticate queries the status of an unknown order. Midtrans answers with
+// 401 for an invalid server key and 404 when the key is accepted.
+func (g *MidtransGateway) Authenticate(ctx context.Context) error {
+	orderID := fmt.Sprintf("handshake-%d", time.Now().UnixNano())
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", g.config.BaseURL+"/v2/"+orderID+"/status", nil)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	httpReq.Header.Set("Authorization", g.getAuthHeader())
+
+	resp, err := g.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	var result struct {
+		StatusCode    string `json:"status_code"`
+		StatusMessage string `json:"status_message"`
+	}
+	_ = json.Unmarshal(body, &result)
+
+	if resp.StatusCode == http.StatusUnauthorized || result.StatusCode == "401" {
+		return fmt.Errorf("midtrans rejected the server key: %s", result.StatusMessage)
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("midtrans returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -51,6 +51,28 @@ func (m *Manager) Register(gateway Gateway) {
 	m.channelMu.Unlock()
 }
 
+// Unregister removes a gateway along with its channel mappings
+func (m *Manager) Unregister(name string) bool {
+	m.mu.Lock()
+	_, ok := m.gateways[name]
+	delete(m.gateways, name)
+	m.mu.Unlock()
+
+	m.channelMu.Lock()
+	for channel, gatewayName := range m.channelGateway {
+		if gatewayName == name {
+			delete(m.channelGateway, channel)
+		}
+	}
+	m.channelMu.Unlock()
+
+	m.healthMu.Lock()
+	delete(m.healthStatus, name)
+	m.healthMu.Unlock()
+
+	return ok
+}
+
 // Get returns a gateway by name
 func (m *Manager) Get(name string) (Gateway, error) {
 	m.mu.RLock()
@@ -29,6 +29,7 @@ type PakaiLinkGatewayConfig struct {
 	ClientKey      string
 	ClientSecret   string
 	PartnerID      string
+	PrivateKey     string // PEM content, used instead of PrivateKeyPath when set
 	PrivateKeyPath string
 	BaseURL        string
 	CallbackURL    string
@@ -83,7 +84,13 @@ func NewPakaiLinkGateway(cfg PakaiLinkGatewayConfig) (*PakaiLinkGateway, error)
 	}
 
 	// Load private key
-	privateKey, err := loadPrivateKey(cfg.PrivateKeyPath)
+	var privateKey *rsa.PrivateKey
+	var err error
+	if cfg.PrivateKey != "" {
+		privateKey, err = parsePrivateKey([]byte(cfg.PrivateKey))
+	} else {
+		privateKey, err = loadPrivateKey(cfg.PrivateKeyPath)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to load private key: %w", err)
 	}
@@ -104,6 +111,11 @@ func loadPrivateKey(path string) (*rsa.PrivateKey, error) {
 		return nil, fmt.Errorf("failed to read private key file: %w", err)
 	}
 
+	return parsePrivateKey(keyData)
+}
+
+// parsePrivateKey parses a PEM encoded RSA private key
+func parsePrivateKey(keyData []byte) (*rsa.PrivateKey, error) {
 	block, _ := pem.Decode(keyData)
 	if block == nil {
 		return nil, fmt.Errorf("failed to decode PEM block")
@@ -137,6 +149,11 @@ func (g *PakaiLinkGateway) GetName() string {
 	return "PAKAILINK"
 }
 
+// CallbackURL returns the callback URL the gateway was configured with
+func (g *PakaiLinkGateway) CallbackURL() string {
+	return g.config.CallbackURL
+}
+
 // GetSupportedChannels returns supported payment channels
 func (g *PakaiLinkGateway) GetSupportedChannels() []string {
 	channels := make([]string, 0, len(pakaiLinkBankCodes))
@@ -0,0 +1,243 @@
+package payment
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// InstanceConfig describes a gateway instance configured in the database
+type InstanceConfig struct {
+	Credentials map[string]string
+	BaseURL     string // overrides the sandbox or production URL of the type when set
+	CallbackURL string
+	Sandbox     bool
+	Timeout     time.Duration
+}
+
+// GatewayFactory builds a gateway instance from its configuration
+type GatewayFactory func(cfg InstanceConfig) (Gateway, error)
+
+// GatewayTypeInfo describes a gateway implementation, keyed by gateway code
+type GatewayTypeInfo struct {
+	Code              string   `json:"code"`
+	Name              string   `json:"name"`
+	SandboxBaseURL    string   `json:"sandboxBaseUrl"`
+	ProductionBaseURL string   `json:"productionBaseUrl"`
+	CredentialFields  []string `json:"credentialFields"`
+	OptionalFields    []string `json:"optionalFields"`
+}
+
+type gatewayType struct {
+	info    GatewayTypeInfo
+	factory GatewayFactory
+}
+
+var (
+	gatewayTypes   = make(map[string]gatewayType)
+	gatewayTypesMu sync.RWMutex
+)
+
+// RegisterGatewayType makes a gateway implementation available to BuildGateway
+func RegisterGatewayType(info GatewayTypeInfo, factory GatewayFactory) {
+	gatewayTypesMu.Lock()
+	defer gatewayTypesMu.Unlock()
+	gatewayTypes[strings.ToUpper(info.Code)] = gatewayType{info: info, factory: factory}
+}
+
+// GetGatewayType returns a registered gateway type
+func GetGatewayType(code string) (GatewayTypeInfo, bool) {
+	gatewayTypesMu.RLock()
+	defer gatewayTypesMu.RUnlock()
+	t, ok := gatewayTypes[strings.ToUpper(code)]
+	return t.info, ok
+}
+
+// GatewayTypes returns every registered gateway type
+func GatewayTypes() []GatewayTypeInfo {
+	gatewayTypesMu.RLock()
+	defer gatewayTypesMu.RUnlock()
+
+	types := make([]GatewayTypeInfo, 0, len(gatewayTypes))
+	for _, t := range gatewayTypes {
+		types = append(types, t.info)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Code < types[j].Code })
+	return types
+}
+
+// MissingGatewayCredentials returns the required credential fields of a gateway that are empty
+func MissingGatewayCredentials(code string, credentials map[string]string) []string {
+	info, ok := GetGatewayType(code)
+	if !ok {
+		return nil
+	}
+	var missing []string
+	for _, field := range info.CredentialFields {
+		if strings.TrimSpace(credentials[field]) == "" {
+			missing = append(missing, field)
+		}
+	}
+	return missing
+}
+
+// BuildGateway creates a gateway instance of a registered type
+func BuildGateway(code string, cfg InstanceConfig) (Gateway, error) {
+	gatewayTypesMu.RLock()
+	t, ok := gatewayTypes[strings.ToUpper(code)]
+	gatewayTypesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown gateway type: %s", code)
+	}
+	if missing := MissingGatewayCredentials(code, cfg.Credentials); len(missing) > 0 {
+		return nil, fmt.Errorf("missing credentials: %s", strings.Join(missing, ", "))
+	}
+
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = t.info.ProductionBaseURL
+		if cfg.Sandbox {
+			cfg.BaseURL = t.info.SandboxBaseURL
+		}
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	return t.factory(cfg)
+}
+
+func init() {
+	RegisterGatewayType(GatewayTypeInfo{
+		Code:              "LINKQU",
+		Name:              "LinkQu",
+		SandboxBaseURL:    "https://sandbox-api.linkqu.id",
+		ProductionBaseURL: "https://api.linkqu.id",
+		CredentialFields:  []string{"clientId", "clientSecret", "username", "pin"},
+	}, func(cfg InstanceConfig) (Gateway, error) {
+		c := cfg.Credentials
+		g := NewLinkQuGateway(c["clientId"], c["clientSecret"], c["username"], c["pin"], !cfg.Sandbox)
+		g.baseURL = cfg.BaseURL
+		g.client.Timeout = cfg.Timeout
+		return g, nil
+	})
+
+	RegisterGatewayType(GatewayTypeInfo{
+		Code:              "BCA_DIRECT",
+		Name:              "BCA Direct API",
+		SandboxBaseURL:    "https://sandbox.bca.co.id",
+		ProductionBaseURL: "https://api.bca.co.id",
+		CredentialFields:  []string{"clientId", "clientSecret", "apiKey", "apiSecret", "corporateId"},
+	}, func(cfg InstanceConfig) (Gateway, error) {
+		c := cfg.Credentials
+		g := NewBCAGateway(c["clientId"], c["clientSecret"], c["apiKey"], c["apiSecret"], c["corporateId"], !cfg.Sandbox)
+		g.baseURL = cfg.BaseURL
+		g.client.Timeout = cfg.Timeout
+		return g, nil
+	})
+
+	RegisterGatewayType(GatewayTypeInfo{
+		Code:              "BRI_DIRECT",
+		Name:              "BRI Direct API",
+		SandboxBaseURL:    "https://sandbox.partner.api.bri.co.id",
+		ProductionBaseURL: "https://partner.api.bri.co.id",
+		CredentialFields:  []string{"clientId", "clientSecret", "partnerId", "privateKey"},
+	}, func(cfg InstanceConfig) (Gateway, error) {
+		c := cfg.Credentials
+		g, err := NewBRIGatewayFromKey(c["clientId"], c["clientSecret"], c["partnerId"], c["privateKey"], cfg.BaseURL, cfg.CallbackURL)
+		if err != nil {
+			return nil, err
+		}
+		g.client.Timeout = cfg.Timeout
+		return g, nil
+	})
+
+	RegisterGatewayType(GatewayTypeInfo{
+		Code:              "XENDIT",
+		Name:              "Xendit",
+		SandboxBaseURL:    "https://api.xendit.co",
+		ProductionBaseURL: "https://api.xendit.co",
+		CredentialFields:  []string{"secretKey"},
+		OptionalFields:    []string{"callbackToken"},
+	}, func(cfg InstanceConfig) (Gateway, error) {
+		c := cfg.Credentials
+		g := NewXenditGateway(c["secretKey"], c["callbackToken"], !cfg.Sandbox)
+		g.baseURL = cfg.BaseURL
+		g.client.Timeout = cfg.Timeout
+		return g, nil
+	})
+
+	RegisterGatewayType(GatewayTypeInfo{
+		Code:              "MIDTRANS",
+		Name:              "Midtrans",
+		SandboxBaseURL:    "https://api.sandbox.midtrans.com",
+		ProductionBaseURL: "https://api.midtrans.com",
+		CredentialFields:  []string{"serverKey"},
+		OptionalFields:    []string{"clientKey"},
+	}, func(cfg InstanceConfig) (Gateway, error) {
+		c := cfg.Credentials
+		g, err := NewMidtransGateway(MidtransGatewayConfig{
+			ServerKey:    c["serverKey"],
+			ClientKey:    c["clientKey"],
+			IsProduction: !cfg.Sandbox,
+			BaseURL:      cfg.BaseURL,
+			CallbackURL:  cfg.CallbackURL,
+		})
+		if err != nil {
+			return nil, err
+		}
+		g.httpClient.Timeout = cfg.Timeout
+		return g, nil
+	})
+
+	RegisterGatewayType(GatewayTypeInfo{
+		Code:             "DANA_DIRECT",
+		Name:             "DANA Direct",
+		CredentialFields: []string{"partnerId", "clientSecret", "merchantId", "privateKey"},
+		OptionalFields:   []string{"shopId", "channelId", "origin", "returnUrl", "defaultMcc"},
+	}, func(cfg InstanceConfig) (Gateway, error) {
+		c := cfg.Credentials
+		environment := "PRODUCTION"
+		if cfg.Sandbox {
+			environment = "SANDBOX"
+		}
+		return NewDANAGateway(DANAGatewayConfig{
+			PartnerID:    c["partnerId"],
+			ClientSecret: c["clientSecret"],
+			MerchantID:   c["merchantId"],
+			ShopId:       c["shopId"],
+			ChannelID:    c["channelId"],
+			Origin:       c["origin"],
+			Environment:  environment,
+			PrivateKey:   c["privateKey"],
+			CallbackURL:  cfg.CallbackURL,
+			ReturnURL:    c["returnUrl"],
+			DefaultMCC:   c["defaultMcc"],
+		})
+	})
+
+	RegisterGatewayType(GatewayTypeInfo{
+		Code:              "PAKAILINK",
+		Name:              "PakaiLink",
+		SandboxBaseURL:    "https://sandbox.pakailink.id",
+		ProductionBaseURL: "https://api.pakailink.id",
+		CredentialFields:  []string{"clientKey", "clientSecret", "partnerId", "privateKey"},
+	}, func(cfg InstanceConfig) (Gateway, error) {
+		c := cfg.Credentials
+		g, err := NewPakaiLinkGateway(PakaiLinkGatewayConfig{
+			ClientKey:    c["clientKey"],
+			ClientSecret: c["clientSecret"],
+			PartnerID:    c["partnerId"],
+			PrivateKey:   c["privateKey"],
+			BaseURL:      cfg.BaseURL,
+			CallbackURL:  cfg.CallbackURL,
+			IsProduction: !cfg.Sandbox,
+		})
+		if err != nil {
+			return nil, err
+		}
+		g.httpClient.Timeout = cfg.Timeout
+		return g, nil
+	})
+}
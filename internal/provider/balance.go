@@ -0,0 +1,50 @@
+package provider
+
+import (
+	"time"
+)
+
+// balanceMaxAge is how long a recorded balance is trusted for routing
+const balanceMaxAge = 2 * time.Hour
+
+// SetBalance records the latest known balance of a provider
+func (m *Manager) SetBalance(name string, balance Balance) {
+	m.balanceMu.Lock()
+	defer m.balanceMu.Unlock()
+	m.balances[name] = balance
+}
+
+// GetKnownBalance returns the last recorded balance of a provider
+func (m *Manager) GetKnownBalance(name string) (Balance, bool) {
+	m.balanceMu.RLock()
+	defer m.balanceMu.RUnlock()
+	balance, ok := m.balances[name]
+	return balance, ok
+}
+
+// debitBalance lowers the known balance after an accepted order so routing
+// doesn't wait for the next snapshot to notice an exhausted provider
+func (m *Manager) debitBalance(name string, amount float64) {
+	if amount <= 0 {
+		return
+	}
+	m.balanceMu.Lock()
+	defer m.balanceMu.Unlock()
+	if balance, ok := m.balances[name]; ok {
+		balance.Balance -= amount
+		m.balances[name] = balance
+	}
+}
+
+// CanCover reports whether a provider's known balance covers an order amount.
+// Unknown or stale balances are assumed to be sufficient.
+func (m *Manager) CanCover(name string, amount float64) bool {
+	if amount <= 0 {
+		return true
+	}
+	balance, ok := m.GetKnownBalance(name)
+	if !ok || time.Since(balance.UpdatedAt) > balanceMaxAge {
+		return true
+	}
+	return balance.Balance >= amount
+}
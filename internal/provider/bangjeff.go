@@ -15,6 +15,7 @@ import (
 
 // BangJeffProvider implements the Provider interface for BangJeff
 type BangJeffProvider struct {
+	name         string
 	memberID     string
 	secretKey    string
 	webhookToken string
@@ -25,19 +26,18 @@ type BangJeffProvider struct {
 // NewBangJeffProvider creates a new BangJeff provider instance
 func NewBangJeffProvider(memberID, secretKey, webhookToken string) *BangJeffProvider {
 	return &BangJeffProvider{
+		name:         "bangjeff",
 		memberID:     memberID,
 		secretKey:    secretKey,
 		webhookToken: webhookToken,
 		baseURL:      "https://api.bangjeff.id/v1",
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		client:       newHTTPClient(30 * time.Second),
 	}
 }
 
 // GetName returns the provider name
 func (b *BangJeffProvider) GetName() string {
-	return "bangjeff"
+	return b.name
 }
 
 // generateSign generates HMAC-SHA256 signature for BangJeff API
@@ -242,6 +242,9 @@ func (b *BangJeffProvider) CreateOrder(ctx context.Context, req *OrderRequest) (
 		"product_id":  req.SKU,
 		"customer_no": req.CustomerNo,
 	}
+	if len(req.CustomerData) > 0 {
+		bjReq["customer_data"] = req.CustomerData
+	}
 
 	body, err := json.Marshal(bjReq)
 	if err != nil {
@@ -0,0 +1,276 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+// Circuit breaker states
+const (
+	CircuitClosed   = "CLOSED"
+	CircuitOpen     = "OPEN"
+	CircuitHalfOpen = "HALF_OPEN"
+)
+
+// ErrCircuitOpen is returned when a provider is skipped because its circuit is open
+var ErrCircuitOpen = errors.New("provider circuit is open")
+
+// BreakerConfig controls when a provider circuit opens and how it recovers
+type BreakerConfig struct {
+	Window           time.Duration // outcomes older than this are forgotten
+	MaxSamples       int           // outcomes kept per circuit
+	MinRequests      int           // outcomes needed before the circuit may open
+	FailureThreshold float64       // failure rate that opens the circuit
+	OpenTimeout      time.Duration // time before a half-open probe is allowed
+}
+
+// DefaultBreakerConfig opens a circuit when 80% of at least 10 requests
+// in the last 10 minutes failed, and probes again after a minute
+var DefaultBreakerConfig = BreakerConfig{
+	Window:           10 * time.Minute,
+	MaxSamples:       200,
+	MinRequests:      10,
+	FailureThreshold: 0.8,
+	OpenTimeout:      time.Minute,
+}
+
+// CircuitStats is a snapshot of a provider or provider SKU circuit
+type CircuitStats struct {
+	State        string     `json:"state"`
+	Requests     int        `json:"requests"`
+	Failures     int        `json:"failures"`
+	SuccessRate  float64    `json:"successRate"`
+	P95LatencyMs int64      `json:"p95LatencyMs"`
+	OpenedAt     *time.Time `json:"openedAt,omitempty"`
+}
+
+type circuitSample struct {
+	at      time.Time
+	failed  bool
+	latency time.Duration
+}
+
+type circuit struct {
+	state    string
+	openedAt time.Time
+	probing  bool
+	samples  []circuitSample
+}
+
+// circuitKey identifies a provider circuit, or a provider SKU circuit when sku is set
+func circuitKey(name, sku string) string {
+	if sku == "" {
+		return name
+	}
+	return name + "|" + sku
+}
+
+// IsProviderFailure reports whether an order outcome counts against the provider.
+// Transport errors and timeouts count, errors caused by the customer input do not.
+func IsProviderFailure(err error, resp *OrderResponse) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if err != nil {
+		return !isOrderRejection(err.Error())
+	}
+	return IsRetryableOrderError(err, resp)
+}
+
+// SetBreakerConfig replaces the circuit breaker configuration
+func (m *Manager) SetBreakerConfig(cfg BreakerConfig) {
+	m.circuitMu.Lock()
+	defer m.circuitMu.Unlock()
+	m.breakerConfig = cfg
+}
+
+// RecordResult feeds an outcome into the provider circuit and, when sku is set,
+// the provider SKU circuit
+func (m *Manager) RecordResult(name, sku string, failed bool, latency time.Duration) {
+	m.circuitMu.Lock()
+	defer m.circuitMu.Unlock()
+
+	now := time.Now()
+	m.recordLocked(circuitKey(name, ""), failed, latency, now)
+	if sku != "" {
+		m.recordLocked(circuitKey(name, sku), failed, latency, now)
+	}
+}
+
+func (m *Manager) recordLocked(key string, failed bool, latency time.Duration, now time.Time) {
+	c, ok := m.circuits[key]
+	if !ok {
+		c = &circuit{state: CircuitClosed}
+		m.circuits[key] = c
+	}
+
+	// A half-open probe decides whether the circuit closes or opens again
+	if c.state == CircuitHalfOpen {
+		c.probing = false
+		if failed {
+			c.state = CircuitOpen
+			c.openedAt = now
+		} else {
+			c.state = CircuitClosed
+			c.samples = nil
+		}
+	}
+
+	c.samples = append(c.samples, circuitSample{at: now, failed: failed, latency: latency})
+	m.pruneLocked(c, now)
+
+	if c.state != CircuitClosed {
+		return
+	}
+	requests, failures := len(c.samples), 0
+	for _, s := range c.samples {
+		if s.failed {
+			failures++
+		}
+	}
+	if requests >= m.breakerConfig.MinRequests && float64(failures)/float64(requests) >= m.breakerConfig.FailureThreshold {
+		c.state = CircuitOpen
+		c.openedAt = now
+	}
+}
+
+func (m *Manager) pruneLocked(c *circuit, now time.Time) {
+	cutoff := now.Add(-m.breakerConfig.Window)
+	start := 0
+	for start < len(c.samples) && c.samples[start].at.Before(cutoff) {
+		start++
+	}
+	if over := len(c.samples) - start - m.breakerConfig.MaxSamples; over > 0 {
+		start += over
+	}
+	if start > 0 {
+		c.samples = append([]circuitSample(nil), c.samples[start:]...)
+	}
+}
+
+// AllowRequest reports whether a request may be sent to the provider SKU.
+// Once the open timeout has passed a single probe request is let through.
+func (m *Manager) AllowRequest(name, sku string) bool {
+	m.circuitMu.Lock()
+	defer m.circuitMu.Unlock()
+
+	now := time.Now()
+	keys := []string{circuitKey(name, "")}
+	if sku != "" {
+		keys = append(keys, circuitKey(name, sku))
+	}
+
+	for _, key := range keys {
+		c, ok := m.circuits[key]
+		if !ok {
+			continue
+		}
+		switch c.state {
+		case CircuitOpen:
+			if now.Sub(c.openedAt) < m.breakerConfig.OpenTimeout {
+				return false
+			}
+		case CircuitHalfOpen:
+			if c.probing {
+				return false
+			}
+		}
+	}
+
+	for _, key := range keys {
+		if c, ok := m.circuits[key]; ok && c.state != CircuitClosed {
+			c.state = CircuitHalfOpen
+			c.probing = true
+		}
+	}
+	return true
+}
+
+// CircuitState returns the state of the provider SKU circuit, or of the
+// provider circuit when it is worse
+func (m *Manager) CircuitState(name, sku string) string {
+	m.circuitMu.Lock()
+	defer m.circuitMu.Unlock()
+
+	state := CircuitClosed
+	keys := []string{circuitKey(name, "")}
+	if sku != "" {
+		keys = append(keys, circuitKey(name, sku))
+	}
+	for _, key := range keys {
+		c, ok := m.circuits[key]
+		if !ok {
+			continue
+		}
+		if c.state == CircuitOpen {
+			return CircuitOpen
+		}
+		if c.state == CircuitHalfOpen {
+			state = CircuitHalfOpen
+		}
+	}
+	return state
+}
+
+// GetCircuitStats returns the circuit of a provider
+func (m *Manager) GetCircuitStats(name string) CircuitStats {
+	m.circuitMu.Lock()
+	defer m.circuitMu.Unlock()
+	return m.statsLocked(m.circuits[circuitKey(name, "")])
+}
+
+// GetSKUCircuitStats returns the circuits of every provider SKU seen for a provider
+func (m *Manager) GetSKUCircuitStats(name string) map[string]CircuitStats {
+	m.circuitMu.Lock()
+	defer m.circuitMu.Unlock()
+
+	prefix := name + "|"
+	result := make(map[string]CircuitStats)
+	for key, c := range m.circuits {
+		if len(key) > len(prefix) && key[:len(prefix)] == prefix {
+			result[key[len(prefix):]] = m.statsLocked(c)
+		}
+	}
+	return result
+}
+
+func (m *Manager) statsLocked(c *circuit) CircuitStats {
+	if c == nil {
+		return CircuitStats{State: CircuitClosed, SuccessRate: 1}
+	}
+	m.pruneLocked(c, time.Now())
+
+	stats := CircuitStats{State: c.state, Requests: len(c.samples), SuccessRate: 1}
+	if c.state != CircuitClosed {
+		openedAt := c.openedAt
+		stats.OpenedAt = &openedAt
+	}
+	if len(c.samples) == 0 {
+		return stats
+	}
+
+	latencies := make([]time.Duration, 0, len(c.samples))
+	for _, s := range c.samples {
+		if s.failed {
+			stats.Failures++
+		}
+		latencies = append(latencies, s.latency)
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	stats.SuccessRate = float64(stats.Requests-stats.Failures) / float64(stats.Requests)
+	stats.P95LatencyMs = latencies[(len(latencies)*95+99)/100-1].Milliseconds()
+	return stats
+}
+
+// CheckOrderStatus checks an order with a provider and records the outcome
+// on the provider circuit
+func (m *Manager) CheckOrderStatus(ctx context.Context, p Provider, refID string) (*OrderStatus, error) {
+	start := time.Now()
+	status, err := p.CheckStatus(ctx, refID)
+	failed := err != nil && !errors.Is(err, context.Canceled)
+	m.RecordResult(p.GetName(), "", failed, time.Since(start))
+	return status, err
+}
@@ -0,0 +1,210 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Diagnostic error kinds
+const (
+	DiagnosticNetwork   = "NETWORK"
+	DiagnosticTimeout   = "TIMEOUT"
+	DiagnosticHTTP      = "HTTP_ERROR"
+	DiagnosticSignature = "SIGNATURE"
+	DiagnosticAPI       = "API_ERROR"
+	DiagnosticParse     = "PARSE_ERROR"
+)
+
+// maxDiagnosticBody limits how much of a response body is kept for diagnostics
+const maxDiagnosticBody = 2048
+
+// HTTPExchange is one HTTP call made by a provider while diagnostics were recorded
+type HTTPExchange struct {
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	StatusCode int    `json:"statusCode,omitempty"`
+	LatencyMs  int64  `json:"latencyMs"`
+	ErrorCode  string `json:"errorCode,omitempty"`
+	Message    string `json:"message,omitempty"`
+	Body       string `json:"body,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Diagnostics collects the HTTP calls providers make with a context from WithDiagnostics
+type Diagnostics struct {
+	mu        sync.Mutex
+	exchanges []HTTPExchange
+}
+
+type diagnosticsKey struct{}
+
+// WithDiagnostics returns a context whose provider HTTP calls are recorded
+func WithDiagnostics(ctx context.Context) (context.Context, *Diagnostics) {
+	d := &Diagnostics{}
+	return context.WithValue(ctx, diagnosticsKey{}, d), d
+}
+
+// Exchanges returns the HTTP calls recorded so far
+func (d *Diagnostics) Exchanges() []HTTPExchange {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]HTTPExchange(nil), d.exchanges...)
+}
+
+// Reset forgets the recorded calls
+func (d *Diagnostics) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.exchanges = nil
+}
+
+func (d *Diagnostics) add(e HTTPExchange) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.exchanges = append(d.exchanges, e)
+}
+
+// diagnosticTransport records requests made with a diagnostics context and
+// passes everything else straight through
+type diagnosticTransport struct {
+	base http.RoundTripper
+}
+
+// newHTTPClient creates the HTTP client used by providers
+func newHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &diagnosticTransport{base: http.DefaultTransport},
+	}
+}
+
+func (t *diagnosticTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	d, ok := req.Context().Value(diagnosticsKey{}).(*Diagnostics)
+	if !ok {
+		return t.base.RoundTrip(req)
+	}
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	exchange := HTTPExchange{
+		Method:    req.Method,
+		Path:      req.URL.Path,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		exchange.Error = err.Error()
+		d.add(exchange)
+		return nil, err
+	}
+
+	body, readErr := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	exchange.StatusCode = resp.StatusCode
+	exchange.ErrorCode, exchange.Message = providerErrorFields(body)
+	if len(body) > maxDiagnosticBody {
+		exchange.Body = string(body[:maxDiagnosticBody]) + "..."
+	} else {
+		exchange.Body = string(body)
+	}
+	if readErr != nil {
+		exchange.Error = readErr.Error()
+	}
+	d.add(exchange)
+	return resp, nil
+}
+
+// providerErrorFields extracts the error code and message providers put in their responses
+func providerErrorFields(body []byte) (string, string) {
+	var payload map[string]interface{}
+	if json.Unmarshal(body, &payload) != nil {
+		return "", ""
+	}
+	// Digiflazz nests its response in data
+	if data, ok := payload["data"].(map[string]interface{}); ok {
+		if _, hasRC := data["rc"]; hasRC {
+			payload = data
+		}
+	}
+
+	var code, message string
+	for _, key := range []string{"rc", "error_code", "code"} {
+		if v, ok := payload[key]; ok && v != nil {
+			code = strings.TrimSpace(fmt.Sprint(v))
+			break
+		}
+	}
+	for _, key := range []string{"message", "msg", "error"} {
+		if v, ok := payload[key].(string); ok && v != "" {
+			message = v
+			break
+		}
+	}
+	return code, message
+}
+
+// signatureMarkers are provider messages reporting a bad signature or credentials
+var signatureMarkers = []string{
+	"sign",
+	"unauthorized",
+	"unauthenticated",
+	"invalid key",
+	"invalid api",
+	"api key",
+	"ip not allowed",
+	"whitelist",
+}
+
+// digiflazzSignatureCodes are Digiflazz response codes for credential errors
+var digiflazzSignatureCodes = map[string]bool{"41": true, "45": true}
+
+// ClassifyError returns the diagnostic kind of a provider error using the HTTP
+// calls recorded while it happened
+func ClassifyError(err error, exchanges []HTTPExchange) string {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return DiagnosticTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return DiagnosticTimeout
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, e := range exchanges {
+		if e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden || digiflazzSignatureCodes[e.ErrorCode] {
+			return DiagnosticSignature
+		}
+		message += " " + strings.ToLower(e.Message)
+	}
+	for _, m := range signatureMarkers {
+		if strings.Contains(message, m) {
+			return DiagnosticSignature
+		}
+	}
+
+	for _, e := range exchanges {
+		if e.StatusCode >= 400 {
+			return DiagnosticHTTP
+		}
+	}
+	switch {
+	case strings.Contains(message, "failed to send request"):
+		return DiagnosticNetwork
+	case strings.Contains(message, "unmarshal"):
+		return DiagnosticParse
+	}
+	return DiagnosticAPI
+}
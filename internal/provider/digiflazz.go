@@ -18,6 +18,7 @@ import (
 
 // DigiflazzProvider implements the Provider interface for Digiflazz
 type DigiflazzProvider struct {
+	name          string
 	username      string
 	apiKey        string
 	webhookSecret string
@@ -34,20 +35,19 @@ func NewDigiflazzProvider(username, apiKey, webhookSecret string, isProduction b
 	}
 
 	return &DigiflazzProvider{
+		name:          "digiflazz",
 		username:      username,
 		apiKey:        apiKey,
 		webhookSecret: webhookSecret,
 		baseURL:       baseURL,
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-		isProduction: isProduction,
+		client:        newHTTPClient(30 * time.Second),
+		isProduction:  isProduction,
 	}
 }
 
 // GetName returns the provider name
 func (d *DigiflazzProvider) GetName() string {
-	return "digiflazz"
+	return d.name
 }
 
 // generateSign generates MD5 signature for Digiflazz API
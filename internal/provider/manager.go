@@ -15,6 +15,15 @@ type Manager struct {
 	// Provider health status
 	healthStatus map[string]HealthStatus
 	healthMu     sync.RWMutex
+
+	// Circuit breakers fed by order outcomes, keyed by provider and provider SKU
+	circuits      map[string]*circuit
+	breakerConfig BreakerConfig
+	circuitMu     sync.Mutex
+
+	// Last known provider balances, kept up to date by the balance monitor
+	balances  map[string]Balance
+	balanceMu sync.RWMutex
 }
 
 // HealthStatus represents the health status of a provider
@@ -27,8 +36,11 @@ type HealthStatus struct {
 // NewManager creates a new provider manager
 func NewManager() *Manager {
 	return &Manager{
-		providers:    make(map[string]Provider),
-		healthStatus: make(map[string]HealthStatus),
+		providers:     make(map[string]Provider),
+		healthStatus:  make(map[string]HealthStatus),
+		circuits:      make(map[string]*circuit),
+		breakerConfig: DefaultBreakerConfig,
+		balances:      make(map[string]Balance),
 	}
 }
 
@@ -39,6 +51,24 @@ func (m *Manager) Register(provider Provider) {
 	m.providers[provider.GetName()] = provider
 }
 
+// Unregister removes a provider and forgets its health status and balance
+func (m *Manager) Unregister(name string) bool {
+	m.mu.Lock()
+	_, ok := m.providers[name]
+	delete(m.providers, name)
+	m.mu.Unlock()
+
+	m.healthMu.Lock()
+	delete(m.healthStatus, name)
+	m.healthMu.Unlock()
+
+	m.balanceMu.Lock()
+	delete(m.balances, name)
+	m.balanceMu.Unlock()
+
+	return ok
+}
+
 // Get returns a provider by name
 func (m *Manager) Get(name string) (Provider, error) {
 	m.mu.RLock()
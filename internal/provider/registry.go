@@ -0,0 +1,152 @@
+package provider
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// InstanceConfig describes a provider instance configured in the database
+type InstanceConfig struct {
+	Name          string // name the instance is registered under, the lowercase provider code
+	BaseURL       string
+	Credentials   map[string]string
+	WebhookSecret string
+	Timeout       time.Duration
+	IsProduction  bool
+}
+
+// Factory builds a provider instance from its configuration
+type Factory func(cfg InstanceConfig) (Provider, error)
+
+// TypeInfo describes a provider implementation that instances can be built from
+type TypeInfo struct {
+	Type              string   `json:"type"`
+	Name              string   `json:"name"`
+	DefaultBaseURL    string   `json:"defaultBaseUrl"`
+	CredentialFields  []string `json:"credentialFields"`
+	UsesWebhookSecret bool     `json:"usesWebhookSecret"`
+}
+
+type providerType struct {
+	info    TypeInfo
+	factory Factory
+}
+
+var (
+	providerTypes   = make(map[string]providerType)
+	providerTypesMu sync.RWMutex
+)
+
+// RegisterType makes a provider implementation available to Build
+func RegisterType(info TypeInfo, factory Factory) {
+	providerTypesMu.Lock()
+	defer providerTypesMu.Unlock()
+	providerTypes[strings.ToLower(info.Type)] = providerType{info: info, factory: factory}
+}
+
+// GetType returns a registered provider type
+func GetType(typ string) (TypeInfo, bool) {
+	providerTypesMu.RLock()
+	defer providerTypesMu.RUnlock()
+	t, ok := providerTypes[strings.ToLower(typ)]
+	return t.info, ok
+}
+
+// Types returns every registered provider type
+func Types() []TypeInfo {
+	providerTypesMu.RLock()
+	defer providerTypesMu.RUnlock()
+
+	types := make([]TypeInfo, 0, len(providerTypes))
+	for _, t := range providerTypes {
+		types = append(types, t.info)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Type < types[j].Type })
+	return types
+}
+
+// MissingCredentials returns the credential fields of a type that are empty
+func MissingCredentials(typ string, credentials map[string]string) []string {
+	info, ok := GetType(typ)
+	if !ok {
+		return nil
+	}
+	var missing []string
+	for _, field := range info.CredentialFields {
+		if strings.TrimSpace(credentials[field]) == "" {
+			missing = append(missing, field)
+		}
+	}
+	return missing
+}
+
+// Build creates a provider instance of a registered type
+func Build(typ string, cfg InstanceConfig) (Provider, error) {
+	providerTypesMu.RLock()
+	t, ok := providerTypes[strings.ToLower(typ)]
+	providerTypesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown provider type: %s", typ)
+	}
+	if missing := MissingCredentials(typ, cfg.Credentials); len(missing) > 0 {
+		return nil, fmt.Errorf("missing credentials: %s", strings.Join(missing, ", "))
+	}
+
+	if cfg.Name == "" {
+		cfg.Name = t.info.Type
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = t.info.DefaultBaseURL
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	return t.factory(cfg)
+}
+
+func init() {
+	RegisterType(TypeInfo{
+		Type:              "digiflazz",
+		Name:              "Digiflazz",
+		DefaultBaseURL:    "https://api.digiflazz.com/v1",
+		CredentialFields:  []string{"username", "apiKey"},
+		UsesWebhookSecret: true,
+	}, func(cfg InstanceConfig) (Provider, error) {
+		p := NewDigiflazzProvider(cfg.Credentials["username"], cfg.Credentials["apiKey"], cfg.WebhookSecret, cfg.IsProduction)
+		p.name = cfg.Name
+		p.baseURL = cfg.BaseURL
+		p.client = newHTTPClient(cfg.Timeout)
+		return p, nil
+	})
+
+	RegisterType(TypeInfo{
+		Type:             "vipreseller",
+		Name:             "VIP Reseller",
+		DefaultBaseURL:   "https://vip-reseller.co.id/api",
+		CredentialFields: []string{"apiId", "apiKey"},
+	}, func(cfg InstanceConfig) (Provider, error) {
+		p := NewVIPResellerProvider(cfg.Credentials["apiId"], cfg.Credentials["apiKey"])
+		p.name = cfg.Name
+		p.baseURL = cfg.BaseURL
+		p.client = newHTTPClient(cfg.Timeout)
+		return p, nil
+	})
+
+	RegisterType(TypeInfo{
+		Type:              "bangjeff",
+		Name:              "BangJeff",
+		DefaultBaseURL:    "https://api.bangjeff.id/v1",
+		CredentialFields:  []string{"memberId", "secretKey"},
+		UsesWebhookSecret: true,
+	}, func(cfg InstanceConfig) (Provider, error) {
+		p := NewBangJeffProvider(cfg.Credentials["memberId"], cfg.Credentials["secretKey"], cfg.WebhookSecret)
+		p.name = cfg.Name
+		p.baseURL = cfg.BaseURL
+		p.client = newHTTPClient(cfg.Timeout)
+		return p, nil
+	})
+}
@@ -0,0 +1,246 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Routing strategies for SKUs mapped to several providers
+const (
+	RoutingPriority    = "PRIORITY"
+	RoutingCheapest    = "CHEAPEST"
+	RoutingSuccessRate = "SUCCESS_RATE"
+	RoutingWeighted    = "WEIGHTED"
+)
+
+// ValidRoutingStrategy reports whether s is a known routing strategy
+func ValidRoutingStrategy(s string) bool {
+	switch s {
+	case RoutingPriority, RoutingCheapest, RoutingSuccessRate, RoutingWeighted:
+		return true
+	}
+	return false
+}
+
+// Route is a provider and provider SKU able to fulfil an order
+type Route struct {
+	MappingID   string
+	ProviderID  string
+	Provider    Provider
+	SKU         string
+	Priority    int
+	Weight      int
+	Cost        float64 // last known provider cost, 0 when unknown
+	SuccessRate float64 // recent success rate between 0 and 1
+	Unavailable bool    // provider reported the SKU as unavailable
+	Amount      float64 // expected order cost the provider balance must cover
+}
+
+// RouteAttempt is the outcome of one CreateOrder call during failover
+type RouteAttempt struct {
+	Route    Route
+	Response *OrderResponse
+	Err      error
+	Latency  time.Duration
+}
+
+// nonRetryableMessages are provider errors caused by the order itself;
+// another provider would reject the same customer number
+var nonRetryableMessages = []string{
+	"customer",
+	"tujuan salah",
+	"nomor tidak valid",
+	"invalid number",
+	"invalid user",
+	"duplicate",
+	"ref_id",
+}
+
+// ErrNoRoute is returned when no route could accept an order
+var ErrNoRoute = errors.New("no provider route available")
+
+// IsRetryableOrderError reports whether an order may be retried on another route.
+// Only orders known not to have reached the provider (open circuit, refused or failed
+// connection) and orders the provider answered as FAILED for a reason of its own
+// (out of stock, maintenance, balance) are retryable. Any other error may come after
+// the provider accepted the order, so sending the ref ID elsewhere could fulfil it twice.
+func IsRetryableOrderError(err error, resp *OrderResponse) bool {
+	if err != nil {
+		return IsOrderNotSent(err)
+	}
+	return resp != nil && resp.Status == StatusFailed && !isOrderRejection(resp.Message)
+}
+
+// IsOrderNotSent reports whether err happened before the order reached the provider
+func IsOrderNotSent(err error) bool {
+	if errors.Is(err, ErrCircuitOpen) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// isOrderRejection reports whether a provider message blames the order itself
+func isOrderRejection(message string) bool {
+	message = strings.ToLower(message)
+	for _, m := range nonRetryableMessages {
+		if strings.Contains(message, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderRoutes sorts routes by strategy. Unhealthy providers and unavailable
+// SKUs are moved to the end but kept as a last resort.
+func (m *Manager) OrderRoutes(routes []Route, strategy string) []Route {
+	ordered := make([]Route, len(routes))
+	copy(ordered, routes)
+
+	less := func(a, b Route) bool { return a.Priority < b.Priority }
+	switch strategy {
+	case RoutingCheapest:
+		less = func(a, b Route) bool {
+			if a.Cost <= 0 || b.Cost <= 0 || a.Cost == b.Cost {
+				if (a.Cost > 0) != (b.Cost > 0) {
+					return a.Cost > 0
+				}
+				return a.Priority < b.Priority
+			}
+			return a.Cost < b.Cost
+		}
+	case RoutingSuccessRate:
+		less = func(a, b Route) bool {
+			if a.SuccessRate == b.SuccessRate {
+				return a.Priority < b.Priority
+			}
+			return a.SuccessRate > b.SuccessRate
+		}
+	}
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		di, dj := m.demoted(ordered[i]), m.demoted(ordered[j])
+		if di != dj {
+			return !di
+		}
+		return less(ordered[i], ordered[j])
+	})
+
+	if strategy == RoutingWeighted {
+		ordered = pickWeighted(ordered, m)
+	}
+	return ordered
+}
+
+// demoted reports whether a route should only be used as a last resort
+func (m *Manager) demoted(r Route) bool {
+	if r.Unavailable || m.CircuitState(r.Provider.GetName(), r.SKU) != CircuitClosed {
+		return true
+	}
+	amount := r.Amount
+	if r.Cost > 0 {
+		amount = r.Cost
+	}
+	if !m.CanCover(r.Provider.GetName(), amount) {
+		return true
+	}
+	status, err := m.GetHealthStatus(r.Provider.GetName())
+	return err == nil && status.Status == "UNHEALTHY"
+}
+
+// pickWeighted moves a weighted random choice among the healthy routes to the front
+func pickWeighted(routes []Route, m *Manager) []Route {
+	total := 0
+	for _, r := range routes {
+		if !m.demoted(r) && r.Weight > 0 {
+			total += r.Weight
+		}
+	}
+	if total == 0 {
+		return routes
+	}
+
+	n := rand.Intn(total)
+	for i, r := range routes {
+		if m.demoted(r) || r.Weight <= 0 {
+			continue
+		}
+		if n < r.Weight {
+			chosen := routes[i]
+			copy(routes[1:i+1], routes[:i])
+			routes[0] = chosen
+			return routes
+		}
+		n -= r.Weight
+	}
+	return routes
+}
+
+// CreateOrderWithFailover sends the order to each route in turn until one accepts it
+// or an error is not retryable. req.SKU is replaced with each route's SKU. Routes
+// whose circuit is open are skipped, and every outcome feeds the route's circuit.
+// When a route fails without a clear answer (timeout, unreadable response) the order
+// may have been accepted, so it is returned as PROCESSING for the provider callback
+// or a status check to settle instead of being sent to the next route.
+func (m *Manager) CreateOrderWithFailover(ctx context.Context, routes []Route, req *OrderRequest) (*OrderResponse, Route, []RouteAttempt, error) {
+	if len(routes) == 0 {
+		return nil, Route{}, nil, ErrNoRoute
+	}
+
+	attempts := make([]RouteAttempt, 0, len(routes))
+	for _, route := range routes {
+		name := route.Provider.GetName()
+		if !m.AllowRequest(name, route.SKU) {
+			attempts = append(attempts, RouteAttempt{Route: route, Err: ErrCircuitOpen})
+			continue
+		}
+
+		routeReq := *req
+		routeReq.SKU = route.SKU
+
+		start := time.Now()
+		resp, err := route.Provider.CreateOrder(ctx, &routeReq)
+		latency := time.Since(start)
+		attempts = append(attempts, RouteAttempt{Route: route, Response: resp, Err: err, Latency: latency})
+		m.RecordResult(name, route.SKU, IsProviderFailure(err, resp), latency)
+		if err == nil && resp != nil && resp.Status != "FAILED" {
+			m.debitBalance(name, resp.Price)
+		}
+
+		if IsRetryableOrderError(err, resp) {
+			continue
+		}
+		if err != nil && !isOrderRejection(err.Error()) {
+			return &OrderResponse{
+				RefID:         req.RefID,
+				ProviderRefID: req.RefID,
+				SKU:           route.SKU,
+				CustomerNo:    req.CustomerNo,
+				Status:        StatusProcessing,
+				Message:       "Outcome unknown, waiting for provider: " + err.Error(),
+				CreatedAt:     time.Now(),
+			}, route, attempts, nil
+		}
+		return resp, route, attempts, err
+	}
+
+	// Every route failed or was skipped, report the last one that was tried
+	for i := len(attempts) - 1; i >= 0; i-- {
+		if a := attempts[i]; !errors.Is(a.Err, ErrCircuitOpen) {
+			return a.Response, a.Route, attempts, a.Err
+		}
+	}
+	return nil, Route{}, attempts, fmt.Errorf("%w for %s", ErrCircuitOpen, req.RefID)
+}
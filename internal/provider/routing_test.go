@@ -0,0 +1,152 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// stubProvider answers every order with resp and counts the orders it received
+type stubProvider struct {
+	name   string
+	resp   *OrderResponse
+	orders int
+}
+
+func (p *stubProvider) GetName() string { return p.name }
+
+func (p *stubProvider) GetProducts(ctx context.Context) ([]Product, error) { return nil, nil }
+
+func (p *stubProvider) CheckPrice(ctx context.Context, sku string) (*PriceInfo, error) {
+	return &PriceInfo{SKU: sku, IsAvailable: true}, nil
+}
+
+func (p *stubProvider) CreateOrder(ctx context.Context, req *OrderRequest) (*OrderResponse, error) {
+	p.orders++
+	resp := *p.resp
+	resp.RefID = req.RefID
+	return &resp, nil
+}
+
+func (p *stubProvider) CheckStatus(ctx context.Context, refID string) (*OrderStatus, error) {
+	return nil, nil
+}
+
+func (p *stubProvider) GetBalance(ctx context.Context) (*Balance, error) { return nil, nil }
+
+func (p *stubProvider) HealthCheck(ctx context.Context) error { return nil }
+
+func newTestDigiflazz(baseURL string) *DigiflazzProvider {
+	d := NewDigiflazzProvider("user", "key", "", false)
+	d.baseURL = baseURL
+	d.client = newHTTPClient(5 * time.Second)
+	return d
+}
+
+// sendWithFailover sends an order to digiflazz first and backup second
+func sendWithFailover(t *testing.T, digiflazz Provider, backup *stubProvider) (*OrderResponse, Route, error) {
+	t.Helper()
+
+	routes := []Route{
+		{Provider: digiflazz, SKU: "ML86", Priority: 1},
+		{Provider: backup, SKU: "ML86-B", Priority: 2},
+	}
+	req := &OrderRequest{RefID: "INV-TEST-1", SKU: "ML86", CustomerNo: "12345678"}
+
+	resp, route, _, err := NewManager().CreateOrderWithFailover(context.Background(), routes, req)
+	return resp, route, err
+}
+
+func TestCreateOrderWithFailoverKeepsOrderWhenResponseUnreadable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Promise more body than is sent, then drop the connection
+		w.Header().Set("Content-Length", "1024")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"data":{"ref_id":"INV-TEST-1","status":"Pen`))
+		w.(http.Flusher).Flush()
+
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer server.Close()
+
+	backup := &stubProvider{name: "backup", resp: &OrderResponse{Status: StatusSuccess}}
+	resp, route, err := sendWithFailover(t, newTestDigiflazz(server.URL), backup)
+
+	if err != nil {
+		t.Fatalf("expected no error for an unknown outcome, got %v", err)
+	}
+	if backup.orders != 0 {
+		t.Fatalf("order was sent to the backup provider %d time(s) after a read failure", backup.orders)
+	}
+	if resp == nil || resp.Status != StatusProcessing {
+		t.Fatalf("expected a PROCESSING response, got %+v", resp)
+	}
+	if route.Provider.GetName() != "digiflazz" || resp.RefID != "INV-TEST-1" {
+		t.Fatalf("expected the order to stay with digiflazz under its ref ID, got %s %s", route.Provider.GetName(), resp.RefID)
+	}
+}
+
+func TestCreateOrderWithFailoverKeepsOrderWhenResponseMalformed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<html>502 Bad Gateway</html>"))
+	}))
+	defer server.Close()
+
+	backup := &stubProvider{name: "backup", resp: &OrderResponse{Status: StatusSuccess}}
+	resp, route, err := sendWithFailover(t, newTestDigiflazz(server.URL), backup)
+
+	if err != nil {
+		t.Fatalf("expected no error for an unknown outcome, got %v", err)
+	}
+	if backup.orders != 0 {
+		t.Fatalf("order was sent to the backup provider %d time(s) after an unmarshal failure", backup.orders)
+	}
+	if resp == nil || resp.Status != StatusProcessing {
+		t.Fatalf("expected a PROCESSING response, got %+v", resp)
+	}
+	if route.Provider.GetName() != "digiflazz" {
+		t.Fatalf("expected the order to stay with digiflazz, got %s", route.Provider.GetName())
+	}
+}
+
+func TestCreateOrderWithFailoverRetriesRefusedConnection(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	baseURL := server.URL
+	server.Close()
+
+	backup := &stubProvider{name: "backup", resp: &OrderResponse{Status: StatusSuccess}}
+	resp, route, err := sendWithFailover(t, newTestDigiflazz(baseURL), backup)
+
+	if err != nil {
+		t.Fatalf("expected the backup provider to accept the order, got %v", err)
+	}
+	if backup.orders != 1 || route.Provider.GetName() != "backup" || resp.Status != StatusSuccess {
+		t.Fatalf("expected one successful order on the backup provider, got %d order(s) on %s", backup.orders, route.Provider.GetName())
+	}
+}
+
+func TestCreateOrderWithFailoverRetriesFailedStatus(t *testing.T) {
+	first := &stubProvider{name: "first", resp: &OrderResponse{Status: StatusFailed, Message: "Stok kosong"}}
+	backup := &stubProvider{name: "backup", resp: &OrderResponse{Status: StatusSuccess}}
+	resp, route, err := sendWithFailover(t, first, backup)
+
+	if err != nil || resp.Status != StatusSuccess || route.Provider.GetName() != "backup" {
+		t.Fatalf("expected the backup provider to fulfil the order, got %+v on %s (%v)", resp, route.Provider.GetName(), err)
+	}
+}
+
+func TestCreateOrderWithFailoverStopsOnCustomerError(t *testing.T) {
+	first := &stubProvider{name: "first", resp: &OrderResponse{Status: StatusFailed, Message: "Nomor tidak valid"}}
+	backup := &stubProvider{name: "backup", resp: &OrderResponse{Status: StatusSuccess}}
+	resp, _, _ := sendWithFailover(t, first, backup)
+
+	if backup.orders != 0 || resp.Status != StatusFailed {
+		t.Fatalf("expected the order to fail without failover, got %+v and %d backup order(s)", resp, backup.orders)
+	}
+}
@@ -14,6 +14,7 @@ import (
 
 // VIPResellerProvider implements the Provider interface for VIP Reseller
 type VIPResellerProvider struct {
+	name    string
 	apiID   string
 	apiKey  string
 	baseURL string
@@ -23,18 +24,17 @@ type VIPResellerProvider struct {
 // NewVIPResellerProvider creates a new VIP Reseller provider instance
 func NewVIPResellerProvider(apiID, apiKey string) *VIPResellerProvider {
 	return &VIPResellerProvider{
+		name:    "vipreseller",
 		apiID:   apiID,
 		apiKey:  apiKey,
 		baseURL: "https://vip-reseller.co.id/api",
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		client:  newHTTPClient(30 * time.Second),
 	}
 }
 
 // GetName returns the provider name
 func (v *VIPResellerProvider) GetName() string {
-	return "vipreseller"
+	return v.name
 }
 
 // generateSign generates MD5 signature for VIP Reseller API
@@ -195,6 +195,12 @@ func (v *VIPResellerProvider) CreateOrder(ctx context.Context, req *OrderRequest
 		"service": req.SKU,
 		"data":    req.CustomerNo,
 	}
+	// Extra customer fields (e.g. zone or server) mapped by the product template
+	for key, value := range req.CustomerData {
+		if _, exists := vipReq[key]; !exists {
+			vipReq[key] = value
+		}
+	}
 
 	body, err := json.Marshal(vipReq)
 	if err != nil {
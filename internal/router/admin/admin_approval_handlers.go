@@ -0,0 +1,468 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"seaply/internal/domain"
+	"seaply/internal/middleware"
+	"seaply/internal/services"
+	"seaply/internal/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// ============================================
+// ADMIN APPROVALS (MAKER-CHECKER)
+// ============================================
+
+// adminAction is who an action that may be held for approval runs for: the admin who
+// requested it and, once held, the approver and their approval request
+type adminAction struct {
+	RequestedBy string
+	ApprovedBy  string
+	ApprovalID  string
+}
+
+// note describes the approval for a ledger entry, empty when the action wasn't held
+func (a adminAction) note() string {
+	if a.ApprovalID == "" {
+		return ""
+	}
+	return "Approved by admin " + a.ApprovedBy + " (approval " + a.ApprovalID + ")"
+}
+
+// logData is stored with the transaction or deposit log entry of the action
+func (a adminAction) logData() map[string]interface{} {
+	data := map[string]interface{}{"requestedBy": a.RequestedBy}
+	if a.ApprovalID != "" {
+		data["approvedBy"] = a.ApprovedBy
+		data["approvalId"] = a.ApprovalID
+	}
+	return data
+}
+
+// actionResult is the outcome of an action run in a transaction. afterCommit publishes
+// and notifies the change once the transaction has committed.
+type actionResult struct {
+	Data        map[string]interface{}
+	afterCommit func()
+}
+
+// actionError is an action failing for a reason the admin can fix, written as is
+type actionError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *actionError) Error() string {
+	return e.Message
+}
+
+// runAdminAction runs an action in a new transaction and commits it
+func runAdminAction(ctx context.Context, deps *Dependencies, action func(tx pgx.Tx) (*actionResult, error)) (*actionResult, error) {
+	var result *actionResult
+	err := pgx.BeginFunc(ctx, deps.DB.Pool, func(tx pgx.Tx) error {
+		var err error
+		result, err = action(tx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.afterCommit != nil {
+		result.afterCommit()
+	}
+	return result, nil
+}
+
+// writeActionError writes the response of a failed action. Nothing of the action was
+// committed, so an idempotent request may run again.
+func writeActionError(w http.ResponseWriter, r *http.Request, err error) {
+	var actionErr *actionError
+	if errors.As(err, &actionErr) {
+		utils.WriteErrorJSON(w, actionErr.Status, actionErr.Code, actionErr.Message, "")
+		return
+	}
+	log.Error().Err(err).Str("path", r.URL.Path).Msg("Admin action failed")
+	middleware.IdempotentRetryable(r.Context())
+	utils.WriteInternalServerError(w)
+}
+
+// approvalExecutor runs an approved action from its stored request in tx
+type approvalExecutor func(ctx context.Context, deps *Dependencies, tx pgx.Tx, r *http.Request, request *services.ApprovalRequest, by adminAction) (*actionResult, error)
+
+// approvalAction adapts an action to an approval executor, decoding the stored payload
+func approvalAction[T any](action func(ctx context.Context, deps *Dependencies, tx pgx.Tx, r *http.Request, resourceID string, req T, by adminAction) (*actionResult, error)) approvalExecutor {
+	return func(ctx context.Context, deps *Dependencies, tx pgx.Tx, r *http.Request, request *services.ApprovalRequest, by adminAction) (*actionResult, error) {
+		var req T
+		if err := json.Unmarshal(request.Payload, &req); err != nil {
+			return nil, fmt.Errorf("decode approval payload: %w", err)
+		}
+		return action(ctx, deps, tx, r, request.ResourceID, req, by)
+	}
+}
+
+// approvalExecutors are the actions that can be held for approval
+var approvalExecutors = map[string]approvalExecutor{
+	services.ApprovalAdjustBalance:     approvalAction(adjustBalance),
+	services.ApprovalRefundTransaction: approvalAction(refundTransaction),
+	services.ApprovalManualProcess:     approvalAction(manualProcessTransaction),
+	services.ApprovalConfirmDeposit:    approvalAction(confirmDeposit),
+	services.ApprovalRefundDeposit:     approvalAction(refundDeposit),
+}
+
+// holdForApproval holds the action for a second admin when its amount reaches the
+// configured threshold. It reports whether the response was written, either the
+// pending request or an error; the caller then stops without executing the action.
+// Call it before opening the transaction of the action.
+func holdForApproval(w http.ResponseWriter, r *http.Request, deps *Dependencies, approval services.NewApproval) bool {
+	if deps.Approvals == nil {
+		return false
+	}
+
+	ctx := r.Context()
+	settings, err := deps.Approvals.LoadSettings(ctx)
+	if err != nil {
+		// Thresholds can't be checked, hold the action rather than skip the second admin
+		log.Warn().Err(err).Str("action", approval.Action).Msg("Failed to load approval settings, holding the action")
+	} else if !settings.Requires(approval.Action, approval.Amount, approval.Currency) {
+		return false
+	}
+
+	approval.RequestedBy = middleware.GetAdminIDFromContext(ctx)
+	request, err := deps.Approvals.Create(ctx, settings, approval, func(tx pgx.Tx, id string) error {
+		return recordAudit(ctx, deps, tx, r, services.AuditEntry{
+			Action:      "REQUEST_APPROVAL",
+			Resource:    "APPROVAL",
+			ResourceID:  id,
+			Description: "Requested approval for " + approval.Summary,
+			After: map[string]interface{}{
+				"action":     approval.Action,
+				"resource":   approval.Resource,
+				"resourceId": approval.ResourceID,
+				"amount":     approval.Amount,
+				"currency":   approval.Currency,
+				"summary":    approval.Summary,
+				"payload":    approval.Payload,
+			},
+		})
+	})
+	if err != nil {
+		log.Error().Err(err).Str("action", approval.Action).Str("resource_id", approval.ResourceID).Msg("Failed to create approval request")
+		utils.WriteInternalServerError(w)
+		return true
+	}
+
+	utils.WriteJSON(w, http.StatusAccepted, domain.SuccessResponse{Data: map[string]interface{}{
+		"message":  "Action requires approval from another admin",
+		"approval": request,
+	}})
+	return true
+}
+
+// writeApprovalError writes the response for an error of the approval service
+func writeApprovalError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		utils.WriteNotFoundError(w, "Approval request")
+	case errors.Is(err, services.ErrApprovalNotPending):
+		utils.WriteErrorJSON(w, http.StatusConflict, "APPROVAL_NOT_PENDING", err.Error(), "")
+	case errors.Is(err, services.ErrApprovalExpired):
+		utils.WriteErrorJSON(w, http.StatusConflict, "APPROVAL_EXPIRED", err.Error(), "")
+	case errors.Is(err, services.ErrApprovalSelfDecision):
+		utils.WriteErrorJSON(w, http.StatusForbidden, "APPROVAL_SELF_DECISION", err.Error(), "")
+	case errors.Is(err, services.ErrApprovalNotRequester):
+		utils.WriteErrorJSON(w, http.StatusForbidden, "APPROVAL_NOT_REQUESTER", err.Error(), "")
+	default:
+		log.Error().Err(err).Msg("Approval request failed")
+		utils.WriteInternalServerError(w)
+	}
+}
+
+// approvalIDParam returns the approval ID of the URL, writing 404 when it isn't a UUID
+func approvalIDParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	approvalID := chi.URLParam(r, "approvalId")
+	if _, err := uuid.Parse(approvalID); err != nil {
+		utils.WriteNotFoundError(w, "Approval request")
+		return "", false
+	}
+	return approvalID, true
+}
+
+// ApprovalDecisionRequest represents the request to approve, reject, cancel or comment on an approval request
+type ApprovalDecisionRequest struct {
+	Comment string `json:"comment"`
+}
+
+func decodeApprovalDecision(w http.ResponseWriter, r *http.Request, requireComment bool) (ApprovalDecisionRequest, bool) {
+	var req ApprovalDecisionRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.WriteBadRequestError(w, "Invalid request body")
+			return req, false
+		}
+	}
+	req.Comment = strings.TrimSpace(req.Comment)
+	if requireComment && req.Comment == "" {
+		utils.WriteValidationErrorJSON(w, "Validation failed", map[string]string{
+			"comment": "Comment is required",
+		})
+		return req, false
+	}
+	return req, true
+}
+
+// HandleGetApprovalsImpl lists approval requests
+func HandleGetApprovalsImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit <= 0 || limit > 100 {
+			limit = 10
+		}
+
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page <= 0 {
+			page = 1
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		query := r.URL.Query()
+		requests, totalRows, err := deps.Approvals.List(ctx, query.Get("status"), query.Get("action"), query.Get("resourceId"), limit, (page-1)*limit)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to list approval requests")
+			utils.WriteInternalServerError(w)
+			return
+		}
+		totalPages := (totalRows + limit - 1) / limit
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"approvals": requests,
+			"pagination": map[string]interface{}{
+				"limit":      limit,
+				"page":       page,
+				"totalRows":  totalRows,
+				"totalPages": totalPages,
+			},
+		})
+	}
+}
+
+// HandleGetApprovalImpl returns an approval request with its comments
+func HandleGetApprovalImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		approvalID, ok := approvalIDParam(w, r)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		request, err := deps.Approvals.Get(ctx, approvalID)
+		if err != nil {
+			writeApprovalError(w, err)
+			return
+		}
+
+		utils.WriteSuccessJSON(w, request)
+	}
+}
+
+// HandleApproveApprovalImpl approves a request of another admin and executes its action
+func HandleApproveApprovalImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		approvalID, ok := approvalIDParam(w, r)
+		if !ok {
+			return
+		}
+		adminID := middleware.GetAdminIDFromContext(r.Context())
+
+		req, ok := decodeApprovalDecision(w, r, false)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+		defer cancel()
+
+		request, err := deps.Approvals.Claim(ctx, approvalID, adminID, req.Comment)
+		if err != nil {
+			writeApprovalError(w, err)
+			return
+		}
+
+		// The claimed action runs to its end even if the approver goes away
+		execCtx, execCancel := context.WithTimeout(context.WithoutCancel(r.Context()), 30*time.Second)
+		defer execCancel()
+
+		execute, known := approvalExecutors[request.Action]
+		if !known {
+			if err := deps.Approvals.Fail(execCtx, approvalID, "Unknown action "+request.Action); err != nil {
+				log.Error().Err(err).Str("approval_id", approvalID).Msg("Failed to fail approval request")
+			}
+			utils.WriteErrorJSON(w, http.StatusUnprocessableEntity, "APPROVAL_UNKNOWN_ACTION", "Unknown action "+request.Action, "")
+			return
+		}
+
+		claimed := request
+		by := adminAction{RequestedBy: claimed.RequestedBy.ID, ApprovedBy: adminID, ApprovalID: claimed.ID}
+		var result *actionResult
+		request, execErr := deps.Approvals.Execute(execCtx, approvalID, func(tx pgx.Tx) (interface{}, error) {
+			var err error
+			result, err = execute(execCtx, deps, tx, r, claimed, by)
+			if err != nil {
+				return nil, err
+			}
+			return result.Data, nil
+		})
+		if request == nil {
+			writeApprovalError(w, execErr)
+			return
+		}
+		if execErr == nil && result.afterCommit != nil {
+			result.afterCommit()
+		}
+
+		description := "Approved " + request.Summary
+		if execErr != nil {
+			description += ", execution failed: " + execErr.Error()
+		}
+		recordAudit(execCtx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      "APPROVE",
+			Resource:    "APPROVAL",
+			ResourceID:  request.ID,
+			Description: description,
+		})
+
+		if execErr != nil {
+			status, code := http.StatusInternalServerError, "APPROVAL_EXECUTION_FAILED"
+			var actionErr *actionError
+			if errors.As(execErr, &actionErr) {
+				status, code = actionErr.Status, actionErr.Code
+			} else {
+				log.Error().Err(execErr).Str("approval_id", approvalID).Msg("Approved action failed")
+			}
+			utils.WriteErrorJSON(w, status, code, request.Error, "The approval request is marked as FAILED")
+			return
+		}
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"message":  "Approval request approved and executed",
+			"approval": request,
+		})
+	}
+}
+
+// HandleRejectApprovalImpl rejects a request of another admin
+func HandleRejectApprovalImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		approvalID, ok := approvalIDParam(w, r)
+		if !ok {
+			return
+		}
+		adminID := middleware.GetAdminIDFromContext(r.Context())
+
+		req, ok := decodeApprovalDecision(w, r, true)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		request, err := deps.Approvals.Reject(ctx, approvalID, adminID, req.Comment)
+		if err != nil {
+			writeApprovalError(w, err)
+			return
+		}
+
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      "REJECT",
+			Resource:    "APPROVAL",
+			ResourceID:  request.ID,
+			Description: "Rejected " + request.Summary + ": " + req.Comment,
+		})
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"message":  "Approval request rejected",
+			"approval": request,
+		})
+	}
+}
+
+// HandleCancelApprovalImpl withdraws an own pending request
+func HandleCancelApprovalImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		approvalID, ok := approvalIDParam(w, r)
+		if !ok {
+			return
+		}
+		adminID := middleware.GetAdminIDFromContext(r.Context())
+
+		req, ok := decodeApprovalDecision(w, r, false)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		request, err := deps.Approvals.Cancel(ctx, approvalID, adminID, req.Comment)
+		if err != nil {
+			writeApprovalError(w, err)
+			return
+		}
+
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      "CANCEL",
+			Resource:    "APPROVAL",
+			ResourceID:  request.ID,
+			Description: "Cancelled approval request for " + request.Summary,
+		})
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"message":  "Approval request cancelled",
+			"approval": request,
+		})
+	}
+}
+
+// HandleCommentApprovalImpl adds a comment to an approval request
+func HandleCommentApprovalImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		approvalID, ok := approvalIDParam(w, r)
+		if !ok {
+			return
+		}
+		adminID := middleware.GetAdminIDFromContext(r.Context())
+
+		req, ok := decodeApprovalDecision(w, r, true)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		request, err := deps.Approvals.Comment(ctx, approvalID, adminID, req.Comment)
+		if err != nil {
+			writeApprovalError(w, err)
+			return
+		}
+
+		utils.WriteCreatedJSON(w, request)
+	}
+}
@@ -0,0 +1,125 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"seaply/internal/services"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestApprovalActionReplaysPayload(t *testing.T) {
+	var gotID string
+	var gotReq AdjustBalanceRequest
+	var gotBy adminAction
+	executor := approvalAction(func(ctx context.Context, deps *Dependencies, tx pgx.Tx, r *http.Request, resourceID string, req AdjustBalanceRequest, by adminAction) (*actionResult, error) {
+		gotID, gotReq, gotBy = resourceID, req, by
+		return &actionResult{}, nil
+	})
+
+	tests := []struct {
+		name    string
+		payload string
+		want    AdjustBalanceRequest
+		wantErr bool
+	}{
+		{
+			name:    "stored request",
+			payload: `{"type":"DEBIT","amount":2500000,"currency":"IDR","reason":"Chargeback"}`,
+			want:    AdjustBalanceRequest{Type: "DEBIT", Amount: 2500000, Currency: "IDR", Reason: "Chargeback"},
+		},
+		{name: "invalid payload", payload: `{"amount":"many"}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotID, gotReq = "", AdjustBalanceRequest{}
+			request := &services.ApprovalRequest{ID: "apr-1", ResourceID: "user-1", Payload: json.RawMessage(tt.payload)}
+			by := adminAction{RequestedBy: "admin-1", ApprovedBy: "admin-2", ApprovalID: request.ID}
+
+			_, err := executor(context.Background(), nil, nil, httptest.NewRequest(http.MethodPost, "/", nil), request, by)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("invalid payload was executed")
+				}
+				if gotID != "" {
+					t.Error("action ran for an invalid payload")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("executor: %v", err)
+			}
+			if gotID != "user-1" || gotReq != tt.want || gotBy != by {
+				t.Errorf("action ran with %q %+v %+v", gotID, gotReq, gotBy)
+			}
+		})
+	}
+}
+
+func TestApprovalExecutorsCoverEveryAction(t *testing.T) {
+	for _, action := range []string{
+		services.ApprovalAdjustBalance,
+		services.ApprovalRefundTransaction,
+		services.ApprovalRefundDeposit,
+		services.ApprovalConfirmDeposit,
+		services.ApprovalManualProcess,
+	} {
+		if approvalExecutors[action] == nil {
+			t.Errorf("no executor for %s", action)
+		}
+	}
+}
+
+func TestAdminActionLedgerNote(t *testing.T) {
+	tests := []struct {
+		name     string
+		by       adminAction
+		wantNote string
+		wantData map[string]interface{}
+	}{
+		{
+			name:     "direct action",
+			by:       adminAction{RequestedBy: "admin-1"},
+			wantData: map[string]interface{}{"requestedBy": "admin-1"},
+		},
+		{
+			name:     "approved action",
+			by:       adminAction{RequestedBy: "admin-1", ApprovedBy: "admin-2", ApprovalID: "apr-1"},
+			wantNote: "Approved by admin admin-2 (approval apr-1)",
+			wantData: map[string]interface{}{"requestedBy": "admin-1", "approvedBy": "admin-2", "approvalId": "apr-1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.by.note(); got != tt.wantNote {
+				t.Errorf("note() = %q, want %q", got, tt.wantNote)
+			}
+			data := tt.by.logData()
+			if len(data) != len(tt.wantData) {
+				t.Fatalf("logData() = %v, want %v", data, tt.wantData)
+			}
+			for k, v := range tt.wantData {
+				if data[k] != v {
+					t.Errorf("logData()[%s] = %v, want %v", k, data[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestHoldForApprovalWithoutService(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	if holdForApproval(w, r, &Dependencies{}, services.NewApproval{Action: services.ApprovalAdjustBalance, Amount: 1}) {
+		t.Error("action held without an approval service")
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("response written: %s", w.Body.String())
+	}
+}
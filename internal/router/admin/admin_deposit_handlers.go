@@ -9,10 +9,12 @@ import (
 	"time"
 
 	"seaply/internal/middleware"
+	"seaply/internal/services"
 	"seaply/internal/utils"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
 )
 
 // ============================================
@@ -364,142 +366,199 @@ func HandleConfirmDepositImpl(deps *Dependencies) http.HandlerFunc {
 		ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
 		defer cancel()
 
-		// Begin transaction
-		tx, err := deps.DB.Pool.Begin(ctx)
+		deposit, err := loadDeposit(ctx, deps.DB.Pool, depositID, "PENDING")
 		if err != nil {
-			utils.WriteInternalServerError(w)
+			writeActionError(w, r, err)
 			return
 		}
-		defer tx.Rollback(ctx)
-
-		// Get deposit details
-		var userID, currency, status string
-		var amount int64
-
-		err = tx.QueryRow(ctx, `
-			SELECT user_id, amount, currency, status
-			FROM deposits
-			WHERE id = $1
-		`, depositID).Scan(&userID, &amount, &currency, &status)
 
-		if err != nil {
-			if err == pgx.ErrNoRows {
-				utils.WriteErrorJSON(w, http.StatusNotFound, "DEPOSIT_NOT_FOUND",
-					"Deposit not found", "")
-				return
-			}
-			utils.WriteInternalServerError(w)
+		if holdForApproval(w, r, deps, services.NewApproval{
+			Action:     services.ApprovalConfirmDeposit,
+			Resource:   "DEPOSIT",
+			ResourceID: depositID,
+			Amount:     deposit.amount,
+			Currency:   deposit.currency,
+			Summary:    "Confirm deposit " + depositID + " of " + utils.FormatCurrency(float64(deposit.amount), deposit.currency),
+			Payload:    req,
+			Comment:    req.Reason,
+		}) {
 			return
 		}
 
-		// Validate deposit status
-		if status != "PENDING" {
-			utils.WriteErrorJSON(w, http.StatusBadRequest, "DEPOSIT_NOT_PENDING",
-				"Deposit must be in PENDING status", "")
+		result, err := runAdminAction(ctx, deps, func(tx pgx.Tx) (*actionResult, error) {
+			return confirmDeposit(ctx, deps, tx, r, depositID, req, adminAction{RequestedBy: adminID})
+		})
+		if err != nil {
+			writeActionError(w, r, err)
 			return
 		}
 
-		// Get current balance
-		balanceColumn := "balance_idr"
-		if currency == "MYR" {
-			balanceColumn = "balance_myr"
-		} else if currency == "PHP" {
-			balanceColumn = "balance_php"
-		} else if currency == "SGD" {
-			balanceColumn = "balance_sgd"
-		} else if currency == "THB" {
-			balanceColumn = "balance_thb"
+		// Get admin name
+		var adminName string
+		deps.DB.Pool.QueryRow(context.Background(), "SELECT first_name || ' ' || last_name FROM admins WHERE id = $1", adminID).Scan(&adminName)
+		result.Data["confirmedBy"] = map[string]interface{}{
+			"id":   adminID,
+			"name": adminName,
 		}
 
-		var currentBalance int64
-		err = tx.QueryRow(ctx, "SELECT "+balanceColumn+" FROM users WHERE id = $1", userID).Scan(&currentBalance)
-		if err != nil {
-			utils.WriteInternalServerError(w)
-			return
-		}
+		utils.WriteSuccessJSON(w, result.Data)
+	}
+}
 
-		// Add deposit amount to user balance
-		newBalance := currentBalance + amount
+// adminDeposit is a deposit loaded for a manual action
+type adminDeposit struct {
+	userID   string
+	currency string
+	amount   int64
+}
 
-		_, err = tx.Exec(ctx, "UPDATE users SET "+balanceColumn+" = $1 WHERE id = $2", newBalance, userID)
-		if err != nil {
-			utils.WriteInternalServerError(w)
-			return
+// loadDeposit loads a deposit and checks that it has the status an action needs
+func loadDeposit(ctx context.Context, db services.DBTX, depositID, wantStatus string) (adminDeposit, error) {
+	var deposit adminDeposit
+	var status string
+	err := db.QueryRow(ctx, `
+		SELECT user_id, amount, currency, status
+		FROM deposits
+		WHERE id = $1
+	`, depositID).Scan(&deposit.userID, &deposit.amount, &deposit.currency, &status)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return deposit, &actionError{Status: http.StatusNotFound, Code: "DEPOSIT_NOT_FOUND", Message: "Deposit not found"}
+		}
+		return deposit, err
+	}
+
+	// Validate deposit status
+	if status != wantStatus {
+		if wantStatus == "PENDING" {
+			return deposit, &actionError{Status: http.StatusBadRequest, Code: "DEPOSIT_NOT_PENDING", Message: "Deposit must be in PENDING status"}
 		}
+		return deposit, &actionError{Status: http.StatusBadRequest, Code: "DEPOSIT_NOT_SUCCESS", Message: "Can only refund SUCCESS deposits"}
+	}
+	return deposit, nil
+}
 
-		// Get deposit invoice number and payment channel for mutation
-		var depositInvoiceNumber sql.NullString
-		var paymentChannelName sql.NullString
-		_ = tx.QueryRow(ctx, `
-			SELECT d.invoice_number, pc.name
-			FROM deposits d
-			LEFT JOIN payment_channels pc ON d.payment_channel_id = pc.id
-			WHERE d.id = $1
-		`, depositID).Scan(&depositInvoiceNumber, &paymentChannelName)
-		
-		invoiceNum := ""
-		if depositInvoiceNumber.Valid {
-			invoiceNum = depositInvoiceNumber.String
-		}
-		paymentName := "Bank Transfer"
-		if paymentChannelName.Valid && paymentChannelName.String != "" {
-			paymentName = paymentChannelName.String
-		}
-
-		// Create balance mutation record
-		_, err = tx.Exec(ctx, `
-			INSERT INTO mutations (
-				user_id, invoice_number, mutation_type, amount, balance_before, balance_after,
-				description, reference_type, reference_id, currency, created_at
-			) VALUES ($1, $2, 'CREDIT', $3, $4, $5, $6, 'DEPOSIT', $7, $8, NOW())
-		`, userID, nullString(invoiceNum), amount, currentBalance, newBalance,
-			"Isi Ulang Saldo via "+paymentName, depositID, currency)
+// depositBalanceColumn returns the users column holding the balance in currency
+func depositBalanceColumn(currency string) string {
+	balanceColumn := "balance_idr"
+	if currency == "MYR" {
+		balanceColumn = "balance_myr"
+	} else if currency == "PHP" {
+		balanceColumn = "balance_php"
+	} else if currency == "SGD" {
+		balanceColumn = "balance_sgd"
+	} else if currency == "THB" {
+		balanceColumn = "balance_thb"
+	}
+	return balanceColumn
+}
 
-		if err != nil {
-			utils.WriteInternalServerError(w)
-			return
-		}
+// confirmDeposit marks a pending deposit as paid in tx and credits it to the user
+func confirmDeposit(ctx context.Context, deps *Dependencies, tx pgx.Tx, r *http.Request, depositID string, req ConfirmDepositRequest, by adminAction) (*actionResult, error) {
+	// Lock the deposit so it is only confirmed once
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM deposits WHERE id = $1 FOR UPDATE`, depositID); err != nil {
+		return nil, err
+	}
+	deposit, err := loadDeposit(ctx, tx, depositID, "PENDING")
+	if err != nil {
+		return nil, err
+	}
 
-		// Update deposit status to SUCCESS
-		_, err = tx.Exec(ctx, `
-			UPDATE deposits
-			SET status = 'SUCCESS', paid_at = NOW()
-			WHERE id = $1
-		`, depositID)
+	// Get current balance
+	balanceColumn := depositBalanceColumn(deposit.currency)
 
-		if err != nil {
-			utils.WriteInternalServerError(w)
-			return
-		}
+	var currentBalance int64
+	err = tx.QueryRow(ctx, "SELECT "+balanceColumn+" FROM users WHERE id = $1 FOR UPDATE", deposit.userID).Scan(&currentBalance)
+	if err != nil {
+		return nil, err
+	}
 
-		// Create audit log
-		_, err = tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'CONFIRM', 'DEPOSIT', $2, $3, NOW())
-		`, adminID, depositID, "Manually confirmed deposit: "+req.Reason)
+	// Add deposit amount to user balance
+	newBalance := currentBalance + deposit.amount
 
-		// Commit transaction
-		if err := tx.Commit(ctx); err != nil {
-			utils.WriteInternalServerError(w)
-			return
-		}
+	if _, err := tx.Exec(ctx, "UPDATE users SET "+balanceColumn+" = $1 WHERE id = $2", newBalance, deposit.userID); err != nil {
+		return nil, err
+	}
 
-		// Get admin name
-		var adminName string
-		deps.DB.Pool.QueryRow(context.Background(), "SELECT first_name || ' ' || last_name FROM admins WHERE id = $1", adminID).Scan(&adminName)
+	// Get deposit invoice number and payment channel for mutation
+	var depositInvoiceNumber sql.NullString
+	var paymentChannelName sql.NullString
+	_ = tx.QueryRow(ctx, `
+		SELECT d.invoice_number, pc.name
+		FROM deposits d
+		LEFT JOIN payment_channels pc ON d.payment_channel_id = pc.id
+		WHERE d.id = $1
+	`, depositID).Scan(&depositInvoiceNumber, &paymentChannelName)
+
+	invoiceNum := ""
+	if depositInvoiceNumber.Valid {
+		invoiceNum = depositInvoiceNumber.String
+	}
+	paymentName := "Bank Transfer"
+	if paymentChannelName.Valid && paymentChannelName.String != "" {
+		paymentName = paymentChannelName.String
+	}
 
-		utils.WriteSuccessJSON(w, map[string]interface{}{
-			"message":       "Deposit confirmed successfully",
-			"depositAmount": amount,
-			"balanceBefore": currentBalance,
-			"balanceAfter":  newBalance,
-			"confirmedBy": map[string]interface{}{
-				"id":   adminID,
-				"name": adminName,
-			},
-			"confirmedAt": time.Now().Format(time.RFC3339),
-		})
+	// Create balance mutation record with the requester and the approver
+	_, err = tx.Exec(ctx, `
+		INSERT INTO mutations (
+			user_id, invoice_number, mutation_type, amount, balance_before, balance_after,
+			description, reference_type, reference_id, currency, admin_id, admin_note, created_at
+		) VALUES ($1, $2, 'CREDIT', $3, $4, $5, $6, 'DEPOSIT', $7, $8, $9, $10, NOW())
+	`, deposit.userID, nullString(invoiceNum), deposit.amount, currentBalance, newBalance,
+		"Isi Ulang Saldo via "+paymentName, depositID, deposit.currency, nullString(by.RequestedBy), nullString(by.note()))
+	if err != nil {
+		return nil, err
+	}
+
+	// Update deposit status to SUCCESS
+	if _, err := tx.Exec(ctx, `
+		UPDATE deposits
+		SET status = 'SUCCESS', paid_at = NOW()
+		WHERE id = $1
+	`, depositID); err != nil {
+		return nil, err
+	}
+
+	// Add deposit log with the requester and the approver
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO deposit_logs (deposit_id, status, message, data, created_at)
+		VALUES ($1, 'SUCCESS', $2, $3, NOW())
+	`, depositID, "Deposit manually confirmed: "+req.Reason, by.logData()); err != nil {
+		return nil, err
+	}
+
+	// Create audit log
+	if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+		Action:      "CONFIRM",
+		Resource:    "DEPOSIT",
+		ResourceID:  depositID,
+		Description: "Manually confirmed deposit: " + req.Reason,
+	}); err != nil {
+		return nil, err
+	}
+
+	// The customer email is queued with the confirmation
+	notification, err := deps.NotificationService.NotifyDepositTx(ctx, tx, depositID, services.EventDepositSuccess, nil)
+	if err != nil {
+		log.Warn().Err(err).Str("deposit_id", depositID).Msg("Failed to queue deposit notification")
 	}
+
+	data := map[string]interface{}{
+		"message":       "Deposit confirmed successfully",
+		"depositAmount": deposit.amount,
+		"balanceBefore": currentBalance,
+		"balanceAfter":  newBalance,
+		"confirmedBy":   map[string]interface{}{"id": by.RequestedBy},
+		"confirmedAt":   time.Now().Format(time.RFC3339),
+	}
+	if by.ApprovalID != "" {
+		data["approvedBy"] = map[string]interface{}{"id": by.ApprovedBy}
+	}
+	return &actionResult{Data: data, afterCommit: func() {
+		publishDepositStatus(deps, depositID)
+		deps.NotificationService.Notify(notification)
+	}}, nil
 }
 
 // CancelDepositRequest represents the request to cancel a deposit
@@ -511,7 +570,6 @@ type CancelDepositRequest struct {
 func HandleCancelDepositImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		depositID := chi.URLParam(r, "depositId")
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		var req CancelDepositRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -558,10 +616,15 @@ func HandleCancelDepositImpl(deps *Dependencies) http.HandlerFunc {
 		}
 
 		// Create audit log
-		_, err = tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'CANCEL', 'DEPOSIT', $2, $3, NOW())
-		`, adminID, depositID, "Cancelled deposit: "+req.Reason)
+		if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+			Action:      "CANCEL",
+			Resource:    "DEPOSIT",
+			ResourceID:  depositID,
+			Description: "Cancelled deposit: " + req.Reason,
+		}); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
 
 		// Commit transaction
 		if err := tx.Commit(ctx); err != nil {
@@ -569,6 +632,8 @@ func HandleCancelDepositImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		publishDepositStatus(deps, depositID)
+
 		utils.WriteSuccessJSON(w, map[string]interface{}{
 			"message": "Deposit cancelled successfully",
 		})
@@ -597,124 +662,141 @@ func HandleRefundDepositImpl(deps *Dependencies) http.HandlerFunc {
 		ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
 		defer cancel()
 
-		// Begin transaction
-		tx, err := deps.DB.Pool.Begin(ctx)
+		deposit, err := loadDeposit(ctx, deps.DB.Pool, depositID, "SUCCESS")
 		if err != nil {
-			utils.WriteInternalServerError(w)
+			writeActionError(w, r, err)
 			return
 		}
-		defer tx.Rollback(ctx)
 
-		// Get deposit details
-		var userID, currency, status string
-		var amount int64
-
-		err = tx.QueryRow(ctx, `
-			SELECT user_id, amount, currency, status
-			FROM deposits
-			WHERE id = $1
-		`, depositID).Scan(&userID, &amount, &currency, &status)
-
-		if err != nil {
-			if err == pgx.ErrNoRows {
-				utils.WriteErrorJSON(w, http.StatusNotFound, "DEPOSIT_NOT_FOUND",
-					"Deposit not found", "")
-				return
-			}
-			utils.WriteInternalServerError(w)
+		// The balance is checked when the refund runs
+		if holdForApproval(w, r, deps, services.NewApproval{
+			Action:     services.ApprovalRefundDeposit,
+			Resource:   "DEPOSIT",
+			ResourceID: depositID,
+			Amount:     req.Amount,
+			Currency:   deposit.currency,
+			Summary:    "Refund " + utils.FormatCurrency(float64(req.Amount), deposit.currency) + " of deposit " + depositID + " to " + req.RefundTo,
+			Payload:    req,
+			Comment:    req.Reason,
+		}) {
 			return
 		}
 
-		// Validate deposit status
-		if status != "SUCCESS" {
-			utils.WriteErrorJSON(w, http.StatusBadRequest, "DEPOSIT_NOT_SUCCESS",
-				"Can only refund SUCCESS deposits", "")
+		result, err := runAdminAction(ctx, deps, func(tx pgx.Tx) (*actionResult, error) {
+			return refundDeposit(ctx, deps, tx, r, depositID, req, adminAction{RequestedBy: adminID})
+		})
+		if err != nil {
+			writeActionError(w, r, err)
 			return
 		}
 
-		// Get current balance
-		balanceColumn := "balance_idr"
-		if currency == "MYR" {
-			balanceColumn = "balance_myr"
-		} else if currency == "PHP" {
-			balanceColumn = "balance_php"
-		} else if currency == "SGD" {
-			balanceColumn = "balance_sgd"
-		} else if currency == "THB" {
-			balanceColumn = "balance_thb"
+		// Get admin name
+		var adminName string
+		deps.DB.Pool.QueryRow(context.Background(), "SELECT first_name || ' ' || last_name FROM admins WHERE id = $1", adminID).Scan(&adminName)
+		result.Data["processedBy"] = map[string]interface{}{
+			"id":   adminID,
+			"name": adminName,
 		}
 
-		var currentBalance int64
-		err = tx.QueryRow(ctx, "SELECT "+balanceColumn+" FROM users WHERE id = $1", userID).Scan(&currentBalance)
-		if err != nil {
-			utils.WriteInternalServerError(w)
-			return
-		}
+		utils.WriteSuccessJSON(w, result.Data)
+	}
+}
 
-		// Check if user has sufficient balance
-		if currentBalance < req.Amount {
-			utils.WriteErrorJSON(w, http.StatusBadRequest, "INSUFFICIENT_BALANCE",
-				"User has insufficient balance for refund", "")
-			return
-		}
+// refundDeposit takes a refunded deposit back from the balance of its user in tx
+func refundDeposit(ctx context.Context, deps *Dependencies, tx pgx.Tx, r *http.Request, depositID string, req RefundDepositRequest, by adminAction) (*actionResult, error) {
+	// Lock the deposit so it is only refunded once
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM deposits WHERE id = $1 FOR UPDATE`, depositID); err != nil {
+		return nil, err
+	}
+	deposit, err := loadDeposit(ctx, tx, depositID, "SUCCESS")
+	if err != nil {
+		return nil, err
+	}
 
-		// Deduct refund amount from user balance
-		newBalance := currentBalance - req.Amount
+	// Get current balance
+	balanceColumn := depositBalanceColumn(deposit.currency)
 
-		_, err = tx.Exec(ctx, "UPDATE users SET "+balanceColumn+" = $1 WHERE id = $2", newBalance, userID)
-		if err != nil {
-			utils.WriteInternalServerError(w)
-			return
-		}
+	var currentBalance int64
+	err = tx.QueryRow(ctx, "SELECT "+balanceColumn+" FROM users WHERE id = $1 FOR UPDATE", deposit.userID).Scan(&currentBalance)
+	if err != nil {
+		return nil, err
+	}
 
-		// Create balance mutation record
-		_, err = tx.Exec(ctx, `
-			INSERT INTO balance_mutations (
-				user_id, type, amount, balance_before, balance_after,
-				description, reference_type, reference_id, currency, created_at
-			) VALUES ($1, 'DEBIT', $2, $3, $4, $5, 'DEPOSIT_REFUND', $6, $7, NOW())
-		`, userID, req.Amount, currentBalance, newBalance,
-			"Deposit refunded: "+req.Reason, depositID, currency)
+	// Check if user has sufficient balance
+	if currentBalance < req.Amount {
+		return nil, &actionError{Status: http.StatusBadRequest, Code: "INSUFFICIENT_BALANCE",
+			Message: "User has insufficient balance for refund"}
+	}
 
-		if err != nil {
-			utils.WriteInternalServerError(w)
-			return
-		}
+	// Deduct refund amount from user balance
+	newBalance := currentBalance - req.Amount
 
-		// Update deposit status to REFUNDED
-		_, err = tx.Exec(ctx, "UPDATE deposits SET status = 'REFUNDED' WHERE id = $1", depositID)
-		if err != nil {
-			utils.WriteInternalServerError(w)
-			return
-		}
+	if _, err := tx.Exec(ctx, "UPDATE users SET "+balanceColumn+" = $1 WHERE id = $2", newBalance, deposit.userID); err != nil {
+		return nil, err
+	}
 
-		// Create audit log
-		_, err = tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'REFUND', 'DEPOSIT', $2, $3, NOW())
-		`, adminID, depositID, "Refunded deposit: "+req.Reason)
+	// Create balance mutation record, naming the approver
+	description := "Deposit refunded: " + req.Reason
+	if note := by.note(); note != "" {
+		description += " (" + note + ")"
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO balance_mutations (
+			user_id, type, amount, balance_before, balance_after,
+			description, reference_type, reference_id, currency, created_at
+		) VALUES ($1, 'DEBIT', $2, $3, $4, $5, 'DEPOSIT_REFUND', $6, $7, NOW())
+	`, deposit.userID, req.Amount, currentBalance, newBalance, description, depositID, deposit.currency)
+	if err != nil {
+		return nil, err
+	}
 
-		// Commit transaction
-		if err := tx.Commit(ctx); err != nil {
-			utils.WriteInternalServerError(w)
-			return
-		}
+	// Update deposit status to REFUNDED
+	if _, err := tx.Exec(ctx, "UPDATE deposits SET status = 'REFUNDED' WHERE id = $1", depositID); err != nil {
+		return nil, err
+	}
 
-		// Get admin name
-		var adminName string
-		deps.DB.Pool.QueryRow(context.Background(), "SELECT first_name || ' ' || last_name FROM admins WHERE id = $1", adminID).Scan(&adminName)
+	// Add deposit log with the requester and the approver
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO deposit_logs (deposit_id, status, message, data, created_at)
+		VALUES ($1, 'REFUNDED', $2, $3, NOW())
+	`, depositID, "Deposit refunded: "+req.Reason, by.logData()); err != nil {
+		return nil, err
+	}
 
-		utils.WriteSuccessJSON(w, map[string]interface{}{
-			"message":       "Deposit refunded successfully",
-			"refundAmount":  req.Amount,
-			"refundTo":      req.RefundTo,
-			"balanceBefore": currentBalance,
-			"balanceAfter":  newBalance,
-			"processedBy": map[string]interface{}{
-				"id":   adminID,
-				"name": adminName,
-			},
-			"createdAt": time.Now().Format(time.RFC3339),
-		})
+	// Create audit log
+	if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+		Action:      "REFUND",
+		Resource:    "DEPOSIT",
+		ResourceID:  depositID,
+		Description: "Refunded deposit: " + req.Reason,
+	}); err != nil {
+		return nil, err
+	}
+
+	// The customer email is queued with the refund
+	notification, err := deps.NotificationService.NotifyDepositTx(ctx, tx, depositID, services.EventRefund, map[string]interface{}{
+		"Amount":   utils.FormatCurrency(float64(req.Amount), deposit.currency),
+		"RefundTo": req.RefundTo,
+		"Reason":   req.Reason,
+	})
+	if err != nil {
+		log.Warn().Err(err).Str("deposit_id", depositID).Msg("Failed to queue deposit notification")
+	}
+
+	data := map[string]interface{}{
+		"message":       "Deposit refunded successfully",
+		"refundAmount":  req.Amount,
+		"refundTo":      req.RefundTo,
+		"balanceBefore": currentBalance,
+		"balanceAfter":  newBalance,
+		"processedBy":   map[string]interface{}{"id": by.RequestedBy},
+		"createdAt":     time.Now().Format(time.RFC3339),
+	}
+	if by.ApprovalID != "" {
+		data["approvedBy"] = map[string]interface{}{"id": by.ApprovedBy}
 	}
+	return &actionResult{Data: data, afterCommit: func() {
+		publishDepositStatus(deps, depositID)
+		deps.NotificationService.Notify(notification)
+	}}, nil
 }
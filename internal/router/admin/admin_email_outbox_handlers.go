@@ -0,0 +1,256 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"seaply/internal/services"
+	"seaply/internal/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// ============================================
+// ADMIN EMAIL OUTBOX
+// ============================================
+
+const emailOutboxColumns = `
+	eo.id, eo.recipient, eo.subject, eo.template, eo.reference_type, eo.reference_id,
+	eo.status, eo.attempts, eo.max_attempts, eo.last_error,
+	eo.next_attempt_at, eo.sent_at, eo.created_at, eo.updated_at,
+	a.id, a.name
+`
+
+// scanEmailOutbox scans a row selected with emailOutboxColumns
+func scanEmailOutbox(row pgx.Row) (map[string]interface{}, error) {
+	var id, recipient, subject, status string
+	var template, referenceType, referenceID, lastError *string
+	var adminID, adminName *string
+	var attempts, maxAttempts int
+	var nextAttemptAt, createdAt, updatedAt time.Time
+	var sentAt *time.Time
+
+	err := row.Scan(
+		&id, &recipient, &subject, &template, &referenceType, &referenceID,
+		&status, &attempts, &maxAttempts, &lastError,
+		&nextAttemptAt, &sentAt, &createdAt, &updatedAt,
+		&adminID, &adminName,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	message := map[string]interface{}{
+		"id":            id,
+		"recipient":     recipient,
+		"subject":       subject,
+		"template":      template,
+		"referenceType": referenceType,
+		"referenceId":   referenceID,
+		"status":        status,
+		"attempts":      attempts,
+		"maxAttempts":   maxAttempts,
+		"lastError":     lastError,
+		"nextAttemptAt": nextAttemptAt.Format(time.RFC3339),
+		"sentAt":        nil,
+		"createdBy":     nil,
+		"createdAt":     createdAt.Format(time.RFC3339),
+		"updatedAt":     updatedAt.Format(time.RFC3339),
+	}
+	if sentAt != nil {
+		message["sentAt"] = sentAt.Format(time.RFC3339)
+	}
+	if adminID != nil {
+		message["createdBy"] = map[string]interface{}{
+			"id":   *adminID,
+			"name": adminName,
+		}
+	}
+	return message, nil
+}
+
+// HandleGetEmailOutboxImpl returns the email delivery log with filters
+func HandleGetEmailOutboxImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit <= 0 || limit > 100 {
+			limit = 10
+		}
+
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page <= 0 {
+			page = 1
+		}
+
+		status := r.URL.Query().Get("status")
+		recipient := r.URL.Query().Get("recipient")
+		referenceType := r.URL.Query().Get("referenceType")
+		referenceID := r.URL.Query().Get("referenceId")
+
+		offset := (page - 1) * limit
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		where := " WHERE 1=1"
+		args := []interface{}{}
+		argCount := 0
+
+		if status != "" {
+			argCount++
+			where += " AND eo.status = $" + strconv.Itoa(argCount)
+			args = append(args, status)
+		}
+		if recipient != "" {
+			argCount++
+			where += " AND eo.recipient ILIKE $" + strconv.Itoa(argCount)
+			args = append(args, "%"+recipient+"%")
+		}
+		if referenceType != "" {
+			argCount++
+			where += " AND eo.reference_type = $" + strconv.Itoa(argCount)
+			args = append(args, referenceType)
+		}
+		if referenceID != "" {
+			argCount++
+			where += " AND eo.reference_id = $" + strconv.Itoa(argCount)
+			args = append(args, referenceID)
+		}
+
+		var totalRows int
+		err := deps.DB.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM email_outbox eo"+where, args...).Scan(&totalRows)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to count email outbox")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		query := "SELECT " + emailOutboxColumns + " FROM email_outbox eo LEFT JOIN admins a ON eo.created_by = a.id" + where
+		query += " ORDER BY eo.created_at DESC"
+		argCount++
+		query += " LIMIT $" + strconv.Itoa(argCount)
+		args = append(args, limit)
+		argCount++
+		query += " OFFSET $" + strconv.Itoa(argCount)
+		args = append(args, offset)
+
+		rows, err := deps.DB.Pool.Query(ctx, query, args...)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to query email outbox")
+			utils.WriteInternalServerError(w)
+			return
+		}
+		defer rows.Close()
+
+		messages := []map[string]interface{}{}
+		for rows.Next() {
+			message, err := scanEmailOutbox(rows)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to scan email outbox")
+				continue
+			}
+			messages = append(messages, message)
+		}
+
+		var pendingCount, failedCount int
+		deps.DB.Pool.QueryRow(ctx, `
+			SELECT
+				COALESCE(SUM(CASE WHEN status IN ('PENDING', 'SENDING') THEN 1 ELSE 0 END), 0),
+				COALESCE(SUM(CASE WHEN status = 'FAILED' THEN 1 ELSE 0 END), 0)
+			FROM email_outbox
+		`).Scan(&pendingCount, &failedCount)
+
+		totalPages := (totalRows + limit - 1) / limit
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"overview": map[string]interface{}{
+				"pending": pendingCount,
+				"failed":  failedCount,
+			},
+			"messages": messages,
+			"pagination": map[string]interface{}{
+				"limit":      limit,
+				"page":       page,
+				"totalRows":  totalRows,
+				"totalPages": totalPages,
+			},
+		})
+	}
+}
+
+// HandleGetEmailOutboxMessageImpl returns a single outbox message including its body
+func HandleGetEmailOutboxMessageImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		message, err := scanEmailOutbox(deps.DB.Pool.QueryRow(ctx, `
+			SELECT `+emailOutboxColumns+`
+			FROM email_outbox eo
+			LEFT JOIN admins a ON eo.created_by = a.id
+			WHERE eo.id::text = $1
+		`, id))
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				utils.WriteNotFoundError(w, "Email")
+				return
+			}
+			log.Error().Err(err).Str("outbox_id", id).Msg("Failed to get email outbox message")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		var htmlBody string
+		deps.DB.Pool.QueryRow(ctx, `SELECT html_body FROM email_outbox WHERE id::text = $1`, id).Scan(&htmlBody)
+		message["htmlBody"] = htmlBody
+
+		utils.WriteSuccessJSON(w, message)
+	}
+}
+
+// HandleRetryEmailOutboxImpl puts a pending or failed email back in the queue
+func HandleRetryEmailOutboxImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+
+		if deps.EmailOutbox == nil {
+			utils.WriteErrorJSON(w, http.StatusServiceUnavailable, "NOTIFICATION_UNAVAILABLE",
+				"Notification service is not configured", "")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		if err := deps.EmailOutbox.Retry(ctx, id); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				utils.WriteErrorJSON(w, http.StatusBadRequest, "EMAIL_NOT_RETRYABLE",
+					"Email not found or already sent", "")
+				return
+			}
+			log.Error().Err(err).Str("outbox_id", id).Msg("Failed to retry email")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      "UPDATE",
+			Resource:    "EMAIL",
+			ResourceID:  id,
+			Description: "Retried email delivery",
+		})
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"message": "Email queued for delivery",
+			"id":      id,
+			"status":  "PENDING",
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"seaply/internal/payment"
+	"seaply/internal/services"
+	"seaply/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// ============================================
+// ADMIN PAYMENT GATEWAY REGISTRATION
+// ============================================
+
+// sealGatewayCredentials validates credentials against the gateway type and encrypts them.
+// A nil result leaves the column unchanged and an empty one clears it. It writes the
+// error response itself and returns false when the request can't be stored.
+func sealGatewayCredentials(w http.ResponseWriter, deps *Dependencies, code string, credentials map[string]string) (*string, bool) {
+	if credentials == nil {
+		return nil, true
+	}
+	if deps.GatewayRegistry == nil {
+		utils.WriteErrorJSON(w, http.StatusServiceUnavailable, "GATEWAY_REGISTRY_UNAVAILABLE", "Payment gateway registry is not configured", "")
+		return nil, false
+	}
+
+	sealed := ""
+	if len(credentials) > 0 {
+		if _, ok := payment.GetGatewayType(code); !ok {
+			utils.WriteValidationErrorJSON(w, "Validation failed", map[string]string{
+				"code": "Credentials can only be stored for a supported gateway",
+			})
+			return nil, false
+		}
+		if missing := payment.MissingGatewayCredentials(code, credentials); len(missing) > 0 {
+			utils.WriteValidationErrorJSON(w, "Validation failed", map[string]string{
+				"credentials": "Missing credentials: " + strings.Join(missing, ", "),
+			})
+			return nil, false
+		}
+		var err error
+		sealed, err = deps.GatewayRegistry.EncryptCredentials(credentials)
+		if err != nil {
+			writeCredentialError(w, err)
+			return nil, false
+		}
+	}
+	return &sealed, true
+}
+
+// verifyGatewayCredentials builds an unregistered instance with the new configuration and
+// runs its handshake, so rotated credentials only replace the running instance once they
+// work. It writes the error response itself and returns false when verification fails.
+func verifyGatewayCredentials(ctx context.Context, w http.ResponseWriter, deps *Dependencies, rec services.GatewayRecord, credentials map[string]string) bool {
+	candidate, err := deps.GatewayRegistry.Build(rec, credentials)
+	if err != nil {
+		utils.WriteValidationErrorJSON(w, "Validation failed", map[string]string{"credentials": err.Error()})
+		return false
+	}
+
+	handshakeCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if _, err := deps.GatewayRegistry.Handshake(handshakeCtx, candidate); err != nil {
+		log.Warn().Err(err).Str("gateway", rec.Code).Msg("Payment gateway credential verification failed")
+		utils.WriteErrorJSON(w, http.StatusUnprocessableEntity, "GATEWAY_VERIFICATION_FAILED",
+			"Gateway rejected the new credentials", err.Error())
+		return false
+	}
+	return true
+}
+
+// gatewayRegistration describes how a gateway is configured and whether it is registered
+func gatewayRegistration(ctx context.Context, deps *Dependencies, gatewayID uuid.UUID, code string) map[string]interface{} {
+	var (
+		isSandbox            bool
+		sealedCredentials    string
+		credentialsUpdatedAt *time.Time
+	)
+	_ = deps.DB.Pool.QueryRow(ctx, `
+		SELECT is_sandbox, COALESCE(credentials, ''), credentials_updated_at
+		FROM payment_gateways
+		WHERE id = $1
+	`, gatewayID).Scan(&isSandbox, &sealedCredentials, &credentialsUpdatedAt)
+
+	_, supported := payment.GetGatewayType(code)
+	registration := map[string]interface{}{
+		"supported":            supported,
+		"isSandbox":            isSandbox,
+		"hasCredentials":       sealedCredentials != "",
+		"credentials":          map[string]string{},
+		"credentialsUpdatedAt": nil,
+		"registered":           false,
+		"source":               "",
+	}
+	if credentialsUpdatedAt != nil {
+		registration["credentialsUpdatedAt"] = credentialsUpdatedAt.Format(time.RFC3339)
+	}
+	if deps.PaymentManager != nil {
+		_, err := deps.PaymentManager.Get(strings.ToUpper(code))
+		registration["registered"] = err == nil
+	}
+	if deps.GatewayRegistry == nil {
+		return registration
+	}
+
+	registration["source"] = deps.GatewayRegistry.Source(code)
+	if sealedCredentials != "" {
+		credentials, err := deps.GatewayRegistry.DecryptCredentials(sealedCredentials)
+		if err != nil {
+			registration["credentialsError"] = "Stored credentials can't be decrypted with the current key"
+			return registration
+		}
+		masked := make(map[string]string, len(credentials))
+		for field, value := range credentials {
+			masked[field] = utils.MaskSecret(value)
+		}
+		registration["credentials"] = masked
+	}
+	return registration
+}
+
+// HandleGetPaymentGatewayTypesImpl lists the gateway implementations and the credentials they need
+func HandleGetPaymentGatewayTypesImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"types": payment.GatewayTypes(),
+		})
+	}
+}
+
+// HandleReloadPaymentGatewaysImpl rebuilds every gateway instance from the database
+func HandleReloadPaymentGatewaysImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if deps.GatewayRegistry == nil {
+			utils.WriteErrorJSON(w, http.StatusServiceUnavailable, "GATEWAY_REGISTRY_UNAVAILABLE", "Payment gateway registry is not configured", "")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+		defer cancel()
+
+		if err := deps.GatewayRegistry.LoadAll(ctx); err != nil {
+			log.Error().Err(err).Msg("Failed to reload payment gateways")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		registered := []string{}
+		for _, g := range deps.PaymentManager.GetAll() {
+			registered = append(registered, g.GetName())
+		}
+
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      "UPDATE",
+			Resource:    "PAYMENT_GATEWAY",
+			Description: "Reloaded payment gateways from database",
+		})
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"registered": registered,
+		})
+	}
+}
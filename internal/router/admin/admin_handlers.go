@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"seaply/internal/middleware"
+	"seaply/internal/services"
 	"seaply/internal/storage"
 	"seaply/internal/utils"
 
@@ -61,6 +62,7 @@ type productFieldPayload struct {
 	MaxLength   *int     `json:"maxLength"`
 	Placeholder string   `json:"placeholder"`
 	Hint        string   `json:"hint"`
+	Pattern     string   `json:"pattern"`
 	Options     []string `json:"options"`
 	SortOrder   int      `json:"sortOrder"`
 }
@@ -346,13 +348,16 @@ type productRecord struct {
 	Features     []string
 	HowToOrder   []string
 	Tags         []string
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
+	// Mapping of account inputs to the provider order payload
+	CustomerNoTemplate   sql.NullString
+	CustomerDataTemplate map[string]string
+	CreatedAt            time.Time
+	UpdatedAt            time.Time
 }
 
 func loadProductByIdentifier(ctx context.Context, deps *Dependencies, identifier string) (*productRecord, error) {
 	var record productRecord
-	var featuresJSON, howToJSON []byte
+	var featuresJSON, howToJSON, customerDataJSON []byte
 	query := `
 		SELECT 
 			p.id, p.code, p.slug, p.title, p.subtitle, p.description, p.publisher,
@@ -362,6 +367,7 @@ func loadProductByIdentifier(ctx context.Context, deps *Dependencies, identifier
 			COALESCE(p.features, '[]'::jsonb),
 			COALESCE(p.how_to_order, '[]'::jsonb),
 			COALESCE(p.tags, ARRAY[]::text[]),
+			p.customer_no_template, p.customer_data_template,
 			p.created_at, p.updated_at
 		FROM products p
 		LEFT JOIN categories c ON p.category_id = c.id
@@ -373,6 +379,7 @@ func loadProductByIdentifier(ctx context.Context, deps *Dependencies, identifier
 		&record.CategoryCode, &record.CategoryName,
 		&record.IsActive, &record.IsPopular, &record.InquirySlug,
 		&featuresJSON, &howToJSON, &record.Tags,
+		&record.CustomerNoTemplate, &customerDataJSON,
 		&record.CreatedAt, &record.UpdatedAt,
 	); err != nil {
 		return nil, err
@@ -383,6 +390,9 @@ func loadProductByIdentifier(ctx context.Context, deps *Dependencies, identifier
 	if err := json.Unmarshal(howToJSON, &record.HowToOrder); err != nil {
 		record.HowToOrder = []string{}
 	}
+	if len(customerDataJSON) > 0 {
+		_ = json.Unmarshal(customerDataJSON, &record.CustomerDataTemplate)
+	}
 	return &record, nil
 }
 
@@ -474,6 +484,12 @@ func buildProductResponse(record *productRecord, regions []string, stats map[str
 	if record.InquirySlug.Valid {
 		response["inquirySlug"] = record.InquirySlug.String
 	}
+	if record.CustomerNoTemplate.Valid {
+		response["customerNoTemplate"] = record.CustomerNoTemplate.String
+	}
+	if len(record.CustomerDataTemplate) > 0 {
+		response["customerDataTemplate"] = record.CustomerDataTemplate
+	}
 	if record.CategoryCode.Valid {
 		response["category"] = map[string]interface{}{
 			"code":  record.CategoryCode.String,
@@ -505,7 +521,7 @@ func productHasTransactions(ctx context.Context, deps *Dependencies, productID u
 
 func fetchProductFields(ctx context.Context, deps *Dependencies, productID uuid.UUID) ([]map[string]interface{}, error) {
 	rows, err := deps.DB.Pool.Query(ctx, `
-		SELECT id, name, key, field_type, label, placeholder, hint,
+		SELECT id, name, key, field_type, label, placeholder, hint, pattern,
 			is_required, min_length, max_length, options, sort_order, created_at
 		FROM product_fields
 		WHERE product_id = $1
@@ -526,6 +542,7 @@ func fetchProductFields(ctx context.Context, deps *Dependencies, productID uuid.
 			label       string
 			placeholder sql.NullString
 			hint        sql.NullString
+			pattern     sql.NullString
 			required    bool
 			minLength   sql.NullInt32
 			maxLength   sql.NullInt32
@@ -533,7 +550,7 @@ func fetchProductFields(ctx context.Context, deps *Dependencies, productID uuid.
 			sortOrder   int
 			createdAt   time.Time
 		)
-		if err := rows.Scan(&id, &name, &key, &fieldType, &label, &placeholder, &hint,
+		if err := rows.Scan(&id, &name, &key, &fieldType, &label, &placeholder, &hint, &pattern,
 			&required, &minLength, &maxLength, &optionsJSON, &sortOrder, &createdAt); err != nil {
 			continue
 		}
@@ -558,6 +575,9 @@ func fetchProductFields(ctx context.Context, deps *Dependencies, productID uuid.
 		if hint.Valid {
 			field["hint"] = hint.String
 		}
+		if pattern.Valid {
+			field["pattern"] = pattern.String
+		}
 		// Always include minLength and maxLength, even if null
 		if minLength.Valid {
 			field["minLength"] = minLength.Int32
@@ -884,6 +904,14 @@ func HandleCreateAdminImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      services.AuditActionCreate,
+			Resource:    "ADMIN",
+			ResourceID:  adminID.String(),
+			Description: "Created admin " + req.Email + " with role " + role.Code,
+			After:       adminData,
+		})
+
 		utils.WriteCreatedJSON(w, adminData)
 	}
 }
@@ -1051,6 +1079,12 @@ func HandleUpdateAdminImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		before, err := fetchAdminDetail(ctx, deps, adminUUID)
+		if err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
+
 		updates = append(updates, "updated_at = NOW()")
 		args = append(args, adminUUID)
 
@@ -1070,6 +1104,19 @@ func HandleUpdateAdminImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		description := "Updated admin"
+		if newPasswordHash != "" {
+			description = "Updated admin and reset password"
+		}
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      services.AuditActionUpdate,
+			Resource:    "ADMIN",
+			ResourceID:  adminUUID.String(),
+			Description: description,
+			Before:      before,
+			After:       adminData,
+		})
+
 		utils.WriteSuccessJSON(w, adminData)
 	}
 }
@@ -1122,6 +1169,12 @@ func HandleDeleteAdminImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		before, err := fetchAdminDetail(ctx, deps, adminUUID)
+		if err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
+
 		result, err := deps.DB.Pool.Exec(ctx, `DELETE FROM admins WHERE id = $1`, adminUUID)
 		if err != nil {
 			utils.WriteInternalServerError(w)
@@ -1132,6 +1185,14 @@ func HandleDeleteAdminImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      services.AuditActionDelete,
+			Resource:    "ADMIN",
+			ResourceID:  adminUUID.String(),
+			Description: "Deleted admin " + fmt.Sprint(before["email"]),
+			Before:      before,
+		})
+
 		utils.WriteSuccessJSON(w, map[string]string{
 			"message": "Admin deleted successfully",
 		})
@@ -1505,6 +1566,14 @@ func HandleCreateProductImpl(deps *Dependencies) http.HandlerFunc {
 			utils.WriteInternalServerError(w)
 			return
 		}
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      services.AuditActionCreate,
+			Resource:    "PRODUCT",
+			ResourceID:  record.ID.String(),
+			Description: "Created product " + record.Code,
+			After:       buildProductResponse(record, regions, nil),
+		})
+
 		response := buildProductResponse(record, regions, getProductStats(ctx, deps, record.ID))
 		utils.WriteCreatedJSON(w, response)
 	}
@@ -1603,6 +1672,7 @@ func HandleUpdateProductImpl(deps *Dependencies) http.HandlerFunc {
 			{"description", "description"},
 			{"publisher", "publisher"},
 			{"inquirySlug", "inquiry_slug"},
+			{"customerNoTemplate", "customer_no_template"},
 		} {
 			if raw, ok := payload[field.jsonKey]; ok {
 				var value string
@@ -1666,6 +1736,21 @@ func HandleUpdateProductImpl(deps *Dependencies) http.HandlerFunc {
 			}
 		}
 
+		if raw, ok := payload["customerDataTemplate"]; ok {
+			var value map[string]string
+			if err := json.Unmarshal(raw, &value); err != nil {
+				utils.WriteValidationErrorJSON(w, "Validation failed", map[string]string{"customerDataTemplate": "Customer data template must map provider fields to template strings"})
+				return
+			}
+			var data []byte
+			if len(value) > 0 {
+				data, _ = json.Marshal(value)
+			}
+			updates = append(updates, fmt.Sprintf("customer_data_template = $%d", argPos))
+			args = append(args, data)
+			argPos++
+		}
+
 		var regions []string
 		if raw, ok := payload["regions"]; ok {
 			var value []string
@@ -1736,6 +1821,9 @@ func HandleUpdateProductImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		previousRegions, _ := getProductRegions(ctx, deps, record.ID)
+		before := buildProductResponse(record, previousRegions, nil)
+
 		updates = append(updates, "updated_at = NOW()")
 		args = append(args, record.ID)
 
@@ -1785,6 +1873,16 @@ func HandleUpdateProductImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 		regions, _ = getProductRegions(ctx, deps, record.ID)
+
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      services.AuditActionUpdate,
+			Resource:    "PRODUCT",
+			ResourceID:  record.ID.String(),
+			Description: "Updated product " + updated.Code,
+			Before:      before,
+			After:       buildProductResponse(updated, regions, nil),
+		})
+
 		utils.WriteSuccessJSON(w, buildProductResponse(updated, regions, getProductStats(ctx, deps, record.ID)))
 	}
 }
@@ -1820,11 +1918,20 @@ func HandleDeleteProductImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		regions, _ := getProductRegions(ctx, deps, record.ID)
 		if _, err := deps.DB.Pool.Exec(ctx, `DELETE FROM products WHERE id = $1`, record.ID); err != nil {
 			utils.WriteInternalServerError(w)
 			return
 		}
 
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      services.AuditActionDelete,
+			Resource:    "PRODUCT",
+			ResourceID:  record.ID.String(),
+			Description: "Deleted product " + record.Code,
+			Before:      buildProductResponse(record, regions, nil),
+		})
+
 		if record.Thumbnail.Valid {
 			deleteS3Object(ctx, deps, record.Thumbnail.String)
 		}
@@ -1896,6 +2003,12 @@ func HandleUpdateFieldsImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		previousFields, err := fetchProductFields(ctx, deps, record.ID)
+		if err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
+
 		tx, err := deps.DB.Pool.Begin(ctx)
 		if err != nil {
 			utils.WriteInternalServerError(w)
@@ -1917,14 +2030,28 @@ func HandleUpdateFieldsImpl(deps *Dependencies) http.HandlerFunc {
 				utils.WriteValidationErrorJSON(w, "Validation failed", map[string]string{"fields": "Field name, key, type and label are required"})
 				return
 			}
+			field.Pattern = strings.TrimSpace(field.Pattern)
+			if field.Pattern != "" {
+				if _, err := regexp.Compile(field.Pattern); err != nil {
+					utils.WriteValidationErrorJSON(w, "Validation failed", map[string]string{field.Key: "Pattern is not a valid regular expression"})
+					return
+				}
+			}
+			if field.Type == "select" && len(field.Options) == 0 {
+				utils.WriteValidationErrorJSON(w, "Validation failed", map[string]string{field.Key: "Select fields require at least one option"})
+				return
+			}
 
-			optionsJSON, _ := json.Marshal(field.Options)
+			var optionsJSON []byte
+			if len(field.Options) > 0 {
+				optionsJSON, _ = json.Marshal(field.Options)
+			}
 			if _, err := tx.Exec(ctx, `
 				INSERT INTO product_fields (
-					product_id, name, key, field_type, label, placeholder, hint,
+					product_id, name, key, field_type, label, placeholder, hint, pattern,
 					is_required, min_length, max_length, options, sort_order
-				) VALUES ($1,$2,$3,$4,$5, NULLIF($6,''), NULLIF($7,''), $8,$9,$10,$11,$12)
-			`, record.ID, field.Name, field.Key, field.Type, field.Label, field.Placeholder, field.Hint,
+				) VALUES ($1,$2,$3,$4,$5, NULLIF($6,''), NULLIF($7,''), NULLIF($8,''), $9,$10,$11,$12,$13)
+			`, record.ID, field.Name, field.Key, field.Type, field.Label, field.Placeholder, field.Hint, field.Pattern,
 				field.Required, field.MinLength, field.MaxLength, optionsJSON, field.SortOrderOrDefault(idx)); err != nil {
 				utils.WriteInternalServerError(w)
 				return
@@ -1942,6 +2069,15 @@ func HandleUpdateFieldsImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      services.AuditActionUpdate,
+			Resource:    "PRODUCT",
+			ResourceID:  record.ID.String(),
+			Description: "Updated fields of product " + record.Code,
+			Before:      map[string]interface{}{"fields": previousFields},
+			After:       map[string]interface{}{"fields": fields},
+		})
+
 		utils.WriteSuccessJSON(w, map[string]interface{}{"fields": fields})
 	}
 }
@@ -9,6 +9,8 @@ import (
 	"strings"
 	"time"
 
+	"seaply/internal/provider"
+	"seaply/internal/services"
 	"seaply/internal/utils"
 
 	"github.com/go-chi/chi/v5"
@@ -144,6 +146,17 @@ func HandleUpdateRolePermissionsImpl(deps *Dependencies) http.HandlerFunc {
 			}
 		}
 
+		var previousPermissions []string
+		if err := deps.DB.Pool.QueryRow(ctx, `
+			SELECT COALESCE(array_agg(p.code ORDER BY p.code), '{}')
+			FROM permissions p
+			JOIN role_permissions rp ON p.id = rp.permission_id
+			WHERE rp.role_id = $1
+		`, roleID).Scan(&previousPermissions); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
+
 		// Update permissions in transaction
 		err = deps.DB.WithTransaction(ctx, func(tx pgx.Tx) error {
 			// Delete existing permissions
@@ -199,6 +212,15 @@ func HandleUpdateRolePermissionsImpl(deps *Dependencies) http.HandlerFunc {
 			}
 		}
 
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      services.AuditActionUpdate,
+			Resource:    "ROLE",
+			ResourceID:  roleID.String(),
+			Description: "Updated permissions of role " + roleCode,
+			Before:      map[string]interface{}{"permissions": previousPermissions},
+			After:       map[string]interface{}{"permissions": permissions},
+		})
+
 		utils.WriteSuccessJSON(w, map[string]interface{}{
 			"code":        roleCode,
 			"name":        roleName,
@@ -282,6 +304,10 @@ func HandleGetProvidersImpl(deps *Dependencies) http.HandlerFunc {
 			if lastHealthCheck.Valid {
 				provider["lastHealthCheck"] = lastHealthCheck.Time.Format(time.RFC3339)
 			}
+			if deps.ProviderManager != nil {
+				provider["circuit"] = deps.ProviderManager.GetCircuitStats(strings.ToLower(code))
+			}
+			provider["balance"] = providerBalance(ctx, deps, id.String())
 
 			providers = append(providers, provider)
 		}
@@ -408,7 +434,7 @@ func HandleGetPaymentGatewayImpl(deps *Dependencies) http.HandlerFunc {
 
 func HandleCreatePaymentGatewayImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(r.Context(), 45*time.Second)
 		defer cancel()
 
 		var req struct {
@@ -422,6 +448,9 @@ func HandleCreatePaymentGatewayImpl(deps *Dependencies) http.HandlerFunc {
 			APIConfig         map[string]interface{} `json:"apiConfig"`
 			Mapping           map[string][]string    `json:"mapping"`
 			EnvCredentialKeys map[string]string      `json:"envCredentialKeys"`
+			Credentials       map[string]string      `json:"credentials"`
+			IsSandbox         bool                   `json:"isSandbox"`
+			Verify            *bool                  `json:"verify"` // run the handshake before storing credentials, default true
 		}
 
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -468,6 +497,11 @@ func HandleCreatePaymentGatewayImpl(deps *Dependencies) http.HandlerFunc {
 			}
 		}
 
+		sealedCredentials, ok := sealGatewayCredentials(w, deps, req.Code, req.Credentials)
+		if !ok {
+			return
+		}
+
 		var exists bool
 		if err := deps.DB.Pool.QueryRow(ctx, `
 			SELECT EXISTS(SELECT 1 FROM payment_gateways WHERE code = $1)
@@ -508,32 +542,65 @@ func HandleCreatePaymentGatewayImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		if stringValue(sealedCredentials) != "" && (req.Verify == nil || *req.Verify) {
+			rec := services.GatewayRecord{
+				Code:        req.Code,
+				CallbackURL: req.CallbackURL,
+				IsActive:    req.IsActive,
+				IsSandbox:   req.IsSandbox,
+				APIConfig:   apiConfigJSON,
+			}
+			if !verifyGatewayCredentials(ctx, w, deps, rec, req.Credentials) {
+				return
+			}
+		}
+
 		var id uuid.UUID
 		if err := deps.DB.Pool.QueryRow(ctx, `
 			INSERT INTO payment_gateways (
 				code, name, base_url, callback_url,
 				is_active, supported_methods, supported_types,
-				api_config, status_mapping, env_credential_keys
+				api_config, status_mapping, env_credential_keys,
+				credentials, is_sandbox, credentials_updated_at
 			)
-			VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, $9, $10)
+			VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, $9, $10,
+				NULLIF($11, ''), $12, CASE WHEN NULLIF($11, '') IS NULL THEN NULL ELSE NOW() END)
 			RETURNING id
-		`, req.Code, req.Name, req.BaseURL, req.CallbackURL, req.IsActive, req.SupportedMethods, req.SupportedTypes, apiConfigJSON, mappingJSON, envKeysJSON).Scan(&id); err != nil {
+		`, req.Code, req.Name, req.BaseURL, req.CallbackURL, req.IsActive, req.SupportedMethods, req.SupportedTypes, apiConfigJSON, mappingJSON, envKeysJSON,
+			stringValue(sealedCredentials), req.IsSandbox).Scan(&id); err != nil {
 			utils.WriteInternalServerError(w)
 			return
 		}
 
-		utils.WriteCreatedJSON(w, map[string]interface{}{
+		response := map[string]interface{}{
 			"id":      id.String(),
 			"code":    req.Code,
 			"name":    req.Name,
 			"message": "Payment gateway created successfully",
-		})
+		}
+
+		if stringValue(sealedCredentials) != "" {
+			response["message"] = "Payment gateway created and registered"
+			if err := deps.GatewayRegistry.Changed(ctx, id.String(), req.Code); err != nil {
+				response["message"] = "Payment gateway created but could not be registered"
+				response["registrationError"] = err.Error()
+			}
+
+			recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+				Action:      "CREATE",
+				Resource:    "PAYMENT_GATEWAY",
+				ResourceID:  id.String(),
+				Description: "Created payment gateway " + req.Code + " with stored credentials",
+			})
+		}
+
+		utils.WriteCreatedJSON(w, response)
 	}
 }
 
 func HandleUpdatePaymentGatewayImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(r.Context(), 45*time.Second)
 		defer cancel()
 
 		gatewayId := chi.URLParam(r, "gatewayId")
@@ -557,6 +624,9 @@ func HandleUpdatePaymentGatewayImpl(deps *Dependencies) http.HandlerFunc {
 			APIConfig         map[string]interface{} `json:"apiConfig"`
 			Mapping           map[string][]string    `json:"mapping"`
 			EnvCredentialKeys map[string]string      `json:"envCredentialKeys"`
+			Credentials       map[string]string      `json:"credentials"` // replaces the stored credentials, {} clears them
+			IsSandbox         *bool                  `json:"isSandbox"`
+			Verify            *bool                  `json:"verify"` // run the handshake before applying new credentials, default true
 		}
 
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -564,6 +634,54 @@ func HandleUpdatePaymentGatewayImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		var current services.GatewayRecord
+		if deps.GatewayRegistry != nil {
+			current, err = deps.GatewayRegistry.Record(ctx, gatewayUUID.String())
+		} else {
+			err = deps.DB.Pool.QueryRow(ctx, `SELECT code FROM payment_gateways WHERE id = $1`, gatewayUUID).Scan(&current.Code)
+		}
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				utils.WriteErrorJSON(w, http.StatusNotFound, "GATEWAY_NOT_FOUND", "Payment gateway not found", "")
+				return
+			}
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		sealedCredentials, ok := sealGatewayCredentials(w, deps, current.Code, req.Credentials)
+		if !ok {
+			return
+		}
+
+		// New credentials or a switch between sandbox and production are verified with a
+		// handshake against an unregistered instance before the running one is replaced
+		if deps.GatewayRegistry != nil && (req.Verify == nil || *req.Verify) {
+			candidate := current
+			credentials := req.Credentials
+			if credentials == nil && current.Credentials != "" && req.IsSandbox != nil && *req.IsSandbox != current.IsSandbox {
+				credentials, err = deps.GatewayRegistry.DecryptCredentials(current.Credentials)
+				if err != nil {
+					writeCredentialError(w, err)
+					return
+				}
+			}
+			if len(credentials) > 0 {
+				if req.IsSandbox != nil {
+					candidate.IsSandbox = *req.IsSandbox
+				}
+				if req.CallbackURL != nil {
+					candidate.CallbackURL = strings.TrimSpace(*req.CallbackURL)
+				}
+				if req.APIConfig != nil {
+					candidate.APIConfig, _ = json.Marshal(req.APIConfig)
+				}
+				if !verifyGatewayCredentials(ctx, w, deps, candidate, credentials) {
+					return
+				}
+			}
+		}
+
 		updates := []string{}
 		args := []interface{}{}
 		argPos := 1
@@ -643,11 +761,32 @@ func HandleUpdatePaymentGatewayImpl(deps *Dependencies) http.HandlerFunc {
 			argPos++
 		}
 
+		if sealedCredentials != nil {
+			updates = append(updates, fmt.Sprintf("credentials = NULLIF($%d, '')", argPos), "credentials_updated_at = NOW()")
+			args = append(args, *sealedCredentials)
+			argPos++
+		}
+		if req.IsSandbox != nil {
+			updates = append(updates, fmt.Sprintf("is_sandbox = $%d", argPos))
+			args = append(args, *req.IsSandbox)
+			argPos++
+		}
+
 		if len(updates) == 0 {
 			utils.WriteBadRequestError(w, "No fields to update")
 			return
 		}
 
+		before, err := fetchPaymentGatewayDetail(ctx, deps, gatewayUUID)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				utils.WriteErrorJSON(w, http.StatusNotFound, "GATEWAY_NOT_FOUND", "Payment gateway not found", "")
+				return
+			}
+			utils.WriteInternalServerError(w)
+			return
+		}
+
 		updates = append(updates, "updated_at = NOW()")
 		args = append(args, gatewayUUID)
 
@@ -668,12 +807,35 @@ func HandleUpdatePaymentGatewayImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		// Swap the running instance so changes apply without a restart
+		var registrationErr error
+		if deps.GatewayRegistry != nil {
+			registrationErr = deps.GatewayRegistry.Changed(ctx, id.String(), current.Code)
+		}
+
 		detail, err := fetchPaymentGatewayDetail(ctx, deps, id)
 		if err != nil {
 			utils.WriteInternalServerError(w)
 			return
 		}
 
+		description := "Updated payment gateway " + current.Code
+		if sealedCredentials != nil {
+			description += " and rotated its credentials"
+		}
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      services.AuditActionUpdate,
+			Resource:    "PAYMENT_GATEWAY",
+			ResourceID:  id.String(),
+			Description: description,
+			Before:      before,
+			After:       detail,
+		})
+
+		if registrationErr != nil {
+			detail["registrationError"] = registrationErr.Error()
+		}
+
 		utils.WriteSuccessJSON(w, detail)
 	}
 }
@@ -709,29 +871,114 @@ func HandleDeletePaymentGatewayImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
-		result, err := deps.DB.Pool.Exec(ctx, `
-			DELETE FROM payment_gateways WHERE id = $1
-		`, gatewayUUID)
+		before, err := fetchPaymentGatewayDetail(ctx, deps, gatewayUUID)
 		if err != nil {
+			if err == pgx.ErrNoRows {
+				utils.WriteErrorJSON(w, http.StatusNotFound, "GATEWAY_NOT_FOUND", "Payment gateway not found", "")
+				return
+			}
 			utils.WriteInternalServerError(w)
 			return
 		}
 
-		if result.RowsAffected() == 0 {
-			utils.WriteErrorJSON(w, http.StatusNotFound, "GATEWAY_NOT_FOUND", "Payment gateway not found", "")
+		var code string
+		err = deps.DB.Pool.QueryRow(ctx, `
+			DELETE FROM payment_gateways WHERE id = $1 RETURNING code
+		`, gatewayUUID).Scan(&code)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				utils.WriteErrorJSON(w, http.StatusNotFound, "GATEWAY_NOT_FOUND", "Payment gateway not found", "")
+				return
+			}
+			utils.WriteInternalServerError(w)
 			return
 		}
 
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      services.AuditActionDelete,
+			Resource:    "PAYMENT_GATEWAY",
+			ResourceID:  gatewayUUID.String(),
+			Description: "Deleted payment gateway " + code,
+			Before:      before,
+		})
+
+		if deps.GatewayRegistry != nil {
+			deps.GatewayRegistry.Deleted(ctx, code)
+		}
+
 		utils.WriteSuccessJSON(w, map[string]string{"message": "Payment gateway deleted successfully"})
 	}
 }
 
 func HandleTestPaymentGatewayImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 45*time.Second)
+		defer cancel()
+
+		gatewayUUID, err := uuid.Parse(chi.URLParam(r, "gatewayId"))
+		if err != nil {
+			utils.WriteBadRequestError(w, "Invalid gateway ID")
+			return
+		}
+		if deps.GatewayRegistry == nil {
+			utils.WriteErrorJSON(w, http.StatusServiceUnavailable, "GATEWAY_REGISTRY_UNAVAILABLE", "Payment gateway registry is not configured", "")
+			return
+		}
+
+		rec, err := deps.GatewayRegistry.Record(ctx, gatewayUUID.String())
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				utils.WriteErrorJSON(w, http.StatusNotFound, "GATEWAY_NOT_FOUND", "Payment gateway not found", "")
+				return
+			}
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		// A fresh instance is used so the test always performs a new handshake
+		gw, source, err := deps.GatewayRegistry.Instance(rec)
+		if err != nil {
+			utils.WriteErrorJSON(w, http.StatusBadRequest, "GATEWAY_NOT_CONFIGURED", "Payment gateway can't be built", err.Error())
+			return
+		}
+
+		start := time.Now()
+		method, err := deps.GatewayRegistry.Handshake(ctx, gw)
+		latency := time.Since(start).Milliseconds()
+
+		status, healthStatus, message := "SUCCESS", "HEALTHY", "Connection successful"
+		errorMessage := ""
+		if err != nil {
+			status, healthStatus, message = "FAILED", "UNHEALTHY", "Gateway handshake failed"
+			errorMessage = err.Error()
+		}
+
+		_, _ = deps.DB.Pool.Exec(ctx, `
+			UPDATE payment_gateways
+			SET health_status = $1::health_status, last_health_check = NOW()
+			WHERE id = $2
+		`, healthStatus, gatewayUUID)
+
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      "UPDATE",
+			Resource:    "PAYMENT_GATEWAY",
+			ResourceID:  gatewayUUID.String(),
+			Description: fmt.Sprintf("Tested payment gateway %s: %s", rec.Code, status),
+		})
+
 		utils.WriteSuccessJSON(w, map[string]interface{}{
-			"status":       "SUCCESS",
-			"responseTime": 600,
-			"message":      "Connection successful",
+			"gateway": map[string]interface{}{
+				"id":        gatewayUUID.String(),
+				"code":      rec.Code,
+				"isSandbox": rec.IsSandbox,
+				"source":    source,
+			},
+			"status":       status,
+			"method":       method,
+			"responseTime": latency,
+			"error":        errorMessage,
+			"message":      message,
+			"testedAt":     time.Now().Format(time.RFC3339),
 		})
 	}
 }
@@ -833,6 +1080,7 @@ func fetchPaymentGatewayDetail(ctx context.Context, deps *Dependencies, gatewayI
 		"stats":            stats,
 		"createdAt":        createdAt.Format(time.RFC3339),
 		"updatedAt":        updatedAt.Format(time.RFC3339),
+		"registration":     gatewayRegistration(ctx, deps, gatewayID, code),
 	}
 
 	if healthStatus.Valid && healthStatus.String != "" {
@@ -1050,6 +1298,12 @@ func HandleGetProviderImpl(deps *Dependencies) http.HandlerFunc {
 		if lastHealthCheck.Valid {
 			provider["lastHealthCheck"] = lastHealthCheck.Time.Format(time.RFC3339)
 		}
+		if deps.ProviderManager != nil {
+			provider["circuit"] = deps.ProviderManager.GetCircuitStats(strings.ToLower(code))
+			provider["skuCircuits"] = deps.ProviderManager.GetSKUCircuitStats(strings.ToLower(code))
+		}
+		provider["balance"] = providerBalance(ctx, deps, id.String())
+		provider["registration"] = providerRegistration(ctx, deps, id, code)
 
 		utils.WriteSuccessJSON(w, provider)
 	}
@@ -1168,6 +1422,7 @@ func fetchProviderDetail(ctx context.Context, deps *Dependencies, providerID uui
 	if lastHealthCheck.Valid {
 		provider["lastHealthCheck"] = lastHealthCheck.Time.Format(time.RFC3339)
 	}
+	provider["registration"] = providerRegistration(ctx, deps, providerID, code)
 
 	return provider, nil
 }
@@ -1188,6 +1443,10 @@ func HandleCreateProviderImpl(deps *Dependencies) http.HandlerFunc {
 			APIConfig         map[string]interface{} `json:"apiConfig"`
 			Mapping           map[string][]string    `json:"mapping"`
 			EnvCredentialKeys map[string]string      `json:"envCredentialKeys"`
+			Type              string                 `json:"type"`
+			Credentials       map[string]string      `json:"credentials"`
+			WebhookSecret     string                 `json:"webhookSecret"`
+			IsProduction      *bool                  `json:"isProduction"`
 		}
 
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -1196,6 +1455,7 @@ func HandleCreateProviderImpl(deps *Dependencies) http.HandlerFunc {
 		}
 
 		req.Code = strings.TrimSpace(strings.ToUpper(req.Code))
+		req.Type = strings.TrimSpace(strings.ToLower(req.Type))
 		req.Name = strings.TrimSpace(req.Name)
 		req.BaseURL = strings.TrimSpace(req.BaseURL)
 		req.WebhookURL = strings.TrimSpace(req.WebhookURL)
@@ -1213,6 +1473,11 @@ func HandleCreateProviderImpl(deps *Dependencies) http.HandlerFunc {
 		if len(req.SupportedTypes) == 0 {
 			validationErrors["supportedTypes"] = "supportedTypes is required"
 		}
+		if req.Type != "" {
+			if _, ok := provider.GetType(req.Type); !ok {
+				validationErrors["type"] = "Unknown provider type"
+			}
+		}
 		if len(validationErrors) > 0 {
 			utils.WriteValidationErrorJSON(w, "Validation failed", validationErrors)
 			return
@@ -1222,6 +1487,19 @@ func HandleCreateProviderImpl(deps *Dependencies) http.HandlerFunc {
 			req.SupportedTypes[i] = strings.ToUpper(strings.TrimSpace(req.SupportedTypes[i]))
 		}
 
+		var webhookSecret *string
+		if req.WebhookSecret != "" {
+			webhookSecret = &req.WebhookSecret
+		}
+		secrets, ok := sealProviderSecrets(w, deps, req.Type, req.Credentials, webhookSecret)
+		if !ok {
+			return
+		}
+		isProduction := true
+		if req.IsProduction != nil {
+			isProduction = *req.IsProduction
+		}
+
 		var exists bool
 		if err := deps.DB.Pool.QueryRow(ctx, `
 			SELECT EXISTS(SELECT 1 FROM providers WHERE code = $1)
@@ -1267,31 +1545,53 @@ func HandleCreateProviderImpl(deps *Dependencies) http.HandlerFunc {
 			INSERT INTO providers (
 				code, name, base_url, webhook_url,
 				is_active, priority, supported_types,
-				api_config, status_mapping, env_credential_keys
+				api_config, status_mapping, env_credential_keys,
+				provider_type, credentials, webhook_secret, is_production,
+				credentials_updated_at
 			)
-			VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, $9, $10)
+			VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, $9, $10,
+				NULLIF($11, ''), NULLIF($12, ''), NULLIF($13, ''), $14,
+				CASE WHEN NULLIF($12, '') IS NULL THEN NULL ELSE NOW() END)
 			RETURNING id
-		`, req.Code, req.Name, req.BaseURL, req.WebhookURL, req.IsActive, req.Priority, req.SupportedTypes, apiConfigJSON, mappingJSON, envKeysJSON).Scan(&id)
+		`, req.Code, req.Name, req.BaseURL, req.WebhookURL, req.IsActive, req.Priority, req.SupportedTypes, apiConfigJSON, mappingJSON, envKeysJSON,
+			req.Type, stringValue(secrets.Credentials), stringValue(secrets.WebhookSecret), isProduction).Scan(&id)
 		if err != nil {
 			utils.WriteInternalServerError(w)
 			return
 		}
 
-		var requiredEnvVars []string
-		for _, key := range req.EnvCredentialKeys {
-			if key != "" {
-				requiredEnvVars = append(requiredEnvVars, key)
+		response := map[string]interface{}{
+			"id":      id.String(),
+			"code":    req.Code,
+			"name":    req.Name,
+			"baseUrl": req.BaseURL,
+		}
+
+		if stringValue(secrets.Credentials) != "" {
+			response["message"] = "Provider created and registered"
+			if err := deps.ProviderRegistry.Changed(ctx, id.String(), req.Code); err != nil {
+				response["message"] = "Provider created but could not be registered"
+				response["registrationError"] = err.Error()
 			}
+
+			recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+				Action:      "CREATE",
+				Resource:    "PROVIDER",
+				ResourceID:  id.String(),
+				Description: "Created provider " + req.Code + " with stored credentials",
+			})
+		} else {
+			var requiredEnvVars []string
+			for _, key := range req.EnvCredentialKeys {
+				if key != "" {
+					requiredEnvVars = append(requiredEnvVars, key)
+				}
+			}
+			response["message"] = "Provider created. Please add credentials to .env file"
+			response["requiredEnvVars"] = requiredEnvVars
 		}
 
-		utils.WriteCreatedJSON(w, map[string]interface{}{
-			"id":              id.String(),
-			"code":            req.Code,
-			"name":            req.Name,
-			"baseUrl":         req.BaseURL,
-			"message":         "Provider created. Please add credentials to .env file",
-			"requiredEnvVars": requiredEnvVars,
-		})
+		utils.WriteCreatedJSON(w, response)
 	}
 }
 
@@ -1321,6 +1621,10 @@ func HandleUpdateProviderImpl(deps *Dependencies) http.HandlerFunc {
 			APIConfig         map[string]interface{} `json:"apiConfig"`
 			Mapping           map[string][]string    `json:"mapping"`
 			EnvCredentialKeys map[string]string      `json:"envCredentialKeys"`
+			Type              *string                `json:"type"`
+			Credentials       map[string]string      `json:"credentials"` // replaces the stored credentials, {} clears them
+			WebhookSecret     *string                `json:"webhookSecret"`
+			IsProduction      *bool                  `json:"isProduction"`
 		}
 
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -1328,6 +1632,31 @@ func HandleUpdateProviderImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		var code, providerType string
+		err = deps.DB.Pool.QueryRow(ctx, `
+			SELECT code, COALESCE(provider_type, '') FROM providers WHERE id = $1
+		`, providerUUID).Scan(&code, &providerType)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				utils.WriteErrorJSON(w, http.StatusNotFound, "PROVIDER_NOT_FOUND", "Provider not found", "")
+				return
+			}
+			utils.WriteInternalServerError(w)
+			return
+		}
+		if req.Type != nil {
+			providerType = strings.TrimSpace(strings.ToLower(*req.Type))
+			if _, ok := provider.GetType(providerType); providerType != "" && !ok {
+				utils.WriteValidationErrorJSON(w, "Validation failed", map[string]string{"type": "Unknown provider type"})
+				return
+			}
+		}
+
+		secrets, ok := sealProviderSecrets(w, deps, providerType, req.Credentials, req.WebhookSecret)
+		if !ok {
+			return
+		}
+
 		// Build update query dynamically
 		updates := []string{}
 		args := []interface{}{}
@@ -1397,12 +1726,42 @@ func HandleUpdateProviderImpl(deps *Dependencies) http.HandlerFunc {
 			args = append(args, envJSON)
 			argPos++
 		}
+		if req.Type != nil {
+			updates = append(updates, fmt.Sprintf("provider_type = NULLIF($%d, '')", argPos))
+			args = append(args, providerType)
+			argPos++
+		}
+		if secrets.Credentials != nil {
+			updates = append(updates, fmt.Sprintf("credentials = NULLIF($%d, '')", argPos), "credentials_updated_at = NOW()")
+			args = append(args, *secrets.Credentials)
+			argPos++
+		}
+		if secrets.WebhookSecret != nil {
+			updates = append(updates, fmt.Sprintf("webhook_secret = NULLIF($%d, '')", argPos))
+			args = append(args, *secrets.WebhookSecret)
+			argPos++
+		}
+		if req.IsProduction != nil {
+			updates = append(updates, fmt.Sprintf("is_production = $%d", argPos))
+			args = append(args, *req.IsProduction)
+			argPos++
+		}
 
 		if len(updates) == 0 {
 			utils.WriteBadRequestError(w, "No fields to update")
 			return
 		}
 
+		before, err := fetchProviderDetail(ctx, deps, providerUUID)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				utils.WriteErrorJSON(w, http.StatusNotFound, "PROVIDER_NOT_FOUND", "Provider not found", "")
+				return
+			}
+			utils.WriteInternalServerError(w)
+			return
+		}
+
 		updates = append(updates, "updated_at = NOW()")
 		args = append(args, providerUUID)
 
@@ -1424,12 +1783,35 @@ func HandleUpdateProviderImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		// Rebuild the running instance so changes apply without a restart
+		var registrationErr error
+		if deps.ProviderRegistry != nil {
+			registrationErr = deps.ProviderRegistry.Changed(ctx, id.String(), code)
+		}
+
 		detail, err := fetchProviderDetail(ctx, deps, id)
 		if err != nil {
 			utils.WriteInternalServerError(w)
 			return
 		}
 
+		description := "Updated provider " + code
+		if secrets.Credentials != nil || secrets.WebhookSecret != nil {
+			description += " and rotated its credentials"
+		}
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      services.AuditActionUpdate,
+			Resource:    "PROVIDER",
+			ResourceID:  id.String(),
+			Description: description,
+			Before:      before,
+			After:       detail,
+		})
+
+		if registrationErr != nil {
+			detail["registrationError"] = registrationErr.Error()
+		}
+
 		utils.WriteSuccessJSON(w, detail)
 	}
 }
@@ -1445,9 +1827,15 @@ func HandleDeleteProviderImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		providerUUID, err := uuid.Parse(providerId)
+		if err != nil {
+			utils.WriteBadRequestError(w, "Invalid provider ID")
+			return
+		}
+
 		// Check if provider has active SKUs
 		var skuCount int
-		err := deps.DB.Pool.QueryRow(ctx, `
+		err = deps.DB.Pool.QueryRow(ctx, `
 			SELECT COUNT(*) FROM skus WHERE provider_id = $1 AND is_active = true
 		`, providerId).Scan(&skuCount)
 		if err != nil {
@@ -1459,32 +1847,214 @@ func HandleDeleteProviderImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
-		// Delete provider
-		result, err := deps.DB.Pool.Exec(ctx, `
-			DELETE FROM providers WHERE id = $1
-		`, providerId)
+		before, err := fetchProviderDetail(ctx, deps, providerUUID)
 		if err != nil {
+			if err == pgx.ErrNoRows {
+				utils.WriteErrorJSON(w, http.StatusNotFound, "PROVIDER_NOT_FOUND", "Provider not found", "")
+				return
+			}
 			utils.WriteInternalServerError(w)
 			return
 		}
 
-		if result.RowsAffected() == 0 {
-			utils.WriteErrorJSON(w, http.StatusNotFound, "PROVIDER_NOT_FOUND", "Provider not found", "")
+		// Delete provider
+		var code string
+		err = deps.DB.Pool.QueryRow(ctx, `
+			DELETE FROM providers WHERE id = $1 RETURNING code
+		`, providerId).Scan(&code)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				utils.WriteErrorJSON(w, http.StatusNotFound, "PROVIDER_NOT_FOUND", "Provider not found", "")
+				return
+			}
+			utils.WriteInternalServerError(w)
 			return
 		}
 
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      services.AuditActionDelete,
+			Resource:    "PROVIDER",
+			ResourceID:  providerUUID.String(),
+			Description: "Deleted provider " + code,
+			Before:      before,
+		})
+
+		if deps.ProviderRegistry != nil {
+			deps.ProviderRegistry.Deleted(ctx, code)
+		}
+
 		utils.WriteSuccessJSON(w, map[string]string{"message": "Provider deleted successfully"})
 	}
 }
 
+// providerCheck is the outcome of one call made while testing a provider
+type providerCheck struct {
+	Name      string                  `json:"name"`
+	Status    string                  `json:"status"`
+	LatencyMs int64                   `json:"latencyMs"`
+	ErrorType string                  `json:"errorType,omitempty"`
+	Error     string                  `json:"error,omitempty"`
+	Result    interface{}             `json:"result,omitempty"`
+	HTTP      []provider.HTTPExchange `json:"http"`
+}
+
+// runProviderCheck calls fn with HTTP diagnostics enabled and records its latency and error
+func runProviderCheck(ctx context.Context, name string, fn func(ctx context.Context) (interface{}, error)) providerCheck {
+	diagCtx, diagnostics := provider.WithDiagnostics(ctx)
+
+	start := time.Now()
+	result, err := fn(diagCtx)
+	check := providerCheck{
+		Name:      name,
+		Status:    "SUCCESS",
+		LatencyMs: time.Since(start).Milliseconds(),
+		Result:    result,
+		HTTP:      diagnostics.Exchanges(),
+	}
+	if check.HTTP == nil {
+		check.HTTP = []provider.HTTPExchange{}
+	}
+	if err != nil {
+		check.Status = "FAILED"
+		check.ErrorType = provider.ClassifyError(err, check.HTTP)
+		check.Error = err.Error()
+	}
+	return check
+}
+
 func HandleTestProviderImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Placeholder - would need actual provider integration
+		ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+		defer cancel()
+
+		providerUUID, err := uuid.Parse(chi.URLParam(r, "providerId"))
+		if err != nil {
+			utils.WriteBadRequestError(w, "Invalid provider ID")
+			return
+		}
+
+		// Body is optional: {"sku": "ML86", "checkPrice": true}
+		var req struct {
+			SKU        string `json:"sku"`
+			CheckPrice *bool  `json:"checkPrice"`
+		}
+		if r.ContentLength > 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				utils.WriteBadRequestError(w, "Invalid request body")
+				return
+			}
+		}
+
+		var code, name string
+		err = deps.DB.Pool.QueryRow(ctx, `SELECT code, name FROM providers WHERE id = $1`, providerUUID).Scan(&code, &name)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				utils.WriteErrorJSON(w, http.StatusNotFound, "PROVIDER_NOT_FOUND", "Provider not found", "")
+				return
+			}
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		if deps.ProviderManager == nil {
+			utils.WriteErrorJSON(w, http.StatusServiceUnavailable, "PROVIDER_MANAGER_UNAVAILABLE", "Provider manager is not configured", "")
+			return
+		}
+		prov, err := deps.ProviderManager.Get(strings.ToLower(code))
+		if err != nil {
+			utils.WriteErrorJSON(w, http.StatusBadRequest, "PROVIDER_NOT_REGISTERED",
+				"Provider is not registered, check its credentials configuration", "")
+			return
+		}
+
+		checks := []providerCheck{
+			runProviderCheck(ctx, "healthCheck", func(ctx context.Context) (interface{}, error) {
+				return nil, prov.HealthCheck(ctx)
+			}),
+		}
+
+		var balance interface{}
+		balanceCheck := runProviderCheck(ctx, "getBalance", func(ctx context.Context) (interface{}, error) {
+			return prov.GetBalance(ctx)
+		})
+		if b, ok := balanceCheck.Result.(*provider.Balance); ok && b != nil {
+			balance = b.Balance
+			deps.ProviderManager.SetBalance(prov.GetName(), *b)
+		} else {
+			balanceCheck.Result = nil
+		}
+		checks = append(checks, balanceCheck)
+
+		sampleSKU := strings.TrimSpace(req.SKU)
+		if req.CheckPrice == nil || *req.CheckPrice {
+			if sampleSKU == "" {
+				_ = deps.DB.Pool.QueryRow(ctx, `
+					SELECT provider_sku_code FROM skus
+					WHERE provider_id = $1 AND is_active = true AND provider_sku_code <> ''
+					ORDER BY total_sold DESC
+					LIMIT 1
+				`, providerUUID).Scan(&sampleSKU)
+			}
+			if sampleSKU != "" {
+				priceCheck := runProviderCheck(ctx, "checkPrice", func(ctx context.Context) (interface{}, error) {
+					return prov.CheckPrice(ctx, sampleSKU)
+				})
+				if p, ok := priceCheck.Result.(*provider.PriceInfo); !ok || p == nil {
+					priceCheck.Result = nil
+				}
+				checks = append(checks, priceCheck)
+			}
+		}
+
+		var passed int
+		var totalLatency int64
+		for _, c := range checks {
+			if c.Status == "SUCCESS" {
+				passed++
+			}
+			totalLatency += c.LatencyMs
+		}
+
+		status, healthStatus, message := "SUCCESS", "HEALTHY", "Connection successful"
+		switch {
+		case passed == 0:
+			status, healthStatus, message = "FAILED", "UNHEALTHY", "All provider calls failed"
+		case passed < len(checks):
+			status, healthStatus, message = "PARTIAL", "DEGRADED", "Some provider calls failed"
+		}
+		for _, c := range checks {
+			if c.ErrorType == provider.DiagnosticSignature {
+				message = "Provider rejected the credentials or signature"
+				break
+			}
+		}
+
+		_, _ = deps.DB.Pool.Exec(ctx, `
+			UPDATE providers
+			SET health_status = $1::health_status, last_health_check = NOW(), avg_response_time = $2
+			WHERE id = $3
+		`, healthStatus, totalLatency/int64(len(checks)), providerUUID)
+
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      "UPDATE",
+			Resource:    "PROVIDER",
+			ResourceID:  providerUUID.String(),
+			Description: fmt.Sprintf("Tested provider %s: %s (%d/%d checks passed)", code, status, passed, len(checks)),
+		})
+
 		utils.WriteSuccessJSON(w, map[string]interface{}{
-			"status":       "SUCCESS",
-			"responseTime": 850,
-			"balance":      15000000,
-			"message":      "Connection successful",
+			"provider": map[string]interface{}{
+				"id":   providerUUID.String(),
+				"code": code,
+				"name": name,
+			},
+			"status":       status,
+			"responseTime": totalLatency,
+			"balance":      balance,
+			"sampleSku":    sampleSKU,
+			"checks":       checks,
+			"message":      message,
+			"testedAt":     time.Now().Format(time.RFC3339),
 		})
 	}
 }
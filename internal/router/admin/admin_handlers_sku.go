@@ -11,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"seaply/internal/services"
 	"seaply/internal/storage"
 	"seaply/internal/utils"
 
@@ -43,6 +44,7 @@ type skuPayload struct {
 	IsActive           *bool                        `json:"isActive"`
 	IsFeatured         *bool                        `json:"isFeatured"`
 	ProcessTime        *int                         `json:"processTime"`
+	MaxQuantity        *int                         `json:"maxQuantity"`
 	Info               string                       `json:"info"`
 	StockStatus        string                       `json:"stockStatus"`
 	Pricing            map[string]skuPricingPayload `json:"pricing"`
@@ -71,6 +73,7 @@ type skuRecord struct {
 	SectionCode        sql.NullString
 	SectionTitle       sql.NullString
 	ProcessTime        int
+	MaxQuantity        int
 	IsActive           bool
 	IsFeatured         bool
 	StockStatus        string
@@ -121,7 +124,7 @@ func HandleAdminGetSKUsImpl(deps *Dependencies) http.HandlerFunc {
 				p.id as product_id, p.code as product_code, p.title,
 				pr.id as provider_id, pr.code as provider_code, pr.name,
 				sc.id as section_id, sc.code as section_code, sc.title,
-				s.process_time, s.is_active, s.is_featured, s.stock_status,
+				s.process_time, s.max_quantity, s.is_active, s.is_featured, s.stock_status,
 				s.badge_text, s.badge_color,
 				s.total_sold, s.created_at, s.updated_at
 			FROM skus s
@@ -189,7 +192,7 @@ func HandleAdminGetSKUsImpl(deps *Dependencies) http.HandlerFunc {
 				&rec.ProductID, &rec.ProductCode, &rec.ProductTitle,
 				&rec.ProviderID, &rec.ProviderCode, &rec.ProviderName,
 				&rec.SectionID, &rec.SectionCode, &rec.SectionTitle,
-				&rec.ProcessTime, &rec.IsActive, &rec.IsFeatured, &rec.StockStatus,
+				&rec.ProcessTime, &rec.MaxQuantity, &rec.IsActive, &rec.IsFeatured, &rec.StockStatus,
 				&rec.BadgeText, &rec.BadgeColor,
 				&rec.TotalSold, &rec.CreatedAt, &rec.UpdatedAt,
 			); err != nil {
@@ -380,6 +383,14 @@ func HandleCreateSKUImpl(deps *Dependencies) http.HandlerFunc {
 		if payload.ProcessTime != nil {
 			processTime = *payload.ProcessTime
 		}
+		maxQuantity := 10
+		if payload.MaxQuantity != nil {
+			maxQuantity = *payload.MaxQuantity
+		}
+		if maxQuantity < 1 {
+			utils.WriteValidationErrorJSON(w, "Validation failed", map[string]string{"maxQuantity": "Max quantity must be at least 1"})
+			return
+		}
 		stockStatus := payload.StockStatus
 		if stockStatus == "" {
 			stockStatus = "AVAILABLE"
@@ -405,9 +416,9 @@ func HandleCreateSKUImpl(deps *Dependencies) http.HandlerFunc {
 				name, description, image, info,
 				product_id, provider_id, section_id,
 				process_time, is_active, is_featured, stock_status,
-				badge_text, badge_color
+				badge_text, badge_color, max_quantity
 			)
-			VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17)
+			VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18)
 			RETURNING id
 		`, payload.Code, payload.ProviderSku, nullString(payload.ProviderSkuBackup1), nullString(payload.ProviderSkuBackup2),
 			payload.Name, nullString(payload.Description), imageURL, nullString(payload.Info),
@@ -415,6 +426,7 @@ func HandleCreateSKUImpl(deps *Dependencies) http.HandlerFunc {
 			processTime, isActive, isFeatured, stockStatus,
 			nullStringFromPtr(payload.Badge, func(b *skuBadgePayload) string { return b.Text }),
 			nullStringFromPtr(payload.Badge, func(b *skuBadgePayload) string { return b.Color }),
+			maxQuantity,
 		).Scan(&skuID)
 		if err != nil {
 			utils.WriteInternalServerError(w)
@@ -426,6 +438,18 @@ func HandleCreateSKUImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		// The SKU's own provider codes are its first routes
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO sku_provider_mappings (sku_id, provider_id, provider_sku_code, priority)
+			SELECT $1, $2, code, priority
+			FROM (VALUES ($3::text, 1), ($4::text, 2), ($5::text, 3)) AS c(code, priority)
+			WHERE COALESCE(code, '') <> ''
+			ON CONFLICT DO NOTHING
+		`, skuID, providerID, payload.ProviderSku, nullString(payload.ProviderSkuBackup1), nullString(payload.ProviderSkuBackup2)); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
+
 		if err := tx.Commit(ctx); err != nil {
 			utils.WriteInternalServerError(w)
 			return
@@ -439,6 +463,14 @@ func HandleCreateSKUImpl(deps *Dependencies) http.HandlerFunc {
 		pricingMap := fetchSKUPricingMap(ctx, deps, []uuid.UUID{rec.ID})
 		stats := skuStatsRecord{TodaySold: 0, TotalSold: rec.TotalSold}
 
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      services.AuditActionCreate,
+			Resource:    "SKU",
+			ResourceID:  rec.ID.String(),
+			Description: "Created SKU " + rec.Code,
+			After:       buildSKUResponse(*rec, pricingMap[rec.ID], skuStatsRecord{}),
+		})
+
 		utils.WriteCreatedJSON(w, buildSKUResponse(*rec, pricingMap[rec.ID], stats))
 	}
 }
@@ -650,6 +682,17 @@ func HandleUpdateSKUImpl(deps *Dependencies) http.HandlerFunc {
 			}
 		}
 
+		if raw, ok := payload["maxQuantity"]; ok {
+			var value int
+			if err := json.Unmarshal(raw, &value); err != nil || value < 1 {
+				utils.WriteValidationErrorJSON(w, "Validation failed", map[string]string{"maxQuantity": "Max quantity must be at least 1"})
+				return
+			}
+			updates = append(updates, fmt.Sprintf("max_quantity = $%d", argPos))
+			args = append(args, value)
+			argPos++
+		}
+
 		if raw, ok := payload["badge"]; ok {
 			var badge skuBadgePayload
 			if err := json.Unmarshal(raw, &badge); err == nil {
@@ -710,6 +753,8 @@ func HandleUpdateSKUImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		before := buildSKUResponse(*rec, fetchSKUPricingMap(ctx, deps, []uuid.UUID{rec.ID})[rec.ID], skuStatsRecord{})
+
 		updates = append(updates, "updated_at = NOW()")
 		args = append(args, rec.ID)
 
@@ -728,6 +773,18 @@ func HandleUpdateSKUImpl(deps *Dependencies) http.HandlerFunc {
 			}
 		}
 
+		// Keep the route of the SKU's own provider code in sync
+		if newProviderSku != "" || providerChanged {
+			if _, err := tx.Exec(ctx, `
+				UPDATE sku_provider_mappings
+				SET provider_id = $1, provider_sku_code = COALESCE(NULLIF($2, ''), provider_sku_code), updated_at = NOW()
+				WHERE sku_id = $3 AND provider_id = $4 AND provider_sku_code = $5
+			`, finalProviderID, newProviderSku, rec.ID, rec.ProviderID, rec.ProviderSku); err != nil {
+				utils.WriteInternalServerError(w)
+				return
+			}
+		}
+
 		if len(pricingUpdates) > 0 {
 			regions := normalizeStringSlice(mapKeys(pricingUpdates))
 			if err := validateRegions(ctx, deps, regions); err != nil {
@@ -771,6 +828,15 @@ func HandleUpdateSKUImpl(deps *Dependencies) http.HandlerFunc {
 			TotalSold: updated.TotalSold,
 		}
 
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      services.AuditActionUpdate,
+			Resource:    "SKU",
+			ResourceID:  updated.ID.String(),
+			Description: "Updated SKU " + updated.Code,
+			Before:      before,
+			After:       buildSKUResponse(*updated, pricingMap[updated.ID], skuStatsRecord{}),
+		})
+
 		utils.WriteSuccessJSON(w, buildSKUResponse(*updated, pricingMap[updated.ID], stats))
 	}
 }
@@ -808,11 +874,20 @@ func HandleDeleteSKUImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		before := buildSKUResponse(*rec, fetchSKUPricingMap(ctx, deps, []uuid.UUID{rec.ID})[rec.ID], skuStatsRecord{})
 		if _, err := deps.DB.Pool.Exec(ctx, `DELETE FROM skus WHERE id = $1`, rec.ID); err != nil {
 			utils.WriteInternalServerError(w)
 			return
 		}
 
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      services.AuditActionDelete,
+			Resource:    "SKU",
+			ResourceID:  rec.ID.String(),
+			Description: "Deleted SKU " + rec.Code,
+			Before:      before,
+		})
+
 		if rec.Image.Valid {
 			deleteS3Object(ctx, deps, rec.Image.String)
 		}
@@ -847,6 +922,7 @@ func HandleBulkUpdatePriceImpl(deps *Dependencies) http.HandlerFunc {
 		}
 
 		updatedCount := 0
+		entries := []services.AuditEntry{}
 		err := deps.DB.WithTransaction(ctx, func(tx pgx.Tx) error {
 			for _, item := range req.SKUs {
 				code := strings.TrimSpace(item.Code)
@@ -865,6 +941,8 @@ func HandleBulkUpdatePriceImpl(deps *Dependencies) http.HandlerFunc {
 					return err
 				}
 
+				before := map[string]interface{}{}
+				after := map[string]interface{}{}
 				for region, price := range item.Pricing {
 					normalizedRegion := strings.ToUpper(strings.TrimSpace(region))
 					if normalizedRegion == "" {
@@ -879,18 +957,37 @@ func HandleBulkUpdatePriceImpl(deps *Dependencies) http.HandlerFunc {
 						return fmt.Errorf("Invalid originalPrice for SKU %s (%s): %v", code, normalizedRegion, err)
 					}
 
-					cmd, err := tx.Exec(ctx, `
+					var previousSell, previousOriginal int64
+					err = tx.QueryRow(ctx, `
+						SELECT sell_price, original_price FROM sku_pricing
+						WHERE sku_id = $1 AND region_code = $2
+						FOR UPDATE
+					`, skuID, normalizedRegion).Scan(&previousSell, &previousOriginal)
+					if errors.Is(err, pgx.ErrNoRows) {
+						return fmt.Errorf("Pricing for region %s not found on SKU %s", normalizedRegion, code)
+					}
+					if err != nil {
+						return err
+					}
+
+					if _, err := tx.Exec(ctx, `
 						UPDATE sku_pricing
 						SET sell_price = $1, original_price = $2, updated_at = NOW()
 						WHERE sku_id = $3 AND region_code = $4
-					`, sell, original, skuID, normalizedRegion)
-					if err != nil {
+					`, sell, original, skuID, normalizedRegion); err != nil {
 						return err
 					}
-					if cmd.RowsAffected() == 0 {
-						return fmt.Errorf("Pricing for region %s not found on SKU %s", normalizedRegion, code)
-					}
+					before[normalizedRegion] = map[string]int64{"sellPrice": previousSell, "originalPrice": previousOriginal}
+					after[normalizedRegion] = map[string]int64{"sellPrice": sell, "originalPrice": original}
 				}
+				entries = append(entries, services.AuditEntry{
+					Action:      services.AuditActionUpdate,
+					Resource:    "SKU",
+					ResourceID:  skuID.String(),
+					Description: "Bulk updated prices of SKU " + code,
+					Before:      map[string]interface{}{"pricing": before},
+					After:       map[string]interface{}{"pricing": after},
+				})
 				updatedCount++
 			}
 			return nil
@@ -901,6 +998,10 @@ func HandleBulkUpdatePriceImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		for _, entry := range entries {
+			recordAudit(ctx, deps, deps.DB.Pool, r, entry)
+		}
+
 		utils.WriteSuccessJSON(w, map[string]interface{}{
 			"updatedSkus": updatedCount,
 			"message":     "Sku prices updated successfully",
@@ -1084,6 +1185,7 @@ func buildSKUResponse(rec skuRecord, pricing []skuPricingRecord, stats skuStatsR
 		"providerSkuCode": rec.ProviderSku,
 		"name":            rec.Name,
 		"processTime":     rec.ProcessTime,
+		"maxQuantity":     rec.MaxQuantity,
 		"isActive":        rec.IsActive,
 		"isFeatured":      rec.IsFeatured,
 		"stockStatus":     rec.StockStatus,
@@ -1155,7 +1257,7 @@ func loadSKUByIdentifier(ctx context.Context, deps *Dependencies, identifier str
 			p.id as product_id, p.code as product_code, p.title,
 			pr.id as provider_id, pr.code as provider_code, pr.name,
 			sc.id as section_id, sc.code as section_code, sc.title,
-			s.process_time, s.is_active, s.is_featured, s.stock_status,
+			s.process_time, s.max_quantity, s.is_active, s.is_featured, s.stock_status,
 			s.badge_text, s.badge_color,
 			s.total_sold, s.created_at, s.updated_at
 		FROM skus s
@@ -1170,7 +1272,7 @@ func loadSKUByIdentifier(ctx context.Context, deps *Dependencies, identifier str
 		&rec.ProductID, &rec.ProductCode, &rec.ProductTitle,
 		&rec.ProviderID, &rec.ProviderCode, &rec.ProviderName,
 		&rec.SectionID, &rec.SectionCode, &rec.SectionTitle,
-		&rec.ProcessTime, &rec.IsActive, &rec.IsFeatured, &rec.StockStatus,
+		&rec.ProcessTime, &rec.MaxQuantity, &rec.IsActive, &rec.IsFeatured, &rec.StockStatus,
 		&rec.BadgeText, &rec.BadgeColor,
 		&rec.TotalSold, &rec.CreatedAt, &rec.UpdatedAt,
 	); err != nil {
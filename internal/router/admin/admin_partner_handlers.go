@@ -0,0 +1,496 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"seaply/internal/middleware"
+	"seaply/internal/services"
+	"seaply/internal/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// ============================================
+// ADMIN PARTNER API KEYS
+// ============================================
+
+const partnerKeyColumns = `
+	k.id, k.name, k.api_key, k.secret_hint, k.callback_url, k.ip_whitelist, k.is_active,
+	k.last_used_at, k.last_used_ip, k.revoked_at, k.created_at, k.updated_at,
+	u.id, TRIM(u.first_name || ' ' || COALESCE(u.last_name, '')), u.email
+`
+
+// scanPartnerKey scans a row selected with partnerKeyColumns. The secret itself is never returned.
+func scanPartnerKey(row pgx.Row) (map[string]interface{}, error) {
+	var id, name, apiKey, secretHint string
+	var callbackURL, lastUsedIP *string
+	var ipWhitelist []string
+	var isActive bool
+	var lastUsedAt, revokedAt *time.Time
+	var createdAt, updatedAt time.Time
+	var userID, userName, userEmail string
+
+	err := row.Scan(
+		&id, &name, &apiKey, &secretHint, &callbackURL, &ipWhitelist, &isActive,
+		&lastUsedAt, &lastUsedIP, &revokedAt, &createdAt, &updatedAt,
+		&userID, &userName, &userEmail,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	status := "ACTIVE"
+	if revokedAt != nil {
+		status = "REVOKED"
+	} else if !isActive {
+		status = "INACTIVE"
+	}
+
+	key := map[string]interface{}{
+		"id":          id,
+		"name":        name,
+		"apiKey":      apiKey,
+		"secretHint":  "••••" + secretHint,
+		"callbackUrl": callbackURL,
+		"ipWhitelist": ipWhitelist,
+		"status":      status,
+		"lastUsedAt":  nil,
+		"lastUsedIp":  lastUsedIP,
+		"revokedAt":   nil,
+		"user": map[string]interface{}{
+			"id":    userID,
+			"name":  userName,
+			"email": userEmail,
+		},
+		"createdAt": createdAt.Format(time.RFC3339),
+		"updatedAt": updatedAt.Format(time.RFC3339),
+	}
+	if lastUsedAt != nil {
+		key["lastUsedAt"] = lastUsedAt.Format(time.RFC3339)
+	}
+	if revokedAt != nil {
+		key["revokedAt"] = revokedAt.Format(time.RFC3339)
+	}
+	return key, nil
+}
+
+// validatePartnerKeySettings checks the callback URL and IP whitelist of a partner key
+func validatePartnerKeySettings(ctx context.Context, callbackURL string, ipWhitelist []string, errs map[string]string) {
+	if callbackURL != "" {
+		if err := utils.ValidateOutboundURL(ctx, callbackURL); err != nil {
+			errs["callbackUrl"] = "Callback URL must be a public https URL"
+		}
+	}
+	for _, entry := range ipWhitelist {
+		if _, _, err := net.ParseCIDR(entry); err == nil {
+			continue
+		}
+		if net.ParseIP(entry) == nil {
+			errs["ipWhitelist"] = "Invalid IP address or CIDR: " + entry
+			return
+		}
+	}
+}
+
+func loadPartnerKey(ctx context.Context, deps *Dependencies, id string) (map[string]interface{}, error) {
+	return scanPartnerKey(deps.DB.Pool.QueryRow(ctx, `
+		SELECT `+partnerKeyColumns+`
+		FROM partner_api_keys k
+		JOIN users u ON u.id = k.user_id
+		WHERE k.id::text = $1
+	`, id))
+}
+
+// HandleGetPartnerKeysImpl lists the partner API keys with filters
+func HandleGetPartnerKeysImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit <= 0 || limit > 100 {
+			limit = 10
+		}
+
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page <= 0 {
+			page = 1
+		}
+
+		userID := r.URL.Query().Get("userId")
+		status := r.URL.Query().Get("status")
+		search := r.URL.Query().Get("search")
+
+		offset := (page - 1) * limit
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		where := " WHERE 1=1"
+		args := []interface{}{}
+		argCount := 0
+
+		if userID != "" {
+			argCount++
+			where += " AND k.user_id::text = $" + strconv.Itoa(argCount)
+			args = append(args, userID)
+		}
+		switch status {
+		case "ACTIVE":
+			where += " AND k.revoked_at IS NULL AND k.is_active = true"
+		case "INACTIVE":
+			where += " AND k.revoked_at IS NULL AND k.is_active = false"
+		case "REVOKED":
+			where += " AND k.revoked_at IS NOT NULL"
+		}
+		if search != "" {
+			argCount++
+			where += " AND (k.name ILIKE $" + strconv.Itoa(argCount) + " OR k.api_key ILIKE $" + strconv.Itoa(argCount) +
+				" OR u.email ILIKE $" + strconv.Itoa(argCount) + ")"
+			args = append(args, "%"+search+"%")
+		}
+
+		var totalRows int
+		err := deps.DB.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM partner_api_keys k JOIN users u ON u.id = k.user_id"+where, args...).Scan(&totalRows)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to count partner keys")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		query := "SELECT " + partnerKeyColumns + " FROM partner_api_keys k JOIN users u ON u.id = k.user_id" + where
+		query += " ORDER BY k.created_at DESC"
+		argCount++
+		query += " LIMIT $" + strconv.Itoa(argCount)
+		args = append(args, limit)
+		argCount++
+		query += " OFFSET $" + strconv.Itoa(argCount)
+		args = append(args, offset)
+
+		rows, err := deps.DB.Pool.Query(ctx, query, args...)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to query partner keys")
+			utils.WriteInternalServerError(w)
+			return
+		}
+		defer rows.Close()
+
+		keys := []map[string]interface{}{}
+		for rows.Next() {
+			key, err := scanPartnerKey(rows)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to scan partner key")
+				continue
+			}
+			keys = append(keys, key)
+		}
+
+		totalPages := (totalRows + limit - 1) / limit
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"keys": keys,
+			"pagination": map[string]interface{}{
+				"limit":      limit,
+				"page":       page,
+				"totalRows":  totalRows,
+				"totalPages": totalPages,
+			},
+		})
+	}
+}
+
+// CreatePartnerKeyRequest represents the request to create a partner API key
+type CreatePartnerKeyRequest struct {
+	UserID      string   `json:"userId"`
+	Name        string   `json:"name"`
+	CallbackURL string   `json:"callbackUrl"`
+	IPWhitelist []string `json:"ipWhitelist"`
+}
+
+// HandleCreatePartnerKeyImpl creates an API key for a reseller account. The secret
+// is only returned in this response.
+func HandleCreatePartnerKeyImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		adminID := middleware.GetAdminIDFromContext(r.Context())
+
+		if deps.PartnerService == nil {
+			utils.WriteErrorJSON(w, http.StatusServiceUnavailable, "PARTNER_API_UNAVAILABLE",
+				"Partner API is not available", "")
+			return
+		}
+
+		var req CreatePartnerKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.WriteBadRequestError(w, "Invalid request body")
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if req.IPWhitelist == nil {
+			req.IPWhitelist = []string{}
+		}
+
+		errs := map[string]string{}
+		if req.UserID == "" {
+			errs["userId"] = "User ID is required"
+		}
+		if req.Name == "" {
+			errs["name"] = "Name is required"
+		}
+		validatePartnerKeySettings(r.Context(), req.CallbackURL, req.IPWhitelist, errs)
+		if len(errs) > 0 {
+			utils.WriteValidationErrorJSON(w, "Validation failed", errs)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		var userStatus string
+		err := deps.DB.Pool.QueryRow(ctx, `SELECT status::text FROM users WHERE id::text = $1`, req.UserID).Scan(&userStatus)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				utils.WriteNotFoundError(w, "User")
+				return
+			}
+			utils.WriteInternalServerError(w)
+			return
+		}
+		if userStatus != "ACTIVE" {
+			utils.WriteErrorJSON(w, http.StatusBadRequest, "USER_NOT_ACTIVE",
+				"User account is not active", "")
+			return
+		}
+
+		apiKey, secret, encryptedSecret, hint, err := deps.PartnerService.GenerateKey()
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to generate partner key")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		var callbackURL *string
+		if req.CallbackURL != "" {
+			callbackURL = &req.CallbackURL
+		}
+
+		var id string
+		err = deps.DB.Pool.QueryRow(ctx, `
+			INSERT INTO partner_api_keys (user_id, name, api_key, secret, secret_hint, callback_url, ip_whitelist, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING id
+		`, req.UserID, req.Name, apiKey, encryptedSecret, hint, callbackURL, req.IPWhitelist, adminID).Scan(&id)
+		if err != nil {
+			log.Error().Err(err).Str("user_id", req.UserID).Msg("Failed to create partner key")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      "CREATE",
+			Resource:    "PARTNER_KEY",
+			ResourceID:  id,
+			Description: "Created partner API key " + req.Name + " (" + apiKey + ")",
+		})
+
+		key, err := loadPartnerKey(ctx, deps, id)
+		if err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
+		key["secret"] = secret
+
+		utils.WriteCreatedJSON(w, key)
+	}
+}
+
+// UpdatePartnerKeyRequest represents the request to update a partner API key
+type UpdatePartnerKeyRequest struct {
+	Name        *string   `json:"name"`
+	CallbackURL *string   `json:"callbackUrl"`
+	IPWhitelist *[]string `json:"ipWhitelist"`
+	IsActive    *bool     `json:"isActive"`
+}
+
+// HandleUpdatePartnerKeyImpl updates the settings of a partner API key
+func HandleUpdatePartnerKeyImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "keyId")
+
+		var req UpdatePartnerKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.WriteBadRequestError(w, "Invalid request body")
+			return
+		}
+
+		errs := map[string]string{}
+		if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+			errs["name"] = "Name cannot be empty"
+		}
+		var callbackURL string
+		if req.CallbackURL != nil {
+			callbackURL = *req.CallbackURL
+		}
+		var ipWhitelist []string
+		if req.IPWhitelist != nil {
+			ipWhitelist = *req.IPWhitelist
+		}
+		validatePartnerKeySettings(r.Context(), callbackURL, ipWhitelist, errs)
+		if len(errs) > 0 {
+			utils.WriteValidationErrorJSON(w, "Validation failed", errs)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		set := []string{}
+		args := []interface{}{}
+		if req.Name != nil {
+			args = append(args, strings.TrimSpace(*req.Name))
+			set = append(set, "name = $"+strconv.Itoa(len(args)))
+		}
+		if req.CallbackURL != nil {
+			args = append(args, nullString(callbackURL))
+			set = append(set, "callback_url = $"+strconv.Itoa(len(args)))
+		}
+		if req.IPWhitelist != nil {
+			if ipWhitelist == nil {
+				ipWhitelist = []string{}
+			}
+			args = append(args, ipWhitelist)
+			set = append(set, "ip_whitelist = $"+strconv.Itoa(len(args)))
+		}
+		if req.IsActive != nil {
+			args = append(args, *req.IsActive)
+			set = append(set, "is_active = $"+strconv.Itoa(len(args)))
+		}
+		if len(set) == 0 {
+			utils.WriteBadRequestError(w, "No fields to update")
+			return
+		}
+
+		args = append(args, id)
+		tag, err := deps.DB.Pool.Exec(ctx, `
+			UPDATE partner_api_keys SET `+strings.Join(set, ", ")+`, updated_at = NOW()
+			WHERE id::text = $`+strconv.Itoa(len(args))+` AND revoked_at IS NULL
+		`, args...)
+		if err != nil {
+			log.Error().Err(err).Str("key_id", id).Msg("Failed to update partner key")
+			utils.WriteInternalServerError(w)
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			utils.WriteNotFoundError(w, "Partner key")
+			return
+		}
+
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      "UPDATE",
+			Resource:    "PARTNER_KEY",
+			ResourceID:  id,
+			Description: "Updated partner API key settings",
+		})
+
+		key, err := loadPartnerKey(ctx, deps, id)
+		if err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
+		utils.WriteSuccessJSON(w, key)
+	}
+}
+
+// HandleRotatePartnerSecretImpl replaces the secret of a partner API key. Requests
+// signed with the old secret are rejected as soon as this returns.
+func HandleRotatePartnerSecretImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "keyId")
+
+		if deps.PartnerService == nil {
+			utils.WriteErrorJSON(w, http.StatusServiceUnavailable, "PARTNER_API_UNAVAILABLE",
+				"Partner API is not available", "")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		secret, encryptedSecret, hint, err := deps.PartnerService.GenerateSecret()
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to generate partner secret")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		tag, err := deps.DB.Pool.Exec(ctx, `
+			UPDATE partner_api_keys SET secret = $1, secret_hint = $2, updated_at = NOW()
+			WHERE id::text = $3 AND revoked_at IS NULL
+		`, encryptedSecret, hint, id)
+		if err != nil {
+			log.Error().Err(err).Str("key_id", id).Msg("Failed to rotate partner secret")
+			utils.WriteInternalServerError(w)
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			utils.WriteNotFoundError(w, "Partner key")
+			return
+		}
+
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      "ROTATE_SECRET",
+			Resource:    "PARTNER_KEY",
+			ResourceID:  id,
+			Description: "Rotated partner API key secret",
+		})
+
+		key, err := loadPartnerKey(ctx, deps, id)
+		if err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
+		key["secret"] = secret
+
+		utils.WriteSuccessJSON(w, key)
+	}
+}
+
+// HandleRevokePartnerKeyImpl permanently revokes a partner API key
+func HandleRevokePartnerKeyImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "keyId")
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		tag, err := deps.DB.Pool.Exec(ctx, `
+			UPDATE partner_api_keys SET is_active = false, revoked_at = NOW(), updated_at = NOW()
+			WHERE id::text = $1 AND revoked_at IS NULL
+		`, id)
+		if err != nil {
+			log.Error().Err(err).Str("key_id", id).Msg("Failed to revoke partner key")
+			utils.WriteInternalServerError(w)
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			utils.WriteNotFoundError(w, "Partner key")
+			return
+		}
+
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      "REVOKE",
+			Resource:    "PARTNER_KEY",
+			ResourceID:  id,
+			Description: "Revoked partner API key",
+		})
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"message": "Partner API key revoked",
+		})
+	}
+}
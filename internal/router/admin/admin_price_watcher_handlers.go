@@ -0,0 +1,205 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"seaply/internal/services"
+	"seaply/internal/utils"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ============================================
+// ADMIN PRICE WATCHER
+// ============================================
+
+// HandleGetPriceHistoryImpl returns provider cost changes recorded by the price watcher
+func HandleGetPriceHistoryImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit <= 0 || limit > 100 {
+			limit = 10
+		}
+
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page <= 0 {
+			page = 1
+		}
+
+		skuID := r.URL.Query().Get("skuId")
+		skuCode := r.URL.Query().Get("skuCode")
+		action := r.URL.Query().Get("action")
+		region := r.URL.Query().Get("region")
+
+		offset := (page - 1) * limit
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		where := " WHERE 1=1"
+		args := []interface{}{}
+		argCount := 0
+
+		if skuID != "" {
+			argCount++
+			where += " AND h.sku_id::text = $" + strconv.Itoa(argCount)
+			args = append(args, skuID)
+		}
+		if skuCode != "" {
+			argCount++
+			where += " AND s.code = $" + strconv.Itoa(argCount)
+			args = append(args, skuCode)
+		}
+		if action != "" {
+			argCount++
+			where += " AND h.action = $" + strconv.Itoa(argCount)
+			args = append(args, action)
+		}
+		if region != "" {
+			argCount++
+			where += " AND h.region_code = $" + strconv.Itoa(argCount)
+			args = append(args, region)
+		}
+
+		from := " FROM sku_price_history h JOIN skus s ON h.sku_id = s.id LEFT JOIN providers p ON h.provider_id = p.id"
+
+		var totalRows int
+		err := deps.DB.Pool.QueryRow(ctx, "SELECT COUNT(*)"+from+where, args...).Scan(&totalRows)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to count price history")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		query := `SELECT h.id, s.id, s.code, s.name, COALESCE(p.code, ''), h.region_code, h.currency,
+			h.old_buy_price, h.new_buy_price, h.old_sell_price, h.new_sell_price,
+			h.change_percent::float8, h.action, h.message, h.created_at` + from + where
+		query += " ORDER BY h.created_at DESC"
+		argCount++
+		query += " LIMIT $" + strconv.Itoa(argCount)
+		args = append(args, limit)
+		argCount++
+		query += " OFFSET $" + strconv.Itoa(argCount)
+		args = append(args, offset)
+
+		rows, err := deps.DB.Pool.Query(ctx, query, args...)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to query price history")
+			utils.WriteInternalServerError(w)
+			return
+		}
+		defer rows.Close()
+
+		history := []map[string]interface{}{}
+		for rows.Next() {
+			var id, historySKUID, historySKUCode, skuName, providerCode, regionCode, currency, historyAction string
+			var oldBuy, newBuy, oldSell, newSell int64
+			var changePercent float64
+			var message *string
+			var createdAt time.Time
+
+			if err := rows.Scan(&id, &historySKUID, &historySKUCode, &skuName, &providerCode, &regionCode, &currency,
+				&oldBuy, &newBuy, &oldSell, &newSell,
+				&changePercent, &historyAction, &message, &createdAt); err != nil {
+				log.Error().Err(err).Msg("Failed to scan price history")
+				continue
+			}
+
+			history = append(history, map[string]interface{}{
+				"id": id,
+				"sku": map[string]interface{}{
+					"id":   historySKUID,
+					"code": historySKUCode,
+					"name": skuName,
+				},
+				"provider":      providerCode,
+				"region":        regionCode,
+				"currency":      currency,
+				"oldBuyPrice":   oldBuy,
+				"newBuyPrice":   newBuy,
+				"oldSellPrice":  oldSell,
+				"newSellPrice":  newSell,
+				"changePercent": changePercent,
+				"action":        historyAction,
+				"message":       message,
+				"createdAt":     createdAt.Format(time.RFC3339),
+			})
+		}
+
+		totalPages := (totalRows + limit - 1) / limit
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"history": history,
+			"pagination": map[string]interface{}{
+				"limit":      limit,
+				"page":       page,
+				"totalRows":  totalRows,
+				"totalPages": totalPages,
+			},
+		})
+	}
+}
+
+// HandleGetPriceWatcherImpl returns the watcher settings and the result of its last run
+func HandleGetPriceWatcherImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if deps.PriceWatcher == nil {
+			utils.WriteErrorJSON(w, http.StatusServiceUnavailable, "PRICE_WATCHER_UNAVAILABLE",
+				"Price watcher is not configured", "")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		settings, err := deps.PriceWatcher.LoadSettings(ctx)
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to load price watcher settings")
+		}
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"settings": map[string]interface{}{
+				"enabled":        settings.Enabled,
+				"interval":       int(settings.Interval.Minutes()),
+				"marginRule":     settings.MarginRule,
+				"autoAdjust":     settings.AutoAdjust,
+				"autoDisable":    settings.AutoDisable,
+				"alertThreshold": settings.AlertThreshold,
+				"alertEmail":     settings.AlertEmail,
+			},
+			"lastRun": deps.PriceWatcher.LastRun(),
+		})
+	}
+}
+
+// HandleRunPriceWatcherImpl runs the price watcher immediately
+func HandleRunPriceWatcherImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if deps.PriceWatcher == nil {
+			utils.WriteErrorJSON(w, http.StatusServiceUnavailable, "PRICE_WATCHER_UNAVAILABLE",
+				"Price watcher is not configured", "")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Minute)
+		defer cancel()
+
+		result, err := deps.PriceWatcher.Run(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("Manual price watcher run failed")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      "UPDATE",
+			Resource:    "SKU",
+			Description: "Ran price watcher: " + strconv.Itoa(result.Changed) + " price(s) changed",
+		})
+
+		utils.WriteSuccessJSON(w, result)
+	}
+}
@@ -11,7 +11,7 @@ import (
 	"strings"
 	"time"
 
-	"seaply/internal/middleware"
+	"seaply/internal/services"
 	"seaply/internal/storage"
 	"seaply/internal/utils"
 
@@ -210,7 +210,6 @@ type CreatePromoRequest struct {
 // handleCreatePromoImpl creates a new promo
 func HandleCreatePromoImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		var req CreatePromoRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -319,10 +318,16 @@ func HandleCreatePromoImpl(deps *Dependencies) http.HandlerFunc {
 		}
 
 		// Create audit log
-		tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'CREATE', 'PROMO', $2, $3, NOW())
-		`, adminID, promoID, "Created promo "+req.Code)
+		if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+			Action:      "CREATE",
+			Resource:    "PROMO",
+			ResourceID:  promoID,
+			Description: "Created promo " + req.Code,
+			After:       auditSnapshot(ctx, deps, tx, "promos", promoID),
+		}); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
 
 		if err := tx.Commit(ctx); err != nil {
 			fmt.Printf("Error committing transaction: %v\n", err)
@@ -342,7 +347,6 @@ func HandleCreatePromoImpl(deps *Dependencies) http.HandlerFunc {
 func HandleUpdatePromoImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		promoID := chi.URLParam(r, "promoId")
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		var req CreatePromoRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -361,6 +365,8 @@ func HandleUpdatePromoImpl(deps *Dependencies) http.HandlerFunc {
 		}
 		defer tx.Rollback(ctx)
 
+		before := auditSnapshot(ctx, deps, tx, "promos", promoID)
+
 		// Update promo
 		_, err = tx.Exec(ctx, `
 			UPDATE promos SET
@@ -398,10 +404,17 @@ func HandleUpdatePromoImpl(deps *Dependencies) http.HandlerFunc {
 		}
 
 		// Create audit log
-		tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'UPDATE', 'PROMO', $2, $3, NOW())
-		`, adminID, promoID, "Updated promo "+req.Code)
+		if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+			Action:      "UPDATE",
+			Resource:    "PROMO",
+			ResourceID:  promoID,
+			Description: "Updated promo " + req.Code,
+			Before:      before,
+			After:       auditSnapshot(ctx, deps, tx, "promos", promoID),
+		}); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
 
 		if err := tx.Commit(ctx); err != nil {
 			utils.WriteInternalServerError(w)
@@ -418,7 +431,6 @@ func HandleUpdatePromoImpl(deps *Dependencies) http.HandlerFunc {
 func HandleDeletePromoImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		promoID := chi.URLParam(r, "promoId")
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 		defer cancel()
@@ -430,6 +442,8 @@ func HandleDeletePromoImpl(deps *Dependencies) http.HandlerFunc {
 		}
 		defer tx.Rollback(ctx)
 
+		before := auditSnapshot(ctx, deps, tx, "promos", promoID)
+
 		// Delete associations
 		tx.Exec(ctx, "DELETE FROM promo_products WHERE promo_id = $1", promoID)
 		tx.Exec(ctx, "DELETE FROM promo_payment_channels WHERE promo_id = $1", promoID)
@@ -443,10 +457,16 @@ func HandleDeletePromoImpl(deps *Dependencies) http.HandlerFunc {
 		}
 
 		// Create audit log
-		tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'DELETE', 'PROMO', $2, 'Deleted promo', NOW())
-		`, adminID, promoID)
+		if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+			Action:      "DELETE",
+			Resource:    "PROMO",
+			ResourceID:  promoID,
+			Description: "Deleted promo",
+			Before:      before,
+		}); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
 
 		if err := tx.Commit(ctx); err != nil {
 			utils.WriteInternalServerError(w)
@@ -565,7 +585,6 @@ type CreateBannerRequest struct {
 // handleCreateBannerImpl creates a new banner
 func HandleCreateBannerImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 		defer cancel()
@@ -688,10 +707,16 @@ func HandleCreateBannerImpl(deps *Dependencies) http.HandlerFunc {
 			}
 		}
 
-		tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'CREATE', 'BANNER', $2, 'Created banner', NOW())
-		`, adminID, bannerID)
+		if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+			Action:      "CREATE",
+			Resource:    "BANNER",
+			ResourceID:  bannerID,
+			Description: "Created banner",
+			After:       auditSnapshot(ctx, deps, tx, "banners", bannerID),
+		}); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
 
 		if err := tx.Commit(ctx); err != nil {
 			utils.WriteInternalServerError(w)
@@ -709,7 +734,6 @@ func HandleCreateBannerImpl(deps *Dependencies) http.HandlerFunc {
 func HandleUpdateBannerImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		bannerID := chi.URLParam(r, "bannerId")
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 		defer cancel()
@@ -831,6 +855,8 @@ func HandleUpdateBannerImpl(deps *Dependencies) http.HandlerFunc {
 		}
 		defer tx.Rollback(ctx)
 
+		before := auditSnapshot(ctx, deps, tx, "banners", bannerID)
+
 		_, err = tx.Exec(ctx, `
 			UPDATE banners SET
 				title = $1, description = $2, href = $3, image = $4,
@@ -865,10 +891,17 @@ func HandleUpdateBannerImpl(deps *Dependencies) http.HandlerFunc {
 			}
 		}
 
-		tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'UPDATE', 'BANNER', $2, 'Updated banner', NOW())
-		`, adminID, bannerID)
+		if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+			Action:      "UPDATE",
+			Resource:    "BANNER",
+			ResourceID:  bannerID,
+			Description: "Updated banner",
+			Before:      before,
+			After:       auditSnapshot(ctx, deps, tx, "banners", bannerID),
+		}); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
 
 		if err := tx.Commit(ctx); err != nil {
 			utils.WriteInternalServerError(w)
@@ -885,7 +918,6 @@ func HandleUpdateBannerImpl(deps *Dependencies) http.HandlerFunc {
 func HandleDeleteBannerImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		bannerID := chi.URLParam(r, "bannerId")
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 		defer cancel()
@@ -897,16 +929,24 @@ func HandleDeleteBannerImpl(deps *Dependencies) http.HandlerFunc {
 		}
 		defer tx.Rollback(ctx)
 
+		before := auditSnapshot(ctx, deps, tx, "banners", bannerID)
+
 		_, err = tx.Exec(ctx, "DELETE FROM banners WHERE id = $1", bannerID)
 		if err != nil {
 			utils.WriteInternalServerError(w)
 			return
 		}
 
-		tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'DELETE', 'BANNER', $2, 'Deleted banner', NOW())
-		`, adminID, bannerID)
+		if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+			Action:      "DELETE",
+			Resource:    "BANNER",
+			ResourceID:  bannerID,
+			Description: "Deleted banner",
+			Before:      before,
+		}); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
 
 		if err := tx.Commit(ctx); err != nil {
 			utils.WriteInternalServerError(w)
@@ -966,7 +1006,6 @@ func HandleAdminGetPopupsImpl(deps *Dependencies) http.HandlerFunc {
 // handleCreatePopupImpl creates a new popup for a region
 func HandleCreatePopupImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
 		defer cancel()
@@ -1067,10 +1106,16 @@ func HandleCreatePopupImpl(deps *Dependencies) http.HandlerFunc {
 		}
 
 		// Create audit log
-		tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'CREATE', 'POPUP', $2, $3, NOW())
-		`, adminID, popupID, fmt.Sprintf("Created popup for region %s", regionCode))
+		if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+			Action:      "CREATE",
+			Resource:    "POPUP",
+			ResourceID:  popupID,
+			Description: fmt.Sprintf("Created popup for region %s", regionCode),
+			After:       auditSnapshot(ctx, deps, tx, "popups", popupID),
+		}); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
 
 		if err := tx.Commit(ctx); err != nil {
 			utils.WriteInternalServerError(w)
@@ -1097,7 +1142,6 @@ type UpdatePopupRequest struct {
 func HandleUpdatePopupImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		region := strings.ToUpper(strings.TrimSpace(chi.URLParam(r, "region")))
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		fmt.Printf("[UPDATE POPUP] Starting update for region: %s\n", region)
 
@@ -1201,6 +1245,18 @@ func HandleUpdatePopupImpl(deps *Dependencies) http.HandlerFunc {
 		}
 		defer tx.Rollback(ctx)
 
+		// Popups are upserted by region, keep the previous one of the region for the audit log
+		var previousPopupID string
+		if err := tx.QueryRow(ctx, "SELECT COALESCE((SELECT id::text FROM popups WHERE region_code = $1), '')", region).Scan(&previousPopupID); err != nil {
+			fmt.Printf("[UPDATE POPUP] Error reading previous popup: %v\n", err)
+			utils.WriteInternalServerError(w)
+			return
+		}
+		var before map[string]interface{}
+		if previousPopupID != "" {
+			before = auditSnapshot(ctx, deps, tx, "popups", previousPopupID)
+		}
+
 		// Upsert popup
 		fmt.Printf("[UPDATE POPUP] Executing upsert query\n")
 		_, err = tx.Exec(ctx, `
@@ -1227,10 +1283,17 @@ func HandleUpdatePopupImpl(deps *Dependencies) http.HandlerFunc {
 
 		// Create audit log (non-blocking)
 		if popupID != "" {
-			tx.Exec(ctx, `
-				INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-				VALUES ($1, 'UPDATE', 'POPUP', $2, $3, NOW())
-			`, adminID, popupID, fmt.Sprintf("Updated popup for region %s", region))
+			if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+				Action:      "UPDATE",
+				Resource:    "POPUP",
+				ResourceID:  popupID,
+				Description: fmt.Sprintf("Updated popup for region %s", region),
+				Before:      before,
+				After:       auditSnapshot(ctx, deps, tx, "popups", popupID),
+			}); err != nil {
+				utils.WriteInternalServerError(w)
+				return
+			}
 		}
 
 		fmt.Printf("[UPDATE POPUP] Committing transaction\n")
@@ -0,0 +1,209 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"seaply/internal/services"
+	"seaply/internal/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// ============================================
+// ADMIN PROVIDER BALANCES
+// ============================================
+
+// providerBalance returns the latest balance snapshot of a provider, or nil when none was taken
+func providerBalance(ctx context.Context, deps *Dependencies, providerID string) *services.ProviderBalanceStatus {
+	if deps.BalanceMonitor == nil {
+		return nil
+	}
+	status, err := deps.BalanceMonitor.Status(ctx, providerID)
+	if err != nil {
+		return nil
+	}
+	return &status
+}
+
+// HandleGetProviderBalancesImpl returns the latest balance and burn rate of every provider
+func HandleGetProviderBalancesImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if deps.BalanceMonitor == nil {
+			utils.WriteErrorJSON(w, http.StatusServiceUnavailable, "BALANCE_MONITOR_UNAVAILABLE",
+				"Balance monitor is not configured", "")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		settings, err := deps.BalanceMonitor.LoadSettings(ctx)
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to load provider balance settings")
+		}
+
+		rows, err := deps.DB.Pool.Query(ctx, `SELECT id FROM providers ORDER BY priority ASC, created_at DESC`)
+		if err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
+		var providerIDs []string
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err == nil {
+				providerIDs = append(providerIDs, id)
+			}
+		}
+		rows.Close()
+
+		balances := []services.ProviderBalanceStatus{}
+		for _, id := range providerIDs {
+			if status := providerBalance(ctx, deps, id); status != nil {
+				balances = append(balances, *status)
+			}
+		}
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"balances": balances,
+			"settings": map[string]interface{}{
+				"enabled":       settings.Enabled,
+				"interval":      int(settings.Interval.Minutes()),
+				"lowThreshold":  settings.LowThreshold,
+				"minHoursLeft":  settings.MinHoursLeft,
+				"alertCooldown": int(settings.AlertCooldown.Minutes()),
+				"retentionDays": settings.RetentionDays,
+				"alertEmail":    settings.AlertEmail,
+			},
+			"lastRun": deps.BalanceMonitor.LastRun(),
+		})
+	}
+}
+
+// HandleRefreshProviderBalancesImpl snapshots every provider balance immediately
+func HandleRefreshProviderBalancesImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if deps.BalanceMonitor == nil {
+			utils.WriteErrorJSON(w, http.StatusServiceUnavailable, "BALANCE_MONITOR_UNAVAILABLE",
+				"Balance monitor is not configured", "")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Minute)
+		defer cancel()
+
+		result, err := deps.BalanceMonitor.Run(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("Manual provider balance refresh failed")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      "UPDATE",
+			Resource:    "PROVIDER",
+			Description: "Refreshed provider balances: " + strconv.Itoa(len(result.Providers)) + " provider(s)",
+		})
+
+		utils.WriteSuccessJSON(w, result)
+	}
+}
+
+// HandleGetProviderBalanceHistoryImpl returns the balance snapshots of a provider
+func HandleGetProviderBalanceHistoryImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		providerID, err := uuid.Parse(chi.URLParam(r, "providerId"))
+		if err != nil {
+			utils.WriteBadRequestError(w, "Invalid provider ID")
+			return
+		}
+
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit <= 0 || limit > 500 {
+			limit = 96
+		}
+
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page <= 0 {
+			page = 1
+		}
+
+		offset := (page - 1) * limit
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		where := " WHERE provider_id = $1"
+		args := []interface{}{providerID}
+		argCount := 1
+
+		if startDate := r.URL.Query().Get("startDate"); startDate != "" {
+			argCount++
+			where += " AND created_at >= $" + strconv.Itoa(argCount) + "::date"
+			args = append(args, startDate)
+		}
+		if endDate := r.URL.Query().Get("endDate"); endDate != "" {
+			argCount++
+			where += " AND created_at < $" + strconv.Itoa(argCount) + "::date + INTERVAL '1 day'"
+			args = append(args, endDate)
+		}
+
+		var totalRows int
+		err = deps.DB.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM provider_balance_history"+where, args...).Scan(&totalRows)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to count provider balance history")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		query := "SELECT balance, currency, created_at FROM provider_balance_history" + where
+		query += " ORDER BY created_at DESC"
+		argCount++
+		query += " LIMIT $" + strconv.Itoa(argCount)
+		args = append(args, limit)
+		argCount++
+		query += " OFFSET $" + strconv.Itoa(argCount)
+		args = append(args, offset)
+
+		rows, err := deps.DB.Pool.Query(ctx, query, args...)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to query provider balance history")
+			utils.WriteInternalServerError(w)
+			return
+		}
+		defer rows.Close()
+
+		history := []map[string]interface{}{}
+		for rows.Next() {
+			var balance int64
+			var currency string
+			var createdAt time.Time
+			if err := rows.Scan(&balance, &currency, &createdAt); err != nil {
+				log.Error().Err(err).Msg("Failed to scan provider balance history")
+				continue
+			}
+			history = append(history, map[string]interface{}{
+				"balance":   balance,
+				"currency":  currency,
+				"createdAt": createdAt.Format(time.RFC3339),
+			})
+		}
+
+		totalPages := (totalRows + limit - 1) / limit
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"current": providerBalance(ctx, deps, providerID.String()),
+			"history": history,
+			"pagination": map[string]interface{}{
+				"limit":      limit,
+				"page":       page,
+				"totalRows":  totalRows,
+				"totalPages": totalPages,
+			},
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"seaply/internal/provider"
+	"seaply/internal/services"
+	"seaply/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// ============================================
+// ADMIN PROVIDER REGISTRATION
+// ============================================
+
+// providerSecrets are the encrypted values written to the providers table
+type providerSecrets struct {
+	Credentials   *string // nil leaves the column unchanged, empty clears it
+	WebhookSecret *string
+}
+
+// sealProviderSecrets validates credentials against the provider type and encrypts them.
+// It writes the error response itself and returns false when the request can't be stored.
+func sealProviderSecrets(w http.ResponseWriter, deps *Dependencies, providerType string, credentials map[string]string, webhookSecret *string) (providerSecrets, bool) {
+	var secrets providerSecrets
+	if credentials == nil && webhookSecret == nil {
+		return secrets, true
+	}
+	if deps.ProviderRegistry == nil {
+		utils.WriteErrorJSON(w, http.StatusServiceUnavailable, "PROVIDER_REGISTRY_UNAVAILABLE", "Provider registry is not configured", "")
+		return secrets, false
+	}
+
+	if credentials != nil {
+		sealed := ""
+		if len(credentials) > 0 {
+			if providerType == "" {
+				utils.WriteValidationErrorJSON(w, "Validation failed", map[string]string{"type": "Type is required to store credentials"})
+				return secrets, false
+			}
+			if missing := provider.MissingCredentials(providerType, credentials); len(missing) > 0 {
+				utils.WriteValidationErrorJSON(w, "Validation failed", map[string]string{
+					"credentials": "Missing credentials: " + strings.Join(missing, ", "),
+				})
+				return secrets, false
+			}
+			var err error
+			sealed, err = deps.ProviderRegistry.EncryptCredentials(credentials)
+			if err != nil {
+				writeCredentialError(w, err)
+				return secrets, false
+			}
+		}
+		secrets.Credentials = &sealed
+	}
+
+	if webhookSecret != nil {
+		sealed := ""
+		if *webhookSecret != "" {
+			var err error
+			sealed, err = deps.ProviderRegistry.EncryptSecret(*webhookSecret)
+			if err != nil {
+				writeCredentialError(w, err)
+				return secrets, false
+			}
+		}
+		secrets.WebhookSecret = &sealed
+	}
+	return secrets, true
+}
+
+// stringValue returns the string a pointer refers to, or an empty string for nil
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func writeCredentialError(w http.ResponseWriter, err error) {
+	if errors.Is(err, utils.ErrMissingCredentialKey) {
+		utils.WriteErrorJSON(w, http.StatusServiceUnavailable, "CREDENTIAL_KEY_MISSING",
+			"APP_CREDENTIAL_KEY must be set to store credentials", "")
+		return
+	}
+	log.Error().Err(err).Msg("Failed to encrypt provider credentials")
+	utils.WriteInternalServerError(w)
+}
+
+// providerRegistration describes how a provider is configured and whether it is registered
+func providerRegistration(ctx context.Context, deps *Dependencies, providerID uuid.UUID, code string) map[string]interface{} {
+	var (
+		providerType         string
+		isProduction         bool
+		sealedCredentials    string
+		sealedWebhookSecret  string
+		credentialsUpdatedAt *time.Time
+	)
+	_ = deps.DB.Pool.QueryRow(ctx, `
+		SELECT COALESCE(provider_type, ''), is_production, COALESCE(credentials, ''),
+			COALESCE(webhook_secret, ''), credentials_updated_at
+		FROM providers
+		WHERE id = $1
+	`, providerID).Scan(&providerType, &isProduction, &sealedCredentials, &sealedWebhookSecret, &credentialsUpdatedAt)
+
+	registration := map[string]interface{}{
+		"type":                 providerType,
+		"isProduction":         isProduction,
+		"hasCredentials":       sealedCredentials != "",
+		"hasWebhookSecret":     sealedWebhookSecret != "",
+		"credentials":          map[string]string{},
+		"credentialsUpdatedAt": nil,
+		"registered":           false,
+		"source":               "",
+	}
+	if credentialsUpdatedAt != nil {
+		registration["credentialsUpdatedAt"] = credentialsUpdatedAt.Format(time.RFC3339)
+	}
+	if deps.ProviderManager != nil {
+		_, err := deps.ProviderManager.Get(strings.ToLower(code))
+		registration["registered"] = err == nil
+	}
+	if deps.ProviderRegistry == nil {
+		return registration
+	}
+
+	registration["source"] = deps.ProviderRegistry.Source(code)
+	if sealedCredentials != "" {
+		credentials, err := deps.ProviderRegistry.DecryptCredentials(sealedCredentials)
+		if err != nil {
+			registration["credentialsError"] = "Stored credentials can't be decrypted with the current key"
+			return registration
+		}
+		masked := make(map[string]string, len(credentials))
+		for field, value := range credentials {
+			masked[field] = utils.MaskSecret(value)
+		}
+		registration["credentials"] = masked
+	}
+	return registration
+}
+
+// HandleGetProviderTypesImpl lists the provider implementations instances can be created from
+func HandleGetProviderTypesImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"types": provider.Types(),
+		})
+	}
+}
+
+// HandleReloadProvidersImpl rebuilds every provider instance from the database
+func HandleReloadProvidersImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if deps.ProviderRegistry == nil {
+			utils.WriteErrorJSON(w, http.StatusServiceUnavailable, "PROVIDER_REGISTRY_UNAVAILABLE", "Provider registry is not configured", "")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+		defer cancel()
+
+		if err := deps.ProviderRegistry.LoadAll(ctx); err != nil {
+			log.Error().Err(err).Msg("Failed to reload providers")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		registered := []string{}
+		for _, p := range deps.ProviderManager.GetAll() {
+			registered = append(registered, p.GetName())
+		}
+
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      "UPDATE",
+			Resource:    "PROVIDER",
+			Description: "Reloaded providers from database",
+		})
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"registered": registered,
+		})
+	}
+}
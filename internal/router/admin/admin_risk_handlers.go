@@ -0,0 +1,227 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"seaply/internal/middleware"
+	"seaply/internal/services"
+	"seaply/internal/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// ============================================
+// ADMIN RISK REVIEWS
+// ============================================
+
+// writeRiskError writes the response for an error of the risk engine
+func writeRiskError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		utils.WriteNotFoundError(w, "Risk evaluation")
+	case errors.Is(err, services.ErrRiskReviewNotPending):
+		utils.WriteErrorJSON(w, http.StatusConflict, "RISK_REVIEW_NOT_PENDING", err.Error(), "")
+	default:
+		log.Error().Err(err).Msg("Risk review failed")
+		utils.WriteInternalServerError(w)
+	}
+}
+
+// riskEvaluationIDParam returns the evaluation ID of the URL, writing 404 when it isn't a UUID
+func riskEvaluationIDParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	evaluationID := chi.URLParam(r, "evaluationId")
+	if _, err := uuid.Parse(evaluationID); err != nil {
+		utils.WriteNotFoundError(w, "Risk evaluation")
+		return "", false
+	}
+	return evaluationID, true
+}
+
+// RiskReviewRequest represents the request to release or cancel a held checkout
+type RiskReviewRequest struct {
+	Note string `json:"note"`
+}
+
+func decodeRiskReview(w http.ResponseWriter, r *http.Request, requireNote bool) (RiskReviewRequest, bool) {
+	var req RiskReviewRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.WriteBadRequestError(w, "Invalid request body")
+			return req, false
+		}
+	}
+	req.Note = strings.TrimSpace(req.Note)
+	if requireNote && req.Note == "" {
+		utils.WriteValidationErrorJSON(w, "Validation failed", map[string]string{
+			"note": "Note is required",
+		})
+		return req, false
+	}
+	return req, true
+}
+
+// riskEvaluationSummary describes an evaluation in audit logs
+func riskEvaluationSummary(evaluation *services.RiskEvaluation) string {
+	summary := strings.ToLower(evaluation.Kind) + " of " + utils.FormatCurrency(float64(evaluation.Amount), evaluation.Currency)
+	if evaluation.Transaction != nil {
+		summary = evaluation.Transaction.InvoiceNumber + " (" + summary + ")"
+	}
+	return summary
+}
+
+// HandleGetRiskEvaluationsImpl lists risk evaluations, reviewStatus=PENDING is the review queue
+func HandleGetRiskEvaluationsImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit <= 0 || limit > 100 {
+			limit = 10
+		}
+
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page <= 0 {
+			page = 1
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		query := r.URL.Query()
+		evaluations, totalRows, err := deps.Risk.List(ctx, query.Get("reviewStatus"), query.Get("action"), query.Get("kind"), limit, (page-1)*limit)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to list risk evaluations")
+			utils.WriteInternalServerError(w)
+			return
+		}
+		totalPages := (totalRows + limit - 1) / limit
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"evaluations": evaluations,
+			"pagination": map[string]interface{}{
+				"limit":      limit,
+				"page":       page,
+				"totalRows":  totalRows,
+				"totalPages": totalPages,
+			},
+		})
+	}
+}
+
+// HandleGetRiskEvaluationImpl returns a risk evaluation with its signals and review
+func HandleGetRiskEvaluationImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		evaluationID, ok := riskEvaluationIDParam(w, r)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		evaluation, err := deps.Risk.Get(ctx, evaluationID)
+		if err != nil {
+			writeRiskError(w, err)
+			return
+		}
+
+		utils.WriteSuccessJSON(w, evaluation)
+	}
+}
+
+// HandleReleaseRiskEvaluationImpl lets a held checkout through; a paid order is sent to its provider
+func HandleReleaseRiskEvaluationImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		evaluationID, ok := riskEvaluationIDParam(w, r)
+		if !ok {
+			return
+		}
+		adminID := middleware.GetAdminIDFromContext(r.Context())
+
+		req, ok := decodeRiskReview(w, r, false)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		evaluation, err := deps.Risk.Release(ctx, evaluationID, adminID, req.Note)
+		if err != nil {
+			writeRiskError(w, err)
+			return
+		}
+
+		description := "Released " + riskEvaluationSummary(evaluation) + " held by the risk engine"
+		if req.Note != "" {
+			description += ": " + req.Note
+		}
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      "RELEASE",
+			Resource:    "RISK_EVALUATION",
+			ResourceID:  evaluation.ID,
+			Description: description,
+		})
+		if evaluation.Transaction != nil {
+			publishTransactionStatus(deps, evaluation.Transaction.ID)
+		}
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"message":    "Checkout released",
+			"evaluation": evaluation,
+		})
+	}
+}
+
+// HandleCancelRiskEvaluationImpl refuses a held checkout and fails its order
+func HandleCancelRiskEvaluationImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		evaluationID, ok := riskEvaluationIDParam(w, r)
+		if !ok {
+			return
+		}
+		adminID := middleware.GetAdminIDFromContext(r.Context())
+
+		req, ok := decodeRiskReview(w, r, true)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		evaluation, err := deps.Risk.Cancel(ctx, evaluationID, adminID, req.Note)
+		if err != nil {
+			writeRiskError(w, err)
+			return
+		}
+
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      "CANCEL",
+			Resource:    "RISK_EVALUATION",
+			ResourceID:  evaluation.ID,
+			Description: "Cancelled " + riskEvaluationSummary(evaluation) + " held by the risk engine: " + req.Note,
+		})
+
+		// The risk engine queued the customer email with the cancellation. A paid order
+		// isn't refunded here, it goes through the transaction refund
+		refundRequired := false
+		if transaction := evaluation.Transaction; transaction != nil {
+			publishTransactionStatus(deps, transaction.ID)
+			refundRequired = transaction.PaymentStatus == "PAID"
+		}
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"message":        "Checkout cancelled",
+			"evaluation":     evaluation,
+			"refundRequired": refundRequired,
+		})
+	}
+}
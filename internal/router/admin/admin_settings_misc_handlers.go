@@ -3,6 +3,7 @@ package admin
 import (
 	"context"
 	"database/sql"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,45 +13,54 @@ import (
 	"time"
 
 	"seaply/internal/middleware"
+	"seaply/internal/services"
 	"seaply/internal/storage"
 	"seaply/internal/utils"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
 )
 
 // ============================================
 // ADMIN SETTINGS MANAGEMENT
 // ============================================
 
-// handleGetSettingsImpl returns all settings
+// handleGetSettingsImpl returns all settings grouped by category
 func HandleGetSettingsImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Mock settings data
-		utils.WriteSuccessJSON(w, map[string]interface{}{
-			"general": map[string]interface{}{
-				"siteName":           "Seaply.co",
-				"siteDescription":    "SEA Gaming Supply",
-				"maintenanceMode":    false,
-				"maintenanceMessage": nil,
-			},
-			"transaction": map[string]interface{}{
-				"orderExpiry":      3600,
-				"autoRefundOnFail": true,
-				"maxRetryAttempts": 3,
-			},
-			"notification": map[string]interface{}{
-				"emailEnabled":    true,
-				"whatsappEnabled": true,
-				"telegramEnabled": false,
-			},
-			"security": map[string]interface{}{
-				"maxLoginAttempts": 5,
-				"lockoutDuration":  900,
-				"sessionTimeout":   3600,
-				"mfaRequired":      true,
-			},
-		})
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		rows, err := deps.DB.Pool.Query(ctx, `
+			SELECT category, key, value FROM settings ORDER BY category, key
+		`)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to get settings")
+			utils.WriteInternalServerError(w)
+			return
+		}
+		defer rows.Close()
+
+		settings := map[string]map[string]interface{}{}
+		for rows.Next() {
+			var category, key string
+			var value []byte
+			if err := rows.Scan(&category, &key, &value); err != nil {
+				continue
+			}
+
+			var decoded interface{}
+			json.Unmarshal(value, &decoded)
+
+			if settings[category] == nil {
+				settings[category] = map[string]interface{}{}
+			}
+			settings[category][key] = decoded
+		}
+
+		utils.WriteSuccessJSON(w, settings)
 	}
 }
 
@@ -63,7 +73,6 @@ type UpdateSettingsRequest struct {
 func HandleUpdateSettingsImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		category := chi.URLParam(r, "category")
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		var req UpdateSettingsRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -71,14 +80,90 @@ func HandleUpdateSettingsImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		if len(req.Settings) == 0 {
+			utils.WriteValidationErrorJSON(w, "Validation failed", map[string]string{
+				"settings": "At least one setting is required",
+			})
+			return
+		}
+
 		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 		defer cancel()
 
+		tx, err := deps.DB.Pool.Begin(ctx)
+		if err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		keys := make([]string, 0, len(req.Settings))
+		for key := range req.Settings {
+			keys = append(keys, key)
+		}
+		before := map[string]interface{}{}
+		rows, err := tx.Query(ctx, `
+			SELECT key, value FROM settings WHERE category = $1 AND key = ANY($2) FOR UPDATE
+		`, category, keys)
+		if err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
+		for rows.Next() {
+			var key string
+			var value json.RawMessage
+			if err := rows.Scan(&key, &value); err != nil {
+				rows.Close()
+				utils.WriteInternalServerError(w)
+				return
+			}
+			before[key] = value
+		}
+		rows.Close()
+
+		for key, value := range req.Settings {
+			data, err := json.Marshal(value)
+			if err != nil {
+				utils.WriteBadRequestError(w, "Invalid value for "+key)
+				return
+			}
+
+			_, err = tx.Exec(ctx, `
+				INSERT INTO settings (category, key, value)
+				VALUES ($1, $2, $3)
+				ON CONFLICT (category, key) DO UPDATE SET value = EXCLUDED.value, updated_at = NOW()
+			`, category, key, data)
+			if err != nil {
+				log.Error().Err(err).Str("category", category).Str("key", key).Msg("Failed to update setting")
+				utils.WriteInternalServerError(w)
+				return
+			}
+		}
+
 		// Create audit log
-		deps.DB.Pool.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'UPDATE', 'SETTINGS', $2, 'Updated settings', NOW())
-		`, adminID, category)
+		if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+			Action:      "UPDATE",
+			Resource:    "SETTINGS",
+			ResourceID:  category,
+			Description: "Updated " + category + " settings",
+			Before:      before,
+			After:       req.Settings,
+		}); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		// Apply rate limit changes right away instead of waiting for the next reload
+		if category == "rate_limit" && deps.RateLimiter != nil {
+			if err := deps.RateLimiter.LoadSettings(ctx, deps.DB.Pool); err != nil {
+				log.Warn().Err(err).Msg("Failed to reload rate limit settings")
+			}
+		}
 
 		utils.WriteSuccessJSON(w, map[string]interface{}{
 			"message": "Settings updated successfully",
@@ -120,7 +205,6 @@ type UpdateContactSettingsRequest struct {
 // handleUpdateContactSettingsImpl updates contact settings
 func HandleUpdateContactSettingsImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		var req UpdateContactSettingsRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -132,10 +216,13 @@ func HandleUpdateContactSettingsImpl(deps *Dependencies) http.HandlerFunc {
 		defer cancel()
 
 		// Create audit log
-		deps.DB.Pool.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'UPDATE', 'SETTINGS', 'contacts', 'Updated contact settings', NOW())
-		`, adminID)
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      "UPDATE",
+			Resource:    "SETTINGS",
+			ResourceID:  "contacts",
+			Description: "Updated contact settings",
+			After:       req,
+		})
 
 		utils.WriteSuccessJSON(w, map[string]interface{}{
 			"message": "Contact settings updated successfully",
@@ -224,7 +311,6 @@ type CreateRegionRequest struct {
 // handleCreateRegionImpl creates a new region
 func HandleCreateRegionImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 		defer cancel()
@@ -325,10 +411,16 @@ func HandleCreateRegionImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
-		tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'CREATE', 'REGION', $2, 'Created region', NOW())
-		`, adminID, regionID)
+		if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+			Action:      "CREATE",
+			Resource:    "REGION",
+			ResourceID:  regionID,
+			Description: "Created region",
+			After:       auditSnapshot(ctx, deps, tx, "regions", regionID),
+		}); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
 
 		if err := tx.Commit(ctx); err != nil {
 			utils.WriteInternalServerError(w)
@@ -346,7 +438,6 @@ func HandleCreateRegionImpl(deps *Dependencies) http.HandlerFunc {
 func HandleUpdateRegionImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		regionID := chi.URLParam(r, "regionId")
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 		defer cancel()
@@ -459,6 +550,8 @@ func HandleUpdateRegionImpl(deps *Dependencies) http.HandlerFunc {
 		}
 		defer tx.Rollback(ctx)
 
+		before := auditSnapshot(ctx, deps, tx, "regions", regionID)
+
 		// If setting as default, unset other defaults first
 		if isDefault && !existingIsDefault {
 			_, err = tx.Exec(ctx, `UPDATE regions SET is_default = false WHERE is_default = true AND id != $1`, regionID)
@@ -499,10 +592,17 @@ func HandleUpdateRegionImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
-		tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'UPDATE', 'REGION', $2, 'Updated region', NOW())
-		`, adminID, regionID)
+		if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+			Action:      "UPDATE",
+			Resource:    "REGION",
+			ResourceID:  regionID,
+			Description: "Updated region",
+			Before:      before,
+			After:       auditSnapshot(ctx, deps, tx, "regions", regionID),
+		}); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
 
 		if err := tx.Commit(ctx); err != nil {
 			utils.WriteInternalServerError(w)
@@ -519,7 +619,6 @@ func HandleUpdateRegionImpl(deps *Dependencies) http.HandlerFunc {
 func HandleDeleteRegionImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		regionID := chi.URLParam(r, "regionId")
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 		defer cancel()
@@ -577,16 +676,24 @@ func HandleDeleteRegionImpl(deps *Dependencies) http.HandlerFunc {
 		}
 		defer tx.Rollback(ctx)
 
+		before := auditSnapshot(ctx, deps, tx, "regions", regionID)
+
 		_, err = tx.Exec(ctx, "DELETE FROM regions WHERE id = $1", regionID)
 		if err != nil {
 			utils.WriteInternalServerError(w)
 			return
 		}
 
-		tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'DELETE', 'REGION', $2, 'Deleted region', NOW())
-		`, adminID, regionID)
+		if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+			Action:      "DELETE",
+			Resource:    "REGION",
+			ResourceID:  regionID,
+			Description: "Deleted region",
+			Before:      before,
+		}); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
 
 		if err := tx.Commit(ctx); err != nil {
 			utils.WriteInternalServerError(w)
@@ -675,7 +782,6 @@ type CreateLanguageRequest struct {
 // handleCreateLanguageImpl creates a new language
 func HandleCreateLanguageImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 		defer cancel()
@@ -780,10 +886,16 @@ func HandleCreateLanguageImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
-		tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'CREATE', 'LANGUAGE', $2, 'Created language', NOW())
-		`, adminID, languageID)
+		if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+			Action:      "CREATE",
+			Resource:    "LANGUAGE",
+			ResourceID:  languageID,
+			Description: "Created language",
+			After:       auditSnapshot(ctx, deps, tx, "languages", languageID),
+		}); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
 
 		if err := tx.Commit(ctx); err != nil {
 			utils.WriteInternalServerError(w)
@@ -801,7 +913,6 @@ func HandleCreateLanguageImpl(deps *Dependencies) http.HandlerFunc {
 func HandleUpdateLanguageImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		languageID := chi.URLParam(r, "languageId")
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 		defer cancel()
@@ -910,6 +1021,8 @@ func HandleUpdateLanguageImpl(deps *Dependencies) http.HandlerFunc {
 		}
 		defer tx.Rollback(ctx)
 
+		before := auditSnapshot(ctx, deps, tx, "languages", languageID)
+
 		// If setting as default, unset other defaults first
 		if isDefault && !existingIsDefault {
 			_, err = tx.Exec(ctx, `UPDATE languages SET is_default = false WHERE is_default = true AND id != $1`, languageID)
@@ -950,10 +1063,17 @@ func HandleUpdateLanguageImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
-		tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'UPDATE', 'LANGUAGE', $2, 'Updated language', NOW())
-		`, adminID, languageID)
+		if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+			Action:      "UPDATE",
+			Resource:    "LANGUAGE",
+			ResourceID:  languageID,
+			Description: "Updated language",
+			Before:      before,
+			After:       auditSnapshot(ctx, deps, tx, "languages", languageID),
+		}); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
 
 		if err := tx.Commit(ctx); err != nil {
 			utils.WriteInternalServerError(w)
@@ -970,7 +1090,6 @@ func HandleUpdateLanguageImpl(deps *Dependencies) http.HandlerFunc {
 func HandleDeleteLanguageImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		languageID := chi.URLParam(r, "languageId")
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 		defer cancel()
@@ -1001,16 +1120,24 @@ func HandleDeleteLanguageImpl(deps *Dependencies) http.HandlerFunc {
 		}
 		defer tx.Rollback(ctx)
 
+		before := auditSnapshot(ctx, deps, tx, "languages", languageID)
+
 		_, err = tx.Exec(ctx, "DELETE FROM languages WHERE id = $1", languageID)
 		if err != nil {
 			utils.WriteInternalServerError(w)
 			return
 		}
 
-		tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'DELETE', 'LANGUAGE', $2, 'Deleted language', NOW())
-		`, adminID, languageID)
+		if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+			Action:      "DELETE",
+			Resource:    "LANGUAGE",
+			ResourceID:  languageID,
+			Description: "Deleted language",
+			Before:      before,
+		}); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
 
 		if err := tx.Commit(ctx); err != nil {
 			utils.WriteInternalServerError(w)
@@ -1092,7 +1219,6 @@ type CreateCategoryRequest struct {
 // handleCreateCategoryImpl creates a new category
 func HandleCreateCategoryImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		var req CreateCategoryRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -1140,10 +1266,16 @@ func HandleCreateCategoryImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
-		tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'CREATE', 'CATEGORY', $2, 'Created category', NOW())
-		`, adminID, categoryID)
+		if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+			Action:      "CREATE",
+			Resource:    "CATEGORY",
+			ResourceID:  categoryID,
+			Description: "Created category",
+			After:       auditSnapshot(ctx, deps, tx, "categories", categoryID),
+		}); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
 
 		if err := tx.Commit(ctx); err != nil {
 			utils.WriteInternalServerError(w)
@@ -1161,7 +1293,6 @@ func HandleCreateCategoryImpl(deps *Dependencies) http.HandlerFunc {
 func HandleUpdateCategoryImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		categoryID := chi.URLParam(r, "categoryId")
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		// Parse multipart form if present (for icon upload)
 		if err := r.ParseMultipartForm(10 << 20); err != nil {
@@ -1182,6 +1313,8 @@ func HandleUpdateCategoryImpl(deps *Dependencies) http.HandlerFunc {
 			}
 			defer tx.Rollback(ctx)
 
+			before := auditSnapshot(ctx, deps, tx, "categories", categoryID)
+
 			// Always update icon if provided in request (emoji or URL)
 			_, err = tx.Exec(ctx, `
 				UPDATE categories SET
@@ -1213,10 +1346,17 @@ func HandleUpdateCategoryImpl(deps *Dependencies) http.HandlerFunc {
 				}
 			}
 
-			tx.Exec(ctx, `
-				INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-				VALUES ($1, 'UPDATE', 'CATEGORY', $2, 'Updated category', NOW())
-			`, adminID, categoryID)
+			if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+				Action:      "UPDATE",
+				Resource:    "CATEGORY",
+				ResourceID:  categoryID,
+				Description: "Updated category",
+				Before:      before,
+				After:       auditSnapshot(ctx, deps, tx, "categories", categoryID),
+			}); err != nil {
+				utils.WriteInternalServerError(w)
+				return
+			}
 
 			if err := tx.Commit(ctx); err != nil {
 				utils.WriteInternalServerError(w)
@@ -1273,6 +1413,8 @@ func HandleUpdateCategoryImpl(deps *Dependencies) http.HandlerFunc {
 		}
 		defer tx.Rollback(ctx)
 
+		before := auditSnapshot(ctx, deps, tx, "categories", categoryID)
+
 		// Update icon if provided
 		if iconURL != "" {
 			_, err = tx.Exec(ctx, `
@@ -1317,10 +1459,17 @@ func HandleUpdateCategoryImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
-		tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'UPDATE', 'CATEGORY', $2, 'Updated category', NOW())
-		`, adminID, categoryID)
+		if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+			Action:      "UPDATE",
+			Resource:    "CATEGORY",
+			ResourceID:  categoryID,
+			Description: "Updated category",
+			Before:      before,
+			After:       auditSnapshot(ctx, deps, tx, "categories", categoryID),
+		}); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
 
 		if err := tx.Commit(ctx); err != nil {
 			utils.WriteInternalServerError(w)
@@ -1337,7 +1486,6 @@ func HandleUpdateCategoryImpl(deps *Dependencies) http.HandlerFunc {
 func HandleDeleteCategoryImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		categoryID := chi.URLParam(r, "categoryId")
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 		defer cancel()
@@ -1349,16 +1497,24 @@ func HandleDeleteCategoryImpl(deps *Dependencies) http.HandlerFunc {
 		}
 		defer tx.Rollback(ctx)
 
+		before := auditSnapshot(ctx, deps, tx, "categories", categoryID)
+
 		_, err = tx.Exec(ctx, "DELETE FROM categories WHERE id = $1", categoryID)
 		if err != nil {
 			utils.WriteInternalServerError(w)
 			return
 		}
 
-		tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'DELETE', 'CATEGORY', $2, 'Deleted category', NOW())
-		`, adminID, categoryID)
+		if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+			Action:      "DELETE",
+			Resource:    "CATEGORY",
+			ResourceID:  categoryID,
+			Description: "Deleted category",
+			Before:      before,
+		}); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
 
 		if err := tx.Commit(ctx); err != nil {
 			utils.WriteInternalServerError(w)
@@ -1435,7 +1591,6 @@ type CreateSectionRequest struct {
 // handleCreateSectionImpl creates a new section
 func HandleCreateSectionImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		var req CreateSectionRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -1488,10 +1643,16 @@ func HandleCreateSectionImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
-		tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'CREATE', 'SECTION', $2, 'Created section', NOW())
-		`, adminID, sectionID)
+		if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+			Action:      "CREATE",
+			Resource:    "SECTION",
+			ResourceID:  sectionID,
+			Description: "Created section",
+			After:       auditSnapshot(ctx, deps, tx, "sections", sectionID),
+		}); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
 
 		if err := tx.Commit(ctx); err != nil {
 			utils.WriteInternalServerError(w)
@@ -1509,7 +1670,6 @@ func HandleCreateSectionImpl(deps *Dependencies) http.HandlerFunc {
 func HandleUpdateSectionImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		sectionID := chi.URLParam(r, "sectionId")
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		var req CreateSectionRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -1527,6 +1687,8 @@ func HandleUpdateSectionImpl(deps *Dependencies) http.HandlerFunc {
 		}
 		defer tx.Rollback(ctx)
 
+		before := auditSnapshot(ctx, deps, tx, "sections", sectionID)
+
 		_, err = tx.Exec(ctx, `
 			UPDATE sections SET
 				title = $1, icon = $2, is_active = $3, sort_order = $4, updated_at = NOW()
@@ -1567,10 +1729,17 @@ func HandleUpdateSectionImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
-		tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'UPDATE', 'SECTION', $2, 'Updated section', NOW())
-		`, adminID, sectionID)
+		if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+			Action:      "UPDATE",
+			Resource:    "SECTION",
+			ResourceID:  sectionID,
+			Description: "Updated section",
+			Before:      before,
+			After:       auditSnapshot(ctx, deps, tx, "sections", sectionID),
+		}); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
 
 		if err := tx.Commit(ctx); err != nil {
 			utils.WriteInternalServerError(w)
@@ -1587,7 +1756,6 @@ func HandleUpdateSectionImpl(deps *Dependencies) http.HandlerFunc {
 func HandleDeleteSectionImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		sectionID := chi.URLParam(r, "sectionId")
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 		defer cancel()
@@ -1599,16 +1767,24 @@ func HandleDeleteSectionImpl(deps *Dependencies) http.HandlerFunc {
 		}
 		defer tx.Rollback(ctx)
 
+		before := auditSnapshot(ctx, deps, tx, "sections", sectionID)
+
 		_, err = tx.Exec(ctx, "DELETE FROM sections WHERE id = $1", sectionID)
 		if err != nil {
 			utils.WriteInternalServerError(w)
 			return
 		}
 
-		tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'DELETE', 'SECTION', $2, 'Deleted section', NOW())
-		`, adminID, sectionID)
+		if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+			Action:      "DELETE",
+			Resource:    "SECTION",
+			ResourceID:  sectionID,
+			Description: "Deleted section",
+			Before:      before,
+		}); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
 
 		if err := tx.Commit(ctx); err != nil {
 			utils.WriteInternalServerError(w)
@@ -1630,7 +1806,6 @@ type AssignSectionProductsRequest struct {
 func HandleAssignSectionProductsImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		sectionID := chi.URLParam(r, "sectionId")
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		var req AssignSectionProductsRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -1654,10 +1829,15 @@ func HandleAssignSectionProductsImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
-		tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'UPDATE', 'SECTION', $2, 'Assigned products to section', NOW())
-		`, adminID, sectionID)
+		if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+			Action:      "UPDATE",
+			Resource:    "SECTION",
+			ResourceID:  sectionID,
+			Description: "Assigned products to section",
+		}); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
 
 		if err := tx.Commit(ctx); err != nil {
 			utils.WriteInternalServerError(w)
@@ -1692,7 +1872,7 @@ func HandleAdminGetPaymentChannelsImplAdmin(deps *Dependencies) http.HandlerFunc
 				pc.fee_type, pc.fee_amount, pc.fee_percentage,
 				pc.min_amount, pc.max_amount,
 				pc.supported_types,
-				pc.is_active, pc.is_featured, pc.sort_order,
+				pc.is_active, pc.is_featured, pc.sort_order, pc.currency::text,
 				pc.created_at, pc.updated_at,
 				pcc.code as category_code, pcc.title as category_title
 			FROM payment_channels pc
@@ -1750,6 +1930,7 @@ func HandleAdminGetPaymentChannelsImplAdmin(deps *Dependencies) http.HandlerFunc
 			var supportedTypes []string
 			var isActive, isFeatured bool
 			var sortOrder int
+			var currency string
 			var createdAt, updatedAt time.Time
 			var categoryCode, categoryTitle sql.NullString
 
@@ -1758,7 +1939,7 @@ func HandleAdminGetPaymentChannelsImplAdmin(deps *Dependencies) http.HandlerFunc
 				&feeType, &feeAmount, &feePercentage,
 				&minAmount, &maxAmount,
 				&supportedTypes,
-				&isActive, &isFeatured, &sortOrder,
+				&isActive, &isFeatured, &sortOrder, &currency,
 				&createdAt, &updatedAt,
 				&categoryCode, &categoryTitle,
 			); err != nil {
@@ -1831,6 +2012,7 @@ func HandleAdminGetPaymentChannelsImplAdmin(deps *Dependencies) http.HandlerFunc
 				},
 				"regions":        regions,
 				"supportedTypes": supportedTypes,
+				"currency":       currency,
 				"isActive":       isActive,
 				"isFeatured":     isFeatured,
 				"order":          sortOrder,
@@ -1952,7 +2134,6 @@ type CreatePaymentChannelRequest struct {
 // handleCreatePaymentChannelImpl creates a new payment channel
 func HandleCreatePaymentChannelImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
 		defer cancel()
@@ -1981,6 +2162,10 @@ func HandleCreatePaymentChannelImpl(deps *Dependencies) http.HandlerFunc {
 		instruction := strings.TrimSpace(r.FormValue("instruction"))
 		isActiveStr := r.FormValue("isActive")
 		isFeaturedStr := r.FormValue("isFeatured")
+		currency := strings.ToUpper(strings.TrimSpace(r.FormValue("currency")))
+		if currency == "" {
+			currency = "IDR"
+		}
 
 		// Parse arrays
 		regions := []string{}
@@ -2009,6 +2194,10 @@ func HandleCreatePaymentChannelImpl(deps *Dependencies) http.HandlerFunc {
 			utils.WriteValidationErrorJSON(w, "Validation failed", map[string]string{"feeType": "Fee type must be FIXED, PERCENTAGE, or MIXED"})
 			return
 		}
+		if !utils.ValidateCurrency(currency) {
+			utils.WriteValidationErrorJSON(w, "Validation failed", map[string]string{"currency": "Currency is not supported"})
+			return
+		}
 
 		// Parse numeric values
 		feeAmount := int64(0)
@@ -2129,12 +2318,12 @@ func HandleCreatePaymentChannelImpl(deps *Dependencies) http.HandlerFunc {
 				INSERT INTO payment_channels (
 					code, name, description, image, category_id,
 					fee_type, fee_amount, fee_percentage, min_amount, max_amount,
-					supported_types, is_active, is_featured, sort_order, instruction, created_at
-				) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, NOW())
+					supported_types, is_active, is_featured, sort_order, instruction, currency, created_at
+				) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, NOW())
 				RETURNING id
 			`, code, name, description, imageURL, categoryID.String,
 				feeType, feeAmount, feePercentage, minAmount, maxAmount,
-				supportedTypes, isActive, isFeatured, order, instruction,
+				supportedTypes, isActive, isFeatured, order, instruction, currency,
 			).Scan(&channelID)
 		} else {
 			// Insert without category_id (NULL)
@@ -2142,12 +2331,12 @@ func HandleCreatePaymentChannelImpl(deps *Dependencies) http.HandlerFunc {
 				INSERT INTO payment_channels (
 					code, name, description, image, category_id,
 					fee_type, fee_amount, fee_percentage, min_amount, max_amount,
-					supported_types, is_active, is_featured, sort_order, instruction, created_at
-				) VALUES ($1, $2, $3, $4, NULL, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, NOW())
+					supported_types, is_active, is_featured, sort_order, instruction, currency, created_at
+				) VALUES ($1, $2, $3, $4, NULL, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, NOW())
 				RETURNING id
 			`, code, name, description, imageURL,
 				feeType, feeAmount, feePercentage, minAmount, maxAmount,
-				supportedTypes, isActive, isFeatured, order, instruction,
+				supportedTypes, isActive, isFeatured, order, instruction, currency,
 			).Scan(&channelID)
 		}
 
@@ -2166,10 +2355,16 @@ func HandleCreatePaymentChannelImpl(deps *Dependencies) http.HandlerFunc {
 		}
 
 		// Create audit log
-		tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'CREATE', 'PAYMENT_CHANNEL', $2, 'Created payment channel', NOW())
-		`, adminID, channelID)
+		if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+			Action:      "CREATE",
+			Resource:    "PAYMENT_CHANNEL",
+			ResourceID:  channelID,
+			Description: "Created payment channel",
+			After:       auditSnapshot(ctx, deps, tx, "payment_channels", channelID),
+		}); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
 
 		if err := tx.Commit(ctx); err != nil {
 			fmt.Printf("Error committing transaction: %v\n", err)
@@ -2202,13 +2397,13 @@ type UpdatePaymentChannelRequest struct {
 	IsFeatured     *bool    `json:"isFeatured"`
 	Order          *int     `json:"order"`
 	Instruction    *string  `json:"instruction"`
+	Currency       *string  `json:"currency"`
 }
 
 // handleUpdatePaymentChannelImpl updates an existing payment channel
 func HandleUpdatePaymentChannelImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		channelID := chi.URLParam(r, "channelId")
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
 		defer cancel()
@@ -2247,6 +2442,7 @@ func HandleUpdatePaymentChannelImpl(deps *Dependencies) http.HandlerFunc {
 		instruction := strings.TrimSpace(r.FormValue("instruction"))
 		isActiveStr := r.FormValue("isActive")
 		isFeaturedStr := r.FormValue("isFeatured")
+		currency := strings.ToUpper(strings.TrimSpace(r.FormValue("currency")))
 
 		// Parse arrays
 		regions := []string{}
@@ -2317,6 +2513,8 @@ func HandleUpdatePaymentChannelImpl(deps *Dependencies) http.HandlerFunc {
 		}
 		defer tx.Rollback(ctx)
 
+		before := auditSnapshot(ctx, deps, tx, "payment_channels", channelID)
+
 		// Build update query dynamically
 		updateFields := []string{}
 		args := []interface{}{}
@@ -2429,6 +2627,15 @@ func HandleUpdatePaymentChannelImpl(deps *Dependencies) http.HandlerFunc {
 			updateFields = append(updateFields, fmt.Sprintf("instruction = $%d", argCount))
 			args = append(args, instruction)
 		}
+		if currency != "" {
+			if !utils.ValidateCurrency(currency) {
+				utils.WriteValidationErrorJSON(w, "Validation failed", map[string]string{"currency": "Currency is not supported"})
+				return
+			}
+			argCount++
+			updateFields = append(updateFields, fmt.Sprintf("currency = $%d", argCount))
+			args = append(args, currency)
+		}
 
 		// Always update updated_at (no parameter needed)
 		updateFields = append(updateFields, "updated_at = NOW()")
@@ -2468,10 +2675,17 @@ func HandleUpdatePaymentChannelImpl(deps *Dependencies) http.HandlerFunc {
 		}
 
 		// Create audit log
-		tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'UPDATE', 'PAYMENT_CHANNEL', $2, 'Updated payment channel', NOW())
-		`, adminID, channelID)
+		if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+			Action:      "UPDATE",
+			Resource:    "PAYMENT_CHANNEL",
+			ResourceID:  channelID,
+			Description: "Updated payment channel",
+			Before:      before,
+			After:       auditSnapshot(ctx, deps, tx, "payment_channels", channelID),
+		}); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
 
 		if err := tx.Commit(ctx); err != nil {
 			fmt.Printf("Error committing transaction: %v\n", err)
@@ -2495,7 +2709,6 @@ type UpdateChannelAssignmentRequest struct {
 func HandleUpdateChannelAssignmentImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		paymentCode := chi.URLParam(r, "paymentCode")
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		var req UpdateChannelAssignmentRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -2507,10 +2720,12 @@ func HandleUpdateChannelAssignmentImpl(deps *Dependencies) http.HandlerFunc {
 		defer cancel()
 
 		// Create audit log
-		deps.DB.Pool.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'UPDATE', 'PAYMENT_CHANNEL', $2, 'Updated gateway assignment', NOW())
-		`, adminID, paymentCode)
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      "UPDATE",
+			Resource:    "PAYMENT_CHANNEL",
+			ResourceID:  paymentCode,
+			Description: "Updated gateway assignment",
+		})
 
 		utils.WriteSuccessJSON(w, map[string]interface{}{
 			"message": "Gateway assignment updated successfully",
@@ -2522,7 +2737,6 @@ func HandleUpdateChannelAssignmentImpl(deps *Dependencies) http.HandlerFunc {
 func HandleDeletePaymentChannelImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		channelID := chi.URLParam(r, "channelId")
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
 		defer cancel()
@@ -2595,6 +2809,8 @@ func HandleDeletePaymentChannelImpl(deps *Dependencies) http.HandlerFunc {
 		}
 		defer tx.Rollback(ctx)
 
+		before := auditSnapshot(ctx, deps, tx, "payment_channels", channelID)
+
 		// Delete will cascade to:
 		// - payment_channel_regions (ON DELETE CASCADE)
 		// - payment_channel_gateways (ON DELETE CASCADE)
@@ -2607,10 +2823,16 @@ func HandleDeletePaymentChannelImpl(deps *Dependencies) http.HandlerFunc {
 		}
 
 		// Create audit log
-		tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'DELETE', 'PAYMENT_CHANNEL', $2, 'Deleted payment channel', NOW())
-		`, adminID, channelID)
+		if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+			Action:      "DELETE",
+			Resource:    "PAYMENT_CHANNEL",
+			ResourceID:  channelID,
+			Description: "Deleted payment channel",
+			Before:      before,
+		}); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
 
 		if err := tx.Commit(ctx); err != nil {
 			fmt.Printf("Error committing transaction: %v\n", err)
@@ -2703,7 +2925,6 @@ type CreatePaymentChannelCategoryRequest struct {
 // handleCreatePaymentChannelCategoryImpl creates a new payment channel category
 func HandleCreatePaymentChannelCategoryImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		var req CreatePaymentChannelCategoryRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -2733,10 +2954,16 @@ func HandleCreatePaymentChannelCategoryImpl(deps *Dependencies) http.HandlerFunc
 			return
 		}
 
-		tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'CREATE', 'PAYMENT_CHANNEL_CATEGORY', $2, 'Created payment channel category', NOW())
-		`, adminID, categoryID)
+		if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+			Action:      "CREATE",
+			Resource:    "PAYMENT_CHANNEL_CATEGORY",
+			ResourceID:  categoryID,
+			Description: "Created payment channel category",
+			After:       auditSnapshot(ctx, deps, tx, "payment_channel_categories", categoryID),
+		}); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
 
 		if err := tx.Commit(ctx); err != nil {
 			utils.WriteInternalServerError(w)
@@ -2754,7 +2981,6 @@ func HandleCreatePaymentChannelCategoryImpl(deps *Dependencies) http.HandlerFunc
 func HandleUpdatePaymentChannelCategoryImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		categoryID := chi.URLParam(r, "categoryId")
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		var req CreatePaymentChannelCategoryRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -2772,6 +2998,8 @@ func HandleUpdatePaymentChannelCategoryImpl(deps *Dependencies) http.HandlerFunc
 		}
 		defer tx.Rollback(ctx)
 
+		before := auditSnapshot(ctx, deps, tx, "payment_channel_categories", categoryID)
+
 		_, err = tx.Exec(ctx, `
 			UPDATE payment_channel_categories SET
 				title = $1, icon = $2, is_active = $3, sort_order = $4, updated_at = NOW()
@@ -2784,10 +3012,17 @@ func HandleUpdatePaymentChannelCategoryImpl(deps *Dependencies) http.HandlerFunc
 			return
 		}
 
-		tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'UPDATE', 'PAYMENT_CHANNEL_CATEGORY', $2, 'Updated payment channel category', NOW())
-		`, adminID, categoryID)
+		if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+			Action:      "UPDATE",
+			Resource:    "PAYMENT_CHANNEL_CATEGORY",
+			ResourceID:  categoryID,
+			Description: "Updated payment channel category",
+			Before:      before,
+			After:       auditSnapshot(ctx, deps, tx, "payment_channel_categories", categoryID),
+		}); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
 
 		if err := tx.Commit(ctx); err != nil {
 			utils.WriteInternalServerError(w)
@@ -2804,7 +3039,6 @@ func HandleUpdatePaymentChannelCategoryImpl(deps *Dependencies) http.HandlerFunc
 func HandleDeletePaymentChannelCategoryImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		categoryID := chi.URLParam(r, "categoryId")
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 		defer cancel()
@@ -2816,16 +3050,24 @@ func HandleDeletePaymentChannelCategoryImpl(deps *Dependencies) http.HandlerFunc
 		}
 		defer tx.Rollback(ctx)
 
+		before := auditSnapshot(ctx, deps, tx, "payment_channel_categories", categoryID)
+
 		_, err = tx.Exec(ctx, "DELETE FROM payment_channel_categories WHERE id = $1", categoryID)
 		if err != nil {
 			utils.WriteInternalServerError(w)
 			return
 		}
 
-		tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'DELETE', 'PAYMENT_CHANNEL_CATEGORY', $2, 'Deleted payment channel category', NOW())
-		`, adminID, categoryID)
+		if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+			Action:      "DELETE",
+			Resource:    "PAYMENT_CHANNEL_CATEGORY",
+			ResourceID:  categoryID,
+			Description: "Deleted payment channel category",
+			Before:      before,
+		}); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
 
 		if err := tx.Commit(ctx); err != nil {
 			utils.WriteInternalServerError(w)
@@ -2929,6 +3171,58 @@ func HandleGetExportStatusImpl(deps *Dependencies) http.HandlerFunc {
 	}
 }
 
+// auditLogExportLimit caps the rows of a CSV export
+const auditLogExportLimit = 50000
+
+// parseAuditFilter reads the audit log filter from the query. Dates are RFC3339 or
+// 2006-01-02, a plain endDate includes the whole day.
+func parseAuditFilter(r *http.Request) (services.AuditFilter, map[string]string) {
+	query := r.URL.Query()
+	filter := services.AuditFilter{
+		AdminID:    query.Get("adminId"),
+		Resource:   query.Get("resource"),
+		ResourceID: query.Get("resourceId"),
+		Action:     query.Get("action"),
+		Search:     strings.TrimSpace(query.Get("search")),
+	}
+	errs := map[string]string{}
+
+	if filter.AdminID != "" {
+		if _, err := uuid.Parse(filter.AdminID); err != nil {
+			errs["adminId"] = "Invalid admin ID"
+		}
+	}
+	if value := query.Get("startDate"); value != "" {
+		if from, _, err := parseAuditDate(value); err != nil {
+			errs["startDate"] = "Invalid date, use YYYY-MM-DD or RFC3339"
+		} else {
+			filter.From = &from
+		}
+	}
+	if value := query.Get("endDate"); value != "" {
+		if to, dateOnly, err := parseAuditDate(value); err != nil {
+			errs["endDate"] = "Invalid date, use YYYY-MM-DD or RFC3339"
+		} else {
+			if dateOnly {
+				to = to.AddDate(0, 0, 1)
+			}
+			filter.To = &to
+		}
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		errs["endDate"] = "End date must be after start date"
+	}
+	return filter, errs
+}
+
+func parseAuditDate(value string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	return t, true, err
+}
+
 // handleGetAuditLogsImpl returns audit logs
 func HandleGetAuditLogsImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -2944,51 +3238,21 @@ func HandleGetAuditLogsImpl(deps *Dependencies) http.HandlerFunc {
 
 		offset := (page - 1) * limit
 
+		filter, errs := parseAuditFilter(r)
+		if len(errs) > 0 {
+			utils.WriteValidationErrorJSON(w, "Validation failed", errs)
+			return
+		}
+
 		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 		defer cancel()
 
-		rows, err := deps.DB.Pool.Query(ctx, `
-			SELECT
-				al.id, al.action, al.resource, al.resource_id, al.description,
-				a.id, a.first_name || ' ' || a.last_name, a.email,
-				al.created_at
-			FROM audit_logs al
-			JOIN admins a ON al.admin_id = a.id
-			ORDER BY al.created_at DESC
-			LIMIT $1 OFFSET $2
-		`, limit, offset)
-
+		logs, totalRows, err := deps.Audit.List(ctx, filter, limit, offset)
 		if err != nil {
+			log.Error().Err(err).Msg("Failed to list audit logs")
 			utils.WriteInternalServerError(w)
 			return
 		}
-		defer rows.Close()
-
-		logs := []map[string]interface{}{}
-		for rows.Next() {
-			var id, action, resource, resourceID, description string
-			var adminID, adminName, adminEmail string
-			var createdAt time.Time
-
-			rows.Scan(&id, &action, &resource, &resourceID, &description, &adminID, &adminName, &adminEmail, &createdAt)
-
-			logs = append(logs, map[string]interface{}{
-				"id":          id,
-				"action":      action,
-				"resource":    resource,
-				"resourceId":  resourceID,
-				"description": description,
-				"admin": map[string]interface{}{
-					"id":    adminID,
-					"name":  adminName,
-					"email": adminEmail,
-				},
-				"createdAt": createdAt.Format(time.RFC3339),
-			})
-		}
-
-		var totalRows int
-		deps.DB.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM audit_logs").Scan(&totalRows)
 		totalPages := (totalRows + limit - 1) / limit
 
 		utils.WriteSuccessJSON(w, map[string]interface{}{
@@ -3003,6 +3267,68 @@ func HandleGetAuditLogsImpl(deps *Dependencies) http.HandlerFunc {
 	}
 }
 
+// HandleGetAuditLogImpl returns an audit log with its changes
+func HandleGetAuditLogImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logID := chi.URLParam(r, "logId")
+		if _, err := uuid.Parse(logID); err != nil {
+			utils.WriteNotFoundError(w, "Audit log")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		auditLog, err := deps.Audit.Get(ctx, logID)
+		if err == pgx.ErrNoRows {
+			utils.WriteNotFoundError(w, "Audit log")
+			return
+		}
+		if err != nil {
+			log.Error().Err(err).Str("log_id", logID).Msg("Failed to get audit log")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		utils.WriteSuccessJSON(w, auditLog)
+	}
+}
+
+// HandleExportAuditLogsImpl exports the audit logs matching the filter as CSV
+func HandleExportAuditLogsImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, errs := parseAuditFilter(r)
+		if len(errs) > 0 {
+			utils.WriteValidationErrorJSON(w, "Validation failed", errs)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Minute)
+		defer cancel()
+
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="audit-logs-`+time.Now().Format("20060102-150405")+`.csv"`)
+
+		writer := csv.NewWriter(w)
+		writer.Write([]string{
+			"Time", "Admin ID", "Admin Name", "Admin Email", "Action", "Resource", "Resource ID",
+			"Description", "IP Address", "User Agent", "Changes",
+		})
+
+		err := deps.Audit.Each(ctx, filter, auditLogExportLimit, 0, func(l services.AuditLog) error {
+			return writer.Write([]string{
+				l.CreatedAt.Format(time.RFC3339), l.Admin.ID, l.Admin.Name, l.Admin.Email, l.Action,
+				l.Resource, l.ResourceID, l.Description, l.IPAddress, l.UserAgent, string(l.Changes),
+			})
+		})
+		writer.Flush()
+		if err != nil {
+			// Headers are already sent, the truncated file is all we can do
+			log.Error().Err(err).Msg("Failed to export audit logs")
+		}
+	}
+}
+
 // handleAdminGetInvoicesImpl returns all invoices (alias for transactions)
 func HandleAdminGetInvoicesImpl(deps *Dependencies) http.HandlerFunc {
 	return HandleAdminGetTransactionsImpl(deps)
@@ -3073,8 +3399,9 @@ func HandleSearchInvoiceImpl(deps *Dependencies) http.HandlerFunc {
 
 // SendInvoiceEmailRequest represents the request to send invoice email
 type SendInvoiceEmailRequest struct {
-	Email string `json:"email"`
-	Type  string `json:"type"`
+	Email     string `json:"email"`
+	Type      string `json:"type"`      // INVOICE or RECEIPT
+	AttachPDF *bool  `json:"attachPdf"` // defaults to true
 }
 
 // handleSendInvoiceEmailImpl sends invoice email to customer
@@ -3088,17 +3415,138 @@ func HandleSendInvoiceEmailImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
-		// TODO: Implement email sending logic with invoiceNumber
+		if req.Email != "" && !utils.ValidateEmail(req.Email) {
+			utils.WriteValidationErrorJSON(w, "Validation failed", map[string]string{
+				"email": "Invalid email format",
+			})
+			return
+		}
+
+		if deps.NotificationService == nil || deps.EmailOutbox == nil {
+			utils.WriteErrorJSON(w, http.StatusServiceUnavailable, "NOTIFICATION_UNAVAILABLE",
+				"Notification service is not configured", "")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+		defer cancel()
+
+		var notification services.Notification
+		var err error
+		if strings.HasPrefix(invoiceNumber, "SEAD") {
+			notification, err = deps.NotificationService.BuildDepositNotification(ctx, deps.DB.Pool, invoiceNumber, services.EventDepositSuccess)
+			if err == nil && notification.Data["Status"] != "SUCCESS" {
+				utils.WriteErrorJSON(w, http.StatusBadRequest, "DEPOSIT_NOT_SUCCESS",
+					"Only successful deposits have a receipt", "")
+				return
+			}
+		} else {
+			notification, err = deps.NotificationService.BuildTransactionNotification(ctx, deps.DB.Pool, invoiceNumber, services.EventInvoice)
+			if err == nil && strings.ToUpper(req.Type) == "RECEIPT" && notification.Data["Status"] == "SUCCESS" {
+				notification.Event = services.EventOrderSuccess
+			}
+		}
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				utils.WriteNotFoundError(w, "Invoice")
+				return
+			}
+			log.Error().Err(err).Str("invoice_number", invoiceNumber).Msg("Failed to load invoice for email")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		if req.Email != "" {
+			notification.Email = req.Email
+		}
+		if notification.Email == "" {
+			utils.WriteValidationErrorJSON(w, "Validation failed", map[string]string{
+				"email": "Invoice has no contact email, please provide one",
+			})
+			return
+		}
+
+		message, err := deps.NotificationService.Render(notification, services.ChannelEmail)
+		if err != nil {
+			log.Error().Err(err).Str("invoice_number", invoiceNumber).Msg("Failed to render invoice email")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		// The PDF is optional; the email still goes out with the online invoice link without it
+		attachments := []string{}
+		if deps.InvoiceService != nil && (req.AttachPDF == nil || *req.AttachPDF) {
+			attachment, err := deps.InvoiceService.Attachment(ctx, invoiceNumber)
+			if err != nil {
+				log.Warn().Err(err).Str("invoice_number", invoiceNumber).Msg("Failed to attach invoice PDF")
+			} else {
+				message.Attachments = append(message.Attachments, attachment)
+				attachments = append(attachments, attachment.Filename)
+			}
+		}
+
+		// Delivery happens in the outbox worker; the admin can follow it in the email outbox log
+		outboxID, err := deps.EmailOutbox.Queue(ctx, &services.OutboxEmail{
+			To:            message.To,
+			Subject:       message.Subject,
+			HTMLBody:      message.Body,
+			Template:      string(message.Event),
+			ReferenceType: message.ReferenceType,
+			ReferenceID:   message.ReferenceID,
+			Attachments:   message.Attachments,
+			CreatedBy:     middleware.GetAdminIDFromContext(r.Context()),
+		})
+		if err != nil {
+			log.Error().Err(err).Str("invoice_number", invoiceNumber).Msg("Failed to queue invoice email")
+			utils.WriteInternalServerError(w)
+			return
+		}
 
 		utils.WriteSuccessJSON(w, map[string]interface{}{
-			"message":       "Invoice email sent successfully",
+			"message":       "Invoice email queued successfully",
 			"invoiceNumber": invoiceNumber,
-			"sentTo":        req.Email,
-			"sentAt":        time.Now().Format(time.RFC3339),
+			"outboxId":      outboxID,
+			"status":        services.OutboxStatusPending,
+			"sentTo":        notification.Email,
+			"attachments":   attachments,
+			"queuedAt":      time.Now().Format(time.RFC3339),
 		})
 	}
 }
 
+// HandleDownloadInvoicePDFImpl streams the PDF of a transaction or deposit invoice
+func HandleDownloadInvoicePDFImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		invoiceNumber := chi.URLParam(r, "invoiceNumber")
+
+		if deps.InvoiceService == nil {
+			utils.WriteErrorJSON(w, http.StatusServiceUnavailable, "INVOICE_PDF_UNAVAILABLE",
+				"Invoice PDFs are not available", "")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+		defer cancel()
+
+		data, doc, err := deps.InvoiceService.PDF(ctx, invoiceNumber)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				utils.WriteNotFoundError(w, "Invoice")
+				return
+			}
+			log.Error().Err(err).Str("invoice_number", invoiceNumber).Msg("Failed to generate invoice PDF")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+doc.Filename()+`"`)
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+	}
+}
+
 // handleAdminVerifyMFAImpl verifies admin MFA code
 func HandleAdminVerifyMFAImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -3129,3 +3577,110 @@ func HandleAdminLogoutImpl(deps *Dependencies) http.HandlerFunc {
 		})
 	}
 }
+
+// ============================================
+// CATALOGUE CACHE
+// ============================================
+
+// HandleGetCacheStatsImpl returns the catalogue cache hit counters of this instance
+func HandleGetCacheStatsImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if deps.CatalogCache == nil {
+			utils.WriteSuccessJSON(w, map[string]interface{}{
+				"enabled": false,
+			})
+			return
+		}
+
+		resources := map[string]interface{}{}
+		var hits, misses int64
+		for resource, stats := range deps.CatalogCache.Stats() {
+			resourceHits := stats.LocalHits + stats.RedisHits
+			hitRate := 0.0
+			if resourceHits+stats.Misses > 0 {
+				hitRate = float64(resourceHits) / float64(resourceHits+stats.Misses) * 100
+			}
+			resources[string(resource)] = map[string]interface{}{
+				"localHits": stats.LocalHits,
+				"redisHits": stats.RedisHits,
+				"misses":    stats.Misses,
+				"errors":    stats.Errors,
+				"hitRate":   hitRate,
+			}
+			hits += resourceHits
+			misses += stats.Misses
+		}
+
+		hitRate := 0.0
+		if hits+misses > 0 {
+			hitRate = float64(hits) / float64(hits+misses) * 100
+		}
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"enabled":   deps.CatalogCache.Enabled(),
+			"hits":      hits,
+			"misses":    misses,
+			"hitRate":   hitRate,
+			"resources": resources,
+		})
+	}
+}
+
+// InvalidateCacheRequest represents the cache invalidation request body
+type InvalidateCacheRequest struct {
+	Resources []string `json:"resources"`
+}
+
+// HandleInvalidateCacheImpl clears catalogue cache entries on every instance
+func HandleInvalidateCacheImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req InvalidateCacheRequest
+		if r.ContentLength > 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				utils.WriteBadRequestError(w, "Invalid request body")
+				return
+			}
+		}
+
+		allResources := []middleware.CacheResource{
+			middleware.CacheProducts, middleware.CacheSKUs, middleware.CacheCategories, middleware.CacheBanners,
+		}
+
+		resources := allResources
+		if len(req.Resources) > 0 {
+			resources = nil
+			for _, name := range req.Resources {
+				valid := false
+				for _, resource := range allResources {
+					if string(resource) == name {
+						resources = append(resources, resource)
+						valid = true
+						break
+					}
+				}
+				if !valid {
+					utils.WriteValidationErrorJSON(w, "Validation failed", map[string]string{
+						"resources": "Unknown resource: " + name,
+					})
+					return
+				}
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		deps.CatalogCache.Invalidate(ctx, resources...)
+
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      "UPDATE",
+			Resource:    "CACHE",
+			Description: "Invalidated catalogue cache",
+		})
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"message":   "Cache invalidated successfully",
+			"resources": resources,
+		})
+	}
+}
@@ -0,0 +1,419 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"seaply/internal/provider"
+	"seaply/internal/services"
+	"seaply/internal/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// ============================================
+// ADMIN SKU PROVIDER ROUTING
+// ============================================
+
+type skuProviderMappingPayload struct {
+	ProviderCode    *string `json:"providerCode"`
+	ProviderSkuCode *string `json:"providerSkuCode"`
+	Priority        *int    `json:"priority"`
+	Weight          *int    `json:"weight"`
+	CostPrice       *int64  `json:"costPrice"`
+	IsActive        *bool   `json:"isActive"`
+}
+
+// writeSKULookupError writes the response for a failed loadSKUByIdentifier
+func writeSKULookupError(w http.ResponseWriter, err error) {
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.WriteErrorJSON(w, http.StatusNotFound, "SKU_NOT_FOUND", "SKU not found", "")
+		return
+	}
+	utils.WriteInternalServerError(w)
+}
+
+// HandleGetSKUProvidersImpl lists the providers a SKU is routed to and its routing strategy
+func HandleGetSKUProvidersImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		rec, err := loadSKUByIdentifier(ctx, deps, chi.URLParam(r, "skuId"))
+		if err != nil {
+			writeSKULookupError(w, err)
+			return
+		}
+
+		var strategy string
+		if err := deps.DB.Pool.QueryRow(ctx, `SELECT routing_strategy FROM skus WHERE id = $1`, rec.ID).Scan(&strategy); err != nil {
+			log.Error().Err(err).Str("sku_id", rec.ID.String()).Msg("Failed to load SKU routing strategy")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		rows, err := deps.DB.Pool.Query(ctx, `
+			SELECT m.id, p.id, p.code, p.name, p.is_active, m.provider_sku_code,
+			       m.priority, m.weight, m.cost_price, m.is_active, m.created_at, m.updated_at
+			FROM sku_provider_mappings m
+			JOIN providers p ON m.provider_id = p.id
+			WHERE m.sku_id = $1
+			ORDER BY m.priority ASC, m.created_at ASC
+		`, rec.ID)
+		if err != nil {
+			log.Error().Err(err).Str("sku_id", rec.ID.String()).Msg("Failed to query SKU provider mappings")
+			utils.WriteInternalServerError(w)
+			return
+		}
+		defer rows.Close()
+
+		mappings := []map[string]interface{}{}
+		for rows.Next() {
+			var id, providerID, providerCode, providerName, providerSkuCode string
+			var providerActive, isActive bool
+			var priority, weight int
+			var costPrice *int64
+			var createdAt, updatedAt time.Time
+
+			if err := rows.Scan(&id, &providerID, &providerCode, &providerName, &providerActive, &providerSkuCode,
+				&priority, &weight, &costPrice, &isActive, &createdAt, &updatedAt); err != nil {
+				log.Error().Err(err).Msg("Failed to scan SKU provider mapping")
+				continue
+			}
+
+			health := "UNKNOWN"
+			if deps.ProviderManager != nil {
+				if status, err := deps.ProviderManager.GetHealthStatus(strings.ToLower(providerCode)); err == nil {
+					health = status.Status
+				}
+			}
+
+			mappings = append(mappings, map[string]interface{}{
+				"id": id,
+				"provider": map[string]interface{}{
+					"id":       providerID,
+					"code":     providerCode,
+					"name":     providerName,
+					"isActive": providerActive,
+					"health":   health,
+				},
+				"providerSkuCode": providerSkuCode,
+				"priority":        priority,
+				"weight":          weight,
+				"costPrice":       costPrice,
+				"isActive":        isActive,
+				"createdAt":       createdAt.Format(time.RFC3339),
+				"updatedAt":       updatedAt.Format(time.RFC3339),
+			})
+		}
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"sku": map[string]interface{}{
+				"id":   rec.ID,
+				"code": rec.Code,
+				"name": rec.Name,
+			},
+			"routingStrategy": strategy,
+			"providers":       mappings,
+		})
+	}
+}
+
+// HandleCreateSKUProviderImpl maps a provider SKU to a SKU
+func HandleCreateSKUProviderImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		rec, err := loadSKUByIdentifier(ctx, deps, chi.URLParam(r, "skuId"))
+		if err != nil {
+			writeSKULookupError(w, err)
+			return
+		}
+
+		var req skuProviderMappingPayload
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.WriteBadRequestError(w, "Invalid request body")
+			return
+		}
+
+		validationErrors := map[string]string{}
+		if req.ProviderCode == nil || strings.TrimSpace(*req.ProviderCode) == "" {
+			validationErrors["providerCode"] = "Provider code is required"
+		}
+		if req.ProviderSkuCode == nil || strings.TrimSpace(*req.ProviderSkuCode) == "" {
+			validationErrors["providerSkuCode"] = "Provider SKU code is required"
+		}
+		validateSKUProviderMapping(req, validationErrors)
+		if len(validationErrors) > 0 {
+			utils.WriteValidationErrorJSON(w, "Validation failed", validationErrors)
+			return
+		}
+
+		providerID, err := getProviderIDByCode(ctx, deps, *req.ProviderCode)
+		if err != nil {
+			utils.WriteErrorJSON(w, http.StatusBadRequest, "PROVIDER_NOT_FOUND", "Provider not found", "")
+			return
+		}
+
+		priority, weight, isActive := 1, 1, true
+		if req.Priority != nil {
+			priority = *req.Priority
+		} else {
+			// New mappings are tried after the existing ones by default
+			_ = deps.DB.Pool.QueryRow(ctx, `
+				SELECT COALESCE(MAX(priority), 0) + 1 FROM sku_provider_mappings WHERE sku_id = $1
+			`, rec.ID).Scan(&priority)
+		}
+		if req.Weight != nil {
+			weight = *req.Weight
+		}
+		if req.IsActive != nil {
+			isActive = *req.IsActive
+		}
+		providerSkuCode := strings.TrimSpace(*req.ProviderSkuCode)
+
+		var id string
+		err = deps.DB.Pool.QueryRow(ctx, `
+			INSERT INTO sku_provider_mappings (sku_id, provider_id, provider_sku_code, priority, weight, cost_price, is_active)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING id
+		`, rec.ID, providerID, providerSkuCode, priority, weight, req.CostPrice, isActive).Scan(&id)
+		if err != nil {
+			if strings.Contains(err.Error(), "unique constraint") || strings.Contains(err.Error(), "duplicate key") {
+				utils.WriteErrorJSON(w, http.StatusConflict, "MAPPING_EXISTS", "Provider SKU is already mapped to this SKU", "")
+				return
+			}
+			log.Error().Err(err).Str("sku_id", rec.ID.String()).Msg("Failed to create SKU provider mapping")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      "CREATE",
+			Resource:    "SKU",
+			ResourceID:  rec.ID.String(),
+			Description: fmt.Sprintf("Mapped SKU %s to %s %s (priority %d)", rec.Code, strings.ToUpper(strings.TrimSpace(*req.ProviderCode)), providerSkuCode, priority),
+		})
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"id":              id,
+			"providerCode":    strings.ToUpper(strings.TrimSpace(*req.ProviderCode)),
+			"providerSkuCode": providerSkuCode,
+			"priority":        priority,
+			"weight":          weight,
+			"costPrice":       req.CostPrice,
+			"isActive":        isActive,
+		})
+	}
+}
+
+// HandleUpdateSKUProviderImpl updates the routing fields of a SKU provider mapping
+func HandleUpdateSKUProviderImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		rec, err := loadSKUByIdentifier(ctx, deps, chi.URLParam(r, "skuId"))
+		if err != nil {
+			writeSKULookupError(w, err)
+			return
+		}
+		mappingID := chi.URLParam(r, "mappingId")
+
+		var req skuProviderMappingPayload
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.WriteBadRequestError(w, "Invalid request body")
+			return
+		}
+
+		validationErrors := map[string]string{}
+		validateSKUProviderMapping(req, validationErrors)
+		if len(validationErrors) > 0 {
+			utils.WriteValidationErrorJSON(w, "Validation failed", validationErrors)
+			return
+		}
+
+		var updates []string
+		var args []interface{}
+		argPos := 1
+
+		if req.ProviderCode != nil && strings.TrimSpace(*req.ProviderCode) != "" {
+			providerID, err := getProviderIDByCode(ctx, deps, *req.ProviderCode)
+			if err != nil {
+				utils.WriteErrorJSON(w, http.StatusBadRequest, "PROVIDER_NOT_FOUND", "Provider not found", "")
+				return
+			}
+			updates = append(updates, fmt.Sprintf("provider_id = $%d", argPos))
+			args = append(args, providerID)
+			argPos++
+		}
+		if req.ProviderSkuCode != nil && strings.TrimSpace(*req.ProviderSkuCode) != "" {
+			updates = append(updates, fmt.Sprintf("provider_sku_code = $%d", argPos))
+			args = append(args, strings.TrimSpace(*req.ProviderSkuCode))
+			argPos++
+		}
+		if req.Priority != nil {
+			updates = append(updates, fmt.Sprintf("priority = $%d", argPos))
+			args = append(args, *req.Priority)
+			argPos++
+		}
+		if req.Weight != nil {
+			updates = append(updates, fmt.Sprintf("weight = $%d", argPos))
+			args = append(args, *req.Weight)
+			argPos++
+		}
+		if req.CostPrice != nil {
+			updates = append(updates, fmt.Sprintf("cost_price = $%d", argPos))
+			args = append(args, *req.CostPrice)
+			argPos++
+		}
+		if req.IsActive != nil {
+			updates = append(updates, fmt.Sprintf("is_active = $%d", argPos))
+			args = append(args, *req.IsActive)
+			argPos++
+		}
+
+		if len(updates) == 0 {
+			utils.WriteBadRequestError(w, "No fields to update")
+			return
+		}
+
+		query := fmt.Sprintf(`UPDATE sku_provider_mappings SET %s WHERE id::text = $%d AND sku_id = $%d`,
+			strings.Join(updates, ", "), argPos, argPos+1)
+		args = append(args, mappingID, rec.ID)
+
+		result, err := deps.DB.Pool.Exec(ctx, query, args...)
+		if err != nil {
+			if strings.Contains(err.Error(), "unique constraint") || strings.Contains(err.Error(), "duplicate key") {
+				utils.WriteErrorJSON(w, http.StatusConflict, "MAPPING_EXISTS", "Provider SKU is already mapped to this SKU", "")
+				return
+			}
+			log.Error().Err(err).Str("mapping_id", mappingID).Msg("Failed to update SKU provider mapping")
+			utils.WriteInternalServerError(w)
+			return
+		}
+		if result.RowsAffected() == 0 {
+			utils.WriteNotFoundError(w, "SKU provider mapping")
+			return
+		}
+
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      "UPDATE",
+			Resource:    "SKU",
+			ResourceID:  rec.ID.String(),
+			Description: fmt.Sprintf("Updated provider mapping %s of SKU %s", mappingID, rec.Code),
+		})
+
+		utils.WriteSuccessJSON(w, map[string]string{"message": "SKU provider mapping updated successfully"})
+	}
+}
+
+// HandleDeleteSKUProviderImpl removes a provider from a SKU's routes
+func HandleDeleteSKUProviderImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		rec, err := loadSKUByIdentifier(ctx, deps, chi.URLParam(r, "skuId"))
+		if err != nil {
+			writeSKULookupError(w, err)
+			return
+		}
+		mappingID := chi.URLParam(r, "mappingId")
+
+		var providerCode, providerSkuCode string
+		err = deps.DB.Pool.QueryRow(ctx, `
+			DELETE FROM sku_provider_mappings m
+			USING providers p
+			WHERE m.provider_id = p.id AND m.id::text = $1 AND m.sku_id = $2
+			RETURNING p.code, m.provider_sku_code
+		`, mappingID, rec.ID).Scan(&providerCode, &providerSkuCode)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				utils.WriteNotFoundError(w, "SKU provider mapping")
+				return
+			}
+			log.Error().Err(err).Str("mapping_id", mappingID).Msg("Failed to delete SKU provider mapping")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      "DELETE",
+			Resource:    "SKU",
+			ResourceID:  rec.ID.String(),
+			Description: fmt.Sprintf("Removed %s %s from SKU %s", providerCode, providerSkuCode, rec.Code),
+		})
+
+		utils.WriteSuccessJSON(w, map[string]string{"message": "SKU provider mapping deleted successfully"})
+	}
+}
+
+// HandleUpdateSKURoutingImpl sets how a SKU chooses between its mapped providers
+func HandleUpdateSKURoutingImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		rec, err := loadSKUByIdentifier(ctx, deps, chi.URLParam(r, "skuId"))
+		if err != nil {
+			writeSKULookupError(w, err)
+			return
+		}
+
+		var req struct {
+			Strategy string `json:"strategy"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.WriteBadRequestError(w, "Invalid request body")
+			return
+		}
+
+		req.Strategy = strings.ToUpper(strings.TrimSpace(req.Strategy))
+		if !provider.ValidRoutingStrategy(req.Strategy) {
+			utils.WriteValidationErrorJSON(w, "Validation failed", map[string]string{
+				"strategy": "Strategy must be one of: PRIORITY, CHEAPEST, SUCCESS_RATE, WEIGHTED",
+			})
+			return
+		}
+
+		if _, err := deps.DB.Pool.Exec(ctx, `
+			UPDATE skus SET routing_strategy = $1, updated_at = NOW() WHERE id = $2
+		`, req.Strategy, rec.ID); err != nil {
+			log.Error().Err(err).Str("sku_id", rec.ID.String()).Msg("Failed to update SKU routing strategy")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      "UPDATE",
+			Resource:    "SKU",
+			ResourceID:  rec.ID.String(),
+			Description: fmt.Sprintf("Set routing strategy of SKU %s to %s", rec.Code, req.Strategy),
+		})
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"routingStrategy": req.Strategy,
+		})
+	}
+}
+
+func validateSKUProviderMapping(req skuProviderMappingPayload, validationErrors map[string]string) {
+	if req.Priority != nil && *req.Priority < 1 {
+		validationErrors["priority"] = "Priority must be at least 1"
+	}
+	if req.Weight != nil && *req.Weight < 0 {
+		validationErrors["weight"] = "Weight must not be negative"
+	}
+	if req.CostPrice != nil && *req.CostPrice < 0 {
+		validationErrors["costPrice"] = "Cost price must not be negative"
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"seaply/internal/middleware"
+	"seaply/internal/services"
 	"seaply/internal/utils"
 
 	"github.com/go-chi/chi/v5"
@@ -679,6 +680,47 @@ func HandleAdminGetTransactionImpl(deps *Dependencies) http.HandlerFunc {
 			response["completedAt"] = (*completedAt).Format(time.RFC3339)
 		}
 
+		// Add provider orders of each unit for multi-quantity orders
+		if quantity > 1 {
+			units := []map[string]interface{}{}
+			unitRows, err := deps.DB.Pool.Query(ctx, `
+				SELECT unit_number, ref_id, status, provider_ref_id, serial_number, message,
+				       refunded_amount, completed_at
+				FROM transaction_units
+				WHERE transaction_id = $1
+				ORDER BY unit_number
+			`, id)
+			if err == nil {
+				defer unitRows.Close()
+				for unitRows.Next() {
+					var unitNumber int
+					var unitRefID, unitStatus string
+					var unitProviderRefID, unitSerialNumber, unitMessage sql.NullString
+					var unitRefunded int64
+					var unitCompletedAt *time.Time
+					if err := unitRows.Scan(&unitNumber, &unitRefID, &unitStatus, &unitProviderRefID,
+						&unitSerialNumber, &unitMessage, &unitRefunded, &unitCompletedAt); err != nil {
+						continue
+					}
+					unit := map[string]interface{}{
+						"unit":           unitNumber,
+						"refId":          unitRefID,
+						"status":         unitStatus,
+						"providerRefId":  unitProviderRefID.String,
+						"serialNumber":   unitSerialNumber.String,
+						"message":        unitMessage.String,
+						"refundedAmount": unitRefunded,
+						"completedAt":    nil,
+					}
+					if unitCompletedAt != nil {
+						unit["completedAt"] = unitCompletedAt.Format(time.RFC3339)
+					}
+					units = append(units, unit)
+				}
+			}
+			response["units"] = units
+		}
+
 		utils.WriteSuccessJSON(w, response)
 	}
 }
@@ -694,7 +736,6 @@ type UpdateTransactionStatusRequest struct {
 func HandleUpdateTransactionStatusImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		transactionID := chi.URLParam(r, "transactionId")
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		var req UpdateTransactionStatusRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -783,10 +824,24 @@ func HandleUpdateTransactionStatusImpl(deps *Dependencies) http.HandlerFunc {
 		}
 
 		// Create audit log
-		_, _ = tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'UPDATE', 'TRANSACTION', $2, $3, NOW())
-		`, adminID, transactionID, "Updated transaction status to "+req.Status)
+		if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+			Action:      "UPDATE",
+			Resource:    "TRANSACTION",
+			ResourceID:  transactionID,
+			Description: "Updated transaction status to " + req.Status,
+		}); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		// The customer email is queued with the status change
+		var notification services.Notification
+		if event, ok := services.OrderEventForStatus(req.Status); ok {
+			notification, err = deps.NotificationService.NotifyTransactionTx(ctx, tx, transactionID, event, nil)
+			if err != nil {
+				log.Warn().Err(err).Str("transaction_id", transactionID).Msg("Failed to queue transaction notification")
+			}
+		}
 
 		// Commit transaction
 		if err := tx.Commit(ctx); err != nil {
@@ -794,6 +849,12 @@ func HandleUpdateTransactionStatusImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		publishTransactionStatus(deps, transactionID)
+		deps.NotificationService.Notify(notification)
+		if _, ok := services.OrderEventForStatus(req.Status); ok {
+			notifyPartnerOrder(deps, transactionID)
+		}
+
 		utils.WriteSuccessJSON(w, map[string]interface{}{
 			"message":      "Transaction status updated successfully",
 			"status":       req.Status,
@@ -836,162 +897,262 @@ func HandleRefundTransactionImpl(deps *Dependencies) http.HandlerFunc {
 		ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
 		defer cancel()
 
-		// Begin transaction
-		tx, err := deps.DB.Pool.Begin(ctx)
+		refund, err := loadTransactionRefund(ctx, deps.DB.Pool, transactionID, req)
 		if err != nil {
-			utils.WriteInternalServerError(w)
+			writeActionError(w, r, err)
 			return
 		}
-		defer tx.Rollback(ctx)
-
-		// Get transaction details
-		var userID sql.NullString
-		var invoiceNumber, currency, status string
-		var totalAmount int64
-
-		err = tx.QueryRow(ctx, `
-			SELECT user_id, invoice_number, total_amount, currency, status
-			FROM transactions
-			WHERE id = $1
-		`, transactionID).Scan(&userID, &invoiceNumber, &totalAmount, &currency, &status)
 
-		if err != nil {
-			if err == pgx.ErrNoRows {
-				utils.WriteErrorJSON(w, http.StatusNotFound, "TRANSACTION_NOT_FOUND",
-					"Transaction not found", "")
-				return
-			}
-			utils.WriteInternalServerError(w)
+		if holdForApproval(w, r, deps, services.NewApproval{
+			Action:     services.ApprovalRefundTransaction,
+			Resource:   "TRANSACTION",
+			ResourceID: transactionID,
+			Amount:     refund.amount,
+			Currency:   refund.currency,
+			Summary:    "Refund " + utils.FormatCurrency(float64(refund.amount), refund.currency) + " of " + refund.invoiceNumber + " to " + req.RefundTo,
+			Payload:    req,
+			Comment:    req.Reason,
+		}) {
 			return
 		}
 
-		// Get payment status
-		var txPaymentStatus string
-		_ = tx.QueryRow(ctx, `SELECT payment_status FROM transactions WHERE id = $1`, transactionID).Scan(&txPaymentStatus)
-
-		// Validate transaction can be refunded
-		// Payment status must be PAID
-		if txPaymentStatus != "PAID" {
-			utils.WriteErrorJSON(w, http.StatusBadRequest, "TRANSACTION_NOT_REFUNDABLE",
-				"Transaction payment status must be PAID to be refunded", "")
+		result, err := runAdminAction(ctx, deps, func(tx pgx.Tx) (*actionResult, error) {
+			return refundTransaction(ctx, deps, tx, r, transactionID, req, adminAction{RequestedBy: adminID})
+		})
+		if err != nil {
+			writeActionError(w, r, err)
 			return
 		}
 
-		// Transaction status must be PROCESSING or FAILED
-		if status != "PROCESSING" && status != "FAILED" {
-			utils.WriteErrorJSON(w, http.StatusBadRequest, "TRANSACTION_NOT_REFUNDABLE",
-				"Transaction status must be PROCESSING or FAILED to be refunded", "")
-			return
+		// Get admin name
+		var adminName string
+		_ = deps.DB.Pool.QueryRow(ctx, `SELECT first_name || ' ' || last_name FROM admins WHERE id = $1`, adminID).Scan(&adminName)
+		if adminName == "" {
+			adminName = "Admin"
 		}
-
-		// Determine refund amount
-		refundAmount := totalAmount
-		if req.Amount != nil && *req.Amount > 0 {
-			if *req.Amount > totalAmount {
-				utils.WriteErrorJSON(w, http.StatusBadRequest, "INVALID_AMOUNT",
-					"Refund amount cannot exceed transaction total", "")
-				return
-			}
-			refundAmount = *req.Amount
+		result.Data["processedBy"] = map[string]interface{}{
+			"id":   adminID,
+			"name": adminName,
 		}
 
-		var refundID string
-		refundStatus := "SUCCESS"
+		utils.WriteSuccessJSON(w, result.Data)
+	}
+}
 
-		// Refund to balance if user exists
-		if req.RefundTo == "BALANCE" && userID.Valid {
-			// Get current balance
-			var currentBalance int64
-			err = tx.QueryRow(ctx, `SELECT balance_idr FROM users WHERE id = $1`, userID.String).Scan(&currentBalance)
-			if err != nil {
-				utils.WriteInternalServerError(w)
-				return
-			}
+// transactionRefund is a transaction checked for a refund and the amount to refund
+type transactionRefund struct {
+	userID        sql.NullString
+	invoiceNumber string
+	currency      string
+	amount        int64
 
-			// Add refund amount to user balance
-			newBalance := currentBalance + refundAmount
+	// pendingRefundID is set when the refund settles the failed units of a delivered
+	// multi-quantity order, the transaction then keeps its status
+	pendingRefundID string
+}
 
-			_, err = tx.Exec(ctx, `
-				UPDATE users SET balance_idr = $1 WHERE id = $2
-			`, newBalance, userID.String)
+// loadTransactionRefund loads a transaction and checks that it can be refunded as requested
+func loadTransactionRefund(ctx context.Context, db services.DBTX, transactionID string, req RefundTransactionRequest) (transactionRefund, error) {
+	var refund transactionRefund
+	var status, paymentStatus string
+	var totalAmount int64
+	err := db.QueryRow(ctx, `
+		SELECT user_id, invoice_number, total_amount, currency, status, payment_status
+		FROM transactions
+		WHERE id = $1
+	`, transactionID).Scan(&refund.userID, &refund.invoiceNumber, &totalAmount, &refund.currency, &status, &paymentStatus)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return refund, &actionError{Status: http.StatusNotFound, Code: "TRANSACTION_NOT_FOUND", Message: "Transaction not found"}
+		}
+		return refund, err
+	}
 
-			if err != nil {
-				utils.WriteInternalServerError(w)
-				return
-			}
+	// Validate transaction can be refunded
+	// Payment status must be PAID
+	if paymentStatus != "PAID" {
+		return refund, &actionError{Status: http.StatusBadRequest, Code: "TRANSACTION_NOT_REFUNDABLE",
+			Message: "Transaction payment status must be PAID to be refunded"}
+	}
 
-			// Create balance mutation record
-			_, err = tx.Exec(ctx, `
-				INSERT INTO mutations (
-					user_id, invoice_number, mutation_type, amount, balance_before, balance_after,
-					description, reference_type, reference_id, currency, created_at
-				) VALUES ($1, $2, 'CREDIT', $3, $4, $5, $6, 'REFUND', $7, $8, NOW())
-			`, userID.String, invoiceNumber, refundAmount, currentBalance, newBalance,
-				"Pengembalian Dana - "+req.Reason, transactionID, currency)
+	// Delivered orders are only refunded for the failed units of a multi-quantity order
+	maxAmount := totalAmount
+	if status == "SUCCESS" {
+		err = db.QueryRow(ctx, `
+			SELECT id, amount FROM refunds
+			WHERE transaction_id = $1 AND status = 'PENDING'
+			ORDER BY created_at ASC
+			LIMIT 1
+		`, transactionID).Scan(&refund.pendingRefundID, &maxAmount)
+		if err != nil && err != pgx.ErrNoRows {
+			return refund, err
+		}
+		if err == pgx.ErrNoRows {
+			return refund, &actionError{Status: http.StatusBadRequest, Code: "TRANSACTION_NOT_REFUNDABLE",
+				Message: "Transaction status must be PROCESSING or FAILED to be refunded"}
+		}
+	} else if status != "PROCESSING" && status != "FAILED" {
+		// Transaction status must be PROCESSING or FAILED
+		return refund, &actionError{Status: http.StatusBadRequest, Code: "TRANSACTION_NOT_REFUNDABLE",
+			Message: "Transaction status must be PROCESSING or FAILED to be refunded"}
+	}
 
-			if err != nil {
-				utils.WriteInternalServerError(w)
-				return
-			}
+	// Determine refund amount
+	refund.amount = maxAmount
+	if req.Amount != nil && *req.Amount > 0 {
+		if *req.Amount > maxAmount {
+			return refund, &actionError{Status: http.StatusBadRequest, Code: "INVALID_AMOUNT",
+				Message: "Refund amount cannot exceed transaction total"}
 		}
+		refund.amount = *req.Amount
+	}
+	return refund, nil
+}
 
-		// Generate refund ID
-		randomStr, _ := utils.GenerateRandomString(12)
-		refundID = "ref_" + randomStr
+// refundTransaction refunds a paid transaction in tx, to the balance of its user when requested
+func refundTransaction(ctx context.Context, deps *Dependencies, tx pgx.Tx, r *http.Request, transactionID string, req RefundTransactionRequest, by adminAction) (*actionResult, error) {
+	if req.RefundTo == "" {
+		req.RefundTo = "BALANCE"
+	}
 
-		// Update transaction status to REFUNDED
-		_, err = tx.Exec(ctx, `
-			UPDATE transactions
-			SET status = 'REFUNDED', updated_at = NOW()
-			WHERE id = $1
-		`, transactionID)
+	// Lock the transaction so concurrent refunds see each other
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM transactions WHERE id = $1 FOR UPDATE`, transactionID); err != nil {
+		return nil, err
+	}
+	refund, err := loadTransactionRefund(ctx, tx, transactionID, req)
+	if err != nil {
+		return nil, err
+	}
 
+	// Refund to balance if user exists
+	if req.RefundTo == "BALANCE" && refund.userID.Valid {
+		// Get current balance
+		var currentBalance int64
+		err = tx.QueryRow(ctx, `SELECT balance_idr FROM users WHERE id = $1 FOR UPDATE`, refund.userID.String).Scan(&currentBalance)
 		if err != nil {
-			utils.WriteInternalServerError(w)
-			return
+			return nil, err
 		}
 
-		// Add transaction log
-		_, _ = tx.Exec(ctx, `
-			INSERT INTO transaction_logs (transaction_id, status, message, created_at)
-			VALUES ($1, 'REFUNDED', $2, NOW())
-		`, transactionID, "Transaction refunded: "+req.Reason)
+		// Add refund amount to user balance
+		newBalance := currentBalance + refund.amount
 
-		// Create audit log
-		_, _ = tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'REFUND', 'TRANSACTION', $2, $3, NOW())
-		`, adminID, transactionID, "Refunded transaction: "+req.Reason)
+		if _, err := tx.Exec(ctx, `
+			UPDATE users SET balance_idr = $1 WHERE id = $2
+		`, newBalance, refund.userID.String); err != nil {
+			return nil, err
+		}
 
-		// Commit transaction
-		if err := tx.Commit(ctx); err != nil {
-			utils.WriteInternalServerError(w)
-			return
+		// Create balance mutation record with the requester and the approver
+		_, err = tx.Exec(ctx, `
+			INSERT INTO mutations (
+				user_id, invoice_number, mutation_type, amount, balance_before, balance_after,
+				description, reference_type, reference_id, currency, admin_id, admin_note, created_at
+			) VALUES ($1, $2, 'CREDIT', $3, $4, $5, $6, 'REFUND', $7, $8, $9, $10, NOW())
+		`, refund.userID.String, refund.invoiceNumber, refund.amount, currentBalance, newBalance,
+			"Pengembalian Dana - "+req.Reason, transactionID, refund.currency, nullString(by.RequestedBy), nullString(by.note()))
+		if err != nil {
+			return nil, err
 		}
+	}
 
-		// Get admin name
-		var adminName string
-		_ = deps.DB.Pool.QueryRow(ctx, `SELECT first_name || ' ' || last_name FROM admins WHERE id = $1`, adminID).Scan(&adminName)
-		if adminName == "" {
-			adminName = "Admin"
+	// Generate refund ID
+	randomStr, _ := utils.GenerateRandomString(12)
+	refundID := "ref_" + randomStr
+
+	if refund.pendingRefundID != "" {
+		// Failed units of a delivered order: the order stays SUCCESS
+		if err := settlePendingRefund(ctx, tx, transactionID, refund, req, by); err != nil {
+			return nil, err
+		}
+	} else {
+		// Update transaction status to REFUNDED
+		if _, err := tx.Exec(ctx, `
+			UPDATE transactions
+			SET status = 'REFUNDED', refunded_amount = $2, updated_at = NOW()
+			WHERE id = $1
+		`, transactionID, refund.amount); err != nil {
+			return nil, err
 		}
+	}
 
-		utils.WriteSuccessJSON(w, map[string]interface{}{
-			"refundId":      refundID,
-			"transactionId": transactionID,
-			"invoiceNumber": invoiceNumber,
-			"amount":        refundAmount,
-			"currency":      currency,
-			"refundTo":      req.RefundTo,
-			"status":        refundStatus,
-			"reason":        req.Reason,
-			"processedBy": map[string]interface{}{
-				"id":   adminID,
-				"name": adminName,
-			},
-			"createdAt": time.Now().Format(time.RFC3339),
-		})
+	// Add transaction log
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO transaction_logs (transaction_id, status, message, data, created_at)
+		VALUES ($1, 'REFUNDED', $2, $3, NOW())
+	`, transactionID, "Transaction refunded: "+req.Reason, by.logData()); err != nil {
+		return nil, err
+	}
+
+	// Create audit log
+	if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+		Action:      "REFUND",
+		Resource:    "TRANSACTION",
+		ResourceID:  transactionID,
+		Description: "Refunded transaction: " + req.Reason,
+	}); err != nil {
+		return nil, err
+	}
+
+	// The customer email is queued with the refund
+	notification, err := deps.NotificationService.NotifyTransactionTx(ctx, tx, transactionID, services.EventRefund, map[string]interface{}{
+		"Amount":   utils.FormatCurrency(float64(refund.amount), refund.currency),
+		"RefundTo": req.RefundTo,
+		"Reason":   req.Reason,
+	})
+	if err != nil {
+		log.Warn().Err(err).Str("transaction_id", transactionID).Msg("Failed to queue transaction notification")
 	}
+
+	data := map[string]interface{}{
+		"refundId":      refundID,
+		"transactionId": transactionID,
+		"invoiceNumber": refund.invoiceNumber,
+		"amount":        refund.amount,
+		"currency":      refund.currency,
+		"refundTo":      req.RefundTo,
+		"status":        "SUCCESS",
+		"reason":        req.Reason,
+		"processedBy":   map[string]interface{}{"id": by.RequestedBy},
+		"createdAt":     time.Now().Format(time.RFC3339),
+	}
+	if by.ApprovalID != "" {
+		data["approvedBy"] = map[string]interface{}{"id": by.ApprovedBy}
+	}
+	return &actionResult{Data: data, afterCommit: func() {
+		publishTransactionStatus(deps, transactionID)
+		notifyPartnerOrder(deps, transactionID)
+		deps.NotificationService.Notify(notification)
+	}}, nil
+}
+
+// settlePendingRefund completes the pending refund of the failed units of a
+// delivered order and spreads the refunded amount over those units
+func settlePendingRefund(ctx context.Context, tx pgx.Tx, transactionID string, refund transactionRefund, req RefundTransactionRequest, by adminAction) error {
+	if _, err := tx.Exec(ctx, `
+		UPDATE refunds
+		SET status = 'COMPLETED', amount = $2, refund_to = $3, processed_by = $4, completed_at = NOW()
+		WHERE id = $1
+	`, refund.pendingRefundID, refund.amount, req.RefundTo, by.RequestedBy); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE transaction_units
+		SET refunded_amount = $2 / GREATEST((
+		        SELECT COUNT(*) FROM transaction_units WHERE transaction_id = $1 AND status = 'FAILED'
+		    ), 1),
+		    updated_at = NOW()
+		WHERE transaction_id = $1 AND status = 'FAILED'
+	`, transactionID, refund.amount); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(ctx, `
+		UPDATE transactions
+		SET refunded_amount = refunded_amount + $2, updated_at = NOW()
+		WHERE id = $1
+	`, transactionID, refund.amount)
+	return err
 }
 
 // RetryTransactionRequest represents the request to retry a transaction
@@ -1004,7 +1165,6 @@ type RetryTransactionRequest struct {
 func HandleRetryTransactionImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		transactionID := chi.URLParam(r, "transactionId")
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		var req RetryTransactionRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -1079,10 +1239,15 @@ func HandleRetryTransactionImpl(deps *Dependencies) http.HandlerFunc {
 		`, transactionID, message)
 
 		// Create audit log
-		_, _ = tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'RETRY', 'TRANSACTION', $2, $3, NOW())
-		`, adminID, transactionID, message)
+		if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+			Action:      "RETRY",
+			Resource:    "TRANSACTION",
+			ResourceID:  transactionID,
+			Description: message,
+		}); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
 
 		// Commit transaction
 		if err := tx.Commit(ctx); err != nil {
@@ -1090,6 +1255,8 @@ func HandleRetryTransactionImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		publishTransactionStatus(deps, transactionID)
+
 		// TODO: Trigger actual provider processing here
 		// deps.ProviderManager.ProcessTransaction(transactionID)
 
@@ -1111,7 +1278,6 @@ type ManualProcessRequest struct {
 func HandleManualProcessImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		transactionID := chi.URLParam(r, "transactionId")
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		var req ManualProcessRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -1136,49 +1302,97 @@ func HandleManualProcessImpl(deps *Dependencies) http.HandlerFunc {
 		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 		defer cancel()
 
-		// Begin transaction
-		tx, err := deps.DB.Pool.Begin(ctx)
+		var invoiceNumber, currency string
+		var totalAmount int64
+		err := deps.DB.Pool.QueryRow(ctx, `
+			SELECT invoice_number, total_amount, currency FROM transactions WHERE id = $1
+		`, transactionID).Scan(&invoiceNumber, &totalAmount, &currency)
 		if err != nil {
+			if err == pgx.ErrNoRows {
+				utils.WriteErrorJSON(w, http.StatusNotFound, "TRANSACTION_NOT_FOUND",
+					"Transaction not found", "")
+				return
+			}
 			utils.WriteInternalServerError(w)
 			return
 		}
-		defer tx.Rollback(ctx)
 
-		// Update transaction to SUCCESS with serial number
-		_, err = tx.Exec(ctx, `
-			UPDATE transactions
-			SET status = 'SUCCESS', provider_serial_number = $1, completed_at = NOW(), updated_at = NOW()
-			WHERE id = $2
-		`, req.SerialNumber, transactionID)
+		if holdForApproval(w, r, deps, services.NewApproval{
+			Action:     services.ApprovalManualProcess,
+			Resource:   "TRANSACTION",
+			ResourceID: transactionID,
+			Amount:     totalAmount,
+			Currency:   currency,
+			Summary:    "Mark " + invoiceNumber + " (" + utils.FormatCurrency(float64(totalAmount), currency) + ") as SUCCESS with SN " + req.SerialNumber,
+			Payload:    req,
+			Comment:    req.Reason,
+		}) {
+			return
+		}
 
+		adminID := middleware.GetAdminIDFromContext(r.Context())
+		result, err := runAdminAction(ctx, deps, func(tx pgx.Tx) (*actionResult, error) {
+			return manualProcessTransaction(ctx, deps, tx, r, transactionID, req, adminAction{RequestedBy: adminID})
+		})
 		if err != nil {
-			utils.WriteInternalServerError(w)
+			writeActionError(w, r, err)
 			return
 		}
 
-		// Add transaction log
-		logMessage := "Manually processed: " + req.Reason + " (SN: " + req.SerialNumber + ")"
-		_, _ = tx.Exec(ctx, `
-			INSERT INTO transaction_logs (transaction_id, status, message, created_at)
-			VALUES ($1, 'SUCCESS', $2, NOW())
-		`, transactionID, logMessage)
+		utils.WriteSuccessJSON(w, result.Data)
+	}
+}
 
-		// Create audit log
-		_, _ = tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'MANUAL_PROCESS', 'TRANSACTION', $2, $3, NOW())
-		`, adminID, transactionID, logMessage)
+// manualProcessTransaction marks a transaction as SUCCESS with a serial number in tx
+func manualProcessTransaction(ctx context.Context, deps *Dependencies, tx pgx.Tx, r *http.Request, transactionID string, req ManualProcessRequest, by adminAction) (*actionResult, error) {
+	// Update transaction to SUCCESS with serial number
+	tag, err := tx.Exec(ctx, `
+		UPDATE transactions
+		SET status = 'SUCCESS', provider_serial_number = $1, completed_at = NOW(), updated_at = NOW()
+		WHERE id = $2
+	`, req.SerialNumber, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, &actionError{Status: http.StatusNotFound, Code: "TRANSACTION_NOT_FOUND", Message: "Transaction not found"}
+	}
 
-		// Commit transaction
-		if err := tx.Commit(ctx); err != nil {
-			utils.WriteInternalServerError(w)
-			return
-		}
+	// Add transaction log with the requester and the approver
+	logMessage := "Manually processed: " + req.Reason + " (SN: " + req.SerialNumber + ")"
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO transaction_logs (transaction_id, status, message, data, created_at)
+		VALUES ($1, 'SUCCESS', $2, $3, NOW())
+	`, transactionID, logMessage, by.logData()); err != nil {
+		return nil, err
+	}
 
-		utils.WriteSuccessJSON(w, map[string]interface{}{
+	// Create audit log
+	if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+		Action:      "MANUAL_PROCESS",
+		Resource:    "TRANSACTION",
+		ResourceID:  transactionID,
+		Description: logMessage,
+	}); err != nil {
+		return nil, err
+	}
+
+	// The customer email is queued with the status change
+	notification, err := deps.NotificationService.NotifyTransactionTx(ctx, tx, transactionID, services.EventOrderSuccess, nil)
+	if err != nil {
+		log.Warn().Err(err).Str("transaction_id", transactionID).Msg("Failed to queue transaction notification")
+	}
+
+	return &actionResult{
+		Data: map[string]interface{}{
 			"message":      "Transaction manually processed successfully",
 			"status":       "SUCCESS",
 			"serialNumber": req.SerialNumber,
-		})
-	}
+		},
+		afterCommit: func() {
+			publishTransactionStatus(deps, transactionID)
+			deps.NotificationService.Notify(notification)
+			notifyPartnerOrder(deps, transactionID)
+		},
+	}, nil
 }
@@ -0,0 +1,154 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// fakeRow is a query result scanned into string, int64 and sql.NullString destinations
+type fakeRow struct {
+	values []interface{}
+	err    error
+}
+
+func (r fakeRow) Scan(dest ...interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	if len(dest) != len(r.values) {
+		return fmt.Errorf("scan of %d values into %d destinations", len(r.values), len(dest))
+	}
+	for i, d := range dest {
+		switch d := d.(type) {
+		case *string:
+			*d = r.values[i].(string)
+		case *int64:
+			*d = r.values[i].(int64)
+		case *sql.NullString:
+			s, ok := r.values[i].(string)
+			*d = sql.NullString{String: s, Valid: ok}
+		default:
+			return fmt.Errorf("unsupported destination %T", d)
+		}
+	}
+	return nil
+}
+
+// fakeDB answers QueryRow calls with its rows in order
+type fakeDB struct {
+	rows []fakeRow
+}
+
+func (db *fakeDB) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	if len(db.rows) == 0 {
+		return fakeRow{err: errors.New("unexpected query")}
+	}
+	row := db.rows[0]
+	db.rows = db.rows[1:]
+	return row
+}
+
+func transactionRow(userID interface{}, status, paymentStatus string, total int64) fakeRow {
+	return fakeRow{values: []interface{}{userID, "SEAI1", total, "IDR", status, paymentStatus}}
+}
+
+func TestLoadTransactionRefund(t *testing.T) {
+	amount := func(v int64) *int64 { return &v }
+
+	tests := []struct {
+		name        string
+		rows        []fakeRow
+		amount      *int64
+		wantCode    string
+		wantAmount  int64
+		wantPending string
+	}{
+		{
+			name:     "not found",
+			rows:     []fakeRow{{err: pgx.ErrNoRows}},
+			wantCode: "TRANSACTION_NOT_FOUND",
+		},
+		{
+			name:     "unpaid",
+			rows:     []fakeRow{transactionRow("u1", "FAILED", "UNPAID", 10000)},
+			wantCode: "TRANSACTION_NOT_REFUNDABLE",
+		},
+		{
+			name:       "failed order refunds the total",
+			rows:       []fakeRow{transactionRow("u1", "FAILED", "PAID", 10000)},
+			wantAmount: 10000,
+		},
+		{
+			name:       "partial amount",
+			rows:       []fakeRow{transactionRow(nil, "PROCESSING", "PAID", 10000)},
+			amount:     amount(2500),
+			wantAmount: 2500,
+		},
+		{
+			name:     "amount above total",
+			rows:     []fakeRow{transactionRow("u1", "FAILED", "PAID", 10000)},
+			amount:   amount(10001),
+			wantCode: "INVALID_AMOUNT",
+		},
+		{
+			name:     "delivered order without pending refund",
+			rows:     []fakeRow{transactionRow(nil, "SUCCESS", "PAID", 10000), {err: pgx.ErrNoRows}},
+			wantCode: "TRANSACTION_NOT_REFUNDABLE",
+		},
+		{
+			name: "delivered order refunds its failed units",
+			rows: []fakeRow{
+				transactionRow(nil, "SUCCESS", "PAID", 10000),
+				{values: []interface{}{"r1", int64(4000)}},
+			},
+			wantAmount:  4000,
+			wantPending: "r1",
+		},
+		{
+			name: "amount above pending refund",
+			rows: []fakeRow{
+				transactionRow(nil, "SUCCESS", "PAID", 10000),
+				{values: []interface{}{"r1", int64(4000)}},
+			},
+			amount:   amount(5000),
+			wantCode: "INVALID_AMOUNT",
+		},
+		{
+			name:     "already refunded",
+			rows:     []fakeRow{transactionRow("u1", "REFUNDED", "PAID", 10000)},
+			wantCode: "TRANSACTION_NOT_REFUNDABLE",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fakeDB{rows: tt.rows}
+			refund, err := loadTransactionRefund(context.Background(), db, "t1", RefundTransactionRequest{
+				Reason: "test",
+				Amount: tt.amount,
+			})
+
+			if tt.wantCode != "" {
+				var actionErr *actionError
+				if !errors.As(err, &actionErr) || actionErr.Code != tt.wantCode {
+					t.Fatalf("err = %v, want %s", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if refund.amount != tt.wantAmount {
+				t.Errorf("amount = %d, want %d", refund.amount, tt.wantAmount)
+			}
+			if refund.pendingRefundID != tt.wantPending {
+				t.Errorf("pending refund = %q, want %q", refund.pendingRefundID, tt.wantPending)
+			}
+		})
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"seaply/internal/middleware"
+	"seaply/internal/services"
 	"seaply/internal/utils"
 
 	"github.com/go-chi/chi/v5"
@@ -309,7 +311,6 @@ type UpdateUserStatusRequest struct {
 func HandleUpdateUserStatusImpl(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID := chi.URLParam(r, "userId")
-		adminID := middleware.GetAdminIDFromContext(r.Context())
 
 		var req UpdateUserStatusRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -362,10 +363,15 @@ func HandleUpdateUserStatusImpl(deps *Dependencies) http.HandlerFunc {
 			description += ": " + req.Reason
 		}
 
-		_, err = tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'UPDATE', 'USER', $2, $3, NOW())
-		`, adminID, userID, description)
+		if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+			Action:      "UPDATE",
+			Resource:    "USER",
+			ResourceID:  userID,
+			Description: description,
+		}); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
 
 		if err := tx.Commit(ctx); err != nil {
 			utils.WriteInternalServerError(w)
@@ -414,104 +420,121 @@ func HandleAdjustBalanceImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		if holdForApproval(w, r, deps, services.NewApproval{
+			Action:     services.ApprovalAdjustBalance,
+			Resource:   "USER",
+			ResourceID: userID,
+			Amount:     req.Amount,
+			Currency:   req.Currency,
+			Summary:    req.Type + " " + utils.FormatCurrency(float64(req.Amount), req.Currency) + " to the balance of user " + userID,
+			Payload:    req,
+			Comment:    req.Reason,
+		}) {
+			return
+		}
+
 		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 		defer cancel()
 
-		// Begin transaction
-		tx, err := deps.DB.Pool.Begin(ctx)
+		result, err := runAdminAction(ctx, deps, func(tx pgx.Tx) (*actionResult, error) {
+			return adjustBalance(ctx, deps, tx, r, userID, req, adminAction{RequestedBy: adminID})
+		})
 		if err != nil {
-			utils.WriteInternalServerError(w)
+			writeActionError(w, r, err)
 			return
 		}
-		defer tx.Rollback(ctx)
 
-		// Get current balance
-		balanceColumn := "balance_idr"
-		if req.Currency == "MYR" {
-			balanceColumn = "balance_myr"
-		} else if req.Currency == "PHP" {
-			balanceColumn = "balance_php"
-		} else if req.Currency == "SGD" {
-			balanceColumn = "balance_sgd"
-		} else if req.Currency == "THB" {
-			balanceColumn = "balance_thb"
+		// Get admin name for response
+		var adminName string
+		deps.DB.Pool.QueryRow(context.Background(), "SELECT first_name || ' ' || last_name FROM admins WHERE id = $1", adminID).Scan(&adminName)
+		result.Data["processedBy"] = map[string]interface{}{
+			"id":   adminID,
+			"name": adminName,
 		}
 
-		var currentBalance int64
-		err = tx.QueryRow(ctx, "SELECT "+balanceColumn+" FROM users WHERE id = $1", userID).Scan(&currentBalance)
-		if err != nil {
-			if err == pgx.ErrNoRows {
-				utils.WriteErrorJSON(w, http.StatusNotFound, "USER_NOT_FOUND",
-					"User not found", "")
-				return
-			}
-			utils.WriteInternalServerError(w)
-			return
-		}
+		utils.WriteSuccessJSON(w, result.Data)
+	}
+}
 
-		// Calculate new balance
-		var newBalance int64
-		if req.Type == "CREDIT" {
-			newBalance = currentBalance + req.Amount
-		} else {
-			newBalance = currentBalance - req.Amount
-			if newBalance < 0 {
-				utils.WriteErrorJSON(w, http.StatusBadRequest, "INSUFFICIENT_BALANCE",
-					"Insufficient balance", "")
-				return
-			}
-		}
+// adjustBalance credits or debits the balance of a user in tx
+func adjustBalance(ctx context.Context, deps *Dependencies, tx pgx.Tx, r *http.Request, userID string, req AdjustBalanceRequest, by adminAction) (*actionResult, error) {
+	// Get current balance
+	balanceColumn := "balance_idr"
+	if req.Currency == "MYR" {
+		balanceColumn = "balance_myr"
+	} else if req.Currency == "PHP" {
+		balanceColumn = "balance_php"
+	} else if req.Currency == "SGD" {
+		balanceColumn = "balance_sgd"
+	} else if req.Currency == "THB" {
+		balanceColumn = "balance_thb"
+	}
 
-		// Update balance
-		_, err = tx.Exec(ctx, "UPDATE users SET "+balanceColumn+" = $1 WHERE id = $2", newBalance, userID)
-		if err != nil {
-			utils.WriteInternalServerError(w)
-			return
+	var currentBalance int64
+	err := tx.QueryRow(ctx, "SELECT "+balanceColumn+" FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&currentBalance)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, &actionError{Status: http.StatusNotFound, Code: "USER_NOT_FOUND", Message: "User not found"}
 		}
+		return nil, err
+	}
 
-		// Create balance mutation record
-		_, err = tx.Exec(ctx, `
-			INSERT INTO balance_mutations (
-				user_id, type, amount, balance_before, balance_after,
-				description, reference_type, reference_id, currency, created_at
-			) VALUES ($1, $2, $3, $4, $5, $6, 'ADMIN_ADJUSTMENT', $7, $8, NOW())
-		`, userID, req.Type, req.Amount, currentBalance, newBalance, req.Reason, adminID, req.Currency)
-
-		if err != nil {
-			utils.WriteInternalServerError(w)
-			return
+	// Calculate new balance
+	var newBalance int64
+	if req.Type == "CREDIT" {
+		newBalance = currentBalance + req.Amount
+	} else {
+		newBalance = currentBalance - req.Amount
+		if newBalance < 0 {
+			return nil, &actionError{Status: http.StatusBadRequest, Code: "INSUFFICIENT_BALANCE", Message: "Insufficient balance"}
 		}
+	}
 
-		// Create audit log
-		_, err = tx.Exec(ctx, `
-			INSERT INTO audit_logs (admin_id, action, resource, resource_id, description, created_at)
-			VALUES ($1, 'ADJUST_BALANCE', 'USER', $2, $3, NOW())
-		`, adminID, userID, req.Type+" "+strconv.FormatInt(req.Amount, 10)+" "+req.Currency+": "+req.Reason)
+	// Update balance
+	if _, err := tx.Exec(ctx, "UPDATE users SET "+balanceColumn+" = $1 WHERE id = $2", newBalance, userID); err != nil {
+		return nil, err
+	}
 
-		if err := tx.Commit(ctx); err != nil {
-			utils.WriteInternalServerError(w)
-			return
-		}
+	// Create balance mutation record, referencing the requester and naming the approver
+	description := req.Reason
+	if note := by.note(); note != "" {
+		description += " (" + note + ")"
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO balance_mutations (
+			user_id, type, amount, balance_before, balance_after,
+			description, reference_type, reference_id, currency, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, 'ADMIN_ADJUSTMENT', $7, $8, NOW())
+	`, userID, req.Type, req.Amount, currentBalance, newBalance, description, by.RequestedBy, req.Currency)
+	if err != nil {
+		return nil, err
+	}
 
-		// Get admin name for response
-		var adminName string
-		deps.DB.Pool.QueryRow(context.Background(), "SELECT first_name || ' ' || last_name FROM admins WHERE id = $1", adminID).Scan(&adminName)
+	// Create audit log
+	if err := recordAudit(ctx, deps, tx, r, services.AuditEntry{
+		Action:      "ADJUST_BALANCE",
+		Resource:    "USER",
+		ResourceID:  userID,
+		Description: req.Type + " " + strconv.FormatInt(req.Amount, 10) + " " + req.Currency + ": " + description,
+	}); err != nil {
+		return nil, err
+	}
 
-		utils.WriteSuccessJSON(w, map[string]interface{}{
-			"userId":        userID,
-			"type":          req.Type,
-			"amount":        req.Amount,
-			"currency":      req.Currency,
-			"balanceBefore": currentBalance,
-			"balanceAfter":  newBalance,
-			"reason":        req.Reason,
-			"processedBy": map[string]interface{}{
-				"id":   adminID,
-				"name": adminName,
-			},
-			"createdAt": time.Now().Format(time.RFC3339),
-		})
+	data := map[string]interface{}{
+		"userId":        userID,
+		"type":          req.Type,
+		"amount":        req.Amount,
+		"currency":      req.Currency,
+		"balanceBefore": currentBalance,
+		"balanceAfter":  newBalance,
+		"reason":        req.Reason,
+		"processedBy":   map[string]interface{}{"id": by.RequestedBy},
+		"createdAt":     time.Now().Format(time.RFC3339),
+	}
+	if by.ApprovalID != "" {
+		data["approvedBy"] = map[string]interface{}{"id": by.ApprovedBy}
 	}
+	return &actionResult{Data: data}, nil
 }
 
 // handleUserTransactionsImpl returns user's transaction history
@@ -687,3 +710,77 @@ func HandleUserMutationsImpl(deps *Dependencies) http.HandlerFunc {
 		})
 	}
 }
+
+// ImpersonateUserRequest represents the request to view as a user
+type ImpersonateUserRequest struct {
+	Reason string `json:"reason"` // e.g. the support ticket being investigated
+}
+
+// HandleImpersonateUserImpl issues a short-lived, read-only token to view the
+// /v2/user endpoints as the user. Every request made with it is audit-logged.
+func HandleImpersonateUserImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := chi.URLParam(r, "userId")
+		adminID := middleware.GetAdminIDFromContext(r.Context())
+
+		var req ImpersonateUserRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.WriteBadRequestError(w, "Invalid request body")
+			return
+		}
+		req.Reason = strings.TrimSpace(req.Reason)
+		if req.Reason == "" {
+			utils.WriteValidationErrorJSON(w, "Validation failed", map[string]string{
+				"reason": "Reason is required",
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		var email, firstName, lastName, status string
+		err := deps.DB.Pool.QueryRow(ctx, `
+			SELECT email, first_name, COALESCE(last_name, ''), status
+			FROM users
+			WHERE id = $1
+		`, userID).Scan(&email, &firstName, &lastName, &status)
+		if err == pgx.ErrNoRows {
+			utils.WriteNotFoundError(w, "User")
+			return
+		}
+		if err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		token, expiresAt, err := deps.JWTService.GenerateImpersonationToken(userID, email, adminID)
+		if err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		if err := recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      services.AuditActionImpersonate,
+			Resource:    "USER",
+			ResourceID:  userID,
+			Description: "Started viewing as " + email + ": " + req.Reason,
+		}); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"accessToken": token,
+			"tokenType":   "Bearer",
+			"expiresAt":   expiresAt.Format(time.RFC3339),
+			"readOnly":    true,
+			"user": map[string]interface{}{
+				"id":     userID,
+				"name":   strings.TrimSpace(firstName + " " + lastName),
+				"email":  email,
+				"status": status,
+			},
+		})
+	}
+}
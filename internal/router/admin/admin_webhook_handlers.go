@@ -0,0 +1,830 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"seaply/internal/middleware"
+	"seaply/internal/services"
+	"seaply/internal/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// ============================================
+// ADMIN WEBHOOKS
+// ============================================
+
+const webhookSubscriberColumns = `
+	s.id, s.name, s.url, s.secret_hint, s.events, s.user_id, s.is_active, s.created_at, s.updated_at,
+	(SELECT COUNT(*) FROM webhook_deliveries d WHERE d.subscriber_id = s.id AND d.status = 'DEAD')
+`
+
+// scanWebhookSubscriber scans a row selected with webhookSubscriberColumns. The secret itself is never returned.
+func scanWebhookSubscriber(row pgx.Row) (map[string]interface{}, error) {
+	var id, name, url, secretHint string
+	var events []string
+	var userID *string
+	var isActive bool
+	var createdAt, updatedAt time.Time
+	var deadCount int
+
+	err := row.Scan(&id, &name, &url, &secretHint, &events, &userID, &isActive, &createdAt, &updatedAt, &deadCount)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"id":         id,
+		"name":       name,
+		"url":        url,
+		"secretHint": "••••" + secretHint,
+		"events":     events,
+		"userId":     userID,
+		"isActive":   isActive,
+		"dead":       deadCount,
+		"createdAt":  createdAt.Format(time.RFC3339),
+		"updatedAt":  updatedAt.Format(time.RFC3339),
+	}, nil
+}
+
+func loadWebhookSubscriber(ctx context.Context, deps *Dependencies, id string) (map[string]interface{}, error) {
+	return scanWebhookSubscriber(deps.DB.Pool.QueryRow(ctx, `
+		SELECT `+webhookSubscriberColumns+`
+		FROM webhook_subscribers s
+		WHERE s.id::text = $1
+	`, id))
+}
+
+// validateWebhookSubscriber checks the URL and event types of a subscriber
+func validateWebhookSubscriber(ctx context.Context, url string, events []string, errs map[string]string) {
+	if url != "" {
+		if err := utils.ValidateOutboundURL(ctx, url); err != nil {
+			errs["url"] = "URL must be a public https URL"
+		}
+	}
+	for _, event := range events {
+		known := false
+		for _, eventType := range services.WebhookEventTypes {
+			if event == eventType {
+				known = true
+				break
+			}
+		}
+		if !known {
+			errs["events"] = "Unknown event: " + event
+			return
+		}
+	}
+}
+
+// HandleGetWebhookSubscribersImpl lists the webhook subscribers
+func HandleGetWebhookSubscribersImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		rows, err := deps.DB.Pool.Query(ctx, `
+			SELECT `+webhookSubscriberColumns+`
+			FROM webhook_subscribers s
+			ORDER BY s.created_at DESC
+		`)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to query webhook subscribers")
+			utils.WriteInternalServerError(w)
+			return
+		}
+		defer rows.Close()
+
+		subscribers := []map[string]interface{}{}
+		for rows.Next() {
+			subscriber, err := scanWebhookSubscriber(rows)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to scan webhook subscriber")
+				continue
+			}
+			subscribers = append(subscribers, subscriber)
+		}
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"subscribers": subscribers,
+			"eventTypes":  services.WebhookEventTypes,
+		})
+	}
+}
+
+// CreateWebhookSubscriberRequest represents the request to create a webhook subscriber
+type CreateWebhookSubscriberRequest struct {
+	Name   string   `json:"name"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	UserID string   `json:"userId"`
+}
+
+// HandleCreateWebhookSubscriberImpl registers a webhook subscriber. The signing
+// secret is only returned in this response.
+func HandleCreateWebhookSubscriberImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		adminID := middleware.GetAdminIDFromContext(r.Context())
+
+		if deps.Webhooks == nil {
+			utils.WriteErrorJSON(w, http.StatusServiceUnavailable, "WEBHOOKS_UNAVAILABLE",
+				"Webhooks are not available", "")
+			return
+		}
+
+		var req CreateWebhookSubscriberRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.WriteBadRequestError(w, "Invalid request body")
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Events == nil {
+			req.Events = []string{}
+		}
+
+		errs := map[string]string{}
+		if req.Name == "" {
+			errs["name"] = "Name is required"
+		}
+		if req.URL == "" {
+			errs["url"] = "URL is required"
+		}
+		validateWebhookSubscriber(r.Context(), req.URL, req.Events, errs)
+		if len(errs) > 0 {
+			utils.WriteValidationErrorJSON(w, "Validation failed", errs)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		if req.UserID != "" {
+			var exists bool
+			deps.DB.Pool.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM users WHERE id::text = $1)`, req.UserID).Scan(&exists)
+			if !exists {
+				utils.WriteNotFoundError(w, "User")
+				return
+			}
+		}
+
+		secret, encryptedSecret, hint, err := deps.Webhooks.GenerateSecret()
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to generate webhook secret")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		var id string
+		err = deps.DB.Pool.QueryRow(ctx, `
+			INSERT INTO webhook_subscribers (name, url, secret, secret_hint, events, user_id, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING id
+		`, req.Name, req.URL, encryptedSecret, hint, req.Events, nullString(req.UserID), adminID).Scan(&id)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to create webhook subscriber")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      "CREATE",
+			Resource:    "WEBHOOK",
+			ResourceID:  id,
+			Description: "Created webhook subscriber " + req.Name + " (" + req.URL + ")",
+		})
+
+		subscriber, err := loadWebhookSubscriber(ctx, deps, id)
+		if err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
+		subscriber["secret"] = secret
+
+		utils.WriteCreatedJSON(w, subscriber)
+	}
+}
+
+// UpdateWebhookSubscriberRequest represents the request to update a webhook subscriber
+type UpdateWebhookSubscriberRequest struct {
+	Name     *string   `json:"name"`
+	URL      *string   `json:"url"`
+	Events   *[]string `json:"events"`
+	IsActive *bool     `json:"isActive"`
+}
+
+// HandleUpdateWebhookSubscriberImpl updates a webhook subscriber. Deliveries already
+// queued keep the URL they were queued with.
+func HandleUpdateWebhookSubscriberImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "subscriberId")
+
+		var req UpdateWebhookSubscriberRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.WriteBadRequestError(w, "Invalid request body")
+			return
+		}
+
+		errs := map[string]string{}
+		if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+			errs["name"] = "Name cannot be empty"
+		}
+		var url string
+		if req.URL != nil {
+			url = *req.URL
+			if url == "" {
+				errs["url"] = "URL cannot be empty"
+			}
+		}
+		var events []string
+		if req.Events != nil {
+			events = *req.Events
+		}
+		validateWebhookSubscriber(r.Context(), url, events, errs)
+		if len(errs) > 0 {
+			utils.WriteValidationErrorJSON(w, "Validation failed", errs)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		set := []string{}
+		args := []interface{}{}
+		if req.Name != nil {
+			args = append(args, strings.TrimSpace(*req.Name))
+			set = append(set, "name = $"+strconv.Itoa(len(args)))
+		}
+		if req.URL != nil {
+			args = append(args, url)
+			set = append(set, "url = $"+strconv.Itoa(len(args)))
+		}
+		if req.Events != nil {
+			if events == nil {
+				events = []string{}
+			}
+			args = append(args, events)
+			set = append(set, "events = $"+strconv.Itoa(len(args)))
+		}
+		if req.IsActive != nil {
+			args = append(args, *req.IsActive)
+			set = append(set, "is_active = $"+strconv.Itoa(len(args)))
+		}
+		if len(set) == 0 {
+			utils.WriteBadRequestError(w, "No fields to update")
+			return
+		}
+
+		args = append(args, id)
+		tag, err := deps.DB.Pool.Exec(ctx, `
+			UPDATE webhook_subscribers SET `+strings.Join(set, ", ")+`, updated_at = NOW()
+			WHERE id::text = $`+strconv.Itoa(len(args)), args...)
+		if err != nil {
+			log.Error().Err(err).Str("subscriber_id", id).Msg("Failed to update webhook subscriber")
+			utils.WriteInternalServerError(w)
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			utils.WriteNotFoundError(w, "Webhook subscriber")
+			return
+		}
+
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      "UPDATE",
+			Resource:    "WEBHOOK",
+			ResourceID:  id,
+			Description: "Updated webhook subscriber",
+		})
+
+		subscriber, err := loadWebhookSubscriber(ctx, deps, id)
+		if err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
+		utils.WriteSuccessJSON(w, subscriber)
+	}
+}
+
+// HandleRotateWebhookSecretImpl replaces the signing secret of a webhook subscriber.
+// Pending deliveries are signed with the new secret.
+func HandleRotateWebhookSecretImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "subscriberId")
+
+		if deps.Webhooks == nil {
+			utils.WriteErrorJSON(w, http.StatusServiceUnavailable, "WEBHOOKS_UNAVAILABLE",
+				"Webhooks are not available", "")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		secret, encryptedSecret, hint, err := deps.Webhooks.GenerateSecret()
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to generate webhook secret")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		tag, err := deps.DB.Pool.Exec(ctx, `
+			UPDATE webhook_subscribers SET secret = $1, secret_hint = $2, updated_at = NOW()
+			WHERE id::text = $3
+		`, encryptedSecret, hint, id)
+		if err != nil {
+			log.Error().Err(err).Str("subscriber_id", id).Msg("Failed to rotate webhook secret")
+			utils.WriteInternalServerError(w)
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			utils.WriteNotFoundError(w, "Webhook subscriber")
+			return
+		}
+
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      "ROTATE_SECRET",
+			Resource:    "WEBHOOK",
+			ResourceID:  id,
+			Description: "Rotated webhook subscriber secret",
+		})
+
+		subscriber, err := loadWebhookSubscriber(ctx, deps, id)
+		if err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
+		subscriber["secret"] = secret
+
+		utils.WriteSuccessJSON(w, subscriber)
+	}
+}
+
+// HandleDeleteWebhookSubscriberImpl deletes a webhook subscriber with its deliveries
+func HandleDeleteWebhookSubscriberImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "subscriberId")
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		var name string
+		err := deps.DB.Pool.QueryRow(ctx, `
+			DELETE FROM webhook_subscribers WHERE id::text = $1 RETURNING name
+		`, id).Scan(&name)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				utils.WriteNotFoundError(w, "Webhook subscriber")
+				return
+			}
+			log.Error().Err(err).Str("subscriber_id", id).Msg("Failed to delete webhook subscriber")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      "DELETE",
+			Resource:    "WEBHOOK",
+			ResourceID:  id,
+			Description: "Deleted webhook subscriber " + name,
+		})
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"message": "Webhook subscriber deleted",
+		})
+	}
+}
+
+const webhookDeliveryColumns = `
+	d.id, d.event_id, e.event_type, e.reference_type, e.reference_id,
+	d.subscriber_id, s.name, d.partner_key_id, k.name, d.url,
+	d.status, d.attempts, d.max_attempts, d.last_error, d.last_status_code,
+	d.next_attempt_at, d.delivered_at, d.created_at, d.updated_at,
+	a.id, a.name
+`
+
+const webhookDeliveryFrom = `
+	FROM webhook_deliveries d
+	JOIN webhook_events e ON e.id = d.event_id
+	LEFT JOIN webhook_subscribers s ON s.id = d.subscriber_id
+	LEFT JOIN partner_api_keys k ON k.id = d.partner_key_id
+	LEFT JOIN admins a ON a.id = d.resent_by
+`
+
+// scanWebhookDelivery scans a row selected with webhookDeliveryColumns
+func scanWebhookDelivery(row pgx.Row) (map[string]interface{}, error) {
+	var id, eventID, eventType, referenceType, referenceID, url, status string
+	var subscriberID, subscriberName, partnerKeyID, partnerKeyName, lastError *string
+	var adminID, adminName *string
+	var attempts, maxAttempts int
+	var lastStatusCode *int
+	var nextAttemptAt, createdAt, updatedAt time.Time
+	var deliveredAt *time.Time
+
+	err := row.Scan(
+		&id, &eventID, &eventType, &referenceType, &referenceID,
+		&subscriberID, &subscriberName, &partnerKeyID, &partnerKeyName, &url,
+		&status, &attempts, &maxAttempts, &lastError, &lastStatusCode,
+		&nextAttemptAt, &deliveredAt, &createdAt, &updatedAt,
+		&adminID, &adminName,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery := map[string]interface{}{
+		"id":             id,
+		"eventId":        eventID,
+		"eventType":      eventType,
+		"referenceType":  referenceType,
+		"referenceId":    referenceID,
+		"recipient":      nil,
+		"url":            url,
+		"status":         status,
+		"attempts":       attempts,
+		"maxAttempts":    maxAttempts,
+		"lastError":      lastError,
+		"lastStatusCode": lastStatusCode,
+		"nextAttemptAt":  nil,
+		"deliveredAt":    nil,
+		"resentBy":       nil,
+		"createdAt":      createdAt.Format(time.RFC3339),
+		"updatedAt":      updatedAt.Format(time.RFC3339),
+	}
+	if subscriberID != nil {
+		delivery["recipient"] = map[string]interface{}{
+			"type": "SUBSCRIBER",
+			"id":   *subscriberID,
+			"name": subscriberName,
+		}
+	} else if partnerKeyID != nil {
+		delivery["recipient"] = map[string]interface{}{
+			"type": "PARTNER_KEY",
+			"id":   *partnerKeyID,
+			"name": partnerKeyName,
+		}
+	}
+	if status == services.WebhookStatusPending {
+		delivery["nextAttemptAt"] = nextAttemptAt.Format(time.RFC3339)
+	}
+	if deliveredAt != nil {
+		delivery["deliveredAt"] = deliveredAt.Format(time.RFC3339)
+	}
+	if adminID != nil {
+		delivery["resentBy"] = map[string]interface{}{
+			"id":   *adminID,
+			"name": adminName,
+		}
+	}
+	return delivery, nil
+}
+
+// HandleGetWebhookDeliveriesImpl returns the webhook deliveries with filters
+func HandleGetWebhookDeliveriesImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit <= 0 || limit > 100 {
+			limit = 10
+		}
+
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page <= 0 {
+			page = 1
+		}
+
+		status := r.URL.Query().Get("status")
+		eventType := r.URL.Query().Get("eventType")
+		eventID := r.URL.Query().Get("eventId")
+		subscriberID := r.URL.Query().Get("subscriberId")
+		partnerKeyID := r.URL.Query().Get("partnerKeyId")
+		referenceID := r.URL.Query().Get("referenceId")
+
+		offset := (page - 1) * limit
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		where := " WHERE 1=1"
+		args := []interface{}{}
+		argCount := 0
+
+		if status != "" {
+			argCount++
+			where += " AND d.status = $" + strconv.Itoa(argCount)
+			args = append(args, status)
+		}
+		if eventType != "" {
+			argCount++
+			where += " AND e.event_type = $" + strconv.Itoa(argCount)
+			args = append(args, eventType)
+		}
+		if eventID != "" {
+			argCount++
+			where += " AND d.event_id::text = $" + strconv.Itoa(argCount)
+			args = append(args, eventID)
+		}
+		if subscriberID != "" {
+			argCount++
+			where += " AND d.subscriber_id::text = $" + strconv.Itoa(argCount)
+			args = append(args, subscriberID)
+		}
+		if partnerKeyID != "" {
+			argCount++
+			where += " AND d.partner_key_id::text = $" + strconv.Itoa(argCount)
+			args = append(args, partnerKeyID)
+		}
+		if referenceID != "" {
+			argCount++
+			where += " AND e.reference_id = $" + strconv.Itoa(argCount)
+			args = append(args, referenceID)
+		}
+
+		var totalRows int
+		err := deps.DB.Pool.QueryRow(ctx, "SELECT COUNT(*)"+webhookDeliveryFrom+where, args...).Scan(&totalRows)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to count webhook deliveries")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		query := "SELECT " + webhookDeliveryColumns + webhookDeliveryFrom + where
+		query += " ORDER BY d.created_at DESC"
+		argCount++
+		query += " LIMIT $" + strconv.Itoa(argCount)
+		args = append(args, limit)
+		argCount++
+		query += " OFFSET $" + strconv.Itoa(argCount)
+		args = append(args, offset)
+
+		rows, err := deps.DB.Pool.Query(ctx, query, args...)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to query webhook deliveries")
+			utils.WriteInternalServerError(w)
+			return
+		}
+		defer rows.Close()
+
+		deliveries := []map[string]interface{}{}
+		for rows.Next() {
+			delivery, err := scanWebhookDelivery(rows)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to scan webhook delivery")
+				continue
+			}
+			deliveries = append(deliveries, delivery)
+		}
+
+		var pendingCount, deadCount int
+		deps.DB.Pool.QueryRow(ctx, `
+			SELECT
+				COALESCE(SUM(CASE WHEN status IN ('PENDING', 'SENDING') THEN 1 ELSE 0 END), 0),
+				COALESCE(SUM(CASE WHEN status = 'DEAD' THEN 1 ELSE 0 END), 0)
+			FROM webhook_deliveries
+		`).Scan(&pendingCount, &deadCount)
+
+		totalPages := (totalRows + limit - 1) / limit
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"overview": map[string]interface{}{
+				"pending": pendingCount,
+				"dead":    deadCount,
+			},
+			"deliveries": deliveries,
+			"pagination": map[string]interface{}{
+				"limit":      limit,
+				"page":       page,
+				"totalRows":  totalRows,
+				"totalPages": totalPages,
+			},
+		})
+	}
+}
+
+// HandleGetWebhookDeliveryImpl returns a delivery with the event payload and the log of its attempts
+func HandleGetWebhookDeliveryImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "deliveryId")
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		delivery, err := scanWebhookDelivery(deps.DB.Pool.QueryRow(ctx, "SELECT "+webhookDeliveryColumns+webhookDeliveryFrom+
+			" WHERE d.id::text = $1", id))
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				utils.WriteNotFoundError(w, "Webhook delivery")
+				return
+			}
+			log.Error().Err(err).Str("delivery_id", id).Msg("Failed to get webhook delivery")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		var payload json.RawMessage
+		deps.DB.Pool.QueryRow(ctx, `
+			SELECT e.payload FROM webhook_events e
+			JOIN webhook_deliveries d ON d.event_id = e.id
+			WHERE d.id::text = $1
+		`, id).Scan(&payload)
+		delivery["payload"] = payload
+
+		rows, err := deps.DB.Pool.Query(ctx, `
+			SELECT attempt, url, status_code, response_body, error, duration_ms, created_at
+			FROM webhook_delivery_attempts
+			WHERE delivery_id::text = $1
+			ORDER BY created_at
+		`, id)
+		if err != nil {
+			log.Error().Err(err).Str("delivery_id", id).Msg("Failed to query webhook attempts")
+			utils.WriteInternalServerError(w)
+			return
+		}
+		defer rows.Close()
+
+		attempts := []map[string]interface{}{}
+		for rows.Next() {
+			var attempt, durationMs int
+			var url string
+			var statusCode *int
+			var responseBody, attemptError *string
+			var createdAt time.Time
+			if err := rows.Scan(&attempt, &url, &statusCode, &responseBody, &attemptError, &durationMs, &createdAt); err != nil {
+				continue
+			}
+			attempts = append(attempts, map[string]interface{}{
+				"attempt":      attempt,
+				"url":          url,
+				"statusCode":   statusCode,
+				"responseBody": responseBody,
+				"error":        attemptError,
+				"durationMs":   durationMs,
+				"createdAt":    createdAt.Format(time.RFC3339),
+			})
+		}
+		delivery["attemptLog"] = attempts
+
+		utils.WriteSuccessJSON(w, delivery)
+	}
+}
+
+// HandleResendWebhookDeliveryImpl queues a delivery again, including delivered and dead ones
+func HandleResendWebhookDeliveryImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "deliveryId")
+		adminID := middleware.GetAdminIDFromContext(r.Context())
+
+		if deps.Webhooks == nil {
+			utils.WriteErrorJSON(w, http.StatusServiceUnavailable, "WEBHOOKS_UNAVAILABLE",
+				"Webhooks are not available", "")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		if err := deps.Webhooks.Resend(ctx, id, adminID); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				utils.WriteErrorJSON(w, http.StatusBadRequest, "WEBHOOK_NOT_RESENDABLE",
+					"Delivery not found or being sent", "")
+				return
+			}
+			log.Error().Err(err).Str("delivery_id", id).Msg("Failed to resend webhook")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		recordAudit(ctx, deps, deps.DB.Pool, r, services.AuditEntry{
+			Action:      "RESEND",
+			Resource:    "WEBHOOK_DELIVERY",
+			ResourceID:  id,
+			Description: "Resent webhook delivery",
+		})
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"message": "Webhook queued for delivery",
+			"id":      id,
+			"status":  services.WebhookStatusPending,
+		})
+	}
+}
+
+// HandleGetWebhookEventsImpl returns the emitted webhook events with a summary of
+// their deliveries; the deliveries of one event are listed with ?eventId=
+func HandleGetWebhookEventsImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit <= 0 || limit > 100 {
+			limit = 10
+		}
+
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page <= 0 {
+			page = 1
+		}
+
+		eventType := r.URL.Query().Get("eventType")
+		referenceType := r.URL.Query().Get("referenceType")
+		referenceID := r.URL.Query().Get("referenceId")
+
+		offset := (page - 1) * limit
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		where := " WHERE 1=1"
+		args := []interface{}{}
+		argCount := 0
+
+		if eventType != "" {
+			argCount++
+			where += " AND e.event_type = $" + strconv.Itoa(argCount)
+			args = append(args, eventType)
+		}
+		if referenceType != "" {
+			argCount++
+			where += " AND e.reference_type = $" + strconv.Itoa(argCount)
+			args = append(args, referenceType)
+		}
+		if referenceID != "" {
+			argCount++
+			where += " AND e.reference_id = $" + strconv.Itoa(argCount)
+			args = append(args, referenceID)
+		}
+
+		var totalRows int
+		err := deps.DB.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM webhook_events e"+where, args...).Scan(&totalRows)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to count webhook events")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		query := `
+			SELECT e.id, e.event_type, e.reference_type, e.reference_id, e.created_at,
+			       COUNT(d.id),
+			       COUNT(d.id) FILTER (WHERE d.status = 'DELIVERED'),
+			       COUNT(d.id) FILTER (WHERE d.status IN ('PENDING', 'SENDING')),
+			       COUNT(d.id) FILTER (WHERE d.status = 'DEAD')
+			FROM webhook_events e
+			LEFT JOIN webhook_deliveries d ON d.event_id = e.id` + where + `
+			GROUP BY e.id
+			ORDER BY e.created_at DESC`
+		argCount++
+		query += " LIMIT $" + strconv.Itoa(argCount)
+		args = append(args, limit)
+		argCount++
+		query += " OFFSET $" + strconv.Itoa(argCount)
+		args = append(args, offset)
+
+		rows, err := deps.DB.Pool.Query(ctx, query, args...)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to query webhook events")
+			utils.WriteInternalServerError(w)
+			return
+		}
+		defer rows.Close()
+
+		events := []map[string]interface{}{}
+		for rows.Next() {
+			var id, eventType, referenceType, referenceID string
+			var createdAt time.Time
+			var total, delivered, pending, dead int
+			if err := rows.Scan(&id, &eventType, &referenceType, &referenceID, &createdAt,
+				&total, &delivered, &pending, &dead); err != nil {
+				log.Error().Err(err).Msg("Failed to scan webhook event")
+				continue
+			}
+			events = append(events, map[string]interface{}{
+				"id":            id,
+				"eventType":     eventType,
+				"referenceType": referenceType,
+				"referenceId":   referenceID,
+				"deliveries": map[string]interface{}{
+					"total":     total,
+					"delivered": delivered,
+					"pending":   pending,
+					"dead":      dead,
+				},
+				"createdAt": createdAt.Format(time.RFC3339),
+			})
+		}
+
+		totalPages := (totalRows + limit - 1) / limit
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"events": events,
+			"pagination": map[string]interface{}{
+				"limit":      limit,
+				"page":       page,
+				"totalRows":  totalRows,
+				"totalPages": totalPages,
+			},
+		})
+	}
+}
@@ -60,6 +60,59 @@ func HandleSyncProvider(deps *Dependencies) http.HandlerFunc {
 	return HandleSyncProviderImpl(deps)
 }
 
+func HandleGetProviderTypes(deps *Dependencies) http.HandlerFunc {
+	return HandleGetProviderTypesImpl(deps)
+}
+
+func HandleReloadProviders(deps *Dependencies) http.HandlerFunc {
+	return HandleReloadProvidersImpl(deps)
+}
+
+func HandleGetProviderBalances(deps *Dependencies) http.HandlerFunc {
+	return HandleGetProviderBalancesImpl(deps)
+}
+
+func HandleRefreshProviderBalances(deps *Dependencies) http.HandlerFunc {
+	return HandleRefreshProviderBalancesImpl(deps)
+}
+
+func HandleGetProviderBalanceHistory(deps *Dependencies) http.HandlerFunc {
+	return HandleGetProviderBalanceHistoryImpl(deps)
+}
+
+// Payment Gateway Handlers
+func HandleGetPaymentGateways(deps *Dependencies) http.HandlerFunc {
+	return HandleGetPaymentGatewaysImpl(deps)
+}
+
+func HandleGetPaymentGateway(deps *Dependencies) http.HandlerFunc {
+	return HandleGetPaymentGatewayImpl(deps)
+}
+
+func HandleCreatePaymentGateway(deps *Dependencies) http.HandlerFunc {
+	return HandleCreatePaymentGatewayImpl(deps)
+}
+
+func HandleUpdatePaymentGateway(deps *Dependencies) http.HandlerFunc {
+	return HandleUpdatePaymentGatewayImpl(deps)
+}
+
+func HandleDeletePaymentGateway(deps *Dependencies) http.HandlerFunc {
+	return HandleDeletePaymentGatewayImpl(deps)
+}
+
+func HandleTestPaymentGateway(deps *Dependencies) http.HandlerFunc {
+	return HandleTestPaymentGatewayImpl(deps)
+}
+
+func HandleGetPaymentGatewayTypes(deps *Dependencies) http.HandlerFunc {
+	return HandleGetPaymentGatewayTypesImpl(deps)
+}
+
+func HandleReloadPaymentGateways(deps *Dependencies) http.HandlerFunc {
+	return HandleReloadPaymentGatewaysImpl(deps)
+}
+
 // Payment Channel Handlers
 func HandleAdminGetPaymentChannels(deps *Dependencies) http.HandlerFunc {
 	return HandleAdminGetPaymentChannelsImplAdmin(deps)
@@ -201,6 +254,38 @@ func HandleSyncSKUs(deps *Dependencies) http.HandlerFunc {
 	return HandleSyncSKUsImpl(deps)
 }
 
+func HandleGetSKUProviders(deps *Dependencies) http.HandlerFunc {
+	return HandleGetSKUProvidersImpl(deps)
+}
+
+func HandleCreateSKUProvider(deps *Dependencies) http.HandlerFunc {
+	return HandleCreateSKUProviderImpl(deps)
+}
+
+func HandleUpdateSKUProvider(deps *Dependencies) http.HandlerFunc {
+	return HandleUpdateSKUProviderImpl(deps)
+}
+
+func HandleDeleteSKUProvider(deps *Dependencies) http.HandlerFunc {
+	return HandleDeleteSKUProviderImpl(deps)
+}
+
+func HandleUpdateSKURouting(deps *Dependencies) http.HandlerFunc {
+	return HandleUpdateSKURoutingImpl(deps)
+}
+
+func HandleGetPriceHistory(deps *Dependencies) http.HandlerFunc {
+	return HandleGetPriceHistoryImpl(deps)
+}
+
+func HandleGetPriceWatcher(deps *Dependencies) http.HandlerFunc {
+	return HandleGetPriceWatcherImpl(deps)
+}
+
+func HandleRunPriceWatcher(deps *Dependencies) http.HandlerFunc {
+	return HandleRunPriceWatcherImpl(deps)
+}
+
 func HandleAdminGetSKUImages(deps *Dependencies) http.HandlerFunc {
 	return HandleAdminGetSKUImagesImpl(deps)
 }
@@ -255,6 +340,10 @@ func HandleUserMutations(deps *Dependencies) http.HandlerFunc {
 	return HandleUserMutationsImpl(deps)
 }
 
+func HandleImpersonateUser(deps *Dependencies) http.HandlerFunc {
+	return HandleImpersonateUserImpl(deps)
+}
+
 // Promo Handlers
 func HandleAdminGetPromos(deps *Dependencies) http.HandlerFunc {
 	return HandleAdminGetPromosImpl(deps)
@@ -343,6 +432,81 @@ func HandleSendInvoiceEmail(deps *Dependencies) http.HandlerFunc {
 	return HandleSendInvoiceEmailImpl(deps)
 }
 
+func HandleDownloadInvoicePDF(deps *Dependencies) http.HandlerFunc {
+	return HandleDownloadInvoicePDFImpl(deps)
+}
+
+// Email Outbox Handlers
+func HandleGetEmailOutbox(deps *Dependencies) http.HandlerFunc {
+	return HandleGetEmailOutboxImpl(deps)
+}
+
+func HandleGetEmailOutboxMessage(deps *Dependencies) http.HandlerFunc {
+	return HandleGetEmailOutboxMessageImpl(deps)
+}
+
+func HandleRetryEmailOutbox(deps *Dependencies) http.HandlerFunc {
+	return HandleRetryEmailOutboxImpl(deps)
+}
+
+// Partner API Key Handlers
+func HandleGetPartnerKeys(deps *Dependencies) http.HandlerFunc {
+	return HandleGetPartnerKeysImpl(deps)
+}
+
+func HandleCreatePartnerKey(deps *Dependencies) http.HandlerFunc {
+	return HandleCreatePartnerKeyImpl(deps)
+}
+
+func HandleUpdatePartnerKey(deps *Dependencies) http.HandlerFunc {
+	return HandleUpdatePartnerKeyImpl(deps)
+}
+
+func HandleRotatePartnerSecret(deps *Dependencies) http.HandlerFunc {
+	return HandleRotatePartnerSecretImpl(deps)
+}
+
+func HandleRevokePartnerKey(deps *Dependencies) http.HandlerFunc {
+	return HandleRevokePartnerKeyImpl(deps)
+}
+
+// Webhook Handlers
+func HandleGetWebhookSubscribers(deps *Dependencies) http.HandlerFunc {
+	return HandleGetWebhookSubscribersImpl(deps)
+}
+
+func HandleCreateWebhookSubscriber(deps *Dependencies) http.HandlerFunc {
+	return HandleCreateWebhookSubscriberImpl(deps)
+}
+
+func HandleUpdateWebhookSubscriber(deps *Dependencies) http.HandlerFunc {
+	return HandleUpdateWebhookSubscriberImpl(deps)
+}
+
+func HandleRotateWebhookSecret(deps *Dependencies) http.HandlerFunc {
+	return HandleRotateWebhookSecretImpl(deps)
+}
+
+func HandleDeleteWebhookSubscriber(deps *Dependencies) http.HandlerFunc {
+	return HandleDeleteWebhookSubscriberImpl(deps)
+}
+
+func HandleGetWebhookEvents(deps *Dependencies) http.HandlerFunc {
+	return HandleGetWebhookEventsImpl(deps)
+}
+
+func HandleGetWebhookDeliveries(deps *Dependencies) http.HandlerFunc {
+	return HandleGetWebhookDeliveriesImpl(deps)
+}
+
+func HandleGetWebhookDelivery(deps *Dependencies) http.HandlerFunc {
+	return HandleGetWebhookDeliveryImpl(deps)
+}
+
+func HandleResendWebhookDelivery(deps *Dependencies) http.HandlerFunc {
+	return HandleResendWebhookDeliveryImpl(deps)
+}
+
 // Report Handlers
 func HandleGetDashboard(deps *Dependencies) http.HandlerFunc {
 	return HandleGetDashboardImpl(deps)
@@ -377,6 +541,65 @@ func HandleGetAuditLogs(deps *Dependencies) http.HandlerFunc {
 	return HandleGetAuditLogsImpl(deps)
 }
 
+func HandleGetAuditLog(deps *Dependencies) http.HandlerFunc {
+	return HandleGetAuditLogImpl(deps)
+}
+
+func HandleExportAuditLogs(deps *Dependencies) http.HandlerFunc {
+	return HandleExportAuditLogsImpl(deps)
+}
+
+// Approval Handlers
+func HandleGetApprovals(deps *Dependencies) http.HandlerFunc {
+	return HandleGetApprovalsImpl(deps)
+}
+
+func HandleGetApproval(deps *Dependencies) http.HandlerFunc {
+	return HandleGetApprovalImpl(deps)
+}
+
+func HandleApproveApproval(deps *Dependencies) http.HandlerFunc {
+	return HandleApproveApprovalImpl(deps)
+}
+
+func HandleRejectApproval(deps *Dependencies) http.HandlerFunc {
+	return HandleRejectApprovalImpl(deps)
+}
+
+func HandleCancelApproval(deps *Dependencies) http.HandlerFunc {
+	return HandleCancelApprovalImpl(deps)
+}
+
+func HandleCommentApproval(deps *Dependencies) http.HandlerFunc {
+	return HandleCommentApprovalImpl(deps)
+}
+
+// Risk Review Handlers
+func HandleGetRiskEvaluations(deps *Dependencies) http.HandlerFunc {
+	return HandleGetRiskEvaluationsImpl(deps)
+}
+
+func HandleGetRiskEvaluation(deps *Dependencies) http.HandlerFunc {
+	return HandleGetRiskEvaluationImpl(deps)
+}
+
+func HandleReleaseRiskEvaluation(deps *Dependencies) http.HandlerFunc {
+	return HandleReleaseRiskEvaluationImpl(deps)
+}
+
+func HandleCancelRiskEvaluation(deps *Dependencies) http.HandlerFunc {
+	return HandleCancelRiskEvaluationImpl(deps)
+}
+
+// Cache Handlers
+func HandleGetCacheStats(deps *Dependencies) http.HandlerFunc {
+	return HandleGetCacheStatsImpl(deps)
+}
+
+func HandleInvalidateCache(deps *Dependencies) http.HandlerFunc {
+	return HandleInvalidateCacheImpl(deps)
+}
+
 // Settings Handlers
 func HandleGetSettings(deps *Dependencies) http.HandlerFunc {
 	return HandleGetSettingsImpl(deps)
@@ -1,8 +1,16 @@
 package admin
 
 import (
+	"context"
 	"database/sql"
+	"net/http"
 	"strings"
+	"time"
+
+	"seaply/internal/middleware"
+	"seaply/internal/services"
+
+	"github.com/rs/zerolog/log"
 )
 
 // nullString converts a string to sql.NullString
@@ -28,3 +36,100 @@ func getMembershipName(level string) string {
 	}
 }
 
+// recordAudit records an action of the signed-in admin through db, the transaction of
+// the change when there is one so the entry is committed or rolled back with it
+func recordAudit(ctx context.Context, deps *Dependencies, db services.AuditDB, r *http.Request, entry services.AuditEntry) error {
+	if deps.Audit == nil {
+		return nil
+	}
+
+	actor := services.AuditActor{
+		AdminID:   middleware.GetAdminIDFromContext(r.Context()),
+		IPAddress: middleware.ClientIP(r),
+		UserAgent: r.UserAgent(),
+	}
+	if err := deps.Audit.Record(ctx, db, actor, entry); err != nil {
+		log.Error().Err(err).Str("action", entry.Action).Str("resource", entry.Resource).Msg("Failed to record audit log")
+		return err
+	}
+	return nil
+}
+
+// auditSnapshot returns the row of table with the given ID for an audit entry, nil when
+// it can't be read so the change itself still goes through
+func auditSnapshot(ctx context.Context, deps *Dependencies, db services.AuditDB, table, id string) map[string]interface{} {
+	if deps.Audit == nil {
+		return nil
+	}
+	row, err := deps.Audit.Snapshot(ctx, db, table, id)
+	if err != nil {
+		log.Error().Err(err).Str("table", table).Str("id", id).Msg("Failed to snapshot audit resource")
+		return nil
+	}
+	return row
+}
+
+// publishTransactionStatus pushes the current status of a transaction to its invoice stream
+// and emits the matching webhook events
+func publishTransactionStatus(deps *Dependencies, transactionID string) {
+	if deps.InvoiceStream == nil && deps.Webhooks == nil {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if deps.InvoiceStream != nil {
+			if err := deps.InvoiceStream.PublishTransaction(ctx, transactionID); err != nil {
+				log.Warn().Err(err).Str("transaction_id", transactionID).Msg("Failed to publish invoice status")
+			}
+		}
+		if deps.Webhooks != nil {
+			if err := deps.Webhooks.EmitTransaction(ctx, transactionID); err != nil {
+				log.Warn().Err(err).Str("transaction_id", transactionID).Msg("Failed to emit transaction webhooks")
+			}
+		}
+	}()
+}
+
+// publishDepositStatus pushes the current status of a deposit to its invoice stream
+// and emits the matching webhook events
+func publishDepositStatus(deps *Dependencies, depositID string) {
+	if deps.InvoiceStream == nil && deps.Webhooks == nil {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if deps.InvoiceStream != nil {
+			if err := deps.InvoiceStream.PublishDeposit(ctx, depositID); err != nil {
+				log.Warn().Err(err).Str("deposit_id", depositID).Msg("Failed to publish invoice status")
+			}
+		}
+		if deps.Webhooks != nil {
+			if err := deps.Webhooks.EmitDeposit(ctx, depositID); err != nil {
+				log.Warn().Err(err).Str("deposit_id", depositID).Msg("Failed to emit deposit webhooks")
+			}
+		}
+	}()
+}
+
+// notifyPartnerOrder queues the callback with the status of an H2H order to the partner's callback URL
+func notifyPartnerOrder(deps *Dependencies, transactionID string) {
+	if deps.PartnerService == nil {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := deps.PartnerService.NotifyOrder(ctx, transactionID); err != nil {
+			log.Warn().Err(err).Str("transaction_id", transactionID).Msg("Failed to queue partner callback")
+		}
+	}()
+}
+
@@ -0,0 +1,267 @@
+package public
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"seaply/internal/utils"
+
+	"github.com/rs/zerolog/log"
+)
+
+// accountField is a product_fields row used to validate account inputs
+type accountField struct {
+	Key       string
+	Type      string
+	Label     string
+	Required  bool
+	MinLength *int
+	MaxLength *int
+	Pattern   string
+	Options   []string
+}
+
+var templatePlaceholder = regexp.MustCompile(`\{([A-Za-z0-9_]+)\}`)
+
+// loadAccountFields returns the input schema configured for a product
+func loadAccountFields(ctx context.Context, deps *Dependencies, productCode string) ([]accountField, error) {
+	rows, err := deps.DB.Pool.Query(ctx, `
+		SELECT pf.key, pf.field_type::text, pf.label, pf.is_required,
+		       pf.min_length, pf.max_length, COALESCE(pf.pattern, ''), pf.options
+		FROM product_fields pf
+		JOIN products p ON pf.product_id = p.id
+		WHERE p.code = $1
+		ORDER BY pf.sort_order ASC
+	`, productCode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fields []accountField
+	for rows.Next() {
+		var f accountField
+		var options []byte
+		if err := rows.Scan(&f.Key, &f.Type, &f.Label, &f.Required,
+			&f.MinLength, &f.MaxLength, &f.Pattern, &options); err != nil {
+			return nil, err
+		}
+		f.Options = parseFieldOptions(options)
+		fields = append(fields, f)
+	}
+	return fields, rows.Err()
+}
+
+// parseFieldOptions accepts options stored either as ["a","b"] or as [{"label":..,"value":..}]
+func parseFieldOptions(raw []byte) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil
+	}
+
+	options := make([]string, 0, len(items))
+	for _, item := range items {
+		var s string
+		if err := json.Unmarshal(item, &s); err == nil {
+			options = append(options, s)
+			continue
+		}
+		var obj struct {
+			Value interface{} `json:"value"`
+		}
+		if err := json.Unmarshal(item, &obj); err == nil && obj.Value != nil {
+			options = append(options, inputString(obj.Value))
+		}
+	}
+	return options
+}
+
+// inputString converts a decoded JSON input value into its string form
+func inputString(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(val)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	default:
+		return strings.TrimSpace(fmt.Sprint(val))
+	}
+}
+
+// normalizeAccountInputs flattens decoded account inputs into strings, dropping empty values
+func normalizeAccountInputs(raw map[string]interface{}) map[string]string {
+	inputs := make(map[string]string, len(raw))
+	for key, value := range raw {
+		if s := inputString(value); s != "" {
+			inputs[key] = s
+		}
+	}
+	return inputs
+}
+
+// validateAccountInputs checks inputs against the product schema. It returns the
+// accepted inputs (only keys defined in the schema) and per-field errors keyed by field key.
+func validateAccountInputs(fields []accountField, inputs map[string]string) (map[string]string, map[string]string) {
+	values := make(map[string]string, len(fields))
+	errs := make(map[string]string)
+
+	for _, f := range fields {
+		value := inputs[f.Key]
+		// zoneId and serverId are used interchangeably by clients
+		if value == "" && f.Key == "zoneId" {
+			value = inputs["serverId"]
+		} else if value == "" && f.Key == "serverId" {
+			value = inputs["zoneId"]
+		}
+
+		if value == "" {
+			if f.Required {
+				errs[f.Key] = fmt.Sprintf("%s is required", f.Label)
+			}
+			continue
+		}
+
+		length := utf8.RuneCountInString(value)
+		if f.MinLength != nil && length < *f.MinLength {
+			errs[f.Key] = fmt.Sprintf("%s must be at least %d characters", f.Label, *f.MinLength)
+			continue
+		}
+		if f.MaxLength != nil && *f.MaxLength > 0 && length > *f.MaxLength {
+			errs[f.Key] = fmt.Sprintf("%s must be at most %d characters", f.Label, *f.MaxLength)
+			continue
+		}
+
+		switch f.Type {
+		case "number":
+			// IDs can be longer than any integer type, length is checked above
+			if !isDigits(value) {
+				errs[f.Key] = fmt.Sprintf("%s must contain digits only", f.Label)
+				continue
+			}
+		case "email":
+			if !utils.ValidateEmail(value) {
+				errs[f.Key] = fmt.Sprintf("%s must be a valid email address", f.Label)
+				continue
+			}
+		case "phone":
+			if !utils.ValidatePhone(value) {
+				errs[f.Key] = fmt.Sprintf("%s must be a valid phone number", f.Label)
+				continue
+			}
+		case "select":
+			if len(f.Options) > 0 && !containsString(f.Options, value) {
+				errs[f.Key] = fmt.Sprintf("%s must be one of: %s", f.Label, strings.Join(f.Options, ", "))
+				continue
+			}
+		}
+
+		if f.Pattern != "" {
+			re, err := regexp.Compile(f.Pattern)
+			if err != nil {
+				log.Warn().Err(err).Str("field", f.Key).Str("pattern", f.Pattern).
+					Msg("Invalid product field pattern, skipping check")
+			} else if !re.MatchString(value) {
+				errs[f.Key] = fmt.Sprintf("%s format is invalid", f.Label)
+				continue
+			}
+		}
+
+		values[f.Key] = value
+	}
+
+	return values, errs
+}
+
+// isDigits reports whether value is made of ASCII digits only
+func isDigits(value string) bool {
+	if value == "" {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		if value[i] < '0' || value[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// renderAccountTemplate replaces {key} placeholders with account input values
+func renderAccountTemplate(tmpl string, inputs map[string]string) string {
+	return templatePlaceholder.ReplaceAllStringFunc(tmpl, func(match string) string {
+		return inputs[match[1:len(match)-1]]
+	})
+}
+
+// legacyCustomerNo builds the customer number used before product templates existed
+func legacyCustomerNo(inputs map[string]string) string {
+	if userID := inputs["userId"]; userID != "" {
+		if zoneID := inputs["zoneId"]; zoneID != "" {
+			return userID + zoneID
+		}
+		return userID + inputs["serverId"]
+	}
+	return inputs["phoneNumber"]
+}
+
+// resolveCustomerTarget builds the provider customer number and extra customer data
+// for a transaction from its account inputs and the product templates.
+func resolveCustomerTarget(ctx context.Context, deps *Dependencies, transactionID string, accountData map[string]interface{}) (string, map[string]string) {
+	inputs := normalizeAccountInputs(accountData)
+
+	var customerNoTemplate *string
+	var customerDataTemplate []byte
+	err := deps.DB.Pool.QueryRow(ctx, `
+		SELECT p.customer_no_template, p.customer_data_template
+		FROM transactions t
+		JOIN products p ON t.product_id = p.id
+		WHERE t.id = $1
+	`, transactionID).Scan(&customerNoTemplate, &customerDataTemplate)
+	if err != nil {
+		log.Warn().Err(err).Str("transaction_id", transactionID).
+			Msg("Failed to load product customer templates, using defaults")
+		return legacyCustomerNo(inputs), nil
+	}
+
+	customerNo := legacyCustomerNo(inputs)
+	if customerNoTemplate != nil && strings.TrimSpace(*customerNoTemplate) != "" {
+		customerNo = renderAccountTemplate(*customerNoTemplate, inputs)
+	}
+
+	var dataTemplate map[string]string
+	if len(customerDataTemplate) == 0 || json.Unmarshal(customerDataTemplate, &dataTemplate) != nil {
+		return customerNo, nil
+	}
+
+	customerData := make(map[string]string, len(dataTemplate))
+	for name, tmpl := range dataTemplate {
+		if value := renderAccountTemplate(tmpl, inputs); value != "" {
+			customerData[name] = value
+		}
+	}
+	if len(customerData) == 0 {
+		return customerNo, nil
+	}
+	return customerNo, customerData
+}
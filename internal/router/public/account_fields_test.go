@@ -0,0 +1,40 @@
+package public
+
+import "testing"
+
+func TestValidateAccountInputsNumber(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+
+	tests := []struct {
+		name    string
+		field   accountField
+		value   string
+		wantErr bool
+	}{
+		{"digits", accountField{Type: "number"}, "123456789", false},
+		{"longer than uint64", accountField{Type: "number"}, "123456789012345678901234", false},
+		{"above max uint64", accountField{Type: "number"}, "99999999999999999999", false},
+		{"leading zero", accountField{Type: "number"}, "000123", false},
+		{"letters", accountField{Type: "number"}, "12a4", true},
+		{"sign", accountField{Type: "number"}, "-1234", true},
+		{"non-ASCII digits", accountField{Type: "number"}, "١٢٣", true},
+		{"below min length", accountField{Type: "number", MinLength: intPtr(6)}, "12345", true},
+		{"above max length", accountField{Type: "number", MaxLength: intPtr(25)}, "12345678901234567890123456", true},
+		{"within length", accountField{Type: "number", MinLength: intPtr(6), MaxLength: intPtr(25)}, "1234567890123456789012", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.field.Key = "userId"
+			tt.field.Label = "User ID"
+			values, errs := validateAccountInputs([]accountField{tt.field}, map[string]string{"userId": tt.value})
+
+			if _, failed := errs["userId"]; failed != tt.wantErr {
+				t.Fatalf("error = %q, want error %v", errs["userId"], tt.wantErr)
+			}
+			if !tt.wantErr && values["userId"] != tt.value {
+				t.Errorf("value = %q, want %q", values["userId"], tt.value)
+			}
+		})
+	}
+}
@@ -353,13 +353,14 @@ func HandleUserLoginImpl(deps *Dependencies) http.HandlerFunc {
 						// Store verification token in Redis (30 minutes expiry)
 						_ = deps.Redis.Set(r.Context(), tokenKey, verificationToken, 30*time.Minute)
 
-						// Send verification email in background
-						go func() {
-							emailService := deps.EmailService
-							if emailService != nil {
-								_ = emailService.SendVerificationEmail(user.Email, user.FirstName, verificationToken)
+						// Queue verification email
+						if deps.EmailService != nil && deps.EmailOutbox != nil {
+							if message, err := deps.EmailService.VerificationEmail(user.Email, user.FirstName, verificationToken); err == nil {
+								message.ReferenceType = "USER"
+								message.ReferenceID = user.ID
+								_, _ = deps.EmailOutbox.Queue(r.Context(), message)
 							}
-						}()
+						}
 					}
 				}
 
@@ -0,0 +1,688 @@
+package public
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"seaply/internal/provider"
+	"seaply/internal/services"
+	"seaply/internal/utils"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// unitRefID derives the provider ref ID of one unit of a multi-quantity order
+func unitRefID(invoiceNumber string, unit int) string {
+	return fmt.Sprintf("%s-%d", invoiceNumber, unit)
+}
+
+// mapProviderOrderStatus maps a provider order status to a transaction status
+func mapProviderOrderStatus(status string) string {
+	switch status {
+	case provider.StatusSuccess:
+		return "SUCCESS"
+	case provider.StatusFailed:
+		return "FAILED"
+	default:
+		return "PROCESSING"
+	}
+}
+
+// appendProviderLog appends an entry to the provider_logs of a transaction
+func appendProviderLog(ctx context.Context, deps *Dependencies, transactionID, eventType string, data interface{}) {
+	_, _ = deps.DB.Pool.Exec(ctx, `
+		UPDATE transactions
+		SET provider_logs = COALESCE(provider_logs, '[]'::jsonb) || $1::jsonb, updated_at = NOW()
+		WHERE id = $2
+	`, mustMarshalJSON([]interface{}{createLogEntry(eventType, data)}), transactionID)
+}
+
+// dispatchTransactionUnits sends one provider order per unit of a multi-quantity
+// order, each with a ref ID derived from the invoice number. Units are ordered in
+// the background. It returns false for single-unit orders, which keep the regular
+// fulfilment flow.
+func dispatchTransactionUnits(ctx context.Context, deps *Dependencies, prov provider.Provider, transactionID, providerSKU, customerNo string, customerData map[string]string) bool {
+	var invoiceNumber string
+	var quantity int
+	err := deps.DB.Pool.QueryRow(ctx, `
+		SELECT invoice_number, quantity FROM transactions WHERE id = $1
+	`, transactionID).Scan(&invoiceNumber, &quantity)
+	if err != nil {
+		log.Error().Err(err).Str("transaction_id", transactionID).Msg("Failed to load transaction for fulfilment")
+		return false
+	}
+	if quantity <= 1 {
+		return false
+	}
+
+	// Units are created once, a replayed payment callback only resumes pending units
+	for unit := 1; unit <= quantity; unit++ {
+		_, err = deps.DB.Pool.Exec(ctx, `
+			INSERT INTO transaction_units (transaction_id, unit_number, ref_id)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (transaction_id, unit_number) DO NOTHING
+		`, transactionID, unit, unitRefID(invoiceNumber, unit))
+		if err != nil {
+			log.Error().Err(err).Str("transaction_id", transactionID).Int("unit", unit).Msg("Failed to create transaction unit")
+			return true
+		}
+	}
+
+	go processTransactionUnits(deps, prov, transactionID, invoiceNumber, providerSKU, customerNo, customerData, quantity)
+	return true
+}
+
+// processTransactionUnits orders every pending unit of a transaction and settles it
+func processTransactionUnits(deps *Dependencies, prov provider.Provider, transactionID, invoiceNumber, providerSKU, customerNo string, customerData map[string]string, quantity int) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(quantity)*30*time.Second)
+	defer cancel()
+
+	// Claim pending units so concurrent callbacks never order the same unit twice
+	rows, err := deps.DB.Pool.Query(ctx, `
+		UPDATE transaction_units
+		SET status = 'PROCESSING', updated_at = NOW()
+		WHERE transaction_id = $1 AND status = 'PENDING'
+		RETURNING unit_number, ref_id
+	`, transactionID)
+	if err != nil {
+		log.Error().Err(err).Str("transaction_id", transactionID).Msg("Failed to claim transaction units")
+		return
+	}
+
+	type pendingUnit struct {
+		number int
+		refID  string
+	}
+	var units []pendingUnit
+	for rows.Next() {
+		var u pendingUnit
+		if err := rows.Scan(&u.number, &u.refID); err == nil {
+			units = append(units, u)
+		}
+	}
+	rows.Close()
+
+	log.Info().
+		Str("invoice_number", invoiceNumber).
+		Str("provider", prov.GetName()).
+		Str("sku", providerSKU).
+		Int("quantity", quantity).
+		Int("units", len(units)).
+		Msg("Processing multi-quantity transaction to provider")
+
+	for _, u := range units {
+		orderResp, err := createProviderOrder(ctx, deps, transactionID, prov, &provider.OrderRequest{
+			RefID:        u.refID,
+			SKU:          providerSKU,
+			CustomerNo:   customerNo,
+			CustomerData: customerData,
+		})
+
+		if orderResp != nil && len(orderResp.RawRequest) > 0 {
+			var rawReqData interface{}
+			json.Unmarshal(orderResp.RawRequest, &rawReqData)
+			appendProviderLog(ctx, deps, transactionID, "ORDER_REQUEST", map[string]interface{}{
+				"unit":    u.number,
+				"refId":   u.refID,
+				"request": rawReqData,
+			})
+		}
+
+		if err != nil {
+			log.Error().Err(err).
+				Str("invoice_number", invoiceNumber).
+				Str("ref_id", u.refID).
+				Msg("Failed to process transaction unit to provider")
+
+			appendProviderLog(ctx, deps, transactionID, "ORDER_FAILED", map[string]interface{}{
+				"unit":  u.number,
+				"refId": u.refID,
+				"error": err.Error(),
+			})
+			_, _ = deps.DB.Pool.Exec(ctx, `
+				UPDATE transaction_units
+				SET status = 'FAILED', message = $1, completed_at = NOW(), updated_at = NOW()
+				WHERE ref_id = $2 AND status = 'PROCESSING'
+			`, err.Error(), u.refID)
+			continue
+		}
+
+		var rawRespData interface{}
+		if len(orderResp.RawResponse) > 0 {
+			json.Unmarshal(orderResp.RawResponse, &rawRespData)
+		} else {
+			rawRespData = map[string]interface{}{
+				"ref_id":          orderResp.RefID,
+				"provider_ref_id": orderResp.ProviderRefID,
+				"status":          orderResp.Status,
+				"message":         orderResp.Message,
+				"sn":              orderResp.SN,
+			}
+		}
+		appendProviderLog(ctx, deps, transactionID, "ORDER_RESPONSE", map[string]interface{}{
+			"unit":     u.number,
+			"refId":    u.refID,
+			"response": rawRespData,
+		})
+
+		// A callback may already have finalised the unit
+		unitStatus := mapProviderOrderStatus(orderResp.Status)
+		_, _ = deps.DB.Pool.Exec(ctx, `
+			UPDATE transaction_units
+			SET status = $1::transaction_status,
+			    provider_ref_id = $2,
+			    serial_number = NULLIF($3, ''),
+			    message = $4,
+			    provider_response = $5,
+			    completed_at = CASE WHEN $1::text IN ('SUCCESS', 'FAILED') THEN NOW() ELSE completed_at END,
+			    updated_at = NOW()
+			WHERE ref_id = $6 AND status = 'PROCESSING'
+		`, unitStatus, orderResp.ProviderRefID, orderResp.SN, orderResp.Message, mustMarshalJSON(rawRespData), u.refID)
+	}
+
+	settleTransactionUnits(ctx, deps, transactionID)
+}
+
+// applyUnitCallback applies a provider callback to the unit carrying refID.
+// It returns false when refID does not belong to a multi-quantity order.
+func applyUnitCallback(ctx context.Context, deps *Dependencies, refID, status, serialNumber string, payload interface{}) bool {
+	return applyUnitStatus(ctx, deps, "PROVIDER_CALLBACK", refID, status, serialNumber, payload)
+}
+
+// applyUnitStatus applies a status reported by the provider, through a callback or
+// a status check, to the unit carrying refID and settles the order once it is final
+func applyUnitStatus(ctx context.Context, deps *Dependencies, eventType, refID, status, serialNumber string, payload interface{}) bool {
+	var transactionID, currentStatus string
+	err := deps.DB.Pool.QueryRow(ctx, `
+		SELECT transaction_id, status FROM transaction_units WHERE ref_id = $1
+	`, refID).Scan(&transactionID, &currentStatus)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			log.Error().Err(err).Str("ref_id", refID).Msg("Failed to look up transaction unit")
+		}
+		return false
+	}
+
+	appendProviderLog(ctx, deps, transactionID, eventType, map[string]interface{}{
+		"refId": refID,
+		"data":  payload,
+	})
+
+	// Final unit statuses are never overwritten
+	if currentStatus == "SUCCESS" || currentStatus == "FAILED" || (status != "SUCCESS" && status != "FAILED") {
+		log.Info().
+			Str("ref_id", refID).
+			Str("event", eventType).
+			Str("current_status", currentStatus).
+			Str("new_status", status).
+			Msg("Ignoring status for transaction unit")
+		return true
+	}
+
+	_, err = deps.DB.Pool.Exec(ctx, `
+		UPDATE transaction_units
+		SET status = $1::transaction_status,
+		    serial_number = COALESCE(NULLIF($2, ''), serial_number),
+		    provider_response = $3,
+		    completed_at = NOW(),
+		    updated_at = NOW()
+		WHERE ref_id = $4 AND status NOT IN ('SUCCESS', 'FAILED')
+	`, status, serialNumber, mustMarshalJSON(payload), refID)
+	if err != nil {
+		log.Error().Err(err).Str("ref_id", refID).Msg("Failed to update transaction unit")
+		return true
+	}
+
+	settleTransactionUnits(ctx, deps, transactionID)
+	return true
+}
+
+// settleTransactionUnits rolls unit results up into the transaction. Once every
+// unit is final the order succeeds if at least one unit was delivered, and the
+// share of failed units is refunded to the customer balance, or queued as a
+// pending refund for an admin when the order has no user.
+func settleTransactionUnits(ctx context.Context, deps *Dependencies, transactionID string) {
+	tx, err := deps.DB.Pool.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Str("transaction_id", transactionID).Msg("Failed to begin unit settlement")
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var status, invoiceNumber, currency string
+	var userID sql.NullString
+	var quantity int
+	var sellPrice, discountAmount int64
+	err = tx.QueryRow(ctx, `
+		SELECT status, user_id, invoice_number, quantity, sell_price, discount_amount, currency
+		FROM transactions
+		WHERE id = $1
+		FOR UPDATE
+	`, transactionID).Scan(&status, &userID, &invoiceNumber, &quantity, &sellPrice, &discountAmount, &currency)
+	if err != nil {
+		log.Error().Err(err).Str("transaction_id", transactionID).Msg("Failed to lock transaction for unit settlement")
+		return
+	}
+
+	// Already settled by another callback
+	if status == "SUCCESS" || status == "FAILED" {
+		return
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT status, COALESCE(serial_number, '') FROM transaction_units
+		WHERE transaction_id = $1
+		ORDER BY unit_number
+	`, transactionID)
+	if err != nil {
+		log.Error().Err(err).Str("transaction_id", transactionID).Msg("Failed to load transaction units")
+		return
+	}
+
+	var succeeded, failed, pending int
+	var serialNumbers []string
+	for rows.Next() {
+		var unitStatus, serialNumber string
+		if err := rows.Scan(&unitStatus, &serialNumber); err != nil {
+			continue
+		}
+		switch unitStatus {
+		case "SUCCESS":
+			succeeded++
+			if serialNumber != "" {
+				serialNumbers = append(serialNumbers, serialNumber)
+			}
+		case "FAILED":
+			failed++
+		default:
+			pending++
+		}
+	}
+	rows.Close()
+
+	serials := strings.Join(serialNumbers, ", ")
+
+	// Keep collecting serial numbers until every unit is final
+	if pending > 0 || succeeded+failed < quantity {
+		if _, err := tx.Exec(ctx, `
+			UPDATE transactions
+			SET status = 'PROCESSING', provider_serial_number = NULLIF($1, ''), updated_at = NOW()
+			WHERE id = $2
+		`, serials, transactionID); err != nil {
+			log.Error().Err(err).Str("transaction_id", transactionID).Msg("Failed to update serial numbers of transaction units")
+			return
+		}
+		if tx.Commit(ctx) == nil {
+			publishInvoiceStatus(deps, invoiceNumber)
+		}
+		return
+	}
+
+	finalStatus := "SUCCESS"
+	timelineMessage := "Item has been successfully sent."
+	if succeeded == 0 {
+		finalStatus = "FAILED"
+		timelineMessage = "Item has been failed to sent."
+	} else if failed > 0 {
+		timelineMessage = fmt.Sprintf("%d of %d items have been successfully sent.", succeeded, quantity)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE transactions
+		SET status = $1::transaction_status,
+		    provider_serial_number = NULLIF($2, ''),
+		    completed_at = CASE WHEN $1::text = 'SUCCESS' THEN NOW() ELSE completed_at END,
+		    updated_at = NOW()
+		WHERE id = $3
+	`, finalStatus, serials, transactionID)
+	if err != nil {
+		log.Error().Err(err).Str("transaction_id", transactionID).Msg("Failed to settle transaction units")
+		return
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO transaction_logs (transaction_id, status, message, created_at)
+		VALUES ($1, $2, $3, NOW())
+	`, transactionID, finalStatus, timelineMessage); err != nil {
+		log.Error().Err(err).Str("transaction_id", transactionID).Msg("Failed to log unit settlement")
+		return
+	}
+
+	// Partial success: refund the paid share of the failed units
+	var refundAmount int64
+	if finalStatus == "SUCCESS" && failed > 0 {
+		var unitRefund int64
+		unitRefund, refundAmount = partialRefund(sellPrice, discountAmount, quantity, failed)
+
+		if refundAmount > 0 && userID.Valid {
+			if err := refundUnitsToBalance(ctx, tx, transactionID, userID.String, invoiceNumber, currency, unitRefund, refundAmount, failed); err != nil {
+				log.Error().Err(err).Str("transaction_id", transactionID).Msg("Failed to refund failed units to balance")
+				return
+			}
+		} else if refundAmount > 0 {
+			// Guests have no balance, the refund waits in the admin refund flow
+			if err := queueUnitRefund(ctx, tx, transactionID, invoiceNumber, currency, refundAmount, failed, quantity); err != nil {
+				log.Error().Err(err).Str("transaction_id", transactionID).Msg("Failed to queue refund of failed units")
+				return
+			}
+			refundAmount = 0
+		}
+	}
+
+	notification := queueOrderNotification(ctx, deps, tx, transactionID, finalStatus)
+	var refundNotification services.Notification
+	if refundAmount > 0 {
+		refundNotification, err = deps.NotificationService.NotifyTransactionTx(ctx, tx, transactionID, services.EventRefund, map[string]interface{}{
+			"Amount":   utils.FormatCurrency(float64(refundAmount), currency),
+			"RefundTo": "BALANCE",
+			"Reason":   fmt.Sprintf("%d of %d items could not be delivered", failed, quantity),
+		})
+		if err != nil {
+			log.Warn().Err(err).Str("transaction_id", transactionID).Msg("Failed to queue refund notification")
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Str("transaction_id", transactionID).Msg("Failed to commit unit settlement")
+		return
+	}
+
+	log.Info().
+		Str("invoice_number", invoiceNumber).
+		Str("status", finalStatus).
+		Int("succeeded", succeeded).
+		Int("failed", failed).
+		Int64("refund_amount", refundAmount).
+		Msg("Multi-quantity transaction settled")
+
+	notifyOrderStatus(deps, transactionID, finalStatus, notification)
+	deps.NotificationService.Notify(refundNotification)
+}
+
+// partialRefund splits the paid amount of an order evenly over its units and
+// returns the refund of one unit and of the failed units
+func partialRefund(sellPrice, discountAmount int64, quantity, failed int) (unitRefund, total int64) {
+	if quantity <= 0 || failed <= 0 {
+		return 0, 0
+	}
+	unitRefund = (sellPrice*int64(quantity) - discountAmount) / int64(quantity)
+	if unitRefund < 0 {
+		return 0, 0
+	}
+	return unitRefund, unitRefund * int64(failed)
+}
+
+// refundUnitsToBalance credits the refund of the failed units to the balance of the buyer in tx
+func refundUnitsToBalance(ctx context.Context, tx pgx.Tx, transactionID, userID, invoiceNumber, currency string, unitRefund, refundAmount int64, failed int) error {
+	balanceColumn := balanceColumnForCurrency(currency)
+
+	var balanceBefore int64
+	err := tx.QueryRow(ctx, "SELECT "+balanceColumn+" FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&balanceBefore)
+	if err != nil {
+		return fmt.Errorf("failed to get balance: %w", err)
+	}
+	balanceAfter := balanceBefore + refundAmount
+
+	if _, err := tx.Exec(ctx, "UPDATE users SET "+balanceColumn+" = $1, updated_at = NOW() WHERE id = $2", balanceAfter, userID); err != nil {
+		return fmt.Errorf("failed to credit balance: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO mutations (
+			user_id, invoice_number, reference_type, reference_id,
+			description, mutation_type, amount,
+			balance_before, balance_after, currency, created_at
+		) VALUES ($1, $2, 'REFUND', $3, $4, 'CREDIT', $5, $6, $7, $8, NOW())
+	`, userID, invoiceNumber, transactionID,
+		fmt.Sprintf("Pengembalian Dana - %d item gagal", failed), refundAmount,
+		balanceBefore, balanceAfter, currency)
+	if err != nil {
+		return fmt.Errorf("failed to record mutation: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE transaction_units SET refunded_amount = $1, updated_at = NOW()
+		WHERE transaction_id = $2 AND status = 'FAILED'
+	`, unitRefund, transactionID); err != nil {
+		return fmt.Errorf("failed to update units: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE transactions SET refunded_amount = refunded_amount + $1 WHERE id = $2
+	`, refundAmount, transactionID); err != nil {
+		return fmt.Errorf("failed to update refunded amount: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO transaction_logs (transaction_id, status, message, created_at)
+		VALUES ($1, 'REFUNDED', $2, NOW())
+	`, transactionID, fmt.Sprintf("Refund of %s for %d failed items has been added to your balance.",
+		utils.FormatCurrency(float64(refundAmount), currency), failed))
+	return err
+}
+
+// queueUnitRefund records the refund of the failed units of an order without a
+// balance to credit. It stays PENDING until an admin refunds the transaction.
+func queueUnitRefund(ctx context.Context, tx pgx.Tx, transactionID, invoiceNumber, currency string, refundAmount int64, failed, quantity int) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO refunds (transaction_id, invoice_number, amount, currency, refund_to, status, reason, created_at)
+		VALUES ($1, $2, $3, $4, 'ORIGINAL', 'PENDING', $5, NOW())
+	`, transactionID, invoiceNumber, refundAmount, currency,
+		fmt.Sprintf("%d of %d items could not be delivered", failed, quantity))
+	if err != nil {
+		return fmt.Errorf("failed to record refund: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO transaction_logs (transaction_id, status, message, created_at)
+		VALUES ($1, 'REFUNDED', $2, NOW())
+	`, transactionID, fmt.Sprintf("Refund of %s for %d failed items will be processed by our team.",
+		utils.FormatCurrency(float64(refundAmount), currency), failed))
+	return err
+}
+
+// getTransactionUnits returns the units of a multi-quantity order for display
+func getTransactionUnits(ctx context.Context, deps *Dependencies, transactionID string) ([]map[string]interface{}, error) {
+	rows, err := deps.DB.Pool.Query(ctx, `
+		SELECT unit_number, ref_id, status, serial_number, refunded_amount, completed_at
+		FROM transaction_units
+		WHERE transaction_id = $1
+		ORDER BY unit_number
+	`, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []map[string]interface{}{}
+	for rows.Next() {
+		var unitNumber int
+		var refID, status string
+		var serialNumber *string
+		var refundedAmount int64
+		var completedAt *time.Time
+		if err := rows.Scan(&unitNumber, &refID, &status, &serialNumber, &refundedAmount, &completedAt); err != nil {
+			return nil, err
+		}
+
+		item := map[string]interface{}{
+			"unit":           unitNumber,
+			"refId":          refID,
+			"status":         status,
+			"serialNumber":   serialNumber,
+			"refundedAmount": float64(refundedAmount),
+			"completedAt":    nil,
+		}
+		if completedAt != nil {
+			item["completedAt"] = completedAt.Format(time.RFC3339)
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// paidOrder is a paid transaction waiting to be sent to its provider
+type paidOrder struct {
+	TransactionID string
+	InvoiceNumber string
+	ProviderID    string
+	SKUCode       string
+	ProductName   string
+	SKUName       string
+	AccountInputs map[string]interface{}
+}
+
+// fulfillPaidOrder sends a transaction paid from a wallet balance to its provider in the background
+func fulfillPaidOrder(deps *Dependencies, order paidOrder) {
+	if deps.ProviderManager == nil || heldForReview(context.Background(), deps, order.TransactionID) {
+		return
+	}
+
+	// Get provider code
+	var pCode string
+	err := deps.DB.Pool.QueryRow(context.Background(), `
+		SELECT code FROM providers WHERE id = $1
+	`, order.ProviderID).Scan(&pCode)
+	if err != nil {
+		return
+	}
+
+	prov, err := deps.ProviderManager.Get(strings.ToLower(pCode))
+	if err != nil {
+		return
+	}
+
+	// Get provider SKU code
+	var providerSKUCode string
+	err = deps.DB.Pool.QueryRow(context.Background(), `
+		SELECT provider_sku_code FROM skus WHERE code = $1
+	`, order.SKUCode).Scan(&providerSKUCode)
+	if err != nil || providerSKUCode == "" {
+		providerSKUCode = order.SKUCode
+	}
+
+	// Process to provider asynchronously
+	go func() {
+		provCtx, provCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer provCancel()
+
+		txID := order.TransactionID
+		invNum := order.InvoiceNumber
+		custNo, custData := resolveCustomerTarget(provCtx, deps, txID, order.AccountInputs)
+		prodName := order.ProductName
+		skuName := order.SKUName
+
+		log.Info().
+			Str("invoice_number", invNum).
+			Str("provider", pCode).
+			Str("sku", providerSKUCode).
+			Str("customer_no", custNo).
+			Msg("Processing balance transaction to provider")
+
+		// Add timeline entry: Processing order
+		_, _ = deps.DB.Pool.Exec(provCtx, `
+			INSERT INTO transaction_logs (transaction_id, status, message, created_at)
+			VALUES ($1, 'PROCESSING', $2, NOW())
+		`, txID, fmt.Sprintf("Processing order %s %s", prodName, skuName))
+
+		// Multi-quantity orders are fulfilled unit by unit
+		if dispatchTransactionUnits(provCtx, deps, prov, txID, providerSKUCode, custNo, custData) {
+			return
+		}
+
+		orderReq := &provider.OrderRequest{
+			RefID:        invNum,
+			SKU:          providerSKUCode,
+			CustomerNo:   custNo,
+			CustomerData: custData,
+		}
+
+		orderResp, err := createProviderOrder(provCtx, deps, txID, prov, orderReq)
+
+		// Log the raw request from provider response
+		if orderResp != nil && len(orderResp.RawRequest) > 0 {
+			var rawReqData interface{}
+			json.Unmarshal(orderResp.RawRequest, &rawReqData)
+			providerReqLog := createLogEntry("ORDER_REQUEST", rawReqData)
+			providerReqJSON, _ := json.Marshal([]interface{}{providerReqLog})
+			_, _ = deps.DB.Pool.Exec(provCtx, `
+				UPDATE transactions
+				SET provider_logs = COALESCE(provider_logs, '[]'::jsonb) || $1::jsonb, updated_at = NOW()
+				WHERE id = $2
+			`, string(providerReqJSON), txID)
+		}
+		if err != nil {
+			log.Error().Err(err).
+				Str("invoice_number", invNum).
+				Str("provider", pCode).
+				Msg("Failed to process balance transaction to provider")
+
+			providerFailLog := createLogEntry("ORDER_FAILED", map[string]interface{}{
+				"error": err.Error(),
+			})
+			_ = settleOrder(context.Background(), deps, txID, "FAILED", `
+				UPDATE transactions
+				SET status = 'FAILED',
+				    provider_logs = COALESCE(provider_logs, '[]'::jsonb) || $1::jsonb,
+				    updated_at = NOW()
+				WHERE id = $2
+			`, mustMarshalJSON([]interface{}{providerFailLog}), txID)
+			return
+		}
+
+		var updateStatus string
+		switch orderResp.Status {
+		case "SUCCESS":
+			updateStatus = "SUCCESS"
+		case "FAILED":
+			updateStatus = "FAILED"
+		default:
+			updateStatus = "PROCESSING"
+		}
+
+		// Use raw response from provider
+		var rawRespData interface{}
+		if len(orderResp.RawResponse) > 0 {
+			json.Unmarshal(orderResp.RawResponse, &rawRespData)
+		} else {
+			rawRespData = map[string]interface{}{
+				"ref_id":          orderResp.RefID,
+				"provider_ref_id": orderResp.ProviderRefID,
+				"status":          orderResp.Status,
+				"message":         orderResp.Message,
+				"sn":              orderResp.SN,
+			}
+		}
+		providerRespJSON, _ := json.Marshal(rawRespData)
+
+		providerRespLog := createLogEntry("ORDER_RESPONSE", rawRespData)
+
+		// Adds the timeline entry: Item has been successfully sent or failed to sent (only for final status)
+		err = settleOrder(context.Background(), deps, txID, updateStatus, `
+			UPDATE transactions
+			SET status = $1::transaction_status,
+			    provider_ref_id = $2,
+			    provider_serial_number = $3,
+			    provider_response = $4,
+			    provider_logs = COALESCE(provider_logs, '[]'::jsonb) || $5::jsonb,
+			    completed_at = CASE WHEN $1::text = 'SUCCESS' THEN NOW() ELSE completed_at END,
+			    updated_at = NOW()
+			WHERE id = $6
+		`, updateStatus, orderResp.ProviderRefID, orderResp.SN, string(providerRespJSON), mustMarshalJSON([]interface{}{providerRespLog}), txID)
+
+		if err == nil {
+			log.Info().
+				Str("invoice_number", invNum).
+				Str("provider", pCode).
+				Str("status", updateStatus).
+				Str("serial_number", orderResp.SN).
+				Msg("Balance transaction processed to provider successfully")
+		}
+	}()
+}
@@ -0,0 +1,47 @@
+package public
+
+import "testing"
+
+func TestPartialRefund(t *testing.T) {
+	tests := []struct {
+		name       string
+		sellPrice  int64
+		discount   int64
+		quantity   int
+		failed     int
+		wantUnit   int64
+		wantRefund int64
+	}{
+		{"one of three failed", 10000, 0, 3, 1, 10000, 10000},
+		{"discount spread over units", 10000, 3000, 3, 2, 9000, 18000},
+		{"discount rounds down", 10000, 1000, 3, 1, 9666, 9666},
+		{"no failed units", 10000, 0, 3, 0, 0, 0},
+		{"no units", 10000, 0, 0, 1, 0, 0},
+		{"discount above price", 1000, 5000, 2, 1, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unit, refund := partialRefund(tt.sellPrice, tt.discount, tt.quantity, tt.failed)
+			if unit != tt.wantUnit || refund != tt.wantRefund {
+				t.Errorf("partialRefund() = %d, %d, want %d, %d", unit, refund, tt.wantUnit, tt.wantRefund)
+			}
+		})
+	}
+}
+
+func TestMapProviderOrderStatus(t *testing.T) {
+	tests := map[string]string{
+		"SUCCESS":    "SUCCESS",
+		"FAILED":     "FAILED",
+		"PENDING":    "PROCESSING",
+		"PROCESSING": "PROCESSING",
+		"":           "PROCESSING",
+	}
+
+	for status, want := range tests {
+		if got := mapProviderOrderStatus(status); got != want {
+			t.Errorf("mapProviderOrderStatus(%q) = %q, want %q", status, got, want)
+		}
+	}
+}
@@ -0,0 +1,415 @@
+package public
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"seaply/internal/middleware"
+	"seaply/internal/services"
+	"seaply/internal/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// GuestTokenHeader carries the token of a guest who verified their contact
+const GuestTokenHeader = "X-Guest-Token"
+
+type guestContactKey struct{}
+
+// guestContactFromContext returns the contact verified by guestOrderAuth
+func guestContactFromContext(ctx context.Context) services.GuestContact {
+	contact, _ := ctx.Value(guestContactKey{}).(services.GuestContact)
+	return contact
+}
+
+// guestOrderAuth checks the guest token and puts the verified contact in the request context
+func guestOrderAuth(deps *Dependencies) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if deps.GuestAccess == nil {
+				utils.WriteErrorJSON(w, http.StatusServiceUnavailable, "GUEST_ACCESS_UNAVAILABLE",
+					"Guest order tracking is not available", "")
+				return
+			}
+
+			contact, err := deps.GuestAccess.VerifyToken(r.Header.Get(GuestTokenHeader))
+			if err != nil {
+				code := "INVALID_GUEST_TOKEN"
+				if errors.Is(err, services.ErrGuestTokenExpired) {
+					code = "GUEST_TOKEN_EXPIRED"
+				}
+				utils.WriteErrorJSON(w, http.StatusUnauthorized, code, err.Error(), "Verify your email or phone number again")
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), guestContactKey{}, contact)))
+		})
+	}
+}
+
+// GuestOTPRequest represents the request for a guest verification code
+type GuestOTPRequest struct {
+	Email       string `json:"email"`
+	PhoneNumber string `json:"phoneNumber"`
+}
+
+// GuestVerifyRequest represents the request verifying a guest code
+type GuestVerifyRequest struct {
+	Email       string `json:"email"`
+	PhoneNumber string `json:"phoneNumber"`
+	Code        string `json:"code"`
+}
+
+// parseGuestContact validates the email or phone number of a guest request
+func parseGuestContact(email, phone string, errs map[string]string) services.GuestContact {
+	if email != "" && !utils.ValidateEmail(strings.TrimSpace(email)) {
+		errs["email"] = "Invalid email format"
+		return services.GuestContact{}
+	}
+	contact, ok := services.NewGuestContact(email, phone)
+	if !ok {
+		errs["email"] = "Email or phone number is required"
+	}
+	return contact
+}
+
+// handleGuestOrderOTPImpl sends a verification code to the contact of guest orders.
+// The response is the same whether or not the contact has orders.
+func handleGuestOrderOTPImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if deps.GuestAccess == nil {
+			utils.WriteErrorJSON(w, http.StatusServiceUnavailable, "GUEST_ACCESS_UNAVAILABLE",
+				"Guest order tracking is not available", "")
+			return
+		}
+
+		var req GuestOTPRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.WriteBadRequestError(w, "Invalid request body")
+			return
+		}
+
+		errs := map[string]string{}
+		contact := parseGuestContact(req.Email, req.PhoneNumber, errs)
+		if len(errs) > 0 {
+			utils.WriteValidationErrorJSON(w, "Validation failed", errs)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		var hasOrders bool
+		err := deps.DB.Pool.QueryRow(ctx, `
+			SELECT EXISTS(SELECT 1 FROM transactions t WHERE t.user_id IS NULL AND `+contact.Condition("t", "$1")+`)
+		`, contact.Value).Scan(&hasOrders)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to look up guest orders")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		language := services.LanguageForRegion(middleware.GetRegionFromContext(r.Context()))
+		if err := deps.GuestAccess.RequestOTP(ctx, contact, language, hasOrders); err != nil {
+			if errors.Is(err, services.ErrGuestOTPCooldown) {
+				w.Header().Set("Retry-After", "60")
+				utils.WriteErrorJSON(w, http.StatusTooManyRequests, "OTP_COOLDOWN", err.Error(), "")
+				return
+			}
+			log.Error().Err(err).Msg("Failed to send guest verification code")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"message": "If there are orders for this contact, a verification code has been sent",
+			"channel": contact.Type,
+		})
+	}
+}
+
+// handleGuestOrderVerifyImpl verifies a guest code and returns a token for the contact's orders
+func handleGuestOrderVerifyImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if deps.GuestAccess == nil {
+			utils.WriteErrorJSON(w, http.StatusServiceUnavailable, "GUEST_ACCESS_UNAVAILABLE",
+				"Guest order tracking is not available", "")
+			return
+		}
+
+		var req GuestVerifyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.WriteBadRequestError(w, "Invalid request body")
+			return
+		}
+
+		errs := map[string]string{}
+		contact := parseGuestContact(req.Email, req.PhoneNumber, errs)
+		if strings.TrimSpace(req.Code) == "" {
+			errs["code"] = "Verification code is required"
+		}
+		if len(errs) > 0 {
+			utils.WriteValidationErrorJSON(w, "Validation failed", errs)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		if err := deps.GuestAccess.VerifyOTP(ctx, contact, req.Code); err != nil {
+			if errors.Is(err, services.ErrGuestOTPInvalid) {
+				utils.WriteErrorJSON(w, http.StatusBadRequest, "INVALID_OTP", err.Error(), "")
+				return
+			}
+			log.Error().Err(err).Msg("Failed to verify guest code")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		token, expiresAt := deps.GuestAccess.Token(contact)
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"token":     token,
+			"expiresAt": expiresAt.Format(time.RFC3339),
+			"contact":   contact,
+		})
+	}
+}
+
+// handleGuestOrdersImpl lists the guest orders placed with the verified contact
+func handleGuestOrdersImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		contact := guestContactFromContext(r.Context())
+
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit <= 0 || limit > 100 {
+			limit = 10
+		}
+
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page <= 0 {
+			page = 1
+		}
+
+		offset := (page - 1) * limit
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		where := " WHERE t.user_id IS NULL AND " + contact.Condition("t", "$1")
+
+		var totalRows int
+		err := deps.DB.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM transactions t"+where, contact.Value).Scan(&totalRows)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to count guest orders")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		rows, err := deps.DB.Pool.Query(ctx, `
+			SELECT t.invoice_number, t.status::text, t.payment_status::text,
+			       p.code, p.title, s.code, s.name, t.quantity, t.account_nickname,
+			       t.total_amount, t.currency::text, t.provider_serial_number, t.created_at
+			FROM transactions t
+			JOIN products p ON t.product_id = p.id
+			JOIN skus s ON t.sku_id = s.id
+			`+where+`
+			ORDER BY t.created_at DESC
+			LIMIT $2 OFFSET $3
+		`, contact.Value, limit, offset)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to query guest orders")
+			utils.WriteInternalServerError(w)
+			return
+		}
+		defer rows.Close()
+
+		orders := []map[string]interface{}{}
+		for rows.Next() {
+			var invoiceNumber, status, paymentStatus, productCode, productName, skuCode, skuName, currency string
+			var nickname, serialNumber *string
+			var quantity int
+			var totalAmount int64
+			var createdAt time.Time
+			if err := rows.Scan(&invoiceNumber, &status, &paymentStatus, &productCode, &productName, &skuCode, &skuName,
+				&quantity, &nickname, &totalAmount, &currency, &serialNumber, &createdAt); err != nil {
+				log.Error().Err(err).Msg("Failed to scan guest order")
+				continue
+			}
+
+			orders = append(orders, map[string]interface{}{
+				"invoiceNumber": invoiceNumber,
+				"product": map[string]interface{}{
+					"code": productCode,
+					"name": productName,
+				},
+				"sku": map[string]interface{}{
+					"code": skuCode,
+					"name": skuName,
+				},
+				"quantity": quantity,
+				"status": map[string]interface{}{
+					"transaction": status,
+					"payment":     paymentStatus,
+				},
+				"nickname":     nickname,
+				"serialNumber": serialNumber,
+				"total":        totalAmount,
+				"currency":     currency,
+				"createdAt":    createdAt.Format(time.RFC3339),
+			})
+		}
+
+		totalPages := (totalRows + limit - 1) / limit
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"contact": contact,
+			"orders":  orders,
+			"pagination": map[string]interface{}{
+				"limit":      limit,
+				"page":       page,
+				"totalRows":  totalRows,
+				"totalPages": totalPages,
+			},
+		})
+	}
+}
+
+// handleGuestOrderImpl returns the full invoice of a guest order placed with the verified contact
+func handleGuestOrderImpl(deps *Dependencies) http.HandlerFunc {
+	invoice := handleGetInvoiceImpl(deps)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		contact := guestContactFromContext(r.Context())
+		invoiceNumber := chi.URLParam(r, "invoiceNumber")
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		var owned bool
+		err := deps.DB.Pool.QueryRow(ctx, `
+			SELECT EXISTS(
+				SELECT 1 FROM transactions t
+				WHERE t.invoice_number = $2 AND t.user_id IS NULL AND `+contact.Condition("t", "$1")+`
+			)
+		`, contact.Value, invoiceNumber).Scan(&owned)
+		if err != nil {
+			log.Error().Err(err).Str("invoice_number", invoiceNumber).Msg("Failed to look up guest order")
+			utils.WriteInternalServerError(w)
+			return
+		}
+		if !owned {
+			utils.WriteNotFoundError(w, "Order")
+			return
+		}
+
+		query := r.URL.Query()
+		query.Set("invoiceNumber", invoiceNumber)
+		r.URL.RawQuery = query.Encode()
+		invoice(w, r)
+	}
+}
+
+// handleClaimGuestOrdersImpl moves the guest orders of the verified contact into the
+// signed-in account, e.g. right after the guest registers
+func handleClaimGuestOrdersImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		contact := guestContactFromContext(r.Context())
+		userID := middleware.GetUserIDFromContext(r.Context())
+		if userID == "" {
+			utils.WriteUnauthorizedError(w)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		tx, err := deps.DB.Pool.Begin(ctx)
+		if err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		var status string
+		err = tx.QueryRow(ctx, `SELECT status::text FROM users WHERE id = $1`, userID).Scan(&status)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				utils.WriteUnauthorizedError(w)
+				return
+			}
+			utils.WriteInternalServerError(w)
+			return
+		}
+		if status != "ACTIVE" {
+			utils.WriteErrorJSON(w, http.StatusForbidden, "USER_NOT_ACTIVE", "User account is not active", "")
+			return
+		}
+
+		rows, err := tx.Query(ctx, `
+			UPDATE transactions t SET user_id = $2, updated_at = NOW()
+			WHERE t.user_id IS NULL AND `+contact.Condition("t", "$1")+`
+			RETURNING t.id, t.invoice_number
+		`, contact.Value, userID)
+		if err != nil {
+			log.Error().Err(err).Str("user_id", userID).Msg("Failed to claim guest orders")
+			utils.WriteInternalServerError(w)
+			return
+		}
+		var ids []string
+		invoiceNumbers := []string{}
+		for rows.Next() {
+			var id, invoiceNumber string
+			if err := rows.Scan(&id, &invoiceNumber); err != nil {
+				rows.Close()
+				utils.WriteInternalServerError(w)
+				return
+			}
+			ids = append(ids, id)
+			invoiceNumbers = append(invoiceNumbers, invoiceNumber)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		if len(ids) > 0 {
+			logData, _ := json.Marshal(map[string]interface{}{
+				"userId":      userID,
+				"contactType": contact.Type,
+			})
+			_, err = tx.Exec(ctx, `
+				INSERT INTO transaction_logs (transaction_id, status, message, data, created_at)
+				SELECT id, 'CLAIMED', 'Guest order claimed into account', $2, NOW()
+				FROM unnest($1::uuid[]) AS id
+			`, ids, logData)
+			if err != nil {
+				log.Error().Err(err).Str("user_id", userID).Msg("Failed to log claimed guest orders")
+				utils.WriteInternalServerError(w)
+				return
+			}
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		log.Info().
+			Str("user_id", userID).
+			Int("orders", len(ids)).
+			Msg("Guest orders claimed")
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"claimed":        len(ids),
+			"invoiceNumbers": invoiceNumbers,
+		})
+	}
+}
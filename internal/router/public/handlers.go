@@ -8,7 +8,6 @@ import (
 	"io"
 	"math"
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 
@@ -19,6 +18,7 @@ import (
 	"seaply/internal/payment"
 	"seaply/internal/provider"
 	"seaply/internal/router/user"
+	"seaply/internal/services"
 	"seaply/internal/utils"
 
 	"github.com/jackc/pgx/v5"
@@ -144,53 +144,16 @@ func HandleValidatePromo(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
-		// Get required product fields
-		rows, err := deps.DB.Pool.Query(ctx, `
-			SELECT key, is_required
-			FROM product_fields
-			WHERE product_id = $1
-			ORDER BY sort_order ASC
-		`, productID)
-
+		// Validate account inputs against the product field schema
+		fields, err := loadAccountFields(ctx, deps, req.ProductCode)
 		if err != nil {
 			utils.WriteInternalServerError(w)
 			return
 		}
-		defer rows.Close()
-
-		requiredFields := make(map[string]bool)
-		allFields := make(map[string]bool)
-		for rows.Next() {
-			var fieldKey string
-			var isRequired bool
-			if err := rows.Scan(&fieldKey, &isRequired); err == nil {
-				allFields[fieldKey] = true
-				if isRequired {
-					requiredFields[fieldKey] = true
-				}
-			}
-		}
 
-		// Validate account contains all required fields
-		if req.Account == nil {
-			req.Account = make(map[string]interface{})
-		}
-
-		missingFields := []string{}
-		for fieldKey, required := range requiredFields {
-			if required {
-				value, exists := req.Account[fieldKey]
-				if !exists || value == nil || value == "" {
-					missingFields = append(missingFields, fieldKey)
-				}
-			}
-		}
-
-		if len(missingFields) > 0 {
-			utils.WriteValidationErrorJSON(w, "Validation failed", map[string]string{
-				"account": fmt.Sprintf("Missing required account fields: %s", strings.Join(missingFields, ", ")),
-			})
-			return
+		allFields := make(map[string]bool, len(fields))
+		for _, field := range fields {
+			allFields[field.Key] = true
 		}
 
 		// Validate account only contains valid product field keys
@@ -203,42 +166,42 @@ func HandleValidatePromo(deps *Dependencies) http.HandlerFunc {
 			}
 		}
 
+		if _, fieldErrors := validateAccountInputs(fields, normalizeAccountInputs(req.Account)); len(fieldErrors) > 0 {
+			utils.WriteValidationErrorJSON(w, "Validation failed", fieldErrors)
+			return
+		}
+
 		// Validate quantity (default to 1 if not provided or invalid)
 		quantity := req.Quantity
 		if quantity <= 0 {
 			quantity = 1
 		}
 
-		// Get SKU price to calculate original amount
-		var skuPrice int64
-		err = deps.DB.Pool.QueryRow(ctx, `
-			SELECT sp.sell_price
-			FROM skus s
-			JOIN sku_pricing sp ON s.id = sp.sku_id
-			JOIN products p ON s.product_id = p.id
-			WHERE s.code = $1 AND p.code = $2 AND s.is_active = true AND sp.is_active = true
-			LIMIT 1
-		`, req.SKUCode, req.ProductCode).Scan(&skuPrice)
+		// Get region from context or use provided region
+		region := middleware.GetRegionFromContext(r.Context())
+		if region == "" {
+			region = strings.ToUpper(req.Region)
+		}
 
+		// Get SKU price for the region to calculate original amount
+		currency, err := getRegionCurrency(ctx, deps.DB.Pool, region)
 		if err != nil {
-			if err == pgx.ErrNoRows {
-				utils.WriteErrorJSON(w, http.StatusNotFound, "SKU_NOT_FOUND",
-					"SKU not found", "The SKU code does not exist or is inactive")
-				return
-			}
-			utils.WriteInternalServerError(w)
+			writeRegionPricingError(w, err, region)
 			return
 		}
 
-		// Calculate original amount: SKU price * quantity
-		originalAmount := float64(skuPrice) * float64(quantity)
-
-		// Get region from context or use provided region
-		region := middleware.GetRegionFromContext(r.Context())
-		if region == "" {
-			region = strings.ToUpper(req.Region)
+		sku, err := getSKURegionPrice(ctx, deps.DB.Pool, req.ProductCode, req.SKUCode, region)
+		if err == nil {
+			err = checkCurrency(currency, sku.Currency, "pricing")
+		}
+		if err != nil {
+			writeRegionPricingError(w, err, region)
+			return
 		}
 
+		// Calculate original amount: SKU price * quantity
+		originalAmount := float64(sku.SellPrice) * float64(quantity)
+
 		// Fetch promo by code
 		var promoID string
 		var promoCode, promoTitle, promoDesc *string
@@ -532,6 +495,7 @@ func HandleValidatePromo(deps *Dependencies) http.HandlerFunc {
 			"discountAmount": math.Round(discountAmount*100) / 100,
 			"originalAmount": math.Round(originalAmount*100) / 100,
 			"finalAmount":    math.Round(finalAmount*100) / 100,
+			"currency":       currency,
 			"promoDetails":   promoDetails,
 		}
 
@@ -547,6 +511,67 @@ func HandleGetDepositInvoice(deps *Dependencies) http.HandlerFunc {
 	return handleGetDepositInvoiceImpl(deps)
 }
 
+func HandleDownloadInvoicePDF(deps *Dependencies) http.HandlerFunc {
+	return handleDownloadInvoicePDFImpl(deps, false)
+}
+
+func HandleDownloadDepositInvoicePDF(deps *Dependencies) http.HandlerFunc {
+	return handleDownloadInvoicePDFImpl(deps, true)
+}
+
+func HandleInvoiceStream(deps *Dependencies) http.HandlerFunc {
+	return handleInvoiceStreamImpl(deps, false)
+}
+
+func HandleDepositInvoiceStream(deps *Dependencies) http.HandlerFunc {
+	return handleInvoiceStreamImpl(deps, true)
+}
+
+func PartnerAuth(deps *Dependencies) func(http.Handler) http.Handler {
+	return partnerAuth(deps)
+}
+
+func HandlePartnerBalance(deps *Dependencies) http.HandlerFunc {
+	return handlePartnerBalanceImpl(deps)
+}
+
+func HandlePartnerPriceList(deps *Dependencies) http.HandlerFunc {
+	return handlePartnerPriceListImpl(deps)
+}
+
+func HandlePartnerCreateOrder(deps *Dependencies) http.HandlerFunc {
+	return handlePartnerCreateOrderImpl(deps)
+}
+
+func HandlePartnerGetOrder(deps *Dependencies) http.HandlerFunc {
+	return handlePartnerGetOrderImpl(deps)
+}
+
+// Guest order tracking handlers
+func GuestOrderAuth(deps *Dependencies) func(http.Handler) http.Handler {
+	return guestOrderAuth(deps)
+}
+
+func HandleGuestOrderOTP(deps *Dependencies) http.HandlerFunc {
+	return handleGuestOrderOTPImpl(deps)
+}
+
+func HandleGuestOrderVerify(deps *Dependencies) http.HandlerFunc {
+	return handleGuestOrderVerifyImpl(deps)
+}
+
+func HandleGuestOrders(deps *Dependencies) http.HandlerFunc {
+	return handleGuestOrdersImpl(deps)
+}
+
+func HandleGuestOrder(deps *Dependencies) http.HandlerFunc {
+	return handleGuestOrderImpl(deps)
+}
+
+func HandleClaimGuestOrders(deps *Dependencies) http.HandlerFunc {
+	return handleClaimGuestOrdersImpl(deps)
+}
+
 func HandleGetReviews(deps *Dependencies) http.HandlerFunc {
 	return handleGetReviewsImpl(deps)
 }
@@ -781,6 +806,14 @@ func HandleDigiflazzWebhook(deps *Dependencies) http.HandlerFunc {
 			Str("mapped_status", newStatus).
 			Msg("Processing Digiflazz webhook transaction")
 
+		// Callbacks for units of a multi-quantity order carry a derived ref ID
+		if applyUnitCallback(ctx, deps, trx.RefID, newStatus, trx.SN, trx) {
+			utils.WriteSuccessJSON(w, map[string]interface{}{
+				"status": "ok",
+			})
+			return
+		}
+
 		// Find transaction
 		var transactionID, currentStatus, skuID, accountInputs string
 		var retryCount int
@@ -827,11 +860,14 @@ func HandleDigiflazzWebhook(deps *Dependencies) http.HandlerFunc {
 					} else if retryCount == 1 && skuCodeBackup2 != nil && *skuCodeBackup2 != "" {
 						backupSKU = *skuCodeBackup2
 					} else {
-						// No backup available, just retry with new ref_id using original SKU
+						// No backup available, just retry with new ref_id using the SKU the order was placed with
 						var originalSKU string
 						err := deps.DB.Pool.QueryRow(ctx, `
-							SELECT s.provider_sku_code FROM skus s WHERE s.id = $1
-						`, skuID).Scan(&originalSKU)
+							SELECT COALESCE(t.provider_sku_code, s.provider_sku_code)
+							FROM transactions t
+							JOIN skus s ON t.sku_id = s.id
+							WHERE t.id = $1
+						`, transactionID).Scan(&originalSKU)
 						if err == nil {
 							backupSKU = originalSKU
 						}
@@ -967,16 +1003,11 @@ func HandleDigiflazzWebhook(deps *Dependencies) http.HandlerFunc {
 						}
 
 						// No more backups - mark as failed
-						_, _ = deps.DB.Pool.Exec(retryCtx, `
+						_ = settleOrder(retryCtx, deps, txID, "FAILED", `
 							UPDATE transactions 
 							SET status = 'FAILED', updated_at = NOW()
 							WHERE id = $1
 						`, txID)
-
-						_, _ = deps.DB.Pool.Exec(retryCtx, `
-							INSERT INTO transaction_logs (transaction_id, status, message, created_at)
-							VALUES ($1, 'FAILED', $2, NOW())
-						`, txID, "Item has been failed to sent.")
 						return
 					}
 
@@ -1002,8 +1033,9 @@ func HandleDigiflazzWebhook(deps *Dependencies) http.HandlerFunc {
 					respJSON, _ := json.Marshal(orderResp)
 
 					if finalStatus == "PROCESSING" || finalStatus == "SUCCESS" {
-						// Pending or Success - update and wait for callback
-						_, err = deps.DB.Pool.Exec(retryCtx, `
+						// Pending or Success - update and wait for callback; the timeline
+						// entry is only added for SUCCESS, PROCESSING waits for the callback
+						_ = settleOrder(retryCtx, deps, txID, finalStatus, `
 							UPDATE transactions 
 							SET status = $1::transaction_status,
 							    provider_ref_id = $2,
@@ -1015,23 +1047,6 @@ func HandleDigiflazzWebhook(deps *Dependencies) http.HandlerFunc {
 							WHERE id = $6
 						`, finalStatus, orderResp.ProviderRefID, orderResp.SN, respJSON, string(retryRespJSON), txID)
 
-						// Add timeline entry: Item has been successfully sent or failed to sent
-						var finalMessage string
-						if finalStatus == "SUCCESS" {
-							finalMessage = "Item has been successfully sent."
-						} else if finalStatus == "FAILED" {
-							finalMessage = "Item has been failed to sent."
-						} else {
-							// PROCESSING status - don't add timeline entry yet, wait for callback
-							finalMessage = ""
-						}
-						if finalMessage != "" {
-							_, _ = deps.DB.Pool.Exec(retryCtx, `
-								INSERT INTO transaction_logs (transaction_id, status, message, created_at)
-								VALUES ($1, $2, $3, NOW())
-							`, txID, finalStatus, finalMessage)
-						}
-
 						log.Info().
 							Str("transaction_id", txID).
 							Str("backup_sku", currentSKU).
@@ -1062,18 +1077,13 @@ func HandleDigiflazzWebhook(deps *Dependencies) http.HandlerFunc {
 					}
 
 					// No more backups - mark as failed
-					_, _ = deps.DB.Pool.Exec(retryCtx, `
+					_ = settleOrder(retryCtx, deps, txID, "FAILED", `
 						UPDATE transactions 
 						SET status = 'FAILED',
 						    provider_response = $1,
 						    updated_at = NOW()
 						WHERE id = $2
 					`, respJSON, txID)
-
-					_, _ = deps.DB.Pool.Exec(retryCtx, `
-						INSERT INTO transaction_logs (transaction_id, status, message, created_at)
-						VALUES ($1, 'FAILED', $2, NOW())
-										`, txID, "Item has been failed to sent.")
 					return
 				}
 
@@ -1194,6 +1204,9 @@ func HandleDigiflazzWebhook(deps *Dependencies) http.HandlerFunc {
 					Msg("Skipping timeline entry - not final status (SUCCESS/FAILED)")
 			}
 
+			// The customer email is queued with the status change
+			notification := queueOrderNotification(ctx, deps, tx, transactionID, newStatus)
+
 			if err := tx.Commit(ctx); err != nil {
 				log.Error().Err(err).Msg("Failed to commit transaction")
 				utils.WriteInternalServerError(w)
@@ -1205,6 +1218,8 @@ func HandleDigiflazzWebhook(deps *Dependencies) http.HandlerFunc {
 				Str("old_status", currentStatus).
 				Str("new_status", newStatus).
 				Msg("Transaction status updated via Digiflazz webhook")
+
+			notifyOrderStatus(deps, transactionID, newStatus, notification)
 		}
 
 		utils.WriteSuccessJSON(w, map[string]interface{}{
@@ -1358,6 +1373,8 @@ func HandleBRIWebhook(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		publishInvoiceStatus(deps, invoiceNumber)
+
 		log.Info().
 			Str("invoice_number", invoiceNumber).
 			Float64("amount", paymentAmount).
@@ -1518,6 +1535,8 @@ func HandleDANAWebhook(deps *Dependencies) http.HandlerFunc {
 				WHERE invoice_number = $3
 			`, paidAt, string(rawCallbackJSON), invoiceNumber)
 
+			publishInvoiceStatus(deps, invoiceNumber)
+
 			// Add log: Payment received via {payment.name}
 			paymentReceivedMessage := fmt.Sprintf("Payment received via %s.", paymentName)
 			if paymentName == "" {
@@ -1528,33 +1547,13 @@ func HandleDANAWebhook(deps *Dependencies) http.HandlerFunc {
 				VALUES ($1, 'PAYMENT', $2, NOW())
 			`, transactionID, paymentReceivedMessage)
 
-			// Get Customer No (Zone if needed) from account inputs
+			// Get Customer No (Zone if needed) and customer data from account inputs
 			var accInputs map[string]interface{}
-			customerNo := ""
-			if err := json.Unmarshal([]byte(accountInputs), &accInputs); err == nil {
-				var userIdStr string
-				if userId, ok := accInputs["userId"].(string); ok && userId != "" {
-					userIdStr = userId
-				} else if userIdFloat, ok := accInputs["userId"].(float64); ok {
-					userIdStr = strconv.FormatFloat(userIdFloat, 'f', 0, 64)
-				}
+			_ = json.Unmarshal([]byte(accountInputs), &accInputs)
+			customerNo, customerData := resolveCustomerTarget(ctx, deps, transactionID, accInputs)
 
-				if userIdStr != "" {
-					customerNo = userIdStr
-					if zoneId, ok := accInputs["zoneId"].(string); ok && zoneId != "" {
-						customerNo = userIdStr + zoneId
-					} else if zoneIdFloat, ok := accInputs["zoneId"].(float64); ok {
-						customerNo = userIdStr + strconv.FormatFloat(zoneIdFloat, 'f', 0, 64)
-					} else if serverId, ok := accInputs["serverId"].(string); ok && serverId != "" {
-						customerNo = userIdStr + serverId
-					}
-				} else if phone, ok := accInputs["phoneNumber"].(string); ok && phone != "" {
-					customerNo = phone
-				}
-			}
-
-			// Call Provider
-			if deps.ProviderManager != nil && providerCode != "" {
+			// Call Provider, unless the order is held for review
+			if deps.ProviderManager != nil && providerCode != "" && !heldForReview(ctx, deps, transactionID) {
 				providerKey := strings.ToLower(providerCode)
 				prov, err := deps.ProviderManager.Get(providerKey)
 
@@ -1568,13 +1567,20 @@ func HandleDANAWebhook(deps *Dependencies) http.HandlerFunc {
 						VALUES ($1, 'PROCESSING', $2, NOW())
 					`, transactionID, processingMessage)
 
+					// Multi-quantity orders are fulfilled unit by unit
+					if dispatchTransactionUnits(ctx, deps, prov, transactionID, providerSKU, customerNo, customerData) {
+						sendDANAResponse(w, "2005600", "Successful")
+						return
+					}
+
 					req := &provider.OrderRequest{
-						RefID:      invoiceNumber,
-						SKU:        providerSKU,
-						CustomerNo: customerNo,
+						RefID:        invoiceNumber,
+						SKU:          providerSKU,
+						CustomerNo:   customerNo,
+						CustomerData: customerData,
 					}
 
-					result, err := prov.CreateOrder(ctx, req)
+					result, err := createProviderOrder(ctx, deps, transactionID, prov, req)
 
 					// Log ORDER_REQUEST
 					if result != nil && len(result.RawRequest) > 0 {
@@ -1605,19 +1611,15 @@ func HandleDANAWebhook(deps *Dependencies) http.HandlerFunc {
 							},
 						}
 						orderFailJSON, _ := json.Marshal([]interface{}{orderFailLog})
-						deps.DB.Pool.Exec(ctx, `
+
+						// Mark as failed with its timeline entry
+						_ = settleOrder(ctx, deps, transactionID, "FAILED", `
 							UPDATE transactions
 							SET status = 'FAILED',
 							    provider_logs = COALESCE(provider_logs, '[]'::jsonb) || $1::jsonb,
 							    updated_at = NOW()
 							WHERE id = $2
 						`, string(orderFailJSON), transactionID)
-
-						// Add timeline entry for failed
-						_, _ = deps.DB.Pool.Exec(ctx, `
-							INSERT INTO transaction_logs (transaction_id, status, message, created_at)
-							VALUES ($1, 'FAILED', $2, NOW())
-						`, transactionID, "Item has been failed to sent.")
 					} else {
 						// Log ORDER_RESPONSE
 						var rawRespData interface{}
@@ -1657,7 +1659,8 @@ func HandleDANAWebhook(deps *Dependencies) http.HandlerFunc {
 							"sn":      result.SN,
 						})
 
-						_, _ = deps.DB.Pool.Exec(ctx, `
+						// Only adds a timeline entry for final status (SUCCESS or FAILED), not for PROCESSING/PENDING
+						_ = settleOrder(ctx, deps, transactionID, finalStatus, `
 							UPDATE transactions
 							SET status = $1::transaction_status,
 							    provider_ref_id = $2,
@@ -1668,19 +1671,6 @@ func HandleDANAWebhook(deps *Dependencies) http.HandlerFunc {
 							    updated_at = NOW()
 							WHERE id = $6
 						`, finalStatus, result.ProviderRefID, result.SN, string(providerRespJSON), string(orderRespJSON), transactionID)
-
-						// Only add timeline entry for final status (SUCCESS or FAILED), not for PROCESSING/PENDING
-						if finalStatus == "SUCCESS" {
-							_, _ = deps.DB.Pool.Exec(ctx, `
-								INSERT INTO transaction_logs (transaction_id, status, message, created_at)
-								VALUES ($1, 'SUCCESS', $2, NOW())
-							`, transactionID, "Item has been successfully sent.")
-						} else if finalStatus == "FAILED" {
-							_, _ = deps.DB.Pool.Exec(ctx, `
-								INSERT INTO transaction_logs (transaction_id, status, message, created_at)
-								VALUES ($1, 'FAILED', $2, NOW())
-							`, transactionID, "Item has been failed to sent.")
-						}
 					}
 				}
 			} else {
@@ -1707,6 +1697,8 @@ func HandleDANAWebhook(deps *Dependencies) http.HandlerFunc {
 				VALUES ($1, 'FAILED', 'Payment failed by user', NOW())
 			`, transactionID)
 
+			publishInvoiceStatus(deps, invoiceNumber)
+
 			sendDANAResponse(w, "2005600", "Successful")
 			return
 		} else {
@@ -1869,6 +1861,8 @@ func HandleXenditWebhook(deps *Dependencies) http.HandlerFunc {
 			}
 
 			if result.RowsAffected() > 0 {
+				publishInvoiceStatus(deps, invoiceNumber)
+
 				// Update payment_data table
 				paymentDataStatus := "PENDING"
 				switch newPaymentStatus {
@@ -1918,31 +1912,12 @@ func HandleXenditWebhook(deps *Dependencies) http.HandlerFunc {
 						VALUES ($1, 'PAYMENT', $2, NOW())
 					`, transactionID, paymentReceivedMessage)
 
-					// Process to provider if payment successful
-					if shouldProcessProvider && deps.ProviderManager != nil && providerCode != "" {
+					// Process to provider if payment successful, unless the order is held for review
+					if shouldProcessProvider && deps.ProviderManager != nil && providerCode != "" && !heldForReview(ctx, deps, transactionID) {
 						// Parse account inputs
 						var accInputs map[string]interface{}
-						customerNo := ""
-						if err := json.Unmarshal([]byte(accountInputs), &accInputs); err == nil {
-							var userIdStr string
-							if userId, ok := accInputs["userId"].(string); ok && userId != "" {
-								userIdStr = userId
-							} else if userIdFloat, ok := accInputs["userId"].(float64); ok {
-								userIdStr = strconv.FormatFloat(userIdFloat, 'f', 0, 64)
-							}
-							if userIdStr != "" {
-								customerNo = userIdStr
-								if zoneId, ok := accInputs["zoneId"].(string); ok && zoneId != "" {
-									customerNo = userIdStr + zoneId
-								} else if zoneIdFloat, ok := accInputs["zoneId"].(float64); ok {
-									customerNo = userIdStr + strconv.FormatFloat(zoneIdFloat, 'f', 0, 64)
-								} else if serverId, ok := accInputs["serverId"].(string); ok && serverId != "" {
-									customerNo = userIdStr + serverId
-								}
-							} else if phone, ok := accInputs["phoneNumber"].(string); ok && phone != "" {
-								customerNo = phone
-							}
-						}
+						_ = json.Unmarshal([]byte(accountInputs), &accInputs)
+						customerNo, customerData := resolveCustomerTarget(ctx, deps, transactionID, accInputs)
 
 						if customerNo != "" && providerSKU != "" {
 							prov, err := deps.ProviderManager.Get(strings.ToLower(providerCode))
@@ -1958,13 +1933,19 @@ func HandleXenditWebhook(deps *Dependencies) http.HandlerFunc {
 										VALUES ($1, 'PROCESSING', $2, NOW())
 									`, txID, processingMessage)
 
+									// Multi-quantity orders are fulfilled unit by unit
+									if dispatchTransactionUnits(providerCtx, deps, prov, txID, sku, custNo, customerData) {
+										return
+									}
+
 									req := &provider.OrderRequest{
-										RefID:      invNum,
-										SKU:        sku,
-										CustomerNo: custNo,
+										RefID:        invNum,
+										SKU:          sku,
+										CustomerNo:   custNo,
+										CustomerData: customerData,
 									}
 
-									orderResp, err := prov.CreateOrder(providerCtx, req)
+									orderResp, err := createProviderOrder(providerCtx, deps, txID, prov, req)
 
 									// Log ORDER_REQUEST
 									if orderResp != nil && len(orderResp.RawRequest) > 0 {
@@ -2028,7 +2009,8 @@ func HandleXenditWebhook(deps *Dependencies) http.HandlerFunc {
 										"message": orderResp.Message, "sn": orderResp.SN,
 									})
 
-									deps.DB.Pool.Exec(providerCtx, `
+									// Adds the timeline entry: Item has been successfully sent or failed to sent (only for final status)
+									_ = settleOrder(providerCtx, deps, txID, updateStatus, `
 										UPDATE transactions
 										SET status = $1::transaction_status,
 										    provider_ref_id = $2,
@@ -2039,20 +2021,6 @@ func HandleXenditWebhook(deps *Dependencies) http.HandlerFunc {
 										    updated_at = NOW()
 										WHERE id = $6
 									`, updateStatus, orderResp.ProviderRefID, orderResp.SN, string(providerRespJSON), string(orderRespJSON), txID)
-
-									// Add timeline entry: Item has been successfully sent or failed to sent (only for final status)
-									if updateStatus == "SUCCESS" || updateStatus == "FAILED" {
-										var finalMessage string
-										if updateStatus == "SUCCESS" {
-											finalMessage = "Item has been successfully sent."
-										} else {
-											finalMessage = "Item has been failed to sent."
-										}
-										deps.DB.Pool.Exec(providerCtx, `
-											INSERT INTO transaction_logs (transaction_id, status, message, created_at)
-											VALUES ($1, $2, $3, NOW())
-										`, txID, updateStatus, finalMessage)
-									}
 								}(invoiceNumber, transactionID, customerNo, providerCode, providerSKU, productName, skuName)
 							}
 						}
@@ -2106,6 +2074,8 @@ func HandleXenditWebhook(deps *Dependencies) http.HandlerFunc {
 			}
 
 			if result.RowsAffected() > 0 {
+				publishInvoiceStatus(deps, invoiceNumber)
+
 				// Update payment_data table
 				rawCallbackJSON, _ := json.Marshal(vaCallback)
 				_, _ = deps.DB.Pool.Exec(ctx, `
@@ -2140,30 +2110,11 @@ func HandleXenditWebhook(deps *Dependencies) http.HandlerFunc {
 						VALUES ($1, 'PAYMENT', $2, NOW())
 					`, transactionID, paymentReceivedMessage)
 
-					// Process to provider
-					if deps.ProviderManager != nil && providerCode != "" {
+					// Process to provider, unless the order is held for review
+					if deps.ProviderManager != nil && providerCode != "" && !heldForReview(ctx, deps, transactionID) {
 						var accInputs map[string]interface{}
-						customerNo := ""
-						if err := json.Unmarshal([]byte(accountInputs), &accInputs); err == nil {
-							var userIdStr string
-							if userId, ok := accInputs["userId"].(string); ok && userId != "" {
-								userIdStr = userId
-							} else if userIdFloat, ok := accInputs["userId"].(float64); ok {
-								userIdStr = strconv.FormatFloat(userIdFloat, 'f', 0, 64)
-							}
-							if userIdStr != "" {
-								customerNo = userIdStr
-								if zoneId, ok := accInputs["zoneId"].(string); ok && zoneId != "" {
-									customerNo = userIdStr + zoneId
-								} else if zoneIdFloat, ok := accInputs["zoneId"].(float64); ok {
-									customerNo = userIdStr + strconv.FormatFloat(zoneIdFloat, 'f', 0, 64)
-								} else if serverId, ok := accInputs["serverId"].(string); ok && serverId != "" {
-									customerNo = userIdStr + serverId
-								}
-							} else if phone, ok := accInputs["phoneNumber"].(string); ok && phone != "" {
-								customerNo = phone
-							}
-						}
+						_ = json.Unmarshal([]byte(accountInputs), &accInputs)
+						customerNo, customerData := resolveCustomerTarget(ctx, deps, transactionID, accInputs)
 
 						if customerNo != "" && providerSKU != "" {
 							prov, err := deps.ProviderManager.Get(strings.ToLower(providerCode))
@@ -2179,8 +2130,13 @@ func HandleXenditWebhook(deps *Dependencies) http.HandlerFunc {
 										VALUES ($1, 'PROCESSING', $2, NOW())
 									`, txID, processingMessage)
 
-									req := &provider.OrderRequest{RefID: invNum, SKU: sku, CustomerNo: custNo}
-									orderResp, err := prov.CreateOrder(providerCtx, req)
+									// Multi-quantity orders are fulfilled unit by unit
+									if dispatchTransactionUnits(providerCtx, deps, prov, txID, sku, custNo, customerData) {
+										return
+									}
+
+									req := &provider.OrderRequest{RefID: invNum, SKU: sku, CustomerNo: custNo, CustomerData: customerData}
+									orderResp, err := createProviderOrder(providerCtx, deps, txID, prov, req)
 
 									if orderResp != nil && len(orderResp.RawRequest) > 0 {
 										var rawReqData interface{}
@@ -2214,21 +2170,12 @@ func HandleXenditWebhook(deps *Dependencies) http.HandlerFunc {
 									}
 
 									providerRespJSON, _ := json.Marshal(map[string]interface{}{"ref_id": orderResp.RefID, "status": orderResp.Status, "message": orderResp.Message, "sn": orderResp.SN})
-									deps.DB.Pool.Exec(providerCtx, `
+									// Adds the timeline entry: Item has been successfully sent or failed to sent (only for final status)
+									_ = settleOrder(providerCtx, deps, txID, updateStatus, `
 										UPDATE transactions SET status = $1::transaction_status, provider_ref_id = $2, provider_serial_number = $3, provider_response = $4,
 										provider_logs = COALESCE(provider_logs, '[]'::jsonb) || $5::jsonb, completed_at = CASE WHEN $1::text = 'SUCCESS' THEN NOW() ELSE completed_at END, updated_at = NOW()
 										WHERE id = $6
 									`, updateStatus, orderResp.ProviderRefID, orderResp.SN, string(providerRespJSON), string(orderRespJSON), txID)
-									// Add timeline entry: Item has been successfully sent or failed to sent (only for final status)
-									if updateStatus == "SUCCESS" || updateStatus == "FAILED" {
-										var finalMessage string
-										if updateStatus == "SUCCESS" {
-											finalMessage = "Item has been successfully sent."
-										} else {
-											finalMessage = "Item has been failed to sent."
-										}
-										deps.DB.Pool.Exec(providerCtx, `INSERT INTO transaction_logs (transaction_id, status, message, created_at) VALUES ($1, $2, $3, NOW())`, txID, updateStatus, finalMessage)
-									}
 								}(invoiceNumber, transactionID, customerNo, providerCode, providerSKU, productName, skuName)
 							}
 						}
@@ -2419,6 +2366,8 @@ func HandleMidtransWebhook(deps *Dependencies) http.HandlerFunc {
 		}
 
 		if result.RowsAffected() > 0 {
+			publishInvoiceStatus(deps, invoiceNumber)
+
 			// Update payment_data table status
 			paymentDataStatus := "PENDING"
 			switch newPaymentStatus {
@@ -2495,8 +2444,8 @@ func HandleMidtransWebhook(deps *Dependencies) http.HandlerFunc {
 				VALUES ($1, 'PAYMENT', $2, NOW())
 			`, transactionID, paymentReceivedMessage)
 
-			// Process to provider if settlement
-			if shouldProcessProvider && deps.ProviderManager != nil {
+			// Process to provider if settlement, unless the order is held for review
+			if shouldProcessProvider && deps.ProviderManager != nil && !heldForReview(ctx, deps, transactionID) {
 				log.Info().
 					Str("invoice_number", invoiceNumber).
 					Str("provider_id", providerID).
@@ -2527,31 +2476,8 @@ func HandleMidtransWebhook(deps *Dependencies) http.HandlerFunc {
 					// Parse account inputs
 					var accountData map[string]interface{}
 					if err := json.Unmarshal([]byte(accountInputs), &accountData); err == nil {
-						// Extract customer number (userId + zoneId/serverId or phone number)
-						customerNo := ""
-						// Handle both string and float64 types for userId
-						var userIdStr string
-						if userId, ok := accountData["userId"].(string); ok && userId != "" {
-							userIdStr = userId
-						} else if userIdFloat, ok := accountData["userId"].(float64); ok {
-							userIdStr = strconv.FormatFloat(userIdFloat, 'f', 0, 64)
-						}
-
-						if userIdStr != "" {
-							customerNo = userIdStr
-							// Append zoneId or serverId if exists
-							if zoneId, ok := accountData["zoneId"].(string); ok && zoneId != "" {
-								customerNo = userIdStr + zoneId
-							} else if zoneIdFloat, ok := accountData["zoneId"].(float64); ok {
-								customerNo = userIdStr + strconv.FormatFloat(zoneIdFloat, 'f', 0, 64)
-							} else if serverId, ok := accountData["serverId"].(string); ok && serverId != "" {
-								customerNo = userIdStr + serverId
-							} else if serverIdFloat, ok := accountData["serverId"].(float64); ok {
-								customerNo = userIdStr + strconv.FormatFloat(serverIdFloat, 'f', 0, 64)
-							}
-						} else if phone, ok := accountData["phoneNumber"].(string); ok && phone != "" {
-							customerNo = phone
-						}
+						// Extract customer number and customer data using the product templates
+						customerNo, customerData := resolveCustomerTarget(ctx, deps, transactionID, accountData)
 
 						log.Info().
 							Str("invoice_number", invoiceNumber).
@@ -2575,6 +2501,16 @@ func HandleMidtransWebhook(deps *Dependencies) http.HandlerFunc {
 										VALUES ($1, 'PROCESSING', $2, NOW())
 									`, txID, processingMessage)
 
+									// Multi-quantity orders are fulfilled unit by unit
+									if dispatchTransactionUnits(providerCtx, deps, prov, txID, sku, custNo, customerData) {
+										return
+									}
+
+									// Mapped SKUs fail over across providers instead of the backup SKU codes
+									if hasProviderMappings(providerCtx, deps, txID) {
+										backup1, backup2 = nil, nil
+									}
+
 									// Retry loop - will try main SKU, then backup1, then backup2
 									skuToUse := sku
 									for attempt := 0; attempt < 3; attempt++ {
@@ -2604,12 +2540,13 @@ func HandleMidtransWebhook(deps *Dependencies) http.HandlerFunc {
 											Msg("Processing transaction to provider")
 
 										orderReq := &provider.OrderRequest{
-											RefID:      invNum,
-											SKU:        skuToUse,
-											CustomerNo: custNo,
+											RefID:        invNum,
+											SKU:          skuToUse,
+											CustomerNo:   custNo,
+											CustomerData: customerData,
 										}
 
-										orderResp, err := prov.CreateOrder(providerCtx, orderReq)
+										orderResp, err := createProviderOrder(providerCtx, deps, txID, prov, orderReq)
 
 										// Log the raw request (from provider response)
 										if orderResp != nil && len(orderResp.RawRequest) > 0 {
@@ -2667,15 +2604,11 @@ func HandleMidtransWebhook(deps *Dependencies) http.HandlerFunc {
 											}
 
 											// No more backups - mark as failed
-											_, _ = deps.DB.Pool.Exec(context.Background(), `
+											_ = settleOrder(context.Background(), deps, txID, "FAILED", `
 											UPDATE transactions
 											SET status = 'FAILED', updated_at = NOW()
 											WHERE invoice_number = $1
 										`, invNum)
-											_, _ = deps.DB.Pool.Exec(context.Background(), `
-											INSERT INTO transaction_logs (transaction_id, status, message, created_at)
-											VALUES ($1, 'FAILED', $2, NOW())
-										`, txID, "Item has been failed to sent.")
 											return
 										}
 
@@ -2720,8 +2653,9 @@ func HandleMidtransWebhook(deps *Dependencies) http.HandlerFunc {
 										providerRespLogJSON, _ := json.Marshal([]interface{}{providerRespLog})
 
 										if updateStatus == "PROCESSING" || updateStatus == "SUCCESS" {
-											// Success or pending - update and done
-											_, err = deps.DB.Pool.Exec(context.Background(), `
+											// Success or pending - update and done, adding the timeline entry
+											// Item has been successfully sent (only for final status)
+											err = settleOrder(context.Background(), deps, txID, updateStatus, `
 											UPDATE transactions
 											SET status = $1::transaction_status,
 												provider_ref_id = $2,
@@ -2743,19 +2677,6 @@ func HandleMidtransWebhook(deps *Dependencies) http.HandlerFunc {
 												log.Info().
 													Str("invoice_number", invNum).
 													Msg("Successfully updated transaction with ORDER_RESPONSE")
-												// Add timeline entry: Item has been successfully sent or failed to sent (only for final status)
-												if updateStatus == "SUCCESS" || updateStatus == "FAILED" {
-													var finalMessage string
-													if updateStatus == "SUCCESS" {
-														finalMessage = "Item has been successfully sent."
-													} else {
-														finalMessage = "Item has been failed to sent."
-													}
-													_, _ = deps.DB.Pool.Exec(context.Background(), `
-													INSERT INTO transaction_logs (transaction_id, status, message, created_at)
-													VALUES ($1, $2, $3, NOW())
-												`, txID, updateStatus, finalMessage)
-												}
 											}
 
 											log.Info().
@@ -2785,17 +2706,13 @@ func HandleMidtransWebhook(deps *Dependencies) http.HandlerFunc {
 										}
 
 										// No more backups - mark as failed
-										_, _ = deps.DB.Pool.Exec(context.Background(), `
+										_ = settleOrder(context.Background(), deps, txID, "FAILED", `
 										UPDATE transactions
 										SET status = 'FAILED',
 											provider_response = $1,
 											updated_at = NOW()
 										WHERE invoice_number = $2
 									`, string(providerRespJSON), invNum)
-										_, _ = deps.DB.Pool.Exec(context.Background(), `
-										INSERT INTO transaction_logs (transaction_id, status, message, created_at)
-										VALUES ($1, 'FAILED', $2, NOW())
-									`, txID, "Item has been failed to sent.")
 										return
 									}
 								}(invoiceNumber, transactionID, customerNo, providerCode, skuCode, productName, skuName, skuCodeBackup1, skuCodeBackup2, prov)
@@ -2946,7 +2863,10 @@ func HandlePakaiLinkWebhook(deps *Dependencies) http.HandlerFunc {
 				gw, err := deps.PaymentManager.Get("PAKAILINK")
 				if err == nil {
 					if pakaiLinkGw, ok := gw.(*payment.PakaiLinkGateway); ok {
-						callbackURL := deps.Config.Payment.PakaiLink.CallbackURL
+						callbackURL := pakaiLinkGw.CallbackURL()
+						if callbackURL == "" {
+							callbackURL = deps.Config.Payment.PakaiLink.CallbackURL
+						}
 						if !pakaiLinkGw.VerifyCallbackSignature(callbackURL, body, timestamp, signature) {
 							log.Warn().
 								Str("invoice_number", invoiceNumber).
@@ -3073,6 +2993,8 @@ func HandlePakaiLinkWebhook(deps *Dependencies) http.HandlerFunc {
 		}
 
 		if result.RowsAffected() > 0 {
+			publishInvoiceStatus(deps, invoiceNumber)
+
 			// Update payment_data table
 			rawCallbackJSON, _ := json.Marshal(callback)
 			_, _ = deps.DB.Pool.Exec(ctx, `
@@ -3091,8 +3013,8 @@ func HandlePakaiLinkWebhook(deps *Dependencies) http.HandlerFunc {
 				VALUES ($1, 'PAYMENT', $2, NOW())
 			`, transactionID, paymentReceivedMessage)
 
-			// Process to provider
-			if deps.ProviderManager != nil {
+			// Process to provider, unless the order is held for review
+			if deps.ProviderManager != nil && !heldForReview(ctx, deps, transactionID) {
 				// Get provider code
 				var providerCode string
 				err = deps.DB.Pool.QueryRow(ctx, `
@@ -3103,19 +3025,8 @@ func HandlePakaiLinkWebhook(deps *Dependencies) http.HandlerFunc {
 					// Parse account inputs
 					var accountData map[string]interface{}
 					if err := json.Unmarshal([]byte(accountInputs), &accountData); err == nil {
-						// Extract customer number (userId + zoneId/serverId or phone number)
-						customerNo := ""
-						if userId, ok := accountData["userId"].(string); ok && userId != "" {
-							customerNo = userId
-							// Append zoneId or serverId if exists
-							if zoneId, ok := accountData["zoneId"].(string); ok && zoneId != "" {
-								customerNo = userId + zoneId
-							} else if serverId, ok := accountData["serverId"].(string); ok && serverId != "" {
-								customerNo = userId + serverId
-							}
-						} else if phone, ok := accountData["phoneNumber"].(string); ok && phone != "" {
-							customerNo = phone
-						}
+						// Extract customer number and customer data using the product templates
+						customerNo, customerData := resolveCustomerTarget(ctx, deps, transactionID, accountData)
 
 						if customerNo != "" && skuCode != "" {
 							// Get provider (convert to lowercase as providers are registered with lowercase names)
@@ -3123,9 +3034,10 @@ func HandlePakaiLinkWebhook(deps *Dependencies) http.HandlerFunc {
 							if err == nil {
 								// Create order request
 								orderReq := &provider.OrderRequest{
-									RefID:      invoiceNumber,
-									SKU:        skuCode,
-									CustomerNo: customerNo,
+									RefID:        invoiceNumber,
+									SKU:          skuCode,
+									CustomerNo:   customerNo,
+									CustomerData: customerData,
 								}
 
 								// Process order asynchronously
@@ -3140,6 +3052,11 @@ func HandlePakaiLinkWebhook(deps *Dependencies) http.HandlerFunc {
 										VALUES ($1, 'PROCESSING', $2, NOW())
 									`, txID, processingMessage)
 
+									// Multi-quantity orders are fulfilled unit by unit
+									if dispatchTransactionUnits(providerCtx, deps, prov, txID, sku, custNo, customerData) {
+										return
+									}
+
 									log.Info().
 										Str("invoice_number", invNum).
 										Str("provider", provCode).
@@ -3147,7 +3064,7 @@ func HandlePakaiLinkWebhook(deps *Dependencies) http.HandlerFunc {
 										Str("customer_no", custNo).
 										Msg("Processing PakaiLink transaction to provider")
 
-									orderResp, err := prov.CreateOrder(providerCtx, orderReq)
+									orderResp, err := createProviderOrder(providerCtx, deps, txID, prov, orderReq)
 
 									// Log ORDER_REQUEST
 									if orderResp != nil && len(orderResp.RawRequest) > 0 {
@@ -3181,20 +3098,14 @@ func HandlePakaiLinkWebhook(deps *Dependencies) http.HandlerFunc {
 										}
 										orderFailJSON, _ := json.Marshal([]interface{}{orderFailLog})
 
-										// Update transaction to failed
-										_, _ = deps.DB.Pool.Exec(context.Background(), `
+										// Update transaction to failed with its timeline entry
+										_ = settleOrder(context.Background(), deps, txID, "FAILED", `
 											UPDATE transactions
 											SET status = 'FAILED', 
 											    provider_logs = COALESCE(provider_logs, '[]'::jsonb) || $1::jsonb,
 											    updated_at = NOW()
 											WHERE id = $2
 										`, string(orderFailJSON), txID)
-
-										// Add timeline entry
-										_, _ = deps.DB.Pool.Exec(context.Background(), `
-											INSERT INTO transaction_logs (transaction_id, status, message, created_at)
-											VALUES ($1, $2, $3, NOW())
-										`, txID, "FAILED", "Item has been failed to sent.")
 										return
 									}
 
@@ -3238,7 +3149,8 @@ func HandlePakaiLinkWebhook(deps *Dependencies) http.HandlerFunc {
 										"sn":              orderResp.SN,
 									})
 
-									_, err = deps.DB.Pool.Exec(context.Background(), `
+									// Adds the timeline entry: Item has been successfully sent or failed to sent (only for final status)
+									err = settleOrder(context.Background(), deps, txID, updateStatus, `
 										UPDATE transactions
 										SET status = $1::transaction_status,
 											provider_ref_id = $2,
@@ -3256,19 +3168,6 @@ func HandlePakaiLinkWebhook(deps *Dependencies) http.HandlerFunc {
 											Str("invoice_number", invNum).
 											Msg("Failed to update transaction with provider response")
 									} else {
-										// Add timeline entry: Item has been successfully sent or failed to sent (only for final status)
-										if updateStatus == "SUCCESS" || updateStatus == "FAILED" {
-											var finalMessage string
-											if updateStatus == "SUCCESS" {
-												finalMessage = "Item has been successfully sent."
-											} else {
-												finalMessage = "Item has been failed to sent."
-											}
-											_, _ = deps.DB.Pool.Exec(context.Background(), `
-												INSERT INTO transaction_logs (transaction_id, status, message, created_at)
-												VALUES ($1, $2, $3, NOW())
-											`, txID, updateStatus, finalMessage)
-										}
 
 										log.Info().
 											Str("invoice_number", invNum).
@@ -3609,6 +3508,15 @@ func handleDepositPaymentCallback(
 		WHERE invoice_number = $4
 	`, paymentStatus, paidAt, string(rawCallbackJSON), invoiceNumber)
 
+	// Queue the customer email with the balance update
+	var depositNotification services.Notification
+	if depositStatus == "SUCCESS" {
+		depositNotification, err = deps.NotificationService.NotifyDepositTx(ctx, tx, depositID, services.EventDepositSuccess, nil)
+		if err != nil {
+			log.Warn().Err(err).Str("deposit_id", depositID).Msg("Failed to queue deposit notification")
+		}
+	}
+
 	// Commit transaction
 	if err = tx.Commit(ctx); err != nil {
 		log.Error().Err(err).Str("invoice", invoiceNumber).Msg("Failed to commit deposit callback transaction")
@@ -3620,6 +3528,8 @@ func handleDepositPaymentCallback(
 		return
 	}
 
+	publishInvoiceStatus(deps, invoiceNumber)
+
 	if depositStatus == "SUCCESS" {
 		log.Info().
 			Str("invoice", invoiceNumber).
@@ -3630,6 +3540,8 @@ func handleDepositPaymentCallback(
 			Int64("balance_before", currentBalance).
 			Int64("balance_after", newBalance).
 			Msg("Deposit payment callback processed successfully, balance updated")
+
+		deps.NotificationService.Notify(depositNotification)
 	} else {
 		log.Info().
 			Str("invoice", invoiceNumber).
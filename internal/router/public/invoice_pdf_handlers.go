@@ -0,0 +1,89 @@
+package public
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"seaply/internal/services"
+	"seaply/internal/utils"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// handleDownloadInvoicePDFImpl streams the PDF of a transaction or deposit invoice.
+// The link is signed by the invoice endpoints, so no session is needed to download it.
+func handleDownloadInvoicePDFImpl(deps *Dependencies, deposit bool) http.HandlerFunc {
+	endpoint := "/v2/invoices/pdf"
+	if deposit {
+		endpoint = "/v2/deposits/invoices/pdf"
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if deps.InvoiceService == nil {
+			utils.WriteErrorJSON(w, http.StatusServiceUnavailable, "INVOICE_PDF_UNAVAILABLE",
+				"Invoice PDFs are not available", "")
+			return
+		}
+
+		query := r.URL.Query()
+		invoiceNumber := query.Get("invoiceNumber")
+		if invoiceNumber == "" {
+			utils.WriteValidationErrorJSON(w, "Validation failed", map[string]string{
+				"invoiceNumber": "Invoice number parameter is required",
+			})
+			return
+		}
+
+		switch err := deps.InvoiceService.VerifySignedURL(invoiceNumber, query.Get("expires"), query.Get("signature")); {
+		case errors.Is(err, services.ErrInvoiceLinkExpired):
+			utils.WriteErrorJSON(w, http.StatusGone, "INVOICE_LINK_EXPIRED",
+				"Invoice link has expired", "Request the invoice again to get a new link")
+			return
+		case err != nil:
+			log.Warn().
+				Str("endpoint", endpoint).
+				Str("error_type", "INVALID_SIGNATURE").
+				Str("invoice_number", invoiceNumber).
+				Msg("Invoice PDF requested with an invalid signature")
+			utils.WriteErrorJSON(w, http.StatusForbidden, "INVALID_SIGNATURE",
+				"Invoice link is invalid", "")
+			return
+		}
+
+		if services.IsDepositInvoice(invoiceNumber) != deposit {
+			utils.WriteErrorJSON(w, http.StatusNotFound, "INVOICE_NOT_FOUND",
+				"Invoice not found", "The invoice number does not exist")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+		defer cancel()
+
+		data, doc, err := deps.InvoiceService.PDF(ctx, invoiceNumber)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				utils.WriteErrorJSON(w, http.StatusNotFound, "INVOICE_NOT_FOUND",
+					"Invoice not found", "The invoice number does not exist")
+				return
+			}
+			log.Error().
+				Err(err).
+				Str("endpoint", endpoint).
+				Str("invoice_number", invoiceNumber).
+				Msg("Failed to generate invoice PDF")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+doc.Filename()+`"`)
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Header().Set("Cache-Control", "private, no-store")
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+	}
+}
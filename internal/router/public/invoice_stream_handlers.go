@@ -0,0 +1,158 @@
+package public
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"seaply/internal/middleware"
+	"seaply/internal/services"
+	"seaply/internal/utils"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// Invoice stream timings
+const (
+	invoiceStreamHeartbeat   = 15 * time.Second
+	invoiceStreamMaxDuration = 30 * time.Minute // the browser reconnects on its own
+	invoiceStreamRetry       = 3 * time.Second
+)
+
+// handleInvoiceStreamImpl streams the payment and fulfilment status of an invoice as
+// Server-Sent Events. The first event is the current status; the stream ends once the
+// invoice reaches a final status. The token comes from the invoice endpoints, so
+// guests can subscribe without a session.
+func handleInvoiceStreamImpl(deps *Dependencies, deposit bool) http.HandlerFunc {
+	endpoint := "/v2/invoices/stream"
+	if deposit {
+		endpoint = "/v2/deposits/invoices/stream"
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if deps.InvoiceStream == nil {
+			utils.WriteErrorJSON(w, http.StatusServiceUnavailable, "INVOICE_STREAM_UNAVAILABLE",
+				"Invoice status streaming is not available", "")
+			return
+		}
+
+		query := r.URL.Query()
+		invoiceNumber := query.Get("invoiceNumber")
+		token := query.Get("token")
+		if invoiceNumber == "" || token == "" {
+			fields := map[string]string{}
+			if invoiceNumber == "" {
+				fields["invoiceNumber"] = "Invoice number parameter is required"
+			}
+			if token == "" {
+				fields["token"] = "Token parameter is required"
+			}
+			utils.WriteValidationErrorJSON(w, "Validation failed", fields)
+			return
+		}
+
+		switch err := deps.InvoiceStream.VerifyToken(invoiceNumber, token); {
+		case errors.Is(err, services.ErrInvoiceStreamTokenExpired):
+			utils.WriteErrorJSON(w, http.StatusUnauthorized, "STREAM_TOKEN_EXPIRED",
+				"Stream token has expired", "Request the invoice again to get a new token")
+			return
+		case err != nil:
+			log.Warn().
+				Str("endpoint", endpoint).
+				Str("error_type", "INVALID_TOKEN").
+				Str("invoice_number", invoiceNumber).
+				Msg("Invoice stream requested with an invalid token")
+			utils.WriteErrorJSON(w, http.StatusForbidden, "INVALID_TOKEN",
+				"Stream token is invalid", "")
+			return
+		}
+
+		if services.IsDepositInvoice(invoiceNumber) != deposit {
+			utils.WriteErrorJSON(w, http.StatusNotFound, "INVOICE_NOT_FOUND",
+				"Invoice not found", "The invoice number does not exist")
+			return
+		}
+
+		// Subscribe before reading the current status so a change in between isn't missed
+		events, unsubscribe := deps.InvoiceStream.Subscribe(invoiceNumber, middleware.ClientIP(r))
+		defer unsubscribe()
+
+		snapshotCtx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		current, err := deps.InvoiceStream.Snapshot(snapshotCtx, invoiceNumber)
+		cancel()
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				utils.WriteErrorJSON(w, http.StatusNotFound, "INVOICE_NOT_FOUND",
+					"Invoice not found", "The invoice number does not exist")
+				return
+			}
+			log.Error().Err(err).Str("endpoint", endpoint).Str("invoice_number", invoiceNumber).Msg("Failed to load invoice status")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		// The stream outlives the server write timeout
+		rc := http.NewResponseController(w)
+		_ = rc.SetWriteDeadline(time.Time{})
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "retry: %d\n\n", invoiceStreamRetry.Milliseconds())
+
+		send := func(event services.InvoiceStatusEvent) error {
+			data, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: status\ndata: %s\n\n", event.UpdatedAt.UnixMilli(), data); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+
+		if err := send(current); err != nil || current.Final() {
+			return
+		}
+
+		heartbeat := time.NewTicker(invoiceStreamHeartbeat)
+		defer heartbeat.Stop()
+		deadline := time.NewTimer(invoiceStreamMaxDuration)
+		defer deadline.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-deadline.C:
+				return
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+					return
+				}
+				if err := rc.Flush(); err != nil {
+					return
+				}
+			case event, ok := <-events:
+				// A newer stream of the client or the invoice took this one's place
+				if !ok {
+					return
+				}
+				// Events published before the snapshot was read are already included in it
+				if event.UpdatedAt.Before(current.UpdatedAt) {
+					continue
+				}
+				current = event
+				if err := send(event); err != nil || event.Final() {
+					return
+				}
+			}
+		}
+	}
+}
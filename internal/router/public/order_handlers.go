@@ -3,9 +3,9 @@ package public
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"net"
 	"net/http"
 	"net/url"
 	"strings"
@@ -13,7 +13,7 @@ import (
 
 	"seaply/internal/middleware"
 	"seaply/internal/payment"
-	"seaply/internal/provider"
+	"seaply/internal/services"
 	"seaply/internal/utils"
 
 	"github.com/jackc/pgx/v5"
@@ -45,6 +45,122 @@ func mustMarshalJSON(data interface{}) string {
 	return string(b)
 }
 
+// orderTimelineMessages are the timeline entries of the final order statuses
+var orderTimelineMessages = map[string]string{
+	"SUCCESS": "Item has been successfully sent.",
+	"FAILED":  "Item has been failed to sent.",
+}
+
+// settleOrder records the provider outcome of an order with the given UPDATE statement.
+// For a final status the timeline entry and the customer email are written in the same
+// database transaction, so the email can't be lost once the status is committed. The
+// change is then pushed to the invoice stream and the partner.
+func settleOrder(ctx context.Context, deps *Dependencies, transactionID, status, query string, args ...interface{}) error {
+	var notification services.Notification
+	err := pgx.BeginFunc(ctx, deps.DB.Pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, query, args...); err != nil {
+			return err
+		}
+
+		message, ok := orderTimelineMessages[status]
+		if !ok {
+			return nil
+		}
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO transaction_logs (transaction_id, status, message, created_at)
+			VALUES ($1, $2, $3, NOW())
+		`, transactionID, status, message); err != nil {
+			return err
+		}
+		notification = queueOrderNotification(ctx, deps, tx, transactionID, status)
+		return nil
+	})
+	if err != nil {
+		log.Error().Err(err).Str("transaction_id", transactionID).Str("status", status).Msg("Failed to settle order")
+		return err
+	}
+
+	notifyOrderStatus(deps, transactionID, status, notification)
+	return nil
+}
+
+// queueOrderNotification queues the customer email for a final order status in tx, the
+// transaction changing the status. The returned notification is for notifyOrderStatus.
+// The email is queued in a savepoint, so when it fails the status change still commits.
+func queueOrderNotification(ctx context.Context, deps *Dependencies, tx pgx.Tx, transactionID, status string) services.Notification {
+	event, ok := services.OrderEventForStatus(status)
+	if !ok {
+		return services.Notification{}
+	}
+
+	notification, err := deps.NotificationService.NotifyTransactionTx(ctx, tx, transactionID, event, nil)
+	if err != nil {
+		log.Warn().Err(err).Str("transaction_id", transactionID).Msg("Failed to queue order notification")
+	}
+	return notification
+}
+
+// notifyOrderStatus pushes an order status change to the invoice stream, queues the
+// partner callback for a final status and sends the customer notification queued with
+// the change on its remaining channels
+func notifyOrderStatus(deps *Dependencies, transactionID, status string, notification services.Notification) {
+	publishTransactionStatus(deps, transactionID)
+
+	if status == "SUCCESS" || status == "FAILED" {
+		notifyPartnerOrder(deps, transactionID, status)
+	}
+
+	deps.NotificationService.Notify(notification)
+}
+
+// publishInvoiceStatus pushes the current status of an invoice to the clients watching it
+// and emits the matching webhook events
+func publishInvoiceStatus(deps *Dependencies, invoiceNumber string) {
+	if deps.InvoiceStream == nil && deps.Webhooks == nil {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if deps.InvoiceStream != nil {
+			if err := deps.InvoiceStream.Publish(ctx, invoiceNumber); err != nil {
+				log.Warn().Err(err).Str("invoice_number", invoiceNumber).Msg("Failed to publish invoice status")
+			}
+		}
+		if deps.Webhooks != nil {
+			if err := deps.Webhooks.EmitInvoice(ctx, invoiceNumber); err != nil {
+				log.Warn().Err(err).Str("invoice_number", invoiceNumber).Msg("Failed to emit invoice webhooks")
+			}
+		}
+	}()
+}
+
+// publishTransactionStatus pushes the current status of a transaction to its invoice stream
+// and emits the matching webhook events
+func publishTransactionStatus(deps *Dependencies, transactionID string) {
+	if deps.InvoiceStream == nil && deps.Webhooks == nil {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if deps.InvoiceStream != nil {
+			if err := deps.InvoiceStream.PublishTransaction(ctx, transactionID); err != nil {
+				log.Warn().Err(err).Str("transaction_id", transactionID).Msg("Failed to publish invoice status")
+			}
+		}
+		if deps.Webhooks != nil {
+			if err := deps.Webhooks.EmitTransaction(ctx, transactionID); err != nil {
+				log.Warn().Err(err).Str("transaction_id", transactionID).Msg("Failed to emit transaction webhooks")
+			}
+		}
+	}()
+}
+
 // OrderInquiryRequest represents the request body for order inquiry
 type OrderInquiryRequest struct {
 	ProductCode string `json:"productCode" validate:"required"`
@@ -57,6 +173,9 @@ type OrderInquiryRequest struct {
 	PromoCode   string `json:"promoCode,omitempty"`
 	Email       string `json:"email,omitempty"`
 	PhoneNumber string `json:"phoneNumber,omitempty"`
+	// Account holds every product field input keyed by product_fields.key;
+	// userId, zoneId, serverId and phoneNumber above are merged into it
+	Account map[string]interface{} `json:"account,omitempty"`
 }
 
 // handleOrderInquiryImpl implements order inquiry with account validation
@@ -86,70 +205,47 @@ func HandleOrderInquiryImpl(deps *Dependencies) http.HandlerFunc {
 		ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
 		defer cancel()
 
-		// Get product info
-		var productCode, productName, productSlug, inquirySlug string
-		err := deps.DB.Pool.QueryRow(ctx, `
-			SELECT code, slug, title, COALESCE(inquiry_slug, '') as inquiry_slug
-			FROM products
-			WHERE code = $1 AND is_active = true
-		`, req.ProductCode).Scan(&productCode, &productSlug, &productName, &inquirySlug)
+		// All pricing is resolved for the region set by RegionValidator
+		region := middleware.GetRegionFromContext(r.Context())
+		if region == "" {
+			region = "ID"
+		}
 
+		currency, err := getRegionCurrency(ctx, deps.DB.Pool, region)
 		if err != nil {
-			if err == pgx.ErrNoRows {
-				utils.WriteErrorJSON(w, http.StatusNotFound, "PRODUCT_NOT_FOUND",
-					"Product not found", "")
-				return
-			}
-			utils.WriteInternalServerError(w)
+			writeRegionPricingError(w, err, region)
 			return
 		}
 
-		// Get SKU info
-		var skuCode, skuName string
-		var skuPrice int64
+		// Get product info (check region availability)
+		var productCode, productName, productSlug, inquirySlug string
 		err = deps.DB.Pool.QueryRow(ctx, `
-			SELECT s.code, s.name, sp.sell_price
-			FROM skus s
-			JOIN sku_pricing sp ON s.id = sp.sku_id
-			JOIN products p ON s.product_id = p.id
-			WHERE s.code = $1 AND p.code = $2 AND s.is_active = true AND sp.is_active = true
-			LIMIT 1
-		`, req.SKUCode, req.ProductCode).Scan(&skuCode, &skuName, &skuPrice)
+			SELECT p.code, p.slug, p.title, COALESCE(p.inquiry_slug, '') as inquiry_slug
+			FROM products p
+			JOIN product_regions pr ON p.id = pr.product_id
+			WHERE p.code = $1 AND p.is_active = true AND pr.region_code = $2
+		`, req.ProductCode, region).Scan(&productCode, &productSlug, &productName, &inquirySlug)
 
 		if err != nil {
 			if err == pgx.ErrNoRows {
-				utils.WriteErrorJSON(w, http.StatusNotFound, "SKU_NOT_FOUND",
-					"SKU not found", "")
+				utils.WriteErrorJSON(w, http.StatusNotFound, "PRODUCT_NOT_FOUND",
+					"Product not found or not available in your region", "")
 				return
 			}
 			utils.WriteInternalServerError(w)
 			return
 		}
 
-		// Check if product requires zone
-		var hasZoneField bool
-		deps.DB.Pool.QueryRow(ctx, `
-			SELECT EXISTS(
-				SELECT 1 FROM product_fields 
-				WHERE product_id = (SELECT id FROM products WHERE code = $1)
-				AND key IN ('zoneId', 'serverId')
-			)
-		`, req.ProductCode).Scan(&hasZoneField)
-
-		// Determine zone value (zoneId or serverId - serverId can be used as zoneId)
-		zoneValue := req.ZoneID
-		if zoneValue == "" {
-			zoneValue = req.ServerID
+		// Get SKU info with pricing for the region
+		sku, err := getSKURegionPrice(ctx, deps.DB.Pool, req.ProductCode, req.SKUCode, region)
+		if err == nil {
+			err = checkCurrency(currency, sku.Currency, "pricing")
 		}
-
-		// Validate zone if required
-		if hasZoneField && zoneValue == "" && req.UserID != "" {
-			utils.WriteValidationErrorJSON(w, "Validation failed", map[string]string{
-				"zoneId":   "Zone ID / Server ID is required for this product",
-				"serverId": "Zone ID / Server ID is required for this product",
-			})
+		if err != nil {
+			writeRegionPricingError(w, err, region)
 			return
 		}
+		skuCode, skuName, skuPrice := sku.Code, sku.Name, sku.SellPrice
 
 		// Validate email format if provided
 		if req.Email != "" && !utils.ValidateEmail(req.Email) {
@@ -217,8 +313,47 @@ func HandleOrderInquiryImpl(deps *Dependencies) http.HandlerFunc {
 			}
 		}
 
-		// Validate user ID or phone number
-		if req.UserID == "" && req.PhoneNumber == "" {
+		// Validate account inputs against the product field schema
+		inputs := normalizeAccountInputs(req.Account)
+		for key, value := range map[string]string{
+			"userId":      req.UserID,
+			"zoneId":      req.ZoneID,
+			"serverId":    req.ServerID,
+			"phoneNumber": req.PhoneNumber,
+		} {
+			if value = strings.TrimSpace(value); value != "" {
+				if _, ok := inputs[key]; !ok {
+					inputs[key] = value
+				}
+			}
+		}
+
+		fields, err := loadAccountFields(ctx, deps, req.ProductCode)
+		if err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
+		if len(fields) > 0 {
+			values, fieldErrors := validateAccountInputs(fields, inputs)
+			if len(fieldErrors) > 0 {
+				utils.WriteValidationErrorJSON(w, "Validation failed", fieldErrors)
+				return
+			}
+			inputs = values
+		}
+		req.UserID = inputs["userId"]
+		if req.PhoneNumber == "" {
+			req.PhoneNumber = inputs["phoneNumber"]
+		}
+
+		// Determine zone value (zoneId or serverId - serverId can be used as zoneId)
+		zoneValue := inputs["zoneId"]
+		if zoneValue == "" {
+			zoneValue = inputs["serverId"]
+		}
+
+		// Validate user ID or phone number for products without a field schema
+		if len(fields) == 0 && req.UserID == "" && req.PhoneNumber == "" {
 			utils.WriteValidationErrorJSON(w, "Validation failed", map[string]string{
 				"userId":      "User ID or phone number is required",
 				"phoneNumber": "User ID or phone number is required",
@@ -344,9 +479,15 @@ func HandleOrderInquiryImpl(deps *Dependencies) http.HandlerFunc {
 		if quantity <= 0 {
 			quantity = 1
 		}
+		if quantity > sku.MaxQuantity {
+			utils.WriteValidationErrorJSON(w, "Validation failed", map[string]string{
+				"quantity": fmt.Sprintf("Maximum quantity for this item is %d", sku.MaxQuantity),
+			})
+			return
+		}
 
-		// sell_price in database is stored in rupiah (e.g., 20000 = 20000 IDR)
-		// All calculations are done in rupiah
+		// sell_price in database is stored in whole units of the region currency
+		// (e.g., 20000 = 20000 IDR), all calculations are done in that currency
 		subtotal := skuPrice * int64(quantity)
 		discount := int64(0)
 		paymentFee := int64(0)
@@ -400,6 +541,19 @@ func HandleOrderInquiryImpl(deps *Dependencies) http.HandlerFunc {
 				return
 			}
 
+			// Check if promo is applicable to the request region
+			var regionCount int
+			err = deps.DB.Pool.QueryRow(ctx, `
+				SELECT COUNT(*) FROM promo_regions WHERE promo_id = $1 AND region_code = $2
+			`, promoID, region).Scan(&regionCount)
+
+			if err != nil || regionCount == 0 {
+				utils.WriteValidationErrorJSON(w, "Validation failed", map[string]string{
+					"promoCode": "Promo code is not applicable to your region",
+				})
+				return
+			}
+
 			// Check if promo is applicable to this product
 			// If promo_products is empty/null, promo can be used for all products
 			// If promo_products has entries, product must be in the list
@@ -469,6 +623,7 @@ func HandleOrderInquiryImpl(deps *Dependencies) http.HandlerFunc {
 		}
 
 		// Validate and calculate payment fee if payment code provided
+		paymentChannelName := req.PaymentCode
 		if req.PaymentCode != "" {
 			var paymentChannelID, paymentName string
 			var feeAmount, feePercentage float64
@@ -498,6 +653,24 @@ func HandleOrderInquiryImpl(deps *Dependencies) http.HandlerFunc {
 				return
 			}
 
+			// Validate payment channel is offered in the region and settles in the order currency
+			var channelCurrency string
+			channelCurrency, err = getChannelCurrency(ctx, deps.DB.Pool, req.PaymentCode, region)
+			if err == nil {
+				err = checkCurrency(currency, channelCurrency, "payment channel")
+			}
+			if err != nil {
+				if errors.Is(err, errChannelNotInRegion) {
+					utils.WriteValidationErrorJSON(w, "Validation failed", map[string]string{
+						"paymentCode": "Payment method is not available in your region",
+					})
+					return
+				}
+				writeRegionPricingError(w, err, region)
+				return
+			}
+			paymentChannelName = paymentName
+
 			// Calculate payment fee (all in rupiah)
 			// feeAmount in database is in rupiah (e.g., 4000 = 4000 IDR)
 			// feePercentage is in decimal format (e.g., 0.7 for 0.7%)
@@ -519,10 +692,13 @@ func HandleOrderInquiryImpl(deps *Dependencies) http.HandlerFunc {
 			"skuCode":     skuCode,
 			"paymentCode": req.PaymentCode,
 			"quantity":    quantity,
+			"region":      region,
+			"currency":    currency,
 			"accountData": map[string]interface{}{
 				"userId":   req.UserID,
 				"zoneId":   zoneValue,
 				"nickname": accountNickname,
+				"inputs":   inputs,
 			},
 			"pricing": map[string]interface{}{
 				"subtotal":   subtotal,   // Store in rupiah for token
@@ -571,11 +747,12 @@ func HandleOrderInquiryImpl(deps *Dependencies) http.HandlerFunc {
 					"nickname": accountNickname,
 					"userId":   req.UserID,
 					"zoneId":   zoneValue,
+					"inputs":   inputs,
 				},
 				"payment": map[string]interface{}{
 					"code":     req.PaymentCode,
-					"name":     req.PaymentCode, // Should get from DB
-					"currency": "IDR",           // Should get from region
+					"name":     paymentChannelName,
+					"currency": currency,
 				},
 				"pricing": map[string]interface{}{
 					"subtotal":   float64(subtotal),   // Already in rupiah
@@ -704,6 +881,7 @@ func HandleCreateOrderImpl(deps *Dependencies) http.HandlerFunc {
 				Str("endpoint", "/v2/orders").
 				Str("error_type", "DB_TRANSACTION_ERROR").
 				Msg("Failed to begin database transaction")
+			middleware.IdempotentRetryable(r.Context())
 			utils.WriteInternalServerError(w)
 			return
 		}
@@ -715,6 +893,32 @@ func HandleCreateOrderImpl(deps *Dependencies) http.HandlerFunc {
 			region = "ID" // Default to Indonesia
 		}
 
+		// The order must be placed in the region it was quoted for
+		if tokenRegion, _ := tokenData["region"].(string); tokenRegion != "" && tokenRegion != region {
+			log.Warn().
+				Str("endpoint", "/v2/orders").
+				Str("error_type", "REGION_MISMATCH").
+				Str("token_region", tokenRegion).
+				Str("region", region).
+				Msg("Validation token was issued for another region")
+			utils.WriteErrorJSON(w, http.StatusBadRequest, "REGION_MISMATCH",
+				"Validation token was issued for another region", "Please create a new order inquiry")
+			return
+		}
+
+		// Order currency follows the region
+		currency, err := getRegionCurrency(ctx, tx, region)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("endpoint", "/v2/orders").
+				Str("error_type", "REGION_CURRENCY_ERROR").
+				Str("region", region).
+				Msg("Failed to resolve region currency")
+			writeRegionPricingError(w, err, region)
+			return
+		}
+
 		// Fetch product details (check region availability)
 		var productID string
 		var productName, productSlug string
@@ -748,29 +952,30 @@ func HandleCreateOrderImpl(deps *Dependencies) http.HandlerFunc {
 			Str("region", region).
 			Msg("Product found successfully")
 
-		// Fetch SKU details with pricing and provider
-		var skuID, skuName, providerID string
-		var buyPrice, sellPrice int64
-		var skuImage *string
-		err = tx.QueryRow(ctx, `
-			SELECT s.id, s.name, s.provider_id, sp.buy_price, sp.sell_price, s.image
-			FROM skus s
-			JOIN sku_pricing sp ON s.id = sp.sku_id
-			WHERE s.code = $1 AND s.product_id = $2 AND s.is_active = true AND sp.is_active = true
-			LIMIT 1
-		`, skuCode, productID).Scan(&skuID, &skuName, &providerID, &buyPrice, &sellPrice, &skuImage)
-
+		// Fetch SKU details with pricing for the region and provider
+		sku, err := getSKURegionPrice(ctx, tx, productCode, skuCode, region)
+		if err == nil {
+			err = checkCurrency(currency, sku.Currency, "pricing")
+		}
 		if err != nil {
 			log.Error().
 				Err(err).
 				Str("endpoint", "/v2/orders").
-				Str("error_type", "SKU_NOT_FOUND").
+				Str("error_type", "SKU_PRICING_ERROR").
 				Str("sku_code", skuCode).
 				Str("product_id", productID).
-				Bool("is_no_rows", err == pgx.ErrNoRows).
-				Msg("Failed to fetch SKU from database")
-			utils.WriteErrorJSON(w, http.StatusNotFound, "SKU_NOT_FOUND",
-				"SKU not found", "")
+				Str("region", region).
+				Msg("Failed to resolve SKU pricing for region")
+			writeRegionPricingError(w, err, region)
+			return
+		}
+		skuID, skuName, providerID, skuImage := sku.ID, sku.Name, sku.ProviderID, sku.Image
+		buyPrice, sellPrice := sku.BuyPrice, sku.SellPrice
+
+		if quantity < 1 || quantity > sku.MaxQuantity {
+			utils.WriteValidationErrorJSON(w, "Validation failed", map[string]string{
+				"quantity": fmt.Sprintf("Maximum quantity for this item is %d", sku.MaxQuantity),
+			})
 			return
 		}
 
@@ -816,6 +1021,23 @@ func HandleCreateOrderImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		// Payment channel must be offered in the region and settle in the order currency
+		channelCurrency, err := getChannelCurrency(ctx, tx, paymentCode, region)
+		if err == nil {
+			err = checkCurrency(currency, channelCurrency, "payment channel")
+		}
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("endpoint", "/v2/orders").
+				Str("error_type", "PAYMENT_CHANNEL_REGION_ERROR").
+				Str("payment_code", paymentCode).
+				Str("region", region).
+				Msg("Payment channel is not usable for region")
+			writeRegionPricingError(w, err, region)
+			return
+		}
+
 		// Determine gateway based on payment channel code (hardcoded routing)
 		gatewayName := getGatewayForChannel(paymentCode)
 
@@ -851,7 +1073,8 @@ func HandleCreateOrderImpl(deps *Dependencies) http.HandlerFunc {
 				SELECT id, promo_percentage, promo_flat, max_promo_amount
 				FROM promos
 				WHERE code = $1 AND is_active = true AND expired_at > NOW()
-			`, promoCode).Scan(&dbPromoID, &promoPercentage, &promoFlat, &maxPromoAmount)
+				  AND EXISTS (SELECT 1 FROM promo_regions WHERE promo_id = promos.id AND region_code = $2)
+			`, promoCode, region).Scan(&dbPromoID, &promoPercentage, &promoFlat, &maxPromoAmount)
 
 			if err == nil {
 				promoID = &dbPromoID
@@ -875,6 +1098,26 @@ func HandleCreateOrderImpl(deps *Dependencies) http.HandlerFunc {
 			userID = &authUserID
 		}
 
+		// Score the checkout for fraud; risky ones need a login or MFA, are held or refused
+		gameID := productCode + ":" + userId
+		if zoneId != "" {
+			gameID += ":" + zoneId
+		}
+		riskAssessment, ok := assessCheckoutRisk(w, r, deps, services.RiskCheck{
+			Kind:      services.RiskKindOrder,
+			UserID:    middleware.GetUserIDFromContext(r.Context()),
+			IPAddress: ipAddress,
+			DeviceID:  r.Header.Get("X-Device-ID"),
+			GameID:    gameID,
+			Region:    region,
+			Amount:    totalAmount,
+			Currency:  currency,
+		})
+		if !ok {
+			return
+		}
+		heldForRisk := riskAssessment != nil && riskAssessment.Action == services.RiskHold
+
 		// For BALANCE payment, check user balance
 		if paymentCode == "BALANCE" {
 			if userID == nil {
@@ -888,10 +1131,9 @@ func HandleCreateOrderImpl(deps *Dependencies) http.HandlerFunc {
 				return
 			}
 
-			var balanceIDR int64
-			err = tx.QueryRow(ctx, `
-				SELECT balance_idr FROM users WHERE id = $1
-			`, *userID).Scan(&balanceIDR)
+			// Balance is checked in the wallet matching the order currency
+			var balance int64
+			err = tx.QueryRow(ctx, "SELECT "+balanceColumnForCurrency(currency)+" FROM users WHERE id = $1", *userID).Scan(&balance)
 
 			if err != nil {
 				log.Error().
@@ -906,12 +1148,13 @@ func HandleCreateOrderImpl(deps *Dependencies) http.HandlerFunc {
 				return
 			}
 
-			if balanceIDR < totalAmount {
+			if balance < totalAmount {
 				log.Warn().
 					Str("endpoint", "/v2/orders").
 					Str("error_type", "INSUFFICIENT_BALANCE").
 					Str("user_id", *userID).
-					Int64("balance_idr", balanceIDR).
+					Str("currency", currency).
+					Int64("balance", balance).
 					Int64("total_amount", totalAmount).
 					Msg("User has insufficient balance")
 				utils.WriteErrorJSON(w, http.StatusBadRequest, "INSUFFICIENT_BALANCE",
@@ -940,30 +1183,18 @@ func HandleCreateOrderImpl(deps *Dependencies) http.HandlerFunc {
 		}
 
 		// Prepare account inputs as JSONB
-		accountInputs := map[string]interface{}{
-			"userId": userId,
+		accountInputs := map[string]interface{}{}
+		if fieldInputs, ok := accountData["inputs"].(map[string]interface{}); ok {
+			for key, value := range fieldInputs {
+				accountInputs[key] = value
+			}
 		}
+		accountInputs["userId"] = userId
 		if zoneId != "" {
 			accountInputs["zoneId"] = zoneId
 		}
 		accountInputsJSON, _ := json.Marshal(accountInputs)
 
-		// Get currency from region (region already set above)
-		currency := "IDR"
-		// Map region to currency if needed
-		switch region {
-		case "MY":
-			currency = "MYR"
-		case "SG":
-			currency = "SGD"
-		case "PH":
-			currency = "PHP"
-		case "TH":
-			currency = "THB"
-		default:
-			currency = "IDR"
-		}
-
 		// Create transaction record
 		var transactionID string
 		var accountNickname *string
@@ -1016,6 +1247,7 @@ func HandleCreateOrderImpl(deps *Dependencies) http.HandlerFunc {
 				Str("sku_id", skuID).
 				Str("payment_channel_id", paymentChannelID).
 				Msg("Failed to insert transaction into database")
+			middleware.IdempotentRetryable(r.Context())
 			utils.WriteInternalServerError(w)
 			return
 		}
@@ -1042,6 +1274,25 @@ func HandleCreateOrderImpl(deps *Dependencies) http.HandlerFunc {
 			// Non-fatal, continue
 		}
 
+		// Held orders are paid as usual but only sent to the provider once released
+		if heldForRisk {
+			if err := deps.Risk.Hold(ctx, tx, riskAssessment, transactionID); err != nil {
+				log.Error().
+					Err(err).
+					Str("endpoint", "/v2/orders").
+					Str("error_type", "RISK_HOLD_ERROR").
+					Str("transaction_id", transactionID).
+					Msg("Failed to hold order for risk review")
+				middleware.IdempotentRetryable(r.Context())
+				utils.WriteInternalServerError(w)
+				return
+			}
+			_, _ = tx.Exec(ctx, `
+				INSERT INTO transaction_logs (transaction_id, status, message, created_at)
+				VALUES ($1, 'REVIEW', $2, NOW())
+			`, transactionID, "Order is under review and will be processed once approved.")
+		}
+
 		// Process payment based on payment channel type
 		var paymentData map[string]interface{}
 		var gatewayRefID *string
@@ -1051,7 +1302,8 @@ func HandleCreateOrderImpl(deps *Dependencies) http.HandlerFunc {
 		if paymentCode == "BALANCE" {
 			// Get balance before deduction for mutation
 			var balanceBefore int64
-			err = tx.QueryRow(ctx, `SELECT balance_idr FROM users WHERE id = $1`, *userID).Scan(&balanceBefore)
+			balanceColumn := balanceColumnForCurrency(currency)
+			err = tx.QueryRow(ctx, "SELECT "+balanceColumn+" FROM users WHERE id = $1", *userID).Scan(&balanceBefore)
 			if err != nil {
 				log.Error().
 					Err(err).
@@ -1060,18 +1312,25 @@ func HandleCreateOrderImpl(deps *Dependencies) http.HandlerFunc {
 					Str("transaction_id", transactionID).
 					Str("user_id", *userID).
 					Msg("Failed to get user balance before deduction")
+				middleware.IdempotentRetryable(r.Context())
 				utils.WriteInternalServerError(w)
 				return
 			}
 
+			// total_spent_idr only accumulates IDR spending
+			var spentIDR int64
+			if currency == "IDR" {
+				spentIDR = totalAmount
+			}
+
 			// For balance payment, deduct immediately
 			_, err = tx.Exec(ctx, `
 				UPDATE users
-				SET balance_idr = balance_idr - $1,
-					total_spent_idr = total_spent_idr + $1,
+				SET `+balanceColumn+` = `+balanceColumn+` - $1,
+					total_spent_idr = total_spent_idr + $2,
 					updated_at = NOW()
-				WHERE id = $2
-			`, totalAmount, *userID)
+				WHERE id = $3
+			`, totalAmount, spentIDR, *userID)
 
 			if err != nil {
 				log.Error().
@@ -1082,6 +1341,7 @@ func HandleCreateOrderImpl(deps *Dependencies) http.HandlerFunc {
 					Str("user_id", *userID).
 					Int64("total_amount", totalAmount).
 					Msg("Failed to deduct user balance")
+				middleware.IdempotentRetryable(r.Context())
 				utils.WriteInternalServerError(w)
 				return
 			}
@@ -1107,6 +1367,7 @@ func HandleCreateOrderImpl(deps *Dependencies) http.HandlerFunc {
 					Str("error_type", "TRANSACTION_UPDATE_ERROR").
 					Str("transaction_id", transactionID).
 					Msg("Failed to update transaction status to PROCESSING")
+				middleware.IdempotentRetryable(r.Context())
 				utils.WriteInternalServerError(w)
 				return
 			}
@@ -1180,180 +1441,15 @@ func HandleCreateOrderImpl(deps *Dependencies) http.HandlerFunc {
 			}
 
 			// For balance payment, process to provider immediately (after commit)
-			// Store the needed data for provider processing
-			// Build customer_no: userId + zoneId/serverId (if exists)
-			customerNo := userId
-			if zoneId != "" {
-				customerNo = userId + zoneId
-			}
-			balanceProviderData := map[string]string{
-				"transactionID": transactionID,
-				"invoiceNumber": invoiceNumber,
-				"skuCode":       skuCode,
-				"providerID":    providerID,
-				"customerNo":    customerNo,
-				"productName":   productName,
-				"skuName":       skuName,
-			}
-			defer func() {
-				if deps.ProviderManager == nil {
-					return
-				}
-
-				// Get provider code
-				var pCode string
-				err := deps.DB.Pool.QueryRow(context.Background(), `
-					SELECT code FROM providers WHERE id = $1
-				`, balanceProviderData["providerID"]).Scan(&pCode)
-				if err != nil {
-					return
-				}
-
-				prov, err := deps.ProviderManager.Get(strings.ToLower(pCode))
-				if err != nil {
-					return
-				}
-
-				// Get provider SKU code
-				var providerSKUCode string
-				err = deps.DB.Pool.QueryRow(context.Background(), `
-					SELECT provider_sku_code FROM skus WHERE code = $1
-				`, balanceProviderData["skuCode"]).Scan(&providerSKUCode)
-				if err != nil || providerSKUCode == "" {
-					providerSKUCode = balanceProviderData["skuCode"]
-				}
-
-				// Process to provider asynchronously
-				go func() {
-					provCtx, provCancel := context.WithTimeout(context.Background(), 30*time.Second)
-					defer provCancel()
-
-					txID := balanceProviderData["transactionID"]
-					invNum := balanceProviderData["invoiceNumber"]
-					custNo := balanceProviderData["customerNo"]
-					prodName := balanceProviderData["productName"]
-					skuName := balanceProviderData["skuName"]
-
-					log.Info().
-						Str("invoice_number", invNum).
-						Str("provider", pCode).
-						Str("sku", providerSKUCode).
-						Str("customer_no", custNo).
-						Msg("Processing balance transaction to provider")
-
-					// Add timeline entry: Processing order
-					_, _ = deps.DB.Pool.Exec(provCtx, `
-						INSERT INTO transaction_logs (transaction_id, status, message, created_at)
-						VALUES ($1, 'PROCESSING', $2, NOW())
-					`, txID, fmt.Sprintf("Processing order %s %s", prodName, skuName))
-
-					orderReq := &provider.OrderRequest{
-						RefID:      invNum,
-						SKU:        providerSKUCode,
-						CustomerNo: custNo,
-					}
-
-					orderResp, err := prov.CreateOrder(provCtx, orderReq)
-
-					// Log the raw request from provider response
-					if orderResp != nil && len(orderResp.RawRequest) > 0 {
-						var rawReqData interface{}
-						json.Unmarshal(orderResp.RawRequest, &rawReqData)
-						providerReqLog := createLogEntry("ORDER_REQUEST", rawReqData)
-						providerReqJSON, _ := json.Marshal([]interface{}{providerReqLog})
-						_, _ = deps.DB.Pool.Exec(provCtx, `
-							UPDATE transactions
-							SET provider_logs = COALESCE(provider_logs, '[]'::jsonb) || $1::jsonb, updated_at = NOW()
-							WHERE id = $2
-						`, string(providerReqJSON), txID)
-					}
-					if err != nil {
-						log.Error().Err(err).
-							Str("invoice_number", invNum).
-							Str("provider", pCode).
-							Msg("Failed to process balance transaction to provider")
-
-						providerFailLog := createLogEntry("ORDER_FAILED", map[string]interface{}{
-							"error": err.Error(),
-						})
-						_, _ = deps.DB.Pool.Exec(context.Background(), `
-							UPDATE transactions
-							SET status = 'FAILED',
-							    provider_logs = COALESCE(provider_logs, '[]'::jsonb) || $1::jsonb,
-							    updated_at = NOW()
-							WHERE id = $2
-						`, mustMarshalJSON([]interface{}{providerFailLog}), txID)
-
-						_, _ = deps.DB.Pool.Exec(context.Background(), `
-							INSERT INTO transaction_logs (transaction_id, status, message, created_at)
-							VALUES ($1, 'FAILED', $2, NOW())
-						`, txID, "Item has been failed to sent.")
-						return
-					}
-
-					var updateStatus string
-					switch orderResp.Status {
-					case "SUCCESS":
-						updateStatus = "SUCCESS"
-					case "FAILED":
-						updateStatus = "FAILED"
-					default:
-						updateStatus = "PROCESSING"
-					}
-
-					// Use raw response from provider
-					var rawRespData interface{}
-					if len(orderResp.RawResponse) > 0 {
-						json.Unmarshal(orderResp.RawResponse, &rawRespData)
-					} else {
-						rawRespData = map[string]interface{}{
-							"ref_id":          orderResp.RefID,
-							"provider_ref_id": orderResp.ProviderRefID,
-							"status":          orderResp.Status,
-							"message":         orderResp.Message,
-							"sn":              orderResp.SN,
-						}
-					}
-					providerRespJSON, _ := json.Marshal(rawRespData)
-
-					providerRespLog := createLogEntry("ORDER_RESPONSE", rawRespData)
-
-					_, err = deps.DB.Pool.Exec(context.Background(), `
-						UPDATE transactions
-						SET status = $1::transaction_status,
-						    provider_ref_id = $2,
-						    provider_serial_number = $3,
-						    provider_response = $4,
-						    provider_logs = COALESCE(provider_logs, '[]'::jsonb) || $5::jsonb,
-						    completed_at = CASE WHEN $1::text = 'SUCCESS' THEN NOW() ELSE completed_at END,
-						    updated_at = NOW()
-						WHERE id = $6
-					`, updateStatus, orderResp.ProviderRefID, orderResp.SN, string(providerRespJSON), mustMarshalJSON([]interface{}{providerRespLog}), txID)
-
-					if err == nil {
-						// Add timeline entry: Item has been successfully sent or failed to sent (only for final status)
-						if updateStatus == "SUCCESS" || updateStatus == "FAILED" {
-							var finalMessage string
-							if updateStatus == "SUCCESS" {
-								finalMessage = "Item has been successfully sent."
-							} else {
-								finalMessage = "Item has been failed to sent."
-							}
-							_, _ = deps.DB.Pool.Exec(context.Background(), `
-								INSERT INTO transaction_logs (transaction_id, status, message, created_at)
-								VALUES ($1, $2, $3, NOW())
-							`, txID, updateStatus, finalMessage)
-						}
-
-						log.Info().
-							Str("invoice_number", invNum).
-							Str("provider", pCode).
-							Str("status", updateStatus).
-							Str("serial_number", orderResp.SN).
-							Msg("Balance transaction processed to provider successfully")
-					}
-				}()
-			}()
+			defer fulfillPaidOrder(deps, paidOrder{
+				TransactionID: transactionID,
+				InvoiceNumber: invoiceNumber,
+				ProviderID:    providerID,
+				SKUCode:       skuCode,
+				ProductName:   productName,
+				SKUName:       skuName,
+				AccountInputs: accountInputs,
+			})
 
 		} else {
 			// For external payment gateways, create payment request
@@ -1370,6 +1466,7 @@ func HandleCreateOrderImpl(deps *Dependencies) http.HandlerFunc {
 					Str("payment_code", paymentCode).
 					Msg("Payment manager not configured")
 				tx.Rollback(ctx)
+				middleware.IdempotentRetryable(r.Context())
 				utils.WriteErrorJSON(w, http.StatusServiceUnavailable, "PAYMENT_GATEWAY_UNAVAILABLE",
 					"Payment gateway is not available", "Please try again later or use a different payment method")
 				return
@@ -1489,6 +1586,7 @@ func HandleCreateOrderImpl(deps *Dependencies) http.HandlerFunc {
 					Str("invoice_number", invoiceNumber).
 					Msg("Payment gateway call failed")
 				tx.Rollback(ctx)
+				middleware.IdempotentRetryable(r.Context())
 				utils.WriteErrorJSON(w, http.StatusBadGateway, "PAYMENT_GATEWAY_ERROR",
 					"Failed to create payment", paymentErr.Error())
 				return
@@ -1616,10 +1714,15 @@ func HandleCreateOrderImpl(deps *Dependencies) http.HandlerFunc {
 
 		// Commit transaction
 		if err = tx.Commit(ctx); err != nil {
+			middleware.IdempotentRetryable(r.Context())
 			utils.WriteInternalServerError(w)
 			return
 		}
 
+		if heldForRisk {
+			deps.Risk.NotifyHold(riskAssessment, invoiceNumber)
+		}
+
 		// Fetch timeline entries (after commit, use connection pool)
 		var timeline []map[string]interface{}
 		timelineRows, err := deps.DB.Pool.Query(ctx, `
@@ -1660,6 +1763,12 @@ func HandleCreateOrderImpl(deps *Dependencies) http.HandlerFunc {
 			time.Now(), expiredAt,
 			timeline,
 		)
+		if heldForRisk {
+			response["review"] = map[string]interface{}{
+				"status":  services.RiskReviewPending,
+				"message": "Your order is under review and will be processed once approved",
+			}
+		}
 
 		utils.WriteSuccessJSON(w, response)
 	}
@@ -1718,40 +1827,10 @@ func mapGatewayResponseToPaymentData(resp *payment.PaymentResponse, expiredAt ti
 	return data
 }
 
-// extractIPAddress extracts IP address from request, removing port if present
+// extractIPAddress returns the client IP resolved by the RealIP middleware from
+// trusted proxies only, so forwarding headers sent by the client are ignored
 func extractIPAddress(r *http.Request) string {
-	// Check X-Forwarded-For header first
-	xff := r.Header.Get("X-Forwarded-For")
-	if xff != "" {
-		// X-Forwarded-For can contain multiple IPs, take the first one
-		ips := strings.Split(xff, ",")
-		if len(ips) > 0 {
-			ip := strings.TrimSpace(ips[0])
-			// Remove port if present
-			if host, _, err := net.SplitHostPort(ip); err == nil {
-				return host
-			}
-			return ip
-		}
-	}
-
-	// Check X-Real-IP header
-	xri := r.Header.Get("X-Real-IP")
-	if xri != "" {
-		// Remove port if present
-		if host, _, err := net.SplitHostPort(xri); err == nil {
-			return host
-		}
-		return xri
-	}
-
-	// Fall back to RemoteAddr
-	// RemoteAddr is in format "IP:port" or "[IPv6]:port"
-	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
-		return host
-	}
-	// If SplitHostPort fails, return as is (shouldn't happen normally)
-	return r.RemoteAddr
+	return middleware.ClientIP(r)
 }
 
 // buildOrderResponse builds the order response object (same format as GET /invoices)
@@ -0,0 +1,647 @@
+package public
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"time"
+
+	"seaply/internal/middleware"
+	"seaply/internal/services"
+	"seaply/internal/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rs/zerolog/log"
+)
+
+type partnerContextKey struct{}
+
+// maxPartnerBodySize caps the body of a partner request, which is read whole to verify its signature
+const maxPartnerBodySize = 64 << 10
+
+var partnerRefIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,100}$`)
+
+// partnerFromContext returns the partner authenticated by partnerAuth
+func partnerFromContext(ctx context.Context) *services.Partner {
+	partner, _ := ctx.Value(partnerContextKey{}).(*services.Partner)
+	return partner
+}
+
+// partnerAuth verifies the signature of an H2H request and rate limits it per API key
+func partnerAuth(deps *Dependencies) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if deps.PartnerService == nil {
+				utils.WriteErrorJSON(w, http.StatusServiceUnavailable, "PARTNER_API_UNAVAILABLE",
+					"Partner API is not available", "")
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxPartnerBodySize+1))
+			if err != nil || len(body) > maxPartnerBodySize {
+				utils.WriteBadRequestError(w, "Invalid request body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			ip := middleware.ClientIP(r)
+
+			ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+			partner, err := deps.PartnerService.Authenticate(ctx, r, body, ip)
+			cancel()
+			if err != nil {
+				writePartnerAuthError(w, r, err, ip)
+				return
+			}
+
+			ctx = context.WithValue(r.Context(), partnerContextKey{}, partner)
+			ctx = middleware.WithRateLimitSubject(ctx, "partner:"+partner.KeyID)
+			ctx = middleware.WithRateLimitTier(ctx, middleware.RateLimitTierPartner)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func writePartnerAuthError(w http.ResponseWriter, r *http.Request, err error, ip string) {
+	logEvent := log.Warn().
+		Str("endpoint", r.URL.Path).
+		Str("api_key", r.Header.Get(services.PartnerKeyHeader)).
+		Str("ip", ip)
+
+	switch {
+	case errors.Is(err, services.ErrPartnerKeyInvalid):
+		logEvent.Str("error_type", "INVALID_API_KEY").Msg("Partner request with an invalid API key")
+		utils.WriteErrorJSON(w, http.StatusUnauthorized, "INVALID_API_KEY",
+			"API key is invalid or inactive", "")
+	case errors.Is(err, services.ErrPartnerTimestampInvalid):
+		logEvent.Str("error_type", "INVALID_TIMESTAMP").Msg("Partner request outside the timestamp window")
+		utils.WriteErrorJSON(w, http.StatusUnauthorized, "INVALID_TIMESTAMP",
+			"Request timestamp is invalid or expired",
+			fmt.Sprintf("%s must be a Unix timestamp within %s of the server time",
+				services.PartnerTimestampHeader, services.PartnerTimestampTolerance))
+	case errors.Is(err, services.ErrPartnerNonceInvalid):
+		logEvent.Str("error_type", "INVALID_NONCE").Msg("Partner request with a reused or invalid nonce")
+		utils.WriteErrorJSON(w, http.StatusUnauthorized, "INVALID_NONCE",
+			"Request nonce is invalid or has already been used",
+			services.PartnerNonceHeader+" must be a unique value of 8 to 64 characters")
+	case errors.Is(err, services.ErrPartnerSignatureInvalid):
+		logEvent.Str("error_type", "INVALID_SIGNATURE").Msg("Partner request with an invalid signature")
+		utils.WriteErrorJSON(w, http.StatusUnauthorized, "INVALID_SIGNATURE",
+			"Request signature is invalid", "")
+	case errors.Is(err, services.ErrPartnerIPNotAllowed):
+		logEvent.Str("error_type", "IP_NOT_ALLOWED").Msg("Partner request from an IP outside the whitelist")
+		utils.WriteErrorJSON(w, http.StatusForbidden, "IP_NOT_ALLOWED",
+			"IP address is not allowed for this API key", "")
+	default:
+		log.Error().Err(err).Str("endpoint", r.URL.Path).Msg("Failed to authenticate partner request")
+		utils.WriteInternalServerError(w)
+	}
+}
+
+// handlePartnerBalanceImpl returns the wallet balances of the partner account
+func handlePartnerBalanceImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		partner := partnerFromContext(r.Context())
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		var idr, myr, php, sgd, thb int64
+		err := deps.DB.Pool.QueryRow(ctx, `
+			SELECT balance_idr, balance_myr, balance_php, balance_sgd, balance_thb
+			FROM users WHERE id = $1
+		`, partner.UserID).Scan(&idr, &myr, &php, &sgd, &thb)
+		if err != nil {
+			log.Error().Err(err).Str("endpoint", "/v2/h2h/balance").Str("user_id", partner.UserID).Msg("Failed to get partner balance")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"balance": map[string]int64{
+				"IDR": idr,
+				"MYR": myr,
+				"PHP": php,
+				"SGD": sgd,
+				"THB": thb,
+			},
+		})
+	}
+}
+
+// handlePartnerPriceListImpl returns the price of every active SKU in a region
+func handlePartnerPriceListImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+		defer cancel()
+
+		region := middleware.GetRegionFromContext(r.Context())
+		if region == "" {
+			region = "ID"
+		}
+		productCode := r.URL.Query().Get("productCode")
+
+		rows, err := deps.DB.Pool.Query(ctx, `
+			SELECT p.code, p.title, s.code, s.name, sp.sell_price, sp.currency::text, s.max_quantity
+			FROM skus s
+			JOIN products p ON p.id = s.product_id
+			JOIN product_regions pr ON pr.product_id = p.id AND pr.region_code = $1
+			JOIN sku_pricing sp ON sp.sku_id = s.id AND sp.region_code = $1 AND sp.is_active = true
+			WHERE s.is_active = true AND p.is_active = true AND ($2 = '' OR p.code = $2)
+			ORDER BY p.code ASC, sp.sell_price ASC
+		`, region, productCode)
+		if err != nil {
+			log.Error().Err(err).Str("endpoint", "/v2/h2h/price-list").Str("region", region).Msg("Failed to get price list")
+			utils.WriteInternalServerError(w)
+			return
+		}
+		defer rows.Close()
+
+		items := []map[string]interface{}{}
+		for rows.Next() {
+			var productCode, productName, skuCode, skuName, currency string
+			var price int64
+			var maxQuantity int
+			if err := rows.Scan(&productCode, &productName, &skuCode, &skuName, &price, &currency, &maxQuantity); err != nil {
+				log.Error().Err(err).Str("endpoint", "/v2/h2h/price-list").Msg("Failed to scan price list row")
+				utils.WriteInternalServerError(w)
+				return
+			}
+			items = append(items, map[string]interface{}{
+				"productCode": productCode,
+				"productName": productName,
+				"skuCode":     skuCode,
+				"skuName":     skuName,
+				"price":       price,
+				"currency":    currency,
+				"maxQuantity": maxQuantity,
+			})
+		}
+
+		utils.WriteSuccessJSON(w, map[string]interface{}{
+			"region": region,
+			"items":  items,
+		})
+	}
+}
+
+// PartnerOrderRequest is the body of an H2H order
+type PartnerOrderRequest struct {
+	RefID       string                 `json:"refId"`
+	ProductCode string                 `json:"productCode"`
+	SKUCode     string                 `json:"skuCode"`
+	Quantity    int                    `json:"quantity,omitempty"`
+	Account     map[string]interface{} `json:"account"`
+	CallbackURL string                 `json:"callbackUrl,omitempty"`
+}
+
+// handlePartnerCreateOrderImpl places an order paid from the partner's wallet. The
+// refId is unique per partner account, so a retried request returns the existing
+// order instead of charging twice.
+func handlePartnerCreateOrderImpl(deps *Dependencies) http.HandlerFunc {
+	const endpoint = "/v2/h2h/orders"
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		partner := partnerFromContext(r.Context())
+
+		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+		defer cancel()
+
+		var req PartnerOrderRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.WriteBadRequestError(w, "Invalid request body")
+			return
+		}
+		if req.Quantity == 0 {
+			req.Quantity = 1
+		}
+
+		fieldErrors := map[string]string{}
+		if !partnerRefIDPattern.MatchString(req.RefID) {
+			fieldErrors["refId"] = "Ref ID is required, up to 100 letters, digits, dots, dashes or underscores"
+		}
+		if req.ProductCode == "" {
+			fieldErrors["productCode"] = "Product code is required"
+		}
+		if req.SKUCode == "" {
+			fieldErrors["skuCode"] = "SKU code is required"
+		}
+		if req.CallbackURL != "" {
+			if err := utils.ValidateOutboundURL(r.Context(), req.CallbackURL); err != nil {
+				fieldErrors["callbackUrl"] = "Callback URL must be a public https URL"
+			}
+		}
+		if len(fieldErrors) > 0 {
+			utils.WriteValidationErrorJSON(w, "Validation failed", fieldErrors)
+			return
+		}
+
+		// A retried order returns the order created by the first request
+		if order, err := loadPartnerOrder(ctx, deps, partner.UserID, req.RefID); err == nil {
+			utils.WriteSuccessJSON(w, order)
+			return
+		} else if !errors.Is(err, pgx.ErrNoRows) {
+			log.Error().Err(err).Str("endpoint", endpoint).Str("ref_id", req.RefID).Msg("Failed to look up partner order")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		region := middleware.GetRegionFromContext(r.Context())
+		if region == "" {
+			region = "ID"
+		}
+		currency, err := getRegionCurrency(ctx, deps.DB.Pool, region)
+		if err != nil {
+			writeRegionPricingError(w, err, region)
+			return
+		}
+
+		var productID, productName string
+		err = deps.DB.Pool.QueryRow(ctx, `
+			SELECT p.id, p.title
+			FROM products p
+			JOIN product_regions pr ON p.id = pr.product_id
+			WHERE p.code = $1 AND p.is_active = true AND pr.region_code = $2
+		`, req.ProductCode, region).Scan(&productID, &productName)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				utils.WriteErrorJSON(w, http.StatusNotFound, "PRODUCT_NOT_FOUND",
+					"Product not found or not available in this region", "")
+				return
+			}
+			log.Error().Err(err).Str("endpoint", endpoint).Str("product_code", req.ProductCode).Msg("Failed to get product")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		sku, err := getSKURegionPrice(ctx, deps.DB.Pool, req.ProductCode, req.SKUCode, region)
+		if err == nil {
+			err = checkCurrency(currency, sku.Currency, "pricing")
+		}
+		if err != nil {
+			writeRegionPricingError(w, err, region)
+			return
+		}
+		if req.Quantity < 1 || req.Quantity > sku.MaxQuantity {
+			utils.WriteValidationErrorJSON(w, "Validation failed", map[string]string{
+				"quantity": fmt.Sprintf("Maximum quantity for this item is %d", sku.MaxQuantity),
+			})
+			return
+		}
+
+		// Validate account inputs against the product field schema
+		inputs := normalizeAccountInputs(req.Account)
+		fields, err := loadAccountFields(ctx, deps, req.ProductCode)
+		if err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
+		if len(fields) > 0 {
+			values, fieldErrors := validateAccountInputs(fields, inputs)
+			if len(fieldErrors) > 0 {
+				utils.WriteValidationErrorJSON(w, "Validation failed", fieldErrors)
+				return
+			}
+			inputs = values
+		} else if inputs["userId"] == "" && inputs["phoneNumber"] == "" {
+			utils.WriteValidationErrorJSON(w, "Validation failed", map[string]string{
+				"account.userId": "User ID or phone number is required",
+			})
+			return
+		}
+		accountInputs := make(map[string]interface{}, len(inputs))
+		for key, value := range inputs {
+			accountInputs[key] = value
+		}
+		accountInputsJSON, _ := json.Marshal(accountInputs)
+
+		var paymentChannelID string
+		err = deps.DB.Pool.QueryRow(ctx, `
+			SELECT id FROM payment_channels WHERE code = 'BALANCE' LIMIT 1
+		`).Scan(&paymentChannelID)
+		if err != nil {
+			log.Error().Err(err).Str("endpoint", endpoint).Msg("BALANCE payment channel not found")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		totalAmount := sku.SellPrice * int64(req.Quantity)
+		var callbackURL *string
+		if req.CallbackURL != "" {
+			callbackURL = &req.CallbackURL
+		}
+
+		tx, err := deps.DB.Pool.Begin(ctx)
+		if err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		// total_spent_idr only accumulates IDR spending
+		var spentIDR int64
+		if currency == "IDR" {
+			spentIDR = totalAmount
+		}
+
+		// Debit the wallet only if it covers the order, in one statement so
+		// concurrent orders can't overdraw it
+		balanceColumn := balanceColumnForCurrency(currency)
+		var balanceAfter int64
+		err = tx.QueryRow(ctx, `
+			UPDATE users
+			SET `+balanceColumn+` = `+balanceColumn+` - $1,
+				total_spent_idr = total_spent_idr + $2,
+				updated_at = NOW()
+			WHERE id = $3 AND `+balanceColumn+` >= $1
+			RETURNING `+balanceColumn+`
+		`, totalAmount, spentIDR, partner.UserID).Scan(&balanceAfter)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				utils.WriteErrorJSON(w, http.StatusBadRequest, "INSUFFICIENT_BALANCE",
+					"Insufficient balance", fmt.Sprintf("Order total is %s", utils.FormatCurrency(float64(totalAmount), currency)))
+				return
+			}
+			log.Error().Err(err).Str("endpoint", endpoint).Str("user_id", partner.UserID).Msg("Failed to debit partner balance")
+			utils.WriteInternalServerError(w)
+			return
+		}
+		balanceBefore := balanceAfter + totalAmount
+
+		invoiceNumber := utils.GenerateInvoiceNumber()
+		paidAt := time.Now()
+
+		var transactionID string
+		err = tx.QueryRow(ctx, `
+			INSERT INTO transactions (
+				invoice_number, user_id, product_id, sku_id, quantity,
+				account_inputs, provider_id, payment_channel_id,
+				buy_price, sell_price, discount_amount, payment_fee, total_amount,
+				currency, region,
+				status, payment_status, paid_at,
+				ip_address, user_agent,
+				partner_key_id, partner_ref_id, partner_callback_url,
+				expired_at, created_at, updated_at
+			) VALUES (
+				$1, $2, $3, $4, $5,
+				$6, $7, $8,
+				$9, $10, 0, 0, $11,
+				$12, $13,
+				'PROCESSING', 'PAID', $14,
+				$15, $16,
+				$17, $18, $19,
+				$14, NOW(), NOW()
+			) RETURNING id
+		`, invoiceNumber, partner.UserID, productID, sku.ID, req.Quantity,
+			accountInputsJSON, sku.ProviderID, paymentChannelID,
+			sku.BuyPrice, sku.SellPrice, totalAmount,
+			currency, region,
+			paidAt,
+			extractIPAddress(r), r.UserAgent(),
+			partner.KeyID, req.RefID, callbackURL).Scan(&transactionID)
+		if err != nil {
+			// A concurrent request with the same refId won the race
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				tx.Rollback(ctx)
+				if order, err := loadPartnerOrder(ctx, deps, partner.UserID, req.RefID); err == nil {
+					utils.WriteSuccessJSON(w, order)
+					return
+				}
+			}
+			log.Error().Err(err).Str("endpoint", endpoint).Str("ref_id", req.RefID).Msg("Failed to insert partner transaction")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		_, err = tx.Exec(ctx, `
+			INSERT INTO transaction_logs (transaction_id, status, message, created_at)
+			VALUES ($1, 'PAYMENT', $2, NOW())
+		`, transactionID, "Payment received via balance.")
+		if err != nil {
+			log.Error().Err(err).Str("endpoint", endpoint).Str("transaction_id", transactionID).Msg("Failed to record partner transaction log")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		_, err = tx.Exec(ctx, `
+			INSERT INTO mutations (
+				user_id, invoice_number, reference_type, reference_id,
+				description, mutation_type, amount,
+				balance_before, balance_after, currency, created_at
+			) VALUES ($1, $2, 'TRANSACTION', $3, $4, 'DEBIT', $5, $6, $7, $8, NOW())
+		`, partner.UserID, invoiceNumber, transactionID,
+			fmt.Sprintf("Pembelian H2H %s - %s (%s)", productName, sku.Name, req.RefID), totalAmount,
+			balanceBefore, balanceAfter, currency)
+		if err != nil {
+			log.Error().Err(err).Str("endpoint", endpoint).Str("transaction_id", transactionID).Msg("Failed to record partner mutation")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		_, err = tx.Exec(ctx, `
+			UPDATE transactions SET payment_logs = $1::jsonb WHERE id = $2
+		`, mustMarshalJSON([]interface{}{createLogEntry("PAYMENT_CREATED", map[string]interface{}{
+			"method":  "BALANCE",
+			"amount":  totalAmount,
+			"status":  "SUCCESS",
+			"message": "Payment completed via balance (H2H)",
+		})}), transactionID)
+		if err != nil {
+			log.Error().Err(err).Str("endpoint", endpoint).Str("transaction_id", transactionID).Msg("Failed to record partner payment log")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			log.Error().Err(err).Str("endpoint", endpoint).Str("transaction_id", transactionID).Msg("Failed to commit partner order")
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		log.Info().
+			Str("endpoint", endpoint).
+			Str("partner_key_id", partner.KeyID).
+			Str("ref_id", req.RefID).
+			Str("invoice_number", invoiceNumber).
+			Int64("total_amount", totalAmount).
+			Msg("Partner order created")
+
+		fulfillPaidOrder(deps, paidOrder{
+			TransactionID: transactionID,
+			InvoiceNumber: invoiceNumber,
+			ProviderID:    sku.ProviderID,
+			SKUCode:       sku.Code,
+			ProductName:   productName,
+			SKUName:       sku.Name,
+			AccountInputs: accountInputs,
+		})
+
+		order, err := loadPartnerOrder(ctx, deps, partner.UserID, req.RefID)
+		if err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
+		utils.WriteCreatedJSON(w, order)
+	}
+}
+
+// handlePartnerGetOrderImpl returns an H2H order by the partner's refId
+func handlePartnerGetOrderImpl(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		partner := partnerFromContext(r.Context())
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		order, err := loadPartnerOrder(ctx, deps, partner.UserID, chi.URLParam(r, "refId"))
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				utils.WriteErrorJSON(w, http.StatusNotFound, "ORDER_NOT_FOUND",
+					"Order not found", "No order with this refId")
+				return
+			}
+			log.Error().Err(err).Str("endpoint", "/v2/h2h/orders/{refId}").Msg("Failed to get partner order")
+			utils.WriteInternalServerError(w)
+			return
+		}
+		utils.WriteSuccessJSON(w, order)
+	}
+}
+
+// loadPartnerOrder loads an H2H order of a partner account by refId
+func loadPartnerOrder(ctx context.Context, deps *Dependencies, userID, refID string) (map[string]interface{}, error) {
+	var invoiceNumber, productCode, skuCode, status, currency string
+	var quantity int
+	var totalAmount, refundedAmount int64
+	var serialNumber *string
+	var createdAt time.Time
+	var completedAt *time.Time
+	err := deps.DB.Pool.QueryRow(ctx, `
+		SELECT t.invoice_number, p.code, s.code, t.quantity, t.status::text, t.provider_serial_number,
+		       t.total_amount, t.refunded_amount, t.currency::text, t.created_at, t.completed_at
+		FROM transactions t
+		JOIN products p ON p.id = t.product_id
+		JOIN skus s ON s.id = t.sku_id
+		WHERE t.user_id = $1 AND t.partner_ref_id = $2
+	`, userID, refID).Scan(&invoiceNumber, &productCode, &skuCode, &quantity, &status, &serialNumber,
+		&totalAmount, &refundedAmount, &currency, &createdAt, &completedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"refId":         refID,
+		"invoiceNumber": invoiceNumber,
+		"productCode":   productCode,
+		"skuCode":       skuCode,
+		"quantity":      quantity,
+		"status":        status,
+		"serialNumber":  serialNumber,
+		"price":         totalAmount,
+		"refunded":      refundedAmount,
+		"currency":      currency,
+		"createdAt":     createdAt,
+		"completedAt":   completedAt,
+	}, nil
+}
+
+// notifyPartnerOrder refunds a failed H2H order to the partner's wallet and queues
+// the callback with its final status to the partner's callback URL
+func notifyPartnerOrder(deps *Dependencies, transactionID, status string) {
+	if deps.PartnerService == nil {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if status == "FAILED" {
+			if err := refundPartnerOrder(ctx, deps, transactionID); err != nil {
+				log.Error().Err(err).Str("transaction_id", transactionID).Msg("Failed to refund partner order")
+			} else {
+				publishTransactionStatus(deps, transactionID)
+			}
+		}
+		if err := deps.PartnerService.NotifyOrder(ctx, transactionID); err != nil {
+			log.Warn().Err(err).Str("transaction_id", transactionID).Msg("Failed to queue partner callback")
+		}
+	}()
+}
+
+// refundPartnerOrder returns what is left of a failed H2H order to the partner's
+// wallet. Partners reconcile from the callback, so they are not left waiting for
+// a manual refund; the order stays FAILED with refunded_amount set.
+func refundPartnerOrder(ctx context.Context, deps *Dependencies, transactionID string) error {
+	tx, err := deps.DB.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var userID, invoiceNumber, currency string
+	var refundAmount int64
+	err = tx.QueryRow(ctx, `
+		SELECT user_id, invoice_number, currency::text, total_amount - refunded_amount
+		FROM transactions
+		WHERE id = $1 AND partner_key_id IS NOT NULL AND status = 'FAILED' AND payment_status = 'PAID'
+		FOR UPDATE
+	`, transactionID).Scan(&userID, &invoiceNumber, &currency, &refundAmount)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	if refundAmount <= 0 {
+		return nil
+	}
+
+	balanceColumn := balanceColumnForCurrency(currency)
+	var balanceAfter int64
+	err = tx.QueryRow(ctx, `
+		UPDATE users SET `+balanceColumn+` = `+balanceColumn+` + $1, updated_at = NOW()
+		WHERE id = $2
+		RETURNING `+balanceColumn+`
+	`, refundAmount, userID).Scan(&balanceAfter)
+	if err != nil {
+		return fmt.Errorf("credit balance: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO mutations (
+			user_id, invoice_number, reference_type, reference_id,
+			description, mutation_type, amount,
+			balance_before, balance_after, currency, created_at
+		) VALUES ($1, $2, 'REFUND', $3, $4, 'CREDIT', $5, $6, $7, $8, NOW())
+	`, userID, invoiceNumber, transactionID, "Pengembalian Dana - Transaksi H2H gagal", refundAmount,
+		balanceAfter-refundAmount, balanceAfter, currency)
+	if err != nil {
+		return fmt.Errorf("record mutation: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE transactions SET refunded_amount = total_amount, updated_at = NOW() WHERE id = $1
+	`, transactionID)
+	if err != nil {
+		return fmt.Errorf("record refunded amount: %w", err)
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO transaction_logs (transaction_id, status, message, created_at)
+		VALUES ($1, 'REFUNDED', $2, NOW())
+	`, transactionID, fmt.Sprintf("Refund of %s has been added to your balance.",
+		utils.FormatCurrency(float64(refundAmount), currency)))
+	if err != nil {
+		return fmt.Errorf("record refund log: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
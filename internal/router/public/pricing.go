@@ -0,0 +1,152 @@
+package public
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"seaply/internal/services"
+	"seaply/internal/utils"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Errors returned while resolving regional pricing
+var (
+	errSKUNotFound        = errors.New("sku not found")
+	errSKUNotInRegion     = errors.New("sku not sold in region")
+	errRegionNotFound     = errors.New("region not found")
+	errCurrencyMismatch   = errors.New("currency mismatch")
+	errChannelNotInRegion = errors.New("payment channel not available in region")
+)
+
+// skuRegionPrice is the price of a SKU in a single region
+type skuRegionPrice struct {
+	ID          string
+	Code        string
+	Name        string
+	ProviderID  string
+	Image       *string
+	MaxQuantity int
+	BuyPrice    int64
+	SellPrice   int64
+	Currency    string
+}
+
+// getRegionCurrency returns the currency configured for an active region
+func getRegionCurrency(ctx context.Context, db services.DBTX, region string) (string, error) {
+	var currency string
+	err := db.QueryRow(ctx, `
+		SELECT currency::text FROM regions WHERE code = $1 AND is_active = true
+	`, region).Scan(&currency)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", errRegionNotFound
+		}
+		return "", err
+	}
+	return currency, nil
+}
+
+// getSKURegionPrice resolves a SKU and its pricing row for the given region.
+// It returns errSKUNotFound when the SKU does not exist and errSKUNotInRegion
+// when the SKU exists but has no active price in the region.
+func getSKURegionPrice(ctx context.Context, db services.DBTX, productCode, skuCode, region string) (*skuRegionPrice, error) {
+	var sku skuRegionPrice
+	var buyPrice, sellPrice *int64
+	var currency *string
+
+	err := db.QueryRow(ctx, `
+		SELECT s.id, s.code, s.name, s.provider_id, s.image, s.max_quantity,
+		       sp.buy_price, sp.sell_price, sp.currency::text
+		FROM skus s
+		JOIN products p ON s.product_id = p.id
+		LEFT JOIN sku_pricing sp ON sp.sku_id = s.id AND sp.region_code = $3 AND sp.is_active = true
+		WHERE s.code = $1 AND p.code = $2 AND s.is_active = true
+	`, skuCode, productCode, region).Scan(&sku.ID, &sku.Code, &sku.Name, &sku.ProviderID, &sku.Image,
+		&sku.MaxQuantity, &buyPrice, &sellPrice, &currency)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errSKUNotFound
+		}
+		return nil, err
+	}
+
+	if sellPrice == nil || currency == nil {
+		return nil, errSKUNotInRegion
+	}
+
+	sku.SellPrice = *sellPrice
+	sku.Currency = *currency
+	if buyPrice != nil {
+		sku.BuyPrice = *buyPrice
+	}
+	return &sku, nil
+}
+
+// getChannelCurrency returns the currency a payment channel settles in, as configured on
+// the channel. It returns errChannelNotInRegion when the channel is not offered in the region.
+func getChannelCurrency(ctx context.Context, db services.DBTX, paymentCode, region string) (string, error) {
+	var currency string
+	err := db.QueryRow(ctx, `
+		SELECT pc.currency::text
+		FROM payment_channels pc
+		JOIN payment_channel_regions pcr ON pcr.channel_id = pc.id
+		WHERE pc.code = $1 AND pcr.region_code = $2
+	`, paymentCode, region).Scan(&currency)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", errChannelNotInRegion
+		}
+		return "", err
+	}
+	return currency, nil
+}
+
+// balanceColumnForCurrency returns the users balance column holding the currency
+func balanceColumnForCurrency(currency string) string {
+	switch currency {
+	case "MYR":
+		return "balance_myr"
+	case "PHP":
+		return "balance_php"
+	case "SGD":
+		return "balance_sgd"
+	case "THB":
+		return "balance_thb"
+	default:
+		return "balance_idr"
+	}
+}
+
+// writeRegionPricingError writes the response for a regional pricing error
+func writeRegionPricingError(w http.ResponseWriter, err error, region string) {
+	switch {
+	case errors.Is(err, errSKUNotFound):
+		utils.WriteErrorJSON(w, http.StatusNotFound, "SKU_NOT_FOUND",
+			"SKU not found", "The SKU code does not exist or is inactive")
+	case errors.Is(err, errSKUNotInRegion):
+		utils.WriteErrorJSON(w, http.StatusNotFound, "SKU_NOT_AVAILABLE_IN_REGION",
+			"SKU is not available in your region", fmt.Sprintf("This SKU is not sold in region %s", region))
+	case errors.Is(err, errRegionNotFound):
+		utils.WriteErrorJSON(w, http.StatusBadRequest, "INVALID_REGION",
+			"Region is not supported", fmt.Sprintf("Region %s is not active", region))
+	case errors.Is(err, errChannelNotInRegion):
+		utils.WriteErrorJSON(w, http.StatusBadRequest, "PAYMENT_NOT_AVAILABLE_IN_REGION",
+			"Payment method is not available in your region", "")
+	case errors.Is(err, errCurrencyMismatch):
+		utils.WriteErrorJSON(w, http.StatusConflict, "CURRENCY_MISMATCH",
+			"Currency does not match the order currency", err.Error())
+	default:
+		utils.WriteInternalServerError(w)
+	}
+}
+
+// checkCurrency returns errCurrencyMismatch when got differs from the order currency
+func checkCurrency(orderCurrency, got, source string) error {
+	if got != orderCurrency {
+		return fmt.Errorf("%w: %s currency %s, order currency %s", errCurrencyMismatch, source, got, orderCurrency)
+	}
+	return nil
+}
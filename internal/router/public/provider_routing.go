@@ -0,0 +1,211 @@
+package public
+
+import (
+	"context"
+	"strings"
+
+	"seaply/internal/provider"
+
+	"github.com/rs/zerolog/log"
+)
+
+// resolveOrderRoutes returns the provider routes for a transaction's SKU in the
+// order they should be tried. SKUs without provider mappings keep a single route
+// to the provider and provider SKU chosen by the caller.
+func resolveOrderRoutes(ctx context.Context, deps *Dependencies, transactionID string, fallback provider.Provider, fallbackSKU string) []provider.Route {
+	fallbackRoutes := []provider.Route{{Provider: fallback, SKU: fallbackSKU, Priority: 1, Weight: 1}}
+
+	var skuID, strategy string
+	var amount int64
+	err := deps.DB.Pool.QueryRow(ctx, `
+		SELECT s.id, s.routing_strategy,
+		       CASE WHEN t.currency::text = 'IDR' THEN t.buy_price ELSE 0 END
+		FROM transactions t
+		JOIN skus s ON t.sku_id = s.id
+		WHERE t.id = $1
+	`, transactionID).Scan(&skuID, &strategy, &amount)
+	if err != nil {
+		log.Warn().Err(err).Str("transaction_id", transactionID).Msg("Failed to load SKU routing, using default provider")
+		return fallbackRoutes
+	}
+
+	rows, err := deps.DB.Pool.Query(ctx, `
+		SELECT m.id, m.provider_id, p.code, m.provider_sku_code, m.priority, m.weight, COALESCE(m.cost_price, 0)
+		FROM sku_provider_mappings m
+		JOIN providers p ON m.provider_id = p.id
+		WHERE m.sku_id = $1 AND m.is_active = true AND p.is_active = true
+		ORDER BY m.priority ASC, m.created_at ASC
+	`, skuID)
+	if err != nil {
+		log.Warn().Err(err).Str("sku_id", skuID).Msg("Failed to load SKU provider mappings, using default provider")
+		return fallbackRoutes
+	}
+
+	var routes []provider.Route
+	for rows.Next() {
+		var route provider.Route
+		var providerCode string
+		var cost int64
+		if err := rows.Scan(&route.MappingID, &route.ProviderID, &providerCode, &route.SKU, &route.Priority, &route.Weight, &cost); err != nil {
+			continue
+		}
+
+		prov, err := deps.ProviderManager.Get(strings.ToLower(providerCode))
+		if err != nil {
+			log.Warn().Str("provider", providerCode).Str("sku_id", skuID).Msg("Mapped provider is not registered, skipping route")
+			continue
+		}
+		route.Provider = prov
+		route.Cost = float64(cost)
+		route.Amount = float64(amount)
+		routes = append(routes, route)
+	}
+	rows.Close()
+
+	if len(routes) == 0 {
+		return fallbackRoutes
+	}
+
+	// CHEAPEST ranks by the stored cost_price the price watcher keeps current,
+	// so no provider is called while the order waits
+	if strategy == provider.RoutingSuccessRate {
+		loadRouteSuccessRates(ctx, deps, skuID, routes)
+	}
+
+	return deps.ProviderManager.OrderRoutes(routes, strategy)
+}
+
+// loadRouteSuccessRates fills each route's success rate from the SKU's
+// transactions of the last 7 days. Rates are smoothed so providers with few
+// orders are neither preferred nor avoided.
+func loadRouteSuccessRates(ctx context.Context, deps *Dependencies, skuID string, routes []provider.Route) {
+	rows, err := deps.DB.Pool.Query(ctx, `
+		SELECT provider_id,
+		       COUNT(*) FILTER (WHERE status = 'SUCCESS'),
+		       COUNT(*) FILTER (WHERE status IN ('SUCCESS', 'FAILED'))
+		FROM transactions
+		WHERE sku_id = $1 AND created_at > NOW() - INTERVAL '7 days'
+		GROUP BY provider_id
+	`, skuID)
+	if err != nil {
+		log.Warn().Err(err).Str("sku_id", skuID).Msg("Failed to load provider success rates")
+		return
+	}
+	defer rows.Close()
+
+	rates := make(map[string]float64)
+	for rows.Next() {
+		var providerID string
+		var succeeded, finished int
+		if err := rows.Scan(&providerID, &succeeded, &finished); err != nil {
+			continue
+		}
+		rates[providerID] = float64(succeeded+1) / float64(finished+2)
+	}
+
+	for i := range routes {
+		if rate, ok := rates[routes[i].ProviderID]; ok {
+			routes[i].SuccessRate = rate
+		} else {
+			routes[i].SuccessRate = 0.5
+		}
+	}
+}
+
+// createProviderOrder sends an order along the transaction's provider routes,
+// failing over to the next mapped provider on retryable errors. The transaction
+// is moved to the mapping that accepts the order so status checks, callbacks
+// and margin reporting use that provider.
+func createProviderOrder(ctx context.Context, deps *Dependencies, transactionID string, prov provider.Provider, req *provider.OrderRequest) (*provider.OrderResponse, error) {
+	if deps.ProviderManager == nil {
+		return prov.CreateOrder(ctx, req)
+	}
+
+	routes := resolveOrderRoutes(ctx, deps, transactionID, prov, req.SKU)
+	resp, route, attempts, err := deps.ProviderManager.CreateOrderWithFailover(ctx, routes, req)
+
+	if len(attempts) > 1 {
+		tried := make([]map[string]interface{}, 0, len(attempts))
+		for _, a := range attempts {
+			attempt := map[string]interface{}{
+				"provider":  a.Route.Provider.GetName(),
+				"sku":       a.Route.SKU,
+				"latencyMs": a.Latency.Milliseconds(),
+			}
+			if a.Err != nil {
+				attempt["error"] = a.Err.Error()
+			} else if a.Response != nil {
+				attempt["status"] = a.Response.Status
+				attempt["message"] = a.Response.Message
+			}
+			tried = append(tried, attempt)
+		}
+		appendProviderLog(ctx, deps, transactionID, "PROVIDER_FAILOVER", map[string]interface{}{
+			"refId":    req.RefID,
+			"attempts": tried,
+		})
+
+		if route.Provider != nil {
+			log.Info().
+				Str("transaction_id", transactionID).
+				Str("ref_id", req.RefID).
+				Str("provider", route.Provider.GetName()).
+				Str("sku", route.SKU).
+				Int("attempts", len(attempts)).
+				Msg("Provider order failed over to another route")
+		}
+	}
+
+	if route.MappingID != "" {
+		recordOrderRoute(ctx, deps, transactionID, req.RefID, route.MappingID)
+	}
+
+	return resp, err
+}
+
+// recordOrderRoute moves a transaction, and the unit carrying refID, to the provider
+// mapping that accepted the order. The mapping is reloaded so the provider SKU and,
+// when the provider changed, the buy price match the provider that fulfils it.
+func recordOrderRoute(ctx context.Context, deps *Dependencies, transactionID, refID, mappingID string) {
+	_, err := deps.DB.Pool.Exec(ctx, `
+		UPDATE transactions t
+		SET provider_id = m.provider_id,
+		    provider_sku_code = m.provider_sku_code,
+		    buy_price = CASE
+		        WHEN t.provider_id <> m.provider_id AND t.currency::text = 'IDR' AND COALESCE(m.cost_price, 0) > 0
+		        THEN m.cost_price
+		        ELSE t.buy_price
+		    END,
+		    updated_at = NOW()
+		FROM sku_provider_mappings m
+		WHERE t.id = $1 AND m.id = $2
+	`, transactionID, mappingID)
+	if err != nil {
+		log.Error().Err(err).Str("transaction_id", transactionID).Str("mapping_id", mappingID).Msg("Failed to record provider route of transaction")
+		return
+	}
+
+	// Units keep their own provider, the next unit of the order may route elsewhere
+	_, err = deps.DB.Pool.Exec(ctx, `
+		UPDATE transaction_units u
+		SET provider_id = m.provider_id, provider_sku_code = m.provider_sku_code, updated_at = NOW()
+		FROM sku_provider_mappings m
+		WHERE u.ref_id = $1 AND m.id = $2
+	`, refID, mappingID)
+	if err != nil {
+		log.Error().Err(err).Str("ref_id", refID).Str("mapping_id", mappingID).Msg("Failed to record provider route of transaction unit")
+	}
+}
+
+// hasProviderMappings reports whether the SKU of a transaction is routed through provider mappings
+func hasProviderMappings(ctx context.Context, deps *Dependencies, transactionID string) bool {
+	var exists bool
+	err := deps.DB.Pool.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM sku_provider_mappings m
+			JOIN transactions t ON t.sku_id = m.sku_id
+			WHERE t.id = $1 AND m.is_active = true
+		)
+	`, transactionID).Scan(&exists)
+	return err == nil && exists
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -519,7 +520,7 @@ func handleGetSKUsImpl(deps *Dependencies) http.HandlerFunc {
 
 		rows, err := deps.DB.Pool.Query(ctx, `
 			SELECT s.code, s.name, s.description, sp.currency, sp.sell_price, sp.original_price,
-			       s.image, s.info, s.process_time, s.is_active, s.is_featured,
+			       s.image, s.info, s.process_time, s.max_quantity, s.is_active, s.is_featured,
 			       s.badge_text, s.badge_color,
 			       sec.code as section_code, sec.title as section_title
 			FROM skus s
@@ -539,12 +540,12 @@ func handleGetSKUsImpl(deps *Dependencies) http.HandlerFunc {
 		for rows.Next() {
 			var code, name, currency string
 			var description, image, info, badgeText, badgeColor, sectionCode, sectionTitle *string
-			var processTime int
+			var processTime, maxQuantity int
 			var isActive, isFeatured bool
 			var sellPrice, originalPrice int64
 
 			if err := rows.Scan(&code, &name, &description, &currency, &sellPrice, &originalPrice,
-				&image, &info, &processTime, &isActive, &isFeatured,
+				&image, &info, &processTime, &maxQuantity, &isActive, &isFeatured,
 				&badgeText, &badgeColor,
 				&sectionCode, &sectionTitle); err != nil {
 				continue
@@ -564,6 +565,7 @@ func handleGetSKUsImpl(deps *Dependencies) http.HandlerFunc {
 				"originalPrice": originalPrice,
 				"discount":      discount,
 				"processTime":   processTime,
+				"maxQuantity":   maxQuantity,
 				"isAvailable":   isActive,
 				"isFeatured":    isFeatured,
 			}
@@ -1201,7 +1203,7 @@ func handleGetFieldsImpl(deps *Dependencies) http.HandlerFunc {
 		rows, err := deps.DB.Pool.Query(ctx, `
 			SELECT pf.name, pf.key, pf.field_type, pf.label, 
 			       pf.is_required, pf.min_length, pf.max_length,
-			       pf.placeholder, pf.pattern, pf.hint
+			       pf.placeholder, pf.pattern, pf.hint, pf.options
 			FROM product_fields pf
 			JOIN products p ON pf.product_id = p.id
 			WHERE p.slug = $1 OR p.code = $1
@@ -1219,10 +1221,11 @@ func handleGetFieldsImpl(deps *Dependencies) http.HandlerFunc {
 			var placeholder, hint, pattern *string
 			var isRequired bool
 			var minLength, maxLength *int
+			var options []byte
 
 			if err := rows.Scan(&name, &key, &fieldType, &label,
 				&isRequired, &minLength, &maxLength,
-				&placeholder, &pattern, &hint); err != nil {
+				&placeholder, &pattern, &hint, &options); err != nil {
 				continue
 			}
 
@@ -1253,6 +1256,9 @@ func handleGetFieldsImpl(deps *Dependencies) http.HandlerFunc {
 			if hint != nil && *hint != "" {
 				field["hint"] = *hint
 			}
+			if fieldOptions := parseFieldOptions(options); len(fieldOptions) > 0 {
+				field["options"] = fieldOptions
+			}
 			fields = append(fields, field)
 		}
 
@@ -1444,6 +1450,7 @@ func handleGetInvoiceImpl(deps *Dependencies) http.HandlerFunc {
 					INSERT INTO transaction_logs (transaction_id, status, message, created_at)
 					VALUES ($1, 'FAILED', 'Payment expired', NOW())
 				`, id)
+				publishInvoiceStatus(deps, invoiceNumber)
 
 				// Update local variables for response
 				status = "FAILED"
@@ -1680,6 +1687,36 @@ func handleGetInvoiceImpl(deps *Dependencies) http.HandlerFunc {
 			response["paidAt"] = paidAt.Format(time.RFC3339)
 		}
 
+		// Multi-quantity orders show the delivery of each unit
+		if quantity > 1 {
+			if items, err := getTransactionUnits(ctx, deps, id); err == nil && len(items) > 0 {
+				response["items"] = items
+			}
+
+			var refundedAmount int64
+			deps.DB.Pool.QueryRow(ctx, `SELECT refunded_amount FROM transactions WHERE id = $1`, id).Scan(&refundedAmount)
+			if refundedAmount > 0 {
+				pricing["refunded"] = float64(refundedAmount)
+			}
+		}
+
+		// Signed link to the PDF version of the invoice
+		if deps.InvoiceService != nil {
+			pdfURL, pdfExpiresAt := deps.InvoiceService.SignedURL(invoiceNumber)
+			response["pdfUrl"] = pdfURL
+			response["pdfUrlExpiresAt"] = pdfExpiresAt.Format(time.RFC3339)
+		}
+
+		// Token for following status changes live instead of polling
+		if deps.InvoiceStream != nil {
+			streamToken, streamExpiresAt := deps.InvoiceStream.Token(invoiceNumber)
+			response["stream"] = map[string]interface{}{
+				"url":       deps.Config.App.BaseURL + "/v2/invoices/stream?invoiceNumber=" + url.QueryEscape(invoiceNumber) + "&token=" + url.QueryEscape(streamToken),
+				"token":     streamToken,
+				"expiresAt": streamExpiresAt.Format(time.RFC3339),
+			}
+		}
+
 		utils.WriteSuccessJSON(w, response)
 	}
 }
@@ -1821,6 +1858,7 @@ func handleGetDepositInvoiceImpl(deps *Dependencies) http.HandlerFunc {
 					INSERT INTO deposit_logs (deposit_id, status, message, created_at)
 					VALUES ($1, 'EXPIRED', 'Payment expired', NOW())
 				`, id)
+				publishInvoiceStatus(deps, invoiceNumber)
 
 				// Update local variable for response
 				status = "EXPIRED"
@@ -2026,6 +2064,23 @@ func handleGetDepositInvoiceImpl(deps *Dependencies) http.HandlerFunc {
 			response["paidAt"] = paidAt.Format(time.RFC3339)
 		}
 
+		// Signed link to the PDF version of the invoice
+		if deps.InvoiceService != nil {
+			pdfURL, pdfExpiresAt := deps.InvoiceService.SignedURL(invoiceNumber)
+			response["pdfUrl"] = pdfURL
+			response["pdfUrlExpiresAt"] = pdfExpiresAt.Format(time.RFC3339)
+		}
+
+		// Token for following status changes live instead of polling
+		if deps.InvoiceStream != nil {
+			streamToken, streamExpiresAt := deps.InvoiceStream.Token(invoiceNumber)
+			response["stream"] = map[string]interface{}{
+				"url":       deps.Config.App.BaseURL + "/v2/deposits/invoices/stream?invoiceNumber=" + url.QueryEscape(invoiceNumber) + "&token=" + url.QueryEscape(streamToken),
+				"token":     streamToken,
+				"expiresAt": streamExpiresAt.Format(time.RFC3339),
+			}
+		}
+
 		utils.WriteSuccessJSON(w, response)
 	}
 }
@@ -0,0 +1,119 @@
+package public
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"seaply/internal/services"
+	"seaply/internal/utils"
+
+	"github.com/rs/zerolog/log"
+)
+
+// assessCheckoutRisk scores a checkout with the risk engine. It reports whether the
+// checkout may go on; otherwise the response asking to sign in, to enable MFA or
+// refusing it was written. Held checkouts go on and are queued once created. The
+// checkout isn't refused when the engine fails.
+func assessCheckoutRisk(w http.ResponseWriter, r *http.Request, deps *Dependencies, check services.RiskCheck) (*services.RiskAssessment, bool) {
+	if deps.Risk == nil {
+		return nil, true
+	}
+
+	ctx := r.Context()
+	settings, err := deps.Risk.LoadSettings(ctx)
+	if err != nil {
+		log.Error().Err(err).Str("kind", check.Kind).Msg("Failed to load risk settings")
+		return nil, true
+	}
+	check.Country = settings.Country(r.Header)
+
+	assessment, err := deps.Risk.Evaluate(ctx, settings, check)
+	if err != nil {
+		log.Error().Err(err).Str("kind", check.Kind).Msg("Failed to evaluate checkout risk")
+		return nil, true
+	}
+
+	switch assessment.Action {
+	case services.RiskRequireLogin:
+		utils.WriteErrorJSON(w, http.StatusUnauthorized, "AUTHENTICATION_REQUIRED",
+			"Please sign in to place this order", "")
+		return nil, false
+	case services.RiskRequireMFA:
+		if check.UserID == "" {
+			utils.WriteErrorJSON(w, http.StatusUnauthorized, "AUTHENTICATION_REQUIRED",
+				"Please sign in to place this order", "Two-factor authentication must be enabled on the account")
+			return nil, false
+		}
+		utils.WriteErrorJSON(w, http.StatusForbidden, "MFA_REQUIRED",
+			"Please enable two-factor authentication to place this order", "")
+		return nil, false
+	case services.RiskBlock:
+		utils.WriteErrorJSON(w, http.StatusForbidden, "CHECKOUT_BLOCKED",
+			"This order can't be placed", "Please contact customer support")
+		return nil, false
+	}
+	return assessment, true
+}
+
+// heldForReview reports whether a paid transaction must wait for its risk review
+// instead of going to the provider, and notes it on the timeline. Orders released
+// later are sent by FulfillReleasedOrder. When the review can't be checked the
+// order is held too, an admin then sends it with a retry.
+func heldForReview(ctx context.Context, deps *Dependencies, transactionID string) bool {
+	if deps.Risk == nil {
+		return false
+	}
+
+	message := "Payment received, the order is under review."
+	status, err := deps.Risk.ReviewStatus(ctx, transactionID)
+	if err != nil {
+		log.Error().Err(err).Str("transaction_id", transactionID).Msg("Failed to check risk review, holding the order")
+		message = "Payment received, the order is waiting for a manual check."
+	} else {
+		switch status {
+		case services.RiskReviewPending:
+		case services.RiskReviewCancelled:
+			message = "Payment received for an order cancelled after review."
+		default:
+			return false
+		}
+	}
+
+	log.Info().Str("transaction_id", transactionID).Str("review_status", status).Msg("Order held for risk review, not sent to provider")
+	if _, err := deps.DB.Pool.Exec(ctx, `
+		INSERT INTO transaction_logs (transaction_id, status, message, created_at)
+		VALUES ($1, 'REVIEW', $2, NOW())
+	`, transactionID, message); err != nil {
+		log.Warn().Err(err).Str("transaction_id", transactionID).Msg("Failed to log held order")
+	}
+	return true
+}
+
+// FulfillReleasedOrder returns the function sending a paid order to its provider once
+// its risk review releases it. Unpaid orders are sent when their payment arrives.
+func FulfillReleasedOrder(deps *Dependencies) func(ctx context.Context, evaluation *services.RiskEvaluation) {
+	return func(ctx context.Context, evaluation *services.RiskEvaluation) {
+		transaction := evaluation.Transaction
+		if transaction == nil || transaction.Status != "PROCESSING" || transaction.PaymentStatus != "PAID" {
+			return
+		}
+
+		order := paidOrder{TransactionID: transaction.ID, InvoiceNumber: transaction.InvoiceNumber}
+		var accountInputs []byte
+		err := deps.DB.Pool.QueryRow(ctx, `
+			SELECT t.provider_id, s.code, COALESCE(p.title, ''), s.name, t.account_inputs
+			FROM transactions t
+			JOIN skus s ON t.sku_id = s.id
+			LEFT JOIN products p ON t.product_id = p.id
+			WHERE t.id = $1
+		`, transaction.ID).Scan(&order.ProviderID, &order.SKUCode, &order.ProductName, &order.SKUName, &accountInputs)
+		if err != nil {
+			log.Error().Err(err).Str("transaction_id", transaction.ID).Msg("Failed to load released order")
+			return
+		}
+		_ = json.Unmarshal(accountInputs, &order.AccountInputs)
+
+		fulfillPaidOrder(deps, order)
+	}
+}
@@ -0,0 +1,125 @@
+package public
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// unitReconcileAfter is how long a unit with a provider response waits for a callback before its status is checked
+	unitReconcileAfter = 2 * time.Minute
+
+	// unitReconcileBatch is the number of units checked per run
+	unitReconcileBatch = 50
+)
+
+// StartUnitReconciler checks the status of multi-quantity order units left
+// PROCESSING with their provider at every interval, so units of providers that
+// send no callback, and units whose outcome was unknown after a failover, settle.
+func StartUnitReconciler(ctx context.Context, deps *Dependencies, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if n := reconcileTransactionUnits(ctx, deps); n > 0 {
+					log.Info().Int("count", n).Msg("Reconciled transaction units with their providers")
+				}
+			}
+		}
+	}()
+}
+
+// staleUnit is a unit waiting for its provider and the code of that provider
+type staleUnit struct {
+	refID        string
+	providerCode string
+}
+
+// reconcileTransactionUnits checks one batch of stale units and returns how many reached a final status
+func reconcileTransactionUnits(ctx context.Context, deps *Dependencies) int {
+	if deps.ProviderManager == nil {
+		return 0
+	}
+
+	// Units without a provider response may still be waiting to be ordered, they
+	// are only checked once the fulfilment of their order has timed out
+	rows, err := deps.DB.Pool.Query(ctx, `
+		SELECT u.ref_id, p.code
+		FROM transaction_units u
+		JOIN transactions t ON t.id = u.transaction_id
+		JOIN providers p ON p.id = COALESCE(u.provider_id, t.provider_id)
+		WHERE u.status = 'PROCESSING'
+		  AND (
+		      (u.provider_response IS NOT NULL AND u.updated_at < $1)
+		      OR u.updated_at < NOW() - make_interval(secs => t.quantity * 30)
+		  )
+		ORDER BY u.updated_at ASC
+		LIMIT $2
+	`, time.Now().Add(-unitReconcileAfter), unitReconcileBatch)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load stale transaction units")
+		return 0
+	}
+
+	var units []staleUnit
+	for rows.Next() {
+		var u staleUnit
+		if err := rows.Scan(&u.refID, &u.providerCode); err == nil {
+			units = append(units, u)
+		}
+	}
+	rows.Close()
+
+	settled := 0
+	for _, u := range units {
+		if reconcileTransactionUnit(ctx, deps, u) {
+			settled++
+		}
+	}
+	return settled
+}
+
+// reconcileTransactionUnit asks the provider for the status of one unit and applies
+// it when final. It reports whether the unit reached a final status.
+func reconcileTransactionUnit(ctx context.Context, deps *Dependencies, u staleUnit) bool {
+	prov, err := deps.ProviderManager.Get(strings.ToLower(u.providerCode))
+	if err != nil {
+		log.Warn().Str("provider", u.providerCode).Str("ref_id", u.refID).Msg("Provider of transaction unit is not registered")
+		touchTransactionUnit(ctx, deps, u.refID)
+		return false
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	orderStatus, err := prov.CheckStatus(checkCtx, u.refID)
+	if err != nil {
+		log.Warn().Err(err).Str("provider", u.providerCode).Str("ref_id", u.refID).Msg("Failed to check status of transaction unit")
+		touchTransactionUnit(ctx, deps, u.refID)
+		return false
+	}
+
+	status := mapProviderOrderStatus(orderStatus.Status)
+	if status != "SUCCESS" && status != "FAILED" {
+		touchTransactionUnit(ctx, deps, u.refID)
+		return false
+	}
+
+	applyUnitStatus(ctx, deps, "STATUS_CHECK", u.refID, status, orderStatus.SN, orderStatus)
+	return true
+}
+
+// touchTransactionUnit moves a unit that is still waiting to the back of the reconcile queue
+func touchTransactionUnit(ctx context.Context, deps *Dependencies, refID string) {
+	_, _ = deps.DB.Pool.Exec(ctx, `
+		UPDATE transaction_units SET updated_at = NOW()
+		WHERE ref_id = $1 AND status = 'PROCESSING'
+	`, refID)
+}
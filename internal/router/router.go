@@ -1,6 +1,8 @@
 package router
 
 import (
+	"context"
+	"time"
 	"unsafe"
 
 	"seaply/internal/config"
@@ -19,16 +21,32 @@ import (
 )
 
 type Dependencies struct {
-	Config          *config.Config
-	DB              *database.PostgresDB
-	Redis           *database.RedisClient
-	S3              *storage.S3Storage
-	JWTService      utils.JWTService
-	EmailService    *services.EmailService
-	AuthMiddleware  *middleware.AuthMiddleware
-	RateLimiter     *middleware.RateLimiter
-	ProviderManager *provider.Manager
-	PaymentManager  *payment.Manager
+	Config              *config.Config
+	DB                  *database.PostgresDB
+	Redis               *database.RedisClient
+	S3                  *storage.S3Storage
+	JWTService          utils.JWTService
+	EmailService        *services.EmailService
+	NotificationService *services.NotificationService
+	EmailOutbox         *services.EmailOutbox
+	AuthMiddleware      *middleware.AuthMiddleware
+	RateLimiter         *middleware.RateLimiter
+	CatalogCache        *middleware.CatalogCache
+	ProviderManager     *provider.Manager
+	PaymentManager      *payment.Manager
+	PriceWatcher        *services.PriceWatcher
+	BalanceMonitor      *services.BalanceMonitor
+	ProviderRegistry    *services.ProviderRegistry
+	GatewayRegistry     *services.GatewayRegistry
+	InvoiceService      *services.InvoiceService
+	InvoiceStream       *services.InvoiceStream
+	PartnerService      *services.PartnerService
+	Webhooks            *services.WebhookDispatcher
+	Idempotency         *middleware.IdempotencyStore
+	GuestAccess         *services.GuestAccess
+	Audit               *services.AuditService
+	Approvals           *services.ApprovalService
+	Risk                *services.RiskEngine
 }
 
 // Helper functions to convert Dependencies to package-specific types
@@ -44,14 +62,29 @@ func toUserDeps(deps *Dependencies) *user.Dependencies {
 	return (*user.Dependencies)(unsafe.Pointer(deps))
 }
 
+// StartJobs starts the background jobs of the route packages until ctx is done
+func StartJobs(ctx context.Context, deps *Dependencies) {
+	// Units of multi-quantity orders still waiting for their provider are checked for a final status
+	public.StartUnitReconciler(ctx, toPublicDeps(deps), time.Minute)
+}
+
 func SetupRoutes(r chi.Router, deps *Dependencies) {
+	// Paid orders released from risk review are sent to their provider
+	if deps.Risk != nil {
+		deps.Risk.OnRelease(public.FulfillReleasedOrder(toPublicDeps(deps)))
+	}
+
 	// Public API v2
 	r.Route("/v2", func(r chi.Router) {
 		// Region validator middleware
 		r.Use(middleware.RegionValidator(deps.Config.App.DefaultRegion))
 
 		// Public endpoints (no auth required)
-		setupPublicRoutes(r, deps)
+		r.Group(func(r chi.Router) {
+			r.Use(deps.AuthMiddleware.OptionalAuth)
+			r.Use(deps.RateLimiter.Limit(middleware.RateLimitGroupPublic))
+			setupPublicRoutes(r, deps)
+		})
 
 		// Auth endpoints
 		r.Route("/auth", func(r chi.Router) {
@@ -61,6 +94,7 @@ func SetupRoutes(r chi.Router, deps *Dependencies) {
 		// User endpoints (auth required)
 		r.Group(func(r chi.Router) {
 			r.Use(deps.AuthMiddleware.RequireAuth)
+			r.Use(deps.RateLimiter.Limit(middleware.RateLimitGroupUser))
 			setupUserRoutes(r, deps)
 		})
 
@@ -69,6 +103,17 @@ func SetupRoutes(r chi.Router, deps *Dependencies) {
 			r.Use(deps.AuthMiddleware.OptionalAuth)
 			setupTransactionRoutes(r, deps)
 		})
+
+		// H2H partner API (signed with API key and secret)
+		r.Route("/h2h", func(r chi.Router) {
+			setupPartnerRoutes(r, deps)
+		})
+
+		// Guest order tracking (contact verified with a one-time code)
+		r.Route("/guest/orders", func(r chi.Router) {
+			r.Use(deps.AuthMiddleware.OptionalAuth)
+			setupGuestOrderRoutes(r, deps)
+		})
 	})
 
 	// Admin API v2
@@ -87,6 +132,7 @@ func SetupRoutes(r chi.Router, deps *Dependencies) {
 
 	// Webhooks (no auth, signature validation)
 	r.Route("/webhooks", func(r chi.Router) {
+		r.Use(deps.RateLimiter.WebhookGuard)
 		setupWebhookRoutes(r, deps)
 	})
 
@@ -108,13 +154,13 @@ func setupPublicRoutes(r chi.Router, deps *Dependencies) {
 	r.Get("/popups", public.HandleGetPopups(toPublicDeps(deps)))
 
 	// GET /v2/banners
-	r.Get("/banners", public.HandleGetBanners(toPublicDeps(deps)))
+	r.With(deps.CatalogCache.Cache(middleware.CacheBanners)).Get("/banners", public.HandleGetBanners(toPublicDeps(deps)))
 
 	// GET /v2/categories
-	r.Get("/categories", public.HandleGetCategories(toPublicDeps(deps)))
+	r.With(deps.CatalogCache.Cache(middleware.CacheCategories)).Get("/categories", public.HandleGetCategories(toPublicDeps(deps)))
 
 	// GET /v2/products
-	r.Get("/products", public.HandleGetProducts(toPublicDeps(deps)))
+	r.With(deps.CatalogCache.Cache(middleware.CacheProducts)).Get("/products", public.HandleGetProducts(toPublicDeps(deps)))
 
 	// GET /v2/populars
 	r.Get("/populars", public.HandleGetPopularProducts(toPublicDeps(deps)))
@@ -126,7 +172,7 @@ func setupPublicRoutes(r chi.Router, deps *Dependencies) {
 	r.Get("/sections", public.HandleGetSections(toPublicDeps(deps)))
 
 	// GET /v2/skus
-	r.Get("/skus", public.HandleGetSKUs(toPublicDeps(deps)))
+	r.With(deps.CatalogCache.Cache(middleware.CacheSKUs)).Get("/skus", public.HandleGetSKUs(toPublicDeps(deps)))
 
 	// GET /v2/sku/promos
 	r.Get("/sku/promos", public.HandleGetPromoSKUs(toPublicDeps(deps)))
@@ -146,9 +192,21 @@ func setupPublicRoutes(r chi.Router, deps *Dependencies) {
 	// GET /v2/invoices
 	r.Get("/invoices", public.HandleGetInvoice(toPublicDeps(deps)))
 
+	// GET /v2/invoices/pdf
+	r.Get("/invoices/pdf", public.HandleDownloadInvoicePDF(toPublicDeps(deps)))
+
+	// GET /v2/invoices/stream
+	r.Get("/invoices/stream", public.HandleInvoiceStream(toPublicDeps(deps)))
+
 	// GET /v2/deposits/invoices
 	r.Get("/deposits/invoices", public.HandleGetDepositInvoice(toPublicDeps(deps)))
 
+	// GET /v2/deposits/invoices/pdf
+	r.Get("/deposits/invoices/pdf", public.HandleDownloadDepositInvoicePDF(toPublicDeps(deps)))
+
+	// GET /v2/deposits/invoices/stream
+	r.Get("/deposits/invoices/stream", public.HandleDepositInvoiceStream(toPublicDeps(deps)))
+
 	// GET /v2/reviews
 	r.Get("/reviews", public.HandleGetReviews(toPublicDeps(deps)))
 
@@ -159,7 +217,7 @@ func setupPublicRoutes(r chi.Router, deps *Dependencies) {
 
 func setupAuthRoutes(r chi.Router, deps *Dependencies) {
 	// Rate limit auth endpoints
-	r.Use(deps.RateLimiter.IPRateLimit(middleware.AuthRateLimit))
+	r.Use(deps.RateLimiter.Limit(middleware.RateLimitGroupAuth))
 	mainDeps := toPublicDeps(deps)
 
 	// POST /v2/auth/register
@@ -238,12 +296,12 @@ func setupUserRoutes(r chi.Router, deps *Dependencies) {
 	r.Post("/deposits/inquiries", public.HandleDepositInquiry(mainDeps))
 
 	// POST /v2/deposits
-	r.Post("/deposits", public.HandleCreateDeposit(mainDeps))
+	r.With(deps.Idempotency.Idempotent).Post("/deposits", public.HandleCreateDeposit(mainDeps))
 }
 
 func setupTransactionRoutes(r chi.Router, deps *Dependencies) {
 	// Rate limit order endpoints
-	r.Use(deps.RateLimiter.RateLimit(middleware.OrderRateLimit))
+	r.Use(deps.RateLimiter.Limit(middleware.RateLimitGroupOrder))
 	mainDeps := toPublicDeps(deps)
 
 	// POST /v2/account/inquiries
@@ -253,11 +311,57 @@ func setupTransactionRoutes(r chi.Router, deps *Dependencies) {
 	r.Post("/orders/inquiries", public.HandleOrderInquiry(mainDeps))
 
 	// POST /v2/orders
-	r.Post("/orders", public.HandleCreateOrder(mainDeps))
+	r.With(deps.Idempotency.Idempotent).Post("/orders", public.HandleCreateOrder(mainDeps))
+}
+
+func setupGuestOrderRoutes(r chi.Router, deps *Dependencies) {
+	mainDeps := toPublicDeps(deps)
+
+	r.Group(func(r chi.Router) {
+		r.Use(deps.RateLimiter.Limit(middleware.RateLimitGroupAuth))
+
+		// POST /v2/guest/orders/otp
+		r.Post("/otp", public.HandleGuestOrderOTP(mainDeps))
+
+		// POST /v2/guest/orders/verify
+		r.Post("/verify", public.HandleGuestOrderVerify(mainDeps))
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(public.GuestOrderAuth(mainDeps))
+		r.Use(deps.RateLimiter.Limit(middleware.RateLimitGroupPublic))
+
+		// GET /v2/guest/orders
+		r.Get("/", public.HandleGuestOrders(mainDeps))
+
+		// GET /v2/guest/orders/{invoiceNumber}
+		r.Get("/{invoiceNumber}", public.HandleGuestOrder(mainDeps))
+
+		// POST /v2/guest/orders/claim
+		r.With(deps.AuthMiddleware.RequireAuth).Post("/claim", public.HandleClaimGuestOrders(mainDeps))
+	})
+}
+
+func setupPartnerRoutes(r chi.Router, deps *Dependencies) {
+	mainDeps := toPublicDeps(deps)
+	r.Use(public.PartnerAuth(mainDeps))
+	r.Use(deps.RateLimiter.Limit(middleware.RateLimitGroupPartner))
+
+	// GET /v2/h2h/balance
+	r.Get("/balance", public.HandlePartnerBalance(mainDeps))
+
+	// GET /v2/h2h/price-list
+	r.Get("/price-list", public.HandlePartnerPriceList(mainDeps))
+
+	// POST /v2/h2h/orders
+	r.Post("/orders", public.HandlePartnerCreateOrder(mainDeps))
+
+	// GET /v2/h2h/orders/{refId}
+	r.Get("/orders/{refId}", public.HandlePartnerGetOrder(mainDeps))
 }
 
 func setupAdminAuthRoutes(r chi.Router, deps *Dependencies) {
-	r.Use(deps.RateLimiter.IPRateLimit(middleware.AuthRateLimit))
+	r.Use(deps.RateLimiter.Limit(middleware.RateLimitGroupAuth))
 
 	// POST /admin/v2/auth/login
 	r.Post("/login", admin.HandleAdminLogin(toAdminDeps(deps)))
@@ -276,7 +380,7 @@ func setupAdminAuthRoutes(r chi.Router, deps *Dependencies) {
 }
 
 func setupAdminRoutes(r chi.Router, deps *Dependencies) {
-	r.Use(deps.RateLimiter.RateLimit(middleware.AdminRateLimit))
+	r.Use(deps.RateLimiter.Limit(middleware.RateLimitGroupAdmin))
 
 	// Admin Management
 	r.Route("/admins", func(r chi.Router) {
@@ -296,12 +400,29 @@ func setupAdminRoutes(r chi.Router, deps *Dependencies) {
 	// Providers
 	r.Route("/providers", func(r chi.Router) {
 		r.With(deps.AuthMiddleware.RequirePermission("provider:read")).Get("/", admin.HandleGetProviders(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("provider:read")).Get("/types", admin.HandleGetProviderTypes(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("provider:update")).Post("/reload", admin.HandleReloadProviders(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("provider:read")).Get("/balances", admin.HandleGetProviderBalances(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("provider:update")).Post("/balances/refresh", admin.HandleRefreshProviderBalances(toAdminDeps(deps)))
 		r.With(deps.AuthMiddleware.RequirePermission("provider:read")).Get("/{providerId}", admin.HandleGetProvider(toAdminDeps(deps)))
 		r.With(deps.AuthMiddleware.RequirePermission("provider:create")).Post("/", admin.HandleCreateProvider(toAdminDeps(deps)))
 		r.With(deps.AuthMiddleware.RequirePermission("provider:update")).Put("/{providerId}", admin.HandleUpdateProvider(toAdminDeps(deps)))
 		r.With(deps.AuthMiddleware.RequirePermission("provider:delete")).Delete("/{providerId}", admin.HandleDeleteProvider(toAdminDeps(deps)))
 		r.With(deps.AuthMiddleware.RequirePermission("provider:update")).Post("/{providerId}/test", admin.HandleTestProvider(toAdminDeps(deps)))
 		r.With(deps.AuthMiddleware.RequirePermission("sku:sync")).Post("/{providerId}/sync", admin.HandleSyncProvider(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("provider:read")).Get("/{providerId}/balance-history", admin.HandleGetProviderBalanceHistory(toAdminDeps(deps)))
+	})
+
+	// Payment Gateways
+	r.Route("/payment-gateways", func(r chi.Router) {
+		r.With(deps.AuthMiddleware.RequirePermission("gateway:read")).Get("/", admin.HandleGetPaymentGateways(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("gateway:read")).Get("/types", admin.HandleGetPaymentGatewayTypes(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("gateway:update")).Post("/reload", admin.HandleReloadPaymentGateways(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("gateway:read")).Get("/{gatewayId}", admin.HandleGetPaymentGateway(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("gateway:create")).Post("/", admin.HandleCreatePaymentGateway(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("gateway:update")).Put("/{gatewayId}", admin.HandleUpdatePaymentGateway(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("gateway:delete")).Delete("/{gatewayId}", admin.HandleDeletePaymentGateway(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("gateway:update")).Post("/{gatewayId}/test", admin.HandleTestPaymentGateway(toAdminDeps(deps)))
 	})
 
 	// Payment Channels
@@ -325,6 +446,7 @@ func setupAdminRoutes(r chi.Router, deps *Dependencies) {
 
 	// Products
 	r.Route("/products", func(r chi.Router) {
+		r.Use(deps.CatalogCache.InvalidateOnSuccess(middleware.CacheProducts, middleware.CacheSKUs))
 		r.With(deps.AuthMiddleware.RequirePermission("product:read")).Get("/", admin.HandleAdminGetProducts(toAdminDeps(deps)))
 		r.With(deps.AuthMiddleware.RequirePermission("product:read")).Get("/{productId}", admin.HandleAdminGetProduct(toAdminDeps(deps)))
 		r.With(deps.AuthMiddleware.RequirePermission("product:create")).Post("/", admin.HandleCreateProduct(toAdminDeps(deps)))
@@ -336,6 +458,7 @@ func setupAdminRoutes(r chi.Router, deps *Dependencies) {
 
 	// Categories
 	r.Route("/categories", func(r chi.Router) {
+		r.Use(deps.CatalogCache.InvalidateOnSuccess(middleware.CacheCategories, middleware.CacheProducts))
 		r.With(deps.AuthMiddleware.RequirePermission("product:read")).Get("/", admin.HandleAdminGetCategories(toAdminDeps(deps)))
 		r.With(deps.AuthMiddleware.RequirePermission("product:create")).Post("/", admin.HandleCreateCategory(toAdminDeps(deps)))
 		r.With(deps.AuthMiddleware.RequirePermission("product:update")).Put("/{categoryId}", admin.HandleUpdateCategory(toAdminDeps(deps)))
@@ -344,6 +467,7 @@ func setupAdminRoutes(r chi.Router, deps *Dependencies) {
 
 	// Sections
 	r.Route("/sections", func(r chi.Router) {
+		r.Use(deps.CatalogCache.InvalidateOnSuccess(middleware.CacheSKUs))
 		r.With(deps.AuthMiddleware.RequirePermission("product:read")).Get("/", admin.HandleAdminGetSections(toAdminDeps(deps)))
 		r.With(deps.AuthMiddleware.RequirePermission("product:create")).Post("/", admin.HandleCreateSection(toAdminDeps(deps)))
 		r.With(deps.AuthMiddleware.RequirePermission("product:update")).Put("/{sectionId}", admin.HandleUpdateSection(toAdminDeps(deps)))
@@ -353,6 +477,7 @@ func setupAdminRoutes(r chi.Router, deps *Dependencies) {
 
 	// SKUs
 	r.Route("/skus", func(r chi.Router) {
+		r.Use(deps.CatalogCache.InvalidateOnSuccess(middleware.CacheSKUs))
 		r.With(deps.AuthMiddleware.RequirePermission("sku:read")).Get("/", admin.HandleAdminGetSKUs(toAdminDeps(deps)))
 		r.With(deps.AuthMiddleware.RequirePermission("sku:read")).Get("/images", admin.HandleAdminGetSKUImages(toAdminDeps(deps)))
 		r.With(deps.AuthMiddleware.RequirePermission("sku:read")).Get("/{skuId}", admin.HandleAdminGetSKU(toAdminDeps(deps)))
@@ -361,6 +486,14 @@ func setupAdminRoutes(r chi.Router, deps *Dependencies) {
 		r.With(deps.AuthMiddleware.RequirePermission("sku:delete")).Delete("/{skuId}", admin.HandleDeleteSKU(toAdminDeps(deps)))
 		r.With(deps.AuthMiddleware.RequirePermission("sku:update")).Put("/bulk-price", admin.HandleBulkUpdatePrice(toAdminDeps(deps)))
 		r.With(deps.AuthMiddleware.RequirePermission("sku:sync")).Post("/sync", admin.HandleSyncSKUs(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("sku:read")).Get("/price-history", admin.HandleGetPriceHistory(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("sku:read")).Get("/price-watcher", admin.HandleGetPriceWatcher(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("sku:sync")).Post("/price-watcher/run", admin.HandleRunPriceWatcher(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("sku:read")).Get("/{skuId}/providers", admin.HandleGetSKUProviders(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("sku:update")).Post("/{skuId}/providers", admin.HandleCreateSKUProvider(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("sku:update")).Put("/{skuId}/providers/{mappingId}", admin.HandleUpdateSKUProvider(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("sku:update")).Delete("/{skuId}/providers/{mappingId}", admin.HandleDeleteSKUProvider(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("sku:update")).Put("/{skuId}/routing", admin.HandleUpdateSKURouting(toAdminDeps(deps)))
 	})
 
 	// Transactions
@@ -378,9 +511,19 @@ func setupAdminRoutes(r chi.Router, deps *Dependencies) {
 		r.With(deps.AuthMiddleware.RequirePermission("user:read")).Get("/", admin.HandleAdminGetUsers(toAdminDeps(deps)))
 		r.With(deps.AuthMiddleware.RequirePermission("user:read")).Get("/{userId}", admin.HandleAdminGetUser(toAdminDeps(deps)))
 		r.With(deps.AuthMiddleware.RequirePermission("user:suspend")).Put("/{userId}/status", admin.HandleUpdateUserStatus(toAdminDeps(deps)))
-		r.With(deps.AuthMiddleware.RequirePermission("user:balance")).Post("/{userId}/balance", admin.HandleAdjustBalance(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("user:balance"), deps.Idempotency.Idempotent).Post("/{userId}/balance", admin.HandleAdjustBalance(toAdminDeps(deps)))
 		r.With(deps.AuthMiddleware.RequirePermission("user:read")).Get("/{userId}/transactions", admin.HandleUserTransactions(toAdminDeps(deps)))
 		r.With(deps.AuthMiddleware.RequirePermission("user:read")).Get("/{userId}/mutations", admin.HandleUserMutations(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("user:impersonate")).Post("/{userId}/impersonate", admin.HandleImpersonateUser(toAdminDeps(deps)))
+	})
+
+	// H2H partner API keys
+	r.Route("/partner-keys", func(r chi.Router) {
+		r.With(deps.AuthMiddleware.RequirePermission("user:read")).Get("/", admin.HandleGetPartnerKeys(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("user:update")).Post("/", admin.HandleCreatePartnerKey(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("user:update")).Put("/{keyId}", admin.HandleUpdatePartnerKey(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("user:update")).Post("/{keyId}/rotate-secret", admin.HandleRotatePartnerSecret(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("user:update")).Delete("/{keyId}", admin.HandleRevokePartnerKey(toAdminDeps(deps)))
 	})
 
 	// Promos
@@ -395,6 +538,7 @@ func setupAdminRoutes(r chi.Router, deps *Dependencies) {
 
 	// Banners
 	r.Route("/banners", func(r chi.Router) {
+		r.Use(deps.CatalogCache.InvalidateOnSuccess(middleware.CacheBanners))
 		r.With(deps.AuthMiddleware.RequirePermission("content:banner")).Get("/", admin.HandleAdminGetBanners(toAdminDeps(deps)))
 		r.With(deps.AuthMiddleware.RequirePermission("content:banner")).Post("/", admin.HandleCreateBanner(toAdminDeps(deps)))
 		r.With(deps.AuthMiddleware.RequirePermission("content:banner")).Put("/{bannerId}", admin.HandleUpdateBanner(toAdminDeps(deps)))
@@ -421,6 +565,7 @@ func setupAdminRoutes(r chi.Router, deps *Dependencies) {
 	r.Route("/invoices", func(r chi.Router) {
 		r.With(deps.AuthMiddleware.RequirePermission("transaction:read")).Get("/", admin.HandleAdminGetInvoices(toAdminDeps(deps)))
 		r.With(deps.AuthMiddleware.RequirePermission("transaction:read")).Get("/search", admin.HandleSearchInvoice(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("transaction:read")).Get("/{invoiceNumber}/pdf", admin.HandleDownloadInvoicePDF(toAdminDeps(deps)))
 		r.With(deps.AuthMiddleware.RequirePermission("transaction:update")).Post("/{invoiceNumber}/send-email", admin.HandleSendInvoiceEmail(toAdminDeps(deps)))
 	})
 
@@ -435,9 +580,55 @@ func setupAdminRoutes(r chi.Router, deps *Dependencies) {
 		r.With(deps.AuthMiddleware.RequirePermission("report:export")).Get("/export/{exportId}", admin.HandleGetExportStatus(toAdminDeps(deps)))
 	})
 
+	// Email Outbox
+	r.Route("/email-outbox", func(r chi.Router) {
+		r.With(deps.AuthMiddleware.RequirePermission("transaction:read")).Get("/", admin.HandleGetEmailOutbox(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("transaction:read")).Get("/{id}", admin.HandleGetEmailOutboxMessage(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("transaction:update")).Post("/{id}/retry", admin.HandleRetryEmailOutbox(toAdminDeps(deps)))
+	})
+
+	// Outbound Webhooks
+	r.Route("/webhooks", func(r chi.Router) {
+		r.With(deps.AuthMiddleware.RequirePermission("setting:read")).Get("/subscribers", admin.HandleGetWebhookSubscribers(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("setting:update")).Post("/subscribers", admin.HandleCreateWebhookSubscriber(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("setting:update")).Put("/subscribers/{subscriberId}", admin.HandleUpdateWebhookSubscriber(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("setting:update")).Post("/subscribers/{subscriberId}/rotate-secret", admin.HandleRotateWebhookSecret(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("setting:update")).Delete("/subscribers/{subscriberId}", admin.HandleDeleteWebhookSubscriber(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("setting:read")).Get("/events", admin.HandleGetWebhookEvents(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("setting:read")).Get("/deliveries", admin.HandleGetWebhookDeliveries(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("setting:read")).Get("/deliveries/{deliveryId}", admin.HandleGetWebhookDelivery(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("setting:update")).Post("/deliveries/{deliveryId}/resend", admin.HandleResendWebhookDelivery(toAdminDeps(deps)))
+	})
+
+	// Catalogue Cache
+	r.Route("/cache", func(r chi.Router) {
+		r.With(deps.AuthMiddleware.RequirePermission("setting:read")).Get("/stats", admin.HandleGetCacheStats(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("setting:update")).Post("/invalidate", admin.HandleInvalidateCache(toAdminDeps(deps)))
+	})
+
+	// Approvals (maker-checker for sensitive actions)
+	r.Route("/approvals", func(r chi.Router) {
+		r.With(deps.AuthMiddleware.RequirePermission("approval:read")).Get("/", admin.HandleGetApprovals(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("approval:read")).Get("/{approvalId}", admin.HandleGetApproval(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("approval:approve")).Post("/{approvalId}/approve", admin.HandleApproveApproval(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("approval:approve")).Post("/{approvalId}/reject", admin.HandleRejectApproval(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("approval:read")).Post("/{approvalId}/cancel", admin.HandleCancelApproval(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("approval:read")).Post("/{approvalId}/comments", admin.HandleCommentApproval(toAdminDeps(deps)))
+	})
+
+	// Risk evaluations of checkouts and the review queue of held ones
+	r.Route("/risk-evaluations", func(r chi.Router) {
+		r.With(deps.AuthMiddleware.RequirePermission("risk:read")).Get("/", admin.HandleGetRiskEvaluations(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("risk:read")).Get("/{evaluationId}", admin.HandleGetRiskEvaluation(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("risk:review")).Post("/{evaluationId}/release", admin.HandleReleaseRiskEvaluation(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("risk:review")).Post("/{evaluationId}/cancel", admin.HandleCancelRiskEvaluation(toAdminDeps(deps)))
+	})
+
 	// Audit Logs
 	r.Route("/audit-logs", func(r chi.Router) {
 		r.With(deps.AuthMiddleware.RequirePermission("audit:read")).Get("/", admin.HandleGetAuditLogs(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("audit:read")).Get("/export", admin.HandleExportAuditLogs(toAdminDeps(deps)))
+		r.With(deps.AuthMiddleware.RequirePermission("audit:read")).Get("/{logId}", admin.HandleGetAuditLog(toAdminDeps(deps)))
 	})
 
 	// Settings
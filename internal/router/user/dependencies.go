@@ -13,14 +13,30 @@ import (
 
 // Dependencies matches router.Dependencies structure
 type Dependencies struct {
-	Config          *config.Config
-	DB              *database.PostgresDB
-	Redis           *database.RedisClient
-	S3              *storage.S3Storage
-	JWTService      utils.JWTService
-	EmailService    *services.EmailService
-	AuthMiddleware  *middleware.AuthMiddleware
-	RateLimiter     *middleware.RateLimiter
-	ProviderManager *provider.Manager
-	PaymentManager  *payment.Manager
+	Config              *config.Config
+	DB                  *database.PostgresDB
+	Redis               *database.RedisClient
+	S3                  *storage.S3Storage
+	JWTService          utils.JWTService
+	EmailService        *services.EmailService
+	NotificationService *services.NotificationService
+	EmailOutbox         *services.EmailOutbox
+	AuthMiddleware      *middleware.AuthMiddleware
+	RateLimiter         *middleware.RateLimiter
+	CatalogCache        *middleware.CatalogCache
+	ProviderManager     *provider.Manager
+	PaymentManager      *payment.Manager
+	PriceWatcher        *services.PriceWatcher
+	BalanceMonitor      *services.BalanceMonitor
+	ProviderRegistry    *services.ProviderRegistry
+	GatewayRegistry     *services.GatewayRegistry
+	InvoiceService      *services.InvoiceService
+	InvoiceStream       *services.InvoiceStream
+	PartnerService      *services.PartnerService
+	Webhooks            *services.WebhookDispatcher
+	Idempotency         *middleware.IdempotencyStore
+	GuestAccess         *services.GuestAccess
+	Audit               *services.AuditService
+	Approvals           *services.ApprovalService
+	Risk                *services.RiskEngine
 }
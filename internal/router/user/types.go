@@ -1,11 +1,16 @@
 package user
 
 import (
+	"context"
 	"database/sql"
+	"net/http"
 	"strings"
 	"time"
 
+	"seaply/internal/services"
 	"seaply/internal/utils"
+
+	"github.com/rs/zerolog/log"
 )
 
 // RegisterRequest represents the registration request body
@@ -147,3 +152,64 @@ func nullString(value string) sql.NullString {
 	}
 	return sql.NullString{String: trimmed, Valid: true}
 }
+
+// notifySecurityAlert sends a security alert about a sensitive account change
+func notifySecurityAlert(deps *Dependencies, r *http.Request, userID, action string) {
+	if deps.NotificationService == nil {
+		return
+	}
+
+	ipAddress := extractIPAddress(r)
+	userAgent := r.UserAgent()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var email, firstName, region string
+		err := deps.DB.Pool.QueryRow(ctx, `
+			SELECT email, first_name, COALESCE(primary_region::text, 'ID') FROM users WHERE id = $1
+		`, userID).Scan(&email, &firstName, &region)
+		if err != nil {
+			log.Warn().Err(err).Str("user_id", userID).Msg("Failed to load user for security alert")
+			return
+		}
+
+		deps.NotificationService.NotifySecurityAlert(email, firstName, region, action, ipAddress, userAgent)
+	}()
+}
+
+// queueVerificationEmail writes the verification email to the outbox using db,
+// which may be the transaction that creates the user
+func queueVerificationEmail(ctx context.Context, deps *Dependencies, db services.DBTX, userID, email, firstName, token string) error {
+	if deps.EmailService == nil || deps.EmailOutbox == nil {
+		return nil
+	}
+
+	message, err := deps.EmailService.VerificationEmail(email, firstName, token)
+	if err != nil {
+		return err
+	}
+	message.ReferenceType = "USER"
+	message.ReferenceID = userID
+
+	_, err = deps.EmailOutbox.Enqueue(ctx, db, message)
+	return err
+}
+
+// queuePasswordResetEmail writes the password reset email to the outbox
+func queuePasswordResetEmail(ctx context.Context, deps *Dependencies, userID, email, firstName, token string) error {
+	if deps.EmailService == nil || deps.EmailOutbox == nil {
+		return nil
+	}
+
+	message, err := deps.EmailService.PasswordResetEmail(email, firstName, token)
+	if err != nil {
+		return err
+	}
+	message.ReferenceType = "USER"
+	message.ReferenceID = userID
+
+	_, err = deps.EmailOutbox.Queue(ctx, message)
+	return err
+}
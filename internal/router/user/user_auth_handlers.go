@@ -130,9 +130,18 @@ func HandleRegisterImpl(deps *Dependencies) http.HandlerFunc {
 
 		// Create user with INACTIVE status
 		// Set current_region same as primary_region at registration
+		// The user and the verification email are written in one transaction,
+		// so the email is never lost and never sent for a user that wasn't created
+		tx, err := deps.DB.Pool.Begin(ctx)
+		if err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
+		defer tx.Rollback(ctx)
+
 		var userID string
 		var createdAt time.Time
-		err = deps.DB.Pool.QueryRow(ctx, `
+		err = tx.QueryRow(ctx, `
 			INSERT INTO users (
 				first_name, last_name, email, password_hash,
 				phone_number, status, primary_region, current_region,
@@ -179,6 +188,18 @@ func HandleRegisterImpl(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		// Queue verification email
+		if err := queueVerificationEmail(ctx, deps, tx, userID, req.Email, req.FirstName, verificationToken); err != nil {
+			fmt.Printf("Error queueing verification email: %v\n", err)
+			utils.WriteInternalServerError(w)
+			return
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			utils.WriteInternalServerError(w)
+			return
+		}
+
 		// Store verification token in Redis (30 minutes expiry)
 		tokenKey := deps.Redis.ValidationTokenKey(userID)
 		err = deps.Redis.Set(ctx, tokenKey, verificationToken, 30*time.Minute)
@@ -186,19 +207,6 @@ func HandleRegisterImpl(deps *Dependencies) http.HandlerFunc {
 			// Log error but continue - user can resend verification
 		}
 
-		// Send verification email
-		go func() {
-			emailService := deps.EmailService
-			if emailService != nil {
-				err := emailService.SendVerificationEmail(req.Email, req.FirstName, verificationToken)
-				if err != nil {
-					// Log error but don't fail registration
-					// Error sending email is non-critical, user can resend verification
-					fmt.Printf("Failed to send verification email to %s: %v\n", req.Email, err)
-				}
-			}
-		}()
-
 		// Get currency from region
 		currency := "IDR"
 		var regionCurrency string
@@ -1177,6 +1185,8 @@ func HandleChangePasswordImpl(deps *Dependencies) http.HandlerFunc {
 		// Invalidate user cache
 		_ = deps.Redis.InvalidateUserCache(ctx, userID)
 
+		notifySecurityAlert(deps, r, userID, "Password changed")
+
 		utils.WriteSuccessJSON(w, map[string]string{
 			"message": "Password berhasil diubah. Silakan login kembali dengan password baru.",
 		})
@@ -1297,16 +1307,12 @@ func HandleResendVerificationImpl(deps *Dependencies) http.HandlerFunc {
 			verificationToken = existingToken
 		}
 
-		// Send verification email
-		go func() {
-			emailService := deps.EmailService
-			if emailService != nil {
-				err := emailService.SendVerificationEmail(req.Email, firstName, verificationToken)
-				if err != nil {
-					fmt.Printf("Failed to send verification email to %s: %v\n", req.Email, err)
-				}
-			}
-		}()
+		// Queue verification email
+		if err := queueVerificationEmail(ctx, deps, deps.DB.Pool, userID, req.Email, firstName, verificationToken); err != nil {
+			fmt.Printf("Failed to queue verification email to %s: %v\n", req.Email, err)
+			utils.WriteInternalServerError(w)
+			return
+		}
 
 		utils.WriteSuccessJSON(w, map[string]string{
 			"message": "Link verifikasi telah dikirim ke email Anda.",
@@ -1374,16 +1380,12 @@ func HandleForgotPasswordImpl(deps *Dependencies) http.HandlerFunc {
 			// Log error but continue
 		}
 
-		// Send password reset email
-		go func() {
-			emailService := deps.EmailService
-			if emailService != nil {
-				err := emailService.SendPasswordResetEmail(req.Email, firstName, resetToken)
-				if err != nil {
-					fmt.Printf("Failed to send password reset email to %s: %v\n", req.Email, err)
-				}
-			}
-		}()
+		// Queue password reset email
+		if err := queuePasswordResetEmail(ctx, deps, userID, req.Email, firstName, resetToken); err != nil {
+			fmt.Printf("Failed to queue password reset email to %s: %v\n", req.Email, err)
+			utils.WriteInternalServerError(w)
+			return
+		}
 
 		utils.WriteSuccessJSON(w, map[string]string{
 			"message": "Link reset password telah dikirim ke email Anda.",
@@ -1517,6 +1519,8 @@ func HandleResetPasswordImpl(deps *Dependencies) http.HandlerFunc {
 		// Delete reset token from Redis
 		_ = deps.Redis.Delete(ctx, resetTokenKey)
 
+		notifySecurityAlert(deps, r, userID, "Password reset")
+
 		// Delete verification token if exists
 		tokenKey := deps.Redis.ValidationTokenKey(userID)
 		_ = deps.Redis.Delete(ctx, tokenKey)
@@ -1863,6 +1867,8 @@ func HandleVerifyMFASetupImpl(deps *Dependencies) http.HandlerFunc {
 
 		log.Info().Str("user_id", userID).Msg("MFA enabled successfully")
 
+		notifySecurityAlert(deps, r, userID, "Two-factor authentication enabled")
+
 		utils.WriteSuccessJSON(w, map[string]interface{}{
 			"step":      "SUCCESS",
 			"message":   "MFA has been enabled successfully",
@@ -1957,6 +1963,8 @@ func HandleDisableMFAImpl(deps *Dependencies) http.HandlerFunc {
 
 		log.Info().Str("user_id", userID).Msg("MFA disabled successfully")
 
+		notifySecurityAlert(deps, r, userID, "Two-factor authentication disabled")
+
 		utils.WriteSuccessJSON(w, map[string]interface{}{
 			"message":   "MFA has been disabled",
 			"mfaStatus": "INACTIVE",
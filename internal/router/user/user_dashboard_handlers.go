@@ -10,8 +10,10 @@ import (
 	"strings"
 	"time"
 
+	"seaply/internal/domain"
 	"seaply/internal/middleware"
 	"seaply/internal/payment"
+	"seaply/internal/services"
 	"seaply/internal/utils"
 
 	"github.com/jackc/pgx/v5"
@@ -1307,6 +1309,19 @@ func HandleCreateDepositImpl(deps *Dependencies) http.HandlerFunc {
 			}
 		}
 
+		// Score the deposit for fraud; risky ones need MFA, are reviewed first or refused
+		if !assessDepositRisk(w, r, deps, services.RiskCheck{
+			Kind:      services.RiskKindDeposit,
+			UserID:    userID,
+			IPAddress: extractIPAddress(r),
+			DeviceID:  r.Header.Get("X-Device-ID"),
+			Region:    region,
+			Amount:    totalAmount,
+			Currency:  currency,
+		}) {
+			return
+		}
+
 		// Start database transaction
 		tx, err := deps.DB.Pool.Begin(ctx)
 		if err != nil {
@@ -1315,6 +1330,7 @@ func HandleCreateDepositImpl(deps *Dependencies) http.HandlerFunc {
 				Str("endpoint", "/v2/deposits").
 				Str("error_type", "DB_TRANSACTION_ERROR").
 				Msg("Failed to begin database transaction")
+			middleware.IdempotentRetryable(r.Context())
 			utils.WriteInternalServerError(w)
 			return
 		}
@@ -1397,6 +1413,7 @@ func HandleCreateDepositImpl(deps *Dependencies) http.HandlerFunc {
 				Str("endpoint", "/v2/deposits").
 				Str("error_type", "DEPOSIT_INSERT_ERROR").
 				Msg("Failed to insert deposit")
+			middleware.IdempotentRetryable(r.Context())
 			utils.WriteInternalServerError(w)
 			return
 		}
@@ -1429,6 +1446,7 @@ func HandleCreateDepositImpl(deps *Dependencies) http.HandlerFunc {
 					Str("payment_code", paymentCode).
 					Msg("Payment manager not configured")
 				tx.Rollback(ctx)
+				middleware.IdempotentRetryable(r.Context())
 				utils.WriteErrorJSON(w, http.StatusServiceUnavailable, "PAYMENT_GATEWAY_UNAVAILABLE",
 					"Payment gateway is not available", "Please try again later or use a different payment method")
 				return
@@ -1505,6 +1523,7 @@ func HandleCreateDepositImpl(deps *Dependencies) http.HandlerFunc {
 					Str("invoice_number", invoiceNumber).
 					Msg("Payment gateway call failed")
 				tx.Rollback(ctx)
+				middleware.IdempotentRetryable(r.Context())
 				utils.WriteErrorJSON(w, http.StatusBadGateway, "PAYMENT_GATEWAY_ERROR",
 					"Failed to create payment", paymentErr.Error())
 				return
@@ -1566,6 +1585,7 @@ func HandleCreateDepositImpl(deps *Dependencies) http.HandlerFunc {
 				Str("endpoint", "/v2/deposits").
 				Str("error_type", "DB_COMMIT_ERROR").
 				Msg("Failed to commit deposit transaction")
+			middleware.IdempotentRetryable(r.Context())
 			utils.WriteInternalServerError(w)
 			return
 		}
@@ -1593,52 +1613,62 @@ func HandleCreateDepositImpl(deps *Dependencies) http.HandlerFunc {
 	}
 }
 
-// extractIPAddress extracts IP address from request, removing port if present
-func extractIPAddress(r *http.Request) string {
-	// Check X-Forwarded-For header first
-	xff := r.Header.Get("X-Forwarded-For")
-	if xff != "" {
-		// X-Forwarded-For can contain multiple IPs, take the first one
-		ips := strings.Split(xff, ",")
-		if len(ips) > 0 {
-			ip := strings.TrimSpace(ips[0])
-			// Remove port if present
-			if idx := strings.LastIndex(ip, ":"); idx != -1 {
-				// Check if it's an IPv6 address
-				if strings.Count(ip, ":") > 1 {
-					// IPv6 address, don't remove last colon
-					return ip
-				}
-				return ip[:idx]
-			}
-			return ip
-		}
+// assessDepositRisk scores a deposit with the risk engine. It reports whether the
+// deposit may be created; otherwise the response asking for MFA, refusing it or
+// telling it's under review was written. Deposits aren't refused when the engine fails.
+func assessDepositRisk(w http.ResponseWriter, r *http.Request, deps *Dependencies, check services.RiskCheck) bool {
+	if deps.Risk == nil {
+		return true
 	}
 
-	// Check X-Real-IP header
-	xri := r.Header.Get("X-Real-IP")
-	if xri != "" {
-		// Remove port if present
-		if idx := strings.LastIndex(xri, ":"); idx != -1 {
-			return xri[:idx]
-		}
-		return xri
+	ctx := r.Context()
+	settings, err := deps.Risk.LoadSettings(ctx)
+	if err != nil {
+		log.Error().Err(err).Str("endpoint", "/v2/deposits").Msg("Failed to load risk settings")
+		return true
 	}
+	check.Country = settings.Country(r.Header)
 
-	// Fall back to RemoteAddr
-	// RemoteAddr is in format "IP:port" or "[IPv6]:port"
-	remoteAddr := r.RemoteAddr
-	if idx := strings.LastIndex(remoteAddr, ":"); idx != -1 {
-		// Check if it's an IPv6 address
-		if strings.HasPrefix(remoteAddr, "[") {
-			// IPv6 address format: [::1]:port
-			if closingBracket := strings.LastIndex(remoteAddr, "]"); closingBracket != -1 {
-				return remoteAddr[1:closingBracket]
-			}
+	assessment, err := deps.Risk.Evaluate(ctx, settings, check)
+	if err != nil {
+		log.Error().Err(err).Str("endpoint", "/v2/deposits").Msg("Failed to evaluate deposit risk")
+		return true
+	}
+
+	switch assessment.Action {
+	case services.RiskRequireMFA:
+		utils.WriteErrorJSON(w, http.StatusForbidden, "MFA_REQUIRED",
+			"Please enable two-factor authentication to top up your balance", "")
+		return false
+	case services.RiskBlock:
+		utils.WriteErrorJSON(w, http.StatusForbidden, "CHECKOUT_BLOCKED",
+			"This deposit can't be made", "Please contact customer support")
+		return false
+	case services.RiskHold:
+		// A paid deposit is credited right away, so it's reviewed before it's created
+		if err := deps.Risk.Hold(ctx, deps.DB.Pool, assessment, ""); err != nil {
+			log.Error().Err(err).Str("endpoint", "/v2/deposits").Msg("Failed to hold deposit for risk review")
+			utils.WriteInternalServerError(w)
+			return false
 		}
-		return remoteAddr[:idx]
+		deps.Risk.NotifyHold(assessment, "Deposit of user "+check.UserID)
+
+		utils.WriteJSON(w, http.StatusAccepted, domain.SuccessResponse{Data: map[string]interface{}{
+			"review": map[string]interface{}{
+				"id":      assessment.ID,
+				"status":  services.RiskReviewPending,
+				"message": "Your deposit is under review, you can top up again once it's approved",
+			},
+		}})
+		return false
 	}
-	return remoteAddr
+	return true
+}
+
+// extractIPAddress returns the client IP resolved by the RealIP middleware from
+// trusted proxies only, so forwarding headers sent by the client are ignored
+func extractIPAddress(r *http.Request) string {
+	return middleware.ClientIP(r)
 }
 
 // getGatewayForChannel returns gateway name for payment channel code
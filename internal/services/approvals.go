@@ -0,0 +1,671 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"seaply/internal/utils"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// Actions that may need the approval of a second admin
+const (
+	ApprovalAdjustBalance     = "ADJUST_BALANCE"
+	ApprovalRefundTransaction = "REFUND_TRANSACTION"
+	ApprovalRefundDeposit     = "REFUND_DEPOSIT"
+	ApprovalConfirmDeposit    = "CONFIRM_DEPOSIT"
+	ApprovalManualProcess     = "MANUAL_PROCESS"
+)
+
+// Approval request statuses. EXECUTING is held while the approved action runs, so
+// an action is never executed twice.
+const (
+	ApprovalPending   = "PENDING"
+	ApprovalExecuting = "EXECUTING"
+	ApprovalApproved  = "APPROVED"
+	ApprovalRejected  = "REJECTED"
+	ApprovalCancelled = "CANCELLED"
+	ApprovalExpired   = "EXPIRED"
+	ApprovalFailed    = "FAILED"
+)
+
+// approvalSettingKeys maps the settings keys holding the thresholds to their action
+var approvalSettingKeys = map[string]string{
+	"adjustBalance":     ApprovalAdjustBalance,
+	"refundTransaction": ApprovalRefundTransaction,
+	"refundDeposit":     ApprovalRefundDeposit,
+	"confirmDeposit":    ApprovalConfirmDeposit,
+	"manualProcess":     ApprovalManualProcess,
+}
+
+// ApprovalExecutionTimeout is how long an approved action may take. A request
+// EXECUTING for longer was interrupted before its action committed, see Execute.
+const ApprovalExecutionTimeout = 10 * time.Minute
+
+var (
+	ErrApprovalNotPending   = errors.New("approval request is no longer pending")
+	ErrApprovalExpired      = errors.New("approval request has expired")
+	ErrApprovalSelfDecision = errors.New("an approval request must be decided by another admin")
+	ErrApprovalNotRequester = errors.New("only the requester can cancel an approval request")
+)
+
+// ApprovalSettings configures the four-eyes workflow. Thresholds are per action and
+// currency, an action reaching its threshold needs a second admin. Actions without
+// thresholds never do; a currency missing from an action's thresholds always does.
+type ApprovalSettings struct {
+	Enabled    bool
+	Expiry     time.Duration
+	Thresholds map[string]map[string]int64
+}
+
+// ApprovalAdmin is the requester or the approver of an approval request
+type ApprovalAdmin struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// ApprovalComment is a comment on an approval request
+type ApprovalComment struct {
+	ID        string        `json:"id"`
+	Admin     ApprovalAdmin `json:"admin"`
+	Comment   string        `json:"comment"`
+	CreatedAt time.Time     `json:"createdAt"`
+}
+
+// ApprovalRequest is a sensitive admin action waiting for, or decided by, a second admin
+type ApprovalRequest struct {
+	ID          string            `json:"id"`
+	Action      string            `json:"action"`
+	Resource    string            `json:"resource"`
+	ResourceID  string            `json:"resourceId"`
+	Amount      int64             `json:"amount"`
+	Currency    string            `json:"currency"`
+	Summary     string            `json:"summary"`
+	Payload     json.RawMessage   `json:"payload"`
+	Status      string            `json:"status"`
+	RequestedBy ApprovalAdmin     `json:"requestedBy"`
+	DecidedBy   *ApprovalAdmin    `json:"decidedBy"`
+	DecidedAt   *time.Time        `json:"decidedAt"`
+	Result      json.RawMessage   `json:"result,omitempty"`
+	Error       string            `json:"error,omitempty"`
+	ExpiresAt   time.Time         `json:"expiresAt"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	UpdatedAt   time.Time         `json:"updatedAt"`
+	Comments    []ApprovalComment `json:"comments,omitempty"`
+}
+
+// NewApproval describes an action to hold for approval
+type NewApproval struct {
+	Action      string
+	Resource    string
+	ResourceID  string
+	Amount      int64
+	Currency    string
+	Summary     string
+	Payload     interface{}
+	RequestedBy string
+	Comment     string
+}
+
+// ApprovalService holds sensitive admin actions above a threshold until a second
+// admin approves them
+type ApprovalService struct {
+	pool          *pgxpool.Pool
+	notifications *NotificationService
+}
+
+// NewApprovalService creates an approval service
+func NewApprovalService(pool *pgxpool.Pool, notifications *NotificationService) *ApprovalService {
+	return &ApprovalService{pool: pool, notifications: notifications}
+}
+
+// LoadSettings reads the approval settings, falling back to defaults
+func (s *ApprovalService) LoadSettings(ctx context.Context) (ApprovalSettings, error) {
+	settings := ApprovalSettings{
+		Enabled:    true,
+		Expiry:     24 * time.Hour,
+		Thresholds: map[string]map[string]int64{},
+	}
+
+	rows, err := s.pool.Query(ctx, `SELECT key, value FROM settings WHERE category = 'approval'`)
+	if err != nil {
+		return settings, fmt.Errorf("failed to load approval settings: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			return settings, fmt.Errorf("failed to scan approval setting: %w", err)
+		}
+
+		switch key {
+		case "enabled":
+			_ = json.Unmarshal(value, &settings.Enabled)
+		case "expiryHours":
+			var hours int
+			if json.Unmarshal(value, &hours) == nil && hours > 0 {
+				settings.Expiry = time.Duration(hours) * time.Hour
+			}
+		default:
+			action, ok := approvalSettingKeys[key]
+			if !ok {
+				continue
+			}
+			thresholds := map[string]int64{}
+			if err := json.Unmarshal(value, &thresholds); err != nil {
+				log.Warn().Err(err).Str("key", key).Msg("Invalid approval threshold setting")
+				continue
+			}
+			settings.Thresholds[action] = thresholds
+		}
+	}
+
+	return settings, rows.Err()
+}
+
+// Requires reports whether an action on the given amount needs a second admin
+func (s *ApprovalSettings) Requires(action string, amount int64, currency string) bool {
+	if !s.Enabled {
+		return false
+	}
+	thresholds, ok := s.Thresholds[action]
+	if !ok {
+		return false
+	}
+	threshold, ok := thresholds[strings.ToUpper(currency)]
+	return !ok || amount >= threshold
+}
+
+// Create holds an action for approval and notifies the approvers. audit, when set,
+// records the request in the same transaction, so nothing is held without it.
+func (s *ApprovalService) Create(ctx context.Context, settings ApprovalSettings, a NewApproval, audit func(tx pgx.Tx, id string) error) (*ApprovalRequest, error) {
+	payload, err := json.Marshal(a.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("encode approval payload: %w", err)
+	}
+
+	var id string
+	err = pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if err := tx.QueryRow(ctx, `
+			INSERT INTO approval_requests (
+				action, resource, resource_id, amount, currency, summary, payload,
+				status, requested_by, expires_at
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, 'PENDING', $8, NOW() + $9::interval)
+			RETURNING id
+		`, a.Action, a.Resource, a.ResourceID, a.Amount, strings.ToUpper(a.Currency), a.Summary, payload,
+			a.RequestedBy, strconv.Itoa(int(settings.Expiry.Seconds()))+" seconds").Scan(&id); err != nil {
+			return err
+		}
+		if err := addApprovalComment(ctx, tx, id, a.RequestedBy, a.Comment); err != nil {
+			return err
+		}
+		if audit != nil {
+			return audit(tx, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create approval request: %w", err)
+	}
+
+	request, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.notifyApprovers(ctx, request, a.Comment)
+	return request, nil
+}
+
+// Claim moves a pending request to EXECUTING on behalf of the approver. The caller
+// then runs the action with Execute.
+func (s *ApprovalService) Claim(ctx context.Context, id, adminID, comment string) (*ApprovalRequest, error) {
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if err := s.decidable(ctx, tx, id, adminID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `
+			UPDATE approval_requests
+			SET status = 'EXECUTING', decided_by = $2, decided_at = NOW(), updated_at = NOW()
+			WHERE id = $1
+		`, id, adminID); err != nil {
+			return err
+		}
+		return addApprovalComment(ctx, tx, id, adminID, comment)
+	})
+	if err != nil {
+		s.expireIfStale(ctx, id, err)
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+// Execute runs the action of a claimed request and notifies the requester. The action
+// commits in the transaction that marks the request APPROVED with its result, so it
+// takes effect at most once per request: a request that is no longer EXECUTING is not
+// run again, and one left EXECUTING by a crash changed nothing. When run fails the
+// request is marked FAILED and returned with the error of run.
+func (s *ApprovalService) Execute(ctx context.Context, id string, run func(tx pgx.Tx) (interface{}, error)) (*ApprovalRequest, error) {
+	runErr := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		var status string
+		if err := tx.QueryRow(ctx, `SELECT status FROM approval_requests WHERE id = $1 FOR UPDATE`, id).Scan(&status); err != nil {
+			return err
+		}
+		if status != ApprovalExecuting {
+			return ErrApprovalNotPending
+		}
+
+		result, err := run(tx)
+		if err != nil {
+			return err
+		}
+		payload, err := json.Marshal(result)
+		if err != nil {
+			return fmt.Errorf("encode approval result: %w", err)
+		}
+		_, err = tx.Exec(ctx, `
+			UPDATE approval_requests SET status = 'APPROVED', result = $2, updated_at = NOW() WHERE id = $1
+		`, id, payload)
+		return err
+	})
+	if errors.Is(runErr, ErrApprovalNotPending) {
+		return nil, runErr
+	}
+	if runErr != nil {
+		if err := s.Fail(ctx, id, runErr.Error()); err != nil {
+			return nil, err
+		}
+	}
+
+	request, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.notifyRequester(ctx, request, request.Error)
+	return request, runErr
+}
+
+// Fail marks a request whose action could not run as FAILED
+func (s *ApprovalService) Fail(ctx context.Context, id, reason string) error {
+	if _, err := s.pool.Exec(ctx, `
+		UPDATE approval_requests SET status = 'FAILED', error = $2, updated_at = NOW()
+		WHERE id = $1 AND status = 'EXECUTING'
+	`, id, reason); err != nil {
+		return fmt.Errorf("fail approval request: %w", err)
+	}
+	return nil
+}
+
+// Reject declines a pending request and notifies the requester
+func (s *ApprovalService) Reject(ctx context.Context, id, adminID, comment string) (*ApprovalRequest, error) {
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if err := s.decidable(ctx, tx, id, adminID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `
+			UPDATE approval_requests
+			SET status = 'REJECTED', decided_by = $2, decided_at = NOW(), updated_at = NOW()
+			WHERE id = $1
+		`, id, adminID); err != nil {
+			return err
+		}
+		return addApprovalComment(ctx, tx, id, adminID, comment)
+	})
+	if err != nil {
+		s.expireIfStale(ctx, id, err)
+		return nil, err
+	}
+
+	request, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.notifyRequester(ctx, request, comment)
+	return request, nil
+}
+
+// Cancel withdraws a pending request, only its requester can
+func (s *ApprovalService) Cancel(ctx context.Context, id, adminID, comment string) (*ApprovalRequest, error) {
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		var status, requestedBy string
+		err := tx.QueryRow(ctx, `
+			SELECT status, COALESCE(requested_by::text, '') FROM approval_requests WHERE id = $1 FOR UPDATE
+		`, id).Scan(&status, &requestedBy)
+		if err != nil {
+			return err
+		}
+		if status != ApprovalPending {
+			return ErrApprovalNotPending
+		}
+		if requestedBy != adminID {
+			return ErrApprovalNotRequester
+		}
+		if _, err := tx.Exec(ctx, `
+			UPDATE approval_requests SET status = 'CANCELLED', updated_at = NOW() WHERE id = $1
+		`, id); err != nil {
+			return err
+		}
+		return addApprovalComment(ctx, tx, id, adminID, comment)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+// Comment adds a comment to a request
+func (s *ApprovalService) Comment(ctx context.Context, id, adminID, comment string) (*ApprovalRequest, error) {
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		var exists bool
+		if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM approval_requests WHERE id = $1)`, id).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return pgx.ErrNoRows
+		}
+		return addApprovalComment(ctx, tx, id, adminID, comment)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+// decidable locks a request and checks that adminID may approve or reject it
+func (s *ApprovalService) decidable(ctx context.Context, tx pgx.Tx, id, adminID string) error {
+	var status, requestedBy string
+	var expiresAt time.Time
+	err := tx.QueryRow(ctx, `
+		SELECT status, COALESCE(requested_by::text, ''), expires_at
+		FROM approval_requests
+		WHERE id = $1
+		FOR UPDATE
+	`, id).Scan(&status, &requestedBy, &expiresAt)
+	if err != nil {
+		return err
+	}
+	if status != ApprovalPending {
+		return ErrApprovalNotPending
+	}
+	if !time.Now().Before(expiresAt) {
+		return ErrApprovalExpired
+	}
+	if requestedBy == adminID {
+		return ErrApprovalSelfDecision
+	}
+	return nil
+}
+
+// expireIfStale marks a request EXPIRED once deciding it failed for that reason
+func (s *ApprovalService) expireIfStale(ctx context.Context, id string, err error) {
+	if !errors.Is(err, ErrApprovalExpired) {
+		return
+	}
+	if _, err := s.pool.Exec(ctx, `
+		UPDATE approval_requests SET status = 'EXPIRED', updated_at = NOW()
+		WHERE id = $1 AND status = 'PENDING'
+	`, id); err != nil {
+		log.Error().Err(err).Str("approval_id", id).Msg("Failed to expire approval request")
+	}
+}
+
+// ExpireStale marks pending requests past their expiry as EXPIRED
+func (s *ApprovalService) ExpireStale(ctx context.Context) (int64, error) {
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE approval_requests SET status = 'EXPIRED', updated_at = NOW()
+		WHERE status = 'PENDING' AND expires_at <= NOW()
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("expire approval requests: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// FailInterrupted marks requests EXECUTING for longer than ApprovalExecutionTimeout as
+// FAILED. Their approval was interrupted before the action committed, so nothing changed
+// and the requester can ask again.
+func (s *ApprovalService) FailInterrupted(ctx context.Context) (int64, error) {
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE approval_requests
+		SET status = 'FAILED', error = 'Execution was interrupted, nothing was changed', updated_at = NOW()
+		WHERE status = 'EXECUTING' AND decided_at < NOW() - $1::interval
+	`, strconv.Itoa(int(ApprovalExecutionTimeout.Seconds()))+" seconds")
+	if err != nil {
+		return 0, fmt.Errorf("fail interrupted approval requests: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// Start expires stale requests and fails interrupted ones on the given interval until ctx is done
+func (s *ApprovalService) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if n, err := s.ExpireStale(ctx); err != nil {
+					log.Error().Err(err).Msg("Failed to expire approval requests")
+				} else if n > 0 {
+					log.Info().Int64("count", n).Msg("Expired approval requests")
+				}
+				if n, err := s.FailInterrupted(ctx); err != nil {
+					log.Error().Err(err).Msg("Failed to fail interrupted approval requests")
+				} else if n > 0 {
+					log.Warn().Int64("count", n).Msg("Failed approval requests interrupted while executing")
+				}
+			}
+		}
+	}()
+}
+
+const approvalColumns = `
+	ar.id, ar.action, ar.resource, ar.resource_id, ar.amount, ar.currency, ar.summary,
+	ar.payload, ar.status, ar.result, COALESCE(ar.error, ''),
+	COALESCE(ar.requested_by::text, ''), COALESCE(ra.name, ''), COALESCE(ra.email, ''),
+	ar.decided_by::text, COALESCE(da.name, ''), COALESCE(da.email, ''), ar.decided_at,
+	ar.expires_at, ar.created_at, ar.updated_at`
+
+const approvalFrom = `
+	FROM approval_requests ar
+	LEFT JOIN admins ra ON ar.requested_by = ra.id
+	LEFT JOIN admins da ON ar.decided_by = da.id`
+
+func scanApproval(row pgx.Row) (*ApprovalRequest, error) {
+	var a ApprovalRequest
+	var payload, result []byte
+	var decidedByID *string
+	var decidedByName, decidedByEmail string
+	err := row.Scan(&a.ID, &a.Action, &a.Resource, &a.ResourceID, &a.Amount, &a.Currency, &a.Summary,
+		&payload, &a.Status, &result, &a.Error,
+		&a.RequestedBy.ID, &a.RequestedBy.Name, &a.RequestedBy.Email,
+		&decidedByID, &decidedByName, &decidedByEmail, &a.DecidedAt,
+		&a.ExpiresAt, &a.CreatedAt, &a.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	a.Payload = payload
+	if len(result) > 0 {
+		a.Result = result
+	}
+	if decidedByID != nil {
+		a.DecidedBy = &ApprovalAdmin{ID: *decidedByID, Name: decidedByName, Email: decidedByEmail}
+	}
+	return &a, nil
+}
+
+// Get returns a request with its comments, pgx.ErrNoRows when it doesn't exist
+func (s *ApprovalService) Get(ctx context.Context, id string) (*ApprovalRequest, error) {
+	request, err := scanApproval(s.pool.QueryRow(ctx, `SELECT `+approvalColumns+approvalFrom+` WHERE ar.id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT c.id, COALESCE(c.admin_id::text, ''), COALESCE(a.name, ''), COALESCE(a.email, ''), c.comment, c.created_at
+		FROM approval_comments c
+		LEFT JOIN admins a ON c.admin_id = a.id
+		WHERE c.approval_id = $1
+		ORDER BY c.created_at
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("query approval comments: %w", err)
+	}
+	defer rows.Close()
+
+	request.Comments = []ApprovalComment{}
+	for rows.Next() {
+		var c ApprovalComment
+		if err := rows.Scan(&c.ID, &c.Admin.ID, &c.Admin.Name, &c.Admin.Email, &c.Comment, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan approval comment: %w", err)
+		}
+		request.Comments = append(request.Comments, c)
+	}
+	return request, rows.Err()
+}
+
+// List returns a page of requests, newest first, and the total count
+func (s *ApprovalService) List(ctx context.Context, status, action, resourceID string, limit, offset int) ([]ApprovalRequest, int, error) {
+	where := " WHERE 1=1"
+	args := []interface{}{}
+	if status != "" {
+		args = append(args, strings.ToUpper(status))
+		where += " AND ar.status = $" + strconv.Itoa(len(args))
+	}
+	if action != "" {
+		args = append(args, strings.ToUpper(action))
+		where += " AND ar.action = $" + strconv.Itoa(len(args))
+	}
+	if resourceID != "" {
+		args = append(args, resourceID)
+		where += " AND ar.resource_id = $" + strconv.Itoa(len(args))
+	}
+
+	var total int
+	if err := s.pool.QueryRow(ctx, `SELECT COUNT(*) FROM approval_requests ar`+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("count approval requests: %w", err)
+	}
+
+	args = append(args, limit, offset)
+	rows, err := s.pool.Query(ctx, `SELECT `+approvalColumns+approvalFrom+where+`
+		ORDER BY ar.created_at DESC
+		LIMIT $`+strconv.Itoa(len(args)-1)+` OFFSET $`+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query approval requests: %w", err)
+	}
+	defer rows.Close()
+
+	requests := []ApprovalRequest{}
+	for rows.Next() {
+		request, err := scanApproval(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scan approval request: %w", err)
+		}
+		requests = append(requests, *request)
+	}
+	return requests, total, rows.Err()
+}
+
+func addApprovalComment(ctx context.Context, tx pgx.Tx, id, adminID, comment string) error {
+	comment = strings.TrimSpace(comment)
+	if comment == "" {
+		return nil
+	}
+	_, err := tx.Exec(ctx, `
+		INSERT INTO approval_comments (approval_id, admin_id, comment) VALUES ($1, $2, $3)
+	`, id, nullableString(adminID), comment)
+	return err
+}
+
+// notifyApprovers tells the admins allowed to decide a request about it, by email,
+// and the ops channel on Telegram
+func (s *ApprovalService) notifyApprovers(ctx context.Context, request *ApprovalRequest, comment string) {
+	if s.notifications == nil {
+		return
+	}
+
+	data := approvalNotificationData(request, comment)
+	s.notifications.Notify(Notification{
+		Event:         EventApprovalRequest,
+		Language:      "en",
+		Channels:      []Channel{ChannelTelegram},
+		ReferenceType: "APPROVAL",
+		ReferenceID:   request.ID,
+		Data:          data,
+	})
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT DISTINCT a.email
+		FROM admins a
+		JOIN role_permissions rp ON rp.role_id = a.role_id
+		JOIN permissions p ON p.id = rp.permission_id
+		WHERE p.code = 'approval:approve' AND a.status = 'ACTIVE' AND a.id::text <> $1
+	`, request.RequestedBy.ID)
+	if err != nil {
+		log.Error().Err(err).Str("approval_id", request.ID).Msg("Failed to load approvers")
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			continue
+		}
+		s.notifications.Notify(Notification{
+			Event:         EventApprovalRequest,
+			Language:      "en",
+			Email:         email,
+			Channels:      []Channel{ChannelEmail},
+			ReferenceType: "APPROVAL",
+			ReferenceID:   request.ID,
+			Data:          data,
+		})
+	}
+}
+
+// notifyRequester tells the requester how their request was decided
+func (s *ApprovalService) notifyRequester(ctx context.Context, request *ApprovalRequest, comment string) {
+	if s.notifications == nil || request.RequestedBy.Email == "" {
+		return
+	}
+
+	s.notifications.Notify(Notification{
+		Event:         EventApprovalDecision,
+		Language:      "en",
+		Email:         request.RequestedBy.Email,
+		ReferenceType: "APPROVAL",
+		ReferenceID:   request.ID,
+		Data:          approvalNotificationData(request, comment),
+	})
+}
+
+func approvalNotificationData(request *ApprovalRequest, comment string) map[string]interface{} {
+	decidedBy := ""
+	if request.DecidedBy != nil {
+		decidedBy = request.DecidedBy.Name
+	}
+	return map[string]interface{}{
+		"ID":          request.ID,
+		"Action":      request.Action,
+		"Summary":     request.Summary,
+		"Amount":      utils.FormatCurrency(float64(request.Amount), request.Currency),
+		"Status":      request.Status,
+		"RequestedBy": request.RequestedBy.Name,
+		"DecidedBy":   decidedBy,
+		"Comment":     comment,
+		"ExpiresAt":   request.ExpiresAt.Format("02 Jan 2006 15:04 MST"),
+	}
+}
@@ -0,0 +1,43 @@
+package services
+
+import "testing"
+
+func TestApprovalSettingsRequires(t *testing.T) {
+	settings := ApprovalSettings{
+		Enabled: true,
+		Thresholds: map[string]map[string]int64{
+			ApprovalAdjustBalance:     {"IDR": 1000000, "MYR": 300},
+			ApprovalRefundTransaction: {"IDR": 500000},
+			ApprovalManualProcess:     {"IDR": 0},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		disabled bool
+		action   string
+		amount   int64
+		currency string
+		want     bool
+	}{
+		{"below threshold", false, ApprovalAdjustBalance, 999999, "IDR", false},
+		{"at threshold", false, ApprovalAdjustBalance, 1000000, "IDR", true},
+		{"above threshold", false, ApprovalAdjustBalance, 5000000, "IDR", true},
+		{"lower case currency", false, ApprovalAdjustBalance, 300, "myr", true},
+		{"per currency threshold", false, ApprovalAdjustBalance, 299, "MYR", false},
+		{"currency without threshold", false, ApprovalRefundTransaction, 1, "PHP", true},
+		{"action without thresholds", false, ApprovalRefundDeposit, 100000000, "IDR", false},
+		{"zero threshold holds everything", false, ApprovalManualProcess, 0, "IDR", true},
+		{"disabled", true, ApprovalAdjustBalance, 5000000, "IDR", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := settings
+			s.Enabled = !tt.disabled
+			if got := s.Requires(tt.action, tt.amount, tt.currency); got != tt.want {
+				t.Errorf("Requires(%s, %d, %s) = %v, want %v", tt.action, tt.amount, tt.currency, got, tt.want)
+			}
+		})
+	}
+}
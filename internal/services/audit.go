@@ -0,0 +1,379 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Common audit actions; handlers may record more specific ones such as REFUND
+const (
+	AuditActionCreate = "CREATE"
+	AuditActionUpdate = "UPDATE"
+	AuditActionDelete = "DELETE"
+	// AuditActionImpersonate is a request made by an admin viewing as a user
+	AuditActionImpersonate = "IMPERSONATE"
+)
+
+// auditRedacted replaces the value of sensitive fields in recorded changes
+const auditRedacted = "[REDACTED]"
+
+// auditSensitiveFields are matched against lowercased field names
+var auditSensitiveFields = []string{"password", "secret", "credential", "token", "privatekey"}
+
+// AuditDB is a pool or a transaction, so an entry can be committed with the change it records
+type AuditDB interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// AuditActor is the admin performing an action
+type AuditActor struct {
+	AdminID   string
+	IPAddress string
+	UserAgent string
+}
+
+// AuditEntry describes an admin action. Before and After are structs or maps of the
+// resource; only the fields that differ between them are recorded.
+type AuditEntry struct {
+	Action      string
+	Resource    string
+	ResourceID  string
+	Description string
+	Before      interface{}
+	After       interface{}
+}
+
+// AuditAdmin is the admin of an audit log
+type AuditAdmin struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// AuditLog is a recorded admin action
+type AuditLog struct {
+	ID          string          `json:"id"`
+	Action      string          `json:"action"`
+	Resource    string          `json:"resource"`
+	ResourceID  string          `json:"resourceId"`
+	Description string          `json:"description"`
+	Changes     json.RawMessage `json:"changes"`
+	IPAddress   string          `json:"ipAddress"`
+	UserAgent   string          `json:"userAgent"`
+	Admin       AuditAdmin      `json:"admin"`
+	CreatedAt   time.Time       `json:"createdAt"`
+}
+
+// AuditFilter narrows down audit logs
+type AuditFilter struct {
+	AdminID    string
+	Resource   string
+	ResourceID string
+	Action     string
+	Search     string
+	From       *time.Time
+	To         *time.Time
+}
+
+// AuditService records admin actions and queries them
+type AuditService struct {
+	pool *pgxpool.Pool
+}
+
+// NewAuditService creates an audit service
+func NewAuditService(pool *pgxpool.Pool) *AuditService {
+	return &AuditService{pool: pool}
+}
+
+// Record writes an audit entry through db, which is the transaction of the change
+// when there is one
+func (s *AuditService) Record(ctx context.Context, db AuditDB, actor AuditActor, entry AuditEntry) error {
+	changes, err := AuditChanges(entry.Before, entry.After)
+	if err != nil {
+		return fmt.Errorf("diff changes: %w", err)
+	}
+
+	var changesJSON []byte
+	if changes != nil {
+		if changesJSON, err = json.Marshal(changes); err != nil {
+			return fmt.Errorf("encode changes: %w", err)
+		}
+	}
+
+	_, err = db.Exec(ctx, `
+		INSERT INTO audit_logs (
+			admin_id, admin_name, admin_email, action, resource, resource_id, description,
+			changes, ip_address, user_agent, created_at
+		)
+		VALUES (
+			$1::uuid,
+			(SELECT name FROM admins WHERE id = $1::uuid),
+			(SELECT email FROM admins WHERE id = $1::uuid),
+			$2, $3, $4, $5, $6, $7::inet, $8, NOW()
+		)
+	`, nullableString(actor.AdminID), strings.ToUpper(entry.Action), strings.ToUpper(entry.Resource),
+		nullableString(entry.ResourceID), nullableString(entry.Description), changesJSON,
+		auditIP(actor.IPAddress), nullableString(actor.UserAgent))
+	if err != nil {
+		return fmt.Errorf("insert audit log: %w", err)
+	}
+	return nil
+}
+
+// Write records an audit entry on its own, for actions without a transaction
+func (s *AuditService) Write(ctx context.Context, actor AuditActor, entry AuditEntry) error {
+	return s.Record(ctx, s.pool, actor, entry)
+}
+
+// Snapshot returns the row of table with the given ID as a map of its columns, nil when
+// it doesn't exist. It serves as Before or After for resources without a detail loader;
+// table must be a constant, never user input.
+func (s *AuditService) Snapshot(ctx context.Context, db AuditDB, table, id string) (map[string]interface{}, error) {
+	var row map[string]interface{}
+	err := db.QueryRow(ctx, `SELECT to_jsonb(t) FROM `+table+` t WHERE t.id::text = $1`, id).Scan(&row)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("snapshot %s: %w", table, err)
+	}
+	return row, nil
+}
+
+// AuditChanges returns the fields that differ between before and after as
+// {"before": {...}, "after": {...}}, or nil when nothing changed. A create has no
+// before and a delete no after, their whole resource is recorded.
+func AuditChanges(before, after interface{}) (map[string]interface{}, error) {
+	b, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil && a == nil {
+		return nil, nil
+	}
+
+	changedBefore := map[string]interface{}{}
+	changedAfter := map[string]interface{}{}
+	for key, value := range b {
+		if other, ok := a[key]; a == nil || !ok || !reflect.DeepEqual(value, other) {
+			changedBefore[key] = value
+		}
+	}
+	for key, value := range a {
+		if other, ok := b[key]; b == nil || !ok || !reflect.DeepEqual(value, other) {
+			changedAfter[key] = value
+		}
+	}
+	if len(changedBefore) == 0 && len(changedAfter) == 0 {
+		return nil, nil
+	}
+
+	changes := map[string]interface{}{}
+	if b != nil {
+		changes["before"] = redactAuditFields(changedBefore)
+	}
+	if a != nil {
+		changes["after"] = redactAuditFields(changedAfter)
+	}
+	return changes, nil
+}
+
+// auditFields converts a struct or map to its JSON fields
+func auditFields(value interface{}) (map[string]interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	if rv := reflect.ValueOf(value); (rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Map) && rv.IsNil() {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		// Not an object, record it as a whole
+		var whole interface{}
+		if err := json.Unmarshal(data, &whole); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"value": whole}, nil
+	}
+	return fields, nil
+}
+
+func redactAuditFields(fields map[string]interface{}) map[string]interface{} {
+	for key, value := range fields {
+		if isSensitiveAuditField(key) {
+			// Flags such as hasCredentials tell nothing about the secret itself
+			if _, isFlag := value.(bool); !isFlag && value != nil && value != "" {
+				fields[key] = auditRedacted
+			}
+			continue
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			fields[key] = redactAuditFields(nested)
+		}
+	}
+	return fields
+}
+
+func isSensitiveAuditField(key string) bool {
+	key = strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(key))
+	for _, field := range auditSensitiveFields {
+		if strings.Contains(key, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// auditIP returns the IP for the inet column, or nil when it can't be parsed
+func auditIP(ip string) interface{} {
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	if net.ParseIP(ip) == nil {
+		return nil
+	}
+	return ip
+}
+
+func nullableString(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+// where returns the conditions of the filter on audit_logs aliased al
+func (f AuditFilter) where() (string, []interface{}) {
+	conditions := []string{"1=1"}
+	args := []interface{}{}
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, strings.ReplaceAll(condition, "?", "$"+strconv.Itoa(len(args))))
+	}
+
+	if f.AdminID != "" {
+		add("al.admin_id = ?::uuid", f.AdminID)
+	}
+	if f.Resource != "" {
+		add("al.resource = ?", strings.ToUpper(f.Resource))
+	}
+	if f.ResourceID != "" {
+		add("al.resource_id = ?", f.ResourceID)
+	}
+	if f.Action != "" {
+		add("al.action = ?", strings.ToUpper(f.Action))
+	}
+	if f.Search != "" {
+		add("(al.description ILIKE ? OR al.admin_name ILIKE ? OR al.admin_email ILIKE ? OR al.resource_id ILIKE ?)", "%"+f.Search+"%")
+	}
+	if f.From != nil {
+		add("al.created_at >= ?", *f.From)
+	}
+	if f.To != nil {
+		add("al.created_at < ?", *f.To)
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+const auditLogColumns = `
+	al.id, al.action, al.resource, COALESCE(al.resource_id, ''), COALESCE(al.description, ''),
+	al.changes, COALESCE(host(al.ip_address), ''), COALESCE(al.user_agent, ''),
+	COALESCE(al.admin_id::text, ''),
+	COALESCE(a.name, al.admin_name, ''),
+	COALESCE(a.email, al.admin_email, ''),
+	al.created_at`
+
+type auditRow interface {
+	Scan(dest ...any) error
+}
+
+func scanAuditLog(row auditRow) (AuditLog, error) {
+	var l AuditLog
+	var changes []byte
+	err := row.Scan(&l.ID, &l.Action, &l.Resource, &l.ResourceID, &l.Description,
+		&changes, &l.IPAddress, &l.UserAgent,
+		&l.Admin.ID, &l.Admin.Name, &l.Admin.Email, &l.CreatedAt)
+	if len(changes) > 0 {
+		l.Changes = changes
+	}
+	return l, err
+}
+
+// List returns a page of audit logs matching the filter, newest first, and the total count
+func (s *AuditService) List(ctx context.Context, filter AuditFilter, limit, offset int) ([]AuditLog, int, error) {
+	where, args := filter.where()
+
+	var total int
+	if err := s.pool.QueryRow(ctx, `SELECT COUNT(*) FROM audit_logs al WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("count audit logs: %w", err)
+	}
+
+	logs := []AuditLog{}
+	err := s.Each(ctx, filter, limit, offset, func(l AuditLog) error {
+		logs = append(logs, l)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return logs, total, nil
+}
+
+// Each calls fn for the audit logs matching the filter, newest first
+func (s *AuditService) Each(ctx context.Context, filter AuditFilter, limit, offset int, fn func(AuditLog) error) error {
+	where, args := filter.where()
+	args = append(args, limit, offset)
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+auditLogColumns+`
+		FROM audit_logs al
+		LEFT JOIN admins a ON al.admin_id = a.id
+		WHERE `+where+`
+		ORDER BY al.created_at DESC, al.id
+		LIMIT $`+strconv.Itoa(len(args)-1)+` OFFSET $`+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		return fmt.Errorf("query audit logs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		l, err := scanAuditLog(rows)
+		if err != nil {
+			return fmt.Errorf("scan audit log: %w", err)
+		}
+		if err := fn(l); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Get returns an audit log by ID, pgx.ErrNoRows when it doesn't exist
+func (s *AuditService) Get(ctx context.Context, id string) (AuditLog, error) {
+	return scanAuditLog(s.pool.QueryRow(ctx, `
+		SELECT `+auditLogColumns+`
+		FROM audit_logs al
+		LEFT JOIN admins a ON al.admin_id = a.id
+		WHERE al.id = $1
+	`, id))
+}
@@ -0,0 +1,365 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"seaply/internal/provider"
+	"seaply/internal/utils"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// Provider balance statuses
+const (
+	BalanceStatusOK        = "OK"
+	BalanceStatusLow       = "LOW"
+	BalanceStatusDepleting = "DEPLETING"
+	BalanceStatusError     = "ERROR"
+)
+
+// BalanceMonitorSettings are read from the provider_balance settings category before every run
+type BalanceMonitorSettings struct {
+	Enabled       bool
+	Interval      time.Duration
+	LowThreshold  int64
+	MinHoursLeft  float64
+	AlertCooldown time.Duration
+	RetentionDays int
+	AlertEmail    string
+}
+
+// ProviderBalanceStatus is the balance of a provider with its projected spend
+type ProviderBalanceStatus struct {
+	ProviderID string     `json:"providerId"`
+	Provider   string     `json:"provider"`
+	Balance    int64      `json:"balance"`
+	Currency   string     `json:"currency"`
+	DailyBurn  int64      `json:"dailyBurn"`
+	HoursLeft  *float64   `json:"hoursLeft"`
+	Status     string     `json:"status"`
+	CheckedAt  *time.Time `json:"checkedAt"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// BalanceMonitorResult summarizes a monitor run
+type BalanceMonitorResult struct {
+	Providers  []ProviderBalanceStatus `json:"providers"`
+	Alerts     int                     `json:"alerts"`
+	StartedAt  time.Time               `json:"startedAt"`
+	FinishedAt time.Time               `json:"finishedAt"`
+}
+
+// BalanceMonitor snapshots provider balances, tracks their burn rate and alerts
+// before a provider runs out of deposit. Known balances are shared with the
+// provider manager so routing avoids providers that can't cover an order.
+type BalanceMonitor struct {
+	pool          *pgxpool.Pool
+	providers     *provider.Manager
+	notifications *NotificationService
+
+	runMu      sync.Mutex
+	mu         sync.RWMutex
+	lastRun    *BalanceMonitorResult
+	lastAlerts map[string]time.Time
+}
+
+// NewBalanceMonitor creates a provider balance monitor
+func NewBalanceMonitor(pool *pgxpool.Pool, providers *provider.Manager, notifications *NotificationService) *BalanceMonitor {
+	return &BalanceMonitor{
+		pool:          pool,
+		providers:     providers,
+		notifications: notifications,
+		lastAlerts:    make(map[string]time.Time),
+	}
+}
+
+// LoadSettings reads the monitor settings, falling back to defaults
+func (b *BalanceMonitor) LoadSettings(ctx context.Context) (BalanceMonitorSettings, error) {
+	settings := BalanceMonitorSettings{
+		Enabled:       true,
+		Interval:      15 * time.Minute,
+		LowThreshold:  1000000,
+		MinHoursLeft:  24,
+		AlertCooldown: time.Hour,
+		RetentionDays: 30,
+	}
+
+	rows, err := b.pool.Query(ctx, `SELECT key, value FROM settings WHERE category = 'provider_balance'`)
+	if err != nil {
+		return settings, fmt.Errorf("failed to load provider balance settings: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			return settings, fmt.Errorf("failed to scan provider balance setting: %w", err)
+		}
+
+		switch key {
+		case "enabled":
+			_ = json.Unmarshal(value, &settings.Enabled)
+		case "interval":
+			var minutes int
+			if json.Unmarshal(value, &minutes) == nil && minutes > 0 {
+				settings.Interval = time.Duration(minutes) * time.Minute
+			}
+		case "lowThreshold":
+			_ = json.Unmarshal(value, &settings.LowThreshold)
+		case "minHoursLeft":
+			_ = json.Unmarshal(value, &settings.MinHoursLeft)
+		case "alertCooldown":
+			var minutes int
+			if json.Unmarshal(value, &minutes) == nil && minutes >= 0 {
+				settings.AlertCooldown = time.Duration(minutes) * time.Minute
+			}
+		case "retentionDays":
+			_ = json.Unmarshal(value, &settings.RetentionDays)
+		case "alertEmail":
+			_ = json.Unmarshal(value, &settings.AlertEmail)
+		}
+	}
+
+	return settings, rows.Err()
+}
+
+// Start snapshots balances immediately and then on the interval from settings
+func (b *BalanceMonitor) Start(ctx context.Context) {
+	go func() {
+		for {
+			settings, err := b.LoadSettings(ctx)
+			if err != nil {
+				log.Warn().Err(err).Msg("Failed to load provider balance settings, using defaults")
+			}
+
+			if settings.Enabled {
+				runCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+				if _, err := b.Run(runCtx); err != nil {
+					log.Error().Err(err).Msg("Provider balance monitor run failed")
+				}
+				cancel()
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(settings.Interval):
+			}
+		}
+	}()
+}
+
+// LastRun returns the result of the most recent run, or nil
+func (b *BalanceMonitor) LastRun() *BalanceMonitorResult {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.lastRun
+}
+
+// Run snapshots the balance of every registered provider once. Concurrent runs are serialized.
+func (b *BalanceMonitor) Run(ctx context.Context) (*BalanceMonitorResult, error) {
+	b.runMu.Lock()
+	defer b.runMu.Unlock()
+
+	result := &BalanceMonitorResult{StartedAt: time.Now(), Providers: []ProviderBalanceStatus{}}
+
+	settings, err := b.LoadSettings(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to load provider balance settings, using defaults")
+	}
+
+	rows, err := b.pool.Query(ctx, `SELECT id, code FROM providers WHERE is_active = true ORDER BY priority ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load providers: %w", err)
+	}
+	type monitoredProvider struct{ id, code string }
+	var monitored []monitoredProvider
+	for rows.Next() {
+		var p monitoredProvider
+		if err := rows.Scan(&p.id, &p.code); err == nil {
+			monitored = append(monitored, p)
+		}
+	}
+	rows.Close()
+
+	var alerts []ProviderBalanceStatus
+	for _, p := range monitored {
+		prov, err := b.providers.Get(strings.ToLower(p.code))
+		if err != nil {
+			continue
+		}
+
+		status := b.snapshot(ctx, prov, p.id, p.code)
+		if status.Status != BalanceStatusError {
+			status = b.classify(settings, status)
+		}
+		result.Providers = append(result.Providers, status)
+
+		if (status.Status == BalanceStatusLow || status.Status == BalanceStatusDepleting) &&
+			b.shouldAlert(p.code, settings.AlertCooldown) {
+			alerts = append(alerts, status)
+		}
+	}
+
+	if settings.RetentionDays > 0 {
+		_, _ = b.pool.Exec(ctx, `
+			DELETE FROM provider_balance_history WHERE created_at < NOW() - make_interval(days => $1)
+		`, settings.RetentionDays)
+	}
+
+	if len(alerts) > 0 {
+		result.Alerts = len(alerts)
+		b.notify(settings, alerts)
+	}
+
+	result.FinishedAt = time.Now()
+	b.mu.Lock()
+	b.lastRun = result
+	b.mu.Unlock()
+
+	log.Info().
+		Int("providers", len(result.Providers)).
+		Int("alerts", result.Alerts).
+		Msg("Provider balance monitor run finished")
+
+	return result, nil
+}
+
+// snapshot fetches and stores one provider balance
+func (b *BalanceMonitor) snapshot(ctx context.Context, prov provider.Provider, providerID, code string) ProviderBalanceStatus {
+	status := ProviderBalanceStatus{ProviderID: providerID, Provider: code}
+
+	balanceCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	balance, err := prov.GetBalance(balanceCtx)
+	cancel()
+	if err != nil {
+		log.Warn().Err(err).Str("provider", code).Msg("Failed to fetch provider balance")
+		status.Status = BalanceStatusError
+		status.Error = err.Error()
+		return status
+	}
+
+	currency := balance.Currency
+	if currency == "" {
+		currency = "IDR"
+	}
+	checkedAt := time.Now()
+	b.providers.SetBalance(prov.GetName(), provider.Balance{Balance: balance.Balance, Currency: currency, UpdatedAt: checkedAt})
+
+	_, err = b.pool.Exec(ctx, `
+		INSERT INTO provider_balance_history (provider_id, balance, currency, created_at)
+		VALUES ($1, $2, $3, $4)
+	`, providerID, int64(balance.Balance), currency, checkedAt)
+	if err != nil {
+		log.Error().Err(err).Str("provider", code).Msg("Failed to store provider balance snapshot")
+	}
+
+	status, err = b.Status(ctx, providerID)
+	if err != nil {
+		status = ProviderBalanceStatus{ProviderID: providerID, Provider: code, Balance: int64(balance.Balance), Currency: currency, CheckedAt: &checkedAt}
+	}
+	return status
+}
+
+// classify sets the status of a fresh snapshot from the alert settings
+func (b *BalanceMonitor) classify(settings BalanceMonitorSettings, status ProviderBalanceStatus) ProviderBalanceStatus {
+	status.Status = BalanceStatusOK
+	if settings.LowThreshold > 0 && status.Balance < settings.LowThreshold {
+		status.Status = BalanceStatusLow
+	} else if status.HoursLeft != nil && settings.MinHoursLeft > 0 && *status.HoursLeft < settings.MinHoursLeft {
+		status.Status = BalanceStatusDepleting
+	}
+	return status
+}
+
+// shouldAlert reports whether a provider may be alerted again and records the alert
+func (b *BalanceMonitor) shouldAlert(code string, cooldown time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if last, ok := b.lastAlerts[code]; ok && time.Since(last) < cooldown {
+		return false
+	}
+	b.lastAlerts[code] = time.Now()
+	return true
+}
+
+// Status returns the latest stored balance of a provider with its burn rate.
+// Burn is the sum of balance drops over the last 24 hours, ignoring top-ups,
+// projected to a full day.
+func (b *BalanceMonitor) Status(ctx context.Context, providerID string) (ProviderBalanceStatus, error) {
+	var status ProviderBalanceStatus
+	var checkedAt time.Time
+	err := b.pool.QueryRow(ctx, `
+		SELECT h.provider_id, p.code, h.balance, h.currency, h.created_at
+		FROM provider_balance_history h
+		JOIN providers p ON h.provider_id = p.id
+		WHERE h.provider_id = $1
+		ORDER BY h.created_at DESC
+		LIMIT 1
+	`, providerID).Scan(&status.ProviderID, &status.Provider, &status.Balance, &status.Currency, &checkedAt)
+	if err != nil {
+		return status, err
+	}
+	status.CheckedAt = &checkedAt
+
+	var spent int64
+	var seconds float64
+	err = b.pool.QueryRow(ctx, `
+		SELECT COALESCE(SUM(GREATEST(prev_balance - balance, 0)), 0)::bigint,
+		       COALESCE(EXTRACT(EPOCH FROM MAX(created_at) - MIN(created_at)), 0)::float8
+		FROM (
+			SELECT balance, created_at, LAG(balance) OVER (ORDER BY created_at) AS prev_balance
+			FROM provider_balance_history
+			WHERE provider_id = $1 AND created_at > NOW() - INTERVAL '24 hours'
+		) h
+	`, providerID).Scan(&spent, &seconds)
+	if err != nil {
+		return status, nil
+	}
+
+	// Less than an hour of history is too little to project
+	if seconds >= 3600 && spent > 0 {
+		status.DailyBurn = int64(math.Round(float64(spent) * 86400 / seconds))
+		hoursLeft := math.Round(float64(status.Balance)/float64(status.DailyBurn)*24*10) / 10
+		status.HoursLeft = &hoursLeft
+	}
+	return status, nil
+}
+
+// notify sends one alert listing every provider running low
+func (b *BalanceMonitor) notify(settings BalanceMonitorSettings, statuses []ProviderBalanceStatus) {
+	if b.notifications == nil {
+		return
+	}
+
+	lines := make([]string, 0, len(statuses))
+	for _, s := range statuses {
+		line := fmt.Sprintf("%s %s: balance %s", s.Provider, s.Status, utils.FormatCurrency(float64(s.Balance), s.Currency))
+		if s.HoursLeft != nil {
+			line += fmt.Sprintf(", burn %s/day, %.1f hours left", utils.FormatCurrency(float64(s.DailyBurn), s.Currency), *s.HoursLeft)
+		}
+		lines = append(lines, line)
+	}
+
+	b.notifications.Notify(Notification{
+		Event:    EventBalanceAlert,
+		Language: "en",
+		Email:    settings.AlertEmail,
+		Data: map[string]interface{}{
+			"Count":     len(statuses),
+			"Providers": statuses,
+			"Threshold": utils.FormatCurrency(float64(settings.LowThreshold), "IDR"),
+			"Summary":   strings.Join(lines, "\n"),
+			"Time":      time.Now().Format("02 Jan 2006 15:04 MST"),
+		},
+	})
+}
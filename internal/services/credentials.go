@@ -0,0 +1,51 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"seaply/internal/utils"
+)
+
+// credentialCipher encrypts the credentials stored for providers and payment gateways
+type credentialCipher struct {
+	credentialKey string
+}
+
+// EncryptCredentials encrypts a set of credentials for a credentials column
+func (c credentialCipher) EncryptCredentials(credentials map[string]string) (string, error) {
+	data, err := json.Marshal(credentials)
+	if err != nil {
+		return "", err
+	}
+	return utils.EncryptSecret(c.credentialKey, string(data))
+}
+
+// DecryptCredentials decrypts a credentials column
+func (c credentialCipher) DecryptCredentials(encrypted string) (map[string]string, error) {
+	credentials := map[string]string{}
+	if encrypted == "" {
+		return credentials, nil
+	}
+	data, err := utils.DecryptSecret(c.credentialKey, encrypted)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(data), &credentials); err != nil {
+		return nil, fmt.Errorf("decode credentials: %w", err)
+	}
+	return credentials, nil
+}
+
+// EncryptSecret encrypts a single secret such as a webhook secret
+func (c credentialCipher) EncryptSecret(secret string) (string, error) {
+	return utils.EncryptSecret(c.credentialKey, secret)
+}
+
+// DecryptSecret decrypts a single secret
+func (c credentialCipher) DecryptSecret(encrypted string) (string, error) {
+	if encrypted == "" {
+		return "", nil
+	}
+	return utils.DecryptSecret(c.credentialKey, encrypted)
+}
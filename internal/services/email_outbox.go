@@ -0,0 +1,328 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// Outbox statuses
+const (
+	OutboxStatusPending = "PENDING"
+	OutboxStatusSending = "SENDING"
+	OutboxStatusSent    = "SENT"
+	OutboxStatusFailed  = "FAILED"
+)
+
+// DBTX is implemented by both *pgxpool.Pool and pgx.Tx, so emails can be
+// queued inside the same database transaction as the business change.
+type DBTX interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// OutboxEmail is an email waiting in the outbox
+type OutboxEmail struct {
+	To            string
+	Subject       string
+	HTMLBody      string
+	Template      string
+	ReferenceType string
+	ReferenceID   string
+	CreatedBy     string // admin ID, for manual resends
+	Attachments   []Attachment
+}
+
+// EmailOutboxConfig configures the outbox worker
+type EmailOutboxConfig struct {
+	BatchSize    int
+	PollInterval time.Duration
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	LockTimeout  time.Duration
+}
+
+// EmailOutbox stores outbound emails and delivers them in the background
+type EmailOutbox struct {
+	pool   *pgxpool.Pool
+	sender Notifier
+	cfg    EmailOutboxConfig
+}
+
+// NewEmailOutbox creates a new email outbox delivering through sender
+func NewEmailOutbox(pool *pgxpool.Pool, sender Notifier, cfg EmailOutboxConfig) *EmailOutbox {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 20
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 5 * time.Second
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.BaseBackoff <= 0 {
+		cfg.BaseBackoff = 30 * time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = 1 * time.Hour
+	}
+	if cfg.LockTimeout <= 0 {
+		cfg.LockTimeout = 10 * time.Minute
+	}
+
+	return &EmailOutbox{
+		pool:   pool,
+		sender: sender,
+		cfg:    cfg,
+	}
+}
+
+// Enqueue writes an email to the outbox using db, which may be a transaction.
+// The email is only delivered once the transaction commits.
+func (o *EmailOutbox) Enqueue(ctx context.Context, db DBTX, email *OutboxEmail) (string, error) {
+	if email.To == "" {
+		return "", fmt.Errorf("email recipient is empty")
+	}
+
+	var createdBy interface{}
+	if email.CreatedBy != "" {
+		createdBy = email.CreatedBy
+	}
+
+	attachments := email.Attachments
+	if attachments == nil {
+		attachments = []Attachment{}
+	}
+	attachmentsJSON, err := json.Marshal(attachments)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode attachments: %w", err)
+	}
+
+	var id string
+	err = db.QueryRow(ctx, `
+		INSERT INTO email_outbox (
+			recipient, subject, html_body, template,
+			reference_type, reference_id, max_attempts, created_by, attachments
+		) VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), $7, $8, $9)
+		RETURNING id
+	`, email.To, email.Subject, email.HTMLBody, email.Template,
+		email.ReferenceType, email.ReferenceID, o.cfg.MaxAttempts, createdBy, attachmentsJSON).Scan(&id)
+	if err != nil {
+		return "", fmt.Errorf("failed to enqueue email: %w", err)
+	}
+
+	return id, nil
+}
+
+// Queue writes an email to the outbox outside of any transaction
+func (o *EmailOutbox) Queue(ctx context.Context, email *OutboxEmail) (string, error) {
+	return o.Enqueue(ctx, o.pool, email)
+}
+
+// Retry resets a message so the worker picks it up again immediately
+func (o *EmailOutbox) Retry(ctx context.Context, id string) error {
+	tag, err := o.pool.Exec(ctx, `
+		UPDATE email_outbox
+		SET status = 'PENDING', next_attempt_at = NOW(), locked_at = NULL,
+		    max_attempts = GREATEST(max_attempts, attempts + 1)
+		WHERE id::text = $1 AND status IN ('PENDING', 'FAILED')
+	`, id)
+	if err != nil {
+		return fmt.Errorf("failed to retry email: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// Start starts the delivery worker
+func (o *EmailOutbox) Start(ctx context.Context) {
+	ticker := time.NewTicker(o.cfg.PollInterval)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				ticker.Stop()
+				return
+			case <-ticker.C:
+				o.releaseStaleLocks(ctx)
+				for {
+					processed, err := o.ProcessBatch(ctx)
+					if err != nil {
+						log.Error().Err(err).Msg("Failed to process email outbox")
+						break
+					}
+					if processed < o.cfg.BatchSize {
+						break
+					}
+				}
+			}
+		}
+	}()
+}
+
+type claimedEmail struct {
+	id          string
+	to          string
+	subject     string
+	htmlBody    string
+	attachments []byte
+	attempts    int
+	maxTries    int
+}
+
+// ProcessBatch claims and delivers one batch of due messages, returning how many were claimed
+func (o *EmailOutbox) ProcessBatch(ctx context.Context) (int, error) {
+	rows, err := o.pool.Query(ctx, `
+		UPDATE email_outbox
+		SET status = 'SENDING', locked_at = NOW(), attempts = attempts + 1
+		WHERE id IN (
+			SELECT id FROM email_outbox
+			WHERE status = 'PENDING' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, recipient, subject, html_body, attachments, attempts, max_attempts
+	`, o.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var claimed []claimedEmail
+	for rows.Next() {
+		var e claimedEmail
+		if err := rows.Scan(&e.id, &e.to, &e.subject, &e.htmlBody, &e.attachments, &e.attempts, &e.maxTries); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		claimed = append(claimed, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, e := range claimed {
+		o.deliver(ctx, e)
+	}
+
+	return len(claimed), nil
+}
+
+func (o *EmailOutbox) deliver(ctx context.Context, e claimedEmail) {
+	sendCtx, cancel := context.WithTimeout(ctx, 1*time.Minute)
+	defer cancel()
+
+	var attachments []Attachment
+	if len(e.attachments) > 0 {
+		if err := json.Unmarshal(e.attachments, &attachments); err != nil {
+			log.Warn().Err(err).Str("outbox_id", e.id).Msg("Invalid email attachments, sending without them")
+		}
+	}
+
+	err := o.sender.Send(sendCtx, Message{
+		Channel:     ChannelEmail,
+		To:          e.to,
+		Subject:     e.subject,
+		Body:        e.htmlBody,
+		Attachments: attachments,
+	})
+
+	if err == nil {
+		_, dbErr := o.pool.Exec(ctx, `
+			UPDATE email_outbox
+			SET status = 'SENT', sent_at = NOW(), locked_at = NULL, last_error = NULL
+			WHERE id = $1
+		`, e.id)
+		if dbErr != nil {
+			log.Error().Err(dbErr).Str("outbox_id", e.id).Msg("Failed to mark email as sent")
+		}
+		return
+	}
+
+	status := OutboxStatusPending
+	if e.attempts >= e.maxTries {
+		status = OutboxStatusFailed
+	}
+
+	log.Warn().Err(err).
+		Str("outbox_id", e.id).
+		Str("to", e.to).
+		Int("attempt", e.attempts).
+		Str("status", status).
+		Msg("Email delivery failed")
+
+	_, dbErr := o.pool.Exec(ctx, `
+		UPDATE email_outbox
+		SET status = $1, last_error = $2, locked_at = NULL, next_attempt_at = $3
+		WHERE id = $4
+	`, status, err.Error(), time.Now().Add(o.backoff(e.attempts)), e.id)
+	if dbErr != nil {
+		log.Error().Err(dbErr).Str("outbox_id", e.id).Msg("Failed to record email delivery failure")
+	}
+}
+
+// backoff returns the delay before the next attempt: base * 2^(attempts-1), capped at MaxBackoff
+func (o *EmailOutbox) backoff(attempts int) time.Duration {
+	delay := o.cfg.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= o.cfg.MaxBackoff {
+			return o.cfg.MaxBackoff
+		}
+	}
+	return delay
+}
+
+// releaseStaleLocks puts messages claimed by a worker that died back in the queue
+func (o *EmailOutbox) releaseStaleLocks(ctx context.Context) {
+	_, err := o.pool.Exec(ctx, `
+		UPDATE email_outbox
+		SET status = 'PENDING', locked_at = NULL
+		WHERE status = 'SENDING' AND locked_at < $1
+	`, time.Now().Add(-o.cfg.LockTimeout))
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to release stale email outbox locks")
+	}
+}
+
+// OutboxNotifier is the email channel of the NotificationService backed by the outbox
+type OutboxNotifier struct {
+	outbox *EmailOutbox
+}
+
+// NewOutboxNotifier creates a notifier that queues emails in the outbox
+func NewOutboxNotifier(outbox *EmailOutbox) *OutboxNotifier {
+	return &OutboxNotifier{outbox: outbox}
+}
+
+// Channel returns the notifier channel
+func (n *OutboxNotifier) Channel() Channel {
+	return ChannelEmail
+}
+
+// Send queues the message in the outbox
+func (n *OutboxNotifier) Send(ctx context.Context, msg Message) error {
+	return n.SendTx(ctx, n.outbox.pool, msg)
+}
+
+// SendTx queues the message in the outbox using db, which may be a transaction
+func (n *OutboxNotifier) SendTx(ctx context.Context, db DBTX, msg Message) error {
+	_, err := n.outbox.Enqueue(ctx, db, &OutboxEmail{
+		To:            msg.To,
+		Subject:       msg.Subject,
+		HTMLBody:      msg.Body,
+		Template:      string(msg.Event),
+		ReferenceType: msg.ReferenceType,
+		ReferenceID:   msg.ReferenceID,
+		Attachments:   msg.Attachments,
+	})
+	return err
+}
@@ -0,0 +1,305 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"seaply/internal/database"
+	"seaply/internal/payment"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// GatewayReloadChannel carries payment gateway changes to the other API instances
+const GatewayReloadChannel = "payment_gateways:reload"
+
+// GatewayRegistry builds payment gateway instances from the payment_gateways table
+// and keeps the payment manager in sync with admin changes. Gateways without
+// stored credentials keep the instance configured from environment variables.
+//
+// Credentials are rotated by building and verifying a new instance before it
+// replaces the registered one, so payments never see a missing gateway.
+type GatewayRegistry struct {
+	credentialCipher
+
+	pool     *pgxpool.Pool
+	redis    *database.RedisClient
+	payments *payment.Manager
+
+	mu          sync.Mutex
+	envGateways map[string]payment.Gateway
+	sources     map[string]string
+}
+
+// GatewayRecord is a payment_gateways row needed to build an instance
+type GatewayRecord struct {
+	Code        string
+	CallbackURL string
+	IsActive    bool
+	IsSandbox   bool
+	APIConfig   []byte
+	Credentials string
+}
+
+// NewGatewayRegistry creates a registry. Gateways already registered in the
+// manager are remembered as the environment fallback.
+func NewGatewayRegistry(pool *pgxpool.Pool, redis *database.RedisClient, payments *payment.Manager, credentialKey string) *GatewayRegistry {
+	r := &GatewayRegistry{
+		credentialCipher: credentialCipher{credentialKey: credentialKey},
+		pool:             pool,
+		redis:            redis,
+		payments:         payments,
+		envGateways:      make(map[string]payment.Gateway),
+		sources:          make(map[string]string),
+	}
+	for _, g := range payments.GetAll() {
+		r.envGateways[g.GetName()] = g
+		r.sources[g.GetName()] = CredentialSourceEnvironment
+	}
+	return r
+}
+
+// Source returns where the registered instance of a gateway was configured from,
+// or an empty string when it is not registered
+func (r *GatewayRegistry) Source(code string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sources[strings.ToUpper(code)]
+}
+
+// LoadAll registers every gateway configured in the database
+func (r *GatewayRegistry) LoadAll(ctx context.Context) error {
+	rows, err := r.pool.Query(ctx, gatewayRecordQuery+` ORDER BY code ASC`)
+	if err != nil {
+		return fmt.Errorf("query payment gateways: %w", err)
+	}
+	var records []GatewayRecord
+	for rows.Next() {
+		rec, err := scanGatewayRecord(rows)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("scan payment gateway: %w", err)
+		}
+		records = append(records, rec)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, rec := range records {
+		if err := r.apply(rec); err != nil {
+			log.Error().Err(err).Str("gateway", rec.Code).Msg("Failed to register payment gateway from database")
+		}
+	}
+	return nil
+}
+
+// Record loads the row of a gateway
+func (r *GatewayRegistry) Record(ctx context.Context, gatewayID string) (GatewayRecord, error) {
+	return scanGatewayRecord(r.pool.QueryRow(ctx, gatewayRecordQuery+` WHERE id = $1`, gatewayID))
+}
+
+// Reload rebuilds the instance of one gateway after its row changed
+func (r *GatewayRegistry) Reload(ctx context.Context, gatewayID string) error {
+	rec, err := r.Record(ctx, gatewayID)
+	if err != nil {
+		return fmt.Errorf("load payment gateway: %w", err)
+	}
+	return r.apply(rec)
+}
+
+// Remove unregisters a deleted gateway
+func (r *GatewayRegistry) Remove(code string) {
+	name := strings.ToUpper(code)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sources, name)
+	if r.payments.Unregister(name) {
+		log.Info().Str("gateway", name).Msg("Unregistered payment gateway")
+	}
+}
+
+// Build creates an unregistered gateway instance from a row and decrypted credentials
+func (r *GatewayRegistry) Build(rec GatewayRecord, credentials map[string]string) (payment.Gateway, error) {
+	var apiConfig struct {
+		Timeout int    `json:"timeout"` // milliseconds
+		BaseURL string `json:"baseUrl"`
+	}
+	_ = json.Unmarshal(rec.APIConfig, &apiConfig)
+
+	return payment.BuildGateway(rec.Code, payment.InstanceConfig{
+		Credentials: credentials,
+		BaseURL:     apiConfig.BaseURL,
+		CallbackURL: rec.CallbackURL,
+		Sandbox:     rec.IsSandbox,
+		Timeout:     time.Duration(apiConfig.Timeout) * time.Millisecond,
+	})
+}
+
+// Instance returns a gateway instance for a row without registering it. Stored
+// credentials are built into a fresh instance; otherwise the environment
+// instance is returned. The second value is the credential source.
+func (r *GatewayRegistry) Instance(rec GatewayRecord) (payment.Gateway, string, error) {
+	if rec.Credentials == "" {
+		r.mu.Lock()
+		g, ok := r.envGateways[strings.ToUpper(rec.Code)]
+		r.mu.Unlock()
+		if !ok {
+			return nil, "", fmt.Errorf("gateway %s has no credentials configured", rec.Code)
+		}
+		return g, CredentialSourceEnvironment, nil
+	}
+
+	credentials, err := r.DecryptCredentials(rec.Credentials)
+	if err != nil {
+		return nil, "", fmt.Errorf("decrypt credentials: %w", err)
+	}
+	g, err := r.Build(rec, credentials)
+	if err != nil {
+		return nil, "", err
+	}
+	return g, CredentialSourceDatabase, nil
+}
+
+// Handshake verifies the credentials of a gateway instance. Gateways with an
+// authentication flow request a fresh token; the others run their health check.
+// It returns the method used.
+func (r *GatewayRegistry) Handshake(ctx context.Context, g payment.Gateway) (string, error) {
+	if auth, ok := g.(payment.Authenticator); ok {
+		return "AUTHENTICATE", auth.Authenticate(ctx)
+	}
+	return "HEALTH_CHECK", g.HealthCheck(ctx)
+}
+
+// gatewayReloadMessage is published on GatewayReloadChannel
+type gatewayReloadMessage struct {
+	GatewayID string `json:"gatewayId,omitempty"`
+	Code      string `json:"code"`
+	Removed   bool   `json:"removed"`
+}
+
+// Changed reloads a gateway after an admin change and tells the other instances to do the same
+func (r *GatewayRegistry) Changed(ctx context.Context, gatewayID, code string) error {
+	err := r.Reload(ctx, gatewayID)
+	r.publish(ctx, gatewayReloadMessage{GatewayID: gatewayID, Code: code})
+	return err
+}
+
+// Deleted unregisters a deleted gateway here and on the other instances
+func (r *GatewayRegistry) Deleted(ctx context.Context, code string) {
+	r.Remove(code)
+	r.publish(ctx, gatewayReloadMessage{Code: code, Removed: true})
+}
+
+func (r *GatewayRegistry) publish(ctx context.Context, msg gatewayReloadMessage) {
+	if r.redis == nil {
+		return
+	}
+	if err := r.redis.Publish(ctx, GatewayReloadChannel, msg); err != nil {
+		log.Warn().Err(err).Str("gateway", msg.Code).Msg("Failed to publish payment gateway reload")
+	}
+}
+
+// Subscribe applies gateway changes published by other instances
+func (r *GatewayRegistry) Subscribe(ctx context.Context) {
+	if r.redis == nil {
+		return
+	}
+	pubsub := r.redis.Subscribe(ctx, GatewayReloadChannel)
+	go func() {
+		defer pubsub.Close()
+
+		ch := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+
+				var reload gatewayReloadMessage
+				if err := json.Unmarshal([]byte(msg.Payload), &reload); err != nil {
+					log.Warn().Err(err).Msg("Invalid payment gateway reload message")
+					continue
+				}
+				if reload.Removed {
+					r.Remove(reload.Code)
+					continue
+				}
+
+				reloadCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+				if err := r.Reload(reloadCtx, reload.GatewayID); err != nil {
+					log.Error().Err(err).Str("gateway", reload.Code).Msg("Failed to reload payment gateway")
+				}
+				cancel()
+			}
+		}
+	}()
+}
+
+const gatewayRecordQuery = `
+	SELECT code, COALESCE(callback_url, ''), COALESCE(is_active, true), is_sandbox,
+		COALESCE(api_config, '{}'::jsonb), COALESCE(credentials, '')
+	FROM payment_gateways`
+
+func scanGatewayRecord(row pgx.Row) (GatewayRecord, error) {
+	var rec GatewayRecord
+	err := row.Scan(&rec.Code, &rec.CallbackURL, &rec.IsActive, &rec.IsSandbox, &rec.APIConfig, &rec.Credentials)
+	return rec, err
+}
+
+// apply registers, replaces or unregisters the instance of a gateway to match its row
+func (r *GatewayRegistry) apply(rec GatewayRecord) error {
+	name := strings.ToUpper(rec.Code)
+
+	// Build outside the lock; registering swaps the instance in one step
+	var built payment.Gateway
+	if rec.IsActive && rec.Credentials != "" {
+		credentials, err := r.DecryptCredentials(rec.Credentials)
+		if err != nil {
+			return fmt.Errorf("decrypt credentials: %w", err)
+		}
+		built, err = r.Build(rec, credentials)
+		if err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !rec.IsActive {
+		delete(r.sources, name)
+		if r.payments.Unregister(name) {
+			log.Info().Str("gateway", name).Msg("Unregistered inactive payment gateway")
+		}
+		return nil
+	}
+
+	// Without stored credentials the gateway falls back to its environment configuration
+	if built == nil {
+		if g, ok := r.envGateways[name]; ok {
+			r.payments.Register(g)
+			r.sources[name] = CredentialSourceEnvironment
+		} else {
+			delete(r.sources, name)
+			r.payments.Unregister(name)
+		}
+		return nil
+	}
+
+	r.payments.Register(built)
+	r.sources[name] = CredentialSourceDatabase
+	log.Info().Str("gateway", name).Bool("sandbox", rec.IsSandbox).Msg("Registered payment gateway from database")
+	return nil
+}
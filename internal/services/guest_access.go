@@ -0,0 +1,229 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"seaply/internal/database"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Guest contact types
+const (
+	GuestContactEmail = "email"
+	GuestContactPhone = "phone"
+)
+
+// Guest access limits
+const (
+	guestOTPLength      = 6
+	guestOTPTTL         = 10 * time.Minute
+	guestOTPCooldown    = time.Minute
+	guestOTPMaxAttempts = 5
+	guestTokenTTL       = 30 * time.Minute
+)
+
+// Errors returned by guest access
+var (
+	ErrGuestOTPCooldown  = errors.New("a code was sent recently, wait before requesting another")
+	ErrGuestOTPInvalid   = errors.New("verification code is invalid or expired")
+	ErrGuestTokenInvalid = errors.New("guest token is invalid")
+	ErrGuestTokenExpired = errors.New("guest token has expired")
+)
+
+// GuestContact is the email address or phone number a guest ordered with
+type GuestContact struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// NewGuestContact normalizes the email or phone number of a guest. Phone numbers are
+// reduced to digits with a leading 0 replaced by 62, as the WhatsApp notifier does.
+func NewGuestContact(email, phone string) (GuestContact, bool) {
+	if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+		return GuestContact{Type: GuestContactEmail, Value: email}, true
+	}
+	if phone = normalizePhoneNumber(phone); len(phone) >= 8 {
+		return GuestContact{Type: GuestContactPhone, Value: phone}, true
+	}
+	return GuestContact{}, false
+}
+
+// Condition returns the SQL condition matching the transactions of the contact,
+// with the contact value bound to the given placeholder
+func (c GuestContact) Condition(alias, placeholder string) string {
+	if c.Type == GuestContactEmail {
+		return "LOWER(" + alias + ".contact_email) = " + placeholder
+	}
+	return "regexp_replace(regexp_replace(" + alias + ".contact_phone, '\\D', '', 'g'), '^0', '62') = " + placeholder
+}
+
+// Notification returns the notification delivering a verification code to the contact
+func (c GuestContact) Notification(code, language string) Notification {
+	n := Notification{
+		Event:    EventGuestOTP,
+		Language: language,
+		Data: map[string]interface{}{
+			"Code":    code,
+			"Minutes": int(guestOTPTTL.Minutes()),
+		},
+	}
+	if c.Type == GuestContactEmail {
+		n.Email = c.Value
+		n.Channels = []Channel{ChannelEmail}
+	} else {
+		n.Phone = c.Value
+		n.Channels = []Channel{ChannelWhatsApp}
+	}
+	return n
+}
+
+func (c GuestContact) key() string {
+	sum := sha256.Sum256([]byte(c.Type + ":" + c.Value))
+	return hex.EncodeToString(sum[:16])
+}
+
+// GuestAccess lets guests reach the orders placed with their email or phone number.
+// A one-time code is sent to the contact; once verified, the guest gets a short-lived
+// token scoped to that contact.
+type GuestAccess struct {
+	redis         *database.RedisClient
+	notifications *NotificationService
+	signingKey    string
+}
+
+// NewGuestAccess creates guest access
+func NewGuestAccess(redis *database.RedisClient, notifications *NotificationService, signingKey string) *GuestAccess {
+	return &GuestAccess{
+		redis:         redis,
+		notifications: notifications,
+		signingKey:    signingKey,
+	}
+}
+
+type guestOTP struct {
+	CodeHash string `json:"codeHash"`
+	Attempts int    `json:"attempts"`
+}
+
+// RequestOTP sends a verification code to the contact. The cooldown applies whether
+// or not the code is delivered, so the response doesn't reveal which contacts have
+// orders; deliver is false for contacts without any.
+func (a *GuestAccess) RequestOTP(ctx context.Context, contact GuestContact, language string, deliver bool) error {
+	ok, err := a.redis.SetNX(ctx, "guest_otp:cooldown:"+contact.key(), 1, guestOTPCooldown)
+	if err != nil {
+		return fmt.Errorf("store cooldown: %w", err)
+	}
+	if !ok {
+		return ErrGuestOTPCooldown
+	}
+	if !deliver {
+		return nil
+	}
+
+	code, err := generateOTP(guestOTPLength)
+	if err != nil {
+		return err
+	}
+	if err := a.redis.Set(ctx, "guest_otp:"+contact.key(), guestOTP{CodeHash: a.hashCode(contact, code)}, guestOTPTTL); err != nil {
+		return fmt.Errorf("store code: %w", err)
+	}
+
+	if a.notifications != nil {
+		a.notifications.Notify(contact.Notification(code, language))
+	}
+	return nil
+}
+
+// VerifyOTP checks a code sent to the contact. A code is accepted once and is
+// dropped after too many wrong guesses.
+func (a *GuestAccess) VerifyOTP(ctx context.Context, contact GuestContact, code string) error {
+	key := "guest_otp:" + contact.key()
+
+	var otp guestOTP
+	if err := a.redis.Get(ctx, key, &otp); err != nil {
+		if errors.Is(err, redis.Nil) {
+			return ErrGuestOTPInvalid
+		}
+		return fmt.Errorf("load code: %w", err)
+	}
+
+	if !hmac.Equal([]byte(otp.CodeHash), []byte(a.hashCode(contact, strings.TrimSpace(code)))) {
+		otp.Attempts++
+		if otp.Attempts >= guestOTPMaxAttempts {
+			_ = a.redis.Delete(ctx, key)
+		} else if ttl, err := a.redis.TTL(ctx, key); err == nil && ttl > 0 {
+			_ = a.redis.Set(ctx, key, otp, ttl)
+		}
+		return ErrGuestOTPInvalid
+	}
+
+	return a.redis.Delete(ctx, key)
+}
+
+// Token returns a token granting access to the orders of a verified contact
+func (a *GuestAccess) Token(contact GuestContact) (string, time.Time) {
+	expiresAt := time.Now().Add(guestTokenTTL).Truncate(time.Second)
+	payload := base64.RawURLEncoding.EncodeToString([]byte(contact.Type + "|" + contact.Value + "|" + strconv.FormatInt(expiresAt.Unix(), 10)))
+	return payload + "." + a.sign(payload), expiresAt
+}
+
+// VerifyToken returns the contact a token was issued for
+func (a *GuestAccess) VerifyToken(token string) (GuestContact, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(a.sign(payload))) {
+		return GuestContact{}, ErrGuestTokenInvalid
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return GuestContact{}, ErrGuestTokenInvalid
+	}
+	parts := strings.Split(string(decoded), "|")
+	if len(parts) != 3 {
+		return GuestContact{}, ErrGuestTokenInvalid
+	}
+	expiresAt, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return GuestContact{}, ErrGuestTokenInvalid
+	}
+	if time.Now().Unix() > expiresAt {
+		return GuestContact{}, ErrGuestTokenExpired
+	}
+	return GuestContact{Type: parts[0], Value: parts[1]}, nil
+}
+
+func (a *GuestAccess) sign(payload string) string {
+	mac := hmac.New(sha256.New, []byte("guest-orders:"+a.signingKey))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (a *GuestAccess) hashCode(contact GuestContact, code string) string {
+	mac := hmac.New(sha256.New, []byte("guest-otp:"+a.signingKey))
+	mac.Write([]byte(contact.Type + "|" + contact.Value + "|" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// generateOTP returns a random numeric code
+func generateOTP(length int) (string, error) {
+	var b strings.Builder
+	for i := 0; i < length; i++ {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(byte('0' + n.Int64()))
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,313 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"seaply/internal/storage"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// Invoice kinds
+const (
+	InvoiceKindTransaction = "TRANSACTION"
+	InvoiceKindDeposit     = "DEPOSIT"
+)
+
+// invoiceLayoutVersion is part of the cache key; bump it when the PDF layout changes
+const invoiceLayoutVersion = "2"
+
+// Errors returned when verifying a signed invoice link
+var (
+	ErrInvoiceLinkExpired = errors.New("invoice link has expired")
+	ErrInvoiceLinkInvalid = errors.New("invoice link signature is invalid")
+)
+
+// InvoiceConfig configures invoice PDF generation
+type InvoiceConfig struct {
+	APIBaseURL      string        // base of the signed download links
+	FrontendBaseURL string        // encoded in the invoice QR code
+	SigningKey      string        // signs download links
+	LinkTTL         time.Duration // validity of download links
+}
+
+// InvoiceLine is a labelled value printed on an invoice
+type InvoiceLine struct {
+	Label string
+	Value string
+}
+
+// InvoiceDocument is everything printed on a transaction or deposit invoice
+type InvoiceDocument struct {
+	Kind          string
+	InvoiceNumber string
+	Language      string
+	Region        string
+	Status        string
+	PaymentStatus string
+	Currency      string
+
+	ProductName   string
+	SKUCode       string
+	SKUName       string
+	Quantity      int
+	UnitPrice     int64
+	Account       []InvoiceLine
+	SerialNumbers []string
+
+	Subtotal   int64
+	Discount   int64
+	PromoCode  string
+	PaymentFee int64
+	Total      int64
+
+	PaymentMethod string
+	PaymentCode   string
+
+	CreatedAt time.Time
+	PaidAt    *time.Time
+	UpdatedAt time.Time
+	URL       string // public invoice page, encoded in the QR code
+}
+
+// Filename returns the download filename of the invoice PDF
+func (d *InvoiceDocument) Filename() string {
+	return d.InvoiceNumber + ".pdf"
+}
+
+// InvoiceService renders invoice PDFs, caches them in S3 and signs download links
+type InvoiceService struct {
+	pool    *pgxpool.Pool
+	storage *storage.S3Storage
+	cfg     InvoiceConfig
+}
+
+// NewInvoiceService creates an invoice service. storage may be nil, in which case
+// PDFs are rendered on every request.
+func NewInvoiceService(pool *pgxpool.Pool, s3 *storage.S3Storage, cfg InvoiceConfig) *InvoiceService {
+	if cfg.LinkTTL <= 0 {
+		cfg.LinkTTL = 7 * 24 * time.Hour
+	}
+	return &InvoiceService{
+		pool:    pool,
+		storage: s3,
+		cfg:     cfg,
+	}
+}
+
+// IsDepositInvoice reports whether an invoice number belongs to a deposit
+func IsDepositInvoice(invoiceNumber string) bool {
+	return strings.HasPrefix(invoiceNumber, "SEAD")
+}
+
+// Load loads the invoice data of a transaction or deposit
+func (s *InvoiceService) Load(ctx context.Context, invoiceNumber string) (*InvoiceDocument, error) {
+	if IsDepositInvoice(invoiceNumber) {
+		return s.loadDeposit(ctx, invoiceNumber)
+	}
+	return s.loadTransaction(ctx, invoiceNumber)
+}
+
+func (s *InvoiceService) loadTransaction(ctx context.Context, invoiceNumber string) (*InvoiceDocument, error) {
+	doc := &InvoiceDocument{Kind: InvoiceKindTransaction, InvoiceNumber: invoiceNumber}
+	var (
+		accountInputs []byte
+		nickname      *string
+		serialNumber  *string
+		promoCode     *string
+		sellPrice     int64
+		transactionID string
+		paymentCode   *string
+	)
+	err := s.pool.QueryRow(ctx, `
+		SELECT t.id, t.status::text, t.payment_status::text, t.currency::text, t.region::text,
+		       p.title, s.code, s.name, t.quantity, t.sell_price, t.discount_amount, t.promo_code,
+		       t.payment_fee, t.total_amount, t.account_inputs, t.account_nickname,
+		       t.provider_serial_number, pc.name, pd.payment_code,
+		       t.created_at, t.paid_at, COALESCE(t.updated_at, t.created_at)
+		FROM transactions t
+		JOIN products p ON t.product_id = p.id
+		JOIN skus s ON t.sku_id = s.id
+		JOIN payment_channels pc ON t.payment_channel_id = pc.id
+		LEFT JOIN payment_data pd ON pd.transaction_id = t.id
+		WHERE t.invoice_number = $1
+		LIMIT 1
+	`, invoiceNumber).Scan(
+		&transactionID, &doc.Status, &doc.PaymentStatus, &doc.Currency, &doc.Region,
+		&doc.ProductName, &doc.SKUCode, &doc.SKUName, &doc.Quantity, &sellPrice, &doc.Discount, &promoCode,
+		&doc.PaymentFee, &doc.Total, &accountInputs, &nickname,
+		&serialNumber, &doc.PaymentMethod, &paymentCode,
+		&doc.CreatedAt, &doc.PaidAt, &doc.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	doc.Language = LanguageForRegion(doc.Region)
+	doc.UnitPrice = sellPrice
+	doc.Subtotal = sellPrice * int64(doc.Quantity)
+	doc.PromoCode = firstNonEmpty(promoCode)
+	doc.PaymentCode = firstNonEmpty(paymentCode)
+	doc.URL = localizedFrontendURL(s.cfg.FrontendBaseURL, doc.Region, "invoice", invoiceNumber)
+
+	var inputs map[string]interface{}
+	_ = json.Unmarshal(accountInputs, &inputs)
+	keys := make([]string, 0, len(inputs))
+	for key := range inputs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if value := fmt.Sprint(inputs[key]); inputs[key] != nil && value != "" {
+			doc.Account = append(doc.Account, InvoiceLine{Label: key, Value: value})
+		}
+	}
+	if nick := firstNonEmpty(nickname); nick != "" {
+		doc.Account = append(doc.Account, InvoiceLine{Label: "nickname", Value: nick})
+	}
+
+	// Multi-quantity orders have one serial number per unit
+	if doc.Quantity > 1 {
+		rows, err := s.pool.Query(ctx, `
+			SELECT serial_number FROM transaction_units
+			WHERE transaction_id = $1 AND serial_number IS NOT NULL AND serial_number <> ''
+			ORDER BY unit_number
+		`, transactionID)
+		if err == nil {
+			for rows.Next() {
+				var sn string
+				if rows.Scan(&sn) == nil {
+					doc.SerialNumbers = append(doc.SerialNumbers, sn)
+				}
+			}
+			rows.Close()
+		}
+	}
+	if len(doc.SerialNumbers) == 0 && firstNonEmpty(serialNumber) != "" {
+		doc.SerialNumbers = []string{*serialNumber}
+	}
+	return doc, nil
+}
+
+func (s *InvoiceService) loadDeposit(ctx context.Context, invoiceNumber string) (*InvoiceDocument, error) {
+	doc := &InvoiceDocument{Kind: InvoiceKindDeposit, InvoiceNumber: invoiceNumber, Quantity: 1}
+	var paymentCode *string
+	err := s.pool.QueryRow(ctx, `
+		SELECT d.status::text, d.currency::text, d.region::text,
+		       d.amount, d.payment_fee, d.total_amount, pc.name, pd.payment_code,
+		       d.created_at, d.paid_at, COALESCE(d.updated_at, d.created_at)
+		FROM deposits d
+		JOIN payment_channels pc ON d.payment_channel_id = pc.id
+		LEFT JOIN payment_data pd ON pd.invoice_number = d.invoice_number
+		WHERE d.invoice_number = $1
+		LIMIT 1
+	`, invoiceNumber).Scan(
+		&doc.Status, &doc.Currency, &doc.Region,
+		&doc.Subtotal, &doc.PaymentFee, &doc.Total, &doc.PaymentMethod, &paymentCode,
+		&doc.CreatedAt, &doc.PaidAt, &doc.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	doc.Language = LanguageForRegion(doc.Region)
+	doc.PaymentStatus = doc.Status
+	doc.UnitPrice = doc.Subtotal
+	doc.PaymentCode = firstNonEmpty(paymentCode)
+	doc.URL = localizedFrontendURL(s.cfg.FrontendBaseURL, doc.Region, "deposit", invoiceNumber)
+	return doc, nil
+}
+
+// PDF returns the invoice PDF, from the S3 cache when the invoice hasn't changed since it was rendered
+func (s *InvoiceService) PDF(ctx context.Context, invoiceNumber string) ([]byte, *InvoiceDocument, error) {
+	doc, err := s.Load(ctx, invoiceNumber)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key := s.cacheKey(doc)
+	if s.storage != nil {
+		if data, err := s.storage.Get(ctx, key); err == nil && len(data) > 0 {
+			return data, doc, nil
+		}
+	}
+
+	data, err := RenderInvoicePDF(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if s.storage != nil {
+		if err := s.storage.PutPrivate(ctx, key, data, "application/pdf"); err != nil {
+			log.Warn().Err(err).Str("invoice_number", invoiceNumber).Msg("Failed to cache invoice PDF")
+		}
+	}
+	return data, doc, nil
+}
+
+// Attachment returns the invoice PDF as an email attachment
+func (s *InvoiceService) Attachment(ctx context.Context, invoiceNumber string) (Attachment, error) {
+	data, doc, err := s.PDF(ctx, invoiceNumber)
+	if err != nil {
+		return Attachment{}, err
+	}
+	return Attachment{Filename: doc.Filename(), ContentType: "application/pdf", Content: data}, nil
+}
+
+// cacheKey changes whenever the invoice changes, so stale PDFs are never served
+func (s *InvoiceService) cacheKey(doc *InvoiceDocument) string {
+	version := sha256.Sum256([]byte(strings.Join([]string{
+		invoiceLayoutVersion, doc.Status, doc.PaymentStatus, doc.Language,
+		strconv.FormatInt(doc.UpdatedAt.UnixNano(), 10),
+	}, "|")))
+	return fmt.Sprintf("%s/%s/%s.pdf", storage.FolderInvoice, doc.InvoiceNumber, hex.EncodeToString(version[:8]))
+}
+
+// SignedURL returns a download link for the invoice PDF that expires after the configured TTL
+func (s *InvoiceService) SignedURL(invoiceNumber string) (string, time.Time) {
+	expiresAt := time.Now().Add(s.cfg.LinkTTL).Truncate(time.Second)
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+
+	path := "/v2/invoices/pdf"
+	if IsDepositInvoice(invoiceNumber) {
+		path = "/v2/deposits/invoices/pdf"
+	}
+	query := url.Values{}
+	query.Set("invoiceNumber", invoiceNumber)
+	query.Set("expires", expires)
+	query.Set("signature", s.sign(invoiceNumber, expires))
+	return strings.TrimSuffix(s.cfg.APIBaseURL, "/") + path + "?" + query.Encode(), expiresAt
+}
+
+// VerifySignedURL checks the expiry and signature of a download link
+func (s *InvoiceService) VerifySignedURL(invoiceNumber, expires, signature string) error {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrInvoiceLinkInvalid
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.sign(invoiceNumber, expires))) {
+		return ErrInvoiceLinkInvalid
+	}
+	if time.Now().Unix() > expiresAt {
+		return ErrInvoiceLinkExpired
+	}
+	return nil
+}
+
+func (s *InvoiceService) sign(invoiceNumber, expires string) string {
+	mac := hmac.New(sha256.New, []byte("invoice-pdf:"+s.cfg.SigningKey))
+	mac.Write([]byte(invoiceNumber + "|" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"seaply/internal/utils"
+)
+
+// invoiceLabels holds the printed labels per language
+var invoiceLabels = map[string]map[string]string{
+	"en": {
+		"invoice":       "INVOICE",
+		"receipt":       "RECEIPT",
+		"depositTitle":  "BALANCE TOP UP",
+		"invoiceNumber": "Invoice Number",
+		"date":          "Date",
+		"paidAt":        "Paid At",
+		"status":        "Status",
+		"product":       "Product",
+		"item":          "Item",
+		"quantity":      "Quantity",
+		"unitPrice":     "Unit Price",
+		"account":       "Account",
+		"serialNumber":  "Serial Number",
+		"payment":       "Payment",
+		"method":        "Method",
+		"paymentCode":   "Payment Code",
+		"subtotal":      "Subtotal",
+		"discount":      "Discount",
+		"paymentFee":    "Payment Fee",
+		"total":         "Total",
+		"deposit":       "Balance Deposit",
+		"scan":          "Scan to view this invoice online",
+		"footer":        "This document is generated electronically and is valid without a signature.",
+	},
+	"id": {
+		"invoice":       "FAKTUR",
+		"receipt":       "BUKTI PEMBAYARAN",
+		"depositTitle":  "ISI ULANG SALDO",
+		"invoiceNumber": "Nomor Invoice",
+		"date":          "Tanggal",
+		"paidAt":        "Dibayar Pada",
+		"status":        "Status",
+		"product":       "Produk",
+		"item":          "Item",
+		"quantity":      "Jumlah",
+		"unitPrice":     "Harga Satuan",
+		"account":       "Akun",
+		"serialNumber":  "Nomor Seri",
+		"payment":       "Pembayaran",
+		"method":        "Metode",
+		"paymentCode":   "Kode Pembayaran",
+		"subtotal":      "Subtotal",
+		"discount":      "Diskon",
+		"paymentFee":    "Biaya Pembayaran",
+		"total":         "Total",
+		"deposit":       "Deposit Saldo",
+		"scan":          "Pindai untuk melihat invoice ini secara online",
+		"footer":        "Dokumen ini dibuat secara elektronik dan sah tanpa tanda tangan.",
+	},
+}
+
+// accountLabels translates the common account input keys
+var accountLabels = map[string]map[string]string{
+	"en": {"userId": "User ID", "zoneId": "Zone ID", "serverId": "Server", "nickname": "Nickname", "phoneNumber": "Phone Number"},
+	"id": {"userId": "User ID", "zoneId": "Zone ID", "serverId": "Server", "nickname": "Nickname", "phoneNumber": "Nomor HP"},
+}
+
+// Invoice layout, in points
+const (
+	invoiceMargin     = 48.0
+	invoiceLineHeight = 16.0
+	invoiceQRSize     = 96.0
+)
+
+// invoiceWriter lays out an invoice top to bottom
+type invoiceWriter struct {
+	pdf *utils.PDFDocument
+	y   float64
+}
+
+func (iw *invoiceWriter) ensureSpace(height float64) {
+	if iw.y+height > utils.PDFPageHeight-invoiceMargin {
+		iw.pdf.AddPage()
+		iw.y = invoiceMargin
+	}
+}
+
+func (iw *invoiceWriter) section(title string) {
+	iw.ensureSpace(invoiceLineHeight * 3)
+	iw.y += invoiceLineHeight / 2
+	iw.pdf.Text(invoiceMargin, iw.y, 11, true, title)
+	iw.y += 5
+	iw.pdf.Line(invoiceMargin, iw.y, utils.PDFPageWidth-invoiceMargin, iw.y, 0.5, 0.8)
+	iw.y += invoiceLineHeight
+}
+
+// row prints a label on the left and a value wrapped to the right column
+func (iw *invoiceWriter) row(label, value string, bold bool) {
+	valueX := invoiceMargin + 150
+	lines := utils.PDFWrapText(value, 10, bold, utils.PDFPageWidth-invoiceMargin-valueX)
+	iw.ensureSpace(float64(len(lines)) * invoiceLineHeight)
+	iw.pdf.Text(invoiceMargin, iw.y, 10, false, label)
+	for _, line := range lines {
+		iw.pdf.Text(valueX, iw.y, 10, bold, line)
+		iw.y += invoiceLineHeight
+	}
+}
+
+// amount prints a label and a right aligned amount
+func (iw *invoiceWriter) amount(label, value string, bold bool) {
+	size := 10.0
+	if bold {
+		size = 12
+	}
+	iw.ensureSpace(invoiceLineHeight)
+	iw.pdf.Text(utils.PDFPageWidth/2, iw.y, size, bold, label)
+	iw.pdf.TextRight(utils.PDFPageWidth-invoiceMargin, iw.y, size, bold, value)
+	iw.y += invoiceLineHeight + (size - 10)
+}
+
+// RenderInvoicePDF renders an invoice document as a PDF
+func RenderInvoicePDF(doc *InvoiceDocument) ([]byte, error) {
+	labels, ok := invoiceLabels[doc.Language]
+	if !ok {
+		labels = invoiceLabels["en"]
+	}
+	iw := &invoiceWriter{pdf: utils.NewPDFDocument(), y: invoiceMargin}
+	iw.pdf.AddPage()
+	pdf := iw.pdf
+
+	title := labels["invoice"]
+	if isPaidInvoice(doc) {
+		title = labels["receipt"]
+	}
+
+	// Header band
+	pdf.SetColor(0.11, 0.31, 0.85)
+	pdf.Rect(0, 0, utils.PDFPageWidth, 6)
+	pdf.SetColor(0, 0, 0)
+	pdf.Text(invoiceMargin, iw.y+14, 22, true, title)
+	if doc.Kind == InvoiceKindDeposit {
+		pdf.Text(invoiceMargin, iw.y+32, 10, false, labels["depositTitle"])
+	}
+
+	if doc.URL != "" {
+		qr, err := utils.GenerateQRCode(doc.URL, 256)
+		if err != nil {
+			return nil, fmt.Errorf("generate QR code: %w", err)
+		}
+		qrX := utils.PDFPageWidth - invoiceMargin - invoiceQRSize
+		if err := pdf.Image(qr, qrX, iw.y-8, invoiceQRSize, invoiceQRSize); err != nil {
+			return nil, err
+		}
+		pdf.TextRight(utils.PDFPageWidth-invoiceMargin, iw.y+invoiceQRSize, 7, false, labels["scan"])
+	}
+	iw.y += 56
+
+	iw.row(labels["invoiceNumber"], doc.InvoiceNumber, true)
+	iw.row(labels["date"], formatInvoiceTime(doc.CreatedAt), false)
+	if doc.PaidAt != nil {
+		iw.row(labels["paidAt"], formatInvoiceTime(*doc.PaidAt), false)
+	}
+	status := doc.Status
+	if doc.PaymentStatus != "" && doc.PaymentStatus != doc.Status {
+		status += " / " + doc.PaymentStatus
+	}
+	iw.row(labels["status"], status, true)
+	if iw.y < invoiceMargin+invoiceQRSize+24 {
+		iw.y = invoiceMargin + invoiceQRSize + 24
+	}
+
+	// Product
+	iw.section(labels["product"])
+	if doc.Kind == InvoiceKindDeposit {
+		iw.row(labels["item"], labels["deposit"], true)
+	} else {
+		iw.row(labels["product"], doc.ProductName, true)
+		iw.row(labels["item"], fmt.Sprintf("%s (%s)", doc.SKUName, doc.SKUCode), false)
+		iw.row(labels["quantity"], fmt.Sprint(doc.Quantity), false)
+		iw.row(labels["unitPrice"], invoiceAmount(doc.UnitPrice, doc.Currency), false)
+	}
+
+	// Account inputs
+	if len(doc.Account) > 0 {
+		iw.section(labels["account"])
+		names := accountLabels[doc.Language]
+		for _, line := range doc.Account {
+			label := names[line.Label]
+			if label == "" {
+				label = line.Label
+			}
+			iw.row(label, line.Value, false)
+		}
+	}
+
+	// Delivered serial numbers
+	if len(doc.SerialNumbers) > 0 {
+		iw.section(labels["serialNumber"])
+		for i, sn := range doc.SerialNumbers {
+			label := labels["serialNumber"]
+			if len(doc.SerialNumbers) > 1 {
+				label = fmt.Sprintf("#%d", i+1)
+			}
+			iw.row(label, sn, true)
+		}
+	}
+
+	// Payment
+	iw.section(labels["payment"])
+	iw.row(labels["method"], doc.PaymentMethod, false)
+	if doc.PaymentCode != "" && !isPaidInvoice(doc) {
+		iw.row(labels["paymentCode"], doc.PaymentCode, true)
+	}
+
+	// Totals
+	iw.y += invoiceLineHeight / 2
+	iw.amount(labels["subtotal"], invoiceAmount(doc.Subtotal, doc.Currency), false)
+	if doc.Discount > 0 {
+		label := labels["discount"]
+		if doc.PromoCode != "" {
+			label += " (" + doc.PromoCode + ")"
+		}
+		iw.amount(label, "-"+invoiceAmount(doc.Discount, doc.Currency), false)
+	}
+	iw.amount(labels["paymentFee"], invoiceAmount(doc.PaymentFee, doc.Currency), false)
+	iw.ensureSpace(invoiceLineHeight * 2)
+	pdf.Line(utils.PDFPageWidth/2, iw.y-10, utils.PDFPageWidth-invoiceMargin, iw.y-10, 0.5, 0.6)
+	iw.y += 4
+	iw.amount(labels["total"], invoiceAmount(doc.Total, doc.Currency), true)
+
+	// Footer
+	iw.y += invoiceLineHeight
+	iw.ensureSpace(invoiceLineHeight)
+	pdf.SetColor(0.4, 0.4, 0.4)
+	for _, line := range utils.PDFWrapText(labels["footer"], 8, false, utils.PDFPageWidth-invoiceMargin*2) {
+		pdf.Text(invoiceMargin, iw.y, 8, false, line)
+		iw.y += 11
+	}
+
+	return pdf.Bytes()
+}
+
+// isPaidInvoice reports whether the invoice is printed as a receipt
+func isPaidInvoice(doc *InvoiceDocument) bool {
+	return doc.PaymentStatus == "PAID" || doc.Status == "SUCCESS"
+}
+
+// invoiceAmount formats an amount with the currency code where the symbol isn't in the PDF font
+func invoiceAmount(amount int64, currency string) string {
+	formatted := utils.FormatCurrency(float64(amount), currency)
+	return strings.NewReplacer("₱", "PHP ", "฿", "THB ").Replace(formatted)
+}
+
+func formatInvoiceTime(t time.Time) string {
+	return t.Format("02 Jan 2006 15:04 MST")
+}
@@ -0,0 +1,317 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"seaply/internal/database"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// InvoiceStatusChannel carries invoice status changes to every API instance
+const InvoiceStatusChannel = "invoices:status"
+
+// Stream limits. A new stream past a limit closes the oldest one instead of being
+// refused, so nobody holding the invoice number can lock its owner out.
+const (
+	invoiceStreamTokenTTL       = 24 * time.Hour
+	invoiceStreamBuffer         = 4
+	maxInvoiceStreamsPerInvoice = 20
+	maxInvoiceStreamsPerClient  = 3
+)
+
+// Errors returned by the invoice stream
+var (
+	ErrInvoiceStreamTokenInvalid = errors.New("invoice stream token is invalid")
+	ErrInvoiceStreamTokenExpired = errors.New("invoice stream token has expired")
+)
+
+// InvoiceStatusEvent is the payment and fulfilment state of an invoice pushed to subscribers.
+// Every event carries the full state, so a subscriber that misses one catches up with the next.
+type InvoiceStatusEvent struct {
+	InvoiceNumber string     `json:"invoiceNumber"`
+	Kind          string     `json:"kind"`
+	Status        string     `json:"status"`
+	PaymentStatus string     `json:"paymentStatus"`
+	Quantity      int        `json:"quantity"`
+	Delivered     int        `json:"delivered"`
+	SerialNumber  string     `json:"serialNumber,omitempty"`
+	ExpiresAt     *time.Time `json:"expiresAt,omitempty"` // unpaid invoices expire when fetched after this
+	UpdatedAt     time.Time  `json:"updatedAt"`
+}
+
+// Final reports whether the invoice has reached a status it won't leave
+func (e InvoiceStatusEvent) Final() bool {
+	switch e.Status {
+	case "SUCCESS", "FAILED", "EXPIRED", "REFUNDED":
+		return true
+	}
+	return false
+}
+
+// InvoiceStream pushes invoice status changes to the clients watching an invoice.
+// Changes are published on Redis so a client connected to any API instance sees
+// changes made by the others; without Redis they are delivered locally only.
+type InvoiceStream struct {
+	pool       *pgxpool.Pool
+	redis      *database.RedisClient
+	signingKey string
+
+	mu          sync.Mutex
+	subscribers map[string]map[chan InvoiceStatusEvent]invoiceSubscriber
+	seq         uint64
+}
+
+// invoiceSubscriber is the client behind a stream and the order it subscribed in
+type invoiceSubscriber struct {
+	client string
+	seq    uint64
+}
+
+// NewInvoiceStream creates an invoice stream
+func NewInvoiceStream(pool *pgxpool.Pool, redis *database.RedisClient, signingKey string) *InvoiceStream {
+	return &InvoiceStream{
+		pool:        pool,
+		redis:       redis,
+		signingKey:  signingKey,
+		subscribers: make(map[string]map[chan InvoiceStatusEvent]invoiceSubscriber),
+	}
+}
+
+// Snapshot loads the current state of an invoice
+func (s *InvoiceStream) Snapshot(ctx context.Context, invoiceNumber string) (InvoiceStatusEvent, error) {
+	event := InvoiceStatusEvent{InvoiceNumber: invoiceNumber, Quantity: 1}
+
+	if IsDepositInvoice(invoiceNumber) {
+		event.Kind = InvoiceKindDeposit
+		err := s.pool.QueryRow(ctx, `
+			SELECT status::text, expired_at, COALESCE(updated_at, created_at)
+			FROM deposits
+			WHERE invoice_number = $1
+		`, invoiceNumber).Scan(&event.Status, &event.ExpiresAt, &event.UpdatedAt)
+		if err != nil {
+			return event, err
+		}
+		event.PaymentStatus = "UNPAID"
+		switch event.Status {
+		case "SUCCESS", "REFUNDED":
+			event.PaymentStatus = "PAID"
+			event.Delivered = 1
+		case "EXPIRED":
+			event.PaymentStatus = "EXPIRED"
+		}
+		if event.Status != "PENDING" {
+			event.ExpiresAt = nil
+		}
+		return event, nil
+	}
+
+	event.Kind = InvoiceKindTransaction
+	err := s.pool.QueryRow(ctx, `
+		SELECT t.status::text, t.payment_status::text, t.quantity, COALESCE(t.provider_serial_number, ''),
+		       t.expired_at, COALESCE(t.updated_at, t.created_at),
+		       (SELECT COUNT(*) FROM transaction_units u WHERE u.transaction_id = t.id AND u.status = 'SUCCESS')
+		FROM transactions t
+		WHERE t.invoice_number = $1
+	`, invoiceNumber).Scan(&event.Status, &event.PaymentStatus, &event.Quantity, &event.SerialNumber,
+		&event.ExpiresAt, &event.UpdatedAt, &event.Delivered)
+	if err != nil {
+		return event, err
+	}
+	if event.Quantity <= 1 && event.Status == "SUCCESS" {
+		event.Delivered = 1
+	}
+	if event.PaymentStatus != "UNPAID" {
+		event.ExpiresAt = nil
+	}
+	return event, nil
+}
+
+// Publish sends the current state of an invoice to its subscribers on every instance
+func (s *InvoiceStream) Publish(ctx context.Context, invoiceNumber string) error {
+	event, err := s.Snapshot(ctx, invoiceNumber)
+	if err != nil {
+		return fmt.Errorf("load invoice status: %w", err)
+	}
+
+	if s.redis == nil {
+		s.deliver(event)
+		return nil
+	}
+	return s.redis.Publish(ctx, InvoiceStatusChannel, event)
+}
+
+// PublishTransaction publishes the state of a transaction by its ID
+func (s *InvoiceStream) PublishTransaction(ctx context.Context, transactionID string) error {
+	var invoiceNumber string
+	if err := s.pool.QueryRow(ctx, `SELECT invoice_number FROM transactions WHERE id = $1`, transactionID).Scan(&invoiceNumber); err != nil {
+		return fmt.Errorf("load transaction: %w", err)
+	}
+	return s.Publish(ctx, invoiceNumber)
+}
+
+// PublishDeposit publishes the state of a deposit by its ID
+func (s *InvoiceStream) PublishDeposit(ctx context.Context, depositID string) error {
+	var invoiceNumber string
+	if err := s.pool.QueryRow(ctx, `SELECT invoice_number FROM deposits WHERE id = $1`, depositID).Scan(&invoiceNumber); err != nil {
+		return fmt.Errorf("load deposit: %w", err)
+	}
+	return s.Publish(ctx, invoiceNumber)
+}
+
+// Subscribe registers a subscriber for an invoice on behalf of a client, identified
+// by its IP. When the client or the invoice has too many streams open the oldest one
+// is closed. The returned function unsubscribes and must be called when the client
+// goes away; the channel is closed when the stream was replaced.
+func (s *InvoiceStream) Subscribe(invoiceNumber, client string) (<-chan InvoiceStatusEvent, func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subs := s.subscribers[invoiceNumber]
+	if subs == nil {
+		subs = make(map[chan InvoiceStatusEvent]invoiceSubscriber)
+		s.subscribers[invoiceNumber] = subs
+	}
+
+	clientStreams := 0
+	for _, sub := range subs {
+		if sub.client == client {
+			clientStreams++
+		}
+	}
+	if clientStreams >= maxInvoiceStreamsPerClient {
+		s.evictOldest(subs, client)
+	}
+	if len(subs) >= maxInvoiceStreamsPerInvoice {
+		s.evictOldest(subs, "")
+	}
+
+	s.seq++
+	ch := make(chan InvoiceStatusEvent, invoiceStreamBuffer)
+	subs[ch] = invoiceSubscriber{client: client, seq: s.seq}
+
+	unsubscribe := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.subscribers[invoiceNumber], ch)
+		if len(s.subscribers[invoiceNumber]) == 0 {
+			delete(s.subscribers, invoiceNumber)
+		}
+	}
+	return ch, unsubscribe
+}
+
+// evictOldest closes the oldest stream of subs, of the given client when one is set.
+// The caller holds s.mu.
+func (s *InvoiceStream) evictOldest(subs map[chan InvoiceStatusEvent]invoiceSubscriber, client string) {
+	var oldest chan InvoiceStatusEvent
+	var oldestSeq uint64
+	for ch, sub := range subs {
+		if client != "" && sub.client != client {
+			continue
+		}
+		if oldest == nil || sub.seq < oldestSeq {
+			oldest, oldestSeq = ch, sub.seq
+		}
+	}
+	if oldest != nil {
+		delete(subs, oldest)
+		close(oldest)
+	}
+}
+
+// deliver hands an event to the local subscribers of its invoice. A subscriber that
+// fell behind drops its oldest event, since the newest one carries the full state.
+func (s *InvoiceStream) deliver(event InvoiceStatusEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for ch := range s.subscribers[event.InvoiceNumber] {
+		select {
+		case ch <- event:
+		default:
+			select {
+			case <-ch:
+			default:
+			}
+			select {
+			case ch <- event:
+			default:
+			}
+		}
+	}
+}
+
+// Start delivers the changes published by every instance to the local subscribers
+func (s *InvoiceStream) Start(ctx context.Context) {
+	if s.redis == nil {
+		return
+	}
+	pubsub := s.redis.Subscribe(ctx, InvoiceStatusChannel)
+	go func() {
+		defer pubsub.Close()
+
+		ch := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+
+				var event InvoiceStatusEvent
+				if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+					log.Warn().Err(err).Msg("Invalid invoice status message")
+					continue
+				}
+				s.deliver(event)
+			}
+		}
+	}()
+}
+
+// Token returns a token that lets anyone holding it watch one invoice, so guests
+// can subscribe without a session
+func (s *InvoiceStream) Token(invoiceNumber string) (string, time.Time) {
+	expiresAt := time.Now().Add(invoiceStreamTokenTTL).Truncate(time.Second)
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	return expires + "." + s.sign(invoiceNumber, expires), expiresAt
+}
+
+// VerifyToken checks that a token was issued for the invoice and hasn't expired
+func (s *InvoiceStream) VerifyToken(invoiceNumber, token string) error {
+	expires, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return ErrInvoiceStreamTokenInvalid
+	}
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrInvoiceStreamTokenInvalid
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.sign(invoiceNumber, expires))) {
+		return ErrInvoiceStreamTokenInvalid
+	}
+	if time.Now().Unix() > expiresAt {
+		return ErrInvoiceStreamTokenExpired
+	}
+	return nil
+}
+
+func (s *InvoiceStream) sign(invoiceNumber, expires string) string {
+	mac := hmac.New(sha256.New, []byte("invoice-stream:"+s.signingKey))
+	mac.Write([]byte(invoiceNumber + "|" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
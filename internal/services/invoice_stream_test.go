@@ -0,0 +1,118 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func isClosed(ch <-chan InvoiceStatusEvent) bool {
+	select {
+	case _, ok := <-ch:
+		return !ok
+	default:
+		return false
+	}
+}
+
+func TestInvoiceStreamClientLimitClosesOldest(t *testing.T) {
+	s := NewInvoiceStream(nil, nil, "key")
+
+	var streams []<-chan InvoiceStatusEvent
+	for i := 0; i < maxInvoiceStreamsPerClient+1; i++ {
+		ch, _ := s.Subscribe("SEAI1", "203.0.113.7")
+		streams = append(streams, ch)
+	}
+	other, _ := s.Subscribe("SEAI1", "198.51.100.2")
+
+	if !isClosed(streams[0]) {
+		t.Error("oldest stream of the client is still open")
+	}
+	for i, ch := range streams[1:] {
+		if isClosed(ch) {
+			t.Errorf("stream %d of the client was closed", i+1)
+		}
+	}
+	if isClosed(other) {
+		t.Error("stream of another client was closed")
+	}
+}
+
+func TestInvoiceStreamInvoiceLimitClosesOldest(t *testing.T) {
+	s := NewInvoiceStream(nil, nil, "key")
+
+	var streams []<-chan InvoiceStatusEvent
+	for i := 0; i < maxInvoiceStreamsPerInvoice; i++ {
+		ch, _ := s.Subscribe("SEAI1", fmt.Sprintf("203.0.113.%d", i))
+		streams = append(streams, ch)
+	}
+
+	// The owner still gets a stream when an attacker holds every slot
+	owner, _ := s.Subscribe("SEAI1", "198.51.100.2")
+	if isClosed(owner) {
+		t.Fatal("owner stream was closed")
+	}
+	if !isClosed(streams[0]) {
+		t.Error("oldest stream of the invoice is still open")
+	}
+	if got := len(s.subscribers["SEAI1"]); got != maxInvoiceStreamsPerInvoice {
+		t.Errorf("%d streams open, want %d", got, maxInvoiceStreamsPerInvoice)
+	}
+
+	// Other invoices are not affected
+	if ch, _ := s.Subscribe("SEAI2", "203.0.113.0"); isClosed(ch) {
+		t.Error("stream of another invoice was closed")
+	}
+}
+
+func TestInvoiceStreamUnsubscribe(t *testing.T) {
+	s := NewInvoiceStream(nil, nil, "key")
+
+	first, unsubscribeFirst := s.Subscribe("SEAI1", "203.0.113.7")
+	_, unsubscribeSecond := s.Subscribe("SEAI1", "203.0.113.7")
+
+	s.deliver(InvoiceStatusEvent{InvoiceNumber: "SEAI1", Status: "PROCESSING"})
+	if event := <-first; event.Status != "PROCESSING" {
+		t.Errorf("delivered status %q, want PROCESSING", event.Status)
+	}
+
+	unsubscribeFirst()
+	unsubscribeSecond()
+	if _, ok := s.subscribers["SEAI1"]; ok {
+		t.Error("invoice still has subscribers after every stream unsubscribed")
+	}
+}
+
+func TestInvoiceStreamToken(t *testing.T) {
+	s := NewInvoiceStream(nil, nil, "key")
+	token, _ := s.Token("SEAI1")
+	expired := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+
+	tests := []struct {
+		name    string
+		invoice string
+		token   string
+		want    error
+	}{
+		{"valid", "SEAI1", token, nil},
+		{"other invoice", "SEAI2", token, ErrInvoiceStreamTokenInvalid},
+		{"no signature", "SEAI1", "12345", ErrInvoiceStreamTokenInvalid},
+		{"bad expiry", "SEAI1", "soon." + s.sign("SEAI1", "soon"), ErrInvoiceStreamTokenInvalid},
+		{"tampered expiry", "SEAI1", "9999999999." + s.sign("SEAI1", expired), ErrInvoiceStreamTokenInvalid},
+		{"expired", "SEAI1", expired + "." + s.sign("SEAI1", expired), ErrInvoiceStreamTokenExpired},
+		{"other key", "SEAI1", token, ErrInvoiceStreamTokenInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier := s
+			if tt.name == "other key" {
+				verifier = NewInvoiceStream(nil, nil, "other")
+			}
+			if err := verifier.VerifyToken(tt.invoice, tt.token); err != tt.want {
+				t.Errorf("VerifyToken() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,459 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// Channel is a notification delivery channel
+type Channel string
+
+const (
+	ChannelEmail    Channel = "email"
+	ChannelWhatsApp Channel = "whatsapp"
+	ChannelTelegram Channel = "telegram"
+)
+
+// EventType identifies what happened and which template is rendered
+type EventType string
+
+const (
+	EventOrderSuccess   EventType = "order_success"
+	EventOrderFailed    EventType = "order_failed"
+	EventDepositSuccess EventType = "deposit_success"
+	EventRefund         EventType = "refund"
+	EventSecurityAlert  EventType = "security_alert"
+	EventInvoice        EventType = "invoice"
+	EventPriceAlert     EventType = "price_alert"
+	EventBalanceAlert   EventType = "balance_alert"
+	EventGuestOTP       EventType = "guest_otp"
+	// Approval events go to admins
+	EventApprovalRequest  EventType = "approval_request"
+	EventApprovalDecision EventType = "approval_decision"
+	// Checkouts held by the risk engine go to the ops channel
+	EventRiskHold EventType = "risk_hold"
+)
+
+// defaultEventChannels lists the channels used when a notification doesn't specify any.
+// Security alerts also go to the security Telegram chat, see NotifySecurityAlert.
+// Price alerts are for admins and go to the ops channel first.
+var defaultEventChannels = map[EventType][]Channel{
+	EventOrderSuccess:   {ChannelEmail, ChannelWhatsApp},
+	EventOrderFailed:    {ChannelEmail, ChannelWhatsApp},
+	EventDepositSuccess: {ChannelEmail, ChannelWhatsApp},
+	EventRefund:         {ChannelEmail, ChannelWhatsApp},
+	EventSecurityAlert:  {ChannelEmail, ChannelTelegram},
+	EventInvoice:        {ChannelEmail},
+	EventPriceAlert:     {ChannelTelegram, ChannelEmail},
+	EventBalanceAlert:   {ChannelTelegram, ChannelEmail},
+	EventGuestOTP:       {ChannelEmail, ChannelWhatsApp},
+	// Approval requests go to the ops channel and to each approver
+	EventApprovalRequest:  {ChannelTelegram, ChannelEmail},
+	EventApprovalDecision: {ChannelEmail},
+	EventRiskHold:         {ChannelTelegram},
+}
+
+// Message is a rendered notification ready to be delivered by a Notifier
+type Message struct {
+	Channel       Channel      `json:"channel"`
+	Event         EventType    `json:"event"`
+	To            string       `json:"to"`
+	Subject       string       `json:"subject"`
+	Body          string       `json:"body"`
+	TextBody      string       `json:"textBody"`
+	ReferenceType string       `json:"referenceType,omitempty"`
+	ReferenceID   string       `json:"referenceId,omitempty"`
+	Attachments   []Attachment `json:"attachments,omitempty"` // email only
+}
+
+// Attachment is a file sent with an email
+type Attachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"contentType"`
+	Content     []byte `json:"content"`
+}
+
+// Notifier delivers a message over a single channel
+type Notifier interface {
+	Channel() Channel
+	Send(ctx context.Context, msg Message) error
+}
+
+// TxNotifier is a Notifier that can also queue a message in a database transaction,
+// so it is only sent once the transaction commits
+type TxNotifier interface {
+	Notifier
+	SendTx(ctx context.Context, db DBTX, msg Message) error
+}
+
+// Notification is an event to be rendered and delivered to a recipient
+type Notification struct {
+	Event         EventType
+	Language      string
+	Email         string
+	Phone         string
+	TelegramID    string
+	Channels      []Channel
+	ReferenceType string
+	ReferenceID   string
+	Data          map[string]interface{}
+}
+
+// recipient returns the address of the recipient on the given channel
+func (n Notification) recipient(channel Channel) string {
+	switch channel {
+	case ChannelEmail:
+		return n.Email
+	case ChannelWhatsApp:
+		return n.Phone
+	case ChannelTelegram:
+		return n.TelegramID
+	}
+	return ""
+}
+
+// NotificationConfig configures the notification service
+type NotificationConfig struct {
+	Workers         int
+	QueueSize       int
+	MaxRetries      int
+	RetryDelay      time.Duration
+	FrontendBaseURL string
+	SecurityChatID  string // Telegram chat of security alerts, they are not sent to the ops chat
+}
+
+type notificationJob struct {
+	notification Notification
+	channel      Channel
+}
+
+// NotificationService renders notifications and dispatches them asynchronously
+type NotificationService struct {
+	notifiers map[Channel]Notifier
+	mu        sync.RWMutex
+
+	enabled   map[Channel]bool
+	enabledMu sync.RWMutex
+
+	templates *TemplateRenderer
+	queue     chan notificationJob
+	cfg       NotificationConfig
+}
+
+// NewNotificationService creates a new notification service
+func NewNotificationService(templates *TemplateRenderer, cfg NotificationConfig) *NotificationService {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 4
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1000
+	}
+	if cfg.MaxRetries <= 0 {
+		cfg.MaxRetries = 3
+	}
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = 2 * time.Second
+	}
+
+	return &NotificationService{
+		notifiers: make(map[Channel]Notifier),
+		enabled: map[Channel]bool{
+			ChannelEmail:    true,
+			ChannelWhatsApp: true,
+			ChannelTelegram: true,
+		},
+		templates: templates,
+		queue:     make(chan notificationJob, cfg.QueueSize),
+		cfg:       cfg,
+	}
+}
+
+// Register registers a notifier for its channel
+func (s *NotificationService) Register(notifier Notifier) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notifiers[notifier.Channel()] = notifier
+}
+
+// Get returns the notifier registered for a channel
+func (s *NotificationService) Get(channel Channel) (Notifier, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	notifier, ok := s.notifiers[channel]
+	if !ok {
+		return nil, fmt.Errorf("notifier not found: %s", channel)
+	}
+	return notifier, nil
+}
+
+// SetChannelEnabled enables or disables a channel
+func (s *NotificationService) SetChannelEnabled(channel Channel, enabled bool) {
+	s.enabledMu.Lock()
+	s.enabled[channel] = enabled
+	s.enabledMu.Unlock()
+}
+
+// IsChannelEnabled reports whether a channel is enabled
+func (s *NotificationService) IsChannelEnabled(channel Channel) bool {
+	s.enabledMu.RLock()
+	defer s.enabledMu.RUnlock()
+	return s.enabled[channel]
+}
+
+// LoadSettings reads the notification.*Enabled toggles from the settings table
+func (s *NotificationService) LoadSettings(ctx context.Context, pool *pgxpool.Pool) error {
+	rows, err := pool.Query(ctx, `
+		SELECT key, value FROM settings WHERE category = 'notification'
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to load notification settings: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			return fmt.Errorf("failed to scan notification setting: %w", err)
+		}
+
+		var enabled bool
+		if err := json.Unmarshal(value, &enabled); err != nil {
+			continue
+		}
+
+		switch key {
+		case "emailEnabled":
+			s.SetChannelEnabled(ChannelEmail, enabled)
+		case "whatsappEnabled":
+			s.SetChannelEnabled(ChannelWhatsApp, enabled)
+		case "telegramEnabled":
+			s.SetChannelEnabled(ChannelTelegram, enabled)
+		}
+	}
+
+	return rows.Err()
+}
+
+// Start starts the delivery workers and periodically reloads channel settings
+func (s *NotificationService) Start(ctx context.Context, pool *pgxpool.Pool, settingsInterval time.Duration) {
+	for i := 0; i < s.cfg.Workers; i++ {
+		go s.worker(ctx)
+	}
+
+	if pool == nil {
+		return
+	}
+
+	go func() {
+		if err := s.LoadSettings(ctx, pool); err != nil {
+			log.Warn().Err(err).Msg("Failed to load notification settings")
+		}
+
+		ticker := time.NewTicker(settingsInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.LoadSettings(ctx, pool); err != nil {
+					log.Warn().Err(err).Msg("Failed to reload notification settings")
+				}
+			}
+		}
+	}()
+}
+
+// Notify queues a notification for asynchronous delivery on every applicable channel.
+// It does nothing on a nil service.
+func (s *NotificationService) Notify(n Notification) {
+	if s == nil {
+		return
+	}
+	for _, channel := range s.channelsFor(n) {
+		select {
+		case s.queue <- notificationJob{notification: n, channel: channel}:
+		default:
+			log.Warn().
+				Str("event", string(n.Event)).
+				Str("channel", string(channel)).
+				Msg("Notification queue is full, dropping notification")
+		}
+	}
+}
+
+// NotifyTx queues the channels of a notification whose notifier can join db's
+// transaction, such as the email outbox, and returns the notification for the other
+// channels, to pass to Notify once the transaction commits. The returned notification
+// is zero, and Notify ignores it, when no channel is left.
+func (s *NotificationService) NotifyTx(ctx context.Context, db DBTX, n Notification) (Notification, error) {
+	if s == nil {
+		return Notification{}, nil
+	}
+
+	var rest []Channel
+	for _, channel := range s.channelsFor(n) {
+		notifier, err := s.Get(channel)
+		if err != nil {
+			continue
+		}
+		txNotifier, ok := notifier.(TxNotifier)
+		if !ok {
+			rest = append(rest, channel)
+			continue
+		}
+
+		msg, err := s.Render(n, channel)
+		if err != nil {
+			return Notification{}, err
+		}
+		if err := txNotifier.SendTx(ctx, db, msg); err != nil {
+			return Notification{}, fmt.Errorf("failed to queue %s notification: %w", channel, err)
+		}
+	}
+
+	if len(rest) == 0 {
+		return Notification{}, nil
+	}
+	n.Channels = rest
+	return n, nil
+}
+
+// SendNow renders and delivers a notification synchronously, retrying on failure
+func (s *NotificationService) SendNow(ctx context.Context, n Notification) error {
+	channels := s.channelsFor(n)
+	if len(channels) == 0 {
+		return fmt.Errorf("no channel available for event %s", n.Event)
+	}
+
+	var errs []string
+	for _, channel := range channels {
+		if err := s.deliver(ctx, n, channel); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", channel, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to send notification: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Render renders the message for a notification on a channel without sending it
+func (s *NotificationService) Render(n Notification, channel Channel) (Message, error) {
+	rendered, err := s.templates.Render(n.Language, string(n.Event), n.Data)
+	if err != nil {
+		return Message{}, err
+	}
+
+	msg := Message{
+		Channel:       channel,
+		Event:         n.Event,
+		To:            n.recipient(channel),
+		Subject:       rendered.Subject,
+		Body:          rendered.HTML,
+		TextBody:      rendered.Text,
+		ReferenceType: n.ReferenceType,
+		ReferenceID:   n.ReferenceID,
+	}
+	if channel != ChannelEmail {
+		msg.Body = rendered.Text
+	}
+	return msg, nil
+}
+
+// channelsFor returns the enabled, registered channels that have a recipient
+func (s *NotificationService) channelsFor(n Notification) []Channel {
+	candidates := n.Channels
+	if len(candidates) == 0 {
+		candidates = defaultEventChannels[n.Event]
+	}
+
+	channels := make([]Channel, 0, len(candidates))
+	for _, channel := range candidates {
+		if !s.IsChannelEnabled(channel) {
+			continue
+		}
+		if _, err := s.Get(channel); err != nil {
+			continue
+		}
+		// Telegram falls back to the notifier's default chat
+		if n.recipient(channel) == "" && channel != ChannelTelegram {
+			continue
+		}
+		channels = append(channels, channel)
+	}
+	return channels
+}
+
+func (s *NotificationService) worker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-s.queue:
+			sendCtx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+			if err := s.deliver(sendCtx, job.notification, job.channel); err != nil {
+				log.Error().Err(err).
+					Str("event", string(job.notification.Event)).
+					Str("channel", string(job.channel)).
+					Msg("Failed to deliver notification")
+			}
+			cancel()
+		}
+	}
+}
+
+// deliver renders and sends a notification, retrying with exponential backoff
+func (s *NotificationService) deliver(ctx context.Context, n Notification, channel Channel) error {
+	notifier, err := s.Get(channel)
+	if err != nil {
+		return err
+	}
+
+	msg, err := s.Render(n, channel)
+	if err != nil {
+		return err
+	}
+
+	delay := s.cfg.RetryDelay
+	for attempt := 1; ; attempt++ {
+		err = notifier.Send(ctx, msg)
+		if err == nil {
+			return nil
+		}
+		if attempt >= s.cfg.MaxRetries {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+
+		log.Warn().Err(err).
+			Str("event", string(n.Event)).
+			Str("channel", string(channel)).
+			Int("attempt", attempt).
+			Msg("Notification delivery failed, retrying")
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// LanguageForRegion returns the template language used for a region
+func LanguageForRegion(region string) string {
+	if strings.EqualFold(region, "ID") {
+		return "id"
+	}
+	return "en"
+}
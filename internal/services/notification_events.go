@@ -0,0 +1,282 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"seaply/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// BuildTransactionNotification loads a transaction by ID or invoice number and builds its notification
+func (s *NotificationService) BuildTransactionNotification(ctx context.Context, db DBTX, identifier string, event EventType) (Notification, error) {
+	var (
+		invoiceNumber, productName, skuName, status, currency, region string
+		contactEmail, contactPhone, userEmail, userPhone              *string
+		serialNumber, nickname                                        *string
+		accountInputs                                                 []byte
+		totalAmount                                                   int64
+		createdAt                                                     time.Time
+	)
+
+	err := db.QueryRow(ctx, `
+		SELECT t.invoice_number, p.title, s.name, t.status::text, t.currency::text, t.region::text,
+		       t.contact_email, t.contact_phone, u.email, u.phone_number,
+		       t.provider_serial_number, t.account_nickname, t.account_inputs,
+		       t.total_amount, t.created_at
+		FROM transactions t
+		JOIN products p ON t.product_id = p.id
+		JOIN skus s ON t.sku_id = s.id
+		LEFT JOIN users u ON t.user_id = u.id
+		WHERE `+lookupColumn("t", identifier)+` = $1
+	`, identifier).Scan(
+		&invoiceNumber, &productName, &skuName, &status, &currency, &region,
+		&contactEmail, &contactPhone, &userEmail, &userPhone,
+		&serialNumber, &nickname, &accountInputs,
+		&totalAmount, &createdAt,
+	)
+	if err != nil {
+		return Notification{}, fmt.Errorf("failed to load transaction %s: %w", identifier, err)
+	}
+
+	n := Notification{
+		Event:         event,
+		Language:      LanguageForRegion(region),
+		Email:         firstNonEmpty(contactEmail, userEmail),
+		Phone:         firstNonEmpty(contactPhone, userPhone),
+		ReferenceType: "TRANSACTION",
+		ReferenceID:   invoiceNumber,
+		Data: map[string]interface{}{
+			"InvoiceNumber": invoiceNumber,
+			"ProductName":   productName,
+			"SKUName":       skuName,
+			"Status":        status,
+			"Total":         utils.FormatCurrency(float64(totalAmount), currency),
+			"Amount":        utils.FormatCurrency(float64(totalAmount), currency),
+			"SerialNumber":  firstNonEmpty(serialNumber),
+			"AccountInfo":   formatAccountInfo(accountInputs, nickname),
+			"CreatedAt":     createdAt.Format("02 Jan 2006 15:04"),
+			"InvoiceURL":    s.frontendURL(region, "invoice", invoiceNumber),
+		},
+	}
+	return n, nil
+}
+
+// BuildDepositNotification loads a deposit by ID or invoice number and builds its notification
+func (s *NotificationService) BuildDepositNotification(ctx context.Context, db DBTX, identifier string, event EventType) (Notification, error) {
+	var (
+		invoiceNumber, currency, region, status, email string
+		phone, channelName                             *string
+		amount                                         int64
+	)
+
+	err := db.QueryRow(ctx, `
+		SELECT d.invoice_number, d.currency::text, d.region::text, d.status::text,
+		       u.email, u.phone_number, pc.name, d.amount
+		FROM deposits d
+		JOIN users u ON d.user_id = u.id
+		LEFT JOIN payment_channels pc ON d.payment_channel_id = pc.id
+		WHERE `+lookupColumn("d", identifier)+` = $1
+	`, identifier).Scan(&invoiceNumber, &currency, &region, &status, &email, &phone, &channelName, &amount)
+	if err != nil {
+		return Notification{}, fmt.Errorf("failed to load deposit %s: %w", identifier, err)
+	}
+
+	n := Notification{
+		Event:         event,
+		Language:      LanguageForRegion(region),
+		Email:         email,
+		Phone:         firstNonEmpty(phone),
+		ReferenceType: "DEPOSIT",
+		ReferenceID:   invoiceNumber,
+		Data: map[string]interface{}{
+			"InvoiceNumber": invoiceNumber,
+			"Status":        status,
+			"Amount":        utils.FormatCurrency(float64(amount), currency),
+			"PaymentMethod": firstNonEmpty(channelName),
+			"InvoiceURL":    s.frontendURL(region, "deposit", invoiceNumber),
+		},
+	}
+	return n, nil
+}
+
+// NotifyTransactionTx builds a notification about a transaction from db, which may be
+// the transaction changing it, and queues its email there with NotifyTx. Pass the
+// returned notification to Notify once the transaction commits. In a transaction it
+// runs in a savepoint, so an error leaves the transaction usable.
+func (s *NotificationService) NotifyTransactionTx(ctx context.Context, db DBTX, transactionID string, event EventType, extra map[string]interface{}) (Notification, error) {
+	if s == nil {
+		return Notification{}, nil
+	}
+
+	var queued Notification
+	err := inSavepoint(ctx, db, func(db DBTX) error {
+		n, err := s.BuildTransactionNotification(ctx, db, transactionID, event)
+		if err != nil {
+			return err
+		}
+		for k, v := range extra {
+			n.Data[k] = v
+		}
+		queued, err = s.NotifyTx(ctx, db, n)
+		return err
+	})
+	if err != nil {
+		return Notification{}, err
+	}
+	return queued, nil
+}
+
+// NotifyDepositTx builds a notification about a deposit from db, which may be the
+// transaction changing it, and queues its email there with NotifyTx. Pass the
+// returned notification to Notify once the transaction commits. In a transaction it
+// runs in a savepoint, so an error leaves the transaction usable.
+func (s *NotificationService) NotifyDepositTx(ctx context.Context, db DBTX, depositID string, event EventType, extra map[string]interface{}) (Notification, error) {
+	if s == nil {
+		return Notification{}, nil
+	}
+
+	var queued Notification
+	err := inSavepoint(ctx, db, func(db DBTX) error {
+		n, err := s.BuildDepositNotification(ctx, db, depositID, event)
+		if err != nil {
+			return err
+		}
+		for k, v := range extra {
+			n.Data[k] = v
+		}
+		queued, err = s.NotifyTx(ctx, db, n)
+		return err
+	})
+	if err != nil {
+		return Notification{}, err
+	}
+	return queued, nil
+}
+
+// inSavepoint runs fn in a savepoint when db is a transaction. A failed statement
+// aborts a Postgres transaction, so fn failing would otherwise make its commit roll
+// back the change the notification is about.
+func inSavepoint(ctx context.Context, db DBTX, fn func(db DBTX) error) error {
+	tx, ok := db.(pgx.Tx)
+	if !ok {
+		return fn(db)
+	}
+
+	savepoint, err := tx.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	if err := fn(savepoint); err != nil {
+		_ = savepoint.Rollback(ctx)
+		return err
+	}
+	return savepoint.Commit(ctx)
+}
+
+// lookupColumn returns the column of table alias that identifier is matched on: the ID
+// for a UUID, the invoice number otherwise, so the lookup can use the index of either
+func lookupColumn(alias, identifier string) string {
+	if _, err := uuid.Parse(identifier); err == nil {
+		return alias + ".id"
+	}
+	return alias + ".invoice_number"
+}
+
+// OrderEventForStatus returns the notification event for a final transaction status
+func OrderEventForStatus(status string) (EventType, bool) {
+	switch status {
+	case "SUCCESS":
+		return EventOrderSuccess, true
+	case "FAILED":
+		return EventOrderFailed, true
+	}
+	return "", false
+}
+
+// NotifySecurityAlert queues a security alert for a user account. A copy goes to the
+// security Telegram chat when one is configured, never to the ops chat.
+func (s *NotificationService) NotifySecurityAlert(email, firstName, region, action, ipAddress, userAgent string) {
+	if s == nil {
+		return
+	}
+
+	channels := []Channel{ChannelEmail}
+	if s.cfg.SecurityChatID != "" {
+		channels = append(channels, ChannelTelegram)
+	}
+	s.Notify(Notification{
+		Event:      EventSecurityAlert,
+		Language:   LanguageForRegion(region),
+		Email:      email,
+		TelegramID: s.cfg.SecurityChatID,
+		Channels:   channels,
+		Data: map[string]interface{}{
+			"FirstName": firstName,
+			"Email":     email,
+			"Action":    action,
+			"IPAddress": ipAddress,
+			"UserAgent": userAgent,
+			"Time":      time.Now().Format("02 Jan 2006 15:04 MST"),
+		},
+	})
+}
+
+// frontendURL builds a localized frontend link, e.g. https://seaply.co/id-id/invoice/SEAI...
+func (s *NotificationService) frontendURL(region, path, invoiceNumber string) string {
+	return localizedFrontendURL(s.cfg.FrontendBaseURL, region, path, invoiceNumber)
+}
+
+func localizedFrontendURL(baseURL, region, path, invoiceNumber string) string {
+	if baseURL == "" {
+		return ""
+	}
+
+	locale := "id-id"
+	switch region {
+	case "MY":
+		locale = "ms-my"
+	case "PH":
+		locale = "en-ph"
+	case "SG":
+		locale = "en-sg"
+	case "TH":
+		locale = "th-th"
+	}
+	return fmt.Sprintf("%s/%s/%s/%s", baseURL, locale, path, invoiceNumber)
+}
+
+// formatAccountInfo formats account inputs as "userId (zoneId) - nickname"
+func formatAccountInfo(accountInputs []byte, nickname *string) string {
+	var inputs map[string]interface{}
+	_ = json.Unmarshal(accountInputs, &inputs)
+
+	info := ""
+	if userID, ok := inputs["userId"]; ok && userID != nil {
+		info = fmt.Sprint(userID)
+	}
+	if zoneID, ok := inputs["zoneId"]; ok && zoneID != nil && fmt.Sprint(zoneID) != "" {
+		info += fmt.Sprintf(" (%v)", zoneID)
+	}
+	if nick := firstNonEmpty(nickname); nick != "" {
+		if info != "" {
+			info += " - "
+		}
+		info += nick
+	}
+	return strings.TrimSpace(info)
+}
+
+func firstNonEmpty(values ...*string) string {
+	for _, v := range values {
+		if v != nil && *v != "" {
+			return *v
+		}
+	}
+	return ""
+}
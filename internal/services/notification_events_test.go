@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestLookupColumn(t *testing.T) {
+	tests := []struct {
+		identifier string
+		want       string
+	}{
+		{"0b7c6f2e-3d1a-4c5b-9e8f-1a2b3c4d5e6f", "t.id"},
+		{"SEAI12345678901234", "t.invoice_number"},
+		{"", "t.invoice_number"},
+		{"0b7c6f2e-3d1a-4c5b-9e8f", "t.invoice_number"},
+	}
+
+	for _, tt := range tests {
+		if got := lookupColumn("t", tt.identifier); got != tt.want {
+			t.Errorf("lookupColumn(%q) = %q, want %q", tt.identifier, got, tt.want)
+		}
+	}
+}
+
+func TestNotifySecurityAlertChannels(t *testing.T) {
+	tests := []struct {
+		name           string
+		securityChatID string
+		want           []Channel
+	}{
+		{"no security chat", "", []Channel{ChannelEmail}},
+		{"security chat", "-100200300", []Channel{ChannelEmail, ChannelTelegram}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewNotificationService(nil, NotificationConfig{SecurityChatID: tt.securityChatID})
+			s.Register(NewMemoryNotifier(ChannelEmail))
+			s.Register(NewMemoryNotifier(ChannelTelegram))
+			s.NotifySecurityAlert("user@example.com", "Budi", "ID", "login", "203.0.113.7", "curl")
+
+			var got []Channel
+			for len(s.queue) > 0 {
+				job := <-s.queue
+				got = append(got, job.channel)
+				if job.channel == ChannelTelegram && job.notification.TelegramID != tt.securityChatID {
+					t.Errorf("telegram alert sent to %q, want %q", job.notification.TelegramID, tt.securityChatID)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("alert queued on %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("alert queued on %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// fakeTx is a transaction whose queries fail with err. Savepoints it begins are
+// fakeTx values too, recording whether they were committed or rolled back.
+type fakeTx struct {
+	pgx.Tx
+	err        error
+	savepoints []*fakeTx
+	committed  bool
+	rolledBack bool
+}
+
+type errRow struct{ err error }
+
+func (r errRow) Scan(dest ...any) error { return r.err }
+
+func (tx *fakeTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return errRow{tx.err}
+}
+
+func (tx *fakeTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	savepoint := &fakeTx{err: tx.err}
+	tx.savepoints = append(tx.savepoints, savepoint)
+	return savepoint, nil
+}
+
+func (tx *fakeTx) Commit(ctx context.Context) error {
+	tx.committed = true
+	return nil
+}
+
+func (tx *fakeTx) Rollback(ctx context.Context) error {
+	tx.rolledBack = true
+	return nil
+}
+
+func TestNotifyTxRollsBackItsSavepoint(t *testing.T) {
+	s := NewNotificationService(nil, NotificationConfig{})
+	queryErr := errors.New("relation does not exist")
+
+	tests := []struct {
+		name   string
+		notify func(tx pgx.Tx) error
+	}{
+		{"transaction", func(tx pgx.Tx) error {
+			_, err := s.NotifyTransactionTx(context.Background(), tx, "SEAI1", EventOrderSuccess, nil)
+			return err
+		}},
+		{"deposit", func(tx pgx.Tx) error {
+			_, err := s.NotifyDepositTx(context.Background(), tx, "SEAD1", EventDepositSuccess, nil)
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := &fakeTx{err: queryErr}
+			if err := tt.notify(tx); !errors.Is(err, queryErr) {
+				t.Fatalf("expected the query error, got %v", err)
+			}
+			if len(tx.savepoints) != 1 {
+				t.Fatalf("expected the notification to run in one savepoint, got %d", len(tx.savepoints))
+			}
+			if savepoint := tx.savepoints[0]; !savepoint.rolledBack || savepoint.committed {
+				t.Fatalf("expected the savepoint to be rolled back, got %+v", savepoint)
+			}
+			if tx.rolledBack || tx.committed {
+				t.Fatal("the transaction of the change must be left to its caller")
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryNotifier records messages in memory instead of sending them.
+// It is used in development and as a test sink.
+type MemoryNotifier struct {
+	channel  Channel
+	messages []Message
+	mu       sync.Mutex
+}
+
+// NewMemoryNotifier creates a new in-memory notifier for a channel
+func NewMemoryNotifier(channel Channel) *MemoryNotifier {
+	return &MemoryNotifier{channel: channel}
+}
+
+// Channel returns the notifier channel
+func (n *MemoryNotifier) Channel() Channel {
+	return n.channel
+}
+
+// Send records the message
+func (n *MemoryNotifier) Send(_ context.Context, msg Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.messages = append(n.messages, msg)
+	return nil
+}
+
+// Messages returns a copy of the recorded messages
+func (n *MemoryNotifier) Messages() []Message {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	messages := make([]Message, len(n.messages))
+	copy(messages, n.messages)
+	return messages
+}
+
+// Reset clears the recorded messages
+func (n *MemoryNotifier) Reset() {
+	n.mu.Lock()
+	n.messages = nil
+	n.mu.Unlock()
+}
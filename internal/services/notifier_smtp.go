@@ -0,0 +1,32 @@
+package services
+
+import (
+	"context"
+	"fmt"
+)
+
+// SMTPNotifier delivers email notifications through the EmailService SMTP relay
+type SMTPNotifier struct {
+	email *EmailService
+}
+
+// NewSMTPNotifier creates a new SMTP notifier
+func NewSMTPNotifier(email *EmailService) *SMTPNotifier {
+	return &SMTPNotifier{email: email}
+}
+
+// Channel returns the notifier channel
+func (n *SMTPNotifier) Channel() Channel {
+	return ChannelEmail
+}
+
+// Send sends the message as an HTML email with its attachments
+func (n *SMTPNotifier) Send(ctx context.Context, msg Message) error {
+	if msg.To == "" {
+		return fmt.Errorf("email recipient is empty")
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return n.email.sendWithAttachments(msg.To, msg.Subject, msg.Body, msg.Attachments)
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// TelegramNotifier delivers messages through the Telegram Bot API
+type TelegramNotifier struct {
+	botToken      string
+	defaultChatID string
+	baseURL       string
+	client        *http.Client
+}
+
+// NewTelegramNotifier creates a new Telegram notifier. Messages without a
+// recipient are sent to defaultChatID (usually the ops group).
+func NewTelegramNotifier(botToken, defaultChatID string) *TelegramNotifier {
+	return &TelegramNotifier{
+		botToken:      botToken,
+		defaultChatID: defaultChatID,
+		baseURL:       "https://api.telegram.org",
+		client: &http.Client{
+			Timeout: 15 * time.Second,
+		},
+	}
+}
+
+// Channel returns the notifier channel
+func (n *TelegramNotifier) Channel() Channel {
+	return ChannelTelegram
+}
+
+// Send sends the message text to the chat
+func (n *TelegramNotifier) Send(ctx context.Context, msg Message) error {
+	chatID := msg.To
+	if chatID == "" {
+		chatID = n.defaultChatID
+	}
+	if chatID == "" {
+		return fmt.Errorf("telegram chat id is empty")
+	}
+
+	text := msg.TextBody
+	if text == "" {
+		text = msg.Body
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"chat_id":                  chatID,
+		"text":                     text,
+		"disable_web_page_preview": true,
+	})
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/bot%s/sendMessage", n.baseURL, n.botToken)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send telegram message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode telegram response: %w", err)
+	}
+	if !result.OK {
+		return fmt.Errorf("telegram error: %s", result.Description)
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// WhatsAppNotifier delivers messages through a generic WhatsApp HTTP gateway.
+// The gateway receives a JSON body {"sender", "to", "message"} authenticated
+// with a bearer API key, which is what most Indonesian WA gateways accept.
+type WhatsAppNotifier struct {
+	apiURL string
+	apiKey string
+	sender string
+	client *http.Client
+}
+
+// NewWhatsAppNotifier creates a new WhatsApp notifier
+func NewWhatsAppNotifier(apiURL, apiKey, sender string) *WhatsAppNotifier {
+	return &WhatsAppNotifier{
+		apiURL: apiURL,
+		apiKey: apiKey,
+		sender: sender,
+		client: &http.Client{
+			Timeout: 15 * time.Second,
+		},
+	}
+}
+
+// Channel returns the notifier channel
+func (n *WhatsAppNotifier) Channel() Channel {
+	return ChannelWhatsApp
+}
+
+// Send sends the message text to the recipient phone number
+func (n *WhatsAppNotifier) Send(ctx context.Context, msg Message) error {
+	phone := normalizePhoneNumber(msg.To)
+	if phone == "" {
+		return fmt.Errorf("whatsapp recipient is empty")
+	}
+
+	text := msg.TextBody
+	if text == "" {
+		text = msg.Body
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"sender":  n.sender,
+		"to":      phone,
+		"message": text,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", n.apiURL, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+n.apiKey)
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send whatsapp message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("whatsapp gateway returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	return nil
+}
+
+// normalizePhoneNumber converts a local phone number to international format without "+"
+func normalizePhoneNumber(phone string) string {
+	var b strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+
+	digits := b.String()
+	if strings.HasPrefix(digits, "0") {
+		digits = "62" + digits[1:]
+	}
+	return digits
+}
@@ -0,0 +1,295 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"seaply/internal/database"
+	"seaply/internal/utils"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// Headers of a signed partner request
+const (
+	PartnerKeyHeader       = "X-Partner-Key"
+	PartnerTimestampHeader = "X-Timestamp"
+	PartnerNonceHeader     = "X-Nonce"
+	PartnerSignatureHeader = "X-Signature"
+)
+
+// Partner request limits
+const (
+	PartnerTimestampTolerance = 5 * time.Minute
+	partnerNonceTTL           = 2 * PartnerTimestampTolerance
+)
+
+// Errors returned while authenticating a partner request
+var (
+	ErrPartnerKeyInvalid       = errors.New("partner API key is invalid")
+	ErrPartnerTimestampInvalid = errors.New("request timestamp is outside the allowed window")
+	ErrPartnerNonceInvalid     = errors.New("request nonce is missing or already used")
+	ErrPartnerSignatureInvalid = errors.New("request signature is invalid")
+	ErrPartnerIPNotAllowed     = errors.New("IP address is not allowed for this API key")
+)
+
+// Partner is an authenticated H2H partner, a reseller account using an API key
+type Partner struct {
+	KeyID       string
+	UserID      string
+	Name        string
+	APIKey      string
+	CallbackURL string
+}
+
+// PartnerCallback is the body posted to a partner when an order reaches a final status
+type PartnerCallback struct {
+	Event         string    `json:"event"`
+	RefID         string    `json:"refId"`
+	InvoiceNumber string    `json:"invoiceNumber"`
+	ProductCode   string    `json:"productCode"`
+	SKUCode       string    `json:"skuCode"`
+	Status        string    `json:"status"`
+	SerialNumber  string    `json:"serialNumber,omitempty"`
+	Price         int64     `json:"price"`
+	Refunded      int64     `json:"refunded"`
+	Currency      string    `json:"currency"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// PartnerService authenticates requests to the H2H partner API and queues the
+// signed order callbacks. Requests are signed with the secret of the API key:
+//
+//	X-Signature = hex(HMAC-SHA256(secret, METHOD + "\n" + path + "\n" + query + "\n" + timestamp + "\n" + nonce + "\n" + hex(SHA256(body))))
+//
+// where query is the raw query string without the leading "?", empty when there
+// is none. The timestamp must be within five minutes of the server clock and a
+// nonce is accepted once, so a captured request can't be replayed. Nonces are
+// kept in Redis, or in the partner_nonces table when Redis is not configured.
+type PartnerService struct {
+	credentialCipher
+
+	pool     *pgxpool.Pool
+	redis    *database.RedisClient
+	webhooks *WebhookDispatcher
+}
+
+// NewPartnerService creates a partner service
+func NewPartnerService(pool *pgxpool.Pool, redis *database.RedisClient, webhooks *WebhookDispatcher, credentialKey string) *PartnerService {
+	return &PartnerService{
+		credentialCipher: credentialCipher{credentialKey: credentialKey},
+		pool:             pool,
+		redis:            redis,
+		webhooks:         webhooks,
+	}
+}
+
+// PartnerSignature signs a partner request
+func PartnerSignature(secret, method, path, query, timestamp, nonce string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	payload := strings.Join([]string{strings.ToUpper(method), path, query, timestamp, nonce, hex.EncodeToString(bodyHash[:])}, "\n")
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// GenerateKey creates the API key and secret of a new partner key. The secret is
+// returned in plain text to be shown once; the returned hint identifies it later.
+func (s *PartnerService) GenerateKey() (apiKey, secret, encryptedSecret, hint string, err error) {
+	random, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return "", "", "", "", err
+	}
+	apiKey = "pk_" + random
+	secret, encryptedSecret, hint, err = s.GenerateSecret()
+	return apiKey, secret, encryptedSecret, hint, err
+}
+
+// GenerateSecret creates a new secret for a partner key
+func (s *PartnerService) GenerateSecret() (secret, encryptedSecret, hint string, err error) {
+	random, err := utils.GenerateRandomString(48)
+	if err != nil {
+		return "", "", "", err
+	}
+	secret = "sk_" + random
+	encryptedSecret, err = s.EncryptSecret(secret)
+	if err != nil {
+		return "", "", "", fmt.Errorf("encrypt secret: %w", err)
+	}
+	return secret, encryptedSecret, secret[len(secret)-4:], nil
+}
+
+// Authenticate verifies a signed partner request and returns the partner it belongs to
+func (s *PartnerService) Authenticate(ctx context.Context, r *http.Request, body []byte, ip string) (*Partner, error) {
+	apiKey := r.Header.Get(PartnerKeyHeader)
+	timestamp := r.Header.Get(PartnerTimestampHeader)
+	nonce := r.Header.Get(PartnerNonceHeader)
+	signature := r.Header.Get(PartnerSignatureHeader)
+	if apiKey == "" || signature == "" {
+		return nil, ErrPartnerKeyInvalid
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, ErrPartnerTimestampInvalid
+	}
+	if skew := time.Since(time.Unix(unix, 0)); skew > PartnerTimestampTolerance || skew < -PartnerTimestampTolerance {
+		return nil, ErrPartnerTimestampInvalid
+	}
+	if len(nonce) < 8 || len(nonce) > 64 {
+		return nil, ErrPartnerNonceInvalid
+	}
+
+	var partner Partner
+	var encryptedSecret string
+	var callbackURL *string
+	var whitelist []string
+	err = s.pool.QueryRow(ctx, `
+		SELECT k.id, k.user_id, k.name, k.api_key, k.secret, k.callback_url, k.ip_whitelist
+		FROM partner_api_keys k
+		JOIN users u ON u.id = k.user_id
+		WHERE k.api_key = $1 AND k.is_active = true AND k.revoked_at IS NULL AND u.status = 'ACTIVE'
+	`, apiKey).Scan(&partner.KeyID, &partner.UserID, &partner.Name, &partner.APIKey, &encryptedSecret,
+		&callbackURL, &whitelist)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrPartnerKeyInvalid
+		}
+		return nil, fmt.Errorf("load partner key: %w", err)
+	}
+	if callbackURL != nil {
+		partner.CallbackURL = *callbackURL
+	}
+
+	if !partnerIPAllowed(whitelist, ip) {
+		return nil, ErrPartnerIPNotAllowed
+	}
+
+	secret, err := s.DecryptSecret(encryptedSecret)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt partner secret: %w", err)
+	}
+	expected := PartnerSignature(secret, r.Method, r.URL.Path, r.URL.RawQuery, timestamp, nonce, body)
+	if !hmac.Equal([]byte(strings.ToLower(signature)), []byte(expected)) {
+		return nil, ErrPartnerSignatureInvalid
+	}
+
+	// The nonce is only spent once the signature is valid, so nobody else can burn it
+	fresh, err := s.spendNonce(ctx, partner.KeyID, nonce)
+	if err != nil {
+		return nil, fmt.Errorf("store nonce: %w", err)
+	}
+	if !fresh {
+		return nil, ErrPartnerNonceInvalid
+	}
+
+	if _, err := s.pool.Exec(ctx, `
+		UPDATE partner_api_keys SET last_used_at = NOW(), last_used_ip = $2 WHERE id = $1
+	`, partner.KeyID, ip); err != nil {
+		log.Warn().Err(err).Str("partner_key_id", partner.KeyID).Msg("Failed to record partner key usage")
+	}
+
+	return &partner, nil
+}
+
+// spendNonce records a nonce of a partner key and reports whether it was unused.
+// Without Redis the nonce is stored in the database, so replays are never
+// accepted because a cache is missing.
+func (s *PartnerService) spendNonce(ctx context.Context, keyID, nonce string) (bool, error) {
+	if s.redis != nil {
+		return s.redis.Client.SetNX(ctx, "partner:nonce:"+keyID+":"+nonce, 1, partnerNonceTTL).Result()
+	}
+
+	if _, err := s.pool.Exec(ctx, `DELETE FROM partner_nonces WHERE expires_at < NOW()`); err != nil {
+		return false, err
+	}
+	tag, err := s.pool.Exec(ctx, `
+		INSERT INTO partner_nonces (key_id, nonce, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (key_id, nonce) DO NOTHING
+	`, keyID, nonce, time.Now().Add(partnerNonceTTL))
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// NotifyOrder queues a signed callback with the current status of an H2H order to
+// the partner's callback URL. Orders not placed through the partner API are ignored.
+// The callback is delivered and retried by the webhook dispatcher.
+func (s *PartnerService) NotifyOrder(ctx context.Context, transactionID string) error {
+	if s.webhooks == nil {
+		return nil
+	}
+
+	var keyID string
+	var callbackURL *string
+	callback := PartnerCallback{Event: WebhookEventPartnerOrder}
+	err := s.pool.QueryRow(ctx, `
+		SELECT k.id, COALESCE(t.partner_callback_url, k.callback_url),
+		       t.partner_ref_id, t.invoice_number, p.code, s.code, t.status::text,
+		       COALESCE(t.provider_serial_number, ''), t.total_amount, t.refunded_amount, t.currency::text
+		FROM transactions t
+		JOIN partner_api_keys k ON k.id = t.partner_key_id
+		JOIN products p ON p.id = t.product_id
+		JOIN skus s ON s.id = t.sku_id
+		WHERE t.id = $1 AND t.partner_ref_id IS NOT NULL
+	`, transactionID).Scan(&keyID, &callbackURL, &callback.RefID, &callback.InvoiceNumber,
+		&callback.ProductCode, &callback.SKUCode, &callback.Status, &callback.SerialNumber,
+		&callback.Price, &callback.Refunded, &callback.Currency)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("load partner order: %w", err)
+	}
+	if callbackURL == nil || *callbackURL == "" {
+		return nil
+	}
+
+	callback.Timestamp = time.Now()
+	_, err = s.webhooks.EmitToPartner(ctx, WebhookEvent{
+		Type:          WebhookEventPartnerOrder,
+		ReferenceType: "TRANSACTION",
+		ReferenceID:   transactionID,
+		DedupeKey:     fmt.Sprintf("partner:%s:%s:%d", transactionID, callback.Status, callback.Refunded),
+		Data:          callback,
+	}, keyID, *callbackURL)
+	return err
+}
+
+// partnerIPAllowed reports whether an IP matches the whitelist of a key; an empty
+// whitelist allows any IP
+func partnerIPAllowed(whitelist []string, ip string) bool {
+	if len(whitelist) == 0 {
+		return true
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, entry := range whitelist {
+		entry = strings.TrimSpace(entry)
+		if strings.Contains(entry, "/") {
+			if _, network, err := net.ParseCIDR(entry); err == nil && network.Contains(parsed) {
+				return true
+			}
+			continue
+		}
+		if allowed := net.ParseIP(entry); allowed != nil && allowed.Equal(parsed) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPartnerSignature(t *testing.T) {
+	body := []byte(`{"refId":"R1","skuCode":"ML86"}`)
+	base := PartnerSignature("sk_secret", "POST", "/v1/partner/orders", "", "1700000000", "nonce-0001", body)
+
+	if got := PartnerSignature("sk_secret", "post", "/v1/partner/orders", "", "1700000000", "nonce-0001", body); got != base {
+		t.Error("signature depends on the case of the method")
+	}
+
+	tests := []struct {
+		name      string
+		secret    string
+		method    string
+		path      string
+		query     string
+		timestamp string
+		nonce     string
+		body      []byte
+	}{
+		{"other secret", "sk_other", "POST", "/v1/partner/orders", "", "1700000000", "nonce-0001", body},
+		{"other method", "sk_secret", "PUT", "/v1/partner/orders", "", "1700000000", "nonce-0001", body},
+		{"other path", "sk_secret", "POST", "/v1/partner/orders/R1", "", "1700000000", "nonce-0001", body},
+		{"query added", "sk_secret", "POST", "/v1/partner/orders", "refId=R2", "1700000000", "nonce-0001", body},
+		{"other timestamp", "sk_secret", "POST", "/v1/partner/orders", "", "1700000001", "nonce-0001", body},
+		{"other nonce", "sk_secret", "POST", "/v1/partner/orders", "", "1700000000", "nonce-0002", body},
+		{"other body", "sk_secret", "POST", "/v1/partner/orders", "", "1700000000", "nonce-0001", []byte(`{"refId":"R2"}`)},
+		{"path moved into query", "sk_secret", "POST", "/v1/partner", "/orders", "1700000000", "nonce-0001", body},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := PartnerSignature(tt.secret, tt.method, tt.path, tt.query, tt.timestamp, tt.nonce, tt.body)
+			if got == base {
+				t.Error("signature did not change")
+			}
+		})
+	}
+}
+
+func TestPartnerAuthenticateRejectsBeforeLookup(t *testing.T) {
+	s := NewPartnerService(nil, nil, nil, "key")
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-2*PartnerTimestampTolerance).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		key       string
+		timestamp string
+		nonce     string
+		signature string
+		want      error
+	}{
+		{"no key", "", now, "nonce-0001", "sig", ErrPartnerKeyInvalid},
+		{"no signature", "pk_test", now, "nonce-0001", "", ErrPartnerKeyInvalid},
+		{"bad timestamp", "pk_test", "yesterday", "nonce-0001", "sig", ErrPartnerTimestampInvalid},
+		{"stale timestamp", "pk_test", stale, "nonce-0001", "sig", ErrPartnerTimestampInvalid},
+		{"short nonce", "pk_test", now, "abc", "sig", ErrPartnerNonceInvalid},
+		{"long nonce", "pk_test", now, strings.Repeat("n", 65), "sig", ErrPartnerNonceInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/v1/partner/orders", nil)
+			r.Header.Set(PartnerKeyHeader, tt.key)
+			r.Header.Set(PartnerTimestampHeader, tt.timestamp)
+			r.Header.Set(PartnerNonceHeader, tt.nonce)
+			r.Header.Set(PartnerSignatureHeader, tt.signature)
+			if _, err := s.Authenticate(context.Background(), r, nil, "203.0.113.7"); !errors.Is(err, tt.want) {
+				t.Errorf("Authenticate() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPartnerIPAllowed(t *testing.T) {
+	tests := []struct {
+		name      string
+		whitelist []string
+		ip        string
+		want      bool
+	}{
+		{"empty whitelist", nil, "203.0.113.7", true},
+		{"exact match", []string{"203.0.113.7"}, "203.0.113.7", true},
+		{"cidr match", []string{"203.0.113.0/24"}, "203.0.113.99", true},
+		{"padded entry", []string{" 203.0.113.7 "}, "203.0.113.7", true},
+		{"no match", []string{"203.0.113.0/24"}, "198.51.100.1", false},
+		{"invalid ip", []string{"203.0.113.0/24"}, "not-an-ip", false},
+		{"invalid entry", []string{"203.0.113"}, "203.0.113.7", false},
+		{"ipv6 cidr", []string{"2001:db8::/32"}, "2001:db8::1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := partnerIPAllowed(tt.whitelist, tt.ip); got != tt.want {
+				t.Errorf("partnerIPAllowed(%v, %q) = %v, want %v", tt.whitelist, tt.ip, got, tt.want)
+			}
+		})
+	}
+}
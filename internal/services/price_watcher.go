@@ -0,0 +1,582 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"seaply/internal/provider"
+	"seaply/internal/utils"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// Price history actions
+const (
+	PriceActionRecorded = "RECORDED"
+	PriceActionAdjusted = "ADJUSTED"
+	PriceActionDisabled = "DISABLED"
+	PriceActionEnabled  = "ENABLED"
+)
+
+// MarginRule computes a sell price from a provider cost.
+// Type "percent" adds Value percent of the cost, "fixed" adds Value.
+// Floor is the minimum margin and RoundTo rounds the result up.
+type MarginRule struct {
+	Type    string  `json:"type"`
+	Value   float64 `json:"value"`
+	Floor   int64   `json:"floor"`
+	RoundTo int64   `json:"roundTo"`
+}
+
+// Apply returns the sell price for a provider cost
+func (r MarginRule) Apply(cost int64) int64 {
+	var margin int64
+	switch r.Type {
+	case "fixed":
+		margin = int64(math.Ceil(r.Value))
+	default:
+		margin = int64(math.Ceil(float64(cost) * r.Value / 100))
+	}
+	if margin < r.Floor {
+		margin = r.Floor
+	}
+
+	price := cost + margin
+	if r.RoundTo > 1 && price%r.RoundTo != 0 {
+		price += r.RoundTo - price%r.RoundTo
+	}
+	return price
+}
+
+// PriceWatcherSettings are read from the price_watcher settings category before every run
+type PriceWatcherSettings struct {
+	Enabled        bool
+	Interval       time.Duration
+	MarginRule     MarginRule
+	AutoAdjust     bool
+	AutoDisable    bool
+	AlertThreshold float64
+	AlertEmail     string
+}
+
+// PriceChange is a price change made or detected by the watcher
+type PriceChange struct {
+	SKUCode       string  `json:"skuCode"`
+	SKUName       string  `json:"skuName"`
+	Provider      string  `json:"provider"`
+	Region        string  `json:"region"`
+	Currency      string  `json:"currency"`
+	OldBuyPrice   int64   `json:"oldBuyPrice"`
+	NewBuyPrice   int64   `json:"newBuyPrice"`
+	OldSellPrice  int64   `json:"oldSellPrice"`
+	NewSellPrice  int64   `json:"newSellPrice"`
+	ChangePercent float64 `json:"changePercent"`
+	Action        string  `json:"action"`
+}
+
+// PriceWatchResult summarizes a watcher run
+type PriceWatchResult struct {
+	Checked    int           `json:"checked"`
+	Missing    int           `json:"missing"`
+	Changed    int           `json:"changed"`
+	Adjusted   int           `json:"adjusted"`
+	Disabled   int           `json:"disabled"`
+	Enabled    int           `json:"enabled"`
+	RouteCosts int           `json:"routeCosts"` // provider mapping costs updated for routing
+	Changes    []PriceChange `json:"changes"`
+	Errors     []string      `json:"errors,omitempty"`
+	StartedAt  time.Time     `json:"startedAt"`
+	FinishedAt time.Time     `json:"finishedAt"`
+}
+
+// watchedPrice is a sku_pricing row compared against the provider cost
+type watchedPrice struct {
+	skuID        string
+	skuCode      string
+	skuName      string
+	providerSKU  string
+	providerID   string
+	providerCode string
+	marginRule   []byte
+	pricingID    string
+	region       string
+	currency     string
+	buyPrice     int64
+	sellPrice    int64
+	isActive     bool
+	autoDisabled bool
+	updatedAt    *time.Time
+}
+
+// PriceWatcher compares provider costs with stored buy prices and protects margins.
+// Provider costs are in IDR, so only IDR prices are watched.
+type PriceWatcher struct {
+	pool          *pgxpool.Pool
+	providers     *provider.Manager
+	notifications *NotificationService
+	onChange      func(ctx context.Context)
+
+	runMu   sync.Mutex
+	mu      sync.RWMutex
+	lastRun *PriceWatchResult
+}
+
+// NewPriceWatcher creates a price watcher. onChange is called after a run that changed prices.
+func NewPriceWatcher(pool *pgxpool.Pool, providers *provider.Manager, notifications *NotificationService, onChange func(ctx context.Context)) *PriceWatcher {
+	return &PriceWatcher{
+		pool:          pool,
+		providers:     providers,
+		notifications: notifications,
+		onChange:      onChange,
+	}
+}
+
+// LoadSettings reads the watcher settings, falling back to defaults
+func (w *PriceWatcher) LoadSettings(ctx context.Context) (PriceWatcherSettings, error) {
+	settings := PriceWatcherSettings{
+		Enabled:        true,
+		Interval:       30 * time.Minute,
+		MarginRule:     MarginRule{Type: "percent", Value: 5},
+		AutoAdjust:     true,
+		AutoDisable:    true,
+		AlertThreshold: 5,
+	}
+
+	rows, err := w.pool.Query(ctx, `SELECT key, value FROM settings WHERE category = 'price_watcher'`)
+	if err != nil {
+		return settings, fmt.Errorf("failed to load price watcher settings: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			return settings, fmt.Errorf("failed to scan price watcher setting: %w", err)
+		}
+
+		switch key {
+		case "enabled":
+			_ = json.Unmarshal(value, &settings.Enabled)
+		case "interval":
+			var minutes int
+			if json.Unmarshal(value, &minutes) == nil && minutes > 0 {
+				settings.Interval = time.Duration(minutes) * time.Minute
+			}
+		case "marginRule":
+			_ = json.Unmarshal(value, &settings.MarginRule)
+		case "autoAdjust":
+			_ = json.Unmarshal(value, &settings.AutoAdjust)
+		case "autoDisable":
+			_ = json.Unmarshal(value, &settings.AutoDisable)
+		case "alertThreshold":
+			_ = json.Unmarshal(value, &settings.AlertThreshold)
+		case "alertEmail":
+			_ = json.Unmarshal(value, &settings.AlertEmail)
+		}
+	}
+
+	return settings, rows.Err()
+}
+
+// Start runs the watcher periodically using the interval from settings
+func (w *PriceWatcher) Start(ctx context.Context) {
+	go func() {
+		for {
+			settings, err := w.LoadSettings(ctx)
+			if err != nil {
+				log.Warn().Err(err).Msg("Failed to load price watcher settings, using defaults")
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(settings.Interval):
+			}
+
+			if !settings.Enabled {
+				continue
+			}
+
+			runCtx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+			if _, err := w.Run(runCtx); err != nil {
+				log.Error().Err(err).Msg("Price watcher run failed")
+			}
+			cancel()
+		}
+	}()
+}
+
+// LastRun returns the result of the most recent run, or nil
+func (w *PriceWatcher) LastRun() *PriceWatchResult {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.lastRun
+}
+
+// Run checks every watched price once. Concurrent runs are serialized.
+func (w *PriceWatcher) Run(ctx context.Context) (*PriceWatchResult, error) {
+	w.runMu.Lock()
+	defer w.runMu.Unlock()
+
+	result := &PriceWatchResult{StartedAt: time.Now(), Changes: []PriceChange{}}
+
+	settings, err := w.LoadSettings(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to load price watcher settings, using defaults")
+	}
+
+	prices, err := w.loadWatchedPrices(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byProvider := make(map[string][]watchedPrice)
+	for _, p := range prices {
+		byProvider[p.providerCode] = append(byProvider[p.providerCode], p)
+	}
+
+	var alerts []PriceChange
+	costsByProvider := make(map[string]map[string]int64)
+	for providerCode, rows := range byProvider {
+		costs, err := w.providerCosts(ctx, providerCode)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", providerCode, err))
+			log.Warn().Err(err).Str("provider", providerCode).Msg("Price watcher could not fetch provider prices")
+			continue
+		}
+		costsByProvider[providerCode] = costs
+
+		for _, row := range rows {
+			result.Checked++
+
+			cost, ok := costs[row.providerSKU]
+			if !ok || cost <= 0 {
+				result.Missing++
+				continue
+			}
+
+			change, err := w.apply(ctx, settings, row, cost)
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s/%s: %v", row.skuCode, row.region, err))
+				log.Error().Err(err).Str("sku", row.skuCode).Msg("Price watcher failed to update price")
+				continue
+			}
+			if change == nil {
+				continue
+			}
+
+			result.Changed++
+			switch change.Action {
+			case PriceActionAdjusted:
+				result.Adjusted++
+			case PriceActionDisabled:
+				result.Disabled++
+			case PriceActionEnabled:
+				result.Enabled++
+			}
+			result.Changes = append(result.Changes, *change)
+
+			if change.Action == PriceActionDisabled || change.Action == PriceActionEnabled ||
+				math.Abs(change.ChangePercent) >= settings.AlertThreshold {
+				alerts = append(alerts, *change)
+			}
+		}
+	}
+
+	w.syncRouteCosts(ctx, costsByProvider, result)
+
+	result.FinishedAt = time.Now()
+	w.mu.Lock()
+	w.lastRun = result
+	w.mu.Unlock()
+
+	log.Info().
+		Int("checked", result.Checked).
+		Int("changed", result.Changed).
+		Int("adjusted", result.Adjusted).
+		Int("disabled", result.Disabled).
+		Int("enabled", result.Enabled).
+		Int("missing", result.Missing).
+		Int("route_costs", result.RouteCosts).
+		Msg("Price watcher run finished")
+
+	if result.Changed > 0 && w.onChange != nil {
+		w.onChange(ctx)
+	}
+	if len(alerts) > 0 {
+		w.notify(settings, alerts)
+	}
+
+	return result, nil
+}
+
+// loadWatchedPrices returns the IDR prices of SKUs whose provider is active,
+// including prices the watcher disabled earlier so they can be re-enabled
+func (w *PriceWatcher) loadWatchedPrices(ctx context.Context) ([]watchedPrice, error) {
+	rows, err := w.pool.Query(ctx, `
+		SELECT s.id, s.code, s.name, s.provider_sku_code, s.provider_id, LOWER(p.code), s.margin_rule,
+		       sp.id, sp.region_code::text, sp.currency::text, sp.buy_price, sp.sell_price,
+		       COALESCE(sp.is_active, false), sp.auto_disabled_at IS NOT NULL, sp.updated_at
+		FROM skus s
+		JOIN providers p ON s.provider_id = p.id
+		JOIN sku_pricing sp ON sp.sku_id = s.id
+		WHERE p.is_active = true AND s.is_active = true
+		  AND sp.currency = 'IDR'
+		  AND (sp.is_active = true OR sp.auto_disabled_at IS NOT NULL)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load watched prices: %w", err)
+	}
+	defer rows.Close()
+
+	var prices []watchedPrice
+	for rows.Next() {
+		var p watchedPrice
+		if err := rows.Scan(&p.skuID, &p.skuCode, &p.skuName, &p.providerSKU, &p.providerID, &p.providerCode, &p.marginRule,
+			&p.pricingID, &p.region, &p.currency, &p.buyPrice, &p.sellPrice,
+			&p.isActive, &p.autoDisabled, &p.updatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan watched price: %w", err)
+		}
+		prices = append(prices, p)
+	}
+	return prices, rows.Err()
+}
+
+// syncRouteCosts stores the current provider cost on every active SKU provider mapping,
+// which the CHEAPEST routing strategy ranks by. costs holds the provider prices already
+// fetched in this run; other providers are fetched here. Missing SKUs keep their cost.
+func (w *PriceWatcher) syncRouteCosts(ctx context.Context, costs map[string]map[string]int64, result *PriceWatchResult) {
+	rows, err := w.pool.Query(ctx, `
+		SELECT m.id, LOWER(p.code), m.provider_sku_code, COALESCE(m.cost_price, 0)
+		FROM sku_provider_mappings m
+		JOIN providers p ON m.provider_id = p.id
+		WHERE m.is_active = true AND p.is_active = true
+	`)
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("route costs: %v", err))
+		log.Warn().Err(err).Msg("Price watcher could not load provider mappings")
+		return
+	}
+
+	type mappingCost struct {
+		id, providerCode, providerSKU string
+		cost                          int64
+	}
+	var mappings []mappingCost
+	for rows.Next() {
+		var m mappingCost
+		if err := rows.Scan(&m.id, &m.providerCode, &m.providerSKU, &m.cost); err != nil {
+			continue
+		}
+		mappings = append(mappings, m)
+	}
+	rows.Close()
+
+	for _, m := range mappings {
+		providerCosts, fetched := costs[m.providerCode]
+		if !fetched {
+			providerCosts, err = w.providerCosts(ctx, m.providerCode)
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", m.providerCode, err))
+				log.Warn().Err(err).Str("provider", m.providerCode).Msg("Price watcher could not fetch provider prices")
+			}
+			// Remember failures too so the provider is only called once per run
+			costs[m.providerCode] = providerCosts
+		}
+
+		cost, ok := providerCosts[m.providerSKU]
+		if !ok || cost <= 0 || cost == m.cost {
+			continue
+		}
+		// A cost edited since it was loaded is left for the next run
+		tag, err := w.pool.Exec(ctx, `
+			UPDATE sku_provider_mappings SET cost_price = $1, updated_at = NOW()
+			WHERE id = $2 AND COALESCE(cost_price, 0) = $3
+		`, cost, m.id, m.cost)
+		if err != nil {
+			log.Warn().Err(err).Str("mapping_id", m.id).Msg("Price watcher failed to update mapping cost")
+			continue
+		}
+		if tag.RowsAffected() == 0 {
+			continue
+		}
+		result.RouteCosts++
+	}
+}
+
+// providerCosts returns the current cost of every available product of a provider keyed by SKU
+func (w *PriceWatcher) providerCosts(ctx context.Context, providerCode string) (map[string]int64, error) {
+	if w.providers == nil {
+		return nil, fmt.Errorf("provider manager is not configured")
+	}
+	prov, err := w.providers.Get(providerCode)
+	if err != nil {
+		return nil, err
+	}
+
+	products, err := prov.GetProducts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	costs := make(map[string]int64, len(products))
+	for _, p := range products {
+		if !p.IsAvailable {
+			continue
+		}
+		cost := int64(math.Ceil(p.Price))
+		costs[p.SKU] = cost
+		if p.BuyerSKUCode != "" {
+			costs[p.BuyerSKUCode] = cost
+		}
+	}
+	return costs, nil
+}
+
+// marginRuleFor returns the margin rule of a SKU, or the global rule when the SKU has none.
+// A SKU rule replaces the global one as a whole, fields it leaves out are zero.
+func marginRuleFor(global MarginRule, raw []byte) MarginRule {
+	if len(raw) == 0 || string(raw) == "null" {
+		return global
+	}
+	var rule MarginRule
+	if err := json.Unmarshal(raw, &rule); err != nil {
+		log.Warn().Err(err).Msg("Invalid SKU margin rule, using the global rule")
+		return global
+	}
+	return rule
+}
+
+// apply compares one price with the provider cost and stores the outcome.
+// Sell prices are only raised automatically; lowering them stays a manual decision.
+func (w *PriceWatcher) apply(ctx context.Context, settings PriceWatcherSettings, row watchedPrice, cost int64) (*PriceChange, error) {
+	rule := marginRuleFor(settings.MarginRule, row.marginRule)
+
+	newSell := row.sellPrice
+	isActive := row.isActive
+	autoDisabled := row.autoDisabled
+	action := ""
+
+	if settings.AutoAdjust {
+		if target := rule.Apply(cost); target > newSell {
+			newSell = target
+			action = PriceActionAdjusted
+		}
+	}
+
+	if newSell < cost {
+		if settings.AutoDisable && isActive {
+			isActive = false
+			autoDisabled = true
+			action = PriceActionDisabled
+		}
+	} else if !isActive && autoDisabled {
+		isActive = true
+		autoDisabled = false
+		action = PriceActionEnabled
+	}
+
+	if cost == row.buyPrice && action == "" {
+		return nil, nil
+	}
+	if action == "" {
+		action = PriceActionRecorded
+	}
+
+	var changePercent float64
+	if row.buyPrice > 0 {
+		changePercent = math.Round(float64(cost-row.buyPrice)/float64(row.buyPrice)*10000) / 100
+	}
+
+	tx, err := w.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// The price is only written when nobody changed it since it was loaded,
+	// an admin edit made during the run wins and is compared again next run
+	tag, err := tx.Exec(ctx, `
+		UPDATE sku_pricing
+		SET buy_price = $1, sell_price = $2, is_active = $3,
+		    auto_disabled_at = CASE WHEN $4::boolean THEN COALESCE(auto_disabled_at, NOW()) ELSE NULL END,
+		    updated_at = NOW()
+		WHERE id = $5 AND updated_at IS NOT DISTINCT FROM $6
+	`, cost, newSell, isActive, autoDisabled, row.pricingID, row.updatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update price: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		log.Info().Str("sku", row.skuCode).Str("region", row.region).Msg("Price changed during price watcher run, skipping")
+		return nil, nil
+	}
+
+	message := fmt.Sprintf("Provider cost %d -> %d", row.buyPrice, cost)
+	_, err = tx.Exec(ctx, `
+		INSERT INTO sku_price_history (
+			sku_id, sku_pricing_id, provider_id, region_code, currency,
+			old_buy_price, new_buy_price, old_sell_price, new_sell_price,
+			change_percent, action, message
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`, row.skuID, row.pricingID, row.providerID, row.region, row.currency,
+		row.buyPrice, cost, row.sellPrice, newSell,
+		changePercent, action, message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write price history: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return &PriceChange{
+		SKUCode:       row.skuCode,
+		SKUName:       row.skuName,
+		Provider:      row.providerCode,
+		Region:        row.region,
+		Currency:      row.currency,
+		OldBuyPrice:   row.buyPrice,
+		NewBuyPrice:   cost,
+		OldSellPrice:  row.sellPrice,
+		NewSellPrice:  newSell,
+		ChangePercent: changePercent,
+		Action:        action,
+	}, nil
+}
+
+// notify sends one alert listing the significant changes of a run
+func (w *PriceWatcher) notify(settings PriceWatcherSettings, changes []PriceChange) {
+	if w.notifications == nil {
+		return
+	}
+
+	lines := make([]string, 0, len(changes))
+	for _, c := range changes {
+		lines = append(lines, fmt.Sprintf("%s (%s) %s: cost %s -> %s (%+.2f%%), sell %s -> %s",
+			c.SKUCode, c.Region, c.Action,
+			utils.FormatCurrency(float64(c.OldBuyPrice), c.Currency),
+			utils.FormatCurrency(float64(c.NewBuyPrice), c.Currency),
+			c.ChangePercent,
+			utils.FormatCurrency(float64(c.OldSellPrice), c.Currency),
+			utils.FormatCurrency(float64(c.NewSellPrice), c.Currency)))
+	}
+
+	w.notifications.Notify(Notification{
+		Event:    EventPriceAlert,
+		Language: "en",
+		Email:    settings.AlertEmail,
+		Data: map[string]interface{}{
+			"Count":   len(changes),
+			"Changes": changes,
+			"Summary": strings.Join(lines, "\n"),
+			"Time":    time.Now().Format("02 Jan 2006 15:04 MST"),
+		},
+	})
+}
@@ -0,0 +1,55 @@
+package services
+
+import "testing"
+
+func TestMarginRuleFor(t *testing.T) {
+	global := MarginRule{Type: "percent", Value: 10, Floor: 500, RoundTo: 100}
+
+	tests := []struct {
+		name string
+		raw  string
+		want MarginRule
+	}{
+		{"no SKU rule", "", global},
+		{"null SKU rule", "null", global},
+		{"invalid SKU rule", "{", global},
+		{"full SKU rule", `{"type":"fixed","value":2000,"floor":0,"roundTo":50}`, MarginRule{Type: "fixed", Value: 2000, RoundTo: 50}},
+		{"partial SKU rule", `{"type":"fixed","value":2000}`, MarginRule{Type: "fixed", Value: 2000}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var raw []byte
+			if tt.raw != "" {
+				raw = []byte(tt.raw)
+			}
+			if got := marginRuleFor(global, raw); got != tt.want {
+				t.Errorf("marginRuleFor() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarginRuleApply(t *testing.T) {
+	tests := []struct {
+		name string
+		rule MarginRule
+		cost int64
+		want int64
+	}{
+		{"percent", MarginRule{Type: "percent", Value: 10}, 10000, 11000},
+		{"percent rounds margin up", MarginRule{Type: "percent", Value: 3}, 1001, 1032},
+		{"fixed", MarginRule{Type: "fixed", Value: 750}, 10000, 10750},
+		{"floor", MarginRule{Type: "percent", Value: 1, Floor: 500}, 10000, 10500},
+		{"round to", MarginRule{Type: "percent", Value: 10, RoundTo: 500}, 10100, 11500},
+		{"zero rule", MarginRule{}, 10000, 10000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.Apply(tt.cost); got != tt.want {
+				t.Errorf("Apply(%d) = %d, want %d", tt.cost, got, tt.want)
+			}
+		})
+	}
+}
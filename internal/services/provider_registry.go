@@ -0,0 +1,267 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"seaply/internal/database"
+	"seaply/internal/provider"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// ProviderReloadChannel carries provider changes to the other API instances
+const ProviderReloadChannel = "providers:reload"
+
+// Provider credential sources
+const (
+	CredentialSourceDatabase    = "DATABASE"
+	CredentialSourceEnvironment = "ENVIRONMENT"
+)
+
+// ProviderRegistry builds provider instances from the providers table and keeps
+// the provider manager in sync with admin changes. Providers without stored
+// credentials keep the instance configured from environment variables.
+type ProviderRegistry struct {
+	credentialCipher
+
+	pool      *pgxpool.Pool
+	redis     *database.RedisClient
+	providers *provider.Manager
+
+	mu           sync.Mutex
+	envProviders map[string]provider.Provider
+	sources      map[string]string
+}
+
+// providerRecord is a providers row needed to build an instance
+type providerRecord struct {
+	Code          string
+	Type          string
+	BaseURL       string
+	IsActive      bool
+	IsProduction  bool
+	APIConfig     []byte
+	Credentials   string
+	WebhookSecret string
+}
+
+// NewProviderRegistry creates a registry. Providers already registered in the
+// manager are remembered as the environment fallback.
+func NewProviderRegistry(pool *pgxpool.Pool, redis *database.RedisClient, providers *provider.Manager, credentialKey string) *ProviderRegistry {
+	r := &ProviderRegistry{
+		credentialCipher: credentialCipher{credentialKey: credentialKey},
+		pool:             pool,
+		redis:            redis,
+		providers:        providers,
+		envProviders:     make(map[string]provider.Provider),
+		sources:          make(map[string]string),
+	}
+	for _, p := range providers.GetAll() {
+		r.envProviders[p.GetName()] = p
+		r.sources[p.GetName()] = CredentialSourceEnvironment
+	}
+	return r
+}
+
+// Source returns where the registered instance of a provider was configured from,
+// or an empty string when it is not registered
+func (r *ProviderRegistry) Source(code string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sources[strings.ToLower(code)]
+}
+
+// LoadAll registers every provider configured in the database
+func (r *ProviderRegistry) LoadAll(ctx context.Context) error {
+	rows, err := r.pool.Query(ctx, providerRecordQuery+` ORDER BY priority ASC`)
+	if err != nil {
+		return fmt.Errorf("query providers: %w", err)
+	}
+	var records []providerRecord
+	for rows.Next() {
+		rec, err := scanProviderRecord(rows)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("scan provider: %w", err)
+		}
+		records = append(records, rec)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, rec := range records {
+		if err := r.apply(rec); err != nil {
+			log.Error().Err(err).Str("provider", rec.Code).Msg("Failed to register provider from database")
+		}
+	}
+	return nil
+}
+
+// Reload rebuilds the instance of one provider after its row changed
+func (r *ProviderRegistry) Reload(ctx context.Context, providerID string) error {
+	rec, err := scanProviderRecord(r.pool.QueryRow(ctx, providerRecordQuery+` WHERE id = $1`, providerID))
+	if err != nil {
+		return fmt.Errorf("load provider: %w", err)
+	}
+	return r.apply(rec)
+}
+
+// Remove unregisters a deleted provider
+func (r *ProviderRegistry) Remove(code string) {
+	name := strings.ToLower(code)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sources, name)
+	if r.providers.Unregister(name) {
+		log.Info().Str("provider", name).Msg("Unregistered provider")
+	}
+}
+
+// providerReloadMessage is published on ProviderReloadChannel
+type providerReloadMessage struct {
+	ProviderID string `json:"providerId,omitempty"`
+	Code       string `json:"code"`
+	Removed    bool   `json:"removed"`
+}
+
+// Changed reloads a provider after an admin change and tells the other instances to do the same
+func (r *ProviderRegistry) Changed(ctx context.Context, providerID, code string) error {
+	err := r.Reload(ctx, providerID)
+	r.publish(ctx, providerReloadMessage{ProviderID: providerID, Code: code})
+	return err
+}
+
+// Deleted unregisters a deleted provider here and on the other instances
+func (r *ProviderRegistry) Deleted(ctx context.Context, code string) {
+	r.Remove(code)
+	r.publish(ctx, providerReloadMessage{Code: code, Removed: true})
+}
+
+func (r *ProviderRegistry) publish(ctx context.Context, msg providerReloadMessage) {
+	if r.redis == nil {
+		return
+	}
+	if err := r.redis.Publish(ctx, ProviderReloadChannel, msg); err != nil {
+		log.Warn().Err(err).Str("provider", msg.Code).Msg("Failed to publish provider reload")
+	}
+}
+
+// Subscribe applies provider changes published by other instances
+func (r *ProviderRegistry) Subscribe(ctx context.Context) {
+	if r.redis == nil {
+		return
+	}
+	pubsub := r.redis.Subscribe(ctx, ProviderReloadChannel)
+	go func() {
+		defer pubsub.Close()
+
+		ch := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+
+				var reload providerReloadMessage
+				if err := json.Unmarshal([]byte(msg.Payload), &reload); err != nil {
+					log.Warn().Err(err).Msg("Invalid provider reload message")
+					continue
+				}
+				if reload.Removed {
+					r.Remove(reload.Code)
+					continue
+				}
+
+				reloadCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+				if err := r.Reload(reloadCtx, reload.ProviderID); err != nil {
+					log.Error().Err(err).Str("provider", reload.Code).Msg("Failed to reload provider")
+				}
+				cancel()
+			}
+		}
+	}()
+}
+
+const providerRecordQuery = `
+	SELECT code, COALESCE(provider_type, ''), base_url, COALESCE(is_active, true), is_production,
+		COALESCE(api_config, '{}'::jsonb), COALESCE(credentials, ''), COALESCE(webhook_secret, '')
+	FROM providers`
+
+func scanProviderRecord(row pgx.Row) (providerRecord, error) {
+	var rec providerRecord
+	err := row.Scan(&rec.Code, &rec.Type, &rec.BaseURL, &rec.IsActive, &rec.IsProduction,
+		&rec.APIConfig, &rec.Credentials, &rec.WebhookSecret)
+	return rec, err
+}
+
+// apply registers, replaces or unregisters the instance of a provider to match its row
+func (r *ProviderRegistry) apply(rec providerRecord) error {
+	name := strings.ToLower(rec.Code)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !rec.IsActive {
+		delete(r.sources, name)
+		if r.providers.Unregister(name) {
+			log.Info().Str("provider", name).Msg("Unregistered inactive provider")
+		}
+		return nil
+	}
+
+	// Without stored credentials the provider falls back to its environment configuration
+	if rec.Credentials == "" || rec.Type == "" {
+		if p, ok := r.envProviders[name]; ok {
+			r.providers.Register(p)
+			r.sources[name] = CredentialSourceEnvironment
+		} else {
+			delete(r.sources, name)
+			r.providers.Unregister(name)
+		}
+		return nil
+	}
+
+	credentials, err := r.DecryptCredentials(rec.Credentials)
+	if err != nil {
+		return fmt.Errorf("decrypt credentials: %w", err)
+	}
+	webhookSecret, err := r.DecryptSecret(rec.WebhookSecret)
+	if err != nil {
+		return fmt.Errorf("decrypt webhook secret: %w", err)
+	}
+
+	var apiConfig struct {
+		Timeout int `json:"timeout"` // milliseconds
+	}
+	_ = json.Unmarshal(rec.APIConfig, &apiConfig)
+
+	p, err := provider.Build(rec.Type, provider.InstanceConfig{
+		Name:          name,
+		BaseURL:       rec.BaseURL,
+		Credentials:   credentials,
+		WebhookSecret: webhookSecret,
+		Timeout:       time.Duration(apiConfig.Timeout) * time.Millisecond,
+		IsProduction:  rec.IsProduction,
+	})
+	if err != nil {
+		return err
+	}
+
+	r.providers.Register(p)
+	r.sources[name] = CredentialSourceDatabase
+	log.Info().Str("provider", name).Str("type", rec.Type).Msg("Registered provider from database")
+	return nil
+}
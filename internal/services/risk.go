@@ -0,0 +1,788 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"seaply/internal/utils"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// Actions taken on a checkout, from the least to the most severe
+const (
+	RiskAllow        = "ALLOW"
+	RiskRequireLogin = "REQUIRE_LOGIN"
+	RiskRequireMFA   = "REQUIRE_MFA"
+	RiskHold         = "HOLD"
+	RiskBlock        = "BLOCK"
+)
+
+var riskActionSeverity = map[string]int{
+	RiskAllow:        0,
+	RiskRequireLogin: 1,
+	RiskRequireMFA:   2,
+	RiskHold:         3,
+	RiskBlock:        4,
+}
+
+// What a checkout creates
+const (
+	RiskKindOrder   = "ORDER"
+	RiskKindDeposit = "DEPOSIT"
+)
+
+// Review statuses of held checkouts
+const (
+	RiskReviewPending   = "PENDING"
+	RiskReviewReleased  = "RELEASED"
+	RiskReviewCancelled = "CANCELLED"
+)
+
+// Signals matched by risk rules. Velocities count checkouts of the same kind over
+// the last hour, including the one being evaluated.
+const (
+	RiskSignalIPVelocity       = "ipPerHour"
+	RiskSignalDeviceVelocity   = "devicePerHour"
+	RiskSignalAccountVelocity  = "accountPerHour"
+	RiskSignalGameIDVelocity   = "gameIdPerHour"
+	RiskSignalDeviceGameIDs    = "deviceGameIds"    // distinct game IDs ordered from the device over a day
+	RiskSignalNewAccountAmount = "newAccountAmount" // amount checked out by an account younger than NewAccountAge
+	RiskSignalRegionMismatch   = "regionMismatch"   // 1 when the country of the IP isn't the region
+	RiskSignalFailedPayments   = "failedPayments"   // orders and deposits of the account or IP left unpaid over a day
+)
+
+var ErrRiskReviewNotPending = errors.New("risk review is no longer pending")
+
+// RiskRule adds its score and applies its action when a signal reaches the threshold.
+// Amount signals use Thresholds, per currency; a currency missing from them never matches.
+type RiskRule struct {
+	Name       string             `json:"name"`
+	Signal     string             `json:"signal"`
+	Threshold  float64            `json:"threshold"`
+	Thresholds map[string]float64 `json:"thresholds,omitempty"`
+	Kinds      []string           `json:"kinds,omitempty"` // ORDER, DEPOSIT; all when empty
+	Score      int                `json:"score"`
+	Action     string             `json:"action"`
+}
+
+// RiskSettings configures the risk engine. The action of a checkout is the most
+// severe of its matched rules and of the ScoreActions its total score reaches.
+type RiskSettings struct {
+	Enabled       bool
+	NewAccountAge time.Duration
+	ReleaseWindow time.Duration // a customer released by a reviewer isn't held again within it
+	CountryHeader string        // set by the CDN with the country of the client IP
+	Rules         []RiskRule
+	ScoreActions  map[string]int
+}
+
+// RiskCheck describes a checkout to evaluate
+type RiskCheck struct {
+	Kind      string
+	UserID    string
+	IPAddress string
+	DeviceID  string
+	GameID    string
+	Region    string
+	Country   string
+	Amount    int64
+	Currency  string
+}
+
+// RiskAssessment is the outcome of a checkout evaluation
+type RiskAssessment struct {
+	ID      string             `json:"id"`
+	Score   int                `json:"score"`
+	Action  string             `json:"action"`
+	Rules   []string           `json:"rules"`
+	Signals map[string]float64 `json:"signals"`
+	Check   RiskCheck          `json:"-"`
+}
+
+// RiskDB is a pool or a transaction
+type RiskDB interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+// RiskAdmin is the reviewer of a held checkout
+type RiskAdmin struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// RiskTransaction is the order of a held checkout
+type RiskTransaction struct {
+	ID            string `json:"id"`
+	InvoiceNumber string `json:"invoiceNumber"`
+	Status        string `json:"status"`
+	PaymentStatus string `json:"paymentStatus"`
+	TotalAmount   int64  `json:"totalAmount"`
+}
+
+// RiskEvaluation is a recorded checkout evaluation and its review
+type RiskEvaluation struct {
+	ID           string           `json:"id"`
+	Kind         string           `json:"kind"`
+	UserID       string           `json:"userId"`
+	UserEmail    string           `json:"userEmail"`
+	IPAddress    string           `json:"ipAddress"`
+	DeviceID     string           `json:"deviceId"`
+	GameID       string           `json:"gameId"`
+	Region       string           `json:"region"`
+	IPCountry    string           `json:"ipCountry"`
+	Amount       int64            `json:"amount"`
+	Currency     string           `json:"currency"`
+	Score        int              `json:"score"`
+	Action       string           `json:"action"`
+	Rules        []string         `json:"rules"`
+	Signals      json.RawMessage  `json:"signals"`
+	Transaction  *RiskTransaction `json:"transaction"`
+	ReviewStatus string           `json:"reviewStatus"`
+	ReviewedBy   *RiskAdmin       `json:"reviewedBy"`
+	ReviewedAt   *time.Time       `json:"reviewedAt"`
+	ReviewNote   string           `json:"reviewNote"`
+	CreatedAt    time.Time        `json:"createdAt"`
+	UpdatedAt    time.Time        `json:"updatedAt"`
+}
+
+// RiskEngine scores checkouts for fraud and keeps the queue of held ones
+type RiskEngine struct {
+	pool          *pgxpool.Pool
+	notifications *NotificationService
+	onRelease     func(ctx context.Context, evaluation *RiskEvaluation)
+}
+
+// NewRiskEngine creates a risk engine
+func NewRiskEngine(pool *pgxpool.Pool, notifications *NotificationService) *RiskEngine {
+	return &RiskEngine{pool: pool, notifications: notifications}
+}
+
+// OnRelease sets the function called once a held order is released, which sends it
+// to its provider when it's paid
+func (s *RiskEngine) OnRelease(fn func(ctx context.Context, evaluation *RiskEvaluation)) {
+	s.onRelease = fn
+}
+
+// LoadSettings reads the risk settings, falling back to defaults
+func (s *RiskEngine) LoadSettings(ctx context.Context) (RiskSettings, error) {
+	settings := RiskSettings{
+		Enabled:       true,
+		NewAccountAge: 24 * time.Hour,
+		ReleaseWindow: 24 * time.Hour,
+		CountryHeader: "CF-IPCountry",
+		ScoreActions:  map[string]int{},
+	}
+
+	rows, err := s.pool.Query(ctx, `SELECT key, value FROM settings WHERE category = 'risk'`)
+	if err != nil {
+		return settings, fmt.Errorf("failed to load risk settings: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			return settings, fmt.Errorf("failed to scan risk setting: %w", err)
+		}
+
+		switch key {
+		case "enabled":
+			_ = json.Unmarshal(value, &settings.Enabled)
+		case "newAccountHours":
+			var hours int
+			if json.Unmarshal(value, &hours) == nil && hours > 0 {
+				settings.NewAccountAge = time.Duration(hours) * time.Hour
+			}
+		case "releaseWindowHours":
+			var hours int
+			if json.Unmarshal(value, &hours) == nil && hours >= 0 {
+				settings.ReleaseWindow = time.Duration(hours) * time.Hour
+			}
+		case "countryHeader":
+			_ = json.Unmarshal(value, &settings.CountryHeader)
+		case "rules":
+			var rules []RiskRule
+			if err := json.Unmarshal(value, &rules); err != nil {
+				log.Warn().Err(err).Msg("Invalid risk rules setting")
+				continue
+			}
+			for _, rule := range rules {
+				if _, ok := riskActionSeverity[strings.ToUpper(rule.Action)]; !ok {
+					log.Warn().Str("rule", rule.Name).Str("action", rule.Action).Msg("Ignoring risk rule with unknown action")
+					continue
+				}
+				rule.Action = strings.ToUpper(rule.Action)
+				settings.Rules = append(settings.Rules, rule)
+			}
+		case "scoreActions":
+			scoreActions := map[string]int{}
+			if err := json.Unmarshal(value, &scoreActions); err != nil {
+				log.Warn().Err(err).Msg("Invalid risk score actions setting")
+				continue
+			}
+			for action, score := range scoreActions {
+				if _, ok := riskActionSeverity[strings.ToUpper(action)]; ok && score > 0 {
+					settings.ScoreActions[strings.ToUpper(action)] = score
+				}
+			}
+		}
+	}
+
+	return settings, rows.Err()
+}
+
+// Country returns the country of the client IP set by the CDN, empty when unknown
+func (s *RiskSettings) Country(header http.Header) string {
+	if s.CountryHeader == "" {
+		return ""
+	}
+	country := strings.ToUpper(strings.TrimSpace(header.Get(s.CountryHeader)))
+	if len(country) != 2 || country == "XX" {
+		return ""
+	}
+	return country
+}
+
+// matches reports whether the rule applies to the checkout
+func (r *RiskRule) matches(check RiskCheck, signals map[string]float64) bool {
+	if len(r.Kinds) > 0 {
+		found := false
+		for _, kind := range r.Kinds {
+			if strings.EqualFold(kind, check.Kind) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	value, ok := signals[r.Signal]
+	if !ok {
+		return false
+	}
+	threshold := r.Threshold
+	if len(r.Thresholds) > 0 {
+		if threshold, ok = r.Thresholds[strings.ToUpper(check.Currency)]; !ok {
+			return false
+		}
+	}
+	return threshold > 0 && value >= threshold
+}
+
+func severerRiskAction(a, b string) string {
+	if riskActionSeverity[b] > riskActionSeverity[a] {
+		return b
+	}
+	return a
+}
+
+// Evaluate scores a checkout and records it, so it counts towards later velocities.
+// Login and MFA requirements the customer already meets are allowed.
+func (s *RiskEngine) Evaluate(ctx context.Context, settings RiskSettings, check RiskCheck) (*RiskAssessment, error) {
+	assessment := &RiskAssessment{Action: RiskAllow, Rules: []string{}, Check: check}
+	if !settings.Enabled {
+		return assessment, nil
+	}
+	if len(check.DeviceID) > 255 {
+		check.DeviceID = check.DeviceID[:255]
+		assessment.Check = check
+	}
+
+	signals, mfaActive, err := s.signals(ctx, settings, check)
+	if err != nil {
+		return assessment, err
+	}
+	assessment.Signals = signals
+
+	action := RiskAllow
+	for _, rule := range settings.Rules {
+		if !rule.matches(check, signals) {
+			continue
+		}
+		assessment.Score += rule.Score
+		assessment.Rules = append(assessment.Rules, rule.Name)
+		action = severerRiskAction(action, rule.Action)
+	}
+	for scoreAction, score := range settings.ScoreActions {
+		if assessment.Score >= score {
+			action = severerRiskAction(action, scoreAction)
+		}
+	}
+
+	switch {
+	case action == RiskRequireLogin && check.UserID != "":
+		action = RiskAllow
+	case action == RiskRequireMFA && mfaActive:
+		action = RiskAllow
+	case action == RiskHold && s.released(ctx, settings, check):
+		action = RiskAllow
+	}
+	assessment.Action = action
+
+	signalsJSON, _ := json.Marshal(signals)
+	rulesJSON, _ := json.Marshal(assessment.Rules)
+	err = s.pool.QueryRow(ctx, `
+		INSERT INTO risk_evaluations (
+			kind, user_id, ip_address, device_id, game_id, region, ip_country,
+			amount, currency, score, signals, rules, action
+		) VALUES ($1, $2::uuid, $3::inet, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id
+	`, check.Kind, nullableString(check.UserID), auditIP(check.IPAddress), nullableString(check.DeviceID),
+		nullableString(check.GameID), nullableString(check.Region), nullableString(check.Country),
+		check.Amount, nullableString(strings.ToUpper(check.Currency)), assessment.Score,
+		signalsJSON, rulesJSON, assessment.Action).Scan(&assessment.ID)
+	if err != nil {
+		return assessment, fmt.Errorf("record risk evaluation: %w", err)
+	}
+
+	if assessment.Action != RiskAllow {
+		log.Warn().
+			Str("risk_evaluation_id", assessment.ID).
+			Str("kind", check.Kind).
+			Str("action", assessment.Action).
+			Int("score", assessment.Score).
+			Strs("rules", assessment.Rules).
+			Str("ip_address", check.IPAddress).
+			Str("user_id", check.UserID).
+			Msg("Risky checkout")
+	}
+	return assessment, nil
+}
+
+// signals measures the checkout against the recent activity of its IP, device,
+// account and game ID, and reports whether the account has MFA active
+func (s *RiskEngine) signals(ctx context.Context, settings RiskSettings, check RiskCheck) (map[string]float64, bool, error) {
+	var ipCount, deviceCount, accountCount, gameIDCount, deviceGameIDs, failedPayments int
+	var accountCreatedAt *time.Time
+	var mfaActive bool
+	err := s.pool.QueryRow(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM risk_evaluations
+			 WHERE kind = $1 AND ip_address = $2::inet AND created_at > NOW() - INTERVAL '1 hour'),
+			(SELECT COUNT(*) FROM risk_evaluations
+			 WHERE kind = $1 AND device_id = $3 AND created_at > NOW() - INTERVAL '1 hour'),
+			(SELECT COUNT(*) FROM risk_evaluations
+			 WHERE kind = $1 AND user_id = $4::uuid AND created_at > NOW() - INTERVAL '1 hour'),
+			(SELECT COUNT(*) FROM risk_evaluations
+			 WHERE kind = $1 AND game_id = $5 AND created_at > NOW() - INTERVAL '1 hour'),
+			(SELECT COUNT(DISTINCT game_id) FROM risk_evaluations
+			 WHERE device_id = $3 AND game_id IS NOT NULL AND game_id IS DISTINCT FROM $5
+			   AND created_at > NOW() - INTERVAL '1 day'),
+			(SELECT COUNT(*) FROM transactions
+			 WHERE payment_status = 'EXPIRED' AND (user_id = $4::uuid OR ip_address = $2::inet)
+			   AND created_at > NOW() - INTERVAL '1 day')
+			+ (SELECT COUNT(*) FROM deposits
+			 WHERE status IN ('FAILED', 'EXPIRED') AND (user_id = $4::uuid OR ip_address = $2::inet)
+			   AND created_at > NOW() - INTERVAL '1 day'),
+			(SELECT created_at FROM users WHERE id = $4::uuid),
+			COALESCE((SELECT mfa_status = 'ACTIVE' FROM users WHERE id = $4::uuid), false)
+	`, check.Kind, auditIP(check.IPAddress), nullableString(check.DeviceID), nullableString(check.UserID),
+		nullableString(check.GameID)).Scan(&ipCount, &deviceCount, &accountCount, &gameIDCount,
+		&deviceGameIDs, &failedPayments, &accountCreatedAt, &mfaActive)
+	if err != nil {
+		return nil, false, fmt.Errorf("measure risk signals: %w", err)
+	}
+
+	// The checkout being evaluated counts too
+	signals := map[string]float64{
+		RiskSignalFailedPayments: float64(failedPayments),
+		RiskSignalRegionMismatch: 0,
+	}
+	if auditIP(check.IPAddress) != nil {
+		signals[RiskSignalIPVelocity] = float64(ipCount + 1)
+	}
+	if check.DeviceID != "" {
+		signals[RiskSignalDeviceVelocity] = float64(deviceCount + 1)
+		signals[RiskSignalDeviceGameIDs] = float64(deviceGameIDs)
+		if check.GameID != "" {
+			signals[RiskSignalDeviceGameIDs]++
+		}
+	}
+	if check.UserID != "" {
+		signals[RiskSignalAccountVelocity] = float64(accountCount + 1)
+	}
+	if check.GameID != "" {
+		signals[RiskSignalGameIDVelocity] = float64(gameIDCount + 1)
+	}
+	if accountCreatedAt != nil && time.Since(*accountCreatedAt) < settings.NewAccountAge {
+		signals[RiskSignalNewAccountAmount] = float64(check.Amount)
+	}
+	if check.Country != "" && check.Region != "" && !strings.EqualFold(check.Country, check.Region) {
+		signals[RiskSignalRegionMismatch] = 1
+	}
+	return signals, mfaActive, nil
+}
+
+// released reports whether a reviewer released a checkout of the same account, or
+// device for guests, within the release window
+func (s *RiskEngine) released(ctx context.Context, settings RiskSettings, check RiskCheck) bool {
+	if settings.ReleaseWindow <= 0 || (check.UserID == "" && check.DeviceID == "") {
+		return false
+	}
+
+	column, value := "user_id::text", check.UserID
+	if value == "" {
+		column, value = "device_id", check.DeviceID
+	}
+	var released bool
+	err := s.pool.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM risk_evaluations
+			WHERE `+column+` = $1 AND kind = $2 AND review_status = 'RELEASED'
+			  AND reviewed_at > NOW() - $3::interval
+		)
+	`, value, check.Kind, strconv.Itoa(int(settings.ReleaseWindow.Seconds()))+" seconds").Scan(&released)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to check released risk reviews")
+		return false
+	}
+	return released
+}
+
+// Hold queues an evaluated checkout for review. Orders are held with their
+// transaction, through db when it's the transaction creating it.
+func (s *RiskEngine) Hold(ctx context.Context, db RiskDB, assessment *RiskAssessment, transactionID string) error {
+	if _, err := db.Exec(ctx, `
+		UPDATE risk_evaluations
+		SET review_status = 'PENDING', transaction_id = $2::uuid, updated_at = NOW()
+		WHERE id = $1
+	`, assessment.ID, nullableString(transactionID)); err != nil {
+		return fmt.Errorf("hold risk evaluation: %w", err)
+	}
+	return nil
+}
+
+// NotifyHold tells the ops channel about a held checkout once it's committed;
+// reference identifies it for the reviewers, such as the invoice number
+func (s *RiskEngine) NotifyHold(assessment *RiskAssessment, reference string) {
+	if s.notifications == nil {
+		return
+	}
+
+	check := assessment.Check
+	s.notifications.Notify(Notification{
+		Event:         EventRiskHold,
+		Language:      "en",
+		Channels:      []Channel{ChannelTelegram},
+		ReferenceType: "RISK",
+		ReferenceID:   assessment.ID,
+		Data: map[string]interface{}{
+			"ID":        assessment.ID,
+			"Kind":      check.Kind,
+			"Reference": reference,
+			"Amount":    utils.FormatCurrency(float64(check.Amount), check.Currency),
+			"Score":     assessment.Score,
+			"Rules":     strings.Join(assessment.Rules, ", "),
+			"IPAddress": check.IPAddress,
+		},
+	})
+}
+
+// ReviewStatus returns the review status of a transaction, empty when it was never held
+func (s *RiskEngine) ReviewStatus(ctx context.Context, transactionID string) (string, error) {
+	var status string
+	err := s.pool.QueryRow(ctx, `
+		SELECT review_status FROM risk_evaluations
+		WHERE transaction_id = $1 AND review_status IS NOT NULL
+		ORDER BY created_at DESC
+		LIMIT 1
+	`, transactionID).Scan(&status)
+	if err == pgx.ErrNoRows {
+		return "", nil
+	}
+	return status, err
+}
+
+// Release lets a held checkout through. Paid orders are then sent to their provider,
+// unpaid ones are fulfilled once paid.
+func (s *RiskEngine) Release(ctx context.Context, id, adminID, note string) (*RiskEvaluation, error) {
+	if err := s.decide(ctx, id, adminID, RiskReviewReleased, note); err != nil {
+		return nil, err
+	}
+
+	evaluation, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if evaluation.Transaction != nil && s.onRelease != nil {
+		s.onRelease(ctx, evaluation)
+	}
+	return evaluation, nil
+}
+
+// Cancel refuses a held checkout, failing its order. An order paid from the wallet
+// is refunded to it; one paid through a gateway still has to be refunded by an admin.
+func (s *RiskEngine) Cancel(ctx context.Context, id, adminID, note string) (*RiskEvaluation, error) {
+	if err := s.decide(ctx, id, adminID, RiskReviewCancelled, note); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+// decide records the review of a pending checkout and notes it on the order timeline.
+// The email about an order failed by the cancellation, and the wallet refund of a
+// balance-paid one, are queued in the same transaction.
+func (s *RiskEngine) decide(ctx context.Context, id, adminID, status, note string) error {
+	var notification, refundNotification Notification
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		var reviewStatus, transactionID *string
+		err := tx.QueryRow(ctx, `
+			SELECT review_status, transaction_id::text FROM risk_evaluations WHERE id = $1 FOR UPDATE
+		`, id).Scan(&reviewStatus, &transactionID)
+		if err != nil {
+			return err
+		}
+		if reviewStatus == nil || *reviewStatus != RiskReviewPending {
+			return ErrRiskReviewNotPending
+		}
+
+		if _, err := tx.Exec(ctx, `
+			UPDATE risk_evaluations
+			SET review_status = $2, reviewed_by = $3, reviewed_at = NOW(), review_note = NULLIF($4, ''), updated_at = NOW()
+			WHERE id = $1
+		`, id, status, nullableString(adminID), strings.TrimSpace(note)); err != nil {
+			return err
+		}
+		if transactionID == nil {
+			return nil
+		}
+
+		if status == RiskReviewCancelled {
+			tag, err := tx.Exec(ctx, `
+				UPDATE transactions SET status = 'FAILED', updated_at = NOW()
+				WHERE id = $1 AND status IN ('PENDING', 'PROCESSING')
+			`, *transactionID)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, `
+				INSERT INTO transaction_logs (transaction_id, status, message, created_at)
+				VALUES ($1, 'FAILED', 'Order cancelled after review.', NOW())
+			`, *transactionID); err != nil {
+				return err
+			}
+			if tag.RowsAffected() == 0 {
+				return nil
+			}
+
+			refund, currency, err := refundCancelledOrder(ctx, tx, *transactionID)
+			if err != nil {
+				return fmt.Errorf("refund cancelled order: %w", err)
+			}
+
+			// The notifications run in a savepoint, a failure leaves the cancellation to commit
+			notification, err = s.notifications.NotifyTransactionTx(ctx, tx, *transactionID, EventOrderFailed, nil)
+			if err != nil {
+				log.Warn().Err(err).Str("transaction_id", *transactionID).Msg("Failed to queue transaction notification")
+			}
+			if refund > 0 {
+				refundNotification, err = s.notifications.NotifyTransactionTx(ctx, tx, *transactionID, EventRefund, map[string]interface{}{
+					"Amount":   utils.FormatCurrency(float64(refund), currency),
+					"RefundTo": "BALANCE",
+					"Reason":   "Order cancelled after review",
+				})
+				if err != nil {
+					log.Warn().Err(err).Str("transaction_id", *transactionID).Msg("Failed to queue refund notification")
+				}
+			}
+			return nil
+		}
+
+		_, err = tx.Exec(ctx, `
+			INSERT INTO transaction_logs (transaction_id, status, message, created_at)
+			VALUES ($1, 'REVIEW', 'Order released after review.', NOW())
+		`, *transactionID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.notifications.Notify(notification)
+	s.notifications.Notify(refundNotification)
+	return nil
+}
+
+// refundCancelledOrder credits what was paid for a balance-paid order cancelled
+// after review back to the user's wallet, with its mutation, in tx. It returns the
+// refunded amount, zero for orders that are unpaid or paid through a gateway.
+func refundCancelledOrder(ctx context.Context, tx pgx.Tx, transactionID string) (int64, string, error) {
+	var userID, invoiceNumber, currency string
+	var amount int64
+	err := tx.QueryRow(ctx, `
+		SELECT t.user_id::text, t.invoice_number, t.currency::text, t.total_amount - t.refunded_amount
+		FROM transactions t
+		JOIN payment_channels pc ON pc.id = t.payment_channel_id
+		WHERE t.id = $1 AND t.user_id IS NOT NULL AND t.payment_status = 'PAID' AND pc.code = 'BALANCE'
+		FOR UPDATE OF t
+	`, transactionID).Scan(&userID, &invoiceNumber, &currency, &amount)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, "", nil
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	if amount <= 0 {
+		return 0, currency, nil
+	}
+
+	column := walletBalanceColumn(currency)
+	var balanceAfter int64
+	err = tx.QueryRow(ctx, `
+		UPDATE users SET `+column+` = `+column+` + $1, updated_at = NOW()
+		WHERE id = $2
+		RETURNING `+column+`
+	`, amount, userID).Scan(&balanceAfter)
+	if err != nil {
+		return 0, "", fmt.Errorf("credit balance: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO mutations (
+			user_id, invoice_number, reference_type, reference_id,
+			description, mutation_type, amount,
+			balance_before, balance_after, currency, created_at
+		) VALUES ($1, $2, 'REFUND', $3, $4, 'CREDIT', $5, $6, $7, $8, NOW())
+	`, userID, invoiceNumber, transactionID, "Pengembalian Dana - Pesanan dibatalkan setelah review", amount,
+		balanceAfter-amount, balanceAfter, currency); err != nil {
+		return 0, "", fmt.Errorf("record mutation: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE transactions SET refunded_amount = total_amount, updated_at = NOW() WHERE id = $1
+	`, transactionID); err != nil {
+		return 0, "", fmt.Errorf("record refunded amount: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO transaction_logs (transaction_id, status, message, created_at)
+		VALUES ($1, 'REFUNDED', $2, NOW())
+	`, transactionID, fmt.Sprintf("Refund of %s has been added to your balance.",
+		utils.FormatCurrency(float64(amount), currency))); err != nil {
+		return 0, "", fmt.Errorf("record refund log: %w", err)
+	}
+	return amount, currency, nil
+}
+
+// walletBalanceColumn returns the users column holding the wallet balance in currency
+func walletBalanceColumn(currency string) string {
+	switch currency {
+	case "MYR":
+		return "balance_myr"
+	case "PHP":
+		return "balance_php"
+	case "SGD":
+		return "balance_sgd"
+	case "THB":
+		return "balance_thb"
+	default:
+		return "balance_idr"
+	}
+}
+
+const riskEvaluationColumns = `
+	re.id, re.kind, COALESCE(re.user_id::text, ''), COALESCE(u.email, ''),
+	COALESCE(host(re.ip_address), ''), COALESCE(re.device_id, ''), COALESCE(re.game_id, ''),
+	COALESCE(re.region, ''), COALESCE(re.ip_country, ''), re.amount, COALESCE(re.currency, ''),
+	re.score, re.action, re.rules, re.signals,
+	t.id::text, COALESCE(t.invoice_number, ''), COALESCE(t.status::text, ''),
+	COALESCE(t.payment_status::text, ''), COALESCE(t.total_amount, 0),
+	COALESCE(re.review_status, ''), re.reviewed_by::text, COALESCE(a.name, ''), COALESCE(a.email, ''),
+	re.reviewed_at, COALESCE(re.review_note, ''), re.created_at, re.updated_at`
+
+const riskEvaluationFrom = `
+	FROM risk_evaluations re
+	LEFT JOIN users u ON re.user_id = u.id
+	LEFT JOIN transactions t ON re.transaction_id = t.id
+	LEFT JOIN admins a ON re.reviewed_by = a.id`
+
+func scanRiskEvaluation(row pgx.Row) (*RiskEvaluation, error) {
+	var e RiskEvaluation
+	var rules, signals []byte
+	var transactionID, reviewedByID *string
+	var transaction RiskTransaction
+	var reviewedByName, reviewedByEmail string
+	err := row.Scan(&e.ID, &e.Kind, &e.UserID, &e.UserEmail,
+		&e.IPAddress, &e.DeviceID, &e.GameID,
+		&e.Region, &e.IPCountry, &e.Amount, &e.Currency,
+		&e.Score, &e.Action, &rules, &signals,
+		&transactionID, &transaction.InvoiceNumber, &transaction.Status,
+		&transaction.PaymentStatus, &transaction.TotalAmount,
+		&e.ReviewStatus, &reviewedByID, &reviewedByName, &reviewedByEmail,
+		&e.ReviewedAt, &e.ReviewNote, &e.CreatedAt, &e.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	e.Rules = []string{}
+	_ = json.Unmarshal(rules, &e.Rules)
+	e.Signals = signals
+	if transactionID != nil {
+		transaction.ID = *transactionID
+		e.Transaction = &transaction
+	}
+	if reviewedByID != nil {
+		e.ReviewedBy = &RiskAdmin{ID: *reviewedByID, Name: reviewedByName, Email: reviewedByEmail}
+	}
+	return &e, nil
+}
+
+// Get returns an evaluation, pgx.ErrNoRows when it doesn't exist
+func (s *RiskEngine) Get(ctx context.Context, id string) (*RiskEvaluation, error) {
+	return scanRiskEvaluation(s.pool.QueryRow(ctx, `SELECT `+riskEvaluationColumns+riskEvaluationFrom+` WHERE re.id = $1`, id))
+}
+
+// List returns a page of evaluations, newest first, and the total count. The review
+// queue is the evaluations with a PENDING review status.
+func (s *RiskEngine) List(ctx context.Context, reviewStatus, action, kind string, limit, offset int) ([]RiskEvaluation, int, error) {
+	where := " WHERE 1=1"
+	args := []interface{}{}
+	if reviewStatus != "" {
+		args = append(args, strings.ToUpper(reviewStatus))
+		where += " AND re.review_status = $" + strconv.Itoa(len(args))
+	}
+	if action != "" {
+		args = append(args, strings.ToUpper(action))
+		where += " AND re.action = $" + strconv.Itoa(len(args))
+	}
+	if kind != "" {
+		args = append(args, strings.ToUpper(kind))
+		where += " AND re.kind = $" + strconv.Itoa(len(args))
+	}
+
+	var total int
+	if err := s.pool.QueryRow(ctx, `SELECT COUNT(*) FROM risk_evaluations re`+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("count risk evaluations: %w", err)
+	}
+
+	args = append(args, limit, offset)
+	rows, err := s.pool.Query(ctx, `SELECT `+riskEvaluationColumns+riskEvaluationFrom+where+`
+		ORDER BY re.created_at DESC
+		LIMIT $`+strconv.Itoa(len(args)-1)+` OFFSET $`+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query risk evaluations: %w", err)
+	}
+	defer rows.Close()
+
+	evaluations := []RiskEvaluation{}
+	for rows.Next() {
+		evaluation, err := scanRiskEvaluation(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scan risk evaluation: %w", err)
+		}
+		evaluations = append(evaluations, *evaluation)
+	}
+	return evaluations, total, rows.Err()
+}
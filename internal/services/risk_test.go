@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// refundTx answers the queries of refundCancelledOrder from a list of rows and
+// records the statements it runs
+type refundTx struct {
+	pgx.Tx
+	rows    []valuesRow
+	queries []string
+	execs   []recordedExec
+	execErr error
+}
+
+type recordedExec struct {
+	sql  string
+	args []any
+}
+
+type valuesRow struct {
+	values []any
+	err    error
+}
+
+func (r valuesRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	for i, d := range dest {
+		switch d := d.(type) {
+		case *string:
+			*d = r.values[i].(string)
+		case *int64:
+			*d = r.values[i].(int64)
+		}
+	}
+	return nil
+}
+
+func (tx *refundTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	tx.queries = append(tx.queries, sql)
+	row := tx.rows[0]
+	tx.rows = tx.rows[1:]
+	return row
+}
+
+func (tx *refundTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	tx.execs = append(tx.execs, recordedExec{sql: sql, args: args})
+	return pgconn.CommandTag{}, tx.execErr
+}
+
+func TestRefundCancelledOrder(t *testing.T) {
+	mutationErr := errors.New("mutations is locked")
+
+	tests := []struct {
+		name       string
+		rows       []valuesRow
+		execErr    error
+		wantAmount int64
+		wantColumn string
+		wantBefore int64
+		wantExecs  int
+		wantErr    error
+	}{
+		{
+			name: "not paid from the wallet",
+			rows: []valuesRow{{err: pgx.ErrNoRows}},
+		},
+		{
+			name: "already refunded",
+			rows: []valuesRow{{values: []any{"user-1", "SEAI1", "IDR", int64(0)}}},
+		},
+		{
+			name: "rupiah order",
+			rows: []valuesRow{
+				{values: []any{"user-1", "SEAI1", "IDR", int64(150000)}},
+				{values: []any{int64(200000)}},
+			},
+			wantAmount: 150000,
+			wantColumn: "balance_idr",
+			wantBefore: 50000,
+			wantExecs:  3,
+		},
+		{
+			name: "baht order",
+			rows: []valuesRow{
+				{values: []any{"user-1", "SEAI2", "THB", int64(350)}},
+				{values: []any{int64(350)}},
+			},
+			wantAmount: 350,
+			wantColumn: "balance_thb",
+			wantBefore: 0,
+			wantExecs:  3,
+		},
+		{
+			name: "mutation fails",
+			rows: []valuesRow{
+				{values: []any{"user-1", "SEAI1", "IDR", int64(150000)}},
+				{values: []any{int64(150000)}},
+			},
+			execErr:   mutationErr,
+			wantExecs: 1,
+			wantErr:   mutationErr,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := &refundTx{rows: tt.rows, execErr: tt.execErr}
+			amount, _, err := refundCancelledOrder(context.Background(), tx, "tx-1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("refundCancelledOrder() error = %v, want %v", err, tt.wantErr)
+			}
+			if amount != tt.wantAmount {
+				t.Errorf("refunded %d, want %d", amount, tt.wantAmount)
+			}
+			if len(tx.execs) != tt.wantExecs {
+				t.Fatalf("ran %d statements, want %d", len(tx.execs), tt.wantExecs)
+			}
+			if tt.wantColumn == "" || tt.wantErr != nil {
+				return
+			}
+
+			if credit := tx.queries[1]; !strings.Contains(credit, tt.wantColumn+" = "+tt.wantColumn+" + $1") {
+				t.Errorf("balance credited with %q, want column %s", credit, tt.wantColumn)
+			}
+			mutation := tx.execs[0]
+			if !strings.Contains(mutation.sql, "'REFUND'") || !strings.Contains(mutation.sql, "'CREDIT'") {
+				t.Errorf("mutation is not a refund credit: %s", mutation.sql)
+			}
+			if got := mutation.args[4]; got != tt.wantAmount {
+				t.Errorf("mutation amount %v, want %d", got, tt.wantAmount)
+			}
+			if got := mutation.args[5]; got != tt.wantBefore {
+				t.Errorf("mutation balance before %v, want %d", got, tt.wantBefore)
+			}
+			if !strings.Contains(tx.execs[1].sql, "refunded_amount = total_amount") {
+				t.Errorf("refunded amount not recorded: %s", tx.execs[1].sql)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+)
+
+// Templates live in templates/<language>/<name>.tmpl and define three blocks:
+// "subject", "content" (HTML, wrapped in templates/layout.tmpl) and "text"
+// (plain text for WhatsApp and Telegram).
+//
+//go:embed templates
+var templateFS embed.FS
+
+// DefaultTemplateLanguage is used when a template doesn't exist in the requested language
+const DefaultTemplateLanguage = "id"
+
+// RenderedTemplate holds the rendered parts of a template
+type RenderedTemplate struct {
+	Subject string
+	HTML    string
+	Text    string
+}
+
+// TemplateRenderer renders localized templates from a filesystem
+type TemplateRenderer struct {
+	html map[string]*htmltemplate.Template
+	text map[string]*texttemplate.Template
+}
+
+var (
+	defaultRenderer     *TemplateRenderer
+	defaultRendererErr  error
+	defaultRendererOnce sync.Once
+)
+
+// NewTemplateRenderer parses every template in the given filesystem.
+// Pass nil to use the embedded templates.
+func NewTemplateRenderer(fsys fs.FS) (*TemplateRenderer, error) {
+	if fsys == nil {
+		sub, err := fs.Sub(templateFS, "templates")
+		if err != nil {
+			return nil, err
+		}
+		fsys = sub
+	}
+
+	layout, err := fs.ReadFile(fsys, "layout.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read layout template: %w", err)
+	}
+
+	r := &TemplateRenderer{
+		html: make(map[string]*htmltemplate.Template),
+		text: make(map[string]*texttemplate.Template),
+	}
+
+	files, err := fs.Glob(fsys, "*/*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		key := strings.TrimSuffix(file, ".tmpl")
+
+		htmlTmpl, err := htmltemplate.New("layout").Parse(string(layout))
+		if err == nil {
+			_, err = htmlTmpl.Parse(string(content))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template %s: %w", file, err)
+		}
+
+		textTmpl, err := texttemplate.New(key).Parse(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template %s: %w", file, err)
+		}
+
+		r.html[key] = htmlTmpl
+		r.text[key] = textTmpl
+	}
+
+	return r, nil
+}
+
+// Render renders a template in the given language, falling back to the default language
+func (r *TemplateRenderer) Render(language, name string, data interface{}) (*RenderedTemplate, error) {
+	key := strings.ToLower(language) + "/" + name
+	if _, ok := r.html[key]; !ok {
+		key = DefaultTemplateLanguage + "/" + name
+	}
+
+	htmlTmpl, ok := r.html[key]
+	if !ok {
+		return nil, fmt.Errorf("template not found: %s", name)
+	}
+	textTmpl := r.text[key]
+
+	var subject, html, text bytes.Buffer
+	if err := textTmpl.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, err
+	}
+	if err := htmlTmpl.ExecuteTemplate(&html, "layout", data); err != nil {
+		return nil, err
+	}
+	if textTmpl.Lookup("text") != nil {
+		if err := textTmpl.ExecuteTemplate(&text, "text", data); err != nil {
+			return nil, err
+		}
+	}
+
+	return &RenderedTemplate{
+		Subject: strings.TrimSpace(subject.String()),
+		HTML:    html.String(),
+		Text:    strings.TrimSpace(text.String()),
+	}, nil
+}
+
+// RenderTemplate renders the HTML body of an embedded template in the default language
+func RenderTemplate(templateName string, data interface{}) (string, error) {
+	defaultRendererOnce.Do(func() {
+		defaultRenderer, defaultRendererErr = NewTemplateRenderer(nil)
+	})
+	if defaultRendererErr != nil {
+		return "", defaultRendererErr
+	}
+
+	rendered, err := defaultRenderer.Render(DefaultTemplateLanguage, templateName, data)
+	if err != nil {
+		return "", err
+	}
+	return rendered.HTML, nil
+}
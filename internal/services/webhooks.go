@@ -0,0 +1,588 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"seaply/internal/utils"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// Webhook event types
+const (
+	WebhookEventTransactionStatus = "transaction.status"
+	WebhookEventDepositSuccess    = "deposit.success"
+	WebhookEventRefund            = "refund"
+	WebhookEventPartnerOrder      = "order.status" // H2H callback, sent to the partner key only
+)
+
+// WebhookEventTypes lists the events a subscriber can subscribe to
+var WebhookEventTypes = []string{WebhookEventTransactionStatus, WebhookEventDepositSuccess, WebhookEventRefund}
+
+// Webhook delivery statuses
+const (
+	WebhookStatusPending   = "PENDING"
+	WebhookStatusSending   = "SENDING"
+	WebhookStatusDelivered = "DELIVERED"
+	WebhookStatusDead      = "DEAD"
+)
+
+// Headers of a webhook request
+const (
+	WebhookEventHeader    = "X-Webhook-Event"
+	WebhookDeliveryHeader = "X-Webhook-Delivery"
+)
+
+// maxWebhookResponseLog caps the response body kept in the delivery log
+const maxWebhookResponseLog = 2048
+
+// WebhookSignature signs a webhook body: hex(HMAC-SHA256(secret, timestamp + "." + body))
+func WebhookSignature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookEvent is an event emitted to webhook subscribers
+type WebhookEvent struct {
+	Type          string
+	ReferenceType string
+	ReferenceID   string
+	UserID        string // owner of the record, subscribers scoped to another account don't receive it
+	DedupeKey     string // an event with the same key is only emitted once
+	Data          interface{}
+}
+
+// WebhookConfig configures the webhook worker
+type WebhookConfig struct {
+	BatchSize      int
+	PollInterval   time.Duration
+	MaxAttempts    int
+	BaseBackoff    time.Duration
+	MaxBackoff     time.Duration
+	LockTimeout    time.Duration
+	RequestTimeout time.Duration
+}
+
+// WebhookDispatcher stores webhook events with one delivery per subscriber and
+// delivers them in the background. Failed deliveries are retried with exponential
+// backoff; once out of attempts they are DEAD until an admin resends them.
+type WebhookDispatcher struct {
+	credentialCipher
+
+	pool   *pgxpool.Pool
+	client *http.Client
+	cfg    WebhookConfig
+}
+
+// NewWebhookDispatcher creates a webhook dispatcher
+func NewWebhookDispatcher(pool *pgxpool.Pool, credentialKey string, cfg WebhookConfig) *WebhookDispatcher {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 20
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 5 * time.Second
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 8
+	}
+	if cfg.BaseBackoff <= 0 {
+		cfg.BaseBackoff = 30 * time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = 6 * time.Hour
+	}
+	if cfg.LockTimeout <= 0 {
+		cfg.LockTimeout = 10 * time.Minute
+	}
+	if cfg.RequestTimeout <= 0 {
+		cfg.RequestTimeout = 15 * time.Second
+	}
+
+	return &WebhookDispatcher{
+		credentialCipher: credentialCipher{credentialKey: credentialKey},
+		pool:             pool,
+		client:           utils.NewOutboundHTTPClient(cfg.RequestTimeout),
+		cfg:              cfg,
+	}
+}
+
+// GenerateSecret creates a signing secret for a subscriber. The secret is returned
+// in plain text to be shown once; the returned hint identifies it later.
+func (d *WebhookDispatcher) GenerateSecret() (secret, encryptedSecret, hint string, err error) {
+	random, err := utils.GenerateRandomString(48)
+	if err != nil {
+		return "", "", "", err
+	}
+	secret = "whsec_" + random
+	encryptedSecret, err = d.EncryptSecret(secret)
+	if err != nil {
+		return "", "", "", fmt.Errorf("encrypt secret: %w", err)
+	}
+	return secret, encryptedSecret, secret[len(secret)-4:], nil
+}
+
+// Emit stores an event with a delivery for every active subscriber of its type.
+// It returns an empty ID when the event was already emitted.
+func (d *WebhookDispatcher) Emit(ctx context.Context, event WebhookEvent) (string, error) {
+	tx, err := d.pool.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	eventID, err := d.insertEvent(ctx, tx, event, func(id string, createdAt time.Time) interface{} {
+		return map[string]interface{}{
+			"id":        id,
+			"type":      event.Type,
+			"createdAt": createdAt.Format(time.RFC3339),
+			"data":      event.Data,
+		}
+	})
+	if err != nil || eventID == "" {
+		return "", err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO webhook_deliveries (event_id, subscriber_id, url, max_attempts)
+		SELECT $1, s.id, s.url, $2
+		FROM webhook_subscribers s
+		WHERE s.is_active = true
+		  AND (cardinality(s.events) = 0 OR $3 = ANY(s.events))
+		  AND (s.user_id IS NULL OR s.user_id::text = $4)
+	`, eventID, d.cfg.MaxAttempts, event.Type, event.UserID)
+	if err != nil {
+		return "", fmt.Errorf("queue deliveries: %w", err)
+	}
+
+	return eventID, tx.Commit(ctx)
+}
+
+// EmitToPartner stores an event delivered only to the callback URL of a partner
+// API key, signed with the key's secret. The data is posted as is.
+func (d *WebhookDispatcher) EmitToPartner(ctx context.Context, event WebhookEvent, partnerKeyID, url string) (string, error) {
+	tx, err := d.pool.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	eventID, err := d.insertEvent(ctx, tx, event, func(string, time.Time) interface{} {
+		return event.Data
+	})
+	if err != nil || eventID == "" {
+		return "", err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO webhook_deliveries (event_id, partner_key_id, url, max_attempts)
+		VALUES ($1, $2, $3, $4)
+	`, eventID, partnerKeyID, url, d.cfg.MaxAttempts)
+	if err != nil {
+		return "", fmt.Errorf("queue delivery: %w", err)
+	}
+
+	return eventID, tx.Commit(ctx)
+}
+
+// insertEvent stores an event with the body built by payload, or returns an empty
+// ID when its dedupe key was already used
+func (d *WebhookDispatcher) insertEvent(ctx context.Context, tx pgx.Tx, event WebhookEvent, payload func(id string, createdAt time.Time) interface{}) (string, error) {
+	var userID interface{}
+	if event.UserID != "" {
+		userID = event.UserID
+	}
+
+	var eventID string
+	var createdAt time.Time
+	err := tx.QueryRow(ctx, `
+		INSERT INTO webhook_events (event_type, reference_type, reference_id, user_id, payload, dedupe_key)
+		VALUES ($1, $2, $3, $4, '{}'::jsonb, $5)
+		ON CONFLICT (dedupe_key) DO NOTHING
+		RETURNING id, created_at
+	`, event.Type, event.ReferenceType, event.ReferenceID, userID, event.DedupeKey).Scan(&eventID, &createdAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("insert event: %w", err)
+	}
+
+	body, err := json.Marshal(payload(eventID, createdAt))
+	if err != nil {
+		return "", fmt.Errorf("encode event: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `UPDATE webhook_events SET payload = $1 WHERE id = $2`, body, eventID); err != nil {
+		return "", fmt.Errorf("store event payload: %w", err)
+	}
+	return eventID, nil
+}
+
+// EmitInvoice emits the events for the current state of a transaction or deposit invoice
+func (d *WebhookDispatcher) EmitInvoice(ctx context.Context, invoiceNumber string) error {
+	if IsDepositInvoice(invoiceNumber) {
+		return d.emitDeposit(ctx, `d.invoice_number = $1`, invoiceNumber)
+	}
+	return d.emitTransaction(ctx, `t.invoice_number = $1`, invoiceNumber)
+}
+
+// EmitTransaction emits the events for the current state of a transaction
+func (d *WebhookDispatcher) EmitTransaction(ctx context.Context, transactionID string) error {
+	return d.emitTransaction(ctx, `t.id = $1`, transactionID)
+}
+
+// EmitDeposit emits the events for the current state of a deposit
+func (d *WebhookDispatcher) EmitDeposit(ctx context.Context, depositID string) error {
+	return d.emitDeposit(ctx, `d.id = $1`, depositID)
+}
+
+func (d *WebhookDispatcher) emitTransaction(ctx context.Context, where string, arg string) error {
+	var id, invoiceNumber, productCode, skuCode, status, paymentStatus, currency string
+	var userID, serialNumber, partnerRefID *string
+	var quantity int
+	var totalAmount, refundedAmount int64
+	var updatedAt time.Time
+	err := d.pool.QueryRow(ctx, `
+		SELECT t.id, t.invoice_number, t.user_id::text, p.code, s.code, t.quantity,
+		       t.status::text, t.payment_status::text, t.provider_serial_number, t.partner_ref_id,
+		       t.total_amount, t.refunded_amount, t.currency::text, COALESCE(t.updated_at, t.created_at)
+		FROM transactions t
+		JOIN products p ON p.id = t.product_id
+		JOIN skus s ON s.id = t.sku_id
+		WHERE `+where, arg).Scan(&id, &invoiceNumber, &userID, &productCode, &skuCode, &quantity,
+		&status, &paymentStatus, &serialNumber, &partnerRefID,
+		&totalAmount, &refundedAmount, &currency, &updatedAt)
+	if err != nil {
+		return fmt.Errorf("load transaction: %w", err)
+	}
+
+	owner := ""
+	if userID != nil {
+		owner = *userID
+	}
+
+	_, err = d.Emit(ctx, WebhookEvent{
+		Type:          WebhookEventTransactionStatus,
+		ReferenceType: "TRANSACTION",
+		ReferenceID:   id,
+		UserID:        owner,
+		DedupeKey:     fmt.Sprintf("%s:%s:%s:%s", WebhookEventTransactionStatus, id, status, paymentStatus),
+		Data: map[string]interface{}{
+			"invoiceNumber": invoiceNumber,
+			"refId":         partnerRefID,
+			"productCode":   productCode,
+			"skuCode":       skuCode,
+			"quantity":      quantity,
+			"status":        status,
+			"paymentStatus": paymentStatus,
+			"serialNumber":  serialNumber,
+			"totalAmount":   totalAmount,
+			"currency":      currency,
+			"updatedAt":     updatedAt.Format(time.RFC3339),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Partial refunds of failed units leave the transaction SUCCESS
+	refund := refundedAmount
+	if status == "REFUNDED" && refund == 0 {
+		refund = totalAmount
+	}
+	if refund <= 0 {
+		return nil
+	}
+	_, err = d.Emit(ctx, WebhookEvent{
+		Type:          WebhookEventRefund,
+		ReferenceType: "TRANSACTION",
+		ReferenceID:   id,
+		UserID:        owner,
+		DedupeKey:     fmt.Sprintf("%s:transaction:%s:%d", WebhookEventRefund, id, refund),
+		Data: map[string]interface{}{
+			"referenceType": "TRANSACTION",
+			"invoiceNumber": invoiceNumber,
+			"refId":         partnerRefID,
+			"status":        status,
+			"amount":        refund,
+			"currency":      currency,
+		},
+	})
+	return err
+}
+
+func (d *WebhookDispatcher) emitDeposit(ctx context.Context, where string, arg string) error {
+	var id, invoiceNumber, userID, status, currency string
+	var amount int64
+	var paidAt *time.Time
+	err := d.pool.QueryRow(ctx, `
+		SELECT d.id, d.invoice_number, d.user_id::text, d.status::text, d.amount, d.currency::text, d.paid_at
+		FROM deposits d
+		WHERE `+where, arg).Scan(&id, &invoiceNumber, &userID, &status, &amount, &currency, &paidAt)
+	if err != nil {
+		return fmt.Errorf("load deposit: %w", err)
+	}
+
+	data := map[string]interface{}{
+		"invoiceNumber": invoiceNumber,
+		"status":        status,
+		"amount":        amount,
+		"currency":      currency,
+		"paidAt":        paidAt,
+	}
+
+	switch status {
+	case "SUCCESS":
+		_, err = d.Emit(ctx, WebhookEvent{
+			Type:          WebhookEventDepositSuccess,
+			ReferenceType: "DEPOSIT",
+			ReferenceID:   id,
+			UserID:        userID,
+			DedupeKey:     WebhookEventDepositSuccess + ":" + id,
+			Data:          data,
+		})
+	case "REFUNDED":
+		data["referenceType"] = "DEPOSIT"
+		_, err = d.Emit(ctx, WebhookEvent{
+			Type:          WebhookEventRefund,
+			ReferenceType: "DEPOSIT",
+			ReferenceID:   id,
+			UserID:        userID,
+			DedupeKey:     WebhookEventRefund + ":deposit:" + id,
+			Data:          data,
+		})
+	}
+	return err
+}
+
+// Resend queues a delivery again, also after it was delivered or went DEAD
+func (d *WebhookDispatcher) Resend(ctx context.Context, deliveryID, adminID string) error {
+	var resentBy interface{}
+	if adminID != "" {
+		resentBy = adminID
+	}
+	tag, err := d.pool.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = 'PENDING', next_attempt_at = NOW(), locked_at = NULL,
+		    max_attempts = GREATEST(max_attempts, attempts + 1), resent_by = $2
+		WHERE id::text = $1 AND status <> 'SENDING'
+	`, deliveryID, resentBy)
+	if err != nil {
+		return fmt.Errorf("failed to resend webhook: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// Start starts the delivery worker
+func (d *WebhookDispatcher) Start(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				ticker.Stop()
+				return
+			case <-ticker.C:
+				d.releaseStaleLocks(ctx)
+				for {
+					processed, err := d.ProcessBatch(ctx)
+					if err != nil {
+						log.Error().Err(err).Msg("Failed to process webhook deliveries")
+						break
+					}
+					if processed < d.cfg.BatchSize {
+						break
+					}
+				}
+			}
+		}
+	}()
+}
+
+type claimedWebhook struct {
+	id        string
+	eventType string
+	url       string
+	payload   []byte
+	secret    string
+	attempts  int
+	maxTries  int
+}
+
+// ProcessBatch claims and delivers one batch of due deliveries, returning how many were claimed
+func (d *WebhookDispatcher) ProcessBatch(ctx context.Context) (int, error) {
+	rows, err := d.pool.Query(ctx, `
+		WITH claimed AS (
+			UPDATE webhook_deliveries
+			SET status = 'SENDING', locked_at = NOW(), attempts = attempts + 1
+			WHERE id IN (
+				SELECT id FROM webhook_deliveries
+				WHERE status = 'PENDING' AND next_attempt_at <= NOW()
+				ORDER BY next_attempt_at
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, event_id, subscriber_id, partner_key_id, url, attempts, max_attempts
+		)
+		SELECT c.id, e.event_type, c.url, e.payload, COALESCE(s.secret, k.secret, ''), c.attempts, c.max_attempts
+		FROM claimed c
+		JOIN webhook_events e ON e.id = c.event_id
+		LEFT JOIN webhook_subscribers s ON s.id = c.subscriber_id
+		LEFT JOIN partner_api_keys k ON k.id = c.partner_key_id
+	`, d.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var claimed []claimedWebhook
+	for rows.Next() {
+		var c claimedWebhook
+		if err := rows.Scan(&c.id, &c.eventType, &c.url, &c.payload, &c.secret, &c.attempts, &c.maxTries); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		claimed = append(claimed, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, c := range claimed {
+		d.deliver(ctx, c)
+	}
+
+	return len(claimed), nil
+}
+
+func (d *WebhookDispatcher) deliver(ctx context.Context, c claimedWebhook) {
+	start := time.Now()
+	statusCode, responseBody, err := d.post(ctx, c)
+	duration := time.Since(start)
+
+	var errorText *string
+	if err != nil {
+		text := err.Error()
+		errorText = &text
+	}
+	var code *int
+	if statusCode > 0 {
+		code = &statusCode
+	}
+
+	_, dbErr := d.pool.Exec(ctx, `
+		INSERT INTO webhook_delivery_attempts (delivery_id, attempt, url, status_code, response_body, error, duration_ms)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, c.id, c.attempts, c.url, code, responseBody, errorText, duration.Milliseconds())
+	if dbErr != nil {
+		log.Warn().Err(dbErr).Str("delivery_id", c.id).Msg("Failed to log webhook attempt")
+	}
+
+	if err == nil {
+		_, dbErr := d.pool.Exec(ctx, `
+			UPDATE webhook_deliveries
+			SET status = 'DELIVERED', delivered_at = NOW(), locked_at = NULL,
+			    last_error = NULL, last_status_code = $1
+			WHERE id = $2
+		`, code, c.id)
+		if dbErr != nil {
+			log.Error().Err(dbErr).Str("delivery_id", c.id).Msg("Failed to mark webhook as delivered")
+		}
+		return
+	}
+
+	status := WebhookStatusPending
+	if c.attempts >= c.maxTries {
+		status = WebhookStatusDead
+	}
+
+	log.Warn().Err(err).
+		Str("delivery_id", c.id).
+		Str("event", c.eventType).
+		Str("url", c.url).
+		Int("attempt", c.attempts).
+		Str("status", status).
+		Msg("Webhook delivery failed")
+
+	_, dbErr = d.pool.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $1, last_error = $2, last_status_code = $3, locked_at = NULL, next_attempt_at = $4
+		WHERE id = $5
+	`, status, err.Error(), code, time.Now().Add(d.backoff(c.attempts)), c.id)
+	if dbErr != nil {
+		log.Error().Err(dbErr).Str("delivery_id", c.id).Msg("Failed to record webhook delivery failure")
+	}
+}
+
+// post sends one signed delivery. Any 2xx response counts as delivered.
+func (d *WebhookDispatcher) post(ctx context.Context, c claimedWebhook) (int, string, error) {
+	secret, err := d.DecryptSecret(c.secret)
+	if err != nil {
+		return 0, "", fmt.Errorf("decrypt secret: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(c.payload))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Seaply-Webhooks/1.0")
+	req.Header.Set(WebhookEventHeader, c.eventType)
+	req.Header.Set(WebhookDeliveryHeader, c.id)
+	req.Header.Set(PartnerTimestampHeader, timestamp)
+	req.Header.Set(PartnerSignatureHeader, WebhookSignature(secret, timestamp, c.payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxWebhookResponseLog))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, string(body), fmt.Errorf("subscriber returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, string(body), nil
+}
+
+// backoff returns the delay before the next attempt: base * 2^(attempts-1), capped at MaxBackoff
+func (d *WebhookDispatcher) backoff(attempts int) time.Duration {
+	delay := d.cfg.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= d.cfg.MaxBackoff {
+			return d.cfg.MaxBackoff
+		}
+	}
+	return delay
+}
+
+// releaseStaleLocks puts deliveries claimed by a worker that died back in the queue
+func (d *WebhookDispatcher) releaseStaleLocks(ctx context.Context) {
+	_, err := d.pool.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = 'PENDING', locked_at = NULL
+		WHERE status = 'SENDING' AND locked_at < $1
+	`, time.Now().Add(-d.cfg.LockTimeout))
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to release stale webhook locks")
+	}
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"seaply/internal/utils"
+)
+
+func TestWebhookSignature(t *testing.T) {
+	body := []byte(`{"event":"transaction.success"}`)
+	mac := hmac.New(sha256.New, []byte("whsec"))
+	mac.Write([]byte("1700000000." + string(body)))
+	want := hex.EncodeToString(mac.Sum(nil))
+
+	if got := WebhookSignature("whsec", "1700000000", body); got != want {
+		t.Fatalf("WebhookSignature() = %s, want %s", got, want)
+	}
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		body      []byte
+	}{
+		{"other secret", "other", "1700000000", body},
+		{"other timestamp", "whsec", "1700000001", body},
+		{"other body", "whsec", "1700000000", []byte(`{"event":"transaction.failed"}`)},
+		{"timestamp moved into body", "whsec", "170000000", []byte("0." + string(body))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if WebhookSignature(tt.secret, tt.timestamp, tt.body) == want {
+				t.Error("signature did not change")
+			}
+		})
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	d := NewWebhookDispatcher(nil, "key", WebhookConfig{BaseBackoff: time.Minute, MaxBackoff: 10 * time.Minute})
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{4, 8 * time.Minute},
+		{5, 10 * time.Minute},
+		{30, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := d.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestWebhookPostRefusesInternalAddresses(t *testing.T) {
+	called := false
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	d := NewWebhookDispatcher(nil, "key", WebhookConfig{})
+	secret, err := d.EncryptSecret("whsec")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = d.post(context.Background(), claimedWebhook{
+		id:        "delivery-1",
+		eventType: WebhookEventPartnerOrder,
+		url:       server.URL,
+		payload:   []byte(`{}`),
+		secret:    secret,
+	})
+	if !errors.Is(err, utils.ErrOutboundHostDenied) {
+		t.Errorf("post() to %s = %v, want %v", server.URL, err, utils.ErrOutboundHostDenied)
+	}
+	if called {
+		t.Error("request reached the loopback server")
+	}
+}
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -46,6 +47,7 @@ const (
 	FolderExport   FolderType = "exports"
 	FolderCategory FolderType = "categories"
 	FolderSection  FolderType = "sections"
+	FolderInvoice  FolderType = "invoices"
 )
 
 func NewS3Storage(cfg config.S3Config) (*S3Storage, error) {
@@ -238,6 +240,35 @@ func (s *S3Storage) UploadBytes(ctx context.Context, folder FolderType, data []b
 	}, nil
 }
 
+// PutPrivate stores bytes under a fixed key without public access
+func (s *S3Storage) PutPrivate(ctx context.Context, key string, data []byte, contentType string) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(contentType),
+		ACL:         "private",
+	})
+	if err != nil {
+		return fmt.Errorf("upload to S3: %w", err)
+	}
+	return nil
+}
+
+// Get downloads the content of a key
+func (s *S3Storage) Get(ctx context.Context, key string) ([]byte, error) {
+	output, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("download from S3: %w", err)
+	}
+	defer output.Body.Close()
+
+	return io.ReadAll(output.Body)
+}
+
 // Delete removes a file from S3
 func (s *S3Storage) Delete(ctx context.Context, key string) error {
 	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// encryptedPrefix marks values produced by EncryptSecret
+const encryptedPrefix = "enc:v1:"
+
+// ErrMissingCredentialKey is returned when secrets are encrypted or decrypted without a key
+var ErrMissingCredentialKey = errors.New("credential encryption key is not configured")
+
+// credentialCipher derives an AES-256-GCM cipher from the configured key
+func credentialCipher(key string) (cipher.AEAD, error) {
+	if key == "" {
+		return nil, ErrMissingCredentialKey
+	}
+	sum := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, fmt.Errorf("create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptSecret encrypts a secret for storage in the database
+func EncryptSecret(key, plaintext string) (string, error) {
+	gcm, err := credentialCipher(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("generate nonce: %w", err)
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptSecret decrypts a secret produced by EncryptSecret
+func DecryptSecret(key, ciphertext string) (string, error) {
+	if !strings.HasPrefix(ciphertext, encryptedPrefix) {
+		return "", errors.New("value is not an encrypted secret")
+	}
+	gcm, err := credentialCipher(key)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(ciphertext, encryptedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("decode secret: %w", err)
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("encrypted secret is too short")
+	}
+	nonce, sealed := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt secret: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// MaskSecret hides all but the last four characters of a secret
+func MaskSecret(secret string) string {
+	if len(secret) <= 4 {
+		return strings.Repeat("*", len(secret))
+	}
+	return strings.Repeat("*", len(secret)-4) + secret[len(secret)-4:]
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEncryptSecretRoundTrip(t *testing.T) {
+	for _, plaintext := range []string{"", "sk_live_123", `{"apiKey":"k","secret":"s"}`, "ünïcödé ฿ ₱", strings.Repeat("x", 4096)} {
+		encrypted, err := EncryptSecret("credential-key", plaintext)
+		if err != nil {
+			t.Fatalf("EncryptSecret: %v", err)
+		}
+		if !strings.HasPrefix(encrypted, encryptedPrefix) {
+			t.Errorf("encrypted value %q has no %q prefix", encrypted, encryptedPrefix)
+		}
+		if plaintext != "" && strings.Contains(encrypted, plaintext) {
+			t.Errorf("encrypted value contains the plaintext")
+		}
+		decrypted, err := DecryptSecret("credential-key", encrypted)
+		if err != nil {
+			t.Fatalf("DecryptSecret: %v", err)
+		}
+		if decrypted != plaintext {
+			t.Errorf("decrypted %q, want %q", decrypted, plaintext)
+		}
+	}
+}
+
+func TestEncryptSecretUsesRandomNonce(t *testing.T) {
+	first, _ := EncryptSecret("credential-key", "secret")
+	second, _ := EncryptSecret("credential-key", "secret")
+	if first == second {
+		t.Error("the same secret encrypted twice gave the same value")
+	}
+}
+
+func TestDecryptSecretRejectsInvalidValues(t *testing.T) {
+	valid, err := EncryptSecret("credential-key", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(valid, encryptedPrefix))
+	sealed[len(sealed)-1] ^= 1
+	tampered := encryptedPrefix + base64.StdEncoding.EncodeToString(sealed)
+
+	tests := []struct {
+		name       string
+		key        string
+		ciphertext string
+		want       error
+	}{
+		{"plain text", "credential-key", "secret", nil},
+		{"wrong key", "other-key", valid, nil},
+		{"no key", "", valid, ErrMissingCredentialKey},
+		{"tampered", "credential-key", tampered, nil},
+		{"not base64", "credential-key", encryptedPrefix + "!!!", nil},
+		{"too short", "credential-key", encryptedPrefix + base64.StdEncoding.EncodeToString([]byte("abc")), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecryptSecret(tt.key, tt.ciphertext)
+			if err == nil {
+				t.Fatalf("DecryptSecret() = %q, want an error", got)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("DecryptSecret() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncryptSecretWithoutKey(t *testing.T) {
+	if _, err := EncryptSecret("", "secret"); !errors.Is(err, ErrMissingCredentialKey) {
+		t.Errorf("EncryptSecret() error = %v, want %v", err, ErrMissingCredentialKey)
+	}
+}
+
+func TestMaskSecret(t *testing.T) {
+	tests := []struct {
+		secret string
+		want   string
+	}{
+		{"", ""},
+		{"abc", "***"},
+		{"abcd", "****"},
+		{"sk_live_1234", "********1234"},
+	}
+	for _, tt := range tests {
+		if got := MaskSecret(tt.secret); got != tt.want {
+			t.Errorf("MaskSecret(%q) = %q, want %q", tt.secret, got, tt.want)
+		}
+	}
+}
@@ -14,6 +14,7 @@ type JWTService interface {
 	GenerateRefreshToken(subject string, tokenType string) (string, error)
 	GenerateMFAToken(subject string, tokenType string) (string, error)
 	GenerateValidationToken(data map[string]interface{}) (string, error)
+	GenerateImpersonationToken(userID, email, adminID string) (string, time.Time, error)
 	ValidateAccessToken(tokenString string) (*TokenClaims, error)
 	ValidateRefreshToken(tokenString string) (*jwt.RegisteredClaims, error)
 	ValidateMFAToken(tokenString string) (*jwt.RegisteredClaims, error)
@@ -27,6 +28,8 @@ type TokenClaims struct {
 	Email       string   `json:"email,omitempty"`
 	Role        string   `json:"role,omitempty"`
 	Permissions []string `json:"permissions,omitempty"`
+	// ImpersonatedBy is the admin viewing as the user, such tokens are read-only
+	ImpersonatedBy string `json:"impersonatedBy,omitempty"`
 }
 
 // Subject returns the subject (user/admin ID) from the token
@@ -38,6 +41,11 @@ func (t *TokenClaims) Subject() string {
 	return sub
 }
 
+// IsImpersonation reports whether an admin issued the token to view as the user
+func (t *TokenClaims) IsImpersonation() bool {
+	return t.ImpersonatedBy != ""
+}
+
 type ValidationTokenClaims struct {
 	jwt.RegisteredClaims
 	Data map[string]interface{} `json:"data"`
@@ -104,6 +112,31 @@ func (s *jwtService) GenerateValidationToken(data map[string]interface{}) (strin
 	return token.SignedString([]byte(s.cfg.SecretKey))
 }
 
+// GenerateImpersonationToken generates a short-lived user token on behalf of an admin,
+// without a refresh token
+func (s *jwtService) GenerateImpersonationToken(userID, email, adminID string) (string, time.Time, error) {
+	expiresAt := time.Now().Add(s.cfg.ImpersonationTokenExpiry)
+	claims := TokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID,
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			Issuer:    "seaply.co",
+		},
+		UserID:         userID,
+		Type:           "user",
+		Email:          email,
+		ImpersonatedBy: adminID,
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString([]byte(s.cfg.SecretKey))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
+}
+
 func (s *jwtService) ValidateAccessToken(tokenString string) (*TokenClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &TokenClaims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
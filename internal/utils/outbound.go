@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Errors returned while validating an outbound URL
+var (
+	ErrOutboundURLInvalid = errors.New("URL must be an absolute https URL")
+	ErrOutboundHostDenied = errors.New("URL host resolves to a private or reserved address")
+)
+
+// Ranges IsPublicIP rejects on top of the loopback, private, link-local,
+// multicast and unspecified checks of the standard library
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// IsPublicIP reports whether ip is a globally routable address a server may
+// call out to. Loopback, private, link-local (including cloud metadata),
+// multicast, unspecified and reserved addresses are rejected.
+func IsPublicIP(ip net.IP) bool {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return false
+	}
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateOutboundURL checks that rawURL is an https URL whose host resolves
+// only to public addresses, so callbacks and webhooks can't be aimed at
+// internal services. The address is checked again when the request is dialed,
+// as DNS can change between the two.
+func ValidateOutboundURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" || u.User != nil {
+		return ErrOutboundURLInvalid
+	}
+
+	host := u.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if !IsPublicIP(ip) {
+			return ErrOutboundHostDenied
+		}
+		return nil
+	}
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return ErrOutboundHostDenied
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return ErrOutboundURLInvalid
+	}
+	for _, addr := range addrs {
+		if !IsPublicIP(addr.IP) {
+			return ErrOutboundHostDenied
+		}
+	}
+	return nil
+}
+
+// OutboundDialControl is a net.Dialer Control hook that refuses connections to
+// addresses IsPublicIP rejects. It runs on the resolved address of every dial,
+// including redirects, so a host that re-resolves to an internal address after
+// ValidateOutboundURL (DNS rebinding) is still blocked.
+func OutboundDialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !IsPublicIP(ip) {
+		return ErrOutboundHostDenied
+	}
+	return nil
+}
+
+// NewOutboundHTTPClient returns an HTTP client for URLs supplied by users,
+// such as webhook and callback URLs, that can only connect to public addresses.
+// Environment proxies are not used, the client always dials the target itself.
+func NewOutboundHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   OutboundDialControl,
+	}
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
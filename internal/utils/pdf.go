@@ -0,0 +1,246 @@
+package utils
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/png" // QR codes are PNG encoded
+	"strings"
+)
+
+// A4 page size in points
+const (
+	PDFPageWidth  = 595.28
+	PDFPageHeight = 841.89
+)
+
+// PDFDocument builds simple PDF documents with text, lines, rectangles and images.
+// Text is drawn with embedded DejaVu Sans subsets, characters it lacks come from the
+// fonts added with RegisterPDFFont. Coordinates start at the top left of the page.
+type PDFDocument struct {
+	pages     []*bytes.Buffer
+	images    [][]byte // encoded image XObjects
+	fonts     []*pdfDocFont
+	fontIndex map[*pdfFont]int
+}
+
+// NewPDFDocument creates an empty document
+func NewPDFDocument() *PDFDocument {
+	return &PDFDocument{fontIndex: make(map[*pdfFont]int)}
+}
+
+// AddPage starts a new A4 page; drawing calls go to the last page
+func (d *PDFDocument) AddPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+}
+
+func (d *PDFDocument) page() *bytes.Buffer {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+	return d.pages[len(d.pages)-1]
+}
+
+// Text draws text with its baseline at y
+func (d *PDFDocument) Text(x, y, size float64, bold bool, text string) {
+	runs := pdfShape(text, bold)
+	if len(runs) == 0 {
+		return
+	}
+
+	page := d.page()
+	fmt.Fprintf(page, "BT %.2f %.2f Td", x, PDFPageHeight-y)
+	for _, run := range runs {
+		fmt.Fprintf(page, " /F%d %.2f Tf <", d.useFont(run), size)
+		for _, gid := range run.glyphs {
+			fmt.Fprintf(page, "%04X", gid)
+		}
+		page.WriteString("> Tj")
+	}
+	page.WriteString(" ET\n")
+}
+
+// useFont records the glyphs of a run and returns the resource number of its font
+func (d *PDFDocument) useFont(run pdfGlyphRun) int {
+	if d.fontIndex == nil {
+		d.fontIndex = make(map[*pdfFont]int)
+	}
+	index, ok := d.fontIndex[run.font]
+	if !ok {
+		d.fonts = append(d.fonts, &pdfDocFont{font: run.font, used: make(map[uint16]rune)})
+		index = len(d.fonts)
+		d.fontIndex[run.font] = index
+	}
+	used := d.fonts[index-1].used
+	for i, gid := range run.glyphs {
+		if _, ok := used[gid]; !ok {
+			used[gid] = run.runes[i]
+		}
+	}
+	return index
+}
+
+// TextRight draws text ending at x
+func (d *PDFDocument) TextRight(x, y, size float64, bold bool, text string) {
+	d.Text(x-PDFTextWidth(text, size, bold), y, size, bold, text)
+}
+
+// SetColor sets the fill color used by text and rectangles, components from 0 to 1
+func (d *PDFDocument) SetColor(r, g, b float64) {
+	fmt.Fprintf(d.page(), "%.3f %.3f %.3f rg\n", r, g, b)
+}
+
+// Line draws a line of the given width in the given gray level
+func (d *PDFDocument) Line(x1, y1, x2, y2, width, gray float64) {
+	fmt.Fprintf(d.page(), "q %.3f G %.2f w %.2f %.2f m %.2f %.2f l S Q\n",
+		gray, width, x1, PDFPageHeight-y1, x2, PDFPageHeight-y2)
+}
+
+// Rect fills a rectangle with the current color
+func (d *PDFDocument) Rect(x, y, w, h float64) {
+	fmt.Fprintf(d.page(), "%.2f %.2f %.2f %.2f re f\n", x, PDFPageHeight-y-h, w, h)
+}
+
+// Image draws a PNG or other registered image format scaled to w x h
+func (d *PDFDocument) Image(data []byte, x, y, w, h float64) error {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("decode image: %w", err)
+	}
+
+	bounds := img.Bounds()
+	pixels := make([]byte, 0, bounds.Dx()*bounds.Dy()*3)
+	for py := bounds.Min.Y; py < bounds.Max.Y; py++ {
+		for px := bounds.Min.X; px < bounds.Max.X; px++ {
+			c := color.RGBAModel.Convert(img.At(px, py)).(color.RGBA)
+			pixels = append(pixels, c.R, c.G, c.B)
+		}
+	}
+	compressed, err := pdfDeflate(pixels)
+	if err != nil {
+		return err
+	}
+
+	var obj bytes.Buffer
+	fmt.Fprintf(&obj, "<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode /Length %d >>\nstream\n",
+		bounds.Dx(), bounds.Dy(), len(compressed))
+	obj.Write(compressed)
+	obj.WriteString("\nendstream")
+	d.images = append(d.images, obj.Bytes())
+
+	fmt.Fprintf(d.page(), "q %.2f 0 0 %.2f %.2f %.2f cm /Im%d Do Q\n",
+		w, h, x, PDFPageHeight-y-h, len(d.images))
+	return nil
+}
+
+// Bytes serializes the document
+func (d *PDFDocument) Bytes() ([]byte, error) {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+
+	// Object 1 is the catalog and 2 the page tree, written once the pages are numbered
+	objects := [][]byte{[]byte("<< /Type /Catalog /Pages 2 0 R >>"), nil}
+	add := func(obj []byte) int {
+		objects = append(objects, obj)
+		return len(objects)
+	}
+
+	var fonts strings.Builder
+	for i, font := range d.fonts {
+		ref, err := font.objects(i, add)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&fonts, "/F%d %d 0 R ", i+1, ref)
+	}
+
+	var xobjects strings.Builder
+	for i, img := range d.images {
+		fmt.Fprintf(&xobjects, "/Im%d %d 0 R ", i+1, add(img))
+	}
+
+	kids := make([]string, len(d.pages))
+	for i, content := range d.pages {
+		compressed, err := pdfDeflate(content.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		pageRef := len(objects) + 1
+		kids[i] = fmt.Sprintf("%d 0 R", pageRef)
+		add([]byte(fmt.Sprintf(
+			"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << %s>> /XObject << %s>> >> /Contents %d 0 R >>",
+			PDFPageWidth, PDFPageHeight, fonts.String(), xobjects.String(), pageRef+1)))
+
+		var stream bytes.Buffer
+		fmt.Fprintf(&stream, "<< /Filter /FlateDecode /Length %d >>\nstream\n", len(compressed))
+		stream.Write(compressed)
+		stream.WriteString("\nendstream")
+		add(stream.Bytes())
+	}
+	objects[1] = []byte(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n", i+1)
+		out.Write(obj)
+		out.WriteString("\nendobj\n")
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return out.Bytes(), nil
+}
+
+// PDFTextWidth returns the width of text in points
+func PDFTextWidth(text string, size float64, bold bool) float64 {
+	var total int
+	for _, run := range pdfShape(text, bold) {
+		for _, gid := range run.glyphs {
+			total += run.font.width(gid)
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// PDFWrapText splits text into lines no wider than width
+func PDFWrapText(text string, size float64, bold bool, width float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if line != "" && PDFTextWidth(candidate, size, bold) > width {
+				lines = append(lines, line)
+				candidate = word
+			}
+			line = candidate
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func pdfDeflate(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,516 @@
+package utils
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf16"
+)
+
+// DejaVu Sans covers Latin, Greek, Cyrillic and the currency symbols of every region
+var (
+	//go:embed fonts/DejaVuSans.ttf
+	dejaVuSans []byte
+
+	//go:embed fonts/DejaVuSans-Bold.ttf
+	dejaVuSansBold []byte
+)
+
+var (
+	pdfRegularFont = mustParsePDFFont("DejaVuSans", dejaVuSans)
+	pdfBoldFont    = mustParsePDFFont("DejaVuSans-Bold", dejaVuSansBold)
+
+	pdfFallbackMu    sync.RWMutex
+	pdfFallbackFonts []*pdfFont
+)
+
+// RegisterPDFFont adds a TrueType font used for characters the built-in fonts do not
+// cover, such as Thai script. Fonts are tried in the order they were registered.
+func RegisterPDFFont(data []byte) error {
+	pdfFallbackMu.Lock()
+	defer pdfFallbackMu.Unlock()
+
+	font, err := parsePDFFont(fmt.Sprintf("Fallback%d", len(pdfFallbackFonts)+1), data)
+	if err != nil {
+		return err
+	}
+	pdfFallbackFonts = append(pdfFallbackFonts, font)
+	return nil
+}
+
+// pdfFont is a parsed TrueType font, embedded in documents as a subset of the glyphs they use
+type pdfFont struct {
+	name       string
+	tables     map[string][]byte
+	unitsPerEm int
+	advances   []uint16
+	loca       []uint32
+	cmap       map[rune]uint16
+	bbox       [4]int16
+	ascent     int16
+	descent    int16
+	capHeight  int16
+}
+
+// pdfGlyphRun is a piece of text drawn with one font
+type pdfGlyphRun struct {
+	font   *pdfFont
+	glyphs []uint16
+	runes  []rune
+}
+
+func mustParsePDFFont(name string, data []byte) *pdfFont {
+	font, err := parsePDFFont(name, data)
+	if err != nil {
+		panic(fmt.Sprintf("pdf font %s: %v", name, err))
+	}
+	return font
+}
+
+// parsePDFFont reads the tables of a TrueType font needed to measure, map and subset it
+func parsePDFFont(name string, data []byte) (font *pdfFont, err error) {
+	// Offsets come from the font itself, a malformed file must not take the caller down
+	defer func() {
+		if r := recover(); r != nil {
+			font, err = nil, fmt.Errorf("invalid font: %v", r)
+		}
+	}()
+
+	tables, err := readSFNT(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range []string{"head", "hhea", "maxp", "hmtx", "loca", "glyf", "cmap"} {
+		if _, ok := tables[tag]; !ok {
+			return nil, fmt.Errorf("font has no %s table, only TrueType outlines are supported", tag)
+		}
+	}
+
+	head, hhea := tables["head"], tables["hhea"]
+	f := &pdfFont{
+		name:       name,
+		tables:     tables,
+		unitsPerEm: int(binary.BigEndian.Uint16(head[18:])),
+		ascent:     int16(binary.BigEndian.Uint16(hhea[4:])),
+		descent:    int16(binary.BigEndian.Uint16(hhea[6:])),
+	}
+	if f.unitsPerEm == 0 {
+		return nil, errors.New("font has no units per em")
+	}
+	for i := range f.bbox {
+		f.bbox[i] = int16(binary.BigEndian.Uint16(head[36+2*i:]))
+	}
+	f.capHeight = f.ascent
+	if os2 := tables["OS/2"]; len(os2) >= 90 && binary.BigEndian.Uint16(os2) >= 2 {
+		f.capHeight = int16(binary.BigEndian.Uint16(os2[88:]))
+	}
+
+	numGlyphs := int(binary.BigEndian.Uint16(tables["maxp"][4:]))
+	numMetrics := int(binary.BigEndian.Uint16(hhea[34:]))
+	f.advances = make([]uint16, numGlyphs)
+	for gid := range f.advances {
+		if gid < numMetrics {
+			f.advances[gid] = binary.BigEndian.Uint16(tables["hmtx"][4*gid:])
+		} else {
+			f.advances[gid] = f.advances[numMetrics-1]
+		}
+	}
+
+	loca, glyf := tables["loca"], tables["glyf"]
+	longLoca := binary.BigEndian.Uint16(head[50:]) == 1
+	f.loca = make([]uint32, numGlyphs+1)
+	for gid := range f.loca {
+		if longLoca {
+			f.loca[gid] = binary.BigEndian.Uint32(loca[4*gid:])
+		} else {
+			f.loca[gid] = uint32(binary.BigEndian.Uint16(loca[2*gid:])) * 2
+		}
+		if f.loca[gid] > uint32(len(glyf)) || (gid > 0 && f.loca[gid] < f.loca[gid-1]) {
+			return nil, fmt.Errorf("glyph %d is outside the glyf table", gid)
+		}
+	}
+
+	if f.cmap, err = parseCmap(tables["cmap"], numGlyphs); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// readSFNT returns the tables of a font file keyed by tag
+func readSFNT(data []byte) (map[string][]byte, error) {
+	if len(data) < 12 {
+		return nil, errors.New("font is too short")
+	}
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	if len(data) < 12+16*numTables {
+		return nil, errors.New("font table directory is truncated")
+	}
+	tables := make(map[string][]byte, numTables)
+	for i := 0; i < numTables; i++ {
+		rec := data[12+16*i:]
+		offset := binary.BigEndian.Uint32(rec[8:])
+		length := binary.BigEndian.Uint32(rec[12:])
+		if uint64(offset)+uint64(length) > uint64(len(data)) {
+			return nil, fmt.Errorf("font table %q is outside the file", rec[:4])
+		}
+		tables[string(rec[:4])] = data[offset : offset+length]
+	}
+	return tables, nil
+}
+
+// parseCmap reads the Unicode character to glyph mapping, format 12 when present, otherwise format 4
+func parseCmap(cmap []byte, numGlyphs int) (map[rune]uint16, error) {
+	var format4, format12 []byte
+	numTables := int(binary.BigEndian.Uint16(cmap[2:]))
+	for i := 0; i < numTables; i++ {
+		rec := cmap[4+8*i:]
+		platform, encoding := binary.BigEndian.Uint16(rec), binary.BigEndian.Uint16(rec[2:])
+		sub := cmap[binary.BigEndian.Uint32(rec[4:]):]
+		unicode := platform == 0 || (platform == 3 && (encoding == 1 || encoding == 10))
+		if !unicode {
+			continue
+		}
+		switch binary.BigEndian.Uint16(sub) {
+		case 4:
+			format4 = sub
+		case 12:
+			format12 = sub
+		}
+	}
+
+	glyphs := make(map[rune]uint16)
+	add := func(r rune, gid uint32) {
+		if gid != 0 && int(gid) < numGlyphs {
+			glyphs[r] = uint16(gid)
+		}
+	}
+
+	switch {
+	case format12 != nil:
+		groups := int(binary.BigEndian.Uint32(format12[12:]))
+		for i := 0; i < groups; i++ {
+			g := format12[16+12*i:]
+			start, end, startGlyph := binary.BigEndian.Uint32(g), binary.BigEndian.Uint32(g[4:]), binary.BigEndian.Uint32(g[8:])
+			if end > 0x10FFFF || end < start {
+				continue
+			}
+			for c := start; c <= end; c++ {
+				add(rune(c), startGlyph+c-start)
+			}
+		}
+	case format4 != nil:
+		segCount := int(binary.BigEndian.Uint16(format4[6:])) / 2
+		ends := 14
+		starts := ends + 2*segCount + 2
+		deltas := starts + 2*segCount
+		rangeOffsets := deltas + 2*segCount
+		for i := 0; i < segCount; i++ {
+			end := int(binary.BigEndian.Uint16(format4[ends+2*i:]))
+			start := int(binary.BigEndian.Uint16(format4[starts+2*i:]))
+			delta := int(binary.BigEndian.Uint16(format4[deltas+2*i:]))
+			rangeOffset := int(binary.BigEndian.Uint16(format4[rangeOffsets+2*i:]))
+			for c := start; c <= end && c != 0xFFFF; c++ {
+				if rangeOffset == 0 {
+					add(rune(c), uint32((c+delta)&0xFFFF))
+					continue
+				}
+				gid := int(binary.BigEndian.Uint16(format4[rangeOffsets+2*i+rangeOffset+2*(c-start):]))
+				if gid != 0 {
+					add(rune(c), uint32((gid+delta)&0xFFFF))
+				}
+			}
+		}
+	default:
+		return nil, errors.New("font has no Unicode cmap")
+	}
+	return glyphs, nil
+}
+
+// width returns the advance of a glyph in 1/1000 em
+func (f *pdfFont) width(gid uint16) int {
+	return int(f.advances[gid]) * 1000 / f.unitsPerEm
+}
+
+// scale converts font units to 1/1000 em
+func (f *pdfFont) scale(v int16) int {
+	return int(v) * 1000 / f.unitsPerEm
+}
+
+// pdfShape splits text into runs of glyphs, each from the first font covering its
+// characters. Characters no font covers are drawn as the missing glyph of the primary font.
+func pdfShape(text string, bold bool) []pdfGlyphRun {
+	primary := pdfRegularFont
+	if bold {
+		primary = pdfBoldFont
+	}
+
+	pdfFallbackMu.RLock()
+	defer pdfFallbackMu.RUnlock()
+
+	var runs []pdfGlyphRun
+	for _, r := range text {
+		if r == '\n' || r == '\r' || r == '\t' {
+			r = ' '
+		}
+		font := primary
+		gid, ok := primary.cmap[r]
+		for _, fallback := range pdfFallbackFonts {
+			if ok {
+				break
+			}
+			if gid, ok = fallback.cmap[r]; ok {
+				font = fallback
+			}
+		}
+
+		if len(runs) == 0 || runs[len(runs)-1].font != font {
+			runs = append(runs, pdfGlyphRun{font: font})
+		}
+		run := &runs[len(runs)-1]
+		run.glyphs = append(run.glyphs, gid)
+		run.runes = append(run.runes, r)
+	}
+	return runs
+}
+
+// subset returns a TrueType font with the outlines of the used glyphs only. Glyph IDs
+// are kept, so text can refer to the same glyphs as in the full font.
+func (f *pdfFont) subset(used map[uint16]rune) (out []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			out, err = nil, fmt.Errorf("subset font %s: %v", f.name, r)
+		}
+	}()
+
+	glyf := f.tables["glyf"]
+	keep := map[uint16]bool{0: true}
+	queue := []uint16{0}
+	for gid := range used {
+		if !keep[gid] {
+			keep[gid] = true
+			queue = append(queue, gid)
+		}
+	}
+	// Composite glyphs are drawn from their component glyphs
+	for len(queue) > 0 {
+		gid := queue[0]
+		queue = queue[1:]
+		for _, component := range glyphComponents(glyf[f.loca[gid]:f.loca[gid+1]]) {
+			if !keep[component] {
+				keep[component] = true
+				queue = append(queue, component)
+			}
+		}
+	}
+
+	numGlyphs := len(f.loca) - 1
+	var newGlyf bytes.Buffer
+	newLoca := make([]byte, 4*(numGlyphs+1))
+	for gid := 0; gid < numGlyphs; gid++ {
+		binary.BigEndian.PutUint32(newLoca[4*gid:], uint32(newGlyf.Len()))
+		if keep[uint16(gid)] {
+			newGlyf.Write(glyf[f.loca[gid]:f.loca[gid+1]])
+			for newGlyf.Len()%4 != 0 {
+				newGlyf.WriteByte(0)
+			}
+		}
+	}
+	binary.BigEndian.PutUint32(newLoca[4*numGlyphs:], uint32(newGlyf.Len()))
+
+	// Long loca offsets, the checksum adjustment is not checked by PDF readers
+	head := append([]byte(nil), f.tables["head"]...)
+	binary.BigEndian.PutUint32(head[8:], 0)
+	binary.BigEndian.PutUint16(head[50:], 1)
+
+	tables := map[string][]byte{
+		"head": head,
+		"hhea": f.tables["hhea"],
+		"maxp": f.tables["maxp"],
+		"hmtx": f.tables["hmtx"],
+		"loca": newLoca,
+		"glyf": newGlyf.Bytes(),
+	}
+	for _, tag := range []string{"cvt ", "fpgm", "prep"} {
+		if t, ok := f.tables[tag]; ok {
+			tables[tag] = t
+		}
+	}
+	return writeSFNT(tables), nil
+}
+
+// glyphComponents returns the glyphs a composite glyph is made of
+func glyphComponents(glyph []byte) []uint16 {
+	if len(glyph) < 10 || int16(binary.BigEndian.Uint16(glyph)) >= 0 {
+		return nil
+	}
+
+	const (
+		argsAreWords   = 0x0001
+		haveScale      = 0x0008
+		moreComponents = 0x0020
+		haveXYScale    = 0x0040
+		haveTwoByTwo   = 0x0080
+	)
+	var components []uint16
+	for pos := 10; ; {
+		flags := binary.BigEndian.Uint16(glyph[pos:])
+		components = append(components, binary.BigEndian.Uint16(glyph[pos+2:]))
+		pos += 4
+		if flags&argsAreWords != 0 {
+			pos += 4
+		} else {
+			pos += 2
+		}
+		switch {
+		case flags&haveScale != 0:
+			pos += 2
+		case flags&haveXYScale != 0:
+			pos += 4
+		case flags&haveTwoByTwo != 0:
+			pos += 8
+		}
+		if flags&moreComponents == 0 {
+			return components
+		}
+	}
+}
+
+// writeSFNT serializes TrueType tables into a font file
+func writeSFNT(tables map[string][]byte) []byte {
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	searchRange, entrySelector := 1, 0
+	for searchRange*2 <= len(tags) {
+		searchRange *= 2
+		entrySelector++
+	}
+
+	var out bytes.Buffer
+	header := make([]byte, 12+16*len(tags))
+	binary.BigEndian.PutUint32(header, 0x00010000)
+	binary.BigEndian.PutUint16(header[4:], uint16(len(tags)))
+	binary.BigEndian.PutUint16(header[6:], uint16(searchRange*16))
+	binary.BigEndian.PutUint16(header[8:], uint16(entrySelector))
+	binary.BigEndian.PutUint16(header[10:], uint16((len(tags)-searchRange)*16))
+
+	offset := len(header)
+	var body bytes.Buffer
+	for i, tag := range tags {
+		data := tables[tag]
+		rec := header[12+16*i:]
+		copy(rec, tag)
+		binary.BigEndian.PutUint32(rec[4:], sfntChecksum(data))
+		binary.BigEndian.PutUint32(rec[8:], uint32(offset+body.Len()))
+		binary.BigEndian.PutUint32(rec[12:], uint32(len(data)))
+		body.Write(data)
+		for body.Len()%4 != 0 {
+			body.WriteByte(0)
+		}
+	}
+	out.Write(header)
+	out.Write(body.Bytes())
+	return out.Bytes()
+}
+
+func sfntChecksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
+
+// pdfDocFont is a font used by a document and the glyphs drawn with it
+type pdfDocFont struct {
+	font *pdfFont
+	used map[uint16]rune
+}
+
+// objects appends the PDF objects of a Type0 font with Identity-H encoding and
+// returns the object number of the font dictionary
+func (df *pdfDocFont) objects(index int, add func([]byte) int) (int, error) {
+	f := df.font
+	subset, err := f.subset(df.used)
+	if err != nil {
+		return 0, err
+	}
+	compressed, err := pdfDeflate(subset)
+	if err != nil {
+		return 0, err
+	}
+
+	var file bytes.Buffer
+	fmt.Fprintf(&file, "<< /Length %d /Length1 %d /Filter /FlateDecode >>\nstream\n", len(compressed), len(subset))
+	file.Write(compressed)
+	file.WriteString("\nendstream")
+	fileRef := add(file.Bytes())
+
+	// Subset fonts are named with a six letter tag
+	baseFont := fmt.Sprintf("SEAPL%c+%s", 'A'+rune(index%26), f.name)
+	descriptorRef := add([]byte(fmt.Sprintf(
+		"<< /Type /FontDescriptor /FontName /%s /Flags 32 /FontBBox [%d %d %d %d] /ItalicAngle 0 /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+		baseFont, f.scale(f.bbox[0]), f.scale(f.bbox[1]), f.scale(f.bbox[2]), f.scale(f.bbox[3]),
+		f.scale(f.ascent), f.scale(f.descent), f.scale(f.capHeight), fileRef)))
+
+	gids := make([]int, 0, len(df.used))
+	for gid := range df.used {
+		gids = append(gids, int(gid))
+	}
+	sort.Ints(gids)
+
+	var widths strings.Builder
+	for _, gid := range gids {
+		fmt.Fprintf(&widths, "%d [%d] ", gid, f.width(uint16(gid)))
+	}
+	cidFontRef := add([]byte(fmt.Sprintf(
+		"<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /DW 1000 /W [%s] /CIDToGIDMap /Identity >>",
+		baseFont, descriptorRef, widths.String())))
+
+	// The ToUnicode map keeps text in the document searchable and copyable
+	var cmap bytes.Buffer
+	cmap.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n" +
+		"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	for start := 0; start < len(gids); start += 100 {
+		end := start + 100
+		if end > len(gids) {
+			end = len(gids)
+		}
+		fmt.Fprintf(&cmap, "%d beginbfchar\n", end-start)
+		for _, gid := range gids[start:end] {
+			fmt.Fprintf(&cmap, "<%04X> <", gid)
+			for _, unit := range utf16.Encode([]rune{df.used[uint16(gid)]}) {
+				fmt.Fprintf(&cmap, "%04X", unit)
+			}
+			cmap.WriteString(">\n")
+		}
+		cmap.WriteString("endbfchar\n")
+	}
+	cmap.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend")
+
+	compressedCmap, err := pdfDeflate(cmap.Bytes())
+	if err != nil {
+		return 0, err
+	}
+	var toUnicode bytes.Buffer
+	fmt.Fprintf(&toUnicode, "<< /Length %d /Filter /FlateDecode >>\nstream\n", len(compressedCmap))
+	toUnicode.Write(compressedCmap)
+	toUnicode.WriteString("\nendstream")
+	toUnicodeRef := add(toUnicode.Bytes())
+
+	return add([]byte(fmt.Sprintf(
+		"<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+		baseFont, cidFontRef, toUnicodeRef))), nil
+}
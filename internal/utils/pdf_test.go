@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestPDFShape(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		bold    bool
+		missing int
+	}{
+		{"rupiah", "Rp 150.000", false, 0},
+		{"baht", "฿1,250.00", false, 0},
+		{"peso", "₱ 499.00", true, 0},
+		{"ringgit", "RM 25.90", false, 0},
+		{"accented name", "Pokémon Café — Ünïcödé", false, 0},
+		{"cyrillic", "Пополнение", true, 0},
+		{"thai script needs a registered font", "เติม", false, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var missing, glyphs int
+			for _, run := range pdfShape(tt.text, tt.bold) {
+				for _, gid := range run.glyphs {
+					glyphs++
+					if gid == 0 {
+						missing++
+					}
+				}
+			}
+			if glyphs != len([]rune(tt.text)) {
+				t.Errorf("%d glyphs for %d characters", glyphs, len([]rune(tt.text)))
+			}
+			if missing != tt.missing {
+				t.Errorf("%d missing glyphs, want %d", missing, tt.missing)
+			}
+		})
+	}
+}
+
+func TestPDFTextWidth(t *testing.T) {
+	if w := PDFTextWidth("", 10, false); w != 0 {
+		t.Errorf("empty text width = %v, want 0", w)
+	}
+	short, long := PDFTextWidth("Rp 1", 10, false), PDFTextWidth("Rp 1.000.000", 10, false)
+	if short <= 0 || long <= short {
+		t.Errorf("widths %v and %v do not grow with the text", short, long)
+	}
+	if PDFTextWidth("Total", 20, false) != 2*PDFTextWidth("Total", 10, false) {
+		t.Error("width does not scale with the font size")
+	}
+	if PDFTextWidth("Total", 10, true) <= PDFTextWidth("Total", 10, false) {
+		t.Error("bold text is not wider than regular text")
+	}
+}
+
+func TestPDFFontSubset(t *testing.T) {
+	font := pdfRegularFont
+	used := map[uint16]rune{}
+	for _, r := range "AÄ฿" {
+		used[font.cmap[r]] = r
+	}
+
+	data, err := font.subset(used)
+	if err != nil {
+		t.Fatalf("subset: %v", err)
+	}
+	if len(data) >= len(dejaVuSans)/2 {
+		t.Errorf("subset is %d bytes, the full font %d", len(data), len(dejaVuSans))
+	}
+
+	tables, err := readSFNT(data)
+	if err != nil {
+		t.Fatalf("read subset: %v", err)
+	}
+	glyf, loca := tables["glyf"], tables["loca"]
+	glyph := func(gid uint16) []byte {
+		return glyf[binary.BigEndian.Uint32(loca[4*int(gid):]):binary.BigEndian.Uint32(loca[4*int(gid)+4:])]
+	}
+	original := func(gid uint16) []byte {
+		return font.tables["glyf"][font.loca[gid]:font.loca[gid+1]]
+	}
+
+	for gid := range used {
+		if !bytes.Equal(glyph(gid), padGlyph(original(gid))) {
+			t.Errorf("glyph %d differs from the full font", gid)
+		}
+		for _, component := range glyphComponents(original(gid)) {
+			if len(glyph(component)) == 0 {
+				t.Errorf("component %d of glyph %d was dropped", component, gid)
+			}
+		}
+	}
+	if unused := font.cmap['Z']; len(glyph(unused)) != 0 {
+		t.Errorf("unused glyph %d was kept", unused)
+	}
+}
+
+func padGlyph(b []byte) []byte {
+	for len(b)%4 != 0 {
+		b = append(b[:len(b):len(b)], 0)
+	}
+	return b
+}
+
+func TestRegisterPDFFontRejectsInvalidFonts(t *testing.T) {
+	for _, data := range [][]byte{nil, []byte("not a font"), dejaVuSans[:2048]} {
+		if err := RegisterPDFFont(data); err == nil {
+			t.Errorf("font of %d bytes was accepted", len(data))
+		}
+	}
+}
+
+func TestPDFDocumentBytes(t *testing.T) {
+	doc := NewPDFDocument()
+	doc.Text(40, 40, 12, true, "INVOICE")
+	doc.Text(40, 60, 10, false, "Total ฿1,250.00 / ₱ 499.00")
+	doc.AddPage()
+	doc.TextRight(500, 40, 10, false, "Rp 150.000")
+
+	data, err := doc.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes: %v", err)
+	}
+	for _, want := range []string{"%PDF-1.4", "/Type /Pages", "/Count 2", "/Subtype /Type0", "/FontFile2", "/ToUnicode", "%%EOF"} {
+		if !bytes.Contains(data, []byte(want)) {
+			t.Errorf("document has no %q", want)
+		}
+	}
+	if bytes.Contains(data, []byte("WinAnsiEncoding")) {
+		t.Error("document still uses WinAnsi fonts")
+	}
+}